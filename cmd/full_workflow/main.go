@@ -1,21 +1,28 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"novel-video-workflow/pkg/tools/aegisub"
 	"novel-video-workflow/pkg/tools/drawthings"
 	"novel-video-workflow/pkg/tools/file"
 	"novel-video-workflow/pkg/tools/indextts2"
+	"novel-video-workflow/pkg/tools/subtitle"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
 func main() {
+	style := flag.String("style", "", "图像风格预设名称，默认使用 "+drawthings.DefaultStylePresetName)
+	flag.Parse()
+
 	// 创建logger
 	logger, err := zap.NewProduction()
 	if err != nil {
@@ -51,6 +58,12 @@ func main() {
 		return
 	}
 
+	stylePreset, err := drawthings.ResolveStylePreset(*style)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+
 	fm.CreateOutputChapterStructure(inputDir)
 	wp := &WorkflowProcessor{
 		logger:        logger,
@@ -58,6 +71,25 @@ func main() {
 		ttsClient:     indextts2.NewIndexTTS2Client(logger, "http://localhost:7860"),
 		aegisubGen:    aegisub.NewAegisubGenerator(),
 		drawThingsGen: drawthings.NewChapterImageGenerator(logger),
+		stylePreset:   stylePreset,
+	}
+
+	// 加载或从前几章提取角色设定集，保持角色形象在各分镜中一致
+	if !viper.IsSet("image.character_bible.enabled") || viper.GetBool("image.character_bible.enabled") {
+		earlyCount := viper.GetInt("image.character_bible.early_chapters")
+		if earlyCount <= 0 {
+			earlyCount = 3
+		}
+		biblePath := drawthings.CharacterBiblePath(filepath.Join(dir, "output", "幽灵客栈"))
+		bible, err := drawthings.LoadOrBuildCharacterBible(biblePath, "幽灵客栈", wp.drawThingsGen.OllamaClient,
+			drawthings.EarlyChapters(file.ChapterMap, earlyCount))
+		if err != nil {
+			wp.logger.Warn("角色设定集生成失败，将不注入角色描述", zap.Error(err))
+			fmt.Printf("⚠️  角色设定集生成失败，将不注入角色描述: %v\n", err)
+		} else {
+			wp.drawThingsGen.CharacterBible = bible
+			fmt.Printf("👤 已加载角色设定集(%d个角色): %s\n", len(bible.Characters), biblePath)
+		}
 	}
 
 	// 执行测试
@@ -107,6 +139,21 @@ func main() {
 			fmt.Printf("⚠️  由于音频文件不存在，跳过字幕生成\n")
 		}
 
+		// 步骤3.1: 翻译字幕，生成双语字幕所需的译文SRT/VTT
+		if viper.GetBool("subtitle.translation.enabled") {
+			if _, err := os.Stat(subtitleFile); err == nil {
+				fmt.Println("🌐 步骤3.1 - 翻译字幕...")
+				translator := subtitle.NewSubtitleTranslator(logger, nil, "")
+				result, err := translator.TranslateSrtFile(subtitleFile)
+				if err != nil {
+					wp.logger.Warn("翻译字幕失败", zap.String("subtitle", subtitleFile), zap.Error(err))
+					fmt.Printf("⚠️  字幕翻译失败: %v\n", err)
+				} else {
+					fmt.Printf("✅ 字幕翻译完成: %s\n", result.SrtFile)
+				}
+			}
+		}
+
 		// 步骤4: 生成图像 (使用缩小的像素和Ollama优化的提示词)
 		fmt.Println("🎨 步骤4 - 生成图像...")
 		imagesDir := filepath.Join(outputDir, fmt.Sprintf("chapter_%02d", key))
@@ -139,12 +186,23 @@ func main() {
 		}
 
 		// 使用Ollama优化的提示词生成图像
-		err = wp.generateImagesWithOllamaPrompts(val, imagesDir, key, estimatedAudioDuration)
+		err = wp.generateImagesWithOllamaPrompts(context.Background(), val, imagesDir, key, estimatedAudioDuration)
 		if err != nil {
 			wp.logger.Warn("生成图像失败", zap.Error(err))
 			fmt.Printf("⚠️  图像生成失败: %v\n", err)
 		} else {
 			fmt.Printf("✅ 图像生成完成，保存在: %s\n", imagesDir)
+
+			// 步骤4.1: 将图像适配到视频画布尺寸（裁剪或模糊背景填充）
+			if opts, enabled := drawthings.PostProcessOptionsFromConfig(); enabled {
+				fmt.Println("🖼️  步骤4.1 - 适配图像到画布...")
+				if records, err := drawthings.PostProcessChapterImages(imagesDir, opts); err != nil {
+					wp.logger.Warn("图像画布适配失败", zap.Error(err))
+					fmt.Printf("⚠️  图像画布适配失败: %v\n", err)
+				} else {
+					fmt.Printf("✅ 已将 %d 张图像适配到 %dx%d\n", len(records), opts.Width, opts.Height)
+				}
+			}
 		}
 	}
 
@@ -213,7 +271,7 @@ func checkOllama() error {
 // checkDrawThings 检查DrawThings服务
 func checkDrawThings(logger *zap.Logger) error {
 	client := drawthings.NewDrawThingsClient(logger, "http://localhost:7861")
-	if !client.APIAvailable {
+	if !client.APIAvailable.Load() {
 		return fmt.Errorf("DrawThings API不可用")
 	}
 	return nil
@@ -274,12 +332,13 @@ type WorkflowProcessor struct {
 	ttsClient     *indextts2.IndexTTS2Client
 	aegisubGen    *aegisub.AegisubGenerator
 	drawThingsGen *drawthings.ChapterImageGenerator
+	stylePreset   *drawthings.StylePreset
 }
 
 // generateImagesWithOllamaPrompts 使用Ollama优化的提示词生成图像
-func (wp *WorkflowProcessor) generateImagesWithOllamaPrompts(content, imagesDir string, chapterNum int, audioDurationSecs int) error {
+func (wp *WorkflowProcessor) generateImagesWithOllamaPrompts(ctx context.Context, content, imagesDir string, chapterNum int, audioDurationSecs int) error {
 	// 使用Ollama分析整个章节内容并生成分镜提示词
-	styleDesc := "悬疑惊悚风格，周围环境模糊成黑影, 空气凝滞,浅景深, 胶片颗粒感, 低饱和度，极致悬疑氛围, 阴沉窒息感, 夏季，环境阴霾，其他部分模糊不可见"
+	styleDesc := wp.stylePreset.OllamaStyle
 
 	// 使用实际音频时长，如果未提供则估算
 	estimatedDurationSecs := audioDurationSecs
@@ -293,7 +352,7 @@ func (wp *WorkflowProcessor) generateImagesWithOllamaPrompts(content, imagesDir
 
 	// 让Ollama分析整个章节并生成分镜
 	wp.logger.Info("开始Ollama分镜分析", zap.Int("chapter_num", chapterNum), zap.Int("content_length", len(content)), zap.Int("estimated_duration_secs", estimatedDurationSecs))
-	sceneDescriptions, err := wp.drawThingsGen.OllamaClient.AnalyzeScenesAndGeneratePrompts(content, styleDesc, estimatedDurationSecs)
+	storyboard, err := wp.drawThingsGen.OllamaClient.AnalyzeScenesAndGeneratePrompts(content, styleDesc, estimatedDurationSecs)
 	if err != nil {
 		wp.logger.Warn("使用Ollama分析场景并生成分镜提示词失败",
 			zap.Error(err))
@@ -301,6 +360,8 @@ func (wp *WorkflowProcessor) generateImagesWithOllamaPrompts(content, imagesDir
 		// 如果Ollama场景分析失败，回退到原来的段落处理方式
 		wp.logger.Info("Ollama分镜分析失败，回退到段落处理方式")
 		paragraphs := wp.splitChapterIntoParagraphsWithMerge(content)
+		locator := drawthings.NewSpanLocator(content)
+		var scenes []drawthings.ScenePrompt
 
 		for idx, paragraph := range paragraphs {
 			if strings.TrimSpace(paragraph) == "" {
@@ -308,56 +369,68 @@ func (wp *WorkflowProcessor) generateImagesWithOllamaPrompts(content, imagesDir
 			}
 
 			optimizedPrompt, err := wp.drawThingsGen.OllamaClient.GenerateImagePrompt(paragraph, styleDesc)
+			styled := err == nil
 			if err != nil {
 				wp.logger.Warn("使用Ollama生成图像提示词失败，使用原始文本",
 					zap.Int("paragraph_index", idx),
 					zap.String("paragraph", paragraph),
 					zap.Error(err))
-				optimizedPrompt = paragraph + ", 周围环境模糊成黑影, 空气凝滞,浅景深, 胶片颗粒感, 低饱和度，极致悬疑氛围, 阴沉窒息感, 夏季，环境阴霾，其他部分模糊不可见"
+				optimizedPrompt = paragraph
 			}
 
 			imageFile := filepath.Join(imagesDir, fmt.Sprintf("paragraph_%02d.png", idx+1))
 
-			err = wp.drawThingsGen.Client.GenerateImageFromText(
-				optimizedPrompt,
-				imageFile,
-				512,   // 缩小宽度
-				896,   // 缩小高度
-				false, // 风格已在提示词中处理
-			)
-			if err != nil {
-				wp.logger.Warn("生成图像失败", zap.String("paragraph", paragraph[:min(len(paragraph), 50)]), zap.Error(err))
-				fmt.Printf("⚠️  段落图像生成失败: %v\n", err)
+			// 注入角色设定，保持角色形象一致
+			seed := -1
+			if wp.drawThingsGen.CharacterBible != nil {
+				optimizedPrompt, seed = wp.drawThingsGen.CharacterBible.ApplyToPrompt(optimizedPrompt, paragraph)
+			}
+
+			sourceStart, sourceEnd := locator.Locate(paragraph)
+			scenes = append(scenes, drawthings.ScenePrompt{
+				Index:       idx + 1,
+				ImageFile:   imageFile,
+				Prompt:      optimizedPrompt,
+				Seed:        seed,
+				Styled:      styled,
+				SourceText:  strings.TrimSpace(paragraph),
+				SourceStart: sourceStart,
+				SourceEnd:   sourceEnd,
+			})
+		}
+
+		// 通过工作池并发生成图像，风格修饰与尺寸由风格预设决定
+		results := wp.drawThingsGen.GenerateScenes(ctx, imagesDir, chapterNum, drawthings.ManifestSourceParagraphs, scenes, 0, 0, wp.stylePreset)
+		for k, result := range results {
+			if result.Err != nil {
+				paragraph := scenes[k].SourceText
+				wp.logger.Warn("生成图像失败", zap.String("paragraph", paragraph[:min(len(paragraph), 50)]), zap.Int("attempts", result.Attempts), zap.Error(result.Err))
+				fmt.Printf("⚠️  段落图像生成失败: %v\n", result.Err)
 			} else {
-				fmt.Printf("✅ 段落图像生成完成: %s\n", imageFile)
+				fmt.Printf("✅ 段落图像生成完成: %s\n", scenes[k].ImageFile)
 			}
 		}
 
-		return nil
+		return ctx.Err()
 	}
 
-	// 如果Ollama分镜分析成功，使用生成的分镜描述生成图像
-	wp.logger.Info("Ollama分镜分析成功", zap.Int("scene_count", len(sceneDescriptions)))
-	for idx, sceneDesc := range sceneDescriptions {
-		imageFile := filepath.Join(imagesDir, fmt.Sprintf("scene_%02d.png", idx+1))
-
-		// 使用分镜描述生成图像
-		err = wp.drawThingsGen.Client.GenerateImageFromText(
-			sceneDesc,
-			imageFile,
-			512,   // 缩小宽度
-			896,   // 缩小高度
-			false, // 风格已在提示词中处理
-		)
-		if err != nil {
-			wp.logger.Warn("生成分镜图像失败", zap.String("scene", sceneDesc[:min(len(sceneDesc), 50)]), zap.Error(err))
-			fmt.Printf("⚠️  分镜图像生成失败: %v\n", err)
+	// 如果Ollama分镜分析成功，使用结构化分镜生成图像，原文范围按分镜摘录定位
+	wp.logger.Info("Ollama分镜分析成功", zap.Int("scene_count", len(storyboard)))
+	scenes := wp.drawThingsGen.StoryboardScenePrompts(imagesDir, storyboard)
+
+	// 通过工作池并发生成分镜图像，风格修饰与尺寸由风格预设决定
+	results := wp.drawThingsGen.GenerateScenes(ctx, imagesDir, chapterNum, drawthings.ManifestSourceOllamaScenes, scenes, 0, 0, wp.stylePreset)
+	for k, result := range results {
+		if result.Err != nil {
+			sceneDesc := scenes[k].Prompt
+			wp.logger.Warn("生成分镜图像失败", zap.String("scene", sceneDesc[:min(len(sceneDesc), 50)]), zap.Int("attempts", result.Attempts), zap.Error(result.Err))
+			fmt.Printf("⚠️  分镜图像生成失败: %v\n", result.Err)
 		} else {
-			fmt.Printf("✅ 分镜图像生成完成: %s\n", imageFile)
+			fmt.Printf("✅ 分镜图像生成完成: %s\n", scenes[k].ImageFile)
 		}
 	}
 
-	return nil
+	return ctx.Err()
 }
 
 // splitChapterIntoParagraphsWithMerge 将章节文本分割为段落，并对短段落进行合并
@@ -1,6 +1,7 @@
 package web_server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -11,8 +12,10 @@ import (
 	"novel-video-workflow/pkg/broadcast"
 	"novel-video-workflow/pkg/capcut"
 	"novel-video-workflow/pkg/tools/aegisub"
+	"novel-video-workflow/pkg/tools/cover"
 	"novel-video-workflow/pkg/tools/file"
 	"novel-video-workflow/pkg/tools/indextts2"
+	"novel-video-workflow/pkg/tools/subtitle"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,6 +28,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -103,10 +107,18 @@ func fallbackToolList() {
 		"generate_indextts2_audio":                    "使用IndexTTS2生成音频文件，具有高级语音克隆功能",
 		"generate_subtitles_from_indextts2":           "使用Aegisub从IndexTTS2音频和提供的文本生成字幕(SRT)",
 		"file_split_novel_into_chapters":              "根据章节标记将小说文件拆分为单独的章节文件夹和文件",
-		"generate_image_from_text":                    "使用DrawThings API根据文本生成图像，采用悬疑风格",
-		"generate_image_from_image":                   "使用DrawThings API根据参考图像生成图像，采用悬疑风格",
-		"generate_images_from_chapter":                "使用DrawThings API根据章节文本生成图像，采用悬疑风格",
-		"generate_images_from_chapter_with_ai_prompt": "使用AI生成提示词和DrawThings API根据章节文本生成图像，采用悬疑风格",
+		"generate_image_from_text":                    "使用DrawThings API根据文本生成图像，支持风格预设",
+		"generate_image_from_image":                   "使用DrawThings API根据参考图像生成图像，支持风格预设",
+		"generate_images_from_chapter":                "使用DrawThings API根据章节文本生成图像，支持风格预设",
+		"generate_images_from_chapter_with_ai_prompt": "使用AI生成提示词和DrawThings API根据章节文本生成图像，支持风格预设",
+		"translate_subtitles":                         "使用Ollama分批翻译SRT字幕，生成双语字幕所需的译文SRT/VTT",
+		"regenerate_scene_image":                      "根据图像生成清单重新生成单张分镜图像，可沿用或更换种子与提示词",
+		"generate_episode_cover":                      "以关键画面为背景生成带小说名、集数和钩子文案的剧集封面，支持多平台尺寸",
+		"export_timeline":                             "将章节时间线导出为 OpenTimelineIO、FCPXML、Premiere XML 或 MLT，供 DaVinci Resolve、Final Cut Pro、Premiere、Kdenlive 等剪辑软件使用",
+		"import_timeline":                             "读回剪辑软件修改后的时间线文件（.otio），更新章节的 timeline.json",
+		"render_timeline":                             "将章节时间线渲染成最终视频（ffmpeg 或 melt），带运动效果、转场、烧录字幕和背景音乐闪避",
+		"render_animatic":                             "不依赖剪映和 ffmpeg 生成低分辨率的分镜预览（GIF 或 PNG 序列），可跟随旁白检查节奏",
+		"lint_draft":                                  "在剪映中打开之前检查草稿：素材引用、素材文件、片段重叠、片段与素材时长、画布帧率和目标版本所需字段",
 	}
 
 	defaultTools := []string{
@@ -117,6 +129,14 @@ func fallbackToolList() {
 		"generate_image_from_image",
 		"generate_images_from_chapter",
 		"generate_images_from_chapter_with_ai_prompt",
+		"translate_subtitles",
+		"regenerate_scene_image",
+		"generate_episode_cover",
+		"export_timeline",
+		"import_timeline",
+		"render_timeline",
+		"render_animatic",
+		"lint_draft",
 	}
 
 	for _, toolName := range defaultTools {
@@ -140,10 +160,18 @@ func getToolDescription(toolName string) string {
 		"generate_indextts2_audio":                    "使用IndexTTS2生成音频文件，具有高级语音克隆功能",
 		"generate_subtitles_from_indextts2":           "使用Aegisub从IndexTTS2音频和提供的文本生成字幕(SRT)",
 		"file_split_novel_into_chapters":              "根据章节标记将小说文件拆分为单独的章节文件夹和文件",
-		"generate_image_from_text":                    "使用DrawThings API根据文本生成图像，采用悬疑风格",
-		"generate_image_from_image":                   "使用DrawThings API根据参考图像生成图像，采用悬疑风格",
-		"generate_images_from_chapter":                "使用DrawThings API根据章节文本生成图像，采用悬疑风格",
-		"generate_images_from_chapter_with_ai_prompt": "使用AI生成提示词和DrawThings API根据章节文本生成图像，采用悬疑风格",
+		"generate_image_from_text":                    "使用DrawThings API根据文本生成图像，支持风格预设",
+		"generate_image_from_image":                   "使用DrawThings API根据参考图像生成图像，支持风格预设",
+		"generate_images_from_chapter":                "使用DrawThings API根据章节文本生成图像，支持风格预设",
+		"generate_images_from_chapter_with_ai_prompt": "使用AI生成提示词和DrawThings API根据章节文本生成图像，支持风格预设",
+		"translate_subtitles":                         "使用Ollama分批翻译SRT字幕，生成双语字幕所需的译文SRT/VTT",
+		"regenerate_scene_image":                      "根据图像生成清单重新生成单张分镜图像，可沿用或更换种子与提示词",
+		"generate_episode_cover":                      "以关键画面为背景生成带小说名、集数和钩子文案的剧集封面，支持多平台尺寸",
+		"export_timeline":                             "将章节时间线导出为 OpenTimelineIO、FCPXML、Premiere XML 或 MLT，供 DaVinci Resolve、Final Cut Pro、Premiere、Kdenlive 等剪辑软件使用",
+		"import_timeline":                             "读回剪辑软件修改后的时间线文件（.otio），更新章节的 timeline.json",
+		"render_timeline":                             "将章节时间线渲染成最终视频（ffmpeg 或 melt），带运动效果、转场、烧录字幕和背景音乐闪避",
+		"render_animatic":                             "不依赖剪映和 ffmpeg 生成低分辨率的分镜预览（GIF 或 PNG 序列），可跟随旁白检查节奏",
+		"lint_draft":                                  "在剪映中打开之前检查草稿：素材引用、素材文件、片段重叠、片段与素材时长、画布帧率和目标版本所需字段",
 	}
 
 	if desc, exists := descriptions[toolName]; exists {
@@ -351,6 +379,30 @@ func apiExecuteHandler(c *gin.Context) {
 					case "generate_image_from_image":
 						mockRequest := &mcp_pkg.MockRequest{Params: params}
 						result, err = handler.HandleGenerateImageFromImageDirect(mockRequest)
+					case "translate_subtitles":
+						mockRequest := &mcp_pkg.MockRequest{Params: params}
+						result, err = handler.HandleTranslateSubtitlesDirect(mockRequest)
+					case "regenerate_scene_image":
+						mockRequest := &mcp_pkg.MockRequest{Params: params}
+						result, err = handler.HandleRegenerateSceneImageDirect(mockRequest)
+					case "generate_episode_cover":
+						mockRequest := &mcp_pkg.MockRequest{Params: params}
+						result, err = handler.HandleGenerateEpisodeCoverDirect(mockRequest)
+					case "export_timeline":
+						mockRequest := &mcp_pkg.MockRequest{Params: params}
+						result, err = handler.HandleExportTimelineDirect(mockRequest)
+					case "import_timeline":
+						mockRequest := &mcp_pkg.MockRequest{Params: params}
+						result, err = handler.HandleImportTimelineDirect(mockRequest)
+					case "render_timeline":
+						mockRequest := &mcp_pkg.MockRequest{Params: params}
+						result, err = handler.HandleRenderTimelineDirect(mockRequest)
+					case "render_animatic":
+						mockRequest := &mcp_pkg.MockRequest{Params: params}
+						result, err = handler.HandleRenderAnimaticDirect(mockRequest)
+					case "lint_draft":
+						mockRequest := &mcp_pkg.MockRequest{Params: params}
+						result, err = handler.HandleLintDraftDirect(mockRequest)
 					case "generate_images_from_chapter_with_ai_prompt":
 						// 处理章节图像生成（使用AI提示词）
 						chapterText, ok := reqBody["chapter_text"].(string)
@@ -379,6 +431,14 @@ func apiExecuteHandler(c *gin.Context) {
 							height = 896 // 默认高度
 						}
 
+						// 风格预设，未指定时使用配置的默认预设
+						styleName, _ := reqBody["style"].(string)
+						preset, err := drawthings.ResolveStylePreset(styleName)
+						if err != nil {
+							broadcast.GlobalBroadcastService.SendLog(toolName, fmt.Sprintf("[%s] 错误: %v", toolName, err), broadcast.GetTimeStr())
+							return
+						}
+
 						// 确保输出目录存在
 						if err := os.MkdirAll(outputDir, 0755); err != nil {
 							return
@@ -398,7 +458,7 @@ func apiExecuteHandler(c *gin.Context) {
 						generator := drawthings.NewChapterImageGenerator(broadcaster)
 
 						// 直接调用图像生成方法，而不是通过MCP处理器
-						results, err := generator.GenerateImagesFromChapter(chapterText, outputDir, width, height, true)
+						results, err := generator.GenerateImagesFromChapter(chapterText, outputDir, width, height, preset)
 						if err != nil {
 							return
 						}
@@ -424,7 +484,7 @@ func apiExecuteHandler(c *gin.Context) {
 							"prompts":               prompts,
 							"width":                 width,
 							"height":                height,
-							"is_suspense":           true,
+							"style":                 preset.Name,
 							"tool":                  "drawthings_chapter_txt2img_with_ai_prompt",
 						}
 					default:
@@ -1053,6 +1113,14 @@ func webServerMain() {
 	r.POST("/api/one-click-film", oneClickFilmHandler)
 	// 添加CapCut项目生成API端点
 	r.GET("/api/capcut-project", capcutProjectHandler)
+	// 单张分镜图像重新生成API端点
+	r.POST("/api/images/regenerate", imageRegenerateHandler)
+	// 剧集封面生成API端点
+	r.POST("/api/covers", coverGenerateHandler)
+	// 分镜预览生成API端点
+	r.POST("/api/animatic", animaticHandler)
+	// 风格预设列表API端点
+	r.GET("/api/styles", styleListHandler)
 	// 添加文件管理API端点
 	r.GET("/api/files/list", fileListHandler)
 	r.GET("/api/files/content", fileContentHandler)
@@ -1108,12 +1176,13 @@ type WorkflowProcessor struct {
 	ttsClient     *indextts2.IndexTTS2Client
 	aegisubGen    *aegisub.AegisubGenerator
 	drawThingsGen *drawthings.ChapterImageGenerator
+	stylePreset   *drawthings.StylePreset
 }
 
 // generateImagesWithOllamaPrompts 使用Ollama优化的提示词生成图像
-func (wp *WorkflowProcessor) generateImagesWithOllamaPrompts(content, imagesDir string, chapterNum int, audioDurationSecs int) error {
-	// 使用Ollama分析整个章节内容并生成分镜提示词
-	styleDesc := "悬疑惊悚风格，周围环境模糊成黑影, 空气凝滞,浅景深, 胶片颗粒感, 低饱和度，极致悬疑氛围, 阴沉窒息感, 夏季，环境阴霾，其他部分模糊不可见"
+func (wp *WorkflowProcessor) generateImagesWithOllamaPrompts(ctx context.Context, content, imagesDir string, chapterNum int, audioDurationSecs int) error {
+	// 使用Ollama按风格预设的风格描述分析整个章节内容并生成分镜提示词
+	styleDesc := wp.stylePreset.OllamaStyle
 
 	// 使用实际音频时长，如果未提供则估算
 	estimatedDurationSecs := audioDurationSecs
@@ -1127,7 +1196,7 @@ func (wp *WorkflowProcessor) generateImagesWithOllamaPrompts(content, imagesDir
 
 	// 让Ollama分析整个章节并生成分镜
 	wp.logger.Info("开始Ollama分镜分析", zap.Int("chapter_num", chapterNum), zap.Int("content_length", len(content)), zap.Int("estimated_duration_secs", estimatedDurationSecs))
-	sceneDescriptions, err := wp.drawThingsGen.OllamaClient.AnalyzeScenesAndGeneratePrompts(content, styleDesc, estimatedDurationSecs)
+	storyboard, err := wp.drawThingsGen.OllamaClient.AnalyzeScenesAndGeneratePrompts(content, styleDesc, estimatedDurationSecs)
 	if err != nil {
 		wp.logger.Warn("使用Ollama分析场景并生成分镜提示词失败",
 			zap.Error(err))
@@ -1135,6 +1204,8 @@ func (wp *WorkflowProcessor) generateImagesWithOllamaPrompts(content, imagesDir
 		// 如果Ollama场景分析失败，回退到原来的段落处理方式
 		wp.logger.Info("Ollama分镜分析失败，回退到段落处理方式")
 		paragraphs := wp.splitChapterIntoParagraphsWithMerge(content)
+		locator := drawthings.NewSpanLocator(content)
+		var scenes []drawthings.ScenePrompt
 
 		for idx, paragraph := range paragraphs {
 			if strings.TrimSpace(paragraph) == "" {
@@ -1142,56 +1213,68 @@ func (wp *WorkflowProcessor) generateImagesWithOllamaPrompts(content, imagesDir
 			}
 
 			optimizedPrompt, err := wp.drawThingsGen.OllamaClient.GenerateImagePrompt(paragraph, styleDesc)
+			styled := err == nil
 			if err != nil {
 				wp.logger.Warn("使用Ollama生成图像提示词失败，使用原始文本",
 					zap.Int("paragraph_index", idx),
 					zap.String("paragraph", paragraph),
 					zap.Error(err))
-				optimizedPrompt = paragraph + ", 周围环境模糊成黑影, 空气凝滞,浅景深, 胶片颗粒感, 低饱和度，极致悬疑氛围, 阴沉窒息感, 夏季，环境阴霾，其他部分模糊不可见"
+				optimizedPrompt = paragraph
 			}
 
 			imageFile := filepath.Join(imagesDir, fmt.Sprintf("paragraph_%02d.png", idx+1))
 
-			err = wp.drawThingsGen.Client.GenerateImageFromText(
-				optimizedPrompt,
-				imageFile,
-				512,   // 缩小宽度
-				896,   // 缩小高度
-				false, // 风格已在提示词中处理
-			)
-			if err != nil {
-				wp.logger.Warn("生成图像失败", zap.String("paragraph", paragraph[:min(len(paragraph), 50)]), zap.Error(err))
-				fmt.Printf("⚠️  段落图像生成失败: %v\n", err)
+			// 注入角色设定，保持角色形象一致
+			seed := -1
+			if wp.drawThingsGen.CharacterBible != nil {
+				optimizedPrompt, seed = wp.drawThingsGen.CharacterBible.ApplyToPrompt(optimizedPrompt, paragraph)
+			}
+
+			sourceStart, sourceEnd := locator.Locate(paragraph)
+			scenes = append(scenes, drawthings.ScenePrompt{
+				Index:       idx + 1,
+				ImageFile:   imageFile,
+				Prompt:      optimizedPrompt,
+				Seed:        seed,
+				Styled:      styled,
+				SourceText:  strings.TrimSpace(paragraph),
+				SourceStart: sourceStart,
+				SourceEnd:   sourceEnd,
+			})
+		}
+
+		// 通过工作池并发生成图像，风格修饰与尺寸由风格预设决定
+		results := wp.drawThingsGen.GenerateScenes(ctx, imagesDir, chapterNum, drawthings.ManifestSourceParagraphs, scenes, 0, 0, wp.stylePreset)
+		for k, result := range results {
+			if result.Err != nil {
+				paragraph := scenes[k].SourceText
+				wp.logger.Warn("生成图像失败", zap.String("paragraph", paragraph[:min(len(paragraph), 50)]), zap.Int("attempts", result.Attempts), zap.Error(result.Err))
+				fmt.Printf("⚠️  段落图像生成失败: %v\n", result.Err)
 			} else {
-				fmt.Printf("✅ 段落图像生成完成: %s\n", imageFile)
+				fmt.Printf("✅ 段落图像生成完成: %s\n", scenes[k].ImageFile)
 			}
 		}
 
-		return nil
+		return ctx.Err()
 	}
 
-	// 如果Ollama分镜分析成功，使用生成的分镜描述生成图像
-	wp.logger.Info("Ollama分镜分析成功", zap.Int("scene_count", len(sceneDescriptions)))
-	for idx, sceneDesc := range sceneDescriptions {
-		imageFile := filepath.Join(imagesDir, fmt.Sprintf("scene_%02d.png", idx+1))
+	// 如果Ollama分镜分析成功，使用结构化分镜生成图像，原文范围按分镜摘录定位
+	wp.logger.Info("Ollama分镜分析成功", zap.Int("scene_count", len(storyboard)))
+	scenes := wp.drawThingsGen.StoryboardScenePrompts(imagesDir, storyboard)
 
-		// 使用分镜描述生成图像
-		err = wp.drawThingsGen.Client.GenerateImageFromText(
-			sceneDesc,
-			imageFile,
-			512,   // 缩小宽度
-			896,   // 缩小高度
-			false, // 风格已在提示词中处理
-		)
-		if err != nil {
-			wp.logger.Warn("生成分镜图像失败", zap.String("scene", sceneDesc[:min(len(sceneDesc), 50)]), zap.Error(err))
-			fmt.Printf("⚠️  分镜图像生成失败: %v\n", err)
+	// 通过工作池并发生成分镜图像，风格修饰与尺寸由风格预设决定
+	results := wp.drawThingsGen.GenerateScenes(ctx, imagesDir, chapterNum, drawthings.ManifestSourceOllamaScenes, scenes, 0, 0, wp.stylePreset)
+	for k, result := range results {
+		if result.Err != nil {
+			sceneDesc := scenes[k].Prompt
+			wp.logger.Warn("生成分镜图像失败", zap.String("scene", sceneDesc[:min(len(sceneDesc), 50)]), zap.Int("attempts", result.Attempts), zap.Error(result.Err))
+			fmt.Printf("⚠️  分镜图像生成失败: %v\n", result.Err)
 		} else {
-			fmt.Printf("✅ 分镜图像生成完成: %s\n", imageFile)
+			fmt.Printf("✅ 分镜图像生成完成: %s\n", scenes[k].ImageFile)
 		}
 	}
 
-	return nil
+	return ctx.Err()
 }
 
 // splitChapterIntoParagraphsWithMerge 将章节文本分割为段落，并对短段落进行合并
@@ -1288,6 +1371,15 @@ func oneClickFilmHandler(c *gin.Context) {
 		projectRoot = filepath.Dir(filepath.Dir(wd)) // 回退两级到项目根目录
 	}
 
+	// 风格预设，通过 ?style=<名称> 指定，未指定时使用配置的默认预设
+	stylePreset, err := drawthings.ResolveStylePreset(c.Query("style"))
+	if err != nil {
+		broadcast.GlobalBroadcastService.SendLog("movie", fmt.Sprintf("[一键出片] ❌ %v", err), broadcast.GetTimeStr())
+		c.JSON(http.StatusOK, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	broadcast.GlobalBroadcastService.SendLog("movie", fmt.Sprintf("[一键出片] 🎨 使用风格预设: %s", stylePreset.Name), broadcast.GetTimeStr())
+
 	inputDir := filepath.Join(projectRoot, "input")
 	items, err := os.ReadDir(inputDir)
 	if err != nil {
@@ -1356,6 +1448,24 @@ func oneClickFilmHandler(c *gin.Context) {
 						ttsClient:     indextts2.NewIndexTTS2Client(logger, "http://localhost:7860"),
 						aegisubGen:    aegisub.NewAegisubGenerator(),
 						drawThingsGen: drawthings.NewChapterImageGenerator(logger),
+						stylePreset:   stylePreset,
+					}
+
+					// 加载或从前几章提取角色设定集，保持角色形象在各分镜中一致
+					if !viper.IsSet("image.character_bible.enabled") || viper.GetBool("image.character_bible.enabled") {
+						earlyCount := viper.GetInt("image.character_bible.early_chapters")
+						if earlyCount <= 0 {
+							earlyCount = 3
+						}
+						biblePath := drawthings.CharacterBiblePath(filepath.Join(projectRoot, "output", item.Name()))
+						bible, err := drawthings.LoadOrBuildCharacterBible(biblePath, item.Name(), wp.drawThingsGen.OllamaClient,
+							drawthings.EarlyChapters(file.ChapterMap, earlyCount))
+						if err != nil {
+							broadcast.GlobalBroadcastService.SendLog("image", fmt.Sprintf("[一键出片] ⚠️  角色设定集生成失败，将不注入角色描述: %v", err), broadcast.GetTimeStr())
+						} else {
+							wp.drawThingsGen.CharacterBible = bible
+							broadcast.GlobalBroadcastService.SendLog("image", fmt.Sprintf("[一键出片] 👤 已加载角色设定集(%d个角色): %s", len(bible.Characters), biblePath), broadcast.GetTimeStr())
+						}
 					}
 
 					// 广播开始生成音频
@@ -1408,6 +1518,20 @@ func oneClickFilmHandler(c *gin.Context) {
 							broadcast.GlobalBroadcastService.SendLog("aegisub", "[一键出片] ⚠️  由于音频文件不存在，跳过字幕生成", broadcast.GetTimeStr())
 
 						}
+
+						// 步骤3.1: 翻译字幕，生成双语字幕所需的译文SRT/VTT
+						if viper.GetBool("subtitle.translation.enabled") {
+							if _, err := os.Stat(subtitleFile); err == nil {
+								broadcast.GlobalBroadcastService.SendLog("translate", "[一键出片] 🌐 步骤3.1 - 翻译字幕...", broadcast.GetTimeStr())
+								translator := subtitle.NewSubtitleTranslator(logger, nil, "")
+								result, err := translator.TranslateSrtFile(subtitleFile)
+								if err != nil {
+									broadcast.GlobalBroadcastService.SendLog("translate", fmt.Sprintf("[一键出片] ⚠️  字幕翻译失败: %v", err), broadcast.GetTimeStr())
+								} else {
+									broadcast.GlobalBroadcastService.SendLog("translate", fmt.Sprintf("[一键出片] ✅ 字幕翻译完成: %s", result.SrtFile), broadcast.GetTimeStr())
+								}
+							}
+						}
 						broadcast.GlobalBroadcastService.SendLog("image", "[一键出片] 🎨 步骤4 - 生成图像...", broadcast.GetTimeStr())
 
 						// 步骤4: 生成图像
@@ -1439,11 +1563,20 @@ func oneClickFilmHandler(c *gin.Context) {
 						}
 
 						// 使用Ollama优化的提示词生成图像
-						err = wp.generateImagesWithOllamaPrompts(val, imagesDir, key, estimatedAudioDuration)
+						err = wp.generateImagesWithOllamaPrompts(c.Request.Context(), val, imagesDir, key, estimatedAudioDuration)
 						if err != nil {
 							broadcast.GlobalBroadcastService.SendLog("image", fmt.Sprintf("[一键出片] ⚠️  图像生成失败: %v", err), broadcast.GetTimeStr())
 						} else {
 							broadcast.GlobalBroadcastService.SendLog("image", fmt.Sprintf("[一键出片] ✅ 图像生成完成，保存在: %s", imagesDir), broadcast.GetTimeStr())
+
+							// 步骤4.1: 将图像适配到视频画布尺寸（裁剪或模糊背景填充）
+							if opts, enabled := drawthings.PostProcessOptionsFromConfig(); enabled {
+								if records, err := drawthings.PostProcessChapterImages(imagesDir, opts); err != nil {
+									broadcast.GlobalBroadcastService.SendLog("image", fmt.Sprintf("[一键出片] ⚠️  图像画布适配失败: %v", err), broadcast.GetTimeStr())
+								} else {
+									broadcast.GlobalBroadcastService.SendLog("image", fmt.Sprintf("[一键出片] ✅ 已将 %d 张图像适配到 %dx%d", len(records), opts.Width, opts.Height), broadcast.GetTimeStr())
+								}
+							}
 						}
 
 						// 一键出片流程至此完成，所有资源（音频、字幕、图像）已保存到output目录
@@ -1461,7 +1594,7 @@ func oneClickFilmHandler(c *gin.Context) {
 							capcutGenerator := capcut.NewCapcutGenerator(nil) // 传递logger或nil
 							err = capcutGenerator.GenerateProject(chapterDir)
 							if err != nil {
-								//broadcast.GlobalBroadcastService.SendLog("capcut", fmt.Sprintf("[一键出片] ⚠️  剪映项目生成失败: %v", err), broadcast.GetTimeStr())
+								broadcast.GlobalBroadcastService.SendLog("capcut", fmt.Sprintf("[一键出片] ⚠️  剪映项目生成失败: %v", err), broadcast.GetTimeStr())
 							} else {
 								broadcast.GlobalBroadcastService.SendLog("capcut", fmt.Sprintf("[一键出片] ✅ 剪映项目生成完成，章节: %d", key), broadcast.GetTimeStr())
 							}
@@ -1571,3 +1704,237 @@ func capcutProjectHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "CapCut project generation started"})
 }
+
+// imageRegenerateHandler 根据章节目录下的图像生成清单重新生成单张分镜图像，其他图像保持不变
+func imageRegenerateHandler(c *gin.Context) {
+	var reqBody struct {
+		ChapterPath    string `json:"chapter_path"` // 章节图像目录，如 ./output/小说名/chapter_01
+		Index          int    `json:"index"`        // 分镜序号，从1开始
+		Seed           *int   `json:"seed"`         // 不传则沿用原种子，-1为随机新种子
+		Prompt         string `json:"prompt"`
+		NegativePrompt string `json:"negative_prompt"`
+	}
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err), "status": "error"})
+		return
+	}
+
+	if reqBody.ChapterPath == "" || reqBody.Index <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing chapter_path or index parameter", "status": "error"})
+		return
+	}
+
+	// 获取项目根目录
+	wd, err := os.Getwd()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法获取当前工作目录", "status": "error"})
+		return
+	}
+
+	projectRoot := wd
+	if strings.HasSuffix(wd, "/cmd/web_server") {
+		projectRoot = filepath.Dir(filepath.Dir(wd)) // 回退两级到项目根目录
+	}
+
+	// 确保路径安全，只允许访问output目录
+	cleanPath := filepath.Clean(filepath.Join(projectRoot, strings.TrimPrefix(reqBody.ChapterPath, "./")))
+	allowedOutputPrefix := filepath.Join(projectRoot, "output")
+	if !strings.HasPrefix(cleanPath, allowedOutputPrefix+"/") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied", "status": "error"})
+		return
+	}
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建logger失败: %v", err), "status": "error"})
+		return
+	}
+	defer logger.Sync()
+
+	broadcast.GlobalBroadcastService.SendLog("image", fmt.Sprintf("[重新生成] 🎨 开始重新生成分镜 %d: %s", reqBody.Index, cleanPath), broadcast.GetTimeStr())
+
+	// 使用 image.engine 配置的图像后端重新生成
+	backend, err := drawthings.NewImageBackendFromConfig(logger)
+	var record *drawthings.ImageRecord
+	if err == nil {
+		record, err = drawthings.RegenerateImageWithBackend(c.Request.Context(), backend, cleanPath, reqBody.Index, drawthings.RegenerateOptions{
+			Seed:           reqBody.Seed,
+			Prompt:         reqBody.Prompt,
+			NegativePrompt: reqBody.NegativePrompt,
+		})
+	}
+	if err != nil {
+		broadcast.GlobalBroadcastService.SendLog("image", fmt.Sprintf("[重新生成] ❌ 重新生成失败: %v", err), broadcast.GetTimeStr())
+		c.JSON(http.StatusOK, gin.H{"status": "error", "message": fmt.Sprintf("重新生成失败: %v", err)})
+		return
+	}
+
+	broadcast.GlobalBroadcastService.SendLog("image", fmt.Sprintf("[重新生成] ✅ 分镜 %d 重新生成完成，种子: %d", record.Index, record.Parameters.Seed), broadcast.GetTimeStr())
+	c.JSON(http.StatusOK, gin.H{"status": "success", "record": record})
+}
+
+// coverGenerateHandler 为章节生成剧集封面
+func coverGenerateHandler(c *gin.Context) {
+	var reqBody struct {
+		ChapterPath string   `json:"chapter_path"` // 章节图像目录，如 ./output/小说名/chapter_01
+		Image       string   `json:"image"`        // 关键画面文件名（相对章节目录），不传则按生成清单选择
+		Title       string   `json:"title"`        // 不传则使用小说目录名
+		Episode     int      `json:"episode"`      // 不传则使用章节号
+		Hook        string   `json:"hook"`         // 不传则截取关键画面对应原文的第一句
+		Template    string   `json:"template"`
+		Sizes       []string `json:"sizes"` // 如 ["9:16", "16:9"]，不传则使用 cover.sizes
+	}
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err), "status": "error"})
+		return
+	}
+
+	if reqBody.ChapterPath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing chapter_path parameter", "status": "error"})
+		return
+	}
+
+	// 获取项目根目录
+	wd, err := os.Getwd()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法获取当前工作目录", "status": "error"})
+		return
+	}
+
+	projectRoot := wd
+	if strings.HasSuffix(wd, "/cmd/web_server") {
+		projectRoot = filepath.Dir(filepath.Dir(wd)) // 回退两级到项目根目录
+	}
+
+	// 确保路径安全，只允许访问output目录
+	cleanPath := filepath.Clean(filepath.Join(projectRoot, strings.TrimPrefix(reqBody.ChapterPath, "./")))
+	allowedOutputPrefix := filepath.Join(projectRoot, "output")
+	if !strings.HasPrefix(cleanPath, allowedOutputPrefix+"/") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied", "status": "error"})
+		return
+	}
+
+	imagePath := ""
+	if reqBody.Image != "" {
+		imagePath = filepath.Clean(filepath.Join(cleanPath, reqBody.Image))
+		if !strings.HasPrefix(imagePath, cleanPath+"/") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied", "status": "error"})
+			return
+		}
+	}
+
+	broadcast.GlobalBroadcastService.SendLog("image", fmt.Sprintf("[封面] 🖼️ 开始生成封面: %s", cleanPath), broadcast.GetTimeStr())
+
+	covers, err := cover.Generate(cover.Request{
+		ImagesDir: cleanPath,
+		Image:     imagePath,
+		Title:     reqBody.Title,
+		Episode:   reqBody.Episode,
+		Hook:      reqBody.Hook,
+		Template:  reqBody.Template,
+		Sizes:     reqBody.Sizes,
+	})
+	if err != nil {
+		broadcast.GlobalBroadcastService.SendLog("image", fmt.Sprintf("[封面] ❌ 封面生成失败: %v", err), broadcast.GetTimeStr())
+		c.JSON(http.StatusOK, gin.H{"status": "error", "message": fmt.Sprintf("封面生成失败: %v", err)})
+		return
+	}
+	if len(covers) > 0 && covers[0].Font == "" {
+		broadcast.GlobalBroadcastService.SendLog("image", "[封面] ⚠️ 没有找到中文字体，请在 image.font_path 中配置字体文件", broadcast.GetTimeStr())
+	}
+
+	broadcast.GlobalBroadcastService.SendLog("image", fmt.Sprintf("[封面] ✅ 已生成 %d 张封面", len(covers)), broadcast.GetTimeStr())
+	c.JSON(http.StatusOK, gin.H{"status": "success", "covers": covers})
+}
+
+// animaticHandler 为章节生成分镜预览，返回预览索引及其静态文件地址
+func animaticHandler(c *gin.Context) {
+	var reqBody struct {
+		ChapterPath string `json:"chapter_path"` // 章节目录，如 ./output/小说名/chapter_01
+		Format      string `json:"format"`       // gif 或 png，不传则使用 video.animatic.format
+		Rebuild     bool   `json:"rebuild"`      // 按章节素材重新生成 timeline.json
+	}
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err), "status": "error"})
+		return
+	}
+
+	if reqBody.ChapterPath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing chapter_path parameter", "status": "error"})
+		return
+	}
+
+	// 获取项目根目录
+	wd, err := os.Getwd()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法获取当前工作目录", "status": "error"})
+		return
+	}
+
+	projectRoot := wd
+	if strings.HasSuffix(wd, "/cmd/web_server") {
+		projectRoot = filepath.Dir(filepath.Dir(wd)) // 回退两级到项目根目录
+	}
+
+	// 确保路径安全，只允许访问output目录
+	cleanPath := filepath.Clean(filepath.Join(projectRoot, strings.TrimPrefix(reqBody.ChapterPath, "./")))
+	allowedOutputPrefix := filepath.Join(projectRoot, "output")
+	if !strings.HasPrefix(cleanPath, allowedOutputPrefix+"/") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied", "status": "error"})
+		return
+	}
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建logger失败: %v", err), "status": "error"})
+		return
+	}
+	defer logger.Sync()
+
+	processor, err := workflow_pkg.NewProcessor(logger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建工作流处理器失败: %v", err), "status": "error"})
+		return
+	}
+
+	broadcast.GlobalBroadcastService.SendLog("video", fmt.Sprintf("[分镜预览] 🎞️ 开始生成分镜预览: %s", cleanPath), broadcast.GetTimeStr())
+
+	index, animatic, err := processor.RenderAnimatic(cleanPath, reqBody.Format, reqBody.Rebuild)
+	if err != nil {
+		broadcast.GlobalBroadcastService.SendLog("video", fmt.Sprintf("[分镜预览] ❌ 生成失败: %v", err), broadcast.GetTimeStr())
+		c.JSON(http.StatusOK, gin.H{"status": "error", "message": fmt.Sprintf("分镜预览生成失败: %v", err)})
+		return
+	}
+	if animatic.Font == "" {
+		broadcast.GlobalBroadcastService.SendLog("video", "[分镜预览] ⚠️ 没有找到中文字体，请在 image.font_path 中配置字体文件", broadcast.GetTimeStr())
+	}
+
+	// 旁白可能不在 output 目录下（如从 input 导入），此时网页无法播放，只返回画面
+	indexURL := ""
+	if rel, err := filepath.Rel(allowedOutputPrefix, index); err == nil && !strings.HasPrefix(rel, "..") {
+		indexURL = "/files/output/" + filepath.ToSlash(rel)
+	}
+
+	broadcast.GlobalBroadcastService.SendLog("video", fmt.Sprintf("[分镜预览] ✅ 已生成 %d 帧: %s", animatic.Frames, index), broadcast.GetTimeStr())
+	c.JSON(http.StatusOK, gin.H{"status": "success", "index": indexURL, "animatic": animatic})
+}
+
+// styleListHandler 返回可用的风格预设，供一键出片等接口的 style 参数选择
+func styleListHandler(c *gin.Context) {
+	registry := drawthings.DefaultStyleRegistry()
+
+	var presets []*drawthings.StylePreset
+	for _, name := range registry.Names() {
+		preset, err := registry.Get(name)
+		if err != nil {
+			continue
+		}
+		presets = append(presets, preset)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"default": viper.GetString("image.style_preset"),
+		"presets": presets,
+	})
+}
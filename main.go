@@ -176,7 +176,7 @@ func checkOllama() error {
 // checkDrawThings 检查DrawThings服务
 func checkDrawThings(logger *zap.Logger) error {
 	client := drawthings.NewDrawThingsClient(logger, "http://localhost:7861")
-	if !client.APIAvailable {
+	if !client.APIAvailable.Load() {
 		return fmt.Errorf("DrawThings API不可用")
 	}
 	return nil
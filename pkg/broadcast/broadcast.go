@@ -101,6 +101,22 @@ func (b *BroadcastService) SendMessage(Name string, msg string, timestamp string
 	}
 }
 
+// TrySendMessage 非阻塞地发送普通消息，通道已满时丢弃消息并返回false，
+// 用于流式输出等高频消息，避免广播服务未启动或客户端过慢时阻塞调用方
+func (b *BroadcastService) TrySendMessage(Name string, msg string, timestamp string) bool {
+	select {
+	case b.broadcastChan <- types.MCPLog{
+		ToolName:  Name,
+		Type:      "stream",
+		Message:   msg,
+		Timestamp: timestamp,
+	}:
+		return true
+	default:
+		return false
+	}
+}
+
 // RegisterClient 注册客户端
 func (b *BroadcastService) RegisterClient(conn interface{}) chan types.MCPLog {
 	client := &Client{
@@ -13,12 +13,6 @@ import (
 	"strconv"
 	"strings"
 
-	"novel-video-workflow/pkg/capcut/internal/material"
-	"novel-video-workflow/pkg/capcut/internal/script"
-	"novel-video-workflow/pkg/capcut/internal/segment"
-	"novel-video-workflow/pkg/capcut/internal/srt"
-	"novel-video-workflow/pkg/capcut/internal/track"
-	"novel-video-workflow/pkg/capcut/internal/types"
 
 	"github.com/google/uuid"
 )
@@ -113,307 +107,21 @@ func (cg *CapcutGenerator) GenerateProject(inputDir string) error {
 	// 清理输入目录路径中的特殊字符
 	inputDir = cleanPath(inputDir)
 
-	// 检查必要的文件
-	audioFile := ""
-	imageFiles := []string{}
-	srtFile := ""
-
-	files, err := ioutil.ReadDir(inputDir)
+	// 生成章节时间线（同时保存为 timeline.json），再由时间线生成剪映草稿
+	tl, err := BuildChapterTimeline(inputDir)
 	if err != nil {
-		return fmt.Errorf("读取输入目录失败: %v", err)
-	}
-
-	for _, file := range files {
-		filename := strings.ToLower(file.Name())
-		if strings.HasSuffix(filename, ".wav") || strings.HasSuffix(filename, ".mp3") {
-			audioFile = cleanPath(filepath.Join(inputDir, file.Name())) // 清理音频文件路径
-		} else if strings.HasSuffix(filename, ".png") || strings.HasSuffix(filename, ".jpg") || strings.HasSuffix(filename, ".jpeg") {
-			imageFiles = append(imageFiles, cleanPath(filepath.Join(inputDir, file.Name()))) // 清理图片文件路径
-		} else if strings.HasSuffix(filename, ".srt") {
-			srtFile = cleanPath(filepath.Join(inputDir, file.Name())) // 清理字幕文件路径
-		}
-	}
-
-	if audioFile == "" {
-		return fmt.Errorf("未找到音频文件")
-	}
-
-	if len(imageFiles) == 0 {
-		return fmt.Errorf("未找到图片文件")
-	}
-
-	// 获取音频文件实际时长
-	audioDuration, err := getAudioDuration(audioFile)
-	if err != nil {
-		return fmt.Errorf("获取音频时长失败: %v", err)
-	}
-
-	// 计算音频时长（秒）
-	audioDurationSec := float64(audioDuration) / 1000000.0
-
-	// 计算台词总字数
-	totalSubtitleChars := 0
-	if srtFile != "" {
-		srtEntries, err := srt.ParseSrtFile(srtFile)
-		if err != nil {
-			fmt.Printf("解析字幕文件失败: %v\n", err)
-		} else {
-			for _, entry := range srtEntries {
-				totalSubtitleChars += len([]rune(entry.Text))
-			}
-		}
-	}
-
-	// 输出日志信息
-	fmt.Printf("🎵 音频时长: %.2f秒 (%d微秒)\n", audioDurationSec, audioDuration)
-	fmt.Printf("🖼️  图片资源: %d张\n", len(imageFiles))
-	fmt.Printf("📄 视频资源: 1个 (音频文件: %s)\n", filepath.Base(audioFile))
-	fmt.Printf("💬 台词字数: %d个字符\n", totalSubtitleChars)
-
-	// 创建草稿文件 (1080x1920 手机竖屏视频)
-	sf, err := script.NewScriptFile(1080, 1920, 30) // 宽度、高度、帧率
-	if err != nil {
-		return fmt.Errorf("创建草稿文件失败: %v", err)
-	}
-
-	// 设置草稿的基本信息
-	sf.Duration = audioDuration
-
-	// 计算每个图片的显示时间（平均分配音频总时长）
-	numScenes := len(imageFiles)
-	sceneDuration := audioDuration / int64(numScenes)
-
-	// 添加图片素材到草稿
-	for i, imageFile := range imageFiles {
-		relPath := imageFile // 使用原始路径，NewVideoMaterial会自动转换为绝对路径
-		imageName := filepath.Base(imageFile)
-		videoMaterial, err := material.NewVideoMaterial(
-			material.MaterialTypePhoto, // 静态图片
-			&relPath,                   // 文件路径 (NewVideoMaterial会自动转换为绝对路径)
-			nil,                        // 替换路径 (不需要，使用原始路径)
-			&imageName,                 // 素材名称
-			nil,                        // 远程URL
-			nil,                        // 裁剪设置
-			nil,                        // 时长
-			nil,                        // 宽度
-			nil,                        // 高度
-		)
-		if err != nil {
-			fmt.Printf("创建视频素材失败: %v\n", err)
-			continue
-		}
-		sf.AddMaterial(videoMaterial)
-
-		// 添加到视频轨道
-		videoTrack, err := sf.GetTrack("video", nil)
-		if err != nil {
-			videoTrackName := stringPtr(fmt.Sprintf("视频轨道_%d", i))
-			sf.AddTrack(track.TrackTypeVideo, videoTrackName)
-			videoTrack, _ = sf.GetTrack("video", videoTrackName)
-		}
-
-		startTime := int64(i) * sceneDuration
-		endTime := startTime + sceneDuration
-
-		// 确保最后一张图片精确结束于音频末尾
-		if i == numScenes-1 {
-			endTime = audioDuration
-		}
-
-		sourceTimeRange := types.NewTimerange(startTime, endTime-startTime)
-		targetTimeRange := types.NewTimerange(startTime, endTime-startTime)
-
-		videoSegment := segment.NewVideoSegment(
-			videoMaterial.MaterialID, // materialID
-			sourceTimeRange,          // sourceTimerange
-			targetTimeRange,          // targetTimerange
-			1.0,                      // speed
-			1.0,                      // volume
-			nil,                      // clipSettings
-		)
-
-		videoTrack.AddSegment(videoSegment)
+		return err
 	}
-
-	// 添加音频素材到草稿
-	audioFileName := filepath.Base(audioFile)
-	audioMaterial, err := material.NewAudioMaterial(
-		&audioFile,                             // 文件路径 (NewAudioMaterial会自动转换为绝对路径)
-		nil,                                    // 替换路径 (不需要，使用原始路径)
-		&audioFileName,                         // 素材名称
-		nil,                                    // 远程URL
-		float64Ptr(float64(audioDuration)/1e6), // 时长（秒）
-	)
+	sf, err := newDraftFromTimeline(tl)
 	if err != nil {
-		return fmt.Errorf("创建音频素材失败: %v", err)
-	} else {
-		sf.AddMaterial(audioMaterial)
-
-		// 添加到音频轨道
-		audioTrackName := stringPtr("音频轨道")
-		sf.AddTrack(track.TrackTypeAudio, audioTrackName)
-
-		// 获取刚刚添加的音频轨道
-		audioTrack, err := sf.GetTrack("audio", audioTrackName)
-		if err != nil {
-			return fmt.Errorf("获取音频轨道失败: %v", err)
-		}
-
-		audioSegment := segment.NewAudioSegment(
-			audioMaterial.MaterialID,             // materialID
-			types.NewTimerange(0, audioDuration), // targetTimerange - 整个音频时长
-			nil,                                  // sourceTimerange
-			1.0,                                  // speed
-			1.0,                                  // volume
-		)
-
-		err = audioTrack.AddSegment(audioSegment)
-		if err != nil {
-			return fmt.Errorf("向音频轨道添加片段失败: %v", err)
-		}
+		return err
 	}
-
-	// 如果有SRT字幕文件，则添加字幕
-	if srtFile != "" {
-		srtEntries, err := srt.ParseSrtFile(srtFile)
-		if err != nil {
-			return fmt.Errorf("解析字幕文件失败: %v", err)
-		} else {
-			// 重新计算字幕时间戳，使其与音频总时长相匹配
-			// 首先获取原始字幕总时长
-			var originalSubtitleDuration int64
-			if len(srtEntries) > 0 {
-				lastEntry := srtEntries[len(srtEntries)-1]
-				originalSubtitleDuration = lastEntry.End
-			}
-
-			// 添加文本轨道和字幕
-			textTrackName := stringPtr("字幕轨道")
-			sf.AddTrack(track.TrackTypeText, textTrackName)
-
-			// 获取文本轨道并添加字幕片段
-			textTrack, err := sf.GetTrack("text", textTrackName)
-			if err != nil {
-				return fmt.Errorf("获取文本轨道失败: %v", err)
-			} else {
-				for _, entry := range srtEntries {
-					// 根据音频时长与原始字幕时长的比例调整字幕时间
-					var adjustedStart, adjustedEnd int64
-					if originalSubtitleDuration > 0 {
-						// 按比例调整时间戳
-						ratio := float64(audioDuration) / float64(originalSubtitleDuration)
-						adjustedStart = int64(float64(entry.Start) * ratio)
-						adjustedEnd = int64(float64(entry.End) * ratio)
-						
-						// 确保最后一个字幕精确结束于音频末尾
-						if entry.End == originalSubtitleDuration && entry.End > 0 {
-							adjustedEnd = audioDuration
-						}
-					} else {
-						// 如果无法计算比例，直接使用原始时间
-						adjustedStart = entry.Start
-						adjustedEnd = entry.End
-					}
-
-					// 创建文本样式
-					textStyle := segment.NewTextStyle()
-					textStyle.Size = 24.0
-					textStyle.Color = [3]float64{1.0, 1.0, 1.0} // 白色
-					textStyle.Bold = true
-					textStyle.Align = 1 // 居中对齐
-
-					// 创建ClipSettings来设置字幕位置，使其显示在画面下方
-					clipSettings := segment.NewClipSettingsWithParams(
-						1.0,   // alpha
-						0.0,   // rotation
-						1.0,   // scaleX
-						1.0,   // scaleY
-						0.0,   // transformX
-						-0.8,  // transformY - 负值使字幕靠下显示
-						false, // flipH
-						false, // flipV
-					)
-
-					// 创建文本素材并添加到素材库
-					textMaterial := map[string]interface{}{
-						"add_type":                     2,
-						"alignment":                    1,
-						"background_alpha":             1.0,
-						"background_color":             "",
-						"background_height":            1.0,
-						"background_horizontal_offset": 0.0,
-						"background_round_radius":      0.0,
-						"background_vertical_offset":   0.0,
-						"background_width":             1.0,
-						"bold_width":                   0.0,
-						"border_color":                 "",
-						"border_width":                 0.08,
-						"check_flag":                   7,
-						"content":                      fmt.Sprintf("<font id=\"%s\" path=\"/Applications/VideoFusion-macOS.app/Contents/Resources/Font/SystemFont/zh-hans.ttf\"><color=(1.000000, 1.000000, 1.000000, 1.000000)><size=5.000000>%s</size></color></font>", uuid.New().String(), strings.ReplaceAll(entry.Text, "\n", "\u0001")),
-						"font_category_id":             "",
-						"font_category_name":           "",
-						"font_id":                      "",
-						"font_name":                    "",
-						"font_path":                    "/Applications/VideoFusion-macOS.app/Contents/Resources/Font/SystemFont/zh-hans.ttf",
-						"font_resource_id":             "",
-						"font_size":                    5.0,
-						"font_title":                   "none",
-						"font_url":                     "",
-						"fonts":                        []interface{}{},
-						"global_alpha":                 1.0,
-						"has_shadow":                   false,
-						"id":                           uuid.New().String(), // 生成唯一ID
-						"initial_scale":                1.0,
-						"is_rich_text":                 false,
-						"italic_degree":                0,
-						"ktv_color":                    "",
-						"layer_weight":                 1,
-						"letter_spacing":               0.0,
-						"line_spacing":                 0.02,
-						"recognize_type":               0,
-						"shadow_alpha":                 0.8,
-						"shadow_angle":                 -45.0,
-						"shadow_color":                 "",
-						"shadow_distance":              8.0,
-						"shadow_point":                 map[string]interface{}{"x": 1.0182337649086284, "y": -1.0182337649086284},
-						"shadow_smoothing":             1.0,
-						"shape_clip_x":                 false,
-						"shape_clip_y":                 false,
-						"style_name":                   "",
-						"sub_type":                     0,
-						"text_alpha":                   1.0,
-						"text_color":                   "#FFFFFF",
-						"text_size":                    30,
-						"text_to_audio_ids":            []interface{}{},
-						"type":                         "subtitle",
-						"typesetting":                  0,
-						"underline":                    false,
-						"underline_offset":             0.22,
-						"underline_width":              0.05,
-						"use_effect_default_color":     true,
-					}
-					// 将文本素材添加到素材库
-					sf.Materials.Texts = append(sf.Materials.Texts, textMaterial)
-
-					// 创建文本片段，使用刚添加的文本素材ID
-					textSegment := segment.NewTextSegment(
-						entry.Text, // text
-						types.NewTimerange(adjustedStart, adjustedEnd-adjustedStart), // targetTimerange - 调整后的时间
-						"",           // font (空字符串使用默认字体)
-						textStyle,    // style
-						clipSettings, // clipSettings - 添加位置设置
-					)
-					// 设置正确的MaterialID（使用刚添加的文本素材ID）
-					textSegment.MaterialID = textMaterial["id"].(string)
-
-					textTrack.AddSegment(textSegment)
-				}
-			}
-		}
+	if err := lintGeneratedDraft(sf); err != nil {
+		return err
 	}
 
-	// 生成项目ID
-	projectID := uuid.New().String()
+	// 生成项目ID：同一章节固定，重新生成时与剪映中修改过的草稿合并
+	projectID := chapterProjectID(inputDir)
 
 	// 将草稿内容写入临时文件
 	outputPath := filepath.Join("output", projectID+".json")
@@ -442,8 +150,8 @@ func (cg *CapcutGenerator) GenerateProject(inputDir string) error {
 		return fmt.Errorf("创建项目文件夹失败: %v", err)
 	}
 
-	// 复制必要的项目文件到剪映项目目录
-	err = copyProjectFiles(outputPath, newProjectDir, inputDir)
+	// 复制必要的项目文件到剪映项目目录（项目已存在时合并用户的修改）
+	err = installProject(outputPath, newProjectDir, inputDir)
 	if err != nil {
 			return fmt.Errorf("复制项目文件失败: %v", err)
 	}
@@ -462,303 +170,17 @@ func (cg *CapcutGenerator) GenerateProjectWithOutputDir(inputDir, outputDir stri
 		return fmt.Errorf("获取输入目录绝对路径失败: %v", err)
 	}
 
-	// 检查必要的文件
-	audioFile := ""
-	imageFiles := []string{}
-	srtFile := ""
-
-	files, err := ioutil.ReadDir(inputDir)
+	// 生成章节时间线（同时保存为 timeline.json），再由时间线生成剪映草稿
+	tl, err := BuildChapterTimeline(inputDir)
 	if err != nil {
-		return fmt.Errorf("读取输入目录失败: %v", err)
-	}
-
-	for _, file := range files {
-		filename := strings.ToLower(file.Name())
-		if strings.HasSuffix(filename, ".wav") || strings.HasSuffix(filename, ".mp3") {
-			audioFile = cleanPath(filepath.Join(inputDir, file.Name())) // 清理音频文件路径
-		} else if strings.HasSuffix(filename, ".png") || strings.HasSuffix(filename, ".jpg") || strings.HasSuffix(filename, ".jpeg") {
-			imageFiles = append(imageFiles, cleanPath(filepath.Join(inputDir, file.Name()))) // 清理图片文件路径
-		} else if strings.HasSuffix(filename, ".srt") {
-			srtFile = cleanPath(filepath.Join(inputDir, file.Name())) // 清理字幕文件路径
-		}
-	}
-
-	if audioFile == "" {
-		return fmt.Errorf("未找到音频文件")
-	}
-
-	if len(imageFiles) == 0 {
-		return fmt.Errorf("未找到图片文件")
-	}
-
-	// 获取音频文件实际时长
-	audioDuration, err := getAudioDuration(audioFile)
-	if err != nil {
-		return fmt.Errorf("获取音频时长失败: %v", err)
-	}
-
-	// 计算音频时长（秒）
-	audioDurationSec := float64(audioDuration) / 1000000.0
-
-	// 计算台词总字数
-	totalSubtitleChars := 0
-	if srtFile != "" {
-		srtEntries, err := srt.ParseSrtFile(srtFile)
-		if err != nil {
-			fmt.Printf("解析字幕文件失败: %v\n", err)
-		} else {
-			for _, entry := range srtEntries {
-				totalSubtitleChars += len([]rune(entry.Text))
-			}
-		}
-	}
-
-	// 输出日志信息
-	fmt.Printf("🎵 音频时长: %.2f秒 (%d微秒)\n", audioDurationSec, audioDuration)
-	fmt.Printf("🖼️  图片资源: %d张\n", len(imageFiles))
-	fmt.Printf("📄 视频资源: 1个 (音频文件: %s)\n", filepath.Base(audioFile))
-	fmt.Printf("💬 台词字数: %d个字符\n", totalSubtitleChars)
-
-	// 创建草稿文件 (1080x1920 竖屏视频)
-	sf, err := script.NewScriptFile(1080, 1920, 30) // 宽度、高度、帧率
-	if err != nil {
-		return fmt.Errorf("创建草稿文件失败: %v", err)
-	}
-
-	// 设置草稿的基本信息
-	sf.Duration = audioDuration
-
-	// 计算每个图片的显示时间（平均分配音频总时长）
-	numScenes := len(imageFiles)
-	sceneDuration := audioDuration / int64(numScenes)
-
-	// 添加图片素材到草稿
-	for i, imageFile := range imageFiles {
-		relPath := imageFile // 使用原始路径，NewVideoMaterial会自动转换为绝对路径
-		imageName := filepath.Base(imageFile)
-		videoMaterial, err := material.NewVideoMaterial(
-			material.MaterialTypePhoto, // 静态图片
-			&relPath,                   // 文件路径 (NewVideoMaterial会自动转换为绝对路径)
-			nil,                        // 替换路径 (不需要，使用原始路径)
-			&imageName,                 // 素材名称
-			nil,                        // 远程URL
-			nil,                        // 裁剪设置
-			nil,                        // 时长
-			nil,                        // 宽度
-			nil,                        // 高度
-		)
-		if err != nil {
-			fmt.Printf("创建视频素材失败: %v\n", err)
-			continue
-		}
-		sf.AddMaterial(videoMaterial)
-
-		// 添加到视频轨道
-		videoTrack, err := sf.GetTrack("video", nil)
-		if err != nil {
-			videoTrackName := stringPtr(fmt.Sprintf("视频轨道_%d", i))
-			sf.AddTrack(track.TrackTypeVideo, videoTrackName)
-			videoTrack, _ = sf.GetTrack("video", videoTrackName)
-		}
-
-		startTime := int64(i) * sceneDuration
-		endTime := startTime + sceneDuration
-
-		// 确保最后一张图片精确结束于音频末尾
-		if i == numScenes-1 {
-			endTime = audioDuration
-		}
-
-		sourceTimeRange := types.NewTimerange(startTime, endTime-startTime)
-		targetTimeRange := types.NewTimerange(startTime, endTime-startTime)
-
-		videoSegment := segment.NewVideoSegment(
-			videoMaterial.MaterialID, // materialID
-			sourceTimeRange,          // sourceTimerange
-			targetTimeRange,          // targetTimerange
-			1.0,                      // speed
-			1.0,                      // volume
-			nil,                      // clipSettings
-		)
-
-		videoTrack.AddSegment(videoSegment)
+		return err
 	}
-
-	// 添加音频素材到草稿
-	audioFileName := filepath.Base(audioFile)
-	audioMaterial, err := material.NewAudioMaterial(
-		&audioFile,                             // 文件路径 (NewAudioMaterial会自动转换为绝对路径)
-		nil,                                    // 替换路径 (不需要，使用原始路径)
-		&audioFileName,                         // 素材名称
-		nil,                                    // 远程URL
-		float64Ptr(float64(audioDuration)/1e6), // 时长（秒）
-	)
+	sf, err := newDraftFromTimeline(tl)
 	if err != nil {
-		return fmt.Errorf("创建音频素材失败: %v", err)
-	} else {
-		sf.AddMaterial(audioMaterial)
-
-		// 添加到音频轨道
-		audioTrackName := stringPtr("音频轨道")
-		sf.AddTrack(track.TrackTypeAudio, audioTrackName)
-
-		// 获取刚刚添加的音频轨道
-		audioTrack, err := sf.GetTrack("audio", audioTrackName)
-		if err != nil {
-			return fmt.Errorf("获取音频轨道失败: %v", err)
-		}
-
-		audioSegment := segment.NewAudioSegment(
-			audioMaterial.MaterialID,             // materialID
-			types.NewTimerange(0, audioDuration), // targetTimerange - 整个音频时长
-			nil,                                  // sourceTimerange
-			1.0,                                  // speed
-			1.0,                                  // volume
-		)
-
-		err = audioTrack.AddSegment(audioSegment)
-		if err != nil {
-			return fmt.Errorf("向音频轨道添加片段失败: %v", err)
-		}
+		return err
 	}
-
-	// 如果有SRT字幕文件，则添加字幕
-	if srtFile != "" {
-		srtEntries, err := srt.ParseSrtFile(srtFile)
-		if err != nil {
-			return fmt.Errorf("解析字幕文件失败: %v", err)
-		} else {
-			// 重新计算字幕时间戳，使其与音频总时长相匹配
-			// 首先获取原始字幕总时长
-			var originalSubtitleDuration int64
-			if len(srtEntries) > 0 {
-				lastEntry := srtEntries[len(srtEntries)-1]
-				originalSubtitleDuration = lastEntry.End
-			}
-
-			// 添加文本轨道和字幕
-			textTrackName := stringPtr("字幕轨道")
-			sf.AddTrack(track.TrackTypeText, textTrackName)
-
-			// 获取文本轨道并添加字幕片段
-			textTrack, err := sf.GetTrack("text", textTrackName)
-			if err != nil {
-				return fmt.Errorf("获取文本轨道失败: %v", err)
-			} else {
-				for _, entry := range srtEntries {
-					// 根据音频时长与原始字幕时长的比例调整字幕时间
-					var adjustedStart, adjustedEnd int64
-					if originalSubtitleDuration > 0 {
-						// 按比例调整时间戳
-						ratio := float64(audioDuration) / float64(originalSubtitleDuration)
-						adjustedStart = int64(float64(entry.Start) * ratio)
-						adjustedEnd = int64(float64(entry.End) * ratio)
-						
-						// 确保最后一个字幕精确结束于音频末尾
-						if entry.End == originalSubtitleDuration && entry.End > 0 {
-							adjustedEnd = audioDuration
-						}
-					} else {
-						// 如果无法计算比例，直接使用原始时间
-						adjustedStart = entry.Start
-						adjustedEnd = entry.End
-					}
-
-					// 创建文本样式
-					textStyle := segment.NewTextStyle()
-					textStyle.Size = 24.0
-					textStyle.Color = [3]float64{1.0, 1.0, 1.0} // 白色
-					textStyle.Bold = true
-					textStyle.Align = 1 // 居中对齐
-
-					// 创建ClipSettings来设置字幕位置，使其显示在画面下方
-					clipSettings := segment.NewClipSettingsWithParams(
-						1.0,   // alpha
-						0.0,   // rotation
-						1.0,   // scaleX
-						1.0,   // scaleY
-						0.0,   // transformX
-						-0.8,  // transformY - 负值使字幕靠下显示
-						false, // flipH
-						false, // flipV
-					)
-
-					// 创建文本素材并添加到素材库
-					textMaterial := map[string]interface{}{
-						"add_type":                     2,
-						"alignment":                    1,
-						"background_alpha":             1.0,
-						"background_color":             "",
-						"background_height":            1.0,
-						"background_horizontal_offset": 0.0,
-						"background_round_radius":      0.0,
-						"background_vertical_offset":   0.0,
-						"background_width":             1.0,
-						"bold_width":                   0.0,
-						"border_color":                 "",
-						"border_width":                 0.08,
-						"check_flag":                   7,
-						"content":                      fmt.Sprintf("<font id=\"%s\" path=\"/Applications/VideoFusion-macOS.app/Contents/Resources/Font/SystemFont/zh-hans.ttf\"><color=(1.000000, 1.000000, 1.000000, 1.000000)><size=5.000000>%s</size></color></font>", uuid.New().String(), strings.ReplaceAll(entry.Text, "\n", "\u0001")),
-						"font_category_id":             "",
-						"font_category_name":           "",
-						"font_id":                      "",
-						"font_name":                    "",
-						"font_path":                    "/Applications/VideoFusion-macOS.app/Contents/Resources/Font/SystemFont/zh-hans.ttf",
-						"font_resource_id":             "",
-						"font_size":                    5.0,
-						"font_title":                   "none",
-						"font_url":                     "",
-						"fonts":                        []interface{}{},
-						"global_alpha":                 1.0,
-						"has_shadow":                   false,
-						"id":                           uuid.New().String(), // 生成唯一ID
-						"initial_scale":                1.0,
-						"is_rich_text":                 false,
-						"italic_degree":                0,
-						"ktv_color":                    "",
-						"layer_weight":                 1,
-						"letter_spacing":               0.0,
-						"line_spacing":                 0.02,
-						"recognize_type":               0,
-						"shadow_alpha":                 0.8,
-						"shadow_angle":                 -45.0,
-						"shadow_color":                 "",
-						"shadow_distance":              8.0,
-						"shadow_point":                 map[string]interface{}{"x": 1.0182337649086284, "y": -1.0182337649086284},
-						"shadow_smoothing":             1.0,
-						"shape_clip_x":                 false,
-						"shape_clip_y":                 false,
-						"style_name":                   "",
-						"sub_type":                     0,
-						"text_alpha":                   1.0,
-						"text_color":                   "#FFFFFF",
-						"text_size":                    30,
-						"text_to_audio_ids":            []interface{}{},
-						"type":                         "subtitle",
-						"typesetting":                  0,
-						"underline":                    false,
-						"underline_offset":             0.22,
-						"underline_width":              0.05,
-						"use_effect_default_color":     true,
-					}
-					// 将文本素材添加到素材库
-					sf.Materials.Texts = append(sf.Materials.Texts, textMaterial)
-
-					// 创建文本片段，使用刚添加的文本素材ID
-					textSegment := segment.NewTextSegment(
-						entry.Text, // text
-						types.NewTimerange(adjustedStart, adjustedEnd-adjustedStart), // targetTimerange - 调整后的时间
-						"",           // font (空字符串使用默认字体)
-						textStyle,    // style
-						clipSettings, // clipSettings - 添加位置设置
-					)
-					// 设置正确的MaterialID（使用刚添加的文本素材ID）
-					textSegment.MaterialID = textMaterial["id"].(string)
-
-					textTrack.AddSegment(textSegment)
-				}
-			}
-		}
+	if err := lintGeneratedDraft(sf); err != nil {
+		return err
 	}
 
 	// 生成项目ID
@@ -789,281 +211,17 @@ func (cg *CapcutGenerator) GenerateAndImportProject(inputDir, projectName string
 		return fmt.Errorf("获取输入目录绝对路径失败: %v", err)
 	}
 
-	// 检查必要的文件
-	audioFile := ""
-	imageFiles := []string{}
-	srtFile := ""
-
-	files, err := ioutil.ReadDir(inputDir)
+	// 生成章节时间线（同时保存为 timeline.json），再由时间线生成剪映草稿
+	tl, err := BuildChapterTimeline(inputDir)
 	if err != nil {
-		return fmt.Errorf("读取输入目录失败: %v", err)
-	}
-
-	for _, file := range files {
-		filename := strings.ToLower(file.Name())
-		if strings.HasSuffix(filename, ".wav") || strings.HasSuffix(filename, ".mp3") {
-			audioFile = cleanPath(filepath.Join(inputDir, file.Name())) // 清理音频文件路径
-		} else if strings.HasSuffix(filename, ".png") || strings.HasSuffix(filename, ".jpg") || strings.HasSuffix(filename, ".jpeg") {
-			imageFiles = append(imageFiles, cleanPath(filepath.Join(inputDir, file.Name()))) // 清理图片文件路径
-		} else if strings.HasSuffix(filename, ".srt") {
-			srtFile = cleanPath(filepath.Join(inputDir, file.Name())) // 清理字幕文件路径
-		}
-	}
-
-	if audioFile == "" {
-		return fmt.Errorf("未找到音频文件")
-	}
-
-	if len(imageFiles) == 0 {
-		return fmt.Errorf("未找到图片文件")
-	}
-
-	// 获取音频文件实际时长
-	audioDuration, err := getAudioDuration(audioFile)
-	if err != nil {
-		return fmt.Errorf("获取音频时长失败: %v", err)
-	}
-
-	// 创建草稿文件 (1080x1920 竖屏视频)
-	sf, err := script.NewScriptFile(1080, 1920, 30) // 宽度、高度、帧率
-	if err != nil {
-		return fmt.Errorf("创建草稿文件失败: %v", err)
-	}
-
-	// 设置草稿的基本信息
-	sf.Duration = audioDuration
-
-	// 计算每个图片的显示时间（平均分配音频总时长）
-	numScenes := len(imageFiles)
-	sceneDuration := audioDuration / int64(numScenes)
-
-	// 添加图片素材到草稿
-	for i, imageFile := range imageFiles {
-		relPath := imageFile // 使用原始路径，NewVideoMaterial会自动转换为绝对路径
-		imageName := filepath.Base(imageFile)
-		videoMaterial, err := material.NewVideoMaterial(
-			material.MaterialTypePhoto, // 静态图片
-			&relPath,                   // 文件路径 (NewVideoMaterial会自动转换为绝对路径)
-			nil,                        // 替换路径 (不需要，使用原始路径)
-			&imageName,                 // 素材名称
-			nil,                        // 远程URL
-			nil,                        // 裁剪设置
-			nil,                        // 时长
-			nil,                        // 宽度
-			nil,                        // 高度
-		)
-		if err != nil {
-			fmt.Printf("创建视频素材失败: %v\n", err)
-			continue
-		}
-		sf.AddMaterial(videoMaterial)
-
-		// 添加到视频轨道
-		videoTrack, err := sf.GetTrack("video", nil)
-		if err != nil {
-			videoTrackName := stringPtr(fmt.Sprintf("视频轨道_%d", i))
-			sf.AddTrack(track.TrackTypeVideo, videoTrackName)
-			videoTrack, _ = sf.GetTrack("video", videoTrackName)
-		}
-
-		startTime := int64(i) * sceneDuration
-		endTime := startTime + sceneDuration
-
-		// 确保最后一张图片精确结束于音频末尾
-		if i == numScenes-1 {
-			endTime = audioDuration
-		}
-
-		sourceTimeRange := types.NewTimerange(startTime, endTime-startTime)
-		targetTimeRange := types.NewTimerange(startTime, endTime-startTime)
-
-		videoSegment := segment.NewVideoSegment(
-			videoMaterial.MaterialID, // materialID
-			sourceTimeRange,          // sourceTimerange
-			targetTimeRange,          // targetTimerange
-			1.0,                      // speed
-			1.0,                      // volume
-			nil,                      // clipSettings
-		)
-
-		videoTrack.AddSegment(videoSegment)
+		return err
 	}
-
-	// 添加音频素材到草稿
-	audioFileName := filepath.Base(audioFile)
-	audioMaterial, err := material.NewAudioMaterial(
-		&audioFile,                             // 文件路径 (NewAudioMaterial会自动转换为绝对路径)
-		nil,                                    // 替换路径 (不需要，使用原始路径)
-		&audioFileName,                         // 素材名称
-		nil,                                    // 远程URL
-		float64Ptr(float64(audioDuration)/1e6), // 时长（秒）
-	)
+	sf, err := newDraftFromTimeline(tl)
 	if err != nil {
-		return fmt.Errorf("创建音频素材失败: %v", err)
-	} else {
-		sf.AddMaterial(audioMaterial)
-
-		// 添加到音频轨道
-		audioTrackName := stringPtr("音频轨道")
-		sf.AddTrack(track.TrackTypeAudio, audioTrackName)
-
-		// 获取刚刚添加的音频轨道
-		audioTrack, err := sf.GetTrack("audio", audioTrackName)
-		if err != nil {
-			return fmt.Errorf("获取音频轨道失败: %v", err)
-		}
-
-		audioSegment := segment.NewAudioSegment(
-			audioMaterial.MaterialID,             // materialID
-			types.NewTimerange(0, audioDuration), // targetTimerange - 整个音频时长
-			nil,                                  // sourceTimerange
-			1.0,                                  // speed
-			1.0,                                  // volume
-		)
-
-		err = audioTrack.AddSegment(audioSegment)
-		if err != nil {
-			return fmt.Errorf("向音频轨道添加片段失败: %v", err)
-		}
+		return err
 	}
-
-	// 如果有SRT字幕文件，则添加字幕
-	if srtFile != "" {
-		srtEntries, err := srt.ParseSrtFile(srtFile)
-		if err != nil {
-			return fmt.Errorf("解析字幕文件失败: %v", err)
-		} else {
-			// 重新计算字幕时间戳，使其与音频总时长相匹配
-			// 首先获取原始字幕总时长
-			var originalSubtitleDuration int64
-			if len(srtEntries) > 0 {
-				lastEntry := srtEntries[len(srtEntries)-1]
-				originalSubtitleDuration = lastEntry.End
-			}
-
-			// 添加文本轨道和字幕
-			textTrackName := stringPtr("字幕轨道")
-			sf.AddTrack(track.TrackTypeText, textTrackName)
-
-			// 获取文本轨道并添加字幕片段
-			textTrack, err := sf.GetTrack("text", textTrackName)
-			if err != nil {
-				return fmt.Errorf("获取文本轨道失败: %v", err)
-			} else {
-				for _, entry := range srtEntries {
-					// 根据音频时长与原始字幕时长的比例调整字幕时间
-					var adjustedStart, adjustedEnd int64
-					if originalSubtitleDuration > 0 {
-						// 按比例调整时间戳
-						ratio := float64(audioDuration) / float64(originalSubtitleDuration)
-						adjustedStart = int64(float64(entry.Start) * ratio)
-						adjustedEnd = int64(float64(entry.End) * ratio)
-						
-						// 确保最后一个字幕精确结束于音频末尾
-						if entry.End == originalSubtitleDuration && entry.End > 0 {
-							adjustedEnd = audioDuration
-						}
-					} else {
-						// 如果无法计算比例，直接使用原始时间
-						adjustedStart = entry.Start
-						adjustedEnd = entry.End
-					}
-
-					// 创建文本样式
-					textStyle := segment.NewTextStyle()
-					textStyle.Size = 24.0
-					textStyle.Color = [3]float64{1.0, 1.0, 1.0} // 白色
-					textStyle.Bold = true
-					textStyle.Align = 1 // 居中对齐
-
-					// 创建ClipSettings来设置字幕位置，使其显示在画面下方
-					clipSettings := segment.NewClipSettingsWithParams(
-						1.0,   // alpha
-						0.0,   // rotation
-						1.0,   // scaleX
-						1.0,   // scaleY
-						0.0,   // transformX
-						-0.8,  // transformY - 负值使字幕靠下显示
-						false, // flipH
-						false, // flipV
-					)
-
-					// 创建文本素材并添加到素材库
-					textMaterial := map[string]interface{}{
-						"add_type":                     2,
-						"alignment":                    1,
-						"background_alpha":             1.0,
-						"background_color":             "",
-						"background_height":            1.0,
-						"background_horizontal_offset": 0.0,
-						"background_round_radius":      0.0,
-						"background_vertical_offset":   0.0,
-						"background_width":             1.0,
-						"bold_width":                   0.0,
-						"border_color":                 "",
-						"border_width":                 0.08,
-						"check_flag":                   7,
-						"content":                      fmt.Sprintf("<font id=\"%s\" path=\"/Applications/VideoFusion-macOS.app/Contents/Resources/Font/SystemFont/zh-hans.ttf\"><color=(1.000000, 1.000000, 1.000000, 1.000000)><size=5.000000>%s</size></color></font>", uuid.New().String(), strings.ReplaceAll(entry.Text, "\n", "\u0001")),
-						"font_category_id":             "",
-						"font_category_name":           "",
-						"font_id":                      "",
-						"font_name":                    "",
-						"font_path":                    "/Applications/VideoFusion-macOS.app/Contents/Resources/Font/SystemFont/zh-hans.ttf",
-						"font_resource_id":             "",
-						"font_size":                    5.0,
-						"font_title":                   "none",
-						"font_url":                     "",
-						"fonts":                        []interface{}{},
-						"global_alpha":                 1.0,
-						"has_shadow":                   false,
-						"id":                           uuid.New().String(), // 生成唯一ID
-						"initial_scale":                1.0,
-						"is_rich_text":                 false,
-						"italic_degree":                0,
-						"ktv_color":                    "",
-						"layer_weight":                 1,
-						"letter_spacing":               0.0,
-						"line_spacing":                 0.02,
-						"recognize_type":               0,
-						"shadow_alpha":                 0.8,
-						"shadow_angle":                 -45.0,
-						"shadow_color":                 "",
-						"shadow_distance":              8.0,
-						"shadow_point":                 map[string]interface{}{"x": 1.0182337649086284, "y": -1.0182337649086284},
-						"shadow_smoothing":             1.0,
-						"shape_clip_x":                 false,
-						"shape_clip_y":                 false,
-						"style_name":                   "",
-						"sub_type":                     0,
-						"text_alpha":                   1.0,
-						"text_color":                   "#FFFFFF",
-						"text_size":                    30,
-						"text_to_audio_ids":            []interface{}{},
-						"type":                         "subtitle",
-						"typesetting":                  0,
-						"underline":                    false,
-						"underline_offset":             0.22,
-						"underline_width":              0.05,
-						"use_effect_default_color":     true,
-					}
-					// 将文本素材添加到素材库
-					sf.Materials.Texts = append(sf.Materials.Texts, textMaterial)
-
-					// 创建文本片段，使用刚添加的文本素材ID
-					textSegment := segment.NewTextSegment(
-						entry.Text, // text
-						types.NewTimerange(adjustedStart, adjustedEnd-adjustedStart), // targetTimerange - 调整后的时间
-						"",           // font (空字符串使用默认字体)
-						textStyle,    // style
-						clipSettings, // clipSettings - 添加位置设置
-					)
-					// 设置正确的MaterialID（使用刚添加的文本素材ID）
-					textSegment.MaterialID = textMaterial["id"].(string)
-
-					textTrack.AddSegment(textSegment)
-				}
-			}
-		}
+	if err := lintGeneratedDraft(sf); err != nil {
+		return err
 	}
 
 	// 生成项目ID - 使用传入的项目名
@@ -1096,8 +254,8 @@ func (cg *CapcutGenerator) GenerateAndImportProject(inputDir, projectName string
 		return fmt.Errorf("创建项目文件夹失败: %v", err)
 	}
 
-	// 复制必要的项目文件到剪映项目目录
-	err = copyProjectFiles(outputPath, newProjectDir, inputDir)
+	// 复制必要的项目文件到剪映项目目录（项目已存在时合并用户的修改）
+	err = installProject(outputPath, newProjectDir, inputDir)
 	if err != nil {
 		return fmt.Errorf("复制项目文件失败: %v", err)
 	}
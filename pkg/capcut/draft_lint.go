@@ -0,0 +1,67 @@
+package capcut
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"novel-video-workflow/pkg/capcut/internal/script"
+	"novel-video-workflow/pkg/capcut/internal/util"
+)
+
+// LintOptions 草稿检查选项
+type LintOptions = script.LintOptions
+
+// LintProblem 草稿检查发现的一个问题
+type LintProblem struct {
+	Kind    string `json:"kind"` // missing_material, missing_file, overlap, duration, invalid
+	Message string `json:"message"`
+	Err     error  `json:"-"` // util 中的类型化错误
+}
+
+// lintProblemKind 按错误类型给问题分类
+func lintProblemKind(err error) string {
+	switch {
+	case util.IsMaterialNotFound(err):
+		return "missing_material"
+	case util.IsMediaFileNotFound(err):
+		return "missing_file"
+	case util.IsSegmentOverlap(err):
+		return "overlap"
+	case util.IsDurationMismatch(err):
+		return "duration"
+	}
+	return "invalid"
+}
+
+// LintDraftFile 检查剪映草稿：可以是草稿文件，也可以是草稿目录（依次查找 draft_info.json、draft_content.json）
+func LintDraftFile(path string, opts LintOptions) ([]LintProblem, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		for _, name := range []string{"draft_info.json", "draft_content.json"} {
+			if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+				path = filepath.Join(path, name)
+				break
+			}
+		}
+	}
+	d, err := script.LoadDraft(path)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []LintProblem{}
+	for _, problem := range d.Lint(opts) {
+		problems = append(problems, LintProblem{Kind: lintProblemKind(problem), Message: problem.Error(), Err: problem})
+	}
+	return problems, nil
+}
+
+// lintGeneratedDraft 写出草稿前检查，避免生成在剪映中显示"素材丢失"或无法打开的草稿。
+// 问题明细包含在返回的错误中，由调用方记录
+func lintGeneratedDraft(sf *script.ScriptFile) error {
+	err := sf.Lint(LintOptions{})
+	if lintErr, ok := err.(*util.DraftLintError); ok {
+		return fmt.Errorf("草稿检查发现 %d 个问题，未写出草稿: %w", len(lintErr.Problems), err)
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package capcut
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"novel-video-workflow/pkg/capcut/internal/util"
+)
+
+// TestLintGeneratedDraft 测试生成的草稿通过检查，素材文件被删除后报告素材丢失
+func TestLintGeneratedDraft(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "chapter_02")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	for name, content := range map[string]string{
+		"chapter_02.wav":    "wav",
+		"scene_01.png":      "png",
+		"scene_02.png":      "png",
+		"chapter_02.srt":    "1\n00:00:00,000 --> 00:00:02,000\n第一句\n\n2\n00:00:02,000 --> 00:00:04,000\n第二句\n",
+		"chapter_02.en.srt": "1\n00:00:00,000 --> 00:00:04,000\nFirst line\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("写入 %s 失败: %v", name, err)
+		}
+	}
+
+	assets, err := scanChapterAssets(dir)
+	if err != nil {
+		t.Fatalf("扫描素材失败: %v", err)
+	}
+	tl, err := buildTimeline(assets, 4000000)
+	if err != nil {
+		t.Fatalf("生成时间线失败: %v", err)
+	}
+	sf, err := newDraftFromTimeline(tl)
+	if err != nil {
+		t.Fatalf("生成草稿失败: %v", err)
+	}
+	if err := lintGeneratedDraft(sf); err != nil {
+		t.Fatalf("生成的草稿应通过检查: %v", err)
+	}
+
+	projectDir := t.TempDir()
+	if err := sf.Dump(filepath.Join(projectDir, "draft_info.json")); err != nil {
+		t.Fatalf("保存草稿失败: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "scene_02.png")); err != nil {
+		t.Fatalf("删除图片失败: %v", err)
+	}
+	problems, err := LintDraftFile(projectDir, LintOptions{})
+	if err != nil {
+		t.Fatalf("检查草稿失败: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Kind != "missing_file" {
+		t.Errorf("应只报告 scene_02.png 丢失，得到 %+v", problems)
+	}
+
+	// 生成前检查的问题明细由返回的错误带给调用方
+	err = lintGeneratedDraft(sf)
+	var missing *util.MediaFileNotFoundError
+	if !errors.As(err, &missing) || !strings.Contains(err.Error(), "scene_02.png") {
+		t.Errorf("错误中应包含丢失的素材: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package capcut
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"novel-video-workflow/pkg/capcut/internal/script"
+
+	"github.com/google/uuid"
+)
+
+// 三方合并的结果和冲突
+type (
+	MergeResult   = script.MergeResult
+	MergeConflict = script.MergeConflict
+)
+
+const (
+	// generatedDraftName 剪映项目目录中保存的上次生成的草稿，作为三方合并的基准
+	generatedDraftName = "draft_info.generated.json"
+	// mergeReportName 章节目录中的合并报告
+	mergeReportName = "capcut_merge_report.json"
+)
+
+// mergeReport 章节目录中的合并报告：合并结果、冲突，以及用户在剪映中做过的修改（上次生成的草稿与剪映保存的草稿的差异）
+type mergeReport struct {
+	*MergeResult
+	UserEdits []SegmentChange `json:"user_edits"`
+}
+
+// MergeDrafts 三方合并上次生成的草稿、剪映中修改后的草稿和重新生成的草稿
+func MergeDrafts(base, edited, generated *Draft) (*MergeResult, error) {
+	return script.MergeDrafts(base, edited, generated)
+}
+
+// chapterProjectID 由章节目录生成固定的剪映项目ID，重新生成时更新同一个项目
+func chapterProjectID(inputDir string) string {
+	return uuid.NewMD5(draftIDNamespace, []byte(inputDir)).String()
+}
+
+// installProject 把生成的草稿安装到剪映项目目录。
+// 项目目录中已有剪映保存的草稿和上次生成的草稿时，三方合并以保留用户的手动修改，冲突写入章节目录的合并报告
+func installProject(draftPath, projectDir, inputDir string) error {
+	draftInfoPath := filepath.Join(projectDir, "draft_info.json")
+	basePath := filepath.Join(projectDir, generatedDraftName)
+	edited, editedErr := os.ReadFile(draftInfoPath)
+	_, baseErr := os.Stat(basePath)
+
+	if err := copyProjectFiles(draftPath, projectDir, inputDir); err != nil {
+		return err
+	}
+	generated, err := os.ReadFile(draftInfoPath)
+	if err != nil {
+		return err
+	}
+	if editedErr != nil || baseErr != nil {
+		// 首次生成
+		return os.WriteFile(basePath, generated, 0644)
+	}
+	return mergeIntoProject(projectDir, inputDir, edited, generated)
+}
+
+// mergeIntoProject 以项目目录中上次生成的草稿为基准，三方合并剪映中修改后的草稿和重新生成的草稿，
+// 写出合并结果和新的基准，冲突写入章节目录的合并报告
+func mergeIntoProject(projectDir, inputDir string, edited, generated []byte) error {
+	draftInfoPath := filepath.Join(projectDir, "draft_info.json")
+	basePath := filepath.Join(projectDir, generatedDraftName)
+	report, err := mergeProjectDraft(basePath, edited, generated)
+	if err != nil {
+		// 合并失败时恢复剪映中修改的草稿，不丢失用户的工作
+		if restoreErr := os.WriteFile(draftInfoPath, edited, 0644); restoreErr != nil {
+			return fmt.Errorf("合并草稿失败: %v，恢复原草稿也失败: %v", err, restoreErr)
+		}
+		return fmt.Errorf("合并草稿失败，已保留剪映中修改的草稿: %v", err)
+	}
+	if err := report.Draft.Dump(draftInfoPath); err != nil {
+		return err
+	}
+	if err := os.WriteFile(basePath, generated, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("已合并剪映中的修改: 保留 %d 处手动修改，应用 %d 处重新生成的变化，%d 处冲突\n",
+		len(report.UserEdits), len(report.Applied), len(report.Conflicts))
+	for _, conflict := range report.Conflicts {
+		fmt.Printf("⚠️  %s\n", conflict)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(inputDir, mergeReportName), data, 0644)
+}
+
+// mergeProjectDraft 解析三个草稿并合并，同时记录用户在剪映中的修改
+func mergeProjectDraft(basePath string, edited, generated []byte) (*mergeReport, error) {
+	base, err := script.LoadDraft(basePath)
+	if err != nil {
+		return nil, err
+	}
+	editedDraft, err := script.ParseDraft(edited)
+	if err != nil {
+		return nil, err
+	}
+	generatedDraft, err := script.ParseDraft(generated)
+	if err != nil {
+		return nil, err
+	}
+	userEdits := script.DiffDrafts(base, editedDraft)
+	result, err := script.MergeDrafts(base, editedDraft, generatedDraft)
+	if err != nil {
+		return nil, err
+	}
+	return &mergeReport{MergeResult: result, UserEdits: userEdits.Changes}, nil
+}
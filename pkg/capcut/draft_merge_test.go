@@ -0,0 +1,125 @@
+package capcut
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"novel-video-workflow/pkg/capcut/internal/script"
+)
+
+// TestRegenerateKeepsManualEdits 测试重新生成章节时保留剪映中的手动修改
+func TestRegenerateKeepsManualEdits(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "chapter_05")
+	projectDir := filepath.Join(t.TempDir(), chapterProjectID(dir))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("写入 %s 失败: %v", name, err)
+		}
+	}
+	write("chapter_05.wav", "wav")
+	write("scene_01.png", "png")
+	write("scene_02.png", "png")
+	write("chapter_05.srt", "1\n00:00:00,000 --> 00:00:03,000\n第一句\n\n2\n00:00:03,000 --> 00:00:06,000\n第二句\n")
+
+	generate := func() {
+		t.Helper()
+		assets, err := scanChapterAssets(dir)
+		if err != nil {
+			t.Fatalf("扫描素材失败: %v", err)
+		}
+		tl, err := buildTimeline(assets, 6000000)
+		if err != nil {
+			t.Fatalf("生成时间线失败: %v", err)
+		}
+		sf, err := newDraftFromTimeline(tl)
+		if err != nil {
+			t.Fatalf("生成草稿失败: %v", err)
+		}
+		draftPath := filepath.Join(t.TempDir(), "draft.json")
+		if err := sf.Dump(draftPath); err != nil {
+			t.Fatalf("保存草稿失败: %v", err)
+		}
+		if err := installProject(draftPath, projectDir, dir); err != nil {
+			t.Fatalf("安装草稿失败: %v", err)
+		}
+	}
+	generate()
+
+	// 在剪映中修改：缩短第一张图片，改正第二句字幕
+	draftInfo := filepath.Join(projectDir, "draft_info.json")
+	edited, err := LoadDraft(draftInfo)
+	if err != nil {
+		t.Fatalf("读取草稿失败: %v", err)
+	}
+	var subtitles *DraftTrack
+	var trimmed *DraftSegment
+	for _, track := range edited.Tracks {
+		switch track.Type {
+		case "video":
+			trimmed = track.Segments[0]
+			trimmed.TargetTimerange.Duration -= 500000
+		case "text":
+			subtitles = track
+		}
+	}
+	if subtitles == nil || len(subtitles.Segments) != 2 {
+		t.Fatalf("草稿应有两句字幕")
+	}
+	if err := edited.TextMaterial(subtitles.Segments[1].MaterialID).SetText("第二句（改）"); err != nil {
+		t.Fatalf("修改字幕失败: %v", err)
+	}
+	if err := edited.Save(); err != nil {
+		t.Fatalf("保存草稿失败: %v", err)
+	}
+
+	// 修改第一句字幕后重新生成
+	write("chapter_05.srt", "1\n00:00:00,000 --> 00:00:03,000\n第一句新版\n\n2\n00:00:03,000 --> 00:00:06,000\n第二句\n")
+	generate()
+
+	merged, err := LoadDraft(draftInfo)
+	if err != nil {
+		t.Fatalf("读取合并后的草稿失败: %v", err)
+	}
+	texts := map[string]bool{}
+	for _, track := range merged.Tracks {
+		switch track.Type {
+		case "video":
+			if seg := track.Segments[0]; seg.ID != trimmed.ID || seg.TargetTimerange != trimmed.TargetTimerange {
+				t.Errorf("用户缩短的图片时长应保留，实际 %+v", seg.TargetTimerange)
+			}
+		case "text":
+			for _, seg := range track.Segments {
+				texts[merged.TextMaterial(seg.MaterialID).Text()] = true
+			}
+		}
+	}
+	if !texts["第一句新版"] || !texts["第二句（改）"] || len(texts) != 2 {
+		t.Errorf("字幕应同时包含新生成和用户修改的内容: %v", texts)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, mergeReportName))
+	if err != nil {
+		t.Fatalf("应写出合并报告: %v", err)
+	}
+	var report struct {
+		Applied   []SegmentChange `json:"applied"`
+		UserEdits []SegmentChange `json:"user_edits"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("解析合并报告失败: %v", err)
+	}
+	edits := DraftDiff{Changes: report.UserEdits}
+	if edits.Count(script.ChangeRetimed) != 1 || edits.Count(script.ChangeText) != 1 {
+		t.Errorf("合并报告应记录缩短图片和修改字幕两处手动修改:\n%s", edits.String())
+	}
+	if len(report.Applied) == 0 {
+		t.Error("合并报告应记录应用的生成变化")
+	}
+}
@@ -0,0 +1,25 @@
+package capcut
+
+import (
+	"novel-video-workflow/pkg/capcut/internal/script"
+)
+
+// 类型化的剪映草稿，供读取、修改和对比在剪映中编辑过的草稿
+type (
+	Draft              = script.Draft
+	DraftTrack         = script.DraftTrack
+	DraftSegment       = script.DraftSegment
+	DraftVideoSegment  = script.DraftVideoSegment
+	DraftAudioSegment  = script.DraftAudioSegment
+	DraftTextSegment   = script.DraftTextSegment
+	DraftEffectSegment = script.DraftEffectSegment
+	DraftTimerange     = script.DraftTimerange
+	DraftDiff          = script.DraftDiff
+	SegmentChange      = script.SegmentChange
+	ChangeKind         = script.ChangeKind
+)
+
+// LoadDraft 读取草稿目录或 draft_content.json
+func LoadDraft(path string) (*Draft, error) {
+	return script.LoadDraft(path)
+}
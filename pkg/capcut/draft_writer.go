@@ -0,0 +1,195 @@
+package capcut
+
+import (
+	"fmt"
+	"strings"
+
+	"novel-video-workflow/pkg/capcut/internal/material"
+	"novel-video-workflow/pkg/capcut/internal/script"
+	"novel-video-workflow/pkg/capcut/internal/segment"
+	"novel-video-workflow/pkg/capcut/internal/track"
+	"novel-video-workflow/pkg/capcut/internal/types"
+	"novel-video-workflow/pkg/timeline"
+
+	"github.com/google/uuid"
+)
+
+// draftIDNamespace 生成草稿内稳定ID的命名空间
+var draftIDNamespace = uuid.MustParse("3d0f6c1e-5b8a-4f2e-9c71-2a6e4b9d8f10")
+
+// stableDraftID 由轨道名和分镜、字幕的身份生成固定的ID。重新生成草稿时同一分镜的片段ID不变，
+// 才能与用户在剪映中修改过的草稿按ID合并
+func stableDraftID(parts ...string) string {
+	return uuid.NewMD5(draftIDNamespace, []byte(strings.Join(parts, "/"))).String()
+}
+
+// draftKeyframeProperty 时间线关键帧属性对应的剪映关键帧属性
+func draftKeyframeProperty(property string) string {
+	if property == timeline.PropertyScale {
+		return "uniform_scale"
+	}
+	return property
+}
+
+// newDraftFromTimeline 根据时间线生成剪映草稿
+func newDraftFromTimeline(tl *timeline.Timeline) (*script.ScriptFile, error) {
+	if err := tl.Validate(); err != nil {
+		return nil, err
+	}
+
+	sf, err := script.NewScriptFile(tl.Width, tl.Height, tl.FPS) // 宽度、高度、帧率
+	if err != nil {
+		return nil, fmt.Errorf("创建草稿文件失败: %v", err)
+	}
+
+	// 设置草稿的基本信息
+	sf.Duration = tl.Duration
+
+	for _, tr := range tl.Tracks {
+		switch tr.Kind {
+		case timeline.TrackVideo:
+			if err := addDraftVideoTrack(sf, tr); err != nil {
+				return nil, err
+			}
+		case timeline.TrackAudio:
+			if err := addDraftAudioTrack(sf, tr); err != nil {
+				return nil, err
+			}
+		case timeline.TrackText:
+			if len(tr.Cues) == 0 {
+				continue
+			}
+			if err := addSubtitleTrack(sf, tr.Cues, subtitleStyleForTrack(tr)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sf, nil
+}
+
+// addDraftVideoTrack 添加图片片段，以及片段上的运动关键帧和转场
+func addDraftVideoTrack(sf *script.ScriptFile, tr *timeline.Track) error {
+	trackName := stringPtr(tr.Name)
+	sf.AddTrack(track.TrackTypeVideo, trackName)
+	videoTrack, err := sf.GetTrack("video", trackName)
+	if err != nil {
+		return fmt.Errorf("获取视频轨道失败: %v", err)
+	}
+	videoTrack.TrackID = stableDraftID("track", tr.Name)
+
+	for _, clip := range tr.Clips {
+		videoMaterial, err := clipVideoMaterial(clip)
+		if err != nil {
+			fmt.Printf("创建视频素材失败: %v\n", err)
+			continue
+		}
+		sf.AddMaterial(videoMaterial)
+
+		videoSegment := segment.NewVideoSegment(
+			videoMaterial.MaterialID,
+			types.NewTimerange(clip.Source.Start, clip.Source.Duration),
+			types.NewTimerange(clip.Target.Start, clip.Target.Duration),
+			1.0,           // speed
+			clip.Volume(), // volume
+			nil,           // clipSettings
+		)
+		videoSegment.SegmentID = stableDraftID("segment", tr.Name, clip.ID)
+		sf.AddMaterial(videoSegment.Speed) // extra_material_refs 引用的变速素材
+
+		if clip.Motion != nil {
+			for _, kf := range clip.Motion.Keyframes {
+				if err := videoSegment.AddKeyframe(draftKeyframeProperty(kf.Property), kf.Offset, kf.Value); err != nil {
+					fmt.Printf("添加运动关键帧失败: %v\n", err)
+				}
+			}
+		}
+
+		if t := clip.Transition; t != nil {
+			effectID, resourceID := t.EffectID, t.ResourceID
+			if effectID == "" {
+				meta, ok := findTransition(t.Name)
+				if !ok {
+					fmt.Printf("⚠️  未知的转场: %s，已忽略\n", t.Name)
+				} else {
+					effectID, resourceID = meta.EffectID, meta.ResourceID
+				}
+			}
+			if effectID != "" {
+				videoSegment.AddTransition(t.Name, effectID, resourceID, t.Duration)
+				sf.AddMaterial(videoSegment.Transition)
+			}
+		}
+
+		videoTrack.AddSegment(videoSegment)
+	}
+	return nil
+}
+
+// addDraftAudioTrack 添加旁白或背景音乐片段
+func addDraftAudioTrack(sf *script.ScriptFile, tr *timeline.Track) error {
+	trackName := stringPtr(tr.Name)
+	sf.AddTrack(track.TrackTypeAudio, trackName)
+
+	// 获取刚刚添加的音频轨道
+	audioTrack, err := sf.GetTrack("audio", trackName)
+	if err != nil {
+		return fmt.Errorf("获取音频轨道失败: %v", err)
+	}
+	audioTrack.TrackID = stableDraftID("track", tr.Name)
+
+	for _, clip := range tr.Clips {
+		audioMaterial, err := clipAudioMaterial(clip)
+		if err != nil {
+			return fmt.Errorf("创建音频素材失败: %v", err)
+		}
+		sf.AddMaterial(audioMaterial)
+
+		sourceTimerange := types.NewTimerange(clip.Source.Start, clip.Source.Duration)
+		audioSegment := segment.NewAudioSegment(
+			audioMaterial.MaterialID,
+			types.NewTimerange(clip.Target.Start, clip.Target.Duration),
+			sourceTimerange,
+			1.0,           // speed
+			clip.Volume(), // volume
+		)
+		audioSegment.SegmentID = stableDraftID("segment", tr.Name, clip.ID)
+		sf.AddMaterial(audioSegment.Speed)
+		if err := audioTrack.AddSegment(audioSegment); err != nil {
+			return fmt.Errorf("向音频轨道添加片段失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// clipVideoMaterial 创建图片或视频片段的素材
+func clipVideoMaterial(clip *timeline.Clip) (*material.VideoMaterial, error) {
+	path, name := clip.Path, clip.Name
+	materialType := material.MaterialTypePhoto // 静态图片
+	if clip.Media == timeline.MediaVideo {
+		materialType = material.MaterialTypeVideo
+	}
+	return material.NewVideoMaterial(
+		materialType,
+		&path, // 文件路径 (NewVideoMaterial会自动转换为绝对路径)
+		nil,   // 替换路径 (不需要，使用原始路径)
+		&name, // 素材名称
+		nil,   // 远程URL
+		nil,   // 裁剪设置
+		nil,   // 时长
+		nil,   // 宽度
+		nil,   // 高度
+	)
+}
+
+// clipAudioMaterial 创建旁白或背景音乐片段的素材，素材时长为音频文件的总时长，
+// 未探测到时长时为片段使用的部分
+func clipAudioMaterial(clip *timeline.Clip) (*material.AudioMaterial, error) {
+	path, name := clip.Path, clip.Name
+	return material.NewAudioMaterial(
+		&path, // 文件路径 (NewAudioMaterial会自动转换为绝对路径)
+		nil,   // 替换路径 (不需要，使用原始路径)
+		&name, // 素材名称
+		nil,   // 远程URL
+		float64Ptr(float64(clip.AvailableDuration())/1e6), // 时长（秒）
+	)
+}
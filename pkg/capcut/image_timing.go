@@ -0,0 +1,300 @@
+package capcut
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"novel-video-workflow/pkg/capcut/internal/srt"
+	"novel-video-workflow/pkg/tools/drawthings"
+
+	"github.com/spf13/viper"
+)
+
+// 图片时间线的分配方式
+const (
+	imageTimingNarration = "narration" // 按图片对应原文在字幕中的位置对齐旁白
+	imageTimingEven      = "even"      // 按时长权重平均分配
+)
+
+// 单张图片默认的最短、最长显示时长（微秒）
+const (
+	defaultMinShotDuration int64 = 2000000
+	defaultMaxShotDuration int64 = 15000000
+)
+
+// matchPrefixRunes 在字幕中查找原文时使用的前缀长度，依次缩短重试
+var matchPrefixRunes = []int{16, 8, 4}
+
+// imageSlot 单张图片在时间线上的区间（微秒）
+type imageSlot struct {
+	Start int64
+	End   int64
+}
+
+// imageTimingOptions 图片显示时长约束
+type imageTimingOptions struct {
+	MinShot int64
+	MaxShot int64
+}
+
+// imageTimingOptionsFromConfig 读取 video.image_timing 配置
+func imageTimingOptionsFromConfig() imageTimingOptions {
+	opts := imageTimingOptions{MinShot: defaultMinShotDuration, MaxShot: defaultMaxShotDuration}
+	if v := viper.GetFloat64("video.image_timing.min_shot_seconds"); v > 0 {
+		opts.MinShot = int64(v * 1e6)
+	}
+	if v := viper.GetFloat64("video.image_timing.max_shot_seconds"); v > 0 {
+		opts.MaxShot = int64(v * 1e6)
+	}
+	return opts
+}
+
+// planImageTimeline 计算每张图片的显示区间。
+// 根据图片目录中的生成清单取得每张图片对应的原文，在字幕中定位该段原文，
+// 以所在字幕的开始时间作为切换点，使画面切换落在句子之间；
+// 再按最短、最长显示时长调整切换点。无法建立任何对应关系时按时长权重平均分配
+func planImageTimeline(imageFiles []string, srtFile string, audioDuration int64, opts imageTimingOptions) ([]imageSlot, string) {
+	if len(imageFiles) == 0 {
+		return nil, imageTimingEven
+	}
+
+	records := loadImageRecords(imageFiles)
+
+	var cues []srt.SrtEntry
+	if srtFile != "" {
+		if entries, err := srt.ParseSrtFile(srtFile); err == nil {
+			cues = scaleSrtEntries(entries, audioDuration)
+		}
+	}
+
+	if starts, ok := alignImagesToCues(imageFiles, records, cues); ok {
+		cuts := snapCuts(starts, cues, audioDuration, opts)
+		if cuts != nil {
+			return slotsFromCuts(cuts), imageTimingNarration
+		}
+	}
+
+	return evenImageSlots(imageFiles, records, audioDuration), imageTimingEven
+}
+
+// loadImageRecords 读取图片所在目录的生成清单，按图片文件名索引，没有清单时返回空
+func loadImageRecords(imageFiles []string) map[string]drawthings.ImageRecord {
+	records := make(map[string]drawthings.ImageRecord)
+	manifest, err := drawthings.LoadImageManifest(filepath.Dir(imageFiles[0]))
+	if err != nil {
+		return records
+	}
+	for _, record := range manifest.Images {
+		records[record.ImageFile] = record
+	}
+	return records
+}
+
+// alignImagesToCues 按顺序在字幕文本中定位每张图片对应的原文，返回每张图片的开始时间；
+// 定位不到的图片在前后已定位的图片之间按字幕位置插值，一张都定位不到时返回false
+func alignImagesToCues(imageFiles []string, records map[string]drawthings.ImageRecord, cues []srt.SrtEntry) ([]int64, bool) {
+	if len(cues) == 0 {
+		return nil, false
+	}
+
+	// 将字幕文本拼接为只含文字和数字的字符流，记录每条字幕的起始位置
+	var stream []rune
+	cueOffsets := make([]int, len(cues))
+	for i, cue := range cues {
+		cueOffsets[i] = len(stream)
+		stream = append(stream, normalizeForMatch(cue.Text)...)
+	}
+
+	// 每张图片在字符流中的位置，-1表示未定位
+	positions := make([]int, len(imageFiles))
+	cursor, matched := 0, 0
+	for i, imageFile := range imageFiles {
+		positions[i] = -1
+		record, ok := records[filepath.Base(imageFile)]
+		if !ok {
+			continue
+		}
+		source := normalizeForMatch(record.SourceText)
+		if len(source) == 0 {
+			continue
+		}
+		for _, n := range matchPrefixRunes {
+			if n > len(source) {
+				n = len(source)
+			}
+			if idx := indexRunes(stream[cursor:], source[:n]); idx != -1 {
+				positions[i] = cursor + idx
+				cursor = positions[i] + n
+				matched++
+				break
+			}
+			if n == len(source) {
+				break
+			}
+		}
+	}
+	if matched == 0 {
+		return nil, false
+	}
+
+	// 第一张图片总是从字符流开头开始，未定位的图片在相邻已定位图片之间等分
+	positions[0] = 0
+	for i := 1; i < len(positions); i++ {
+		if positions[i] != -1 {
+			continue
+		}
+		next, nextPos := len(positions), len(stream)
+		for j := i + 1; j < len(positions); j++ {
+			if positions[j] != -1 {
+				next, nextPos = j, positions[j]
+				break
+			}
+		}
+		prevPos := positions[i-1]
+		positions[i] = prevPos + (nextPos-prevPos)/(next-i+1)
+	}
+
+	starts := make([]int64, len(positions))
+	for i, pos := range positions {
+		starts[i] = cues[cueAt(cueOffsets, pos)].Start
+	}
+	starts[0] = 0
+	return starts, true
+}
+
+// cueAt 返回字符流位置所在的字幕序号
+func cueAt(cueOffsets []int, pos int) int {
+	index := 0
+	for i, offset := range cueOffsets {
+		if offset <= pos {
+			index = i
+		} else {
+			break
+		}
+	}
+	return index
+}
+
+// snapCuts 将图片开始时间整理为切换点序列（首尾分别为0和音频时长），
+// 保证切换点递增并满足最短、最长显示时长，调整后的切换点仍落在字幕边界上；
+// 图片数量过多、总时长不足以满足最短时长时返回nil
+func snapCuts(starts []int64, cues []srt.SrtEntry, audioDuration int64, opts imageTimingOptions) []int64 {
+	n := len(starts)
+	if int64(n)*opts.MinShot > audioDuration {
+		return nil
+	}
+
+	boundaries := make([]int64, 0, len(cues))
+	for _, cue := range cues {
+		boundaries = append(boundaries, cue.Start)
+	}
+
+	cuts := make([]int64, n+1)
+	cuts[n] = audioDuration
+	for i := 1; i < n; i++ {
+		cut := starts[i]
+		earliest := cuts[i-1] + opts.MinShot
+		latest := audioDuration - int64(n-i)*opts.MinShot // 为后面的图片留出最短时长
+		if opts.MaxShot > 0 && cuts[i-1]+opts.MaxShot < latest {
+			if cut > cuts[i-1]+opts.MaxShot {
+				cut = lastBoundaryAtOrBefore(boundaries, cuts[i-1]+opts.MaxShot, earliest)
+			}
+		}
+		if cut < earliest {
+			cut = firstBoundaryAtOrAfter(boundaries, earliest, latest)
+		}
+		if cut > latest {
+			cut = lastBoundaryAtOrBefore(boundaries, latest, earliest)
+		}
+		cuts[i] = cut
+	}
+	return cuts
+}
+
+// firstBoundaryAtOrAfter 返回不早于t的第一个字幕边界，超过limit时返回t
+func firstBoundaryAtOrAfter(boundaries []int64, t, limit int64) int64 {
+	for _, b := range boundaries {
+		if b >= t {
+			if b <= limit {
+				return b
+			}
+			break
+		}
+	}
+	return t
+}
+
+// lastBoundaryAtOrBefore 返回不晚于t的最后一个字幕边界，早于limit时返回t
+func lastBoundaryAtOrBefore(boundaries []int64, t, limit int64) int64 {
+	for i := len(boundaries) - 1; i >= 0; i-- {
+		if boundaries[i] <= t {
+			if boundaries[i] >= limit {
+				return boundaries[i]
+			}
+			break
+		}
+	}
+	return t
+}
+
+func slotsFromCuts(cuts []int64) []imageSlot {
+	slots := make([]imageSlot, len(cuts)-1)
+	for i := range slots {
+		slots[i] = imageSlot{Start: cuts[i], End: cuts[i+1]}
+	}
+	return slots
+}
+
+// evenImageSlots 按生成清单中分镜的时长权重分配音频总时长，没有权重时平均分配
+func evenImageSlots(imageFiles []string, records map[string]drawthings.ImageRecord, audioDuration int64) []imageSlot {
+	weights := make([]float64, len(imageFiles))
+	var total float64
+	for i, imageFile := range imageFiles {
+		weights[i] = 1
+		if record, ok := records[filepath.Base(imageFile)]; ok && record.Scene != nil && record.Scene.DurationWeight > 0 {
+			weights[i] = record.Scene.DurationWeight
+		}
+		total += weights[i]
+	}
+
+	cuts := make([]int64, len(imageFiles)+1)
+	var acc float64
+	for i, w := range weights {
+		acc += w
+		cuts[i+1] = int64(float64(audioDuration) * acc / total)
+	}
+	// 确保最后一张图片精确结束于音频末尾
+	cuts[len(imageFiles)] = audioDuration
+	return slotsFromCuts(cuts)
+}
+
+// normalizeForMatch 只保留文字和数字，忽略标点、空白的差异
+func normalizeForMatch(text string) []rune {
+	var out []rune
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func indexRunes(haystack, needle []rune) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,97 @@
+package capcut
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"novel-video-workflow/pkg/tools/drawthings"
+)
+
+// writeTimingFixture 写入6条各2秒的字幕，以及每张图片对应原文的生成清单
+func writeTimingFixture(t *testing.T, sources []string) (string, []string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	srtContent := "" +
+		"1\n00:00:00,000 --> 00:00:02,000\n夜深了，客栈里一片寂静。\n\n" +
+		"2\n00:00:02,000 --> 00:00:04,000\n油灯忽明忽暗\n\n" +
+		"3\n00:00:04,000 --> 00:00:06,000\n门，忽然开了\n\n" +
+		"4\n00:00:06,000 --> 00:00:08,000\n一阵冷风吹了进来\n\n" +
+		"5\n00:00:08,000 --> 00:00:10,000\n林晚抬起头\n\n" +
+		"6\n00:00:10,000 --> 00:00:12,000\n门口站着一个人\n"
+	if err := os.WriteFile(filepath.Join(dir, "chapter_01.srt"), []byte(srtContent), 0644); err != nil {
+		t.Fatalf("写入字幕失败: %v", err)
+	}
+
+	manifest := drawthings.NewImageManifest(1, drawthings.ManifestSourceOllamaScenes)
+	var imageFiles []string
+	for i, source := range sources {
+		imageFile := filepath.Join(dir, fmt.Sprintf("scene_%02d.png", i+1))
+		if err := os.WriteFile(imageFile, []byte("png"), 0644); err != nil {
+			t.Fatalf("写入图片失败: %v", err)
+		}
+		record := drawthings.NewImageRecord(i+1, imageFile, drawthings.Txt2ImgRequest{})
+		record.SourceText = source
+		manifest.Put(record)
+		imageFiles = append(imageFiles, imageFile)
+	}
+	if err := manifest.Save(dir); err != nil {
+		t.Fatalf("保存清单失败: %v", err)
+	}
+	return dir, imageFiles
+}
+
+func TestPlanImageTimelineFollowsNarration(t *testing.T) {
+	dir, imageFiles := writeTimingFixture(t, []string{"夜深了。客栈里一片寂静", "门忽然开了！", "林晚抬起头，门口站着一个人"})
+
+	slots, mode := planImageTimeline(imageFiles, filepath.Join(dir, "chapter_01.srt"), 12000000, imageTimingOptions{MinShot: 1000000, MaxShot: 15000000})
+	if mode != imageTimingNarration {
+		t.Fatalf("应按旁白对齐, 实际 %s", mode)
+	}
+
+	want := []imageSlot{{0, 4000000}, {4000000, 8000000}, {8000000, 12000000}}
+	for i := range want {
+		if slots[i] != want[i] {
+			t.Errorf("第%d张图片区间 %+v, 期望 %+v", i+1, slots[i], want[i])
+		}
+	}
+}
+
+func TestPlanImageTimelineEnforcesShotLength(t *testing.T) {
+	// 第2、3张图片落在相邻字幕上，最短时长要求切换点后移到下一个字幕边界
+	dir, imageFiles := writeTimingFixture(t, []string{"夜深了", "油灯忽明忽暗", "门忽然开了"})
+	srtFile := filepath.Join(dir, "chapter_01.srt")
+
+	slots, _ := planImageTimeline(imageFiles, srtFile, 12000000, imageTimingOptions{MinShot: 3000000, MaxShot: 6000000})
+	want := []imageSlot{{0, 4000000}, {4000000, 8000000}, {8000000, 12000000}}
+	for i := range want {
+		if slots[i] != want[i] {
+			t.Errorf("第%d张图片区间 %+v, 期望 %+v", i+1, slots[i], want[i])
+		}
+	}
+
+	// 最长时长限制切换点提前
+	slots, _ = planImageTimeline([]string{imageFiles[0], imageFiles[2]}, srtFile, 12000000, imageTimingOptions{MinShot: 1000000, MaxShot: 3000000})
+	if slots[0].End != 2000000 {
+		t.Errorf("超过最长时长时应提前切换, 实际 %+v", slots)
+	}
+}
+
+func TestPlanImageTimelineFallsBackToEven(t *testing.T) {
+	dir, imageFiles := writeTimingFixture(t, []string{"完全不相关的文字", "另一段不相关的文字"})
+
+	slots, mode := planImageTimeline(imageFiles, filepath.Join(dir, "chapter_01.srt"), 12000000, imageTimingOptionsFromConfig())
+	if mode != imageTimingEven {
+		t.Fatalf("无法对齐时应平均分配, 实际 %s", mode)
+	}
+	if slots[0] != (imageSlot{0, 6000000}) || slots[1] != (imageSlot{6000000, 12000000}) {
+		t.Errorf("平均分配区间错误: %+v", slots)
+	}
+
+	// 没有字幕时同样平均分配
+	if _, mode := planImageTimeline(imageFiles, "", 12000000, imageTimingOptionsFromConfig()); mode != imageTimingEven {
+		t.Errorf("没有字幕时应平均分配, 实际 %s", mode)
+	}
+}
@@ -0,0 +1,319 @@
+package script
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeKind 草稿差异的类型
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"    // 新增片段
+	ChangeRemoved  ChangeKind = "removed"  // 删除片段
+	ChangeMoved    ChangeKind = "moved"    // 起始时间或所在轨道改变
+	ChangeRetimed  ChangeKind = "retimed"  // 时长或素材截取范围改变
+	ChangeText     ChangeKind = "text"     // 文字内容改变
+	ChangeMaterial ChangeKind = "material" // 引用的素材文件改变
+	ChangeProperty ChangeKind = "property" // 音量、速度、画面变换等属性改变
+	ChangeSetting  ChangeKind = "setting"  // 草稿时长、画布、帧率改变
+)
+
+var changeKindNames = map[ChangeKind]string{
+	ChangeAdded:    "新增",
+	ChangeRemoved:  "删除",
+	ChangeMoved:    "移动",
+	ChangeRetimed:  "调整时长",
+	ChangeText:     "修改文字",
+	ChangeMaterial: "替换素材",
+	ChangeProperty: "修改属性",
+	ChangeSetting:  "草稿设置",
+}
+
+// SegmentChange 两个草稿之间的一处差异。Track 和 FromTrack 为轨道序号（从0开始），
+// 删除的片段 Track 为其在原草稿中的轨道
+type SegmentChange struct {
+	Kind      ChangeKind      `json:"kind"`
+	TrackType string          `json:"track_type,omitempty"`
+	Track     int             `json:"track"`
+	FromTrack int             `json:"from_track"`
+	SegmentID string          `json:"segment_id,omitempty"`
+	Label     string          `json:"label,omitempty"`
+	Before    *DraftTimerange `json:"before,omitempty"`
+	After     *DraftTimerange `json:"after,omitempty"`
+	OldValue  string          `json:"old_value,omitempty"`
+	NewValue  string          `json:"new_value,omitempty"`
+	Fields    []string        `json:"fields,omitempty"`
+}
+
+// DraftDiff 两个草稿之间的语义差异
+type DraftDiff struct {
+	Changes []SegmentChange `json:"changes"`
+}
+
+// Empty 两个草稿在语义上是否相同
+func (d *DraftDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// Count 某类差异的数量
+func (d *DraftDiff) Count(kind ChangeKind) int {
+	count := 0
+	for _, change := range d.Changes {
+		if change.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// String 每行一处差异的可读文本
+func (d *DraftDiff) String() string {
+	if d.Empty() {
+		return "草稿没有差异"
+	}
+	var sb strings.Builder
+	for _, change := range d.Changes {
+		sb.WriteString(change.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func (c SegmentChange) String() string {
+	name := changeKindNames[c.Kind]
+	if c.Kind == ChangeSetting {
+		return fmt.Sprintf("[%s] %s: %s -> %s", name, strings.Join(c.Fields, ","), c.OldValue, c.NewValue)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s] %s轨道#%d", name, c.TrackType, c.Track)
+	if c.Label != "" {
+		fmt.Fprintf(&sb, " 「%s」", truncateLabel(c.Label, 20))
+	}
+	switch c.Kind {
+	case ChangeAdded:
+		fmt.Fprintf(&sb, " %s", formatTimerange(c.After))
+	case ChangeRemoved:
+		fmt.Fprintf(&sb, " %s", formatTimerange(c.Before))
+	case ChangeMoved:
+		fmt.Fprintf(&sb, " %s -> %s", formatTimerange(c.Before), formatTimerange(c.After))
+		if c.FromTrack != c.Track {
+			fmt.Fprintf(&sb, "（来自轨道#%d）", c.FromTrack)
+		}
+	case ChangeRetimed:
+		fmt.Fprintf(&sb, " %s -> %s", formatTimerange(c.Before), formatTimerange(c.After))
+	case ChangeText, ChangeMaterial:
+		fmt.Fprintf(&sb, " %q -> %q", c.OldValue, c.NewValue)
+	case ChangeProperty:
+		fmt.Fprintf(&sb, " %s", strings.Join(c.Fields, ","))
+	}
+	return sb.String()
+}
+
+// draftEntry 扁平化后的片段
+type draftEntry struct {
+	trackIndex int
+	track      *DraftTrack
+	segment    *DraftSegment
+	source     string
+	label      string
+	matched    bool
+}
+
+func draftEntries(d *Draft) []*draftEntry {
+	var entries []*draftEntry
+	for i, track := range d.Tracks {
+		for _, seg := range track.Segments {
+			entries = append(entries, &draftEntry{
+				trackIndex: i,
+				track:      track,
+				segment:    seg,
+				source:     d.SegmentSource(track, seg),
+				label:      d.SegmentLabel(track, seg),
+			})
+		}
+	}
+	return entries
+}
+
+// DiffDrafts 比较两个草稿。片段先按ID配对，剩下的按轨道类型和引用的素材（文件路径或文字）依次配对，
+// 仍未配对的视为新增或删除。配对的片段比较位置、时长、内容和属性
+func DiffDrafts(base, other *Draft) *DraftDiff {
+	diff := &DraftDiff{}
+	diff.Changes = append(diff.Changes, diffSettings(base, other)...)
+
+	oldEntries, newEntries := draftEntries(base), draftEntries(other)
+	type pair struct{ old, new *draftEntry }
+	var pairs []pair
+
+	byID := make(map[string]*draftEntry)
+	for _, entry := range newEntries {
+		byID[entry.segment.ID] = entry
+	}
+	for _, entry := range oldEntries {
+		if match, ok := byID[entry.segment.ID]; ok && !match.matched && match.track.Type == entry.track.Type {
+			entry.matched, match.matched = true, true
+			pairs = append(pairs, pair{entry, match})
+		}
+	}
+	for _, entry := range oldEntries {
+		if entry.matched || entry.source == "" {
+			continue
+		}
+		for _, candidate := range newEntries {
+			if !candidate.matched && candidate.track.Type == entry.track.Type && candidate.source == entry.source {
+				entry.matched, candidate.matched = true, true
+				pairs = append(pairs, pair{entry, candidate})
+				break
+			}
+		}
+	}
+
+	for _, entry := range oldEntries {
+		if !entry.matched {
+			diff.Changes = append(diff.Changes, entryChange(ChangeRemoved, entry, entry))
+		}
+	}
+	for _, entry := range newEntries {
+		if !entry.matched {
+			diff.Changes = append(diff.Changes, entryChange(ChangeAdded, entry, entry))
+		}
+	}
+	for _, p := range pairs {
+		diff.Changes = append(diff.Changes, diffSegment(p.old, p.new)...)
+	}
+
+	sort.SliceStable(diff.Changes, func(i, j int) bool {
+		a, b := diff.Changes[i], diff.Changes[j]
+		if (a.Kind == ChangeSetting) != (b.Kind == ChangeSetting) {
+			return a.Kind == ChangeSetting
+		}
+		if changeTime(a) != changeTime(b) {
+			return changeTime(a) < changeTime(b)
+		}
+		return a.Track < b.Track
+	})
+	return diff
+}
+
+func entryChange(kind ChangeKind, old, new *draftEntry) SegmentChange {
+	change := SegmentChange{
+		Kind:      kind,
+		TrackType: new.track.Type,
+		Track:     new.trackIndex,
+		FromTrack: old.trackIndex,
+		SegmentID: new.segment.ID,
+		Label:     new.label,
+	}
+	before, after := old.segment.TargetTimerange, new.segment.TargetTimerange
+	if kind != ChangeAdded {
+		change.Before = &before
+	}
+	if kind != ChangeRemoved {
+		change.After = &after
+	}
+	return change
+}
+
+func diffSegment(old, new *draftEntry) []SegmentChange {
+	var changes []SegmentChange
+	oldSeg, newSeg := old.segment, new.segment
+
+	if old.trackIndex != new.trackIndex || oldSeg.TargetTimerange.Start != newSeg.TargetTimerange.Start {
+		changes = append(changes, entryChange(ChangeMoved, old, new))
+	}
+	if oldSeg.TargetTimerange.Duration != newSeg.TargetTimerange.Duration || !sameTimerange(oldSeg.SourceTimerange, newSeg.SourceTimerange) {
+		changes = append(changes, entryChange(ChangeRetimed, old, new))
+	}
+	if old.source != new.source {
+		kind := ChangeMaterial
+		if new.track.Type == "text" {
+			kind = ChangeText
+		}
+		change := entryChange(kind, old, new)
+		change.OldValue, change.NewValue = old.source, new.source
+		changes = append(changes, change)
+	}
+
+	var fields []string
+	if oldSeg.Volume != newSeg.Volume {
+		fields = append(fields, "volume")
+	}
+	if oldSeg.Speed != newSeg.Speed {
+		fields = append(fields, "speed")
+	}
+	if !sameEncoding(oldSeg.Clip, newSeg.Clip) {
+		fields = append(fields, "clip")
+	}
+	if len(fields) > 0 {
+		change := entryChange(ChangeProperty, old, new)
+		change.Fields = fields
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func diffSettings(base, other *Draft) []SegmentChange {
+	var changes []SegmentChange
+	add := func(field string, oldValue, newValue interface{}) {
+		oldText, newText := fmt.Sprint(oldValue), fmt.Sprint(newValue)
+		if oldText != newText {
+			changes = append(changes, SegmentChange{Kind: ChangeSetting, Fields: []string{field}, OldValue: oldText, NewValue: newText})
+		}
+	}
+	add("duration", base.Duration, other.Duration)
+	add("fps", base.FPS, other.FPS)
+	if base.Canvas != nil && other.Canvas != nil {
+		add("canvas", fmt.Sprintf("%dx%d", base.Canvas.Width, base.Canvas.Height), fmt.Sprintf("%dx%d", other.Canvas.Width, other.Canvas.Height))
+		add("ratio", base.Canvas.Ratio, other.Canvas.Ratio)
+	}
+	return changes
+}
+
+func changeTime(c SegmentChange) int64 {
+	if c.After != nil {
+		return c.After.Start
+	}
+	if c.Before != nil {
+		return c.Before.Start
+	}
+	return 0
+}
+
+func sameTimerange(a, b *DraftTimerange) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameEncoding(a, b interface{}) bool {
+	encodedA, errA := marshalDraftValue(a)
+	encodedB, errB := marshalDraftValue(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// formatTimerange 以 分:秒.毫秒 显示时间范围
+func formatTimerange(r *DraftTimerange) string {
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s-%s", formatMicroseconds(r.Start), formatMicroseconds(r.End()))
+}
+
+func formatMicroseconds(us int64) string {
+	ms := us / 1000
+	return fmt.Sprintf("%02d:%02d.%03d", ms/60000, ms/1000%60, ms%1000)
+}
+
+func truncateLabel(label string, max int) string {
+	label = strings.ReplaceAll(label, "\n", " ")
+	runes := []rune(label)
+	if len(runes) <= max {
+		return label
+	}
+	return string(runes[:max]) + "…"
+}
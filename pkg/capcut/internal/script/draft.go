@@ -0,0 +1,641 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Draft 类型化的剪映草稿（draft_content.json）。
+// 素材、轨道和片段解析为结构体，可以直接查看和修改，片段可按轨道类型取得带素材的视图（VideoSegments 等）；
+// 没有建模的字段原样保留，未修改时重新写出的文件与原文件逐字节相同
+type Draft struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Duration  int64           `json:"duration"` // 微秒
+	FPS       float64         `json:"fps"`
+	Canvas    *DraftCanvas    `json:"canvas_config"`
+	Materials *DraftMaterials `json:"materials"`
+	Tracks    []*DraftTrack   `json:"tracks"`
+
+	SavePath *string `json:"-"` // LoadDraft 打开的路径，Save 写回此处
+
+	raw    *rawObject
+	layout draftLayout
+}
+
+// DraftCanvas 画布配置
+type DraftCanvas struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Ratio  string `json:"ratio"`
+
+	raw *rawObject
+}
+
+// DraftMaterials 草稿中的素材，只建模片段引用的几类，其余素材列表原样保留
+type DraftMaterials struct {
+	Videos       []*DraftVideoMaterial  `json:"videos"`        // 视频和图片
+	Audios       []*DraftAudioMaterial  `json:"audios"`        // 旁白、背景音乐
+	Texts        []*DraftTextMaterial   `json:"texts"`         // 字幕和文本
+	Effects      []*DraftEffectMaterial `json:"effects"`       // 滤镜、花字、文字气泡
+	VideoEffects []*DraftEffectMaterial `json:"video_effects"` // 画面特效
+	Transitions  []*DraftEffectMaterial `json:"transitions"`   // 转场
+	Stickers     []*DraftEffectMaterial `json:"stickers"`      // 贴纸
+
+	raw *rawObject
+}
+
+// DraftVideoMaterial 视频或图片素材
+type DraftVideoMaterial struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"` // video 或 photo
+	MaterialName string `json:"material_name"`
+	Path         string `json:"path"`
+	Duration     int64  `json:"duration"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+
+	raw *rawObject
+}
+
+// DraftAudioMaterial 音频素材
+type DraftAudioMaterial struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Duration int64  `json:"duration"`
+
+	raw *rawObject
+}
+
+// DraftTextMaterial 文本素材，文字内容保存在 content 中
+type DraftTextMaterial struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"` // text 或 subtitle
+	Content string `json:"content"`
+
+	raw *rawObject
+}
+
+// DraftEffectMaterial 特效、滤镜、转场和贴纸素材
+type DraftEffectMaterial struct {
+	ID         string  `json:"id"`
+	Type       string  `json:"type"` // filter、text_shape、text_effect、video_effect、transition、sticker 等
+	Name       string  `json:"name"`
+	EffectID   string  `json:"effect_id"`
+	ResourceID string  `json:"resource_id"`
+	Duration   int64   `json:"duration"` // 转场时长（微秒）
+	Value      float64 `json:"value"`    // 滤镜强度
+
+	raw *rawObject
+}
+
+// DraftTrack 草稿中的一条轨道
+type DraftTrack struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"` // video、audio、text、effect、filter、sticker、adjust
+	Name      string          `json:"name"`
+	Attribute int             `json:"attribute"` // 1为静音
+	Segments  []*DraftSegment `json:"segments"`
+
+	raw *rawObject
+}
+
+// DraftTimerange 片段的时间范围（微秒），字段顺序与剪映一致
+type DraftTimerange struct {
+	Duration int64 `json:"duration"`
+	Start    int64 `json:"start"`
+}
+
+// End 结束时间
+func (r DraftTimerange) End() int64 {
+	return r.Start + r.Duration
+}
+
+// DraftPoint 二维坐标或缩放
+type DraftPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// DraftFlip 翻转
+type DraftFlip struct {
+	Horizontal bool `json:"horizontal"`
+	Vertical   bool `json:"vertical"`
+}
+
+// DraftClip 片段的画面变换
+type DraftClip struct {
+	Alpha     float64    `json:"alpha"`
+	Flip      DraftFlip  `json:"flip"`
+	Rotation  float64    `json:"rotation"`
+	Scale     DraftPoint `json:"scale"`
+	Transform DraftPoint `json:"transform"`
+}
+
+// DraftSegment 轨道上的一个片段，MaterialID 指向对应类型的素材，ExtraMaterialRefs 为动画、转场等附加素材
+type DraftSegment struct {
+	ID                string          `json:"id"`
+	MaterialID        string          `json:"material_id"`
+	TargetTimerange   DraftTimerange  `json:"target_timerange"`
+	SourceTimerange   *DraftTimerange `json:"source_timerange"` // 文本、特效片段为null
+	Speed             float64         `json:"speed"`
+	Volume            float64         `json:"volume"`
+	RenderIndex       int             `json:"render_index"`
+	ExtraMaterialRefs []string        `json:"extra_material_refs"`
+	Clip              *DraftClip      `json:"clip"`
+
+	raw *rawObject
+}
+
+// 不带 JSON 方法的别名，用于解析类型化字段
+type (
+	draftFields               Draft
+	draftCanvasFields         DraftCanvas
+	draftMaterialsFields      DraftMaterials
+	draftVideoMaterialFields  DraftVideoMaterial
+	draftAudioMaterialFields  DraftAudioMaterial
+	draftTextMaterialFields   DraftTextMaterial
+	draftEffectMaterialFields DraftEffectMaterial
+	draftTrackFields          DraftTrack
+	draftSegmentFields        DraftSegment
+)
+
+func (d *Draft) UnmarshalJSON(data []byte) (err error) {
+	d.raw, err = decodeRawObject(data, (*draftFields)(d))
+	return err
+}
+
+func (d *Draft) MarshalJSON() ([]byte, error) {
+	return encodeRawObject(d.raw, (*draftFields)(d))
+}
+
+func (c *DraftCanvas) UnmarshalJSON(data []byte) (err error) {
+	c.raw, err = decodeRawObject(data, (*draftCanvasFields)(c))
+	return err
+}
+
+func (c *DraftCanvas) MarshalJSON() ([]byte, error) {
+	return encodeRawObject(c.raw, (*draftCanvasFields)(c))
+}
+
+func (m *DraftMaterials) UnmarshalJSON(data []byte) (err error) {
+	m.raw, err = decodeRawObject(data, (*draftMaterialsFields)(m))
+	return err
+}
+
+func (m *DraftMaterials) MarshalJSON() ([]byte, error) {
+	return encodeRawObject(m.raw, (*draftMaterialsFields)(m))
+}
+
+func (m *DraftVideoMaterial) UnmarshalJSON(data []byte) (err error) {
+	m.raw, err = decodeRawObject(data, (*draftVideoMaterialFields)(m))
+	return err
+}
+
+func (m *DraftVideoMaterial) MarshalJSON() ([]byte, error) {
+	return encodeRawObject(m.raw, (*draftVideoMaterialFields)(m))
+}
+
+func (m *DraftAudioMaterial) UnmarshalJSON(data []byte) (err error) {
+	m.raw, err = decodeRawObject(data, (*draftAudioMaterialFields)(m))
+	return err
+}
+
+func (m *DraftAudioMaterial) MarshalJSON() ([]byte, error) {
+	return encodeRawObject(m.raw, (*draftAudioMaterialFields)(m))
+}
+
+func (m *DraftTextMaterial) UnmarshalJSON(data []byte) (err error) {
+	m.raw, err = decodeRawObject(data, (*draftTextMaterialFields)(m))
+	return err
+}
+
+func (m *DraftTextMaterial) MarshalJSON() ([]byte, error) {
+	return encodeRawObject(m.raw, (*draftTextMaterialFields)(m))
+}
+
+func (m *DraftEffectMaterial) UnmarshalJSON(data []byte) (err error) {
+	m.raw, err = decodeRawObject(data, (*draftEffectMaterialFields)(m))
+	return err
+}
+
+func (m *DraftEffectMaterial) MarshalJSON() ([]byte, error) {
+	return encodeRawObject(m.raw, (*draftEffectMaterialFields)(m))
+}
+
+func (t *DraftTrack) UnmarshalJSON(data []byte) (err error) {
+	t.raw, err = decodeRawObject(data, (*draftTrackFields)(t))
+	return err
+}
+
+func (t *DraftTrack) MarshalJSON() ([]byte, error) {
+	return encodeRawObject(t.raw, (*draftTrackFields)(t))
+}
+
+func (s *DraftSegment) UnmarshalJSON(data []byte) (err error) {
+	s.raw, err = decodeRawObject(data, (*draftSegmentFields)(s))
+	return err
+}
+
+func (s *DraftSegment) MarshalJSON() ([]byte, error) {
+	return encodeRawObject(s.raw, (*draftSegmentFields)(s))
+}
+
+// ParseDraft 解析 draft_content.json 的内容
+func ParseDraft(data []byte) (*Draft, error) {
+	draft := &Draft{}
+	if err := json.Unmarshal(data, draft); err != nil {
+		return nil, fmt.Errorf("无法解析草稿: %v", err)
+	}
+	if draft.Materials == nil {
+		draft.Materials = &DraftMaterials{}
+	}
+	draft.layout = detectLayout(data)
+	return draft, nil
+}
+
+// LoadDraft 从文件读取类型化的草稿，可以是 draft_content.json 或其所在的草稿目录
+func LoadDraft(path string) (*Draft, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "draft_content.json")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取草稿文件: %v", err)
+	}
+	draft, err := ParseDraft(data)
+	if err != nil {
+		return nil, err
+	}
+	draft.SavePath = &path
+	return draft, nil
+}
+
+// Draft 将草稿导出为类型化的草稿，用于与剪映中修改后的草稿对比
+func (sf *ScriptFile) Draft() (*Draft, error) {
+	content, err := sf.Dumps()
+	if err != nil {
+		return nil, err
+	}
+	return ParseDraft([]byte(content))
+}
+
+// Dumps 导出为JSON字符串，沿用原文件的排版（紧凑或缩进）
+func (d *Draft) Dumps() (string, error) {
+	data, err := marshalDraftValue(d)
+	if err != nil {
+		return "", fmt.Errorf("JSON序列化失败: %v", err)
+	}
+	formatted, err := d.layout.apply(data)
+	if err != nil {
+		return "", fmt.Errorf("JSON序列化失败: %v", err)
+	}
+	return string(formatted), nil
+}
+
+// Dump 写入文件
+func (d *Draft) Dump(filePath string) error {
+	content, err := d.Dumps()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+	return nil
+}
+
+// Save 写回 LoadDraft 打开的文件
+func (d *Draft) Save() error {
+	if d.SavePath == nil {
+		return fmt.Errorf("没有设置保存路径")
+	}
+	return d.Dump(*d.SavePath)
+}
+
+// VideoMaterial 按ID查找视频或图片素材
+func (d *Draft) VideoMaterial(id string) *DraftVideoMaterial {
+	for _, m := range d.Materials.Videos {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+// AudioMaterial 按ID查找音频素材
+func (d *Draft) AudioMaterial(id string) *DraftAudioMaterial {
+	for _, m := range d.Materials.Audios {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+// TextMaterial 按ID查找文本素材
+func (d *Draft) TextMaterial(id string) *DraftTextMaterial {
+	for _, m := range d.Materials.Texts {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+// EffectMaterial 按ID在滤镜、特效、转场和贴纸中查找
+func (d *Draft) EffectMaterial(id string) *DraftEffectMaterial {
+	for _, list := range [][]*DraftEffectMaterial{d.Materials.Effects, d.Materials.VideoEffects, d.Materials.Transitions, d.Materials.Stickers} {
+		for _, m := range list {
+			if m.ID == id {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// DraftVideoSegment 视频轨道上的片段及其视频或图片素材，素材不存在时 Material 为nil
+type DraftVideoSegment struct {
+	*DraftSegment
+	Material *DraftVideoMaterial
+}
+
+// DraftAudioSegment 音频轨道上的片段及其音频素材
+type DraftAudioSegment struct {
+	*DraftSegment
+	Material *DraftAudioMaterial
+}
+
+// DraftTextSegment 文本轨道上的片段及其文本素材
+type DraftTextSegment struct {
+	*DraftSegment
+	Material *DraftTextMaterial
+}
+
+// DraftEffectSegment 特效或滤镜轨道上的片段及其特效素材
+type DraftEffectSegment struct {
+	*DraftSegment
+	Material *DraftEffectMaterial
+}
+
+// VideoSegments 视频轨道上的片段，其他类型的轨道返回nil
+func (d *Draft) VideoSegments(track *DraftTrack) []DraftVideoSegment {
+	if track.Type != "video" {
+		return nil
+	}
+	segments := make([]DraftVideoSegment, len(track.Segments))
+	for i, seg := range track.Segments {
+		segments[i] = DraftVideoSegment{seg, d.VideoMaterial(seg.MaterialID)}
+	}
+	return segments
+}
+
+// AudioSegments 音频轨道上的片段，其他类型的轨道返回nil
+func (d *Draft) AudioSegments(track *DraftTrack) []DraftAudioSegment {
+	if track.Type != "audio" {
+		return nil
+	}
+	segments := make([]DraftAudioSegment, len(track.Segments))
+	for i, seg := range track.Segments {
+		segments[i] = DraftAudioSegment{seg, d.AudioMaterial(seg.MaterialID)}
+	}
+	return segments
+}
+
+// TextSegments 文本轨道上的片段，其他类型的轨道返回nil
+func (d *Draft) TextSegments(track *DraftTrack) []DraftTextSegment {
+	if track.Type != "text" {
+		return nil
+	}
+	segments := make([]DraftTextSegment, len(track.Segments))
+	for i, seg := range track.Segments {
+		segments[i] = DraftTextSegment{seg, d.TextMaterial(seg.MaterialID)}
+	}
+	return segments
+}
+
+// EffectSegments 特效或滤镜轨道上的片段，其他类型的轨道返回nil
+func (d *Draft) EffectSegments(track *DraftTrack) []DraftEffectSegment {
+	if track.Type != "effect" && track.Type != "filter" {
+		return nil
+	}
+	segments := make([]DraftEffectSegment, len(track.Segments))
+	for i, seg := range track.Segments {
+		segments[i] = DraftEffectSegment{seg, d.EffectMaterial(seg.MaterialID)}
+	}
+	return segments
+}
+
+// segmentView 按轨道类型返回片段的类型化视图，不支持的轨道类型返回nil
+func (d *Draft) segmentView(track *DraftTrack, seg *DraftSegment) draftSegmentView {
+	switch track.Type {
+	case "video":
+		return DraftVideoSegment{seg, d.VideoMaterial(seg.MaterialID)}
+	case "audio":
+		return DraftAudioSegment{seg, d.AudioMaterial(seg.MaterialID)}
+	case "text":
+		return DraftTextSegment{seg, d.TextMaterial(seg.MaterialID)}
+	case "effect", "filter":
+		return DraftEffectSegment{seg, d.EffectMaterial(seg.MaterialID)}
+	}
+	return nil
+}
+
+// draftSegmentView 各类片段视图共有的素材描述
+type draftSegmentView interface {
+	Source() string
+	Label() string
+}
+
+// Source 图片或视频文件路径
+func (s DraftVideoSegment) Source() string {
+	if s.Material == nil {
+		return ""
+	}
+	return s.Material.Path
+}
+
+// Label 素材名，没有时为文件名
+func (s DraftVideoSegment) Label() string {
+	if s.Material == nil {
+		return s.MaterialID
+	}
+	if s.Material.MaterialName != "" {
+		return s.Material.MaterialName
+	}
+	return filepath.Base(s.Material.Path)
+}
+
+// Source 音频文件路径
+func (s DraftAudioSegment) Source() string {
+	if s.Material == nil {
+		return ""
+	}
+	return s.Material.Path
+}
+
+// Label 素材名，没有时为文件名
+func (s DraftAudioSegment) Label() string {
+	if s.Material == nil {
+		return s.MaterialID
+	}
+	if s.Material.Name != "" {
+		return s.Material.Name
+	}
+	return filepath.Base(s.Material.Path)
+}
+
+// Source 文字
+func (s DraftTextSegment) Source() string {
+	if s.Material == nil {
+		return ""
+	}
+	return s.Material.Text()
+}
+
+// Label 文字
+func (s DraftTextSegment) Label() string {
+	if s.Material == nil {
+		return s.MaterialID
+	}
+	return s.Material.Text()
+}
+
+// Source 特效资源ID
+func (s DraftEffectSegment) Source() string {
+	if s.Material == nil {
+		return ""
+	}
+	return s.Material.ResourceID
+}
+
+// Label 特效名
+func (s DraftEffectSegment) Label() string {
+	if s.Material == nil {
+		return s.MaterialID
+	}
+	return s.Material.Name
+}
+
+// SegmentSource 片段引用的素材：媒体片段为文件路径，文本片段为文字，特效和滤镜为资源ID
+func (d *Draft) SegmentSource(track *DraftTrack, seg *DraftSegment) string {
+	if view := d.segmentView(track, seg); view != nil {
+		return view.Source()
+	}
+	return ""
+}
+
+// SegmentLabel 便于阅读的片段名称：素材名、文字或特效名
+func (d *Draft) SegmentLabel(track *DraftTrack, seg *DraftSegment) string {
+	if view := d.segmentView(track, seg); view != nil {
+		return view.Label()
+	}
+	return seg.MaterialID
+}
+
+// 文本素材 content 的两种写法：新版本为 {"styles":[...],"text":"..."} 的JSON字符串，
+// 旧版本（本项目生成的字幕）为 <font ...><color=...><size=...>文字</size></color></font>，换行写作 \u0001
+const richTextNewline = "\u0001"
+
+// Text 返回文本素材的文字
+func (m *DraftTextMaterial) Text() string {
+	content := strings.TrimSpace(m.Content)
+	if strings.HasPrefix(content, "{") {
+		var parsed struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(content), &parsed); err == nil {
+			return parsed.Text
+		}
+	}
+	if start, end, ok := richTextBounds(m.Content); ok {
+		return strings.ReplaceAll(m.Content[start:end], richTextNewline, "\n")
+	}
+	return m.Content
+}
+
+// Clone 复制文本素材，保留字体、颜色等没有建模的样式字段
+func (m *DraftTextMaterial) Clone() (*DraftTextMaterial, error) {
+	return cloneValue(m, &DraftTextMaterial{})
+}
+
+// SetText 修改文本素材的文字，保留样式。JSON 写法中覆盖到原文末尾的样式区间随文字长度伸缩
+func (m *DraftTextMaterial) SetText(text string) error {
+	content := strings.TrimSpace(m.Content)
+	if strings.HasPrefix(content, "{") {
+		obj, err := parseRawObject([]byte(content))
+		if err != nil {
+			return fmt.Errorf("无法解析文本内容: %v", err)
+		}
+		var parsed struct {
+			Text   string                   `json:"text"`
+			Styles []map[string]interface{} `json:"styles"`
+		}
+		if err := json.Unmarshal([]byte(content), &parsed); err != nil {
+			return fmt.Errorf("无法解析文本内容: %v", err)
+		}
+		oldLength, newLength := utf8.RuneCountInString(parsed.Text), utf8.RuneCountInString(text)
+		for _, style := range parsed.Styles {
+			rng, ok := style["range"].([]interface{})
+			if !ok || len(rng) != 2 {
+				continue
+			}
+			start, _ := rng[0].(float64)
+			end, _ := rng[1].(float64)
+			if int(end) >= oldLength {
+				end = float64(newLength)
+			}
+			if int(start) > newLength {
+				start = float64(newLength)
+			}
+			style["range"] = []interface{}{int(start), int(end)}
+		}
+		textValue, err := marshalDraftValue(text)
+		if err != nil {
+			return err
+		}
+		obj.values["text"] = textValue
+		if _, ok := obj.values["styles"]; ok {
+			styles, err := marshalDraftValue(parsed.Styles)
+			if err != nil {
+				return err
+			}
+			obj.values["styles"] = styles
+		}
+		encoded, err := encodeRawObject(obj, &struct{}{})
+		if err != nil {
+			return err
+		}
+		m.Content = string(encoded)
+		return nil
+	}
+	if start, end, ok := richTextBounds(m.Content); ok {
+		m.Content = m.Content[:start] + strings.ReplaceAll(text, "\n", richTextNewline) + m.Content[end:]
+		return nil
+	}
+	m.Content = text
+	return nil
+}
+
+// richTextBounds 旧版本富文本中文字的位置：最后一个开始标签之后、第一个结束标签之前
+func richTextBounds(content string) (int, int, bool) {
+	if !strings.HasPrefix(content, "<") {
+		return 0, 0, false
+	}
+	end := strings.Index(content, "</")
+	if end < 0 {
+		return 0, 0, false
+	}
+	start := strings.LastIndex(content[:end], ">")
+	if start < 0 {
+		return 0, 0, false
+	}
+	return start + 1, end, true
+}
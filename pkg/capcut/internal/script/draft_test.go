@@ -0,0 +1,201 @@
+package script
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadTestDraft(t *testing.T) (*Draft, []byte) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "draft_content.json"))
+	if err != nil {
+		t.Fatalf("读取测试草稿失败: %v", err)
+	}
+	draft, err := ParseDraft(data)
+	if err != nil {
+		t.Fatalf("解析草稿失败: %v", err)
+	}
+	return draft, data
+}
+
+// TestDraftRoundTrip 测试未修改的草稿重新写出后逐字节不变
+func TestDraftRoundTrip(t *testing.T) {
+	draft, data := loadTestDraft(t)
+	if len(draft.Tracks) != 5 || len(draft.Materials.Videos) != 2 || len(draft.Materials.Transitions) != 1 {
+		t.Fatalf("轨道或素材解析错误: %d 条轨道", len(draft.Tracks))
+	}
+	if draft.Canvas.Width != 1080 || draft.FPS != 30 || draft.Duration != 6000000 {
+		t.Errorf("草稿设置解析错误: %+v", draft.Canvas)
+	}
+	if seg := draft.Tracks[2].Segments[0]; seg.SourceTimerange != nil || seg.TargetTimerange.Duration != 2000000 {
+		t.Errorf("文本片段时间解析错误: %+v", seg)
+	}
+
+	out, err := draft.Dumps()
+	if err != nil {
+		t.Fatalf("导出草稿失败: %v", err)
+	}
+	if out != string(data) {
+		t.Fatalf("紧凑格式的草稿写出后发生变化:\n%s", out)
+	}
+
+	// 本项目生成的缩进格式
+	sf, err := NewScriptFile(1080, 1920, 30)
+	if err != nil {
+		t.Fatalf("创建草稿失败: %v", err)
+	}
+	dumped, err := sf.Dumps()
+	if err != nil {
+		t.Fatalf("导出草稿失败: %v", err)
+	}
+	generated, err := ParseDraft([]byte(dumped))
+	if err != nil {
+		t.Fatalf("解析生成的草稿失败: %v", err)
+	}
+	if out, _ := generated.Dumps(); out != dumped {
+		t.Error("缩进格式的草稿写出后发生变化")
+	}
+}
+
+// TestDraftModifySegment 测试修改片段只改变对应字段，未知字段保留
+func TestDraftModifySegment(t *testing.T) {
+	draft, data := loadTestDraft(t)
+	draft.Tracks[0].Segments[1].TargetTimerange.Start = 3500000
+
+	out, err := draft.Dumps()
+	if err != nil {
+		t.Fatalf("导出草稿失败: %v", err)
+	}
+	expected := strings.Replace(string(data),
+		`"target_timerange":{"duration":3000000,"start":3000000}`,
+		`"target_timerange":{"duration":3000000,"start":3500000}`, 1)
+	if out != expected {
+		t.Fatalf("只应修改第二个片段的起始时间:\n%s", out)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "draft_content.json")
+	if err := draft.Dump(path); err != nil {
+		t.Fatalf("写入草稿失败: %v", err)
+	}
+	reloaded, err := LoadDraft(dir)
+	if err != nil {
+		t.Fatalf("重新读取草稿失败: %v", err)
+	}
+	if reloaded.Tracks[0].Segments[1].TargetTimerange.Start != 3500000 || *reloaded.SavePath != path {
+		t.Error("重新读取的草稿内容错误")
+	}
+}
+
+// TestDraftTextMaterial 测试两种文本内容写法的读取和修改
+func TestDraftTextMaterial(t *testing.T) {
+	draft, _ := loadTestDraft(t)
+	styled := draft.Materials.Texts[0]
+	rich := draft.Materials.Texts[1]
+	if styled.Text() != "第一句话" || rich.Text() != "第二句\n换行" {
+		t.Fatalf("文字读取错误: %q %q", styled.Text(), rich.Text())
+	}
+
+	if err := styled.SetText("修改后的第一句"); err != nil {
+		t.Fatalf("修改文字失败: %v", err)
+	}
+	if styled.Text() != "修改后的第一句" || !strings.Contains(styled.Content, `"range":[0,7]`) || !strings.Contains(styled.Content, `"size":8`) {
+		t.Errorf("样式区间应随文字伸缩: %s", styled.Content)
+	}
+
+	if err := rich.SetText("新的\n两行"); err != nil {
+		t.Fatalf("修改文字失败: %v", err)
+	}
+	if !strings.HasSuffix(rich.Content, "<size=8.000000>新的\u0001两行</size></color></font>") {
+		t.Errorf("富文本标签应保留: %s", rich.Content)
+	}
+
+	if label := draft.SegmentLabel(draft.Tracks[4], draft.Tracks[4].Segments[0]); label != "暖食" {
+		t.Errorf("滤镜片段名称错误: %s", label)
+	}
+}
+
+// TestDraftSegmentViews 测试按轨道类型取得带素材的片段视图
+func TestDraftSegmentViews(t *testing.T) {
+	draft, _ := loadTestDraft(t)
+	videos := draft.VideoSegments(draft.Tracks[0])
+	if len(videos) != 2 || videos[1].Material == nil || videos[1].Material.Path != "/work/chapter_01/scene_02.png" {
+		t.Fatalf("视频片段视图错误: %+v", videos)
+	}
+	if videos[1].TargetTimerange.Start != 3000000 || videos[1].Label() != "scene_02.png" {
+		t.Errorf("视图应能直接读取片段字段和素材名: %+v", videos[1].TargetTimerange)
+	}
+	if draft.AudioSegments(draft.Tracks[0]) != nil || draft.TextSegments(draft.Tracks[1]) != nil {
+		t.Error("轨道类型不符时应返回nil")
+	}
+	if audios := draft.AudioSegments(draft.Tracks[1]); len(audios) != 1 || audios[0].Source() != "/work/chapter_01/chapter_01.wav" {
+		t.Errorf("音频片段视图错误: %+v", audios)
+	}
+	if filters := draft.EffectSegments(draft.Tracks[4]); len(filters) != 1 || filters[0].Material.Name != "暖食" {
+		t.Errorf("滤镜片段视图错误: %+v", filters)
+	}
+
+	// 视图中的素材即草稿中的素材，复制的素材与原素材互不影响
+	texts := draft.TextSegments(draft.Tracks[2])
+	clone, err := texts[1].Material.Clone()
+	if err != nil {
+		t.Fatalf("复制文本素材失败: %v", err)
+	}
+	if err := texts[1].Material.SetText("改过的第二句"); err != nil {
+		t.Fatalf("修改文字失败: %v", err)
+	}
+	if draft.TextMaterial(texts[1].MaterialID).Text() != "改过的第二句" {
+		t.Error("通过视图修改的文字应写入草稿的素材")
+	}
+	if clone.Text() != "第二句\n换行" || clone.ID != texts[1].MaterialID {
+		t.Errorf("复制的素材不应随原素材改变: %q", clone.Text())
+	}
+}
+
+// TestDiffDrafts 测试草稿的语义差异
+func TestDiffDrafts(t *testing.T) {
+	base, _ := loadTestDraft(t)
+	if diff := DiffDrafts(base, base); !diff.Empty() {
+		t.Fatalf("相同草稿不应有差异: %s", diff)
+	}
+
+	edited, _ := loadTestDraft(t)
+	video := edited.Tracks[0]
+	video.Segments[1].TargetTimerange.Start = 3500000
+	video.Segments[0].TargetTimerange.Duration = 2500000
+	edited.Materials.Texts[0].SetText("改过的字幕")
+	edited.Tracks[3].Segments = nil
+	// 在剪映中删除后重新添加的片段ID会变化，按文字配对
+	edited.Tracks[2].Segments[1].ID = "S0000000-0000-4000-8000-000000000099"
+	edited.Tracks[2].Segments = append(edited.Tracks[2].Segments, &DraftSegment{
+		ID: "S0000000-0000-4000-8000-000000000100", MaterialID: "T0000000-0000-4000-8000-000000000001",
+		TargetTimerange: DraftTimerange{Start: 5000000, Duration: 1000000},
+	})
+	edited.Duration = 6500000
+
+	diff := DiffDrafts(base, edited)
+	counts := map[ChangeKind]int{
+		ChangeSetting: 1, ChangeMoved: 1, ChangeRetimed: 1, ChangeText: 1, ChangeRemoved: 1, ChangeAdded: 1,
+	}
+	for kind, want := range counts {
+		if got := diff.Count(kind); got != want {
+			t.Errorf("%s 差异应为 %d 处，实际 %d:\n%s", kind, want, got, diff)
+		}
+	}
+	if len(diff.Changes) != 6 || diff.Changes[0].Kind != ChangeSetting {
+		t.Fatalf("差异数量或顺序错误:\n%s", diff)
+	}
+	for _, change := range diff.Changes {
+		if change.Kind == ChangeText && (change.OldValue != "第一句话" || change.NewValue != "改过的字幕") {
+			t.Errorf("文字差异错误: %+v", change)
+		}
+		if change.Kind == ChangeRemoved && change.TrackType != "effect" {
+			t.Errorf("删除的应为特效片段: %+v", change)
+		}
+	}
+	if !strings.Contains(diff.String(), "[移动] video轨道#0 「scene_02.png」 00:03.000-00:06.000 -> 00:03.500-00:06.500") {
+		t.Errorf("差异文本错误:\n%s", diff)
+	}
+}
@@ -0,0 +1,252 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"novel-video-workflow/pkg/capcut/internal/util"
+)
+
+// draftBaseKeys 各版本剪映打开草稿都需要的顶层字段
+var draftBaseKeys = []string{
+	"canvas_config", "config", "duration", "fps", "id", "materials", "new_version", "platform", "tracks", "version",
+}
+
+// draftVersionKeys 按剪映主版本额外需要的顶层字段。
+// 3.x 为 mac 剪映专业版（草稿为 draft_info.json），缺少这些字段时打开草稿会崩溃；新版本会自动补全
+var draftVersionKeys = map[int][]string{
+	3: {"keyframes", "last_modified_platform", "relationships", "render_index_track_mode_on"},
+}
+
+// draftPathPlaceholder 剪映以草稿目录为基准的素材路径前缀，如 ##_draftpath_placeholder_<ID>_##/materials/a.png
+const draftPathPlaceholder = "##_draftpath_placeholder_"
+
+// LintOptions 草稿检查选项
+type LintOptions struct {
+	SkipFiles  bool   // 不检查素材文件是否存在，如检查从其他电脑拷来的草稿
+	AppVersion string // 目标剪映版本，如 3.4.1；为空时取草稿的 platform.app_version
+}
+
+// Lint 检查草稿中会导致剪映显示"素材丢失"或打开时崩溃的问题，返回 util 中的类型化错误：
+// 缺少必需字段或画布、帧率不一致（ValidationError）、片段引用的素材不存在（MaterialNotFoundError）、
+// 素材文件不存在（MediaFileNotFoundError）、同一轨道上的片段重叠（SegmentOverlapError）、
+// 片段时长与素材时长不符（DurationMismatchError）
+func (d *Draft) Lint(opts LintOptions) []error {
+	var problems []error
+	problems = append(problems, d.lintKeys(opts.AppVersion)...)
+	problems = append(problems, d.lintCanvas()...)
+	problems = append(problems, d.lintTracks()...)
+	if !opts.SkipFiles {
+		problems = append(problems, d.lintFiles()...)
+	}
+	return problems
+}
+
+// Lint 导出草稿并检查，有问题时返回 *util.DraftLintError
+func (sf *ScriptFile) Lint(opts LintOptions) error {
+	draft, err := sf.Draft()
+	if err != nil {
+		return err
+	}
+	return util.NewDraftLintError(draft.Lint(opts))
+}
+
+// AppVersion 草稿的 platform.app_version
+func (d *Draft) AppVersion() string {
+	if d.raw == nil {
+		return ""
+	}
+	var platform struct {
+		AppVersion string `json:"app_version"`
+	}
+	if err := json.Unmarshal(d.raw.values["platform"], &platform); err != nil {
+		return ""
+	}
+	return platform.AppVersion
+}
+
+// lintKeys 检查目标版本需要的顶层字段
+func (d *Draft) lintKeys(appVersion string) []error {
+	if appVersion == "" {
+		appVersion = d.AppVersion()
+	}
+	keys := draftBaseKeys
+	if major, err := strconv.Atoi(strings.SplitN(appVersion, ".", 2)[0]); err == nil {
+		keys = append(keys[:len(keys):len(keys)], draftVersionKeys[major]...)
+	}
+
+	var problems []error
+	for _, key := range keys {
+		if d.raw == nil {
+			problems = append(problems, util.NewValidationError(key, nil, "draft has no fields"))
+			break
+		}
+		if _, ok := d.raw.values[key]; !ok {
+			problems = append(problems, util.NewValidationError(key, nil, fmt.Sprintf("required by CapCut %s", appVersion)))
+		}
+	}
+	return problems
+}
+
+// lintCanvas 检查画布尺寸、比例和帧率
+func (d *Draft) lintCanvas() []error {
+	var problems []error
+	if d.FPS <= 0 {
+		problems = append(problems, util.NewValidationError("fps", d.FPS, "must be positive"))
+	}
+	if d.Canvas == nil {
+		return problems
+	}
+	if d.Canvas.Width <= 0 || d.Canvas.Height <= 0 {
+		problems = append(problems, util.NewValidationError("canvas_config", fmt.Sprintf("%dx%d", d.Canvas.Width, d.Canvas.Height), "width and height must be positive"))
+		return problems
+	}
+	if w, h, ok := parseRatio(d.Canvas.Ratio); ok && d.Canvas.Width*h != d.Canvas.Height*w {
+		problems = append(problems, util.NewValidationError("canvas_config.ratio", d.Canvas.Ratio,
+			fmt.Sprintf("does not match canvas size %dx%d", d.Canvas.Width, d.Canvas.Height)))
+	}
+	return problems
+}
+
+// parseRatio 解析 9:16 这样的画布比例，original 等其他取值返回false
+func parseRatio(ratio string) (int, int, bool) {
+	parts := strings.Split(ratio, ":")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	w, errW := strconv.Atoi(parts[0])
+	h, errH := strconv.Atoi(parts[1])
+	if errW != nil || errH != nil || w <= 0 || h <= 0 {
+		return 0, 0, false
+	}
+	return w, h, true
+}
+
+// lintTracks 检查片段引用的素材、片段重叠、片段与素材的时长，以及草稿时长
+func (d *Draft) lintTracks() []error {
+	var problems []error
+	ids, err := materialIDs(d.Materials)
+	if err != nil {
+		return []error{util.NewJSONProcessingError("read materials", "materials", err.Error())}
+	}
+
+	// 时长允许一帧的误差
+	tolerance := int64(1000000 / 30)
+	if d.FPS > 0 {
+		tolerance = int64(1000000 / d.FPS)
+	}
+
+	var end int64
+	for _, track := range d.Tracks {
+		segments := append([]*DraftSegment(nil), track.Segments...)
+		sort.SliceStable(segments, func(i, j int) bool {
+			return segments[i].TargetTimerange.Start < segments[j].TargetTimerange.Start
+		})
+		// 与之前结束最晚的片段比较，被长片段覆盖的多个短片段也能查出重叠
+		var last *DraftSegment
+		for _, seg := range segments {
+			if seg.TargetTimerange.End() > end {
+				end = seg.TargetTimerange.End()
+			}
+			if seg.TargetTimerange.Duration <= 0 {
+				problems = append(problems, util.NewValidationError("target_timerange.duration", seg.TargetTimerange.Duration,
+					fmt.Sprintf("segment %s on %s track must have a positive duration", seg.ID, track.Type)))
+			}
+			if last != nil && seg.TargetTimerange.Start < last.TargetTimerange.End() {
+				problems = append(problems, util.NewSegmentOverlapError(
+					seg.TargetTimerange.Start, seg.TargetTimerange.End(), last.TargetTimerange.Start, last.TargetTimerange.End()))
+			}
+			if last == nil || seg.TargetTimerange.End() > last.TargetTimerange.End() {
+				last = seg
+			}
+			if !ids[strings.ToLower(seg.MaterialID)] {
+				problems = append(problems, util.NewMaterialNotFoundError(
+					fmt.Sprintf("segment %s on %s track references material %q", seg.ID, track.Type, seg.MaterialID)))
+			}
+			for _, ref := range seg.ExtraMaterialRefs {
+				if !ids[strings.ToLower(ref)] {
+					problems = append(problems, util.NewMaterialNotFoundError(
+						fmt.Sprintf("segment %s on %s track references extra material %q", seg.ID, track.Type, ref)))
+				}
+			}
+			if track.Type == "video" || track.Type == "audio" {
+				problems = append(problems, d.lintSegmentDuration(track, seg, tolerance)...)
+			}
+		}
+	}
+
+	if d.Duration+tolerance < end {
+		problems = append(problems, util.NewValidationError("duration", d.Duration,
+			fmt.Sprintf("shorter than the last segment ending at %d", end)))
+	}
+	return problems
+}
+
+// lintSegmentDuration 检查音视频片段取用的素材范围：按播放速度与片段时长一致，且不超出素材时长（图片不限时长）
+func (d *Draft) lintSegmentDuration(track *DraftTrack, seg *DraftSegment, tolerance int64) []error {
+	if seg.SourceTimerange == nil {
+		return []error{util.NewValidationError("source_timerange", nil, fmt.Sprintf("media segment %s has no source range", seg.ID))}
+	}
+	var problems []error
+	speed := seg.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	expected := int64(float64(seg.TargetTimerange.Duration) * speed)
+	if diff := seg.SourceTimerange.Duration - expected; diff > tolerance || diff < -tolerance {
+		problems = append(problems, util.NewDurationMismatchError(seg.ID, seg.MaterialID, expected, seg.SourceTimerange.Duration,
+			fmt.Sprintf("source range does not match target range at speed %g", speed)))
+	}
+
+	var materialDuration int64
+	switch view := d.segmentView(track, seg).(type) {
+	case DraftVideoSegment:
+		if view.Material != nil && view.Material.Type != "photo" {
+			materialDuration = view.Material.Duration
+		}
+	case DraftAudioSegment:
+		if view.Material != nil {
+			materialDuration = view.Material.Duration
+		}
+	}
+	if materialDuration > 0 && seg.SourceTimerange.End() > materialDuration+tolerance {
+		problems = append(problems, util.NewDurationMismatchError(seg.ID, seg.MaterialID, materialDuration, seg.SourceTimerange.End(),
+			"source range exceeds material duration"))
+	}
+	return problems
+}
+
+// lintFiles 检查音视频素材文件是否存在
+func (d *Draft) lintFiles() []error {
+	draftDir := ""
+	if d.SavePath != nil {
+		draftDir = filepath.Dir(*d.SavePath)
+	}
+	var problems []error
+	check := func(id, path string) {
+		if path == "" {
+			return
+		}
+		resolved := path
+		if strings.HasPrefix(path, draftPathPlaceholder) {
+			if i := strings.Index(path, "_##"); i >= 0 {
+				resolved = filepath.Join(draftDir, path[i+len("_##"):])
+			}
+		}
+		if _, err := os.Stat(resolved); err != nil {
+			problems = append(problems, util.NewMediaFileNotFoundError(id, path))
+		}
+	}
+	for _, m := range d.Materials.Videos {
+		check(m.ID, m.Path)
+	}
+	for _, m := range d.Materials.Audios {
+		check(m.ID, m.Path)
+	}
+	return problems
+}
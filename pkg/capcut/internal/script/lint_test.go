@@ -0,0 +1,94 @@
+package script
+
+import (
+	"errors"
+	"testing"
+
+	"novel-video-workflow/pkg/capcut/internal/util"
+)
+
+// TestLintDraft 测试草稿检查：完好的草稿没有问题，各类损坏返回对应的类型化错误
+func TestLintDraft(t *testing.T) {
+	draft, _ := loadTestDraft(t)
+	if problems := draft.Lint(LintOptions{SkipFiles: true}); len(problems) != 0 {
+		t.Fatalf("完好的草稿不应有问题: %v", problems)
+	}
+
+	// 测试草稿中的素材路径在本机不存在
+	problems := draft.Lint(LintOptions{})
+	if len(problems) != 3 {
+		t.Errorf("应有3个素材文件不存在，得到 %v", problems)
+	}
+	for _, problem := range problems {
+		if !util.IsMediaFileNotFound(problem) {
+			t.Errorf("应为素材文件不存在错误: %v", problem)
+		}
+	}
+
+	// 3.x 版本需要的字段
+	problems = draft.Lint(LintOptions{SkipFiles: true, AppVersion: "3.4.1"})
+	if len(problems) != 4 || !util.IsValidationError(problems[0]) {
+		t.Errorf("3.x 版本应缺少4个字段，得到 %v", problems)
+	}
+
+	// 损坏草稿：第二张图片与第一张重叠并超出草稿时长、字幕引用不存在的素材、旁白超出素材长度、画布比例不符
+	draft.Tracks[0].Segments[1].TargetTimerange = DraftTimerange{Start: 2500000, Duration: 4000000}
+	draft.Tracks[0].Segments[1].SourceTimerange = &DraftTimerange{Start: 0, Duration: 4000000}
+	draft.Tracks[2].Segments[1].MaterialID = "T-MISSING"
+	draft.Tracks[1].Segments[0].SourceTimerange.Start = 1000000
+	draft.Canvas.Ratio = "16:9"
+
+	problems = draft.Lint(LintOptions{SkipFiles: true})
+	var overlap *util.SegmentOverlapError
+	var missing *util.MaterialNotFoundError
+	var mismatch *util.DurationMismatchError
+	err := util.NewDraftLintError(problems)
+	if !errors.As(err, &overlap) || overlap.ExistingEnd != 3000000 {
+		t.Errorf("应报告图片重叠: %v", err)
+	}
+	if !errors.As(err, &missing) {
+		t.Errorf("应报告字幕素材不存在: %v", err)
+	}
+	if !errors.As(err, &mismatch) || mismatch.Expected != 6000000 || mismatch.Actual != 7000000 {
+		t.Errorf("应报告旁白超出素材长度: %v", err)
+	}
+	var fields []string
+	for _, problem := range problems {
+		if v, ok := problem.(*util.ValidationError); ok {
+			fields = append(fields, v.Field)
+		}
+	}
+	if len(fields) != 2 || fields[0] != "canvas_config.ratio" || fields[1] != "duration" {
+		t.Errorf("应报告画布比例和草稿时长，得到 %v", fields)
+	}
+	if len(problems) != 5 {
+		t.Errorf("应有5个问题，得到 %d: %v", len(problems), problems)
+	}
+}
+
+// TestLintNestedOverlap 测试长片段覆盖多个短片段时，每个短片段都报告重叠
+func TestLintNestedOverlap(t *testing.T) {
+	draft, _ := loadTestDraft(t)
+	texts := draft.Tracks[2]
+	nested := *texts.Segments[1]
+	nested.ID = "S-NESTED"
+	texts.Segments = append(texts.Segments, &nested)
+	texts.Segments[0].TargetTimerange = DraftTimerange{Start: 0, Duration: 6000000}
+	texts.Segments[1].TargetTimerange = DraftTimerange{Start: 1000000, Duration: 500000}
+	texts.Segments[2].TargetTimerange = DraftTimerange{Start: 3000000, Duration: 2000000}
+
+	var overlaps []*util.SegmentOverlapError
+	for _, problem := range draft.Lint(LintOptions{SkipFiles: true}) {
+		if overlap, ok := problem.(*util.SegmentOverlapError); ok {
+			overlaps = append(overlaps, overlap)
+		} else {
+			t.Errorf("不应有其他问题: %v", problem)
+		}
+	}
+	if len(overlaps) != 2 {
+		t.Fatalf("两个短片段都应与长片段重叠，得到 %v", overlaps)
+	}
+	if last := overlaps[1]; last.NewSegmentStart != 3000000 || last.ExistingStart != 0 || last.ExistingEnd != 6000000 {
+		t.Errorf("第三个片段应与覆盖它的长片段比较: %+v", last)
+	}
+}
@@ -0,0 +1,667 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MergeConflict 三方合并中无法自动处理的一处修改：用户在剪映中的修改和重新生成的结果改动了同一处，
+// 合并结果保留用户的版本
+type MergeConflict struct {
+	SegmentID string `json:"segment_id"`
+	TrackType string `json:"track_type,omitempty"`
+	Label     string `json:"label,omitempty"`
+	Field     string `json:"field"` // target_timerange、source_timerange、content、volume、speed、segment、track
+	Base      string `json:"base,omitempty"`
+	Edited    string `json:"edited,omitempty"`
+	Generated string `json:"generated,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+func (c MergeConflict) String() string {
+	label := c.SegmentID
+	if c.Label != "" {
+		label = truncateLabel(c.Label, 20)
+	}
+	return fmt.Sprintf("[冲突] %s「%s」%s: %s", c.TrackType, label, c.Field, c.Reason)
+}
+
+// MergeResult 三方合并的结果。Applied 为应用到用户草稿上的生成变化
+type MergeResult struct {
+	Draft     *Draft          `json:"-"`
+	Applied   []SegmentChange `json:"applied"`
+	Conflicts []MergeConflict `json:"conflicts"`
+}
+
+// mergeField 片段上参与合并的一组字段
+type mergeField struct {
+	name  string
+	value func(d *Draft, track *DraftTrack, seg *DraftSegment) string
+	apply func(result *Draft, seg *DraftSegment, generated *Draft, from *DraftSegment) error
+}
+
+var mergeFields = []mergeField{
+	{
+		name: "target_timerange",
+		value: func(d *Draft, track *DraftTrack, seg *DraftSegment) string {
+			return formatTimerange(&seg.TargetTimerange)
+		},
+		apply: func(result *Draft, seg *DraftSegment, generated *Draft, from *DraftSegment) error {
+			seg.TargetTimerange = from.TargetTimerange
+			return nil
+		},
+	},
+	{
+		name: "source_timerange",
+		value: func(d *Draft, track *DraftTrack, seg *DraftSegment) string {
+			return formatTimerange(seg.SourceTimerange)
+		},
+		apply: func(result *Draft, seg *DraftSegment, generated *Draft, from *DraftSegment) error {
+			if from.SourceTimerange == nil {
+				seg.SourceTimerange = nil
+				return nil
+			}
+			source := *from.SourceTimerange
+			seg.SourceTimerange = &source
+			return nil
+		},
+	},
+	{
+		name: "content",
+		value: func(d *Draft, track *DraftTrack, seg *DraftSegment) string {
+			return d.SegmentSource(track, seg)
+		},
+		apply: mergeContent,
+	},
+	{
+		name: "volume",
+		value: func(d *Draft, track *DraftTrack, seg *DraftSegment) string {
+			return fmt.Sprint(seg.Volume)
+		},
+		apply: func(result *Draft, seg *DraftSegment, generated *Draft, from *DraftSegment) error {
+			seg.Volume = from.Volume
+			return nil
+		},
+	},
+	{
+		name: "speed",
+		value: func(d *Draft, track *DraftTrack, seg *DraftSegment) string {
+			return fmt.Sprint(seg.Speed)
+		},
+		apply: func(result *Draft, seg *DraftSegment, generated *Draft, from *DraftSegment) error {
+			seg.Speed = from.Speed
+			return nil
+		},
+	},
+}
+
+// mergeContent 把生成结果中素材的变化（图片、音频路径或字幕文字）写入用户草稿的素材，保留用户的样式
+func mergeContent(result *Draft, seg *DraftSegment, generated *Draft, from *DraftSegment) error {
+	if m := generated.TextMaterial(from.MaterialID); m != nil {
+		target := result.TextMaterial(seg.MaterialID)
+		if target == nil {
+			return fmt.Errorf("找不到文本素材 %s", seg.MaterialID)
+		}
+		return target.SetText(m.Text())
+	}
+	if m := generated.VideoMaterial(from.MaterialID); m != nil {
+		target := result.VideoMaterial(seg.MaterialID)
+		if target == nil {
+			return fmt.Errorf("找不到视频素材 %s", seg.MaterialID)
+		}
+		target.Path, target.MaterialName, target.Duration = m.Path, m.MaterialName, m.Duration
+		target.Width, target.Height = m.Width, m.Height
+		return nil
+	}
+	if m := generated.AudioMaterial(from.MaterialID); m != nil {
+		target := result.AudioMaterial(seg.MaterialID)
+		if target == nil {
+			return fmt.Errorf("找不到音频素材 %s", seg.MaterialID)
+		}
+		target.Path, target.Name, target.Duration = m.Path, m.Name, m.Duration
+		return nil
+	}
+	return nil
+}
+
+// segmentLocation 片段所在的轨道
+type segmentLocation struct {
+	track   *DraftTrack
+	segment *DraftSegment
+}
+
+// segmentIndex 按ID索引草稿中的片段。剪映保存时可能改变ID的大小写，索引不区分大小写
+func segmentIndex(d *Draft) map[string]segmentLocation {
+	index := make(map[string]segmentLocation)
+	for _, track := range d.Tracks {
+		for _, seg := range track.Segments {
+			index[strings.ToLower(seg.ID)] = segmentLocation{track, seg}
+		}
+	}
+	return index
+}
+
+func trackIndex(d *Draft) map[string]*DraftTrack {
+	index := make(map[string]*DraftTrack)
+	for _, track := range d.Tracks {
+		index[strings.ToLower(track.ID)] = track
+	}
+	return index
+}
+
+// MergeDrafts 三方合并：base 为上次生成的草稿，edited 为用户在剪映中修改后的草稿，generated 为重新生成的草稿。
+// 以 edited 为基础，应用 base 到 generated 之间的变化（新增、删除的片段，时间、素材和文字的修改），
+// 用户新增的片段、轨道、贴纸等保持不变。双方都修改了同一处时保留用户的修改并记录冲突。
+// 片段按ID对应，要求生成器为片段生成稳定的ID
+func MergeDrafts(base, edited, generated *Draft) (*MergeResult, error) {
+	result, err := cloneDraft(edited)
+	if err != nil {
+		return nil, err
+	}
+	merge := &draftMerge{
+		base:      base,
+		edited:    edited,
+		generated: generated,
+		result:    result,
+		baseSegs:  segmentIndex(base),
+		genSegs:   segmentIndex(generated),
+		resSegs:   segmentIndex(result),
+		out:       &MergeResult{Draft: result},
+	}
+	if err := merge.run(); err != nil {
+		return nil, err
+	}
+	return merge.out, nil
+}
+
+type draftMerge struct {
+	base, edited, generated, result *Draft
+	baseSegs, genSegs, resSegs      map[string]segmentLocation
+	moves                           []segmentMove
+	out                             *MergeResult
+}
+
+// segmentMove 待应用的片段时间变化，original 为用户草稿中的原时间
+type segmentMove struct {
+	base, result, generated segmentLocation
+	original                DraftTimerange
+}
+
+// pendingSegment 重新生成时新增、等待加入用户草稿的片段
+type pendingSegment struct {
+	resTrack, genTrack *DraftTrack
+	segment            *DraftSegment
+}
+
+func (m *draftMerge) run() error {
+	baseTracks, resultTracks := trackIndex(m.base), trackIndex(m.result)
+	var added []pendingSegment
+
+	for _, genTrack := range m.generated.Tracks {
+		resTrack := resultTracks[strings.ToLower(genTrack.ID)]
+		if resTrack == nil {
+			if baseTrack := baseTracks[strings.ToLower(genTrack.ID)]; baseTrack != nil {
+				// 用户删除了整条轨道
+				if !sameEncoding(baseTrack.Segments, genTrack.Segments) {
+					m.conflict(genTrack, nil, "track", "用户删除了该轨道，但重新生成时轨道内容有变化", "", "", "")
+				}
+				continue
+			}
+			if err := m.addTrack(genTrack); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, genSeg := range genTrack.Segments {
+			id := strings.ToLower(genSeg.ID)
+			baseLoc, inBase := m.baseSegs[id]
+			resLoc, inResult := m.resSegs[id]
+			switch {
+			case !inBase && !inResult:
+				added = append(added, pendingSegment{resTrack, genTrack, genSeg})
+			case !inBase && inResult:
+				for _, field := range mergeFields {
+					edited := field.value(m.result, resLoc.track, resLoc.segment)
+					generated := field.value(m.generated, genTrack, genSeg)
+					if edited != generated {
+						m.conflict(genTrack, genSeg, field.name, "用户和重新生成的草稿各自新增了同一片段", "", edited, generated)
+					}
+				}
+			case inBase && !inResult:
+				if segmentChanged(m.base, baseLoc, m.generated, segmentLocation{genTrack, genSeg}) {
+					m.conflict(genTrack, genSeg, "segment", "用户删除了该片段，但重新生成时它有变化", "", "", "")
+				}
+			default:
+				if err := m.mergeSegment(baseLoc, resLoc, segmentLocation{genTrack, genSeg}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// 重新生成时去掉的片段：用户没有改动过的一并删除
+	for _, baseTrack := range m.base.Tracks {
+		for _, baseSeg := range baseTrack.Segments {
+			id := strings.ToLower(baseSeg.ID)
+			if _, ok := m.genSegs[id]; ok {
+				continue
+			}
+			resLoc, ok := m.resSegs[id]
+			if !ok {
+				continue
+			}
+			baseLoc := segmentLocation{baseTrack, baseSeg}
+			if segmentChanged(m.base, baseLoc, m.result, resLoc) {
+				m.conflict(resLoc.track, resLoc.segment, "segment", "重新生成时去掉了该片段，但用户修改过它，已保留", "", "", "")
+				continue
+			}
+			m.removeSegment(resLoc)
+			m.out.Applied = append(m.out.Applied, m.change(ChangeRemoved, baseLoc, baseLoc))
+		}
+	}
+
+	// 删除后再移动、再新增，重叠按最终的排布检查
+	m.applyMoves()
+	for _, p := range added {
+		if err := m.addSegment(p.resTrack, p.genTrack, p.segment); err != nil {
+			return err
+		}
+	}
+
+	// 草稿时长：生成结果变化而用户未修改时采用新时长，并保证覆盖所有片段
+	if m.generated.Duration != m.base.Duration && m.edited.Duration == m.base.Duration {
+		m.result.Duration = m.generated.Duration
+	}
+	for _, track := range m.result.Tracks {
+		for _, seg := range track.Segments {
+			if end := seg.TargetTimerange.End(); end > m.result.Duration {
+				m.result.Duration = end
+			}
+		}
+	}
+	return nil
+}
+
+// mergeSegment 逐组字段合并三方都存在的片段
+func (m *draftMerge) mergeSegment(baseLoc, resLoc, genLoc segmentLocation) error {
+	for _, field := range mergeFields {
+		base := field.value(m.base, baseLoc.track, baseLoc.segment)
+		edited := field.value(m.result, resLoc.track, resLoc.segment)
+		generated := field.value(m.generated, genLoc.track, genLoc.segment)
+		if generated == base || edited == generated {
+			continue
+		}
+		if edited != base {
+			m.conflict(resLoc.track, resLoc.segment, field.name, "用户和重新生成的草稿都修改了该字段，已保留用户的修改", base, edited, generated)
+			continue
+		}
+		if field.name == "target_timerange" {
+			// 相邻片段常常一起平移，等所有片段的时间都确定后再检查重叠
+			m.moves = append(m.moves, segmentMove{baseLoc, resLoc, genLoc, resLoc.segment.TargetTimerange})
+			continue
+		}
+		if err := field.apply(m.result, resLoc.segment, m.generated, genLoc.segment); err != nil {
+			return fmt.Errorf("合并片段 %s 的 %s 失败: %v", resLoc.segment.ID, field.name, err)
+		}
+		m.out.Applied = append(m.out.Applied, m.fieldChange(field.name, baseLoc, genLoc, base, generated))
+	}
+	return nil
+}
+
+// applyMoves 应用所有片段的时间变化，再按最终的排布检查重叠：与其他片段重叠的退回原时间并记录冲突，
+// 退回后可能又与别的移动片段重叠，重复检查直到没有重叠
+func (m *draftMerge) applyMoves() {
+	for _, move := range m.moves {
+		move.result.segment.TargetTimerange = move.generated.segment.TargetTimerange
+	}
+	reverted := make(map[*DraftSegment]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, move := range m.moves {
+			seg := move.result.segment
+			if reverted[seg] {
+				continue
+			}
+			other := overlapping(move.result.track, seg.TargetTimerange, seg)
+			if other == nil {
+				continue
+			}
+			seg.TargetTimerange = move.original
+			reverted[seg], changed = true, true
+			m.conflict(move.result.track, seg, "target_timerange", fmt.Sprintf("新的时间与片段 %s 重叠，已保留原时间", other.ID),
+				formatTimerange(&move.base.segment.TargetTimerange), formatTimerange(&move.original), formatTimerange(&move.generated.segment.TargetTimerange))
+		}
+	}
+
+	for _, move := range m.moves {
+		if !reverted[move.result.segment] {
+			m.out.Applied = append(m.out.Applied, m.fieldChange("target_timerange", move.base, move.generated, "", ""))
+		}
+		sortSegments(move.result.track)
+	}
+}
+
+// fieldChange 描述应用到用户草稿上的一个字段变化
+func (m *draftMerge) fieldChange(field string, baseLoc, genLoc segmentLocation, base, generated string) SegmentChange {
+	change := m.change(ChangeProperty, baseLoc, genLoc)
+	switch field {
+	case "target_timerange":
+		change.Kind = ChangeMoved
+		if baseLoc.segment.TargetTimerange.Duration != genLoc.segment.TargetTimerange.Duration {
+			change.Kind = ChangeRetimed
+		}
+	case "source_timerange":
+		change.Kind = ChangeRetimed
+	case "content":
+		change.Kind = ChangeMaterial
+		if genLoc.track.Type == "text" {
+			change.Kind = ChangeText
+		}
+		change.OldValue, change.NewValue = base, generated
+	default:
+		change.Fields = []string{field}
+	}
+	return change
+}
+
+// segmentChanged 片段的任一合并字段是否不同
+func segmentChanged(fromDraft *Draft, from segmentLocation, toDraft *Draft, to segmentLocation) bool {
+	for _, field := range mergeFields {
+		if field.value(fromDraft, from.track, from.segment) != field.value(toDraft, to.track, to.segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// addSegment 把生成结果中新增的片段及其素材加入用户草稿
+func (m *draftMerge) addSegment(resTrack, genTrack *DraftTrack, genSeg *DraftSegment) error {
+	if other := overlapping(resTrack, genSeg.TargetTimerange, nil); other != nil {
+		m.conflict(genTrack, genSeg, "segment", fmt.Sprintf("新增片段与用户的片段 %s 重叠，未加入", other.ID), "", "", formatTimerange(&genSeg.TargetTimerange))
+		return nil
+	}
+	seg, err := cloneValue(genSeg, &DraftSegment{})
+	if err != nil {
+		return err
+	}
+	if err := m.copyMaterials(genSeg); err != nil {
+		return err
+	}
+	resTrack.Segments = append(resTrack.Segments, seg)
+	sortSegments(resTrack)
+	m.resSegs[strings.ToLower(seg.ID)] = segmentLocation{resTrack, seg}
+	loc := segmentLocation{genTrack, genSeg}
+	m.out.Applied = append(m.out.Applied, m.change(ChangeAdded, loc, loc))
+	return nil
+}
+
+// addTrack 把生成结果中新增的轨道整条加入用户草稿
+func (m *draftMerge) addTrack(genTrack *DraftTrack) error {
+	track, err := cloneValue(genTrack, &DraftTrack{})
+	if err != nil {
+		return err
+	}
+	for _, seg := range genTrack.Segments {
+		if err := m.copyMaterials(seg); err != nil {
+			return err
+		}
+		loc := segmentLocation{genTrack, seg}
+		m.out.Applied = append(m.out.Applied, m.change(ChangeAdded, loc, loc))
+	}
+	for _, seg := range track.Segments {
+		m.resSegs[strings.ToLower(seg.ID)] = segmentLocation{track, seg}
+	}
+	m.result.Tracks = append(m.result.Tracks, track)
+	return nil
+}
+
+func (m *draftMerge) removeSegment(loc segmentLocation) {
+	segments := make([]*DraftSegment, 0, len(loc.track.Segments))
+	for _, seg := range loc.track.Segments {
+		if seg != loc.segment {
+			segments = append(segments, seg)
+		}
+	}
+	loc.track.Segments = segments
+	delete(m.resSegs, strings.ToLower(loc.segment.ID))
+}
+
+// copyMaterials 复制片段引用的素材（主素材和动画、转场等附加素材）到用户草稿，已有的素材不重复添加
+func (m *draftMerge) copyMaterials(seg *DraftSegment) error {
+	existing, err := materialIDs(m.result.Materials)
+	if err != nil {
+		return err
+	}
+	for _, id := range append([]string{seg.MaterialID}, seg.ExtraMaterialRefs...) {
+		if existing[strings.ToLower(id)] {
+			continue
+		}
+		if err := copyMaterial(m.result.Materials, m.generated.Materials, id); err != nil {
+			return fmt.Errorf("复制素材 %s 失败: %v", id, err)
+		}
+		existing[strings.ToLower(id)] = true
+	}
+	return nil
+}
+
+func (m *draftMerge) change(kind ChangeKind, from, to segmentLocation) SegmentChange {
+	draft := m.generated
+	if kind == ChangeRemoved {
+		draft = m.base
+	}
+	before, after := from.segment.TargetTimerange, to.segment.TargetTimerange
+	change := SegmentChange{
+		Kind:      kind,
+		TrackType: to.track.Type,
+		SegmentID: to.segment.ID,
+		Label:     draft.SegmentLabel(to.track, to.segment),
+	}
+	if kind != ChangeAdded {
+		change.Before = &before
+	}
+	if kind != ChangeRemoved {
+		change.After = &after
+	}
+	return change
+}
+
+func (m *draftMerge) conflict(track *DraftTrack, seg *DraftSegment, field, reason, base, edited, generated string) {
+	conflict := MergeConflict{
+		TrackType: track.Type,
+		Field:     field,
+		Base:      base,
+		Edited:    edited,
+		Generated: generated,
+		Reason:    reason,
+	}
+	if seg != nil {
+		conflict.SegmentID = seg.ID
+		conflict.Label = m.generated.SegmentLabel(track, seg)
+		if conflict.Label == seg.MaterialID {
+			conflict.Label = m.result.SegmentLabel(track, seg)
+		}
+	} else {
+		conflict.SegmentID = track.ID
+		conflict.Label = track.Name
+	}
+	m.out.Conflicts = append(m.out.Conflicts, conflict)
+}
+
+// overlapping 轨道上与时间范围重叠的片段，except 为正在移动的片段本身
+func overlapping(track *DraftTrack, r DraftTimerange, except *DraftSegment) *DraftSegment {
+	for _, seg := range track.Segments {
+		if seg == except {
+			continue
+		}
+		if r.Start < seg.TargetTimerange.End() && seg.TargetTimerange.Start < r.End() {
+			return seg
+		}
+	}
+	return nil
+}
+
+func sortSegments(track *DraftTrack) {
+	sort.SliceStable(track.Segments, func(i, j int) bool {
+		return track.Segments[i].TargetTimerange.Start < track.Segments[j].TargetTimerange.Start
+	})
+}
+
+// cloneDraft 深拷贝草稿，保留原排版和未建模的字段
+func cloneDraft(d *Draft) (*Draft, error) {
+	content, err := d.Dumps()
+	if err != nil {
+		return nil, err
+	}
+	clone, err := ParseDraft([]byte(content))
+	if err != nil {
+		return nil, err
+	}
+	clone.SavePath = d.SavePath
+	return clone, nil
+}
+
+func cloneValue[T any](value, into *T) (*T, error) {
+	data, err := marshalDraftValue(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, into); err != nil {
+		return nil, err
+	}
+	return into, nil
+}
+
+// untypedMaterialLists 素材中没有类型化的列表（speeds、canvases、material_animations 等）
+func untypedMaterialLists(m *DraftMaterials) []string {
+	if m.raw == nil {
+		return nil
+	}
+	typed := make(map[string]bool)
+	for _, name := range typedFieldNames(&draftMaterialsFields{}) {
+		typed[name] = true
+	}
+	var lists []string
+	for _, key := range m.raw.keys {
+		if !typed[key] {
+			lists = append(lists, key)
+		}
+	}
+	return lists
+}
+
+// rawMaterialID 读取未类型化素材的ID
+func rawMaterialID(item json.RawMessage) string {
+	var material struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(item, &material) != nil {
+		return ""
+	}
+	return material.ID
+}
+
+// materialIDs 草稿中所有素材的ID（小写）
+func materialIDs(m *DraftMaterials) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	for _, v := range m.Videos {
+		ids[strings.ToLower(v.ID)] = true
+	}
+	for _, a := range m.Audios {
+		ids[strings.ToLower(a.ID)] = true
+	}
+	for _, t := range m.Texts {
+		ids[strings.ToLower(t.ID)] = true
+	}
+	for _, list := range [][]*DraftEffectMaterial{m.Effects, m.VideoEffects, m.Transitions, m.Stickers} {
+		for _, e := range list {
+			ids[strings.ToLower(e.ID)] = true
+		}
+	}
+	for _, key := range untypedMaterialLists(m) {
+		var items []json.RawMessage
+		if err := json.Unmarshal(m.raw.values[key], &items); err != nil {
+			continue // 不是素材列表
+		}
+		for _, item := range items {
+			if id := rawMaterialID(item); id != "" {
+				ids[strings.ToLower(id)] = true
+			}
+		}
+	}
+	return ids, nil
+}
+
+// copyMaterial 按ID从 src 复制一个素材到 dst 的同名列表，找不到时忽略（生成的草稿中可能有悬空的附加素材引用）
+func copyMaterial(dst, src *DraftMaterials, id string) error {
+	for _, v := range src.Videos {
+		if strings.EqualFold(v.ID, id) {
+			clone, err := cloneValue(v, &DraftVideoMaterial{})
+			dst.Videos = append(dst.Videos, clone)
+			return err
+		}
+	}
+	for _, a := range src.Audios {
+		if strings.EqualFold(a.ID, id) {
+			clone, err := cloneValue(a, &DraftAudioMaterial{})
+			dst.Audios = append(dst.Audios, clone)
+			return err
+		}
+	}
+	for _, t := range src.Texts {
+		if strings.EqualFold(t.ID, id) {
+			clone, err := cloneValue(t, &DraftTextMaterial{})
+			dst.Texts = append(dst.Texts, clone)
+			return err
+		}
+	}
+	for _, pair := range []struct {
+		from []*DraftEffectMaterial
+		to   *[]*DraftEffectMaterial
+	}{{src.Effects, &dst.Effects}, {src.VideoEffects, &dst.VideoEffects}, {src.Transitions, &dst.Transitions}, {src.Stickers, &dst.Stickers}} {
+		for _, e := range pair.from {
+			if strings.EqualFold(e.ID, id) {
+				clone, err := cloneValue(e, &DraftEffectMaterial{})
+				*pair.to = append(*pair.to, clone)
+				return err
+			}
+		}
+	}
+
+	for _, key := range untypedMaterialLists(src) {
+		var items []json.RawMessage
+		if err := json.Unmarshal(src.raw.values[key], &items); err != nil {
+			continue
+		}
+		for _, item := range items {
+			if strings.EqualFold(rawMaterialID(item), id) {
+				return appendRawMaterial(dst, key, item)
+			}
+		}
+	}
+	return nil
+}
+
+// appendRawMaterial 向未类型化的素材列表追加一项
+func appendRawMaterial(m *DraftMaterials, key string, item json.RawMessage) error {
+	if m.raw == nil {
+		m.raw = &rawObject{values: make(map[string]json.RawMessage)}
+	}
+	var items []json.RawMessage
+	if existing, ok := m.raw.values[key]; ok {
+		if err := json.Unmarshal(existing, &items); err != nil {
+			return err
+		}
+	} else {
+		m.raw.keys = append(m.raw.keys, key)
+	}
+	items = append(items, item)
+	encoded, err := marshalDraftValue(items)
+	if err != nil {
+		return err
+	}
+	m.raw.values[key] = encoded
+	return nil
+}
@@ -0,0 +1,123 @@
+package script
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestMergeDrafts 测试三方合并：应用重新生成的变化，保留用户的修改，双方都改动的地方记录冲突
+func TestMergeDrafts(t *testing.T) {
+	base, _ := loadTestDraft(t)
+
+	// 用户在剪映中：缩短第一张图片、修改第二句字幕、新增一条贴纸轨道
+	edited, _ := loadTestDraft(t)
+	edited.Tracks[0].Segments[0].TargetTimerange.Duration = 2800000
+	edited.Materials.Texts[1].SetText("第二句\n修正")
+	sticker := &DraftTrack{}
+	if err := json.Unmarshal([]byte(`{"attribute":0,"id":"K-USER","segments":[{"id":"S-USER","material_id":"sticker_1","target_timerange":{"duration":1000000,"start":0}}],"type":"sticker"}`), sticker); err != nil {
+		t.Fatalf("解析贴纸轨道失败: %v", err)
+	}
+	edited.Tracks = append(edited.Tracks, sticker)
+
+	// 重新生成：替换第二张图片、修改第一句字幕、第一张图片时长变化、去掉特效、新增一句字幕
+	generated, _ := loadTestDraft(t)
+	generated.Materials.Videos[1].Path = "/work/chapter_01/scene_02_fixed.png"
+	generated.Materials.Texts[0].SetText("第一句新版")
+	generated.Tracks[0].Segments[0].TargetTimerange.Duration = 3200000
+	generated.Tracks[3].Segments = nil
+	newText := &DraftTextMaterial{ID: "T0000000-0000-4000-8000-000000000003", Type: "subtitle", Content: "第三句"}
+	generated.Materials.Texts = append(generated.Materials.Texts, newText)
+	if err := appendRawMaterial(generated.Materials, "speeds", json.RawMessage(`{"id":"P0000000-0000-4000-8000-000000000001","speed":1.0,"type":"speed"}`)); err != nil {
+		t.Fatalf("添加素材失败: %v", err)
+	}
+	generated.Tracks[2].Segments = append(generated.Tracks[2].Segments, &DraftSegment{
+		ID: "S0000000-0000-4000-8000-000000000008", MaterialID: newText.ID,
+		TargetTimerange:   DraftTimerange{Start: 5000000, Duration: 1000000},
+		ExtraMaterialRefs: []string{"P0000000-0000-4000-8000-000000000001"},
+	})
+
+	result, err := MergeDrafts(base, edited, generated)
+	if err != nil {
+		t.Fatalf("合并失败: %v", err)
+	}
+	merged := result.Draft
+
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Field != "target_timerange" || result.Conflicts[0].Edited == "" {
+		t.Fatalf("应只有第一张图片时长一处冲突: %+v", result.Conflicts)
+	}
+	if len(result.Applied) != 4 {
+		t.Errorf("应应用4处变化，实际 %d: %+v", len(result.Applied), result.Applied)
+	}
+	if d := merged.Tracks[0].Segments[0].TargetTimerange.Duration; d != 2800000 {
+		t.Errorf("冲突时应保留用户的时长，实际 %d", d)
+	}
+	if p := merged.VideoMaterial("V0000000-0000-4000-8000-000000000002").Path; p != "/work/chapter_01/scene_02_fixed.png" {
+		t.Errorf("替换的图片应合并进来，实际 %s", p)
+	}
+	if text := merged.Materials.Texts[0].Text(); text != "第一句新版" {
+		t.Errorf("用户未改动的字幕应更新，实际 %s", text)
+	}
+	if text := merged.Materials.Texts[1].Text(); text != "第二句\n修正" {
+		t.Errorf("用户修改的字幕应保留，实际 %s", text)
+	}
+	if len(merged.Tracks) != 6 || merged.Tracks[5].Type != "sticker" {
+		t.Errorf("用户新增的贴纸轨道应保留")
+	}
+	if len(merged.Tracks[3].Segments) != 0 {
+		t.Errorf("重新生成时去掉的特效应删除")
+	}
+	if len(merged.Tracks[2].Segments) != 3 || merged.TextMaterial(newText.ID) == nil {
+		t.Errorf("新增的字幕及其素材应加入草稿")
+	}
+	if ids, _ := materialIDs(merged.Materials); !ids["p0000000-0000-4000-8000-000000000001"] {
+		t.Errorf("新增片段引用的附加素材应一并复制")
+	}
+
+	// 合并结果可以写出并重新解析
+	content, err := merged.Dumps()
+	if err != nil {
+		t.Fatalf("导出合并结果失败: %v", err)
+	}
+	if _, err := ParseDraft([]byte(content)); err != nil {
+		t.Fatalf("合并结果无法解析: %v", err)
+	}
+}
+
+// TestMergeDraftsCascadingShift 测试用户未修改时，前一张图片变长、后一张随之后移不算重叠冲突
+func TestMergeDraftsCascadingShift(t *testing.T) {
+	base, _ := loadTestDraft(t)
+	edited, _ := loadTestDraft(t)
+	generated, _ := loadTestDraft(t)
+	first, second := generated.Tracks[0].Segments[0], generated.Tracks[0].Segments[1]
+	first.TargetTimerange.Duration += 1000000
+	second.TargetTimerange.Start += 1000000
+	generated.Duration += 1000000
+
+	result, err := MergeDrafts(base, edited, generated)
+	if err != nil {
+		t.Fatalf("合并失败: %v", err)
+	}
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("用户未修改时不应有冲突: %+v", result.Conflicts)
+	}
+	segments := result.Draft.Tracks[0].Segments
+	if segments[0].TargetTimerange != first.TargetTimerange || segments[1].TargetTimerange != second.TargetTimerange {
+		t.Errorf("两张图片都应采用新时间: %+v %+v", segments[0].TargetTimerange, segments[1].TargetTimerange)
+	}
+	if result.Draft.Duration != generated.Duration {
+		t.Errorf("草稿时长应为 %d，实际 %d", generated.Duration, result.Draft.Duration)
+	}
+
+	// 用户把第一张图片拉长到新位置，第二张图片后移后仍与它重叠，退回原时间
+	edited.Tracks[0].Segments[0].TargetTimerange.Duration = 4500000
+	result, err = MergeDrafts(base, edited, generated)
+	if err != nil {
+		t.Fatalf("合并失败: %v", err)
+	}
+	if len(result.Conflicts) != 2 {
+		t.Fatalf("第一张图片双方都修改、第二张图片重叠，应有2处冲突: %+v", result.Conflicts)
+	}
+	if start := result.Draft.Tracks[0].Segments[1].TargetTimerange.Start; start != base.Tracks[0].Segments[1].TargetTimerange.Start {
+		t.Errorf("重叠的图片应保留原时间，实际 %d", start)
+	}
+}
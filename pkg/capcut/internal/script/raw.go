@@ -0,0 +1,204 @@
+package script
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// rawObject 草稿中一个JSON对象的字段顺序和原始值。
+// 类型化的结构只覆盖常用字段，其余字段（包括剪映新版本增加的字段）原样保留并按原顺序写回
+type rawObject struct {
+	keys   []string
+	values map[string]json.RawMessage
+	typed  map[string][]byte // 解析时类型化字段的编码结果，写回时据此判断字段是否被修改
+}
+
+// parseRawObject 按出现顺序读取对象的字段，重复的字段以最后一次为准
+func parseRawObject(data []byte) (*rawObject, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("期望JSON对象，实际为 %v", token)
+	}
+
+	obj := &rawObject{values: make(map[string]json.RawMessage)}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := token.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("解析字段 %s 失败: %v", key, err)
+		}
+		if _, exists := obj.values[key]; !exists {
+			obj.keys = append(obj.keys, key)
+		}
+		obj.values[key] = value
+	}
+	return obj, nil
+}
+
+// decodeRawObject 解析对象并填充类型化字段。v 必须是不带 UnmarshalJSON 方法的结构体指针
+func decodeRawObject(data []byte, v interface{}) (*rawObject, error) {
+	obj, err := parseRawObject(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	if obj.typed, err = typedFields(v); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// encodeRawObject 按原字段顺序写回对象：未修改的字段使用原始值，保证数字格式等细节不变；
+// 修改过的字段使用新的编码；原来没有、被赋了值的类型化字段按结构体顺序追加在最后。
+// obj 为nil时（新建的对象）写出所有类型化字段
+func encodeRawObject(obj *rawObject, v interface{}) ([]byte, error) {
+	current, err := typedFields(v)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		obj = &rawObject{}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	write := func(key string, value []byte) {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	written := make(map[string]bool)
+	for _, key := range obj.keys {
+		value := []byte(obj.values[key])
+		if encoded, ok := current[key]; ok && !bytes.Equal(encoded, obj.typed[key]) {
+			value = encoded
+		}
+		write(key, value)
+		written[key] = true
+	}
+	for _, key := range typedFieldNames(v) {
+		if written[key] {
+			continue
+		}
+		if encoded := current[key]; obj.typed == nil || !bytes.Equal(encoded, obj.typed[key]) {
+			write(key, encoded)
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// typedFields 按 json 标签编码结构体的每个字段
+func typedFields(v interface{}) (map[string][]byte, error) {
+	rv := reflect.ValueOf(v).Elem()
+	fields := make(map[string][]byte)
+	for i := 0; i < rv.NumField(); i++ {
+		name := jsonFieldName(rv.Type().Field(i))
+		if name == "" {
+			continue
+		}
+		encoded, err := marshalDraftValue(rv.Field(i).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("编码字段 %s 失败: %v", name, err)
+		}
+		fields[name] = encoded
+	}
+	return fields, nil
+}
+
+// typedFieldNames 结构体中带 json 标签的字段名，按声明顺序
+func typedFieldNames(v interface{}) []string {
+	rt := reflect.TypeOf(v).Elem()
+	var names []string
+	for i := 0; i < rt.NumField(); i++ {
+		if name := jsonFieldName(rt.Field(i)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// marshalDraftValue 编码单个值，不转义 HTML 字符，与剪映的写法一致
+func marshalDraftValue(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// draftLayout 草稿文件的排版：剪映写出紧凑的单行JSON，本项目的 Dumps 使用4个空格缩进
+type draftLayout struct {
+	indent          string // 为空时为紧凑格式
+	trailingNewline bool
+}
+
+// detectLayout 从第一个字段前的空白推断缩进
+func detectLayout(data []byte) draftLayout {
+	layout := draftLayout{trailingNewline: bytes.HasSuffix(data, []byte("\n"))}
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return layout
+	}
+	rest := trimmed[1:]
+	if newline := bytes.IndexByte(rest, '\n'); newline >= 0 && len(bytes.TrimSpace(rest[:newline])) == 0 {
+		line := rest[newline+1:]
+		end := 0
+		for end < len(line) && (line[end] == ' ' || line[end] == '\t') {
+			end++
+		}
+		layout.indent = string(line[:end])
+	}
+	return layout
+}
+
+// apply 按排版重新格式化紧凑的JSON
+func (l draftLayout) apply(data []byte) ([]byte, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return nil, err
+	}
+	out := compact.Bytes()
+	if l.indent != "" {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, out, "", l.indent); err != nil {
+			return nil, err
+		}
+		out = indented.Bytes()
+	}
+	if l.trailingNewline {
+		out = append(out, '\n')
+	}
+	return out, nil
+}
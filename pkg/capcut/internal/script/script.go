@@ -79,6 +79,12 @@ func (sm *ScriptMaterial) Contains(item interface{}) bool {
 				return true
 			}
 		}
+	case *segment.Speed:
+		for _, speed := range sm.Speeds {
+			if speed.GlobalID == v.GlobalID {
+				return true
+			}
+		}
 	case *segment.AudioFade:
 		for _, fade := range sm.AudioFades {
 			if fade.FadeID == v.FadeID {
@@ -261,6 +267,8 @@ type ScriptFile struct {
 
 	ImportedMaterials map[string][]map[string]interface{} `json:"imported_materials"` // 导入的素材信息
 	ImportedTracks    []*track.Track                      `json:"imported_tracks"`    // 导入的轨道信息
+	TemplateTracks    []template.TemplateTrack             `json:"-"`                  // 模板模式下导入的轨道（保留片段），导出时代替 ImportedTracks
+	TemplateMaterials *DraftMaterials                      `json:"-"`                  // 模板模式下导入的类型化素材，导出时代替 ImportedMaterials
 }
 
 const TemplateFile = "draft_content_template.json"
@@ -328,17 +336,19 @@ func LoadTemplate(jsonPath string) (*ScriptFile, error) {
 		return nil, fmt.Errorf("JSON文件 '%s' 不存在", jsonPath)
 	}
 
-	file, err := os.Open(jsonPath)
+	data, err := os.ReadFile(jsonPath)
 	if err != nil {
 		return nil, fmt.Errorf("无法打开JSON文件: %v", err)
 	}
-	defer file.Close()
 
 	var content map[string]interface{}
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&content); err != nil {
+	if err := json.Unmarshal(data, &content); err != nil {
 		return nil, fmt.Errorf("无法解析JSON文件: %v", err)
 	}
+	draft, err := ParseDraft(data)
+	if err != nil {
+		return nil, err
+	}
 
 	sf := &ScriptFile{
 		SavePath:          &jsonPath,
@@ -347,6 +357,7 @@ func LoadTemplate(jsonPath string) (*ScriptFile, error) {
 		Tracks:            make(map[string]*track.Track),
 		ImportedMaterials: make(map[string][]map[string]interface{}),
 		ImportedTracks:    make([]*track.Track, 0),
+		TemplateMaterials: draft.Materials,
 	}
 
 	// 提取基本属性
@@ -367,40 +378,22 @@ func LoadTemplate(jsonPath string) (*ScriptFile, error) {
 		}
 	}
 
-	// 导入素材
-	if materials, ok := content["materials"].(map[string]interface{}); ok {
-		for key, value := range materials {
-			if materialList, ok := value.([]interface{}); ok {
-				materialMaps := make([]map[string]interface{}, len(materialList))
-				for i, item := range materialList {
-					if itemMap, ok := item.(map[string]interface{}); ok {
-						materialMaps[i] = itemMap
-					}
-				}
-				sf.ImportedMaterials[key] = materialMaps
-			}
-		}
-	}
-
-	// 导入轨道
+	// 导入轨道。素材以类型化的 TemplateMaterials 保存，ImportTrack 只需查找片段引用的素材
+	materialsMap, _ := content["materials"].(map[string]interface{})
 	if tracks, ok := content["tracks"].([]interface{}); ok {
 		for _, trackData := range tracks {
 			if trackMap, ok := trackData.(map[string]interface{}); ok {
-				// 转换 ImportedMaterials 为正确的类型
-				materialsMap := make(map[string]interface{})
-				for key, value := range sf.ImportedMaterials {
-					interfaceSlice := make([]interface{}, len(value))
-					for i, item := range value {
-						interfaceSlice[i] = item
-					}
-					materialsMap[key] = interfaceSlice
-				}
-
 				importedTrack, err := template.ImportTrack(trackMap, materialsMap)
 				if err != nil {
 					return nil, fmt.Errorf("导入轨道失败: %v", err)
 				}
 				sf.ImportedTracks = append(sf.ImportedTracks, importedTrack)
+
+				templateTrack, err := template.NewTemplateTrack(trackMap)
+				if err != nil {
+					return nil, fmt.Errorf("导入轨道失败: %v", err)
+				}
+				sf.TemplateTracks = append(sf.TemplateTracks, templateTrack)
 			}
 		}
 	}
@@ -420,6 +413,10 @@ func (sf *ScriptFile) AddMaterial(mat interface{}) *ScriptFile {
 		sf.Materials.Videos = append(sf.Materials.Videos, material)
 	case *material.AudioMaterial:
 		sf.Materials.Audios = append(sf.Materials.Audios, material)
+	case *segment.Transition:
+		sf.Materials.Transitions = append(sf.Materials.Transitions, material)
+	case *segment.Speed:
+		sf.Materials.Speeds = append(sf.Materials.Speeds, material)
 	default:
 		// TODO: 可以添加日志记录不支持的素材类型
 	}
@@ -712,42 +709,61 @@ func (sf *ScriptFile) Dumps() (string, error) {
 	sf.Content["last_modified_platform"] = platformInfo
 	sf.Content["platform"] = platformInfo
 
-	// 合并导入的素材
+	// 合并导入的素材。新添加的素材导出为 []map[string]interface{}，两种列表都要合并，否则模板中的素材会丢失
+	imported, err := sf.importedMaterialLists()
+	if err != nil {
+		return "", err
+	}
 	if materials, ok := sf.Content["materials"].(map[string]interface{}); ok {
-		for materialType, materialList := range sf.ImportedMaterials {
+		for materialType, materialList := range imported {
 			if existingList, exists := materials[materialType]; exists {
-				if existingSlice, ok := existingList.([]interface{}); ok {
-					for _, item := range materialList {
-						existingSlice = append(existingSlice, item)
+				var merged []interface{}
+				switch existingSlice := existingList.(type) {
+				case []interface{}:
+					merged = existingSlice
+				case []map[string]interface{}:
+					merged = make([]interface{}, 0, len(existingSlice)+len(materialList))
+					for _, item := range existingSlice {
+						merged = append(merged, item)
 					}
-					materials[materialType] = existingSlice
+				default:
+					continue
 				}
+				materials[materialType] = append(merged, materialList...)
 			} else {
-				interfaceList := make([]interface{}, len(materialList))
-				for i, item := range materialList {
-					interfaceList[i] = item
-				}
-				materials[materialType] = interfaceList
+				materials[materialType] = materialList
 			}
 		}
 	}
 
-	// 对轨道排序并导出
-	var trackList []*track.Track
+	// 对轨道排序并导出。模板模式下导入的轨道由 TemplateTracks 导出，保留其中的片段
+	type trackExport struct {
+		renderIndex int
+		data        map[string]interface{}
+	}
+	var trackList []trackExport
 	for _, t := range sf.Tracks {
-		trackList = append(trackList, t)
+		trackList = append(trackList, trackExport{t.RenderIndex, t.ExportJSON()})
+	}
+	if sf.TemplateTracks != nil {
+		for _, t := range sf.TemplateTracks {
+			trackList = append(trackList, trackExport{t.Base().RenderIndex, t.ExportJSON()})
+		}
+	} else {
+		for _, t := range sf.ImportedTracks {
+			trackList = append(trackList, trackExport{t.RenderIndex, t.ExportJSON()})
+		}
 	}
-	trackList = append(trackList, sf.ImportedTracks...)
 
-	// 按渲染层级排序
-	sort.Slice(trackList, func(i, j int) bool {
-		return trackList[i].RenderIndex < trackList[j].RenderIndex
+	// 按渲染层级排序，同一层级保持原顺序
+	sort.SliceStable(trackList, func(i, j int) bool {
+		return trackList[i].renderIndex < trackList[j].renderIndex
 	})
 
 	// 导出轨道
 	tracks := make([]map[string]interface{}, len(trackList))
 	for i, t := range trackList {
-		tracks[i] = t.ExportJSON()
+		tracks[i] = t.data
 	}
 	sf.Content["tracks"] = tracks
 
@@ -760,6 +776,36 @@ func (sf *ScriptFile) Dumps() (string, error) {
 	return string(jsonBytes), nil
 }
 
+// importedMaterialLists 导入的素材列表：模板模式下为类型化的 TemplateMaterials，否则为 ImportedMaterials
+func (sf *ScriptFile) importedMaterialLists() (map[string][]interface{}, error) {
+	lists := make(map[string][]interface{})
+	if sf.TemplateMaterials == nil {
+		for materialType, materialList := range sf.ImportedMaterials {
+			items := make([]interface{}, len(materialList))
+			for i, item := range materialList {
+				items[i] = item
+			}
+			lists[materialType] = items
+		}
+		return lists, nil
+	}
+
+	data, err := marshalDraftValue(sf.TemplateMaterials)
+	if err != nil {
+		return nil, fmt.Errorf("导出模板素材失败: %v", err)
+	}
+	var materials map[string]interface{}
+	if err := json.Unmarshal(data, &materials); err != nil {
+		return nil, fmt.Errorf("导出模板素材失败: %v", err)
+	}
+	for materialType, value := range materials {
+		if items, ok := value.([]interface{}); ok {
+			lists[materialType] = items
+		}
+	}
+	return lists, nil
+}
+
 // Dump 将草稿文件内容写入文件
 // 对应Python的dump方法
 func (sf *ScriptFile) Dump(filePath string) error {
@@ -794,56 +840,27 @@ func (sf *ScriptFile) Save() error {
 // InspectMaterial 输出草稿中导入的贴纸、文本气泡以及花字素材的元数据
 // 对应Python的inspect_material方法
 func (sf *ScriptFile) InspectMaterial() {
+	materials := sf.TemplateMaterials
+	if materials == nil {
+		materials = &DraftMaterials{}
+	}
+
 	fmt.Println("贴纸素材:")
-	if stickers, ok := sf.ImportedMaterials["stickers"]; ok {
-		for _, sticker := range stickers {
-			resourceID := ""
-			name := ""
-			if rid, ok := sticker["resource_id"].(string); ok {
-				resourceID = rid
-			}
-			if n, ok := sticker["name"].(string); ok {
-				name = n
-			}
-			fmt.Printf("\tResource id: %s '%s'\n", resourceID, name)
-		}
+	for _, sticker := range materials.Stickers {
+		fmt.Printf("\tResource id: %s '%s'\n", sticker.ResourceID, sticker.Name)
 	}
 
 	fmt.Println("文字气泡效果:")
-	if effects, ok := sf.ImportedMaterials["effects"]; ok {
-		for _, effect := range effects {
-			if effectType, ok := effect["type"].(string); ok && effectType == "text_shape" {
-				effectID := ""
-				resourceID := ""
-				name := ""
-				if eid, ok := effect["effect_id"].(string); ok {
-					effectID = eid
-				}
-				if rid, ok := effect["resource_id"].(string); ok {
-					resourceID = rid
-				}
-				if n, ok := effect["name"].(string); ok {
-					name = n
-				}
-				fmt.Printf("\tEffect id: %s ,Resource id: %s '%s'\n", effectID, resourceID, name)
-			}
+	for _, effect := range materials.Effects {
+		if effect.Type == "text_shape" {
+			fmt.Printf("\tEffect id: %s ,Resource id: %s '%s'\n", effect.EffectID, effect.ResourceID, effect.Name)
 		}
 	}
 
 	fmt.Println("花字效果:")
-	if effects, ok := sf.ImportedMaterials["effects"]; ok {
-		for _, effect := range effects {
-			if effectType, ok := effect["type"].(string); ok && effectType == "text_effect" {
-				resourceID := ""
-				name := ""
-				if rid, ok := effect["resource_id"].(string); ok {
-					resourceID = rid
-				}
-				if n, ok := effect["name"].(string); ok {
-					name = n
-				}
-				fmt.Printf("\tResource id: %s '%s'\n", resourceID, name)
-			}
+	for _, effect := range materials.Effects {
+		if effect.Type == "text_effect" {
+			fmt.Printf("\tResource id: %s '%s'\n", effect.ResourceID, effect.Name)
 		}
 	}
 }
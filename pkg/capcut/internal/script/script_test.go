@@ -694,12 +694,13 @@ func TestLoadTemplate(t *testing.T) {
 	}
 
 	// 验证导入的素材
-	if videos, exists := sf.ImportedMaterials["videos"]; exists {
-		if len(videos) != 1 {
-			t.Errorf("期望导入1个视频素材，得到%d", len(videos))
-		}
-	} else {
-		t.Error("未找到导入的视频素材")
+	if sf.TemplateMaterials == nil {
+		t.Fatal("未找到导入的素材")
+	}
+	if videos := sf.TemplateMaterials.Videos; len(videos) != 1 {
+		t.Errorf("期望导入1个视频素材，得到%d", len(videos))
+	} else if videos[0].ID != "test_video_1" || videos[0].Path != "/test/video.mp4" {
+		t.Errorf("导入的视频素材不正确: %+v", videos[0])
 	}
 
 	// 测试加载不存在的文件
@@ -717,24 +718,13 @@ func TestScriptFileInspectMaterial(t *testing.T) {
 	}
 
 	// 添加测试素材
-	sf.ImportedMaterials["stickers"] = []map[string]interface{}{
-		{
-			"resource_id": "sticker_123",
-			"name":        "测试贴纸",
-		},
-	}
-
-	sf.ImportedMaterials["effects"] = []map[string]interface{}{
-		{
-			"type":        "text_shape",
-			"effect_id":   "effect_456",
-			"resource_id": "bubble_789",
-			"name":        "文字气泡",
+	sf.TemplateMaterials = &DraftMaterials{
+		Stickers: []*DraftEffectMaterial{
+			{ResourceID: "sticker_123", Name: "测试贴纸"},
 		},
-		{
-			"type":        "text_effect",
-			"resource_id": "flower_101",
-			"name":        "花字效果",
+		Effects: []*DraftEffectMaterial{
+			{Type: "text_shape", EffectID: "effect_456", ResourceID: "bubble_789", Name: "文字气泡"},
+			{Type: "text_effect", ResourceID: "flower_101", Name: "花字效果"},
 		},
 	}
 
@@ -0,0 +1,240 @@
+package template
+
+import (
+	"fmt"
+	"sort"
+
+	"novel-video-workflow/pkg/capcut/internal/segment"
+	"novel-video-workflow/pkg/capcut/internal/types"
+)
+
+// TemplateTrack 模板模式下导入并保留片段的轨道：
+// 音视频轨道为 *ImportedMediaTrack，文本轨道为 *ImportedTextTrack，其余（特效、滤镜、贴纸等）为 *ImportedTrack
+type TemplateTrack interface {
+	Base() *ImportedTrack
+	ExportJSON() map[string]interface{}
+	EndTime() int64
+	// RetimeAround 以 at 为界调整片段：开始于 at 及之后的片段后移 delta，跨过 at 的片段延长 delta，
+	// skip 返回true的片段不调整
+	RetimeAround(at, delta int64, skip func(materialID string) bool) error
+}
+
+// NewTemplateTrack 按轨道类型导入模板轨道。音视频片段缺少素材时间范围等无法解析的轨道按原始数据保留
+func NewTemplateTrack(jsonData map[string]interface{}) (TemplateTrack, error) {
+	trackType, _ := jsonData["type"].(string)
+	switch trackType {
+	case "video", "audio":
+		if mediaTrack, err := NewImportedMediaTrack(jsonData); err == nil {
+			return mediaTrack, nil
+		}
+	case "text":
+		if textTrack, err := NewImportedTextTrack(jsonData); err == nil {
+			return textTrack, nil
+		}
+	}
+	return NewImportedTrack(jsonData)
+}
+
+// SegmentFill 替换占位片段的一个新片段
+type SegmentFill struct {
+	SegmentID  string // 为空时生成新的ID；重新生成时需要固定ID才能与修改过的草稿合并
+	MaterialID string
+	Target     *types.Timerange
+	Source     *types.Timerange // 文本片段为nil
+}
+
+// Base 返回轨道的基本信息
+func (it *ImportedTrack) Base() *ImportedTrack {
+	return it
+}
+
+// EndTime 返回轨道结束时间（微秒），按原始片段数据计算
+func (it *ImportedTrack) EndTime() int64 {
+	var end int64
+	for _, seg := range it.rawSegments() {
+		start, duration := rawTimerange(seg)
+		if start+duration > end {
+			end = start + duration
+		}
+	}
+	return end
+}
+
+// RetimeAround 调整原始片段数据中的时间范围
+func (it *ImportedTrack) RetimeAround(at, delta int64, skip func(materialID string) bool) error {
+	for _, seg := range it.rawSegments() {
+		if materialID, _ := seg["material_id"].(string); skip != nil && skip(materialID) {
+			continue
+		}
+		start, duration := rawTimerange(seg)
+		newStart, newDuration, err := retime(start, duration, at, delta)
+		if err != nil {
+			return err
+		}
+		seg["target_timerange"] = map[string]interface{}{"start": newStart, "duration": newDuration}
+	}
+	return nil
+}
+
+func (it *ImportedTrack) rawSegments() []map[string]interface{} {
+	segments, _ := it.RawData["segments"].([]interface{})
+	result := make([]map[string]interface{}, 0, len(segments))
+	for _, seg := range segments {
+		if segMap, ok := seg.(map[string]interface{}); ok {
+			result = append(result, segMap)
+		}
+	}
+	return result
+}
+
+// rawTimerange 读取原始片段数据中的 target_timerange
+func rawTimerange(seg map[string]interface{}) (int64, int64) {
+	timerange, _ := seg["target_timerange"].(map[string]interface{})
+	return rawInt(timerange["start"]), rawInt(timerange["duration"])
+}
+
+func rawInt(v interface{}) int64 {
+	switch n := v.(type) {
+	case float64:
+		return int64(n)
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	}
+	return 0
+}
+
+// retime 计算片段以 at 为界调整后的时间范围
+func retime(start, duration, at, delta int64) (int64, int64, error) {
+	switch {
+	case start >= at:
+		return start + delta, duration, nil
+	case start+duration >= at:
+		if duration+delta <= 0 {
+			return 0, 0, fmt.Errorf("片段 [%d, %d) 缩短 %d 微秒后时长不为正", start, start+duration, -delta)
+		}
+		return start, duration + delta, nil
+	}
+	return start, duration, nil
+}
+
+// RetimeAround 调整轨道上的片段
+func (et *EditableTrack) RetimeAround(at, delta int64, skip func(materialID string) bool) error {
+	for _, seg := range et.Segments {
+		if skip != nil && skip(seg.MaterialID) {
+			continue
+		}
+		start, duration, err := retime(seg.TargetTimerange.Start, seg.TargetTimerange.Duration, at, delta)
+		if err != nil {
+			return err
+		}
+		seg.TargetTimerange.Start, seg.TargetTimerange.Duration = start, duration
+	}
+	return nil
+}
+
+// ExpandSegment 用一组新片段替换占位片段。新片段复制占位片段的其余属性（位置、缩放、文字样式、动画引用等），
+// 使用各自的素材和时间范围；fills 为空时删除占位片段
+func (et *EditableTrack) ExpandSegment(segIndex int, fills []SegmentFill) error {
+	if segIndex < 0 || segIndex >= len(et.Segments) {
+		return fmt.Errorf("segment index %d out of range", segIndex)
+	}
+	placeholder := et.Segments[segIndex]
+	segments := append([]*ImportedSegment{}, et.Segments[:segIndex]...)
+	for _, fill := range fills {
+		segments = append(segments, placeholder.duplicate(fill))
+	}
+	et.Segments = append(segments, et.Segments[segIndex+1:]...)
+	sort.SliceStable(et.Segments, func(i, j int) bool {
+		return et.Segments[i].TargetTimerange.Start < et.Segments[j].TargetTimerange.Start
+	})
+	return nil
+}
+
+// duplicate 复制片段，使用新的ID、素材和时间范围
+func (is *ImportedSegment) duplicate(fill SegmentFill) *ImportedSegment {
+	rawData := make(map[string]interface{}, len(is.RawData))
+	for k, v := range is.RawData {
+		rawData[k] = v
+	}
+	base := segment.NewBaseSegment(fill.MaterialID, types.NewTimerange(fill.Target.Start, fill.Target.Duration))
+	if fill.SegmentID != "" {
+		base.SegmentID = fill.SegmentID
+	}
+	rawData["id"] = base.SegmentID
+	return &ImportedSegment{BaseSegment: base, RawData: rawData}
+}
+
+// RetimeAround 调整轨道上的片段，延长的片段同时延长素材时间范围
+func (imt *ImportedMediaTrack) RetimeAround(at, delta int64, skip func(materialID string) bool) error {
+	for _, seg := range imt.MediaSegments {
+		if skip != nil && skip(seg.MaterialID) {
+			continue
+		}
+		start, duration, err := retime(seg.TargetTimerange.Start, seg.TargetTimerange.Duration, at, delta)
+		if err != nil {
+			return err
+		}
+		if duration != seg.TargetTimerange.Duration {
+			seg.SourceTimerange = types.NewTimerange(seg.SourceTimerange.Start, seg.SourceTimerange.Duration+duration-seg.TargetTimerange.Duration)
+		}
+		seg.TargetTimerange.Start, seg.TargetTimerange.Duration = start, duration
+	}
+	return nil
+}
+
+// ExpandSegment 用一组新的音视频片段替换占位片段
+func (imt *ImportedMediaTrack) ExpandSegment(segIndex int, fills []SegmentFill) error {
+	if segIndex < 0 || segIndex >= len(imt.MediaSegments) {
+		return fmt.Errorf("segment index %d out of range", segIndex)
+	}
+	placeholder := imt.MediaSegments[segIndex]
+	segments := append([]*ImportedMediaSegment{}, imt.MediaSegments[:segIndex]...)
+	for _, fill := range fills {
+		source := fill.Source
+		if source == nil {
+			source = types.NewTimerange(0, fill.Target.Duration)
+		}
+		segments = append(segments, &ImportedMediaSegment{
+			ImportedSegment: placeholder.ImportedSegment.duplicate(fill),
+			SourceTimerange: types.NewTimerange(source.Start, source.Duration),
+		})
+	}
+	segments = append(segments, imt.MediaSegments[segIndex+1:]...)
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].TargetTimerange.Start < segments[j].TargetTimerange.Start
+	})
+
+	imt.MediaSegments = segments
+	imt.Segments = make([]*ImportedSegment, len(segments))
+	for i, seg := range segments {
+		imt.Segments[i] = seg.ImportedSegment
+	}
+	return nil
+}
+
+// ReplaceMaterial 替换片段的素材，素材时长变化时按 shrinkMode、extendModes 调整片段和后续片段
+// 对应Python的replace_material_by_seg
+func (imt *ImportedMediaTrack) ReplaceMaterial(segIndex int, materialID string, srcTimerange *types.Timerange, shrinkMode ShrinkMode, extendModes []ExtendMode) error {
+	if segIndex < 0 || segIndex >= len(imt.MediaSegments) {
+		return fmt.Errorf("segment index %d out of range", segIndex)
+	}
+	if err := imt.ProcessTimerange(segIndex, srcTimerange, shrinkMode, extendModes); err != nil {
+		return err
+	}
+	imt.MediaSegments[segIndex].MaterialID = materialID
+	return nil
+}
+
+// ExportJSON 导出为JSON格式，片段包含素材时间范围
+func (imt *ImportedMediaTrack) ExportJSON() map[string]interface{} {
+	jsonData := imt.ImportedTrack.ExportJSON()
+	segmentExports := make([]map[string]interface{}, len(imt.MediaSegments))
+	for i, seg := range imt.MediaSegments {
+		segmentExports[i] = seg.ExportJSON()
+		segmentExports[i]["render_index"] = imt.RenderIndex
+	}
+	jsonData["segments"] = segmentExports
+	return jsonData
+}
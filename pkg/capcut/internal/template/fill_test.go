@@ -0,0 +1,129 @@
+package template
+
+import (
+	"encoding/json"
+	"testing"
+
+	"novel-video-workflow/pkg/capcut/internal/types"
+)
+
+// parseTrackJSON 解析测试用的轨道JSON
+func parseTrackJSON(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var jsonData map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &jsonData); err != nil {
+		t.Fatalf("解析轨道失败: %v", err)
+	}
+	return jsonData
+}
+
+// TestNewTemplateTrack 测试按轨道类型导入模板轨道
+func TestNewTemplateTrack(t *testing.T) {
+	cases := []struct {
+		data string
+		want string
+	}{
+		{`{"type":"video","name":"","id":"v","segments":[{"id":"s","material_id":"m","target_timerange":{"start":0,"duration":1000000},"source_timerange":{"start":0,"duration":1000000}}]}`, "media"},
+		{`{"type":"text","name":"","id":"t","segments":[{"id":"s","material_id":"m","target_timerange":{"start":0,"duration":1000000}}]}`, "text"},
+		{`{"type":"effect","name":"","id":"e","segments":[{"id":"s","material_id":"m","target_timerange":{"start":0,"duration":1000000}}]}`, "raw"},
+		// 缺少素材时间范围的音视频轨道按原始数据保留
+		{`{"type":"audio","name":"","id":"a","segments":[{"id":"s","material_id":"m","target_timerange":{"start":0,"duration":1000000}}]}`, "raw"},
+	}
+	for _, c := range cases {
+		tr, err := NewTemplateTrack(parseTrackJSON(t, c.data))
+		if err != nil {
+			t.Fatalf("导入轨道失败: %v", err)
+		}
+		var got string
+		switch tr.(type) {
+		case *ImportedMediaTrack:
+			got = "media"
+		case *ImportedTextTrack:
+			got = "text"
+		case *ImportedTrack:
+			got = "raw"
+		}
+		if got != c.want {
+			t.Errorf("%s: 期望 %s 轨道，得到 %s", c.data, c.want, got)
+		}
+		if tr.EndTime() != 1000000 {
+			t.Errorf("%s: 结束时间应为1000000，得到 %d", c.data, tr.EndTime())
+		}
+	}
+}
+
+// TestRetimeAround 测试以正片结尾为界平移和伸缩片段
+func TestRetimeAround(t *testing.T) {
+	// 片头 [0,2s)、边框 [0,10s)、片尾 [8s,10s)，正片原结尾在8s
+	tr, err := NewImportedMediaTrack(parseTrackJSON(t, `{"type":"video","name":"","id":"v","segments":[
+		{"id":"intro","material_id":"intro","target_timerange":{"start":0,"duration":2000000},"source_timerange":{"start":0,"duration":2000000}},
+		{"id":"outro","material_id":"outro","target_timerange":{"start":8000000,"duration":2000000},"source_timerange":{"start":0,"duration":2000000}}]}`))
+	if err != nil {
+		t.Fatalf("导入轨道失败: %v", err)
+	}
+	frame, err := NewTemplateTrack(parseTrackJSON(t, `{"type":"filter","name":"","id":"f","segments":[
+		{"id":"frame","material_id":"frame","target_timerange":{"start":0,"duration":10000000}}]}`))
+	if err != nil {
+		t.Fatalf("导入轨道失败: %v", err)
+	}
+
+	// 正片延长5s
+	if err := tr.RetimeAround(8000000, 5000000, nil); err != nil {
+		t.Fatalf("调整失败: %v", err)
+	}
+	if err := frame.RetimeAround(8000000, 5000000, nil); err != nil {
+		t.Fatalf("调整失败: %v", err)
+	}
+	intro, outro := tr.MediaSegments[0], tr.MediaSegments[1]
+	if intro.TargetTimerange.Start != 0 || intro.TargetTimerange.Duration != 2000000 {
+		t.Errorf("片头不应变化: %+v", intro.TargetTimerange)
+	}
+	if outro.TargetTimerange.Start != 13000000 || outro.SourceTimerange.Duration != 2000000 {
+		t.Errorf("片尾应后移5s且不改变素材范围: %+v %+v", outro.TargetTimerange, outro.SourceTimerange)
+	}
+	if frame.EndTime() != 15000000 {
+		t.Errorf("跨过正片结尾的边框应延长到15s，得到 %d", frame.EndTime())
+	}
+
+	// 缩短到时长不为正时报错
+	if err := frame.RetimeAround(14000000, -20000000, nil); err == nil {
+		t.Errorf("片段时长不为正时应返回错误")
+	}
+}
+
+// TestExpandSegment 测试把占位片段展开为多个片段
+func TestExpandSegment(t *testing.T) {
+	tr, err := NewImportedMediaTrack(parseTrackJSON(t, `{"type":"video","name":"","id":"v","segments":[
+		{"id":"intro","material_id":"intro","target_timerange":{"start":0,"duration":2000000},"source_timerange":{"start":0,"duration":2000000}},
+		{"id":"placeholder","material_id":"images","clip":{"scale":{"x":0.9,"y":0.9}},"target_timerange":{"start":2000000,"duration":1000000},"source_timerange":{"start":0,"duration":1000000}}]}`))
+	if err != nil {
+		t.Fatalf("导入轨道失败: %v", err)
+	}
+	fills := []SegmentFill{
+		{MaterialID: "img2", Target: &types.Timerange{Start: 5000000, Duration: 3000000}},
+		{MaterialID: "img1", Target: &types.Timerange{Start: 2000000, Duration: 3000000}},
+	}
+	if err := tr.ExpandSegment(1, fills); err != nil {
+		t.Fatalf("展开失败: %v", err)
+	}
+	if len(tr.MediaSegments) != 3 || len(tr.Segments) != 3 {
+		t.Fatalf("应有3个片段，得到 %d", len(tr.MediaSegments))
+	}
+	exported := tr.ExportJSON()["segments"].([]map[string]interface{})
+	ids := map[interface{}]bool{}
+	for i, want := range []string{"intro", "img1", "img2"} {
+		if exported[i]["material_id"] != want {
+			t.Errorf("第%d个片段应为 %s，得到 %v", i, want, exported[i]["material_id"])
+		}
+		ids[exported[i]["id"]] = true
+	}
+	if len(ids) != 3 {
+		t.Errorf("展开的片段应有各自的ID: %v", ids)
+	}
+	if _, ok := exported[1]["clip"]; !ok {
+		t.Errorf("展开的片段应沿用占位片段的属性")
+	}
+	if src := exported[2]["source_timerange"].(map[string]interface{}); src["duration"] != int64(3000000) {
+		t.Errorf("未指定素材范围时应取片段时长，得到 %v", src)
+	}
+}
@@ -2,7 +2,10 @@
 // 对应Python的 pyJianYingDraft/exceptions.py
 package util
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // TrackNotFoundError 未找到满足条件的轨道
 // 对应Python的TrackNotFound异常
@@ -235,6 +238,72 @@ func NewConfigurationError(component, setting, reason string) *ConfigurationErro
 	return &ConfigurationError{Component: component, Setting: setting, Reason: reason}
 }
 
+// MediaFileNotFoundError 素材引用的文件不存在，剪映打开时显示"素材丢失"
+type MediaFileNotFoundError struct {
+	MaterialID string
+	Path       string
+}
+
+func (e *MediaFileNotFoundError) Error() string {
+	return fmt.Sprintf("media file not found for material %s: %s", e.MaterialID, e.Path)
+}
+
+// NewMediaFileNotFoundError 创建素材文件不存在错误
+func NewMediaFileNotFoundError(materialID, path string) *MediaFileNotFoundError {
+	return &MediaFileNotFoundError{MaterialID: materialID, Path: path}
+}
+
+// DurationMismatchError 片段时长与素材时长不符
+type DurationMismatchError struct {
+	SegmentID  string
+	MaterialID string
+	Expected   int64 // 微秒
+	Actual     int64 // 微秒
+	Reason     string
+}
+
+func (e *DurationMismatchError) Error() string {
+	return fmt.Sprintf("duration mismatch for segment %s with material %s: %s (expected %d, got %d)",
+		e.SegmentID, e.MaterialID, e.Reason, e.Expected, e.Actual)
+}
+
+// NewDurationMismatchError 创建时长不符错误
+func NewDurationMismatchError(segmentID, materialID string, expected, actual int64, reason string) *DurationMismatchError {
+	return &DurationMismatchError{
+		SegmentID:  segmentID,
+		MaterialID: materialID,
+		Expected:   expected,
+		Actual:     actual,
+		Reason:     reason,
+	}
+}
+
+// DraftLintError 草稿检查发现的问题，Problems 中为上面的各类错误
+type DraftLintError struct {
+	Problems []error
+}
+
+func (e *DraftLintError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		messages[i] = problem.Error()
+	}
+	return fmt.Sprintf("draft lint found %d problems: %s", len(e.Problems), strings.Join(messages, "; "))
+}
+
+// Unwrap 支持 errors.As 取出其中的具体错误
+func (e *DraftLintError) Unwrap() []error {
+	return e.Problems
+}
+
+// NewDraftLintError 创建草稿检查错误，没有问题时返回nil
+func NewDraftLintError(problems []error) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &DraftLintError{Problems: problems}
+}
+
 // 错误检查辅助函数
 
 // IsTrackNotFound 检查是否为轨道未找到错误
@@ -314,3 +383,21 @@ func IsConfigurationError(err error) bool {
 	_, ok := err.(*ConfigurationError)
 	return ok
 }
+
+// IsMediaFileNotFound 检查是否为素材文件不存在错误
+func IsMediaFileNotFound(err error) bool {
+	_, ok := err.(*MediaFileNotFoundError)
+	return ok
+}
+
+// IsDurationMismatch 检查是否为时长不符错误
+func IsDurationMismatch(err error) bool {
+	_, ok := err.(*DurationMismatchError)
+	return ok
+}
+
+// IsDraftLintError 检查是否为草稿检查错误
+func IsDraftLintError(err error) bool {
+	_, ok := err.(*DraftLintError)
+	return ok
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"errors"
 	"strings"
 	"testing"
 )
@@ -420,3 +421,30 @@ func TestErrorInterface(t *testing.T) {
 		}
 	}
 }
+
+// TestDraftLintError 测试草稿检查错误汇总各类问题
+func TestDraftLintError(t *testing.T) {
+	if err := NewDraftLintError(nil); err != nil {
+		t.Errorf("没有问题时应返回nil，得到 %v", err)
+	}
+
+	err := NewDraftLintError([]error{
+		NewMediaFileNotFoundError("M1", "/work/scene_01.png"),
+		NewDurationMismatchError("S1", "M2", 5000000, 6000000, "source range exceeds material"),
+	})
+	if !IsDraftLintError(err) {
+		t.Fatal("IsDraftLintError应该返回true")
+	}
+	if !strings.Contains(err.Error(), "2 problems") || !strings.Contains(err.Error(), "/work/scene_01.png") {
+		t.Errorf("错误消息应包含问题数量和各个问题，得到'%s'", err.Error())
+	}
+
+	var mismatch *DurationMismatchError
+	if !errors.As(err, &mismatch) || mismatch.SegmentID != "S1" {
+		t.Error("应能用errors.As取出时长不符错误")
+	}
+	var missing *MediaFileNotFoundError
+	if !errors.As(err, &missing) || !IsMediaFileNotFound(missing) {
+		t.Error("应能用errors.As取出素材文件不存在错误")
+	}
+}
@@ -0,0 +1,208 @@
+package capcut
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"novel-video-workflow/pkg/timeline"
+
+	"github.com/spf13/viper"
+)
+
+// 运动预设的选择方式
+const (
+	motionSelectAlternate = "alternate" // 按预设顺序轮流使用
+	motionSelectRandom    = "random"    // 按随机种子选择，相邻两张不重复
+)
+
+// 默认缩放幅度及其取值范围
+const (
+	defaultKenBurnsZoom = 1.1
+	minKenBurnsZoom     = 1.02
+	maxKenBurnsZoom     = 1.5
+)
+
+// shakeInterval 轻微晃动预设相邻两个关键帧的间隔（微秒）
+const shakeInterval int64 = 400000
+
+// motionPreset 静态图片的运动预设。
+// 平移和晃动在放大后的画面内移动，位移不超过放大多出的部分，画面边缘不会露出黑边；
+// 位置的单位是半个画布宽（高），放大到 zoom 后每侧多出 zoom-1 个单位
+type motionPreset struct {
+	Name        string
+	Description string
+	build       func(zoom float64, duration int64) []timeline.Keyframe
+}
+
+// motionPresets 内置运动预设，alternate 模式按此顺序轮流使用
+var motionPresets = []motionPreset{
+	{Name: "zoom_in", Description: "缓慢放大", build: func(zoom float64, duration int64) []timeline.Keyframe {
+		return scaleMotion(1, zoom, duration)
+	}},
+	{Name: "pan_left", Description: "画面向左平移", build: func(zoom float64, duration int64) []timeline.Keyframe {
+		return panMotion(timeline.PropertyPositionX, zoom, 1, duration)
+	}},
+	{Name: "zoom_out", Description: "缓慢缩小", build: func(zoom float64, duration int64) []timeline.Keyframe {
+		return scaleMotion(zoom, 1, duration)
+	}},
+	{Name: "pan_right", Description: "画面向右平移", build: func(zoom float64, duration int64) []timeline.Keyframe {
+		return panMotion(timeline.PropertyPositionX, zoom, -1, duration)
+	}},
+	{Name: "slow_push", Description: "以一半幅度缓慢推近", build: func(zoom float64, duration int64) []timeline.Keyframe {
+		return scaleMotion(1, 1+(zoom-1)/2, duration)
+	}},
+	{Name: "pan_up", Description: "画面向上平移", build: func(zoom float64, duration int64) []timeline.Keyframe {
+		return panMotion(timeline.PropertyPositionY, zoom, -1, duration)
+	}},
+	{Name: "pan_down", Description: "画面向下平移", build: func(zoom float64, duration int64) []timeline.Keyframe {
+		return panMotion(timeline.PropertyPositionY, zoom, 1, duration)
+	}},
+	{Name: "shake", Description: "放大后轻微晃动，适合紧张情节", build: shakeMotion},
+}
+
+// scaleMotion 从 from 缩放到 to
+func scaleMotion(from, to float64, duration int64) []timeline.Keyframe {
+	return []timeline.Keyframe{
+		{Property: timeline.PropertyScale, Offset: 0, Value: from},
+		{Property: timeline.PropertyScale, Offset: duration, Value: to},
+	}
+}
+
+// panMotion 保持放大，沿 property 从 direction 一侧移动到另一侧
+func panMotion(property string, zoom float64, direction float64, duration int64) []timeline.Keyframe {
+	offset := (zoom - 1) * 0.8 * direction
+	return []timeline.Keyframe{
+		{Property: timeline.PropertyScale, Offset: 0, Value: zoom},
+		{Property: timeline.PropertyScale, Offset: duration, Value: zoom},
+		{Property: property, Offset: 0, Value: offset},
+		{Property: property, Offset: duration, Value: -offset},
+	}
+}
+
+// shakePattern 晃动的位移方向，按顺序循环
+var shakePattern = [][2]float64{{0, 0}, {1, -0.5}, {-0.6, 1}, {0.4, -1}, {-1, 0.3}, {0.7, 0.8}}
+
+// shakeMotion 保持放大，每隔 shakeInterval 小幅改变位置
+func shakeMotion(zoom float64, duration int64) []timeline.Keyframe {
+	amplitude := (zoom - 1) * 0.3
+	keyframes := scaleMotion(zoom, zoom, duration)
+	step := 0
+	for offset := int64(0); offset < duration; offset += shakeInterval {
+		p := shakePattern[step%len(shakePattern)]
+		keyframes = append(keyframes,
+			timeline.Keyframe{Property: timeline.PropertyPositionX, Offset: offset, Value: p[0] * amplitude},
+			timeline.Keyframe{Property: timeline.PropertyPositionY, Offset: offset, Value: p[1] * amplitude})
+		step++
+	}
+	// 结尾回到中心
+	return append(keyframes,
+		timeline.Keyframe{Property: timeline.PropertyPositionX, Offset: duration, Value: 0},
+		timeline.Keyframe{Property: timeline.PropertyPositionY, Offset: duration, Value: 0})
+}
+
+// motionOptions 静态图片运动效果配置
+type motionOptions struct {
+	Enabled   bool
+	Zoom      float64
+	Presets   []string // 使用的预设名称，为空时使用全部内置预设
+	Selection string
+	Seed      int64 // random 模式的随机种子，0为每次随机
+}
+
+// motionOptionsFromConfig 读取 video.effects 中的 ken_burns 配置
+func motionOptionsFromConfig() motionOptions {
+	opts := motionOptions{
+		Enabled:   viper.GetBool("video.effects.ken_burns_enabled"),
+		Zoom:      viper.GetFloat64("video.effects.ken_burns_zoom"),
+		Presets:   viper.GetStringSlice("video.effects.ken_burns_presets"),
+		Selection: viper.GetString("video.effects.ken_burns_selection"),
+		Seed:      viper.GetInt64("video.effects.ken_burns_seed"),
+	}
+	if opts.Zoom <= 0 {
+		opts.Zoom = defaultKenBurnsZoom
+	}
+	return opts
+}
+
+// motionPlanner 为每张图片选择运动预设并生成关键帧
+type motionPlanner struct {
+	opts    motionOptions
+	presets []motionPreset
+	rng     *rand.Rand
+	last    int
+}
+
+// newMotionPlanner 按配置创建运动规划器，未启用或没有可用预设时返回nil
+func newMotionPlanner(opts motionOptions) *motionPlanner {
+	if !opts.Enabled {
+		return nil
+	}
+	if opts.Zoom < minKenBurnsZoom {
+		opts.Zoom = minKenBurnsZoom
+	} else if opts.Zoom > maxKenBurnsZoom {
+		opts.Zoom = maxKenBurnsZoom
+	}
+
+	presets := motionPresets
+	if len(opts.Presets) > 0 {
+		presets = nil
+		for _, name := range opts.Presets {
+			preset, ok := lookupMotionPreset(name)
+			if !ok {
+				fmt.Printf("⚠️  未知的运动预设: %s，已忽略\n", name)
+				continue
+			}
+			presets = append(presets, preset)
+		}
+	}
+	if len(presets) == 0 {
+		return nil
+	}
+
+	planner := &motionPlanner{opts: opts, presets: presets, last: -1}
+	if opts.Selection == motionSelectRandom {
+		seed := opts.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+			fmt.Printf("🎥 运动预设随机种子: %d\n", seed)
+		}
+		planner.rng = rand.New(rand.NewSource(seed))
+	}
+	return planner
+}
+
+// lookupMotionPreset 按名称查找内置预设
+func lookupMotionPreset(name string) (motionPreset, bool) {
+	name = strings.TrimSpace(name)
+	for _, preset := range motionPresets {
+		if preset.Name == name {
+			return preset, true
+		}
+	}
+	return motionPreset{}, false
+}
+
+// next 选择第 index 张图片使用的预设
+func (p *motionPlanner) next(index int) motionPreset {
+	choice := index % len(p.presets)
+	if p.rng != nil {
+		choice = p.rng.Intn(len(p.presets))
+		if choice == p.last && len(p.presets) > 1 {
+			// 避免相邻两张使用同一个预设
+			choice = (choice + 1 + p.rng.Intn(len(p.presets)-1)) % len(p.presets)
+		}
+	}
+	p.last = choice
+	return p.presets[choice]
+}
+
+// plan 为第 index 张图片选择运动预设并生成关键帧；规划器为nil时返回nil
+func (p *motionPlanner) plan(index int, duration int64) *timeline.Motion {
+	if p == nil || duration <= 0 {
+		return nil
+	}
+	preset := p.next(index)
+	return &timeline.Motion{Preset: preset.Name, Keyframes: preset.build(p.opts.Zoom, duration)}
+}
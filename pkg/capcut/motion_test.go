@@ -0,0 +1,76 @@
+package capcut
+
+import (
+	"math"
+	"testing"
+
+	"novel-video-workflow/pkg/timeline"
+)
+
+func TestMotionPlannerAlternatesPresets(t *testing.T) {
+	planner := newMotionPlanner(motionOptions{Enabled: true, Zoom: 1.2, Presets: []string{"zoom_in", "pan_left", "missing"}})
+
+	var names []string
+	for i := 0; i < 3; i++ {
+		names = append(names, planner.plan(i, 3000000).Preset)
+	}
+	if names[0] != "zoom_in" || names[1] != "pan_left" || names[2] != "zoom_in" {
+		t.Errorf("应按顺序轮流使用预设: %v", names)
+	}
+
+	if newMotionPlanner(motionOptions{Enabled: false}) != nil {
+		t.Errorf("未启用时不应创建规划器")
+	}
+	var disabled *motionPlanner
+	if disabled.plan(0, 1000000) != nil {
+		t.Errorf("规划器为nil时不应添加关键帧")
+	}
+}
+
+func TestMotionPlannerRandomIsReproducible(t *testing.T) {
+	pick := func() []string {
+		planner := newMotionPlanner(motionOptions{Enabled: true, Selection: motionSelectRandom, Seed: 42, Zoom: 1.1})
+		var names []string
+		for i := 0; i < 12; i++ {
+			names = append(names, planner.next(i).Name)
+		}
+		return names
+	}
+
+	first, second := pick(), pick()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("相同种子应选择相同的预设: %v / %v", first, second)
+		}
+		if i > 0 && first[i] == first[i-1] {
+			t.Errorf("相邻两张不应使用同一预设: %v", first)
+		}
+	}
+}
+
+func TestMotionPresetsStayInsideFrame(t *testing.T) {
+	const zoom, duration = 1.2, int64(2500000)
+	for _, preset := range motionPresets {
+		planner := &motionPlanner{opts: motionOptions{Zoom: zoom}, presets: []motionPreset{preset}, last: -1}
+		motion := planner.plan(0, duration)
+
+		scales := 0
+		for _, kf := range motion.Keyframes {
+			if kf.Offset < 0 || kf.Offset > duration {
+				t.Errorf("%s 的关键帧超出片段: %d", preset.Name, kf.Offset)
+			}
+			switch kf.Property {
+			case timeline.PropertyScale:
+				scales++
+			case timeline.PropertyPositionX, timeline.PropertyPositionY:
+				// 位移不能超过当前缩放多出的部分，否则会露出黑边
+				if limit := motion.ValueAt(timeline.PropertyScale, kf.Offset, 1) - 1; math.Abs(kf.Value) > limit+1e-9 {
+					t.Errorf("%s 在 %d 的位移 %.3f 超出放大范围 %.3f", preset.Name, kf.Offset, kf.Value, limit)
+				}
+			}
+		}
+		if scales < 2 {
+			t.Fatalf("%s 应包含缩放关键帧", preset.Name)
+		}
+	}
+}
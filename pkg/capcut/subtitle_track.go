@@ -0,0 +1,298 @@
+package capcut
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"novel-video-workflow/pkg/capcut/internal/script"
+	"novel-video-workflow/pkg/capcut/internal/segment"
+	"novel-video-workflow/pkg/capcut/internal/srt"
+	"novel-video-workflow/pkg/capcut/internal/track"
+	"novel-video-workflow/pkg/capcut/internal/types"
+	"novel-video-workflow/pkg/timeline"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+// subtitleFontPath 剪映内置中文字体路径
+const subtitleFontPath = "/Applications/VideoFusion-macOS.app/Contents/Resources/Font/SystemFont/zh-hans.ttf"
+
+// subtitleTrackStyle 字幕轨道样式
+type subtitleTrackStyle struct {
+	TrackName     string
+	FontSize      float64    // 剪映文本素材字号
+	Color         [3]float64 // RGB三元组，取值范围为[0, 1]
+	Bold          bool
+	TransformY    float64 // 垂直位置，负值靠下，-1为画面底边
+	RelativeIndex int     // 相对默认文本轨道的渲染层级
+}
+
+// primarySubtitleStyle 中文字幕样式：白色加粗，位于画面下方
+func primarySubtitleStyle() subtitleTrackStyle {
+	return subtitleTrackStyle{
+		TrackName:  "字幕轨道",
+		FontSize:   5.0,
+		Color:      [3]float64{1.0, 1.0, 1.0},
+		Bold:       true,
+		TransformY: -0.8,
+	}
+}
+
+// translatedSubtitleStyle 译文字幕样式，默认略小的浅黄色字幕，位于中文字幕下方
+// 可通过 subtitle.translation.font_size/color/position_y 配置
+func translatedSubtitleStyle() subtitleTrackStyle {
+	style := subtitleTrackStyle{
+		TrackName:     "译文字幕轨道",
+		FontSize:      4.0,
+		Color:         [3]float64{1.0, 0.878, 0.4},
+		Bold:          false,
+		TransformY:    -0.9,
+		RelativeIndex: 1,
+	}
+
+	if viper.IsSet("subtitle.translation.font_size") {
+		style.FontSize = viper.GetFloat64("subtitle.translation.font_size")
+	}
+	if viper.IsSet("subtitle.translation.position_y") {
+		style.TransformY = viper.GetFloat64("subtitle.translation.position_y")
+	}
+	if color, err := parseHexColor(viper.GetString("subtitle.translation.color")); err == nil {
+		style.Color = color
+	}
+
+	return style
+}
+
+// isTranslatedSubtitle 判断是否为译文字幕文件，译文字幕以语言代码作为第二扩展名，如 chapter_01.en.srt
+func isTranslatedSubtitle(filename string) bool {
+	lang := strings.TrimPrefix(filepath.Ext(strings.TrimSuffix(filename, filepath.Ext(filename))), ".")
+	if len(lang) < 2 || len(lang) > 5 {
+		return false
+	}
+	for _, r := range lang {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseHexColor 解析 #RRGGBB 格式颜色
+func parseHexColor(hex string) ([3]float64, error) {
+	hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(hex) != 6 {
+		return [3]float64{}, fmt.Errorf("无效的颜色值: %s", hex)
+	}
+	var color [3]float64
+	for i := 0; i < 3; i++ {
+		v, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return [3]float64{}, fmt.Errorf("无效的颜色值: %s", hex)
+		}
+		color[i] = float64(v) / 255.0
+	}
+	return color, nil
+}
+
+// hexColor 将RGB三元组转换为 #RRGGBB
+func hexColor(color [3]float64) string {
+	return fmt.Sprintf("#%02X%02X%02X", int(color[0]*255+0.5), int(color[1]*255+0.5), int(color[2]*255+0.5))
+}
+
+// capcutFontScale 剪映字号与字号占画布高度比例之间的换算系数，字号5.0约为画布高度的2.5%
+const capcutFontScale = 200.0
+
+// timelineStyle 转换为时间线中的字幕样式
+func (s subtitleTrackStyle) timelineStyle() *timeline.TextStyle {
+	return &timeline.TextStyle{
+		Size:      s.FontSize / capcutFontScale,
+		Color:     hexColor(s.Color),
+		Bold:      s.Bold,
+		PositionY: s.TransformY,
+	}
+}
+
+// subtitleStyleForTrack 按时间线文本轨道的用途和样式生成剪映字幕样式，未设置的部分使用默认样式
+func subtitleStyleForTrack(tr *timeline.Track) subtitleTrackStyle {
+	style := primarySubtitleStyle()
+	if tr.Role == timeline.RoleTranslation {
+		style = translatedSubtitleStyle()
+	}
+	if tr.Name != "" {
+		style.TrackName = tr.Name
+	}
+	if tr.Style != nil {
+		if tr.Style.Size > 0 {
+			style.FontSize = tr.Style.Size * capcutFontScale
+		}
+		if color, err := parseHexColor(tr.Style.Color); err == nil {
+			style.Color = color
+		}
+		style.Bold = tr.Style.Bold
+		style.TransformY = tr.Style.PositionY
+	}
+	return style
+}
+
+// subtitleCues 解析SRT字幕文件，字幕时间按音频时长等比缩放
+func subtitleCues(srtFile string, audioDuration int64) ([]timeline.TextCue, error) {
+	srtEntries, err := srt.ParseSrtFile(srtFile)
+	if err != nil {
+		return nil, fmt.Errorf("解析字幕文件失败: %v", err)
+	}
+
+	// 重新计算字幕时间戳，使其与音频总时长相匹配
+	srtEntries = scaleSrtEntries(srtEntries, audioDuration)
+
+	cues := make([]timeline.TextCue, 0, len(srtEntries))
+	for _, entry := range srtEntries {
+		cues = append(cues, timeline.TextCue{
+			Target: timeline.Range{Start: entry.Start, Duration: entry.End - entry.Start},
+			Text:   entry.Text,
+		})
+	}
+	return cues, nil
+}
+
+// addSubtitleTrack 按给定样式将字幕添加为一条文本轨道
+func addSubtitleTrack(sf *script.ScriptFile, cues []timeline.TextCue, style subtitleTrackStyle) error {
+	// 添加文本轨道和字幕
+	textTrackName := stringPtr(style.TrackName)
+	sf.AddTrack(track.TrackTypeText, textTrackName, script.WithRelativeIndex(style.RelativeIndex))
+
+	// 获取文本轨道并添加字幕片段
+	textTrack, err := sf.GetTrack("text", textTrackName)
+	if err != nil {
+		return fmt.Errorf("获取文本轨道失败: %v", err)
+	}
+	textTrack.TrackID = stableDraftID("track", style.TrackName)
+
+	for i, entry := range cues {
+		cueKey := fmt.Sprintf("cue_%04d", i+1) // 字幕按序号对应，重新生成时ID不变
+		// 创建文本样式
+		textStyle := segment.NewTextStyle()
+		textStyle.Size = style.FontSize * 4.8
+		textStyle.Color = style.Color
+		textStyle.Bold = style.Bold
+		textStyle.Align = 1 // 居中对齐
+
+		// 创建ClipSettings来设置字幕位置
+		clipSettings := segment.NewClipSettingsWithParams(
+			1.0,              // alpha
+			0.0,              // rotation
+			1.0,              // scaleX
+			1.0,              // scaleY
+			0.0,              // transformX
+			style.TransformY, // transformY - 负值使字幕靠下显示
+			false,            // flipH
+			false,            // flipV
+		)
+
+		// 创建文本素材并添加到素材库
+		textMaterial := map[string]interface{}{
+			"add_type":                     2,
+			"alignment":                    1,
+			"background_alpha":             1.0,
+			"background_color":             "",
+			"background_height":            1.0,
+			"background_horizontal_offset": 0.0,
+			"background_round_radius":      0.0,
+			"background_vertical_offset":   0.0,
+			"background_width":             1.0,
+			"bold_width":                   0.0,
+			"border_color":                 "",
+			"border_width":                 0.08,
+			"check_flag":                   7,
+			"content": fmt.Sprintf("<font id=\"%s\" path=\"%s\"><color=(%f, %f, %f, 1.000000)><size=%f>%s</size></color></font>",
+				uuid.New().String(), subtitleFontPath, style.Color[0], style.Color[1], style.Color[2], style.FontSize,
+				strings.ReplaceAll(entry.Text, "\n", "\u0001")),
+			"font_category_id":         "",
+			"font_category_name":       "",
+			"font_id":                  "",
+			"font_name":                "",
+			"font_path":                subtitleFontPath,
+			"font_resource_id":         "",
+			"font_size":                style.FontSize,
+			"font_title":               "none",
+			"font_url":                 "",
+			"fonts":                    []interface{}{},
+			"global_alpha":             1.0,
+			"has_shadow":               false,
+			"id":                       stableDraftID("text", style.TrackName, cueKey),
+			"initial_scale":            1.0,
+			"is_rich_text":             false,
+			"italic_degree":            0,
+			"ktv_color":                "",
+			"layer_weight":             1,
+			"letter_spacing":           0.0,
+			"line_spacing":             0.02,
+			"recognize_type":           0,
+			"shadow_alpha":             0.8,
+			"shadow_angle":             -45.0,
+			"shadow_color":             "",
+			"shadow_distance":          8.0,
+			"shadow_point":             map[string]interface{}{"x": 1.0182337649086284, "y": -1.0182337649086284},
+			"shadow_smoothing":         1.0,
+			"shape_clip_x":             false,
+			"shape_clip_y":             false,
+			"style_name":               "",
+			"sub_type":                 0,
+			"text_alpha":               1.0,
+			"text_color":               hexColor(style.Color),
+			"text_size":                int(style.FontSize * 6),
+			"text_to_audio_ids":        []interface{}{},
+			"type":                     "subtitle",
+			"typesetting":              0,
+			"underline":                false,
+			"underline_offset":         0.22,
+			"underline_width":          0.05,
+			"use_effect_default_color": true,
+		}
+		// 将文本素材添加到素材库
+		sf.Materials.Texts = append(sf.Materials.Texts, textMaterial)
+
+		// 创建文本片段，使用刚添加的文本素材ID
+		textSegment := segment.NewTextSegment(
+			entry.Text, // text
+			types.NewTimerange(entry.Target.Start, entry.Target.Duration), // targetTimerange - 调整后的时间
+			"",           // font (空字符串使用默认字体)
+			textStyle,    // style
+			clipSettings, // clipSettings - 添加位置设置
+		)
+		// 设置正确的MaterialID（使用刚添加的文本素材ID）
+		textSegment.MaterialID = textMaterial["id"].(string)
+		textSegment.SegmentID = stableDraftID("segment", style.TrackName, cueKey)
+		sf.AddMaterial(textSegment.Speed) // extra_material_refs 引用的变速素材
+
+		textTrack.AddSegment(textSegment)
+	}
+
+	return nil
+}
+
+// scaleSrtEntries 按音频时长与字幕总时长的比例缩放字幕时间，最后一条字幕精确结束于音频末尾；
+// 无法计算比例时保持原始时间
+func scaleSrtEntries(entries []srt.SrtEntry, audioDuration int64) []srt.SrtEntry {
+	if len(entries) == 0 {
+		return entries
+	}
+	originalSubtitleDuration := entries[len(entries)-1].End
+	if originalSubtitleDuration <= 0 || audioDuration <= 0 {
+		return entries
+	}
+
+	ratio := float64(audioDuration) / float64(originalSubtitleDuration)
+	scaled := make([]srt.SrtEntry, len(entries))
+	for i, entry := range entries {
+		scaled[i] = entry
+		scaled[i].Start = int64(float64(entry.Start) * ratio)
+		scaled[i].End = int64(float64(entry.End) * ratio)
+		if entry.End == originalSubtitleDuration {
+			scaled[i].End = audioDuration
+		}
+	}
+	return scaled
+}
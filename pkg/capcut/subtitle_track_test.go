@@ -0,0 +1,96 @@
+package capcut
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"novel-video-workflow/pkg/capcut/internal/script"
+	"novel-video-workflow/pkg/capcut/internal/segment"
+	"novel-video-workflow/pkg/timeline"
+)
+
+func TestIsTranslatedSubtitle(t *testing.T) {
+	cases := map[string]bool{
+		"chapter_01.srt":       false,
+		"chapter_01.en.srt":    true,
+		"chapter_01.zh-tw.srt": true,
+		"chapter_01.v2_1.srt":  false,
+		"subtitle.srt":         false,
+	}
+	for name, want := range cases {
+		if got := isTranslatedSubtitle(name); got != want {
+			t.Errorf("isTranslatedSubtitle(%s) = %v, 期望 %v", name, got, want)
+		}
+	}
+}
+
+// TestAddBilingualSubtitleTracks 测试中文字幕与译文字幕生成两条位置不同的文本轨道
+func TestAddBilingualSubtitleTracks(t *testing.T) {
+	dir := t.TempDir()
+	zhSrt := filepath.Join(dir, "chapter_01.srt")
+	enSrt := filepath.Join(dir, "chapter_01.en.srt")
+	if err := os.WriteFile(zhSrt, []byte("1\n00:00:00,000 --> 00:00:02,000\n第一句\n\n2\n00:00:02,000 --> 00:00:04,000\n第二句\n"), 0644); err != nil {
+		t.Fatalf("写入字幕失败: %v", err)
+	}
+	if err := os.WriteFile(enSrt, []byte("1\n00:00:00,000 --> 00:00:02,000\nFirst line\n\n2\n00:00:02,000 --> 00:00:04,000\nSecond line\n"), 0644); err != nil {
+		t.Fatalf("写入译文字幕失败: %v", err)
+	}
+
+	sf, err := script.NewScriptFile(1080, 1920, 30)
+	if err != nil {
+		t.Fatalf("创建草稿文件失败: %v", err)
+	}
+
+	primary := primarySubtitleStyle()
+	translated := translatedSubtitleStyle()
+	for _, sub := range []struct {
+		file  string
+		style subtitleTrackStyle
+	}{{zhSrt, primary}, {enSrt, translated}} {
+		cues, err := subtitleCues(sub.file, 8000000)
+		if err != nil {
+			t.Fatalf("解析字幕失败: %v", err)
+		}
+		if err := addSubtitleTrack(sf, cues, sub.style); err != nil {
+			t.Fatalf("添加字幕失败: %v", err)
+		}
+	}
+
+	zhTrack := sf.Tracks[primary.TrackName]
+	enTrack := sf.Tracks[translated.TrackName]
+	if zhTrack == nil || enTrack == nil {
+		t.Fatalf("缺少字幕轨道: %v", sf.Tracks)
+	}
+	if len(zhTrack.Segments) != 2 || len(enTrack.Segments) != 2 {
+		t.Fatalf("字幕片段数量错误: %d, %d", len(zhTrack.Segments), len(enTrack.Segments))
+	}
+	if enTrack.RenderIndex == zhTrack.RenderIndex {
+		t.Errorf("两条字幕轨道的渲染层级不应相同")
+	}
+
+	enSeg := enTrack.Segments[1].(*segment.TextSegment)
+	if enSeg.ClipSettings.TransformY >= zhTrack.Segments[1].(*segment.TextSegment).ClipSettings.TransformY {
+		t.Errorf("译文字幕应位于中文字幕下方")
+	}
+	// 字幕时间按音频时长等比缩放，最后一条结束于音频末尾
+	if enSeg.Start() != 4000000 || enSeg.Start()+enSeg.Duration() != 8000000 {
+		t.Errorf("译文字幕时间错误: start=%d duration=%d", enSeg.Start(), enSeg.Duration())
+	}
+	if len(sf.Materials.Texts) != 4 {
+		t.Errorf("文本素材数量错误: %d", len(sf.Materials.Texts))
+	}
+}
+
+func TestSubtitleStyleRoundTripsThroughTimeline(t *testing.T) {
+	translated := translatedSubtitleStyle()
+	tr := &timeline.Track{Kind: timeline.TrackText, Name: translated.TrackName, Role: timeline.RoleTranslation, Style: translated.timelineStyle()}
+	got := subtitleStyleForTrack(tr)
+	if math.Abs(got.FontSize-translated.FontSize) > 1e-9 || got.TransformY != translated.TransformY || got.RelativeIndex != translated.RelativeIndex {
+		t.Errorf("经时间线转换后的样式不一致: %+v / %+v", got, translated)
+	}
+	if hexColor(got.Color) != hexColor(translated.Color) {
+		t.Errorf("字幕颜色不一致: %s / %s", hexColor(got.Color), hexColor(translated.Color))
+	}
+}
@@ -0,0 +1,456 @@
+package capcut
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"novel-video-workflow/pkg/capcut/internal/draft"
+	"novel-video-workflow/pkg/capcut/internal/script"
+	"novel-video-workflow/pkg/capcut/internal/template"
+	"novel-video-workflow/pkg/capcut/internal/types"
+	"novel-video-workflow/pkg/timeline"
+
+	"github.com/spf13/viper"
+)
+
+// 模板中的占位素材。音视频素材按去掉扩展名后的素材名匹配（如 {{narration}}.mp3），文本素材按文字匹配
+const (
+	placeholderImages      = "{{images}}"      // 分镜图片，按时间线展开为多个片段
+	placeholderNarration   = "{{narration}}"   // 旁白，替换后模板轨道随旁白时长伸缩
+	placeholderSubtitle    = "{{subtitle}}"    // 原文字幕，每条字幕一个片段，沿用占位文字的样式
+	placeholderTranslation = "{{translation}}" // 译文字幕
+	placeholderTitle       = "{{title}}"       // 章节标题，原位替换文字
+)
+
+// templateFillOptions 替换旁白占位素材时片段时长的调整方式（video.capcut_template.*）
+type templateFillOptions struct {
+	ShrinkMode  template.ShrinkMode
+	ExtendModes []template.ExtendMode
+}
+
+// templateFillOptionsFromConfig 读取模板填充配置，默认旁白变短时裁剪尾部并前移后续片段，变长时后移后续片段
+func templateFillOptionsFromConfig() templateFillOptions {
+	opts := templateFillOptions{
+		ShrinkMode:  template.ShrinkModeCutTailAlign,
+		ExtendModes: []template.ExtendMode{template.ExtendModePushTail},
+	}
+	if mode := viper.GetString("video.capcut_template.shrink_mode"); mode != "" {
+		opts.ShrinkMode = template.ShrinkMode(mode)
+	}
+	if modes := viper.GetStringSlice("video.capcut_template.extend_modes"); len(modes) > 0 {
+		opts.ExtendModes = opts.ExtendModes[:0]
+		for _, mode := range modes {
+			opts.ExtendModes = append(opts.ExtendModes, template.ExtendMode(mode))
+		}
+	}
+	return opts
+}
+
+// GenerateProjectFromTemplate 复制剪映草稿目录中设计好的模板草稿，用章节的图片、旁白和字幕替换其中的占位素材，
+// 生成名为「小说名_chapter_XX」的新草稿。同名草稿已存在时与剪映中修改过的草稿三方合并，保留用户的手动修改
+func (cg *CapcutGenerator) GenerateProjectFromTemplate(inputDir, templateName string) error {
+	inputDir, err := filepath.Abs(inputDir)
+	if err != nil {
+		return fmt.Errorf("获取输入目录绝对路径失败: %v", err)
+	}
+	inputDir = cleanPath(inputDir)
+
+	tl, err := BuildChapterTimeline(inputDir)
+	if err != nil {
+		return err
+	}
+
+	folder := viper.GetString("video.capcut_template.folder")
+	if folder == "" {
+		if folder, err = findJianyingDraftFolder(); err != nil {
+			return fmt.Errorf("查找剪映草稿文件夹失败: %v", err)
+		}
+	}
+	draftFolder, err := draft.NewDraftFolder(folder)
+	if err != nil {
+		return err
+	}
+
+	draftName := filepath.Base(filepath.Dir(inputDir)) + "_" + tl.Name
+	draftPath := draftFolder.GetDraftPath(draftName)
+	if draftFolder.DraftExists(draftName) {
+		err = regenerateFromTemplate(draftFolder, templateName, draftPath, inputDir, tl)
+	} else {
+		err = createFromTemplate(draftFolder, templateName, draftName, tl)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("已根据模板 %s 生成剪映项目: %s\n", templateName, draftPath)
+	fmt.Println("请在剪映中打开该项目进行最终调整和导出")
+	return nil
+}
+
+// createFromTemplate 复制模板草稿并填充章节素材，同时保存一份生成的草稿作为下次重新生成时三方合并的基准
+func createFromTemplate(draftFolder *draft.DraftFolder, templateName, draftName string, tl *timeline.Timeline) error {
+	sf, err := draftFolder.DuplicateAsTemplate(templateName, draftName, false)
+	if err != nil {
+		return fmt.Errorf("复制模板草稿失败: %v", err)
+	}
+	if err := fillTemplate(sf, tl, templateFillOptionsFromConfig()); err != nil {
+		return fmt.Errorf("填充模板 %s 失败: %v", templateName, err)
+	}
+	if err := lintGeneratedDraft(sf); err != nil {
+		return err
+	}
+	generated, err := sf.Dumps()
+	if err != nil {
+		return fmt.Errorf("导出草稿失败: %v", err)
+	}
+	if err := sf.Save(); err != nil {
+		return fmt.Errorf("保存草稿文件失败: %v", err)
+	}
+
+	draftPath := draftFolder.GetDraftPath(draftName)
+	if err := os.WriteFile(filepath.Join(draftPath, generatedDraftName), []byte(generated), 0644); err != nil {
+		return fmt.Errorf("保存草稿文件失败: %v", err)
+	}
+	if err := renameTemplateMeta(draftPath, draftName); err != nil {
+		return fmt.Errorf("更新草稿信息失败: %v", err)
+	}
+	return nil
+}
+
+// regenerateFromTemplate 在模板上重新填充章节素材，与已有草稿三方合并。
+// 已有草稿没有上次生成的记录时无法区分用户的修改，不覆盖并报错
+func regenerateFromTemplate(draftFolder *draft.DraftFolder, templateName, draftPath, inputDir string, tl *timeline.Timeline) error {
+	if _, err := os.Stat(filepath.Join(draftPath, generatedDraftName)); err != nil {
+		return fmt.Errorf("草稿 %s 已存在，但没有上次生成的记录 %s，为避免覆盖剪映中的修改未重新生成；如需重新生成请先删除该草稿",
+			draftPath, generatedDraftName)
+	}
+	edited, err := os.ReadFile(filepath.Join(draftPath, "draft_info.json"))
+	if err != nil {
+		return fmt.Errorf("读取草稿失败: %v", err)
+	}
+
+	// 直接在模板上填充，只导出内容，不写回模板
+	sf, err := draftFolder.LoadTemplate(templateName)
+	if err != nil {
+		return fmt.Errorf("加载模板草稿失败: %v", err)
+	}
+	if err := fillTemplate(sf, tl, templateFillOptionsFromConfig()); err != nil {
+		return fmt.Errorf("填充模板 %s 失败: %v", templateName, err)
+	}
+	if err := lintGeneratedDraft(sf); err != nil {
+		return err
+	}
+	generated, err := sf.Dumps()
+	if err != nil {
+		return fmt.Errorf("导出草稿失败: %v", err)
+	}
+	return mergeIntoProject(draftPath, inputDir, edited, []byte(generated))
+}
+
+// renameTemplateMeta 把复制出的 draft_meta_info.json 中的名称、路径和ID改为新草稿的，
+// 否则剪映会把新草稿当作模板草稿
+func renameTemplateMeta(draftPath, draftName string) error {
+	metaPath := filepath.Join(draftPath, "draft_meta_info.json")
+	content, err := os.ReadFile(metaPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var meta map[string]interface{}
+	if err := json.Unmarshal(content, &meta); err != nil {
+		return err
+	}
+	meta["draft_name"] = draftName
+	meta["draft_fold_path"] = draftPath
+	meta["draft_id"] = strings.ToUpper(chapterProjectID(draftPath))
+	updated, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath, updated, 0644)
+}
+
+// fillTemplate 用时间线替换模板草稿中的占位素材：
+// 旁白占位片段按 opts 替换为章节旁白，其余轨道上位于旁白之后的片段随之平移，跨过旁白结尾的片段（边框、背景音乐等）随之伸缩；
+// 图片和字幕占位片段按时间线展开，沿用占位片段的位置、缩放、动画和文字样式
+func fillTemplate(sf *script.ScriptFile, tl *timeline.Timeline, opts templateFillOptions) error {
+	placeholders := templatePlaceholders(sf)
+	narrationClips := roleClips(tl, timeline.RoleNarration)
+	if len(narrationClips) == 0 {
+		return fmt.Errorf("时间线中没有旁白")
+	}
+
+	// 替换旁白，得到正片在模板中的起止时间
+	narrationTrack, narrationIndex := findPlaceholderSegment(sf, placeholders, placeholderNarration)
+	if narrationTrack == nil {
+		return fmt.Errorf("模板中没有旁白占位素材 %s", placeholderNarration)
+	}
+	placeholder := narrationTrack.MediaSegments[narrationIndex].TargetTimerange
+	oldEnd := placeholder.Start + placeholder.Duration
+
+	narration := narrationClips[0]
+	audioMaterial, err := clipAudioMaterial(narration)
+	if err != nil {
+		return fmt.Errorf("创建音频素材失败: %v", err)
+	}
+	sf.AddMaterial(audioMaterial)
+	source := types.NewTimerange(narration.Source.Start, narration.Source.Duration)
+	if err := narrationTrack.ReplaceMaterial(narrationIndex, audioMaterial.MaterialID, source, opts.ShrinkMode, opts.ExtendModes); err != nil {
+		return fmt.Errorf("替换旁白失败: %v", err)
+	}
+	body := narrationTrack.MediaSegments[narrationIndex].TargetTimerange
+	bodyStart, delta := body.Start, body.Start+body.Duration-oldEnd
+
+	// 其余轨道随旁白时长伸缩。图片和字幕占位片段随后按时间线展开，不参与伸缩
+	expanded := func(materialID string) bool {
+		switch placeholders[materialID] {
+		case placeholderImages, placeholderSubtitle, placeholderTranslation:
+			return true
+		}
+		return false
+	}
+	for _, tr := range sf.TemplateTracks {
+		if tr == template.TemplateTrack(narrationTrack) {
+			continue
+		}
+		if err := tr.RetimeAround(oldEnd, delta, expanded); err != nil {
+			return fmt.Errorf("调整轨道 %s 失败: %v", tr.Base().Name, err)
+		}
+	}
+
+	if err := expandImagePlaceholders(sf, placeholders, roleClips(tl, timeline.RoleImages), bodyStart); err != nil {
+		return err
+	}
+	for role, name := range map[string]string{timeline.RoleSubtitle: placeholderSubtitle, timeline.RoleTranslation: placeholderTranslation} {
+		var cues []timeline.TextCue
+		if tr := tl.TrackByRole(role); tr != nil {
+			cues = tr.Cues
+		}
+		if err := expandTextPlaceholders(sf, placeholders, name, cues, bodyStart); err != nil {
+			return err
+		}
+	}
+	if err := replaceTitlePlaceholders(sf, placeholders, chapterTitle(tl)); err != nil {
+		return err
+	}
+	removeReplacedPlaceholders(sf, placeholders)
+
+	var duration int64
+	for _, tr := range sf.TemplateTracks {
+		if end := tr.EndTime(); end > duration {
+			duration = end
+		}
+	}
+	sf.Duration = duration
+	return nil
+}
+
+// templatePlaceholders 返回模板中占位素材的ID到占位名的映射
+func templatePlaceholders(sf *script.ScriptFile) map[string]string {
+	placeholders := make(map[string]string)
+	materials := sf.TemplateMaterials
+	if materials == nil {
+		return placeholders
+	}
+	mediaPlaceholder := func(id, name string) {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+		if name == placeholderImages || name == placeholderNarration {
+			placeholders[id] = name
+		}
+	}
+	for _, mat := range materials.Videos {
+		mediaPlaceholder(mat.ID, mat.MaterialName)
+	}
+	for _, mat := range materials.Audios {
+		mediaPlaceholder(mat.ID, mat.Name)
+	}
+	for _, mat := range materials.Texts {
+		switch text := strings.TrimSpace(mat.Text()); text {
+		case placeholderSubtitle, placeholderTranslation, placeholderTitle:
+			placeholders[mat.ID] = text
+		}
+	}
+	return placeholders
+}
+
+// findPlaceholderSegment 查找第一个使用指定占位素材的音视频片段
+func findPlaceholderSegment(sf *script.ScriptFile, placeholders map[string]string, name string) (*template.ImportedMediaTrack, int) {
+	for _, tr := range sf.TemplateTracks {
+		mediaTrack, ok := tr.(*template.ImportedMediaTrack)
+		if !ok {
+			continue
+		}
+		for i, seg := range mediaTrack.MediaSegments {
+			if placeholders[seg.MaterialID] == name {
+				return mediaTrack, i
+			}
+		}
+	}
+	return nil, -1
+}
+
+// placeholderIndexes 返回轨道上使用指定占位素材的片段下标，从后往前排列，展开时不影响前面的下标
+func placeholderIndexes(segmentMaterials []string, placeholders map[string]string, name string) []int {
+	var indexes []int
+	for i, materialID := range segmentMaterials {
+		if placeholders[materialID] == name {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+	return indexes
+}
+
+// expandImagePlaceholders 把图片占位片段展开为时间线上的分镜图片
+func expandImagePlaceholders(sf *script.ScriptFile, placeholders map[string]string, clips []*timeline.Clip, bodyStart int64) error {
+	for _, tr := range sf.TemplateTracks {
+		mediaTrack, ok := tr.(*template.ImportedMediaTrack)
+		if !ok {
+			continue
+		}
+		materials := make([]string, len(mediaTrack.MediaSegments))
+		for i, seg := range mediaTrack.MediaSegments {
+			materials[i] = seg.MaterialID
+		}
+		for _, index := range placeholderIndexes(materials, placeholders, placeholderImages) {
+			placeholderID, _ := mediaTrack.MediaSegments[index].RawData["id"].(string)
+			var fills []template.SegmentFill
+			for _, clip := range clips {
+				videoMaterial, err := clipVideoMaterial(clip)
+				if err != nil {
+					return fmt.Errorf("创建视频素材失败: %v", err)
+				}
+				sf.AddMaterial(videoMaterial)
+				fills = append(fills, template.SegmentFill{
+					SegmentID:  stableDraftID("segment", placeholderID, clip.ID),
+					MaterialID: videoMaterial.MaterialID,
+					Target:     types.NewTimerange(bodyStart+clip.Target.Start, clip.Target.Duration),
+					Source:     types.NewTimerange(clip.Source.Start, clip.Source.Duration),
+				})
+			}
+			if err := mediaTrack.ExpandSegment(index, fills); err != nil {
+				return fmt.Errorf("展开图片占位片段失败: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// expandTextPlaceholders 把字幕占位片段展开为每条字幕一个片段，每条字幕复制一份占位文本素材
+func expandTextPlaceholders(sf *script.ScriptFile, placeholders map[string]string, name string, cues []timeline.TextCue, bodyStart int64) error {
+	for _, tr := range sf.TemplateTracks {
+		textTrack, ok := tr.(*template.ImportedTextTrack)
+		if !ok {
+			continue
+		}
+		materials := make([]string, len(textTrack.Segments))
+		for i, seg := range textTrack.Segments {
+			materials[i] = seg.MaterialID
+		}
+		for _, index := range placeholderIndexes(materials, placeholders, name) {
+			source := templateTextMaterial(sf, textTrack.Segments[index].MaterialID)
+			if source == nil {
+				return fmt.Errorf("找不到字幕占位素材 %s", textTrack.Segments[index].MaterialID)
+			}
+			placeholderID, _ := textTrack.Segments[index].RawData["id"].(string)
+			var fills []template.SegmentFill
+			for i, cue := range cues {
+				mat, err := source.Clone()
+				if err != nil {
+					return fmt.Errorf("复制字幕素材失败: %v", err)
+				}
+				if err := mat.SetText(cue.Text); err != nil {
+					return fmt.Errorf("设置字幕文字失败: %v", err)
+				}
+				mat.ID = stableDraftID("text", placeholderID, fmt.Sprintf("cue_%04d", i+1))
+				sf.TemplateMaterials.Texts = append(sf.TemplateMaterials.Texts, mat)
+				fills = append(fills, template.SegmentFill{
+					SegmentID:  stableDraftID("segment", placeholderID, fmt.Sprintf("cue_%04d", i+1)),
+					MaterialID: mat.ID,
+					Target:     types.NewTimerange(bodyStart+cue.Target.Start, cue.Target.Duration),
+				})
+			}
+			if err := textTrack.ExpandSegment(index, fills); err != nil {
+				return fmt.Errorf("展开字幕占位片段失败: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// replaceTitlePlaceholders 原位替换标题占位文字，保留样式
+func replaceTitlePlaceholders(sf *script.ScriptFile, placeholders map[string]string, title string) error {
+	if sf.TemplateMaterials == nil {
+		return nil
+	}
+	for _, mat := range sf.TemplateMaterials.Texts {
+		if placeholders[mat.ID] != placeholderTitle {
+			continue
+		}
+		if err := mat.SetText(title); err != nil {
+			return fmt.Errorf("替换标题失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// removeReplacedPlaceholders 删除已被替换的占位素材，标题素材原位修改后保留
+func removeReplacedPlaceholders(sf *script.ScriptFile, placeholders map[string]string) {
+	materials := sf.TemplateMaterials
+	if materials == nil {
+		return
+	}
+	replaced := func(id string) bool {
+		name, ok := placeholders[id]
+		return ok && name != placeholderTitle
+	}
+	materials.Videos = removeMaterials(materials.Videos, func(m *script.DraftVideoMaterial) bool { return replaced(m.ID) })
+	materials.Audios = removeMaterials(materials.Audios, func(m *script.DraftAudioMaterial) bool { return replaced(m.ID) })
+	materials.Texts = removeMaterials(materials.Texts, func(m *script.DraftTextMaterial) bool { return replaced(m.ID) })
+}
+
+// removeMaterials 删除满足条件的素材
+func removeMaterials[T any](materials []T, remove func(T) bool) []T {
+	kept := materials[:0]
+	for _, mat := range materials {
+		if !remove(mat) {
+			kept = append(kept, mat)
+		}
+	}
+	return kept
+}
+
+// templateTextMaterial 按ID查找模板中的文本素材
+func templateTextMaterial(sf *script.ScriptFile, id string) *script.DraftTextMaterial {
+	if sf.TemplateMaterials == nil {
+		return nil
+	}
+	for _, mat := range sf.TemplateMaterials.Texts {
+		if mat.ID == id {
+			return mat
+		}
+	}
+	return nil
+}
+
+// roleClips 返回指定用途轨道上的片段
+func roleClips(tl *timeline.Timeline, role string) []*timeline.Clip {
+	if tr := tl.TrackByRole(role); tr != nil {
+		return tr.Clips
+	}
+	return nil
+}
+
+// chapterTitle 章节标题，没有章节号时使用时间线名称
+func chapterTitle(tl *timeline.Timeline) string {
+	if tl.Chapter > 0 {
+		return fmt.Sprintf("第%d章", tl.Chapter)
+	}
+	return tl.Name
+}
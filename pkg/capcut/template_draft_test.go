@@ -0,0 +1,268 @@
+package capcut
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"novel-video-workflow/pkg/capcut/internal/draft"
+	"novel-video-workflow/pkg/capcut/internal/template"
+	"novel-video-workflow/pkg/timeline"
+)
+
+// testTemplateDraft 设计师做的模板：片头2秒、正片占位1秒、片尾2秒，背景音乐和边框贯穿全片，标题显示在片头
+const testTemplateDraft = `{
+  "canvas_config": {"height": 1920, "ratio": "original", "width": 1080},
+  "duration": 5000000,
+  "fps": 30.0,
+  "id": "TEMPLATE",
+  "materials": {
+    "videos": [
+      {"id": "M-INTRO", "material_name": "intro.mp4", "path": "/design/intro.mp4", "type": "video"},
+      {"id": "M-IMAGES", "material_name": "{{images}}.png", "path": "/design/placeholder.png", "type": "photo"},
+      {"id": "M-OUTRO", "material_name": "outro.mp4", "path": "/design/outro.mp4", "type": "video"}
+    ],
+    "audios": [
+      {"id": "M-NARRATION", "name": "{{narration}}.mp3", "path": "/design/placeholder.mp3", "type": "extract_music"},
+      {"id": "M-BGM", "name": "bgm.mp3", "path": "/design/bgm.mp3", "type": "extract_music"}
+    ],
+    "texts": [
+      {"id": "M-TITLE", "type": "text", "content": "{\"styles\":[{\"range\":[0,9],\"size\":15}],\"text\":\"{{title}}\"}"},
+      {"id": "M-SUBTITLE", "type": "subtitle", "content": "{\"styles\":[{\"range\":[0,12],\"size\":8}],\"text\":\"{{subtitle}}\"}"}
+    ],
+    "effects": [{"id": "M-FRAME", "type": "frame"}]
+  },
+  "tracks": [
+    {"id": "T-VIDEO", "name": "", "type": "video", "segments": [
+      {"id": "S-INTRO", "material_id": "M-INTRO", "render_index": 0, "target_timerange": {"start": 0, "duration": 2000000}, "source_timerange": {"start": 0, "duration": 2000000}},
+      {"id": "S-IMAGES", "material_id": "M-IMAGES", "render_index": 0, "clip": {"scale": {"x": 0.9, "y": 0.9}}, "target_timerange": {"start": 2000000, "duration": 1000000}, "source_timerange": {"start": 0, "duration": 1000000}},
+      {"id": "S-OUTRO", "material_id": "M-OUTRO", "render_index": 0, "target_timerange": {"start": 3000000, "duration": 2000000}, "source_timerange": {"start": 0, "duration": 2000000}}
+    ]},
+    {"id": "T-NARRATION", "name": "", "type": "audio", "segments": [
+      {"id": "S-NARRATION", "material_id": "M-NARRATION", "target_timerange": {"start": 2000000, "duration": 1000000}, "source_timerange": {"start": 0, "duration": 1000000}}
+    ]},
+    {"id": "T-BGM", "name": "", "type": "audio", "segments": [
+      {"id": "S-BGM", "material_id": "M-BGM", "target_timerange": {"start": 0, "duration": 5000000}, "source_timerange": {"start": 0, "duration": 5000000}}
+    ]},
+    {"id": "T-FRAME", "name": "", "type": "effect", "segments": [
+      {"id": "S-FRAME", "material_id": "M-FRAME", "render_index": 11000, "target_timerange": {"start": 0, "duration": 5000000}}
+    ]},
+    {"id": "T-TEXT", "name": "", "type": "text", "segments": [
+      {"id": "S-TITLE", "material_id": "M-TITLE", "render_index": 15000, "target_timerange": {"start": 0, "duration": 2000000}},
+      {"id": "S-SUBTITLE", "material_id": "M-SUBTITLE", "render_index": 15000, "target_timerange": {"start": 2000000, "duration": 1000000}}
+    ]}
+  ]
+}`
+
+// TestFillTemplate 测试用章节素材替换模板中的占位素材，模板轨道随旁白时长伸缩
+func TestFillTemplate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "chapter_05")
+	folder := t.TempDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(folder, "模板"), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	write := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("写入 %s 失败: %v", path, err)
+		}
+	}
+	write(filepath.Join(folder, "模板", "draft_info.json"), testTemplateDraft)
+	write(filepath.Join(dir, "chapter_05.wav"), "wav")
+	write(filepath.Join(dir, "scene_01.png"), "png")
+	write(filepath.Join(dir, "scene_02.png"), "png")
+	write(filepath.Join(dir, "chapter_05.srt"), "1\n00:00:00,000 --> 00:00:03,000\n第一句\n\n2\n00:00:03,000 --> 00:00:06,000\n第二句\n")
+
+	assets, err := scanChapterAssets(dir)
+	if err != nil {
+		t.Fatalf("扫描素材失败: %v", err)
+	}
+	tl, err := buildTimeline(assets, 6000000)
+	if err != nil {
+		t.Fatalf("生成时间线失败: %v", err)
+	}
+	draftFolder, err := draft.NewDraftFolder(folder)
+	if err != nil {
+		t.Fatalf("打开草稿文件夹失败: %v", err)
+	}
+	sf, err := draftFolder.DuplicateAsTemplate("模板", "小说_chapter_05", true)
+	if err != nil {
+		t.Fatalf("复制模板失败: %v", err)
+	}
+	if err := fillTemplate(sf, tl, templateFillOptions{ShrinkMode: "cut_tail_align", ExtendModes: []template.ExtendMode{"push_tail"}}); err != nil {
+		t.Fatalf("填充模板失败: %v", err)
+	}
+	if err := sf.Save(); err != nil {
+		t.Fatalf("保存草稿失败: %v", err)
+	}
+
+	result, err := LoadDraft(filepath.Join(folder, "小说_chapter_05", "draft_info.json"))
+	if err != nil {
+		t.Fatalf("读取草稿失败: %v", err)
+	}
+	// 旁白6秒，比占位素材长5秒：片尾后移到8秒，全片10秒
+	if result.Duration != 10000000 {
+		t.Errorf("草稿时长应为10秒，得到 %d", result.Duration)
+	}
+	segments := map[string][]*DraftSegment{}
+	for _, track := range result.Tracks {
+		segments[track.ID] = track.Segments
+	}
+
+	video := segments["T-VIDEO"]
+	if len(video) != 4 || video[0].ID != "S-INTRO" || video[3].ID != "S-OUTRO" {
+		t.Fatalf("视频轨道应为片头、两张图片、片尾: %+v", video)
+	}
+	if video[1].TargetTimerange.Start != 2000000 || video[2].TargetTimerange.End() != 8000000 {
+		t.Errorf("图片应铺满正片 [2s, 8s): %+v %+v", video[1].TargetTimerange, video[2].TargetTimerange)
+	}
+	if video[1].Clip == nil || video[1].Clip.Scale.X != 0.9 {
+		t.Errorf("图片应沿用占位片段的缩放")
+	}
+	if image := result.VideoMaterial(video[1].MaterialID); image == nil || filepath.Base(image.Path) != "scene_01.png" {
+		t.Errorf("第一张图片应为 scene_01.png")
+	}
+	if video[3].TargetTimerange.Start != 8000000 {
+		t.Errorf("片尾应后移到8秒，得到 %d", video[3].TargetTimerange.Start)
+	}
+	if narration := segments["T-NARRATION"][0]; narration.TargetTimerange.Duration != 6000000 || result.AudioMaterial(narration.MaterialID) == nil {
+		t.Errorf("旁白应替换为章节音频: %+v", narration)
+	}
+	if bgm := segments["T-BGM"][0]; bgm.TargetTimerange.Duration != 10000000 {
+		t.Errorf("背景音乐应延长到全片，得到 %d", bgm.TargetTimerange.Duration)
+	}
+	if frame := segments["T-FRAME"][0]; frame.TargetTimerange.Duration != 10000000 {
+		t.Errorf("边框应延长到全片，得到 %d", frame.TargetTimerange.Duration)
+	}
+
+	var texts []string
+	for _, track := range result.Tracks {
+		for _, seg := range result.TextSegments(track) {
+			texts = append(texts, seg.Material.Text())
+			if seg.ID != "S-TITLE" && !strings.Contains(seg.Material.Content, `"size":8`) {
+				t.Errorf("字幕应沿用占位文字的样式: %s", seg.Material.Content)
+			}
+		}
+	}
+	if len(texts) != 3 || texts[0] != "第5章" || texts[1] != "第一句" || texts[2] != "第二句" {
+		t.Errorf("文本轨道应为标题和两句字幕，得到 %v", texts)
+	}
+	if text := segments["T-TEXT"][2]; text.TargetTimerange.Start != 5000000 {
+		t.Errorf("第二句字幕应从5秒开始，得到 %d", text.TargetTimerange.Start)
+	}
+
+	for _, id := range []string{"M-IMAGES", "M-NARRATION", "M-SUBTITLE"} {
+		if result.VideoMaterial(id) != nil || result.AudioMaterial(id) != nil || result.TextMaterial(id) != nil {
+			t.Errorf("占位素材 %s 应删除", id)
+		}
+	}
+	if result.VideoMaterial("M-INTRO") == nil || result.AudioMaterial("M-BGM") == nil || result.EffectMaterial("M-FRAME") == nil {
+		t.Errorf("模板中的片头、背景音乐和边框素材应保留")
+	}
+}
+
+// TestRegenerateFromTemplate 测试模板模式下重新生成章节时与剪映中修改过的草稿合并，没有生成记录时不覆盖
+func TestRegenerateFromTemplate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "chapter_05")
+	folder := t.TempDir()
+	for _, d := range []string{dir, filepath.Join(folder, "模板"), filepath.Join(folder, "design")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+	}
+	write := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("写入 %s 失败: %v", path, err)
+		}
+	}
+
+	// 模板素材指向真实文件，并补全剪映打开草稿需要的字段，生成的草稿才能通过检查
+	var tmpl map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.ReplaceAll(testTemplateDraft, "/design/", filepath.ToSlash(filepath.Join(folder, "design"))+"/")), &tmpl); err != nil {
+		t.Fatalf("解析模板失败: %v", err)
+	}
+	for key, value := range map[string]interface{}{"config": map[string]interface{}{}, "new_version": "110.0.0", "platform": map[string]interface{}{}, "version": 360000} {
+		tmpl[key] = value
+	}
+	content, err := json.Marshal(tmpl)
+	if err != nil {
+		t.Fatalf("导出模板失败: %v", err)
+	}
+	write(filepath.Join(folder, "模板", "draft_info.json"), string(content))
+	for _, name := range []string{"intro.mp4", "placeholder.png", "outro.mp4", "placeholder.mp3", "bgm.mp3"} {
+		write(filepath.Join(folder, "design", name), name)
+	}
+	write(filepath.Join(dir, "chapter_05.wav"), "wav")
+	write(filepath.Join(dir, "scene_01.png"), "png")
+	write(filepath.Join(dir, "scene_02.png"), "png")
+
+	draftFolder, err := draft.NewDraftFolder(folder)
+	if err != nil {
+		t.Fatalf("打开草稿文件夹失败: %v", err)
+	}
+	chapterTimeline := func(firstCue string) *timeline.Timeline {
+		t.Helper()
+		write(filepath.Join(dir, "chapter_05.srt"), "1\n00:00:00,000 --> 00:00:03,000\n"+firstCue+"\n\n2\n00:00:03,000 --> 00:00:06,000\n第二句\n")
+		assets, err := scanChapterAssets(dir)
+		if err != nil {
+			t.Fatalf("扫描素材失败: %v", err)
+		}
+		tl, err := buildTimeline(assets, 6000000)
+		if err != nil {
+			t.Fatalf("生成时间线失败: %v", err)
+		}
+		return tl
+	}
+	if err := createFromTemplate(draftFolder, "模板", "小说_chapter_05", chapterTimeline("第一句")); err != nil {
+		t.Fatalf("根据模板生成草稿失败: %v", err)
+	}
+
+	// 在剪映中改正第二句字幕
+	draftPath := draftFolder.GetDraftPath("小说_chapter_05")
+	edited, err := LoadDraft(filepath.Join(draftPath, "draft_info.json"))
+	if err != nil {
+		t.Fatalf("读取草稿失败: %v", err)
+	}
+	subtitles := edited.Tracks[len(edited.Tracks)-1]
+	if err := edited.TextMaterial(subtitles.Segments[2].MaterialID).SetText("第二句（改）"); err != nil {
+		t.Fatalf("修改字幕失败: %v", err)
+	}
+	if err := edited.Save(); err != nil {
+		t.Fatalf("保存草稿失败: %v", err)
+	}
+
+	// 修改第一句字幕后重新生成
+	if err := regenerateFromTemplate(draftFolder, "模板", draftPath, dir, chapterTimeline("第一句新版")); err != nil {
+		t.Fatalf("重新生成失败: %v", err)
+	}
+	result, err := LoadDraft(filepath.Join(draftPath, "draft_info.json"))
+	if err != nil {
+		t.Fatalf("读取草稿失败: %v", err)
+	}
+	var texts []string
+	for _, seg := range result.Tracks[len(result.Tracks)-1].Segments {
+		texts = append(texts, result.TextMaterial(seg.MaterialID).Text())
+	}
+	if len(texts) != 3 || texts[1] != "第一句新版" || texts[2] != "第二句（改）" {
+		t.Errorf("应更新第一句并保留用户改正的第二句，得到 %v", texts)
+	}
+	if _, err := os.Stat(filepath.Join(dir, mergeReportName)); err != nil {
+		t.Errorf("应写出合并报告: %v", err)
+	}
+
+	// 没有上次生成的记录时不覆盖草稿
+	if err := os.Remove(filepath.Join(draftPath, generatedDraftName)); err != nil {
+		t.Fatalf("删除生成记录失败: %v", err)
+	}
+	before, _ := os.ReadFile(filepath.Join(draftPath, "draft_info.json"))
+	if err := regenerateFromTemplate(draftFolder, "模板", draftPath, dir, chapterTimeline("第一句")); err == nil {
+		t.Errorf("没有生成记录时应拒绝覆盖草稿")
+	}
+	if after, _ := os.ReadFile(filepath.Join(draftPath, "draft_info.json")); string(after) != string(before) {
+		t.Errorf("拒绝覆盖时草稿不应变化")
+	}
+}
@@ -0,0 +1,278 @@
+package capcut
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"novel-video-workflow/pkg/timeline"
+
+	"github.com/spf13/viper"
+)
+
+// chapterNumberPattern 从 chapter_03 这样的目录名中提取章节号
+var chapterNumberPattern = regexp.MustCompile(`(?i)chapter[_-]?(\d+)`)
+
+// defaultBGMGain 背景音乐的默认音量倍数
+const defaultBGMGain = 0.3
+
+// chapterAssets 章节目录中用于生成时间线的素材
+type chapterAssets struct {
+	Dir               string
+	AudioFile         string
+	ImageFiles        []string
+	SrtFile           string
+	TranslatedSrtFile string // 译文字幕，如 chapter_01.en.srt
+	BGMFile           string // 背景音乐：章节目录中的 bgm.*，没有时使用 video.bgm.file
+	BGMDuration       int64  // 背景音乐时长（微秒），由 BuildChapterTimeline 获取，未知时为0
+}
+
+// scanChapterAssets 扫描输入目录中的音频、图片和字幕文件，缺少音频或图片时返回错误
+func scanChapterAssets(inputDir string) (*chapterAssets, error) {
+	// 获取输入目录的绝对路径
+	inputDir, err := filepath.Abs(inputDir)
+	if err != nil {
+		return nil, fmt.Errorf("获取输入目录绝对路径失败: %v", err)
+	}
+
+	// 清理输入目录路径中的特殊字符
+	assets := &chapterAssets{Dir: cleanPath(inputDir)}
+
+	files, err := ioutil.ReadDir(assets.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取输入目录失败: %v", err)
+	}
+
+	for _, file := range files {
+		filename := strings.ToLower(file.Name())
+		path := cleanPath(filepath.Join(assets.Dir, file.Name()))
+		if strings.HasSuffix(filename, ".wav") || strings.HasSuffix(filename, ".mp3") {
+			// bgm 开头的音频是背景音乐，不作为旁白
+			if strings.HasPrefix(filename, "bgm") {
+				assets.BGMFile = path
+			} else {
+				assets.AudioFile = path
+			}
+		} else if strings.HasSuffix(filename, ".png") || strings.HasSuffix(filename, ".jpg") || strings.HasSuffix(filename, ".jpeg") {
+			assets.ImageFiles = append(assets.ImageFiles, path)
+		} else if strings.HasSuffix(filename, ".srt") {
+			if isTranslatedSubtitle(filename) {
+				assets.TranslatedSrtFile = path
+			} else {
+				assets.SrtFile = path
+			}
+		}
+	}
+
+	if assets.AudioFile == "" {
+		return nil, fmt.Errorf("未找到音频文件")
+	}
+	if len(assets.ImageFiles) == 0 {
+		return nil, fmt.Errorf("未找到图片文件")
+	}
+	if assets.BGMFile == "" {
+		assets.BGMFile = configuredBGMFile()
+	}
+	return assets, nil
+}
+
+// ChapterBGMFile 返回章节使用的背景音乐文件，没有背景音乐或章节素材不全时返回空
+func ChapterBGMFile(chapterDir string) string {
+	assets, err := scanChapterAssets(chapterDir)
+	if err != nil {
+		return ""
+	}
+	return assets.BGMFile
+}
+
+// configuredBGMFile 返回 video.bgm.file 配置的背景音乐，未配置或文件不存在时返回空
+func configuredBGMFile() string {
+	file := viper.GetString("video.bgm.file")
+	if file == "" {
+		return ""
+	}
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return ""
+	}
+	if _, err := os.Stat(path); err != nil {
+		fmt.Printf("⚠️  背景音乐文件不存在，不添加背景音乐: %s\n", path)
+		return ""
+	}
+	return cleanPath(path)
+}
+
+// BuildChapterTimeline 根据章节目录中的音频、图片和字幕生成时间线，并保存为该目录下的 timeline.json。
+// 剪映草稿和其他导出格式都从返回的时间线生成
+func BuildChapterTimeline(inputDir string) (*timeline.Timeline, error) {
+	assets, err := scanChapterAssets(inputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取音频文件实际时长
+	audioDuration, err := getAudioDuration(assets.AudioFile)
+	if err != nil {
+		return nil, fmt.Errorf("获取音频时长失败: %v", err)
+	}
+	if assets.BGMFile != "" {
+		// 获取不到时长时背景音乐按一段铺满旁白
+		if assets.BGMDuration, err = getAudioDuration(assets.BGMFile); err != nil {
+			fmt.Printf("⚠️  获取背景音乐时长失败: %v\n", err)
+		}
+	}
+
+	tl, err := buildTimeline(assets, audioDuration)
+	if err != nil {
+		return nil, err
+	}
+	path, err := tl.Save(assets.Dir)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("🧾 时间线已保存: %s\n", path)
+	return tl, nil
+}
+
+// buildTimeline 按旁白字幕安排图片，并添加运动效果、转场、旁白和字幕
+func buildTimeline(assets *chapterAssets, audioDuration int64) (*timeline.Timeline, error) {
+	tl := timeline.New(filepath.Base(assets.Dir), timeline.DefaultWidth, timeline.DefaultHeight, timeline.DefaultFPS)
+	tl.Chapter = chapterNumberFromDir(assets.Dir)
+	tl.Duration = audioDuration
+
+	var primaryCues, translatedCues []timeline.TextCue
+	if assets.SrtFile != "" {
+		cues, err := subtitleCues(assets.SrtFile, audioDuration)
+		if err != nil {
+			return nil, err
+		}
+		primaryCues = cues
+	}
+	if assets.TranslatedSrtFile != "" {
+		cues, err := subtitleCues(assets.TranslatedSrtFile, audioDuration)
+		if err != nil {
+			return nil, err
+		}
+		translatedCues = cues
+	}
+
+	// 计算台词总字数
+	totalSubtitleChars := 0
+	for _, cue := range primaryCues {
+		totalSubtitleChars += len([]rune(cue.Text))
+	}
+
+	// 输出日志信息
+	fmt.Printf("🎵 音频时长: %.2f秒 (%d微秒)\n", float64(audioDuration)/1000000.0, audioDuration)
+	fmt.Printf("🖼️  图片资源: %d张\n", len(assets.ImageFiles))
+	fmt.Printf("📄 视频资源: 1个 (音频文件: %s)\n", filepath.Base(assets.AudioFile))
+	fmt.Printf("💬 台词字数: %d个字符\n", totalSubtitleChars)
+
+	// 计算每个图片的显示时间：按旁白字幕对齐切换点，无法对齐时按时长权重平均分配
+	imageSlots, timingMode := planImageTimeline(assets.ImageFiles, assets.SrtFile, audioDuration, imageTimingOptionsFromConfig())
+	fmt.Printf("⏱️  图片时间线: %s\n", timingMode)
+
+	// 静态图片的运动效果（video.effects.ken_burns_*）
+	motion := newMotionPlanner(motionOptionsFromConfig())
+	// 相邻图片之间的转场（video.effects.transition_*）
+	transitions := newTransitionPlanner(transitionOptionsFromConfig(), assets.ImageFiles)
+	records := loadImageRecords(assets.ImageFiles)
+
+	images := tl.AddTrack(timeline.TrackVideo, "视频轨道", timeline.RoleImages)
+	for i, imageFile := range assets.ImageFiles {
+		duration := imageSlots[i].End - imageSlots[i].Start
+		clip := images.AddClip(&timeline.Clip{
+			ID:         fmt.Sprintf("image_%02d", i+1),
+			Name:       filepath.Base(imageFile),
+			Media:      timeline.MediaImage,
+			Path:       imageFile,
+			Source:     timeline.Range{Start: 0, Duration: duration},
+			Target:     timeline.Range{Start: imageSlots[i].Start, Duration: duration},
+			Motion:     motion.plan(i, duration),
+			Transition: transitions.plan(i, imageSlots),
+		})
+		if record, ok := records[filepath.Base(imageFile)]; ok {
+			clip.SourceText = record.SourceText
+			if record.Scene != nil {
+				clip.Mood = record.Scene.Mood
+			}
+		}
+	}
+
+	narration := tl.AddTrack(timeline.TrackAudio, "音频轨道", timeline.RoleNarration)
+	narration.AddClip(&timeline.Clip{
+		ID:            "narration",
+		Name:          filepath.Base(assets.AudioFile),
+		Media:         timeline.MediaAudio,
+		Path:          assets.AudioFile,
+		Source:        timeline.Range{Start: 0, Duration: audioDuration},
+		Target:        timeline.Range{Start: 0, Duration: audioDuration},
+		MediaDuration: audioDuration,
+		Gain:          1.0,
+	})
+
+	if assets.BGMFile != "" {
+		addBGMTrack(tl, assets.BGMFile, assets.BGMDuration, audioDuration)
+	}
+
+	// 中文字幕在前，译文字幕在其下方
+	if len(primaryCues) > 0 {
+		primary := primarySubtitleStyle()
+		subtitles := tl.AddTrack(timeline.TrackText, primary.TrackName, timeline.RoleSubtitle)
+		subtitles.Cues = primaryCues
+		subtitles.Style = primary.timelineStyle()
+	}
+	if len(translatedCues) > 0 {
+		translated := translatedSubtitleStyle()
+		subtitles := tl.AddTrack(timeline.TrackText, translated.TrackName, timeline.RoleTranslation)
+		subtitles.Cues = translatedCues
+		subtitles.Style = translated.timelineStyle()
+	}
+
+	if err := tl.Validate(); err != nil {
+		return nil, err
+	}
+	return tl, nil
+}
+
+// addBGMTrack 添加铺满旁白的背景音乐轨道，背景音乐比旁白短时首尾相接循环，音量取自 video.bgm.gain
+func addBGMTrack(tl *timeline.Timeline, bgmFile string, bgmDuration, total int64) {
+	gain := viper.GetFloat64("video.bgm.gain")
+	if gain <= 0 {
+		gain = defaultBGMGain
+	}
+	length := bgmDuration
+	if length <= 0 || length > total {
+		length = total
+	}
+	fmt.Printf("🎶 背景音乐: %s\n", filepath.Base(bgmFile))
+
+	bgm := tl.AddTrack(timeline.TrackAudio, "背景音乐", timeline.RoleBGM)
+	for start := int64(0); start < total; start += length {
+		duration := min(length, total-start)
+		bgm.AddClip(&timeline.Clip{
+			ID:            fmt.Sprintf("bgm_%02d", len(bgm.Clips)+1),
+			Name:          filepath.Base(bgmFile),
+			Media:         timeline.MediaAudio,
+			Path:          bgmFile,
+			Source:        timeline.Range{Start: 0, Duration: duration},
+			Target:        timeline.Range{Start: start, Duration: duration},
+			MediaDuration: bgmDuration, // 素材为完整的背景音乐文件，比旁白长时可在剪映中继续拉长
+			Gain:          gain,
+		})
+	}
+}
+
+// chapterNumberFromDir 从目录名中提取章节号，没有时返回0
+func chapterNumberFromDir(dir string) int {
+	match := chapterNumberPattern.FindStringSubmatch(filepath.Base(dir))
+	if match == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(match[1])
+	return n
+}
@@ -0,0 +1,269 @@
+package capcut
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"novel-video-workflow/pkg/timeline"
+	"novel-video-workflow/pkg/timeline/mlt"
+	"novel-video-workflow/pkg/timeline/otio"
+
+	"github.com/spf13/viper"
+)
+
+func TestBuildTimelineAndDraft(t *testing.T) {
+	viper.Set("video.effects.ken_burns_enabled", true)
+	viper.Set("video.effects.transition_policy", transitionPolicyFixed)
+	viper.Set("video.effects.transition_name", "淡入淡出")
+	defer func() {
+		viper.Set("video.effects.ken_burns_enabled", nil)
+		viper.Set("video.effects.transition_policy", nil)
+		viper.Set("video.effects.transition_name", nil)
+	}()
+
+	dir := filepath.Join(t.TempDir(), "chapter_03")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	files := map[string]string{
+		"chapter_03.wav":    "wav",
+		"scene_01.png":      "png",
+		"scene_02.png":      "png",
+		"chapter_03.srt":    "1\n00:00:00,000 --> 00:00:03,000\n第一句\n\n2\n00:00:03,000 --> 00:00:06,000\n第二句\n",
+		"chapter_03.en.srt": "1\n00:00:00,000 --> 00:00:06,000\nFirst line\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("写入 %s 失败: %v", name, err)
+		}
+	}
+
+	assets, err := scanChapterAssets(dir)
+	if err != nil {
+		t.Fatalf("扫描素材失败: %v", err)
+	}
+	tl, err := buildTimeline(assets, 6000000)
+	if err != nil {
+		t.Fatalf("生成时间线失败: %v", err)
+	}
+	if tl.Chapter != 3 || tl.Duration != 6000000 {
+		t.Errorf("时间线基本信息错误: chapter=%d duration=%d", tl.Chapter, tl.Duration)
+	}
+
+	images := tl.TrackByRole(timeline.RoleImages)
+	if images == nil || len(images.Clips) != 2 {
+		t.Fatalf("应有两张图片: %+v", images)
+	}
+	if images.Clips[0].Motion == nil || images.Clips[0].Transition == nil || images.Clips[1].Transition != nil {
+		t.Errorf("第一张应有运动和转场，最后一张之后没有转场")
+	}
+	if last := images.Clips[1]; last.Target.End() != 6000000 {
+		t.Errorf("最后一张应结束于音频末尾: %d", last.Target.End())
+	}
+	if tl.TrackByRole(timeline.RoleNarration) == nil || tl.TrackByRole(timeline.RoleSubtitle) == nil || tl.TrackByRole(timeline.RoleTranslation) == nil {
+		t.Fatalf("缺少旁白或字幕轨道")
+	}
+
+	// 保存后重新读取，生成的草稿与直接使用内存中的时间线一致
+	if _, err := tl.Save(dir); err != nil {
+		t.Fatalf("保存时间线失败: %v", err)
+	}
+	loaded, err := timeline.Load(dir)
+	if err != nil {
+		t.Fatalf("读取时间线失败: %v", err)
+	}
+	sf, err := newDraftFromTimeline(loaded)
+	if err != nil {
+		t.Fatalf("生成草稿失败: %v", err)
+	}
+	videoTrack := sf.Tracks["视频轨道"]
+	if videoTrack == nil || len(videoTrack.Segments) != 2 {
+		t.Fatalf("草稿视频轨道错误: %v", sf.Tracks)
+	}
+	if len(sf.Materials.Transitions) != 1 {
+		t.Errorf("草稿应包含一个转场素材: %d", len(sf.Materials.Transitions))
+	}
+	if len(sf.Materials.Texts) != 3 {
+		t.Errorf("文本素材数量错误: %d", len(sf.Materials.Texts))
+	}
+}
+
+func TestScanChapterAssetsRequiresAudioAndImages(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := scanChapterAssets(dir); err == nil {
+		t.Errorf("缺少音频时应报错")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.mp3"), []byte("mp3"), 0644); err != nil {
+		t.Fatalf("写入音频失败: %v", err)
+	}
+	if _, err := scanChapterAssets(dir); err == nil {
+		t.Errorf("缺少图片时应报错")
+	}
+}
+
+// TestBuildTimelineBGM 测试章节目录中的 bgm.* 作为背景音乐轨道循环铺满旁白，不当作旁白；没有时使用 video.bgm.file
+func TestBuildTimelineBGM(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "chapter_04")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	for _, name := range []string{"bgm.mp3", "chapter_04.wav", "scene_01.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("写入 %s 失败: %v", name, err)
+		}
+	}
+
+	assets, err := scanChapterAssets(dir)
+	if err != nil {
+		t.Fatalf("扫描素材失败: %v", err)
+	}
+	if filepath.Base(assets.AudioFile) != "chapter_04.wav" || filepath.Base(assets.BGMFile) != "bgm.mp3" {
+		t.Fatalf("旁白和背景音乐识别错误: %s, %s", assets.AudioFile, assets.BGMFile)
+	}
+	assets.BGMDuration = 2500000
+	tl, err := buildTimeline(assets, 6000000)
+	if err != nil {
+		t.Fatalf("生成时间线失败: %v", err)
+	}
+	bgm := tl.TrackByRole(timeline.RoleBGM)
+	if bgm == nil || len(bgm.Clips) != 3 {
+		t.Fatalf("背景音乐应循环为3段: %+v", bgm)
+	}
+	if last := bgm.Clips[2]; last.Target.Start != 5000000 || last.Source.Duration != 1000000 || last.Target.End() != 6000000 {
+		t.Errorf("最后一段应截到旁白结尾: %+v", last)
+	}
+	if bgm.Clips[0].Gain != defaultBGMGain {
+		t.Errorf("背景音乐应使用默认音量，得到 %v", bgm.Clips[0].Gain)
+	}
+
+	sf, err := newDraftFromTimeline(tl)
+	if err != nil {
+		t.Fatalf("生成草稿失败: %v", err)
+	}
+	if track := sf.Tracks["背景音乐"]; track == nil || len(track.Segments) != 3 {
+		t.Errorf("草稿应有3段背景音乐: %v", sf.Tracks)
+	}
+	// 每段背景音乐的素材时长都是整个文件的时长，而不是该段使用的部分
+	for _, m := range sf.Materials.Audios {
+		want := int64(6000000)
+		if m.MaterialName == "bgm.mp3" {
+			want = 2500000
+		}
+		if m.Duration != want {
+			t.Errorf("素材 %s 的时长应为 %d，得到 %d", m.MaterialName, want, m.Duration)
+		}
+	}
+
+	// 背景音乐比旁白长时只用一段，素材仍保留完整时长，可在剪映中继续拉长
+	assets.BGMDuration = 9000000
+	if tl, err = buildTimeline(assets, 6000000); err != nil {
+		t.Fatalf("生成时间线失败: %v", err)
+	}
+	if sf, err = newDraftFromTimeline(tl); err != nil {
+		t.Fatalf("生成草稿失败: %v", err)
+	}
+	for _, m := range sf.Materials.Audios {
+		if m.MaterialName == "bgm.mp3" && m.Duration != 9000000 {
+			t.Errorf("背景音乐素材时长应为完整文件的9秒，得到 %d", m.Duration)
+		}
+	}
+
+	// 章节目录中没有背景音乐时使用配置的文件
+	shared := filepath.Join(t.TempDir(), "shared.mp3")
+	if err := os.WriteFile(shared, []byte("mp3"), 0644); err != nil {
+		t.Fatalf("写入背景音乐失败: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "bgm.mp3")); err != nil {
+		t.Fatalf("删除背景音乐失败: %v", err)
+	}
+	viper.Set("video.bgm.file", shared)
+	defer viper.Set("video.bgm.file", nil)
+	if assets, err = scanChapterAssets(dir); err != nil {
+		t.Fatalf("扫描素材失败: %v", err)
+	}
+	if filepath.Base(assets.BGMFile) != "shared.mp3" {
+		t.Errorf("应使用 video.bgm.file，得到 %q", assets.BGMFile)
+	}
+}
+
+// TestChapterTimelineExportsBGM 测试章节时间线导出的 OTIO 中有旁白和背景音乐两条音频轨道，往返后背景音乐的分段和音量不变
+func TestChapterTimelineExportsBGM(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "chapter_06")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	for _, name := range []string{"bgm.mp3", "chapter_06.wav", "scene_01.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("写入 %s 失败: %v", name, err)
+		}
+	}
+	assets, err := scanChapterAssets(dir)
+	if err != nil {
+		t.Fatalf("扫描素材失败: %v", err)
+	}
+	assets.BGMDuration = 4000000
+	tl, err := buildTimeline(assets, 6000000)
+	if err != nil {
+		t.Fatalf("生成时间线失败: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := otio.Encode(&buf, tl); err != nil {
+		t.Fatalf("导出OTIO失败: %v", err)
+	}
+	decoded, err := otio.Decode(&buf)
+	if err != nil {
+		t.Fatalf("读取OTIO失败: %v", err)
+	}
+	if len(decoded.TracksOf(timeline.TrackAudio)) != 2 || decoded.TrackByRole(timeline.RoleNarration) == nil {
+		t.Fatalf("应有旁白和背景音乐两条音频轨道")
+	}
+	bgm := decoded.TrackByRole(timeline.RoleBGM)
+	if bgm == nil || len(bgm.Clips) != 2 {
+		t.Fatalf("背景音乐应为两段: %+v", bgm)
+	}
+	if bgm.Clips[1].Target.Start != 4000000 || bgm.Clips[1].Gain != defaultBGMGain {
+		t.Errorf("第二段背景音乐应从4秒开始、音量不变: %+v", bgm.Clips[1])
+	}
+}
+
+// TestChapterTimelineExportsBGMToMLT 测试章节时间线导出的 MLT 项目中背景音乐每段一个 producer，带音量滤镜并与旁白混音
+func TestChapterTimelineExportsBGMToMLT(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "chapter_07")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	for _, name := range []string{"bgm.mp3", "chapter_07.wav", "scene_01.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("写入 %s 失败: %v", name, err)
+		}
+	}
+	assets, err := scanChapterAssets(dir)
+	if err != nil {
+		t.Fatalf("扫描素材失败: %v", err)
+	}
+	assets.BGMDuration = 4000000
+	tl, err := buildTimeline(assets, 6000000)
+	if err != nil {
+		t.Fatalf("生成时间线失败: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := mlt.Encode(&buf, tl, mlt.Options{}); err != nil {
+		t.Fatalf("导出MLT失败: %v", err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, `<property name="resource">`+assets.BGMFile+`</property>`); n != 2 {
+		t.Errorf("背景音乐应有2个 producer，得到 %d", n)
+	}
+	// 旁白为原始音量，只有背景音乐带音量滤镜
+	if n := strings.Count(out, `<property name="shotcut:filter">audioGain</property>`); n != 2 {
+		t.Errorf("两段背景音乐都应带音量滤镜，得到 %d", n)
+	}
+	if n := strings.Count(out, `<property name="mlt_service">mix</property>`); n < 2 {
+		t.Errorf("旁白和背景音乐应混音，得到 %d 个 mix", n)
+	}
+}
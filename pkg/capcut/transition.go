@@ -0,0 +1,181 @@
+package capcut
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"novel-video-workflow/pkg/capcut/internal/metadata"
+	"novel-video-workflow/pkg/timeline"
+
+	"github.com/spf13/viper"
+)
+
+// 转场的选择方式
+const (
+	transitionPolicyNone   = "none"   // 硬切，不加转场
+	transitionPolicyFixed  = "fixed"  // 所有切换点使用同一个转场
+	transitionPolicyRandom = "random" // 从允许列表中随机选择
+	transitionPolicyMood   = "mood"   // 按下一张图片分镜的氛围选择
+)
+
+// 转场时长的默认值和下限（微秒），切换点两侧的图片显示时长太短时不加转场
+const (
+	defaultTransitionDuration int64 = 500000
+	minTransitionDuration     int64 = 100000
+)
+
+// transitionOptions 图片之间的转场配置
+type transitionOptions struct {
+	Policy    string
+	Name      string            // fixed 使用的转场，也是 mood 没有匹配时的默认转场
+	Allowlist []string          // random 可选的转场，为空时使用所有非VIP转场
+	Moods     map[string]string // 氛围关键词 -> 转场名称，氛围包含关键词即匹配
+	Duration  int64
+	Seed      int64 // random 的随机种子，0为每次随机
+}
+
+// transitionOptionsFromConfig 读取 video.effects 中的转场配置，时长取 transition_duration
+func transitionOptionsFromConfig() transitionOptions {
+	opts := transitionOptions{
+		Policy:    viper.GetString("video.effects.transition_policy"),
+		Name:      viper.GetString("video.effects.transition_name"),
+		Allowlist: viper.GetStringSlice("video.effects.transition_allowlist"),
+		Moods:     viper.GetStringMapString("video.effects.transition_moods"),
+		Duration:  defaultTransitionDuration,
+		Seed:      viper.GetInt64("video.effects.transition_seed"),
+	}
+	if v := viper.GetFloat64("video.effects.transition_duration"); v > 0 {
+		opts.Duration = int64(v * 1e6)
+	}
+	return opts
+}
+
+// findTransition 在剪映转场和CapCut转场目录中按名称查找
+func findTransition(name string) (metadata.TransitionMeta, bool) {
+	if strings.TrimSpace(name) == "" {
+		return metadata.TransitionMeta{}, false
+	}
+	effect, err := metadata.FindTransitionByName(name)
+	if err != nil {
+		return metadata.TransitionMeta{}, false
+	}
+	meta, ok := effect.GetMeta().(metadata.TransitionMeta)
+	return meta, ok
+}
+
+// transitionPlanner 为相邻两张图片之间的切换点选择转场
+type transitionPlanner struct {
+	opts  transitionOptions
+	pool  []metadata.TransitionMeta // random 的候选转场
+	moods []string                  // 每张图片分镜的氛围，mood 模式使用
+	rng   *rand.Rand
+}
+
+// newTransitionPlanner 按配置创建转场规划器，策略为 none 或没有可用转场时返回nil
+func newTransitionPlanner(opts transitionOptions, imageFiles []string) *transitionPlanner {
+	planner := &transitionPlanner{opts: opts}
+	switch opts.Policy {
+	case transitionPolicyFixed:
+		if _, ok := findTransition(opts.Name); !ok {
+			fmt.Printf("⚠️  未知的转场: %s，不添加转场\n", opts.Name)
+			return nil
+		}
+	case transitionPolicyRandom:
+		names := opts.Allowlist
+		if len(names) == 0 {
+			var catalog []metadata.EffectEnumerable
+			catalog = append(catalog, metadata.GetAllTransitionTypes()...)
+			catalog = append(catalog, metadata.GetAllCapCutTransitionTypes()...)
+			for _, effect := range catalog {
+				if meta, ok := effect.GetMeta().(metadata.TransitionMeta); ok && !meta.IsVIP {
+					names = append(names, effect.GetName())
+				}
+			}
+		}
+		for _, name := range names {
+			if meta, ok := findTransition(name); ok {
+				planner.pool = append(planner.pool, meta)
+			} else {
+				fmt.Printf("⚠️  未知的转场: %s，已忽略\n", name)
+			}
+		}
+		if len(planner.pool) == 0 {
+			return nil
+		}
+		seed := opts.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+			fmt.Printf("🎞️  转场随机种子: %d\n", seed)
+		}
+		planner.rng = rand.New(rand.NewSource(seed))
+	case transitionPolicyMood:
+		if len(imageFiles) > 0 {
+			records := loadImageRecords(imageFiles)
+			planner.moods = make([]string, len(imageFiles))
+			for i, imageFile := range imageFiles {
+				if record, ok := records[filepath.Base(imageFile)]; ok && record.Scene != nil {
+					planner.moods[i] = record.Scene.Mood
+				}
+			}
+		}
+	default:
+		return nil
+	}
+	return planner
+}
+
+// choose 选择第 index 张图片切换到下一张时使用的转场，返回false表示硬切
+func (p *transitionPlanner) choose(index int) (metadata.TransitionMeta, bool) {
+	switch p.opts.Policy {
+	case transitionPolicyFixed:
+		return findTransition(p.opts.Name)
+	case transitionPolicyRandom:
+		return p.pool[p.rng.Intn(len(p.pool))], true
+	case transitionPolicyMood:
+		mood := ""
+		if index+1 < len(p.moods) {
+			mood = p.moods[index+1]
+		}
+		return findTransition(moodTransition(p.opts.Moods, mood, p.opts.Name))
+	}
+	return metadata.TransitionMeta{}, false
+}
+
+// moodTransition 按氛围关键词匹配转场名称，多个关键词匹配时取最长的关键词，没有匹配时返回 fallback
+func moodTransition(moods map[string]string, mood, fallback string) string {
+	best, name := "", fallback
+	for keyword, transition := range moods {
+		if keyword != "" && strings.Contains(mood, keyword) && len(keyword) > len(best) {
+			best, name = keyword, transition
+		}
+	}
+	return name
+}
+
+// plan 选择第 index 张图片切换到下一张的转场；规划器为nil或应硬切时返回nil。
+// 转场跨在切换点两侧，时长不超过前后两张图片各自显示时长的一半，
+// 旁白对齐的切换点保持不变
+func (p *transitionPlanner) plan(index int, slots []imageSlot) *timeline.Transition {
+	if p == nil || index+1 >= len(slots) {
+		return nil
+	}
+	meta, ok := p.choose(index)
+	if !ok {
+		return nil
+	}
+
+	duration := p.opts.Duration
+	for _, slot := range slots[index : index+2] {
+		if half := (slot.End - slot.Start) / 2; half < duration {
+			duration = half
+		}
+	}
+	if duration < minTransitionDuration {
+		return nil
+	}
+
+	return &timeline.Transition{Name: meta.Name, Duration: duration, EffectID: meta.EffectID, ResourceID: meta.ResourceID}
+}
@@ -0,0 +1,84 @@
+package capcut
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"novel-video-workflow/pkg/tools/drawthings"
+)
+
+func TestTransitionPlannerClampsToNeighbours(t *testing.T) {
+	planner := newTransitionPlanner(transitionOptions{Policy: transitionPolicyFixed, Name: "淡入淡出", Duration: 800000}, nil)
+	slots := []imageSlot{{0, 4000000}, {4000000, 5000000}, {5000000, 5150000}}
+
+	transition := planner.plan(0, slots)
+	if transition == nil || transition.Name != "淡入淡出" {
+		t.Fatalf("应添加固定转场: %+v", transition)
+	}
+	// 下一张只显示1秒，转场不超过其一半
+	if transition.Duration != 500000 {
+		t.Errorf("转场时长应限制为相邻图片的一半: %d", transition.Duration)
+	}
+	if transition.EffectID == "" || transition.ResourceID == "" {
+		t.Errorf("转场应带有剪映资源ID: %+v", transition)
+	}
+
+	// 最后一张只有0.15秒，放不下转场；最后一张之后没有切换点
+	if planner.plan(1, slots) != nil || planner.plan(2, slots) != nil {
+		t.Errorf("放不下转场或最后一张时应硬切")
+	}
+
+	if newTransitionPlanner(transitionOptions{Policy: transitionPolicyFixed, Name: "不存在的转场"}, nil) != nil {
+		t.Errorf("未知转场应不添加转场")
+	}
+	if newTransitionPlanner(transitionOptions{}, nil) != nil {
+		t.Errorf("未配置策略时应为硬切")
+	}
+}
+
+func TestTransitionPlannerByMood(t *testing.T) {
+	dir := t.TempDir()
+	manifest := drawthings.NewImageManifest(1, drawthings.ManifestSourceOllamaScenes)
+	var imageFiles []string
+	for i, mood := range []string{"平静", "阴冷紧张", "温馨"} {
+		imageFile := filepath.Join(dir, fmt.Sprintf("scene_%02d.png", i+1))
+		if err := os.WriteFile(imageFile, []byte("png"), 0644); err != nil {
+			t.Fatalf("写入图片失败: %v", err)
+		}
+		record := drawthings.NewImageRecord(i+1, imageFile, drawthings.Txt2ImgRequest{})
+		record.Scene = &drawthings.StoryboardScene{Mood: mood}
+		manifest.Put(record)
+		imageFiles = append(imageFiles, imageFile)
+	}
+	if err := manifest.Save(dir); err != nil {
+		t.Fatalf("保存清单失败: %v", err)
+	}
+
+	planner := newTransitionPlanner(transitionOptions{
+		Policy:   transitionPolicyMood,
+		Moods:    map[string]string{"紧张": "淡入淡出"},
+		Duration: 500000,
+	}, imageFiles)
+	slots := []imageSlot{{0, 3000000}, {3000000, 6000000}, {6000000, 9000000}}
+
+	// 切换到紧张的分镜时使用淡入淡出，其余没有匹配且没有默认转场，硬切
+	if transition := planner.plan(0, slots); transition == nil || transition.Name != "淡入淡出" {
+		t.Errorf("紧张氛围应匹配淡入淡出: %+v", transition)
+	}
+	if transition := planner.plan(1, slots); transition != nil {
+		t.Errorf("没有匹配的氛围应硬切: %+v", transition)
+	}
+}
+
+func TestTransitionPlannerRandomUsesAllowlist(t *testing.T) {
+	planner := newTransitionPlanner(transitionOptions{Policy: transitionPolicyRandom, Allowlist: []string{"淡入淡出", "missing"}, Seed: 7, Duration: 500000}, nil)
+	if planner == nil || len(planner.pool) != 1 {
+		t.Fatalf("应只保留允许列表中存在的转场")
+	}
+	slots := []imageSlot{{0, 3000000}, {3000000, 6000000}}
+	if transition := planner.plan(0, slots); transition == nil || transition.Name != "淡入淡出" {
+		t.Errorf("应从允许列表中选择转场: %+v", transition)
+	}
+}
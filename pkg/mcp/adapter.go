@@ -177,6 +177,14 @@ func (a *MCPAdapter) GetAvailableTools() []string {
 		"generate_image_from_image",
 		"generate_images_from_chapter",
 		"generate_images_from_chapter_with_ai_prompt",
+		"translate_subtitles",
+		"regenerate_scene_image",
+		"generate_episode_cover",
+		"export_timeline",
+		"import_timeline",
+		"render_timeline",
+		"render_animatic",
+		"lint_draft",
 	}
 
 	return tools
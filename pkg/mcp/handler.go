@@ -6,14 +6,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 
 	aegisub "novel-video-workflow/pkg/tools/aegisub"
+	"novel-video-workflow/pkg/tools/cover"
 	drawthings "novel-video-workflow/pkg/tools/drawthings"
 	"novel-video-workflow/pkg/tools/file"
 	"novel-video-workflow/pkg/tools/indextts2"
+	"novel-video-workflow/pkg/tools/subtitle"
 	"novel-video-workflow/pkg/workflow"
 
 	mcp "github.com/mark3labs/mcp-go/mcp"
@@ -74,14 +77,18 @@ func (h *Handler) RegisterTools() {
 	h.server.AddTool(fileSplitNovelTool, h.handleFileSplitNovelIntoChapters)
 	h.toolNames = append(h.toolNames, "file_split_novel_into_chapters")
 
+	// 风格预设参数说明，列出当前可用的预设名称
+	styleDescription := fmt.Sprintf("Style preset name (%s); defaults to image.style_preset in config.yaml",
+		strings.Join(drawthings.DefaultStyleRegistry().Names(), ", "))
+
 	// Register generate_image_from_text tool - DrawThings文生图工具
 	generateImageFromTextTool := mcp.NewTool("generate_image_from_text",
-		mcp.WithDescription("Generate image from text using DrawThings API with suspense style"),
+		mcp.WithDescription("Generate image from text using DrawThings API with a named style preset"),
 		mcp.WithString("text", mcp.Required(), mcp.Description("The text to generate image from")),
 		mcp.WithString("output_file", mcp.Required(), mcp.Description("Output image file path")),
-		mcp.WithNumber("width", mcp.Description("Image width"), mcp.DefaultNumber(float64(512))),
-		mcp.WithNumber("height", mcp.Description("Image height"), mcp.DefaultNumber(float64(896))),
-		mcp.WithBoolean("is_suspense", mcp.Description("Apply suspense style"), mcp.DefaultBool(true)),
+		mcp.WithNumber("width", mcp.Description("Image width, defaults to the style preset size")),
+		mcp.WithNumber("height", mcp.Description("Image height, defaults to the style preset size")),
+		mcp.WithString("style", mcp.Description(styleDescription)),
 	)
 
 	h.server.AddTool(generateImageFromTextTool, h.handleGenerateImageFromText)
@@ -89,13 +96,13 @@ func (h *Handler) RegisterTools() {
 
 	// Register generate_image_from_image tool - DrawThings图生图工具
 	generateImageFromImageTool := mcp.NewTool("generate_image_from_image",
-		mcp.WithDescription("Generate image from reference image using DrawThings API with suspense style"),
+		mcp.WithDescription("Generate image from reference image using DrawThings API with a named style preset"),
 		mcp.WithString("init_image_path", mcp.Required(), mcp.Description("Reference image path for img2img")),
 		mcp.WithString("text", mcp.Required(), mcp.Description("The text to guide image generation")),
 		mcp.WithString("output_file", mcp.Required(), mcp.Description("Output image file path")),
-		mcp.WithNumber("width", mcp.Description("Image width"), mcp.DefaultNumber(float64(512))),
-		mcp.WithNumber("height", mcp.Description("Image height"), mcp.DefaultNumber(float64(896))),
-		mcp.WithBoolean("is_suspense", mcp.Description("Apply suspense style"), mcp.DefaultBool(true)),
+		mcp.WithNumber("width", mcp.Description("Image width, defaults to the style preset size")),
+		mcp.WithNumber("height", mcp.Description("Image height, defaults to the style preset size")),
+		mcp.WithString("style", mcp.Description(styleDescription)),
 	)
 
 	h.server.AddTool(generateImageFromImageTool, h.handleGenerateImageFromImage)
@@ -103,12 +110,13 @@ func (h *Handler) RegisterTools() {
 
 	// Register generate_images_from_chapter tool - DrawThings章节文生图工具
 	generateImagesFromChapterTool := mcp.NewTool("generate_images_from_chapter",
-		mcp.WithDescription("Generate images from chapter text using DrawThings API with suspense style"),
+		mcp.WithDescription("Generate images from chapter text using DrawThings API with a named style preset"),
 		mcp.WithString("chapter_text", mcp.Required(), mcp.Description("The chapter text to generate images from")),
 		mcp.WithString("output_dir", mcp.Required(), mcp.Description("Output directory for generated images")),
-		mcp.WithNumber("width", mcp.Description("Image width"), mcp.DefaultNumber(float64(512))),
-		mcp.WithNumber("height", mcp.Description("Image height"), mcp.DefaultNumber(float64(896))),
-		mcp.WithBoolean("is_suspense", mcp.Description("Apply suspense style"), mcp.DefaultBool(true)),
+		mcp.WithNumber("width", mcp.Description("Image width, defaults to the style preset size")),
+		mcp.WithNumber("height", mcp.Description("Image height, defaults to the style preset size")),
+		mcp.WithString("style", mcp.Description(styleDescription)),
+		mcp.WithString("character_bible", mcp.Description("Optional path to characters.yaml; descriptors of mentioned characters are injected into every prompt")),
 	)
 
 	h.server.AddTool(generateImagesFromChapterTool, h.handleGenerateImagesFromChapter)
@@ -116,17 +124,114 @@ func (h *Handler) RegisterTools() {
 
 	// Register generate_images_from_chapter_with_ai_prompt tool - DrawThings章节文生图工具(使用AI生成提示词)
 	generateImagesFromChapterWithAIPromptTool := mcp.NewTool("generate_images_from_chapter_with_ai_prompt",
-		mcp.WithDescription("Generate images from chapter text using AI-generated prompts with DrawThings API and suspense style"),
+		mcp.WithDescription("Generate images from chapter text using AI-generated prompts with DrawThings API and a named style preset"),
 		mcp.WithString("chapter_text", mcp.Required(), mcp.Description("The chapter text to generate images from")),
 		mcp.WithString("output_dir", mcp.Required(), mcp.Description("Output directory for generated images")),
-		mcp.WithNumber("width", mcp.Description("Image width"), mcp.DefaultNumber(float64(512))),
-		mcp.WithNumber("height", mcp.Description("Image height"), mcp.DefaultNumber(float64(896))),
-		mcp.WithBoolean("is_suspense", mcp.Description("Apply suspense style"), mcp.DefaultBool(true)),
+		mcp.WithNumber("width", mcp.Description("Image width, defaults to the style preset size")),
+		mcp.WithNumber("height", mcp.Description("Image height, defaults to the style preset size")),
+		mcp.WithString("style", mcp.Description(styleDescription)),
+		mcp.WithString("character_bible", mcp.Description("Optional path to characters.yaml; descriptors of mentioned characters are injected into every prompt")),
 	)
 
 	h.server.AddTool(generateImagesFromChapterWithAIPromptTool, h.handleGenerateImagesFromChapterWithAIPrompt)
 	h.toolNames = append(h.toolNames, "generate_images_from_chapter_with_ai_prompt")
 
+	// Register translate_subtitles tool - 字幕翻译工具(双语字幕)
+	translateSubtitlesTool := mcp.NewTool("translate_subtitles",
+		mcp.WithDescription("Translate an SRT subtitle file in batches with Ollama and write <name>.<lang>.srt/.vtt for bilingual subtitles"),
+		mcp.WithString("srt_file", mcp.Required(), mcp.Description("The source SRT subtitle file path")),
+		mcp.WithString("target_language", mcp.Description("Target language code, e.g. en, ja, ko (defaults to subtitle.translation.target_language)")),
+		mcp.WithNumber("batch_size", mcp.Description("Number of cues per translation request"), mcp.DefaultNumber(float64(20))),
+	)
+
+	h.server.AddTool(translateSubtitlesTool, h.handleTranslateSubtitles)
+	h.toolNames = append(h.toolNames, "translate_subtitles")
+
+	// Register regenerate_scene_image tool - 根据生成清单重新生成单张分镜图像
+	regenerateSceneImageTool := mcp.NewTool("regenerate_scene_image",
+		mcp.WithDescription("Regenerate a single scene image recorded in images_manifest.json, reusing its prompt, seed, model and parameters unless overridden"),
+		mcp.WithString("images_dir", mcp.Required(), mcp.Description("The chapter images directory containing images_manifest.json")),
+		mcp.WithNumber("index", mcp.Required(), mcp.Description("Scene index (1-based, matches the image file name)")),
+		mcp.WithNumber("seed", mcp.Description("New seed; -1 for a random seed; omit to reuse the recorded seed")),
+		mcp.WithString("prompt", mcp.Description("New prompt; omit to reuse the recorded prompt")),
+		mcp.WithString("negative_prompt", mcp.Description("New negative prompt; omit to reuse the recorded one")),
+	)
+
+	h.server.AddTool(regenerateSceneImageTool, h.handleRegenerateSceneImage)
+	h.toolNames = append(h.toolNames, "regenerate_scene_image")
+
+	// Register generate_episode_cover tool - 剧集封面生成工具
+	generateEpisodeCoverTool := mcp.NewTool("generate_episode_cover",
+		mcp.WithDescription("Render episode covers (novel title, episode number and hook line over a key scene image) in platform sizes 9:16, 3:4 and 16:9"),
+		mcp.WithString("images_dir", mcp.Description("The chapter images directory; the key scene is chosen from images_manifest.json")),
+		mcp.WithString("image", mcp.Description("Key scene image path; omit to choose one from images_dir")),
+		mcp.WithString("title", mcp.Description("Novel title; defaults to the parent directory name of images_dir")),
+		mcp.WithNumber("episode", mcp.Description("Episode number; defaults to the chapter number")),
+		mcp.WithString("hook", mcp.Description("Hook line; defaults to the first sentence of the key scene's source text")),
+		mcp.WithString("template", mcp.Description("Cover template name, e.g. "+strings.Join(cover.TemplateNames(), ", "))),
+		mcp.WithString("sizes", mcp.Description("Comma separated size presets, e.g. 9:16,3:4,16:9 (defaults to cover.sizes)")),
+		mcp.WithString("output_dir", mcp.Description("Output directory; defaults to <images_dir>/covers")),
+	)
+
+	h.server.AddTool(generateEpisodeCoverTool, h.handleGenerateEpisodeCover)
+	h.toolNames = append(h.toolNames, "generate_episode_cover")
+
+	// Register export_timeline tool - 章节时间线导出到其他剪辑软件
+	exportTimelineTool := mcp.NewTool("export_timeline",
+		mcp.WithDescription("Export a chapter timeline (timeline.json; built from the chapter's audio, images and subtitles when missing) for other editors such as DaVinci Resolve, Final Cut Pro, Premiere Pro, Kdenlive and Shotcut"),
+		mcp.WithString("chapter_dir", mcp.Required(), mcp.Description("The chapter directory containing audio, images and subtitles")),
+		mcp.WithString("format", mcp.Description("Export format: "+strings.Join(workflow.TimelineFormats(), ", ")+" (default otio)")),
+		mcp.WithString("output", mcp.Description("Output file path; defaults to <chapter_dir>/<chapter name>.<ext>")),
+		mcp.WithBoolean("rebuild", mcp.Description("Rebuild timeline.json from the chapter assets before exporting")),
+	)
+
+	h.server.AddTool(exportTimelineTool, h.handleExportTimeline)
+	h.toolNames = append(h.toolNames, "export_timeline")
+
+	// Register import_timeline tool - 读回剪辑软件修改后的时间线
+	importTimelineTool := mcp.NewTool("import_timeline",
+		mcp.WithDescription("Read an edited timeline file (.otio) back into the chapter's timeline.json"),
+		mcp.WithString("file", mcp.Required(), mcp.Description("The edited timeline file")),
+		mcp.WithString("chapter_dir", mcp.Description("Chapter directory to write timeline.json into; defaults to the file's directory")),
+	)
+
+	h.server.AddTool(importTimelineTool, h.handleImportTimeline)
+	h.toolNames = append(h.toolNames, "import_timeline")
+
+	// Register render_timeline tool - 在服务器上把章节时间线渲染成最终视频
+	renderTimelineTool := mcp.NewTool("render_timeline",
+		mcp.WithDescription("Render a chapter timeline to the final video with ffmpeg (filter_complex with motion, transitions, burned-in subtitles and BGM ducking) or with melt from an MLT project"),
+		mcp.WithString("chapter_dir", mcp.Required(), mcp.Description("The chapter directory containing audio, images and subtitles")),
+		mcp.WithString("engine", mcp.Description("Render engine: ffmpeg or melt (default ffmpeg)")),
+		mcp.WithString("output", mcp.Description("Output video path; defaults to <chapter_dir>/<chapter name>.mp4")),
+		mcp.WithBoolean("rebuild", mcp.Description("Rebuild timeline.json from the chapter assets before rendering")),
+	)
+
+	h.server.AddTool(renderTimelineTool, h.handleRenderTimeline)
+	h.toolNames = append(h.toolNames, "render_timeline")
+
+	// Register render_animatic tool - 不依赖剪映和 ffmpeg 的分镜预览
+	renderAnimaticTool := mcp.NewTool("render_animatic",
+		mcp.WithDescription("Render a low-resolution storyboard animatic of a chapter timeline in pure Go (image motion, transitions, burned subtitles, shot numbers and timecode) as an animated GIF or PNG sequence next to the narration audio"),
+		mcp.WithString("chapter_dir", mcp.Required(), mcp.Description("The chapter directory containing audio, images and subtitles")),
+		mcp.WithString("format", mcp.Description("Output format: gif or png (default video.animatic.format, gif)")),
+		mcp.WithBoolean("rebuild", mcp.Description("Rebuild timeline.json from the chapter assets before rendering")),
+	)
+
+	h.server.AddTool(renderAnimaticTool, h.handleRenderAnimatic)
+	h.toolNames = append(h.toolNames, "render_animatic")
+
+	// Register lint_draft tool - 在剪映中打开之前检查草稿
+	lintDraftTool := mcp.NewTool("lint_draft",
+		mcp.WithDescription("Check a CapCut draft before opening it: segment material references, media files on disk, overlapping segments, segment durations against material lengths, canvas/fps settings and the keys required by the target CapCut version"),
+		mcp.WithString("draft", mcp.Required(), mcp.Description("The draft file (draft_info.json or draft_content.json) or the draft directory")),
+		mcp.WithString("app_version", mcp.Description("Target CapCut version such as 3.4.1; defaults to the draft's platform.app_version")),
+		mcp.WithBoolean("skip_files", mcp.Description("Do not check that media files exist, e.g. for drafts copied from another machine")),
+	)
+
+	h.server.AddTool(lintDraftTool, h.handleLintDraft)
+	h.toolNames = append(h.toolNames, "lint_draft")
+
 	h.logger.Info("MCP tools registered",
 		zap.Int("tool_count", len(h.toolNames)))
 }
@@ -698,9 +803,12 @@ func (h *Handler) handleGenerateImageFromText(ctx context.Context, request mcp.C
 	}
 
 	// 获取可选参数
-	width := int(request.GetInt("width", 512))
-	height := int(request.GetInt("height", 896))
-	isSuspense := request.GetBool("is_suspense", true)
+	preset, err := drawthings.ResolveStylePreset(request.GetString("style", ""))
+	if err != nil {
+		h.logger.Error("Invalid style parameter", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid style: %v", err)), nil
+	}
+	width, height := preset.Size(int(request.GetInt("width", 0)), int(request.GetInt("height", 0)))
 
 	// 确保输出目录存在
 	outputDir := filepath.Dir(outputFile)
@@ -709,10 +817,11 @@ func (h *Handler) handleGenerateImageFromText(ctx context.Context, request mcp.C
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to create output directory: %v", err)), nil
 	}
 
-	// 使用DrawThings客户端生成图像
-	client := drawthings.NewDrawThingsClient(h.logger, "http://localhost:7861")
-
-	err = client.GenerateImageFromText(text, outputFile, width, height, isSuspense)
+	// 使用 image.engine 配置的图像后端生成图像
+	backend, err := drawthings.NewImageBackendFromConfig(h.logger)
+	if err == nil {
+		_, err = drawthings.GenerateImageWithBackend(ctx, backend, text, outputFile, width, height, preset, -1)
+	}
 	if err != nil {
 		h.logger.Error("Failed to generate image from text", zap.Error(err))
 		response := map[string]interface{}{
@@ -738,7 +847,7 @@ func (h *Handler) handleGenerateImageFromText(ctx context.Context, request mcp.C
 		"text":        text,
 		"width":       width,
 		"height":      height,
-		"is_suspense": isSuspense,
+		"style":       preset.Name,
 		"tool":        "drawthings_txt2img",
 	}
 
@@ -766,9 +875,12 @@ func (h *Handler) HandleGenerateImageFromTextDirect(request *MockRequest) (map[s
 	}
 
 	// 获取可选参数
-	width := request.GetInt("width", 512)
-	height := request.GetInt("height", 896)
-	isSuspense := request.GetBool("is_suspense", true)
+	preset, err := drawthings.ResolveStylePreset(request.GetString("style", ""))
+	if err != nil {
+		h.logger.Error("Invalid style parameter", zap.Error(err))
+		return nil, fmt.Errorf("invalid style: %v", err)
+	}
+	width, height := preset.Size(request.GetInt("width", 0), request.GetInt("height", 0))
 
 	// 确保输出目录存在
 	outputDir := filepath.Dir(outputFile)
@@ -777,10 +889,11 @@ func (h *Handler) HandleGenerateImageFromTextDirect(request *MockRequest) (map[s
 		return nil, fmt.Errorf("failed to create output directory: %v", err)
 	}
 
-	// 使用DrawThings客户端生成图像
-	client := drawthings.NewDrawThingsClient(h.logger, "http://localhost:7861")
-
-	err = client.GenerateImageFromText(text, outputFile, width, height, isSuspense)
+	// 使用 image.engine 配置的图像后端生成图像
+	backend, err := drawthings.NewImageBackendFromConfig(h.logger)
+	if err == nil {
+		_, err = drawthings.GenerateImageWithBackend(context.Background(), backend, text, outputFile, width, height, preset, -1)
+	}
 	if err != nil {
 		h.logger.Error("Failed to generate image from text", zap.Error(err))
 		response := map[string]interface{}{
@@ -800,7 +913,7 @@ func (h *Handler) HandleGenerateImageFromTextDirect(request *MockRequest) (map[s
 		"text":        text,
 		"width":       width,
 		"height":      height,
-		"is_suspense": isSuspense,
+		"style":       preset.Name,
 		"tool":        "drawthings_txt2img",
 	}
 
@@ -828,9 +941,12 @@ func (h *Handler) handleGenerateImageFromImage(ctx context.Context, request mcp.
 	}
 
 	// 获取可选参数
-	width := int(request.GetInt("width", 512))
-	height := int(request.GetInt("height", 896))
-	isSuspense := request.GetBool("is_suspense", true)
+	preset, err := drawthings.ResolveStylePreset(request.GetString("style", ""))
+	if err != nil {
+		h.logger.Error("Invalid style parameter", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid style: %v", err)), nil
+	}
+	width, height := preset.Size(int(request.GetInt("width", 0)), int(request.GetInt("height", 0)))
 
 	// 验证参考图像文件是否存在
 	if _, err := os.Stat(initImagePath); os.IsNotExist(err) {
@@ -857,10 +973,11 @@ func (h *Handler) handleGenerateImageFromImage(ctx context.Context, request mcp.
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to create output directory: %v", err)), nil
 	}
 
-	// 使用DrawThings客户端生成图像
-	client := drawthings.NewDrawThingsClient(h.logger, "http://localhost:7861")
-
-	err = client.GenerateImageFromImage(initImagePath, text, outputFile, width, height, isSuspense)
+	// 使用 image.engine 配置的图像后端生成图像
+	backend, err := drawthings.NewImageBackendFromConfig(h.logger)
+	if err == nil {
+		err = drawthings.GenerateImageFromImageWithBackend(ctx, backend, initImagePath, text, outputFile, width, height, preset)
+	}
 	if err != nil {
 		h.logger.Error("Failed to generate image from reference image", zap.Error(err))
 		response := map[string]interface{}{
@@ -888,7 +1005,7 @@ func (h *Handler) handleGenerateImageFromImage(ctx context.Context, request mcp.
 		"text":            text,
 		"width":           width,
 		"height":          height,
-		"is_suspense":     isSuspense,
+		"style":           preset.Name,
 		"tool":            "drawthings_img2img",
 	}
 
@@ -922,9 +1039,12 @@ func (h *Handler) HandleGenerateImageFromImageDirect(request *MockRequest) (map[
 	}
 
 	// 获取可选参数
-	width := request.GetInt("width", 512)
-	height := request.GetInt("height", 896)
-	isSuspense := request.GetBool("is_suspense", true)
+	preset, err := drawthings.ResolveStylePreset(request.GetString("style", ""))
+	if err != nil {
+		h.logger.Error("Invalid style parameter", zap.Error(err))
+		return nil, fmt.Errorf("invalid style: %v", err)
+	}
+	width, height := preset.Size(request.GetInt("width", 0), request.GetInt("height", 0))
 
 	// 验证参考图像文件是否存在
 	if _, err := os.Stat(initImagePath); os.IsNotExist(err) {
@@ -945,10 +1065,11 @@ func (h *Handler) HandleGenerateImageFromImageDirect(request *MockRequest) (map[
 		return nil, fmt.Errorf("failed to create output directory: %v", err)
 	}
 
-	// 使用DrawThings客户端生成图像
-	client := drawthings.NewDrawThingsClient(h.logger, "http://localhost:7861")
-
-	err = client.GenerateImageFromImage(initImagePath, text, outputFile, width, height, isSuspense)
+	// 使用 image.engine 配置的图像后端生成图像
+	backend, err := drawthings.NewImageBackendFromConfig(h.logger)
+	if err == nil {
+		err = drawthings.GenerateImageFromImageWithBackend(context.Background(), backend, initImagePath, text, outputFile, width, height, preset)
+	}
 	if err != nil {
 		h.logger.Error("Failed to generate image from reference image", zap.Error(err))
 		response := map[string]interface{}{
@@ -970,7 +1091,7 @@ func (h *Handler) HandleGenerateImageFromImageDirect(request *MockRequest) (map[
 		"text":            text,
 		"width":           width,
 		"height":          height,
-		"is_suspense":     isSuspense,
+		"style":           preset.Name,
 		"tool":            "drawthings_img2img",
 	}
 
@@ -982,6 +1103,19 @@ func (h *Handler) GetToolNames() []string {
 	return h.toolNames
 }
 
+// loadCharacterBible 加载角色设定集，未指定或加载失败时返回nil，不影响图像生成
+func (h *Handler) loadCharacterBible(path string) *drawthings.CharacterBible {
+	if path == "" {
+		return nil
+	}
+	bible, err := drawthings.LoadCharacterBible(path)
+	if err != nil {
+		h.logger.Warn("加载角色设定集失败，将不注入角色描述", zap.String("path", path), zap.Error(err))
+		return nil
+	}
+	return bible
+}
+
 // handleGenerateImagesFromChapter generates images from chapter text using DrawThings API
 func (h *Handler) handleGenerateImagesFromChapter(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	chapterText, err := request.RequireString("chapter_text")
@@ -997,9 +1131,12 @@ func (h *Handler) handleGenerateImagesFromChapter(ctx context.Context, request m
 	}
 
 	// 获取可选参数
-	width := int(request.GetInt("width", 512))
-	height := int(request.GetInt("height", 896))
-	isSuspense := request.GetBool("is_suspense", true)
+	preset, err := drawthings.ResolveStylePreset(request.GetString("style", ""))
+	if err != nil {
+		h.logger.Error("Invalid style parameter", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid style: %v", err)), nil
+	}
+	width, height := preset.Size(int(request.GetInt("width", 0)), int(request.GetInt("height", 0)))
 
 	// 确保输出目录存在
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
@@ -1009,8 +1146,9 @@ func (h *Handler) handleGenerateImagesFromChapter(ctx context.Context, request m
 
 	// 使用章节图像生成器
 	generator := drawthings.NewChapterImageGenerator(h.logger)
+	generator.CharacterBible = h.loadCharacterBible(request.GetString("character_bible", ""))
 
-	results, err := generator.GenerateImagesFromChapter(chapterText, outputDir, width, height, isSuspense)
+	results, err := generator.GenerateImagesFromChapterContext(ctx, chapterText, outputDir, width, height, preset)
 	if err != nil {
 		h.logger.Error("Failed to generate images from chapter", zap.Error(err))
 		response := map[string]interface{}{
@@ -1046,7 +1184,7 @@ func (h *Handler) handleGenerateImagesFromChapter(ctx context.Context, request m
 		"paragraphs":            paragraphs,
 		"width":                 width,
 		"height":                height,
-		"is_suspense":           isSuspense,
+		"style":                 preset.Name,
 		"tool":                  "drawthings_chapter_txt2img",
 	}
 
@@ -1074,9 +1212,12 @@ func (h *Handler) HandleGenerateImagesFromChapterDirect(request *MockRequest) (m
 	}
 
 	// 获取可选参数
-	width := request.GetInt("width", 512)
-	height := request.GetInt("height", 896)
-	isSuspense := request.GetBool("is_suspense", true)
+	preset, err := drawthings.ResolveStylePreset(request.GetString("style", ""))
+	if err != nil {
+		h.logger.Error("Invalid style parameter", zap.Error(err))
+		return nil, fmt.Errorf("invalid style: %v", err)
+	}
+	width, height := preset.Size(request.GetInt("width", 0), request.GetInt("height", 0))
 
 	// 确保输出目录存在
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
@@ -1086,8 +1227,9 @@ func (h *Handler) HandleGenerateImagesFromChapterDirect(request *MockRequest) (m
 
 	// 使用章节图像生成器
 	generator := drawthings.NewChapterImageGenerator(h.logger)
+	generator.CharacterBible = h.loadCharacterBible(request.GetString("character_bible", ""))
 
-	results, err := generator.GenerateImagesFromChapter(chapterText, outputDir, width, height, isSuspense)
+	results, err := generator.GenerateImagesFromChapter(chapterText, outputDir, width, height, preset)
 	if err != nil {
 		h.logger.Error("Failed to generate images from chapter", zap.Error(err))
 		response := map[string]interface{}{
@@ -1117,7 +1259,7 @@ func (h *Handler) HandleGenerateImagesFromChapterDirect(request *MockRequest) (m
 		"paragraphs":            paragraphs,
 		"width":                 width,
 		"height":                height,
-		"is_suspense":           isSuspense,
+		"style":                 preset.Name,
 		"tool":                  "drawthings_chapter_txt2img",
 	}
 
@@ -1139,9 +1281,12 @@ func (h *Handler) handleGenerateImagesFromChapterWithAIPrompt(ctx context.Contex
 	}
 
 	// 获取可选参数
-	width := int(request.GetInt("width", 512))
-	height := int(request.GetInt("height", 896))
-	isSuspense := request.GetBool("is_suspense", true)
+	preset, err := drawthings.ResolveStylePreset(request.GetString("style", ""))
+	if err != nil {
+		h.logger.Error("Invalid style parameter", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid style: %v", err)), nil
+	}
+	width, height := preset.Size(int(request.GetInt("width", 0)), int(request.GetInt("height", 0)))
 
 	// 确保输出目录存在
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
@@ -1151,8 +1296,9 @@ func (h *Handler) handleGenerateImagesFromChapterWithAIPrompt(ctx context.Contex
 
 	// 使用章节图像生成器（使用Ollama生成提示词）
 	generator := drawthings.NewChapterImageGenerator(h.logger)
+	generator.CharacterBible = h.loadCharacterBible(request.GetString("character_bible", ""))
 
-	results, err := generator.GenerateImagesFromChapter(chapterText, outputDir, width, height, isSuspense)
+	results, err := generator.GenerateImagesFromChapterContext(ctx, chapterText, outputDir, width, height, preset)
 	if err != nil {
 		h.logger.Error("Failed to generate images from chapter with AI prompts", zap.Error(err))
 		response := map[string]interface{}{
@@ -1192,7 +1338,7 @@ func (h *Handler) handleGenerateImagesFromChapterWithAIPrompt(ctx context.Contex
 		"prompts":               prompts,
 		"width":                 width,
 		"height":                height,
-		"is_suspense":           isSuspense,
+		"style":                 preset.Name,
 		"tool":                  "drawthings_chapter_txt2img_with_ai_prompt",
 	}
 
@@ -1220,9 +1366,12 @@ func (h *Handler) HandleGenerateImagesFromChapterWithAIPromptDirect(request *Moc
 	}
 
 	// 获取可选参数
-	width := request.GetInt("width", 512)
-	height := request.GetInt("height", 896)
-	isSuspense := request.GetBool("is_suspense", true)
+	preset, err := drawthings.ResolveStylePreset(request.GetString("style", ""))
+	if err != nil {
+		h.logger.Error("Invalid style parameter", zap.Error(err))
+		return nil, fmt.Errorf("invalid style: %v", err)
+	}
+	width, height := preset.Size(request.GetInt("width", 0), request.GetInt("height", 0))
 
 	// 确保输出目录存在
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
@@ -1232,8 +1381,9 @@ func (h *Handler) HandleGenerateImagesFromChapterWithAIPromptDirect(request *Moc
 
 	// 使用章节图像生成器（使用Ollama生成提示词）
 	generator := drawthings.NewChapterImageGenerator(h.logger)
+	generator.CharacterBible = h.loadCharacterBible(request.GetString("character_bible", ""))
 
-	results, err := generator.GenerateImagesFromChapter(chapterText, outputDir, width, height, isSuspense)
+	results, err := generator.GenerateImagesFromChapter(chapterText, outputDir, width, height, preset)
 	if err != nil {
 		h.logger.Error("Failed to generate images from chapter with AI prompts", zap.Error(err))
 		response := map[string]interface{}{
@@ -1267,13 +1417,512 @@ func (h *Handler) HandleGenerateImagesFromChapterWithAIPromptDirect(request *Moc
 		"prompts":               prompts,
 		"width":                 width,
 		"height":                height,
-		"is_suspense":           isSuspense,
+		"style":                 preset.Name,
 		"tool":                  "drawthings_chapter_txt2img_with_ai_prompt",
 	}
 
 	return response, nil
 }
 
+// handleTranslateSubtitles translates an SRT file into a second-language SRT/VTT
+func (h *Handler) handleTranslateSubtitles(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	srtFile, err := request.RequireString("srt_file")
+	if err != nil {
+		h.logger.Error("Missing srt_file parameter", zap.Error(err))
+		return mcp.NewToolResultError("Missing required parameter: srt_file"), nil
+	}
+
+	targetLanguage := request.GetString("target_language", "")
+	batchSize := int(request.GetInt("batch_size", 0))
+
+	response := h.translateSubtitles(srtFile, targetLanguage, batchSize)
+
+	responseJSON, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		h.logger.Error("Failed to serialize response", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to serialize response: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(responseJSON)), nil
+}
+
+// HandleTranslateSubtitlesDirect 直接调用版本
+func (h *Handler) HandleTranslateSubtitlesDirect(request *MockRequest) (map[string]interface{}, error) {
+	srtFile, err := request.RequireString("srt_file")
+	if err != nil {
+		h.logger.Error("Missing srt_file parameter", zap.Error(err))
+		return nil, fmt.Errorf("missing required parameter: srt_file")
+	}
+
+	targetLanguage := request.GetString("target_language", "")
+	batchSize := request.GetInt("batch_size", 0)
+
+	return h.translateSubtitles(srtFile, targetLanguage, batchSize), nil
+}
+
+// translateSubtitles 翻译字幕文件并组装响应
+func (h *Handler) translateSubtitles(srtFile, targetLanguage string, batchSize int) map[string]interface{} {
+	translator := subtitle.NewSubtitleTranslator(h.logger, nil, targetLanguage)
+	if batchSize > 0 {
+		translator.BatchSize = batchSize
+	}
+
+	result, err := translator.TranslateSrtFile(srtFile)
+	if err != nil {
+		h.logger.Error("Failed to translate subtitles", zap.Error(err))
+		return map[string]interface{}{
+			"success":         false,
+			"error":           fmt.Sprintf("Failed to translate subtitles: %v", err),
+			"srt_file":        srtFile,
+			"target_language": translator.TargetLanguage,
+		}
+	}
+
+	return map[string]interface{}{
+		"success":         true,
+		"srt_file":        result.SourceFile,
+		"output_srt":      result.SrtFile,
+		"output_vtt":      result.VttFile,
+		"target_language": result.Language,
+		"cue_count":       result.CueCount,
+		"untranslated":    result.Untranslated,
+		"tool":            "ollama_subtitle_translator",
+	}
+}
+
+// handleRegenerateSceneImage regenerates one image from the chapter images manifest
+func (h *Handler) handleRegenerateSceneImage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	imagesDir, err := request.RequireString("images_dir")
+	if err != nil {
+		h.logger.Error("Missing images_dir parameter", zap.Error(err))
+		return mcp.NewToolResultError("Missing required parameter: images_dir"), nil
+	}
+
+	index, err := request.RequireInt("index")
+	if err != nil {
+		h.logger.Error("Missing index parameter", zap.Error(err))
+		return mcp.NewToolResultError("Missing required parameter: index"), nil
+	}
+
+	opts := drawthings.RegenerateOptions{
+		Prompt:         request.GetString("prompt", ""),
+		NegativePrompt: request.GetString("negative_prompt", ""),
+	}
+	if _, exists := request.GetArguments()["seed"]; exists {
+		seed := request.GetInt("seed", -1)
+		opts.Seed = &seed
+	}
+
+	response := h.regenerateSceneImage(imagesDir, index, opts)
+
+	responseJSON, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		h.logger.Error("Failed to serialize response", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to serialize response: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(responseJSON)), nil
+}
+
+// HandleRegenerateSceneImageDirect 直接调用版本
+func (h *Handler) HandleRegenerateSceneImageDirect(request *MockRequest) (map[string]interface{}, error) {
+	imagesDir, err := request.RequireString("images_dir")
+	if err != nil {
+		h.logger.Error("Missing images_dir parameter", zap.Error(err))
+		return nil, fmt.Errorf("missing required parameter: images_dir")
+	}
+
+	index := request.GetInt("index", 0)
+	if index <= 0 {
+		return nil, fmt.Errorf("missing required parameter: index")
+	}
+
+	opts := drawthings.RegenerateOptions{
+		Prompt:         request.GetString("prompt", ""),
+		NegativePrompt: request.GetString("negative_prompt", ""),
+	}
+	if _, exists := request.Params["seed"]; exists {
+		seed := request.GetInt("seed", -1)
+		opts.Seed = &seed
+	}
+
+	return h.regenerateSceneImage(imagesDir, index, opts), nil
+}
+
+// regenerateSceneImage 重新生成单张分镜图像并组装响应
+func (h *Handler) regenerateSceneImage(imagesDir string, index int, opts drawthings.RegenerateOptions) map[string]interface{} {
+	// 使用 image.engine 配置的图像后端重新生成
+	backend, err := drawthings.NewImageBackendFromConfig(h.logger)
+	var record *drawthings.ImageRecord
+	if err == nil {
+		record, err = drawthings.RegenerateImageWithBackend(context.Background(), backend, imagesDir, index, opts)
+	}
+	if err != nil {
+		h.logger.Error("Failed to regenerate scene image", zap.Error(err))
+		return map[string]interface{}{
+			"success":    false,
+			"error":      fmt.Sprintf("Failed to regenerate image: %v", err),
+			"images_dir": imagesDir,
+			"index":      index,
+		}
+	}
+
+	return map[string]interface{}{
+		"success":       true,
+		"images_dir":    imagesDir,
+		"index":         record.Index,
+		"image_file":    filepath.Join(imagesDir, record.ImageFile),
+		"prompt":        record.Parameters.Prompt,
+		"seed":          record.Parameters.Seed,
+		"model":         record.Parameters.Model,
+		"regenerations": record.Regenerations,
+		"tool":          "drawthings_regenerate_scene",
+	}
+}
+
+// handleGenerateEpisodeCover renders episode covers from a key scene image
+func (h *Handler) handleGenerateEpisodeCover(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req := cover.Request{
+		ImagesDir: request.GetString("images_dir", ""),
+		Image:     request.GetString("image", ""),
+		OutputDir: request.GetString("output_dir", ""),
+		Title:     request.GetString("title", ""),
+		Episode:   request.GetInt("episode", 0),
+		Hook:      request.GetString("hook", ""),
+		Template:  request.GetString("template", ""),
+		Sizes:     splitList(request.GetString("sizes", "")),
+	}
+	if req.ImagesDir == "" && req.Image == "" {
+		return mcp.NewToolResultError("Missing required parameter: images_dir or image"), nil
+	}
+
+	response := h.generateEpisodeCover(req)
+
+	responseJSON, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		h.logger.Error("Failed to serialize response", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to serialize response: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(responseJSON)), nil
+}
+
+// HandleGenerateEpisodeCoverDirect 直接调用版本
+func (h *Handler) HandleGenerateEpisodeCoverDirect(request *MockRequest) (map[string]interface{}, error) {
+	req := cover.Request{
+		ImagesDir: request.GetString("images_dir", ""),
+		Image:     request.GetString("image", ""),
+		OutputDir: request.GetString("output_dir", ""),
+		Title:     request.GetString("title", ""),
+		Episode:   request.GetInt("episode", 0),
+		Hook:      request.GetString("hook", ""),
+		Template:  request.GetString("template", ""),
+		Sizes:     splitList(request.GetString("sizes", "")),
+	}
+	if req.ImagesDir == "" && req.Image == "" {
+		return nil, fmt.Errorf("missing required parameter: images_dir or image")
+	}
+
+	return h.generateEpisodeCover(req), nil
+}
+
+// generateEpisodeCover 生成封面并组装响应
+func (h *Handler) generateEpisodeCover(req cover.Request) map[string]interface{} {
+	results, err := cover.Generate(req)
+	if err != nil {
+		h.logger.Error("Failed to generate episode cover", zap.Error(err))
+		return map[string]interface{}{
+			"success":    false,
+			"error":      fmt.Sprintf("Failed to generate cover: %v", err),
+			"images_dir": req.ImagesDir,
+		}
+	}
+	if len(results) > 0 && results[0].Font == "" {
+		h.logger.Warn("没有找到中文字体，封面文字可能无法显示，请配置 image.font_path")
+	}
+
+	return map[string]interface{}{
+		"success":    true,
+		"images_dir": req.ImagesDir,
+		"covers":     results,
+		"tool":       "episode_cover_generator",
+	}
+}
+
+// handleExportTimeline exports a chapter timeline to another editor's format
+func (h *Handler) handleExportTimeline(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chapterDir, err := request.RequireString("chapter_dir")
+	if err != nil {
+		h.logger.Error("Missing chapter_dir parameter", zap.Error(err))
+		return mcp.NewToolResultError("Missing required parameter: chapter_dir"), nil
+	}
+
+	response := h.exportTimeline(chapterDir, request.GetString("format", ""), request.GetString("output", ""), request.GetBool("rebuild", false))
+
+	responseJSON, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		h.logger.Error("Failed to serialize response", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to serialize response: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(responseJSON)), nil
+}
+
+// HandleExportTimelineDirect 直接调用版本
+func (h *Handler) HandleExportTimelineDirect(request *MockRequest) (map[string]interface{}, error) {
+	chapterDir, err := request.RequireString("chapter_dir")
+	if err != nil {
+		h.logger.Error("Missing chapter_dir parameter", zap.Error(err))
+		return nil, fmt.Errorf("missing required parameter: chapter_dir")
+	}
+
+	return h.exportTimeline(chapterDir, request.GetString("format", ""), request.GetString("output", ""), request.GetBool("rebuild", false)), nil
+}
+
+// exportTimeline 导出章节时间线并组装响应
+func (h *Handler) exportTimeline(chapterDir, format, output string, rebuild bool) map[string]interface{} {
+	path, err := h.processor.ExportTimeline(chapterDir, format, output, rebuild)
+	if err != nil {
+		h.logger.Error("Failed to export timeline", zap.Error(err))
+		return map[string]interface{}{
+			"success":     false,
+			"error":       fmt.Sprintf("Failed to export timeline: %v", err),
+			"chapter_dir": chapterDir,
+		}
+	}
+
+	return map[string]interface{}{
+		"success":     true,
+		"chapter_dir": chapterDir,
+		"output":      path,
+		"tool":        "timeline_exporter",
+	}
+}
+
+// handleImportTimeline reads an edited timeline file back into timeline.json
+func (h *Handler) handleImportTimeline(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	file, err := request.RequireString("file")
+	if err != nil {
+		h.logger.Error("Missing file parameter", zap.Error(err))
+		return mcp.NewToolResultError("Missing required parameter: file"), nil
+	}
+
+	response := h.importTimeline(file, request.GetString("chapter_dir", ""))
+
+	responseJSON, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		h.logger.Error("Failed to serialize response", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to serialize response: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(responseJSON)), nil
+}
+
+// HandleImportTimelineDirect 直接调用版本
+func (h *Handler) HandleImportTimelineDirect(request *MockRequest) (map[string]interface{}, error) {
+	file, err := request.RequireString("file")
+	if err != nil {
+		h.logger.Error("Missing file parameter", zap.Error(err))
+		return nil, fmt.Errorf("missing required parameter: file")
+	}
+
+	return h.importTimeline(file, request.GetString("chapter_dir", "")), nil
+}
+
+// importTimeline 读回时间线并组装响应
+func (h *Handler) importTimeline(file, chapterDir string) map[string]interface{} {
+	saved, tl, err := h.processor.ImportTimeline(file, chapterDir)
+	if err != nil {
+		h.logger.Error("Failed to import timeline", zap.Error(err))
+		return map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to import timeline: %v", err),
+			"file":    file,
+		}
+	}
+
+	return map[string]interface{}{
+		"success":  true,
+		"file":     file,
+		"timeline": saved,
+		"tracks":   len(tl.Tracks),
+		"duration": float64(tl.Duration) / 1e6,
+		"tool":     "timeline_importer",
+	}
+}
+
+// handleRenderTimeline renders a chapter timeline to the final video
+func (h *Handler) handleRenderTimeline(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chapterDir, err := request.RequireString("chapter_dir")
+	if err != nil {
+		h.logger.Error("Missing chapter_dir parameter", zap.Error(err))
+		return mcp.NewToolResultError("Missing required parameter: chapter_dir"), nil
+	}
+
+	response := h.renderTimeline(chapterDir, request.GetString("engine", ""), request.GetString("output", ""), request.GetBool("rebuild", false))
+
+	responseJSON, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		h.logger.Error("Failed to serialize response", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to serialize response: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(responseJSON)), nil
+}
+
+// HandleRenderTimelineDirect 直接调用版本
+func (h *Handler) HandleRenderTimelineDirect(request *MockRequest) (map[string]interface{}, error) {
+	chapterDir, err := request.RequireString("chapter_dir")
+	if err != nil {
+		h.logger.Error("Missing chapter_dir parameter", zap.Error(err))
+		return nil, fmt.Errorf("missing required parameter: chapter_dir")
+	}
+
+	return h.renderTimeline(chapterDir, request.GetString("engine", ""), request.GetString("output", ""), request.GetBool("rebuild", false)), nil
+}
+
+// renderTimeline 渲染章节时间线并组装响应
+func (h *Handler) renderTimeline(chapterDir, engine, output string, rebuild bool) map[string]interface{} {
+	video, err := h.processor.RenderTimeline(chapterDir, engine, output, rebuild)
+	if err != nil {
+		h.logger.Error("Failed to render timeline", zap.Error(err))
+		return map[string]interface{}{
+			"success":     false,
+			"error":       fmt.Sprintf("Failed to render timeline: %v", err),
+			"chapter_dir": chapterDir,
+		}
+	}
+
+	if engine == "" {
+		engine = workflow.RenderEngineFFmpeg
+	}
+	return map[string]interface{}{
+		"success":     true,
+		"chapter_dir": chapterDir,
+		"engine":      engine,
+		"output":      video,
+		"tool":        "timeline_renderer",
+	}
+}
+
+// handleRenderAnimatic renders a storyboard animatic of a chapter timeline
+func (h *Handler) handleRenderAnimatic(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chapterDir, err := request.RequireString("chapter_dir")
+	if err != nil {
+		h.logger.Error("Missing chapter_dir parameter", zap.Error(err))
+		return mcp.NewToolResultError("Missing required parameter: chapter_dir"), nil
+	}
+
+	response := h.renderAnimatic(chapterDir, request.GetString("format", ""), request.GetBool("rebuild", false))
+
+	responseJSON, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		h.logger.Error("Failed to serialize response", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to serialize response: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(responseJSON)), nil
+}
+
+// HandleRenderAnimaticDirect 直接调用版本
+func (h *Handler) HandleRenderAnimaticDirect(request *MockRequest) (map[string]interface{}, error) {
+	chapterDir, err := request.RequireString("chapter_dir")
+	if err != nil {
+		h.logger.Error("Missing chapter_dir parameter", zap.Error(err))
+		return nil, fmt.Errorf("missing required parameter: chapter_dir")
+	}
+
+	return h.renderAnimatic(chapterDir, request.GetString("format", ""), request.GetBool("rebuild", false)), nil
+}
+
+// renderAnimatic 生成分镜预览并组装响应
+func (h *Handler) renderAnimatic(chapterDir, format string, rebuild bool) map[string]interface{} {
+	index, animatic, err := h.processor.RenderAnimatic(chapterDir, format, rebuild)
+	if err != nil {
+		h.logger.Error("Failed to render animatic", zap.Error(err))
+		return map[string]interface{}{
+			"success":     false,
+			"error":       fmt.Sprintf("Failed to render animatic: %v", err),
+			"chapter_dir": chapterDir,
+		}
+	}
+
+	return map[string]interface{}{
+		"success":     true,
+		"chapter_dir": chapterDir,
+		"index":       index,
+		"output":      filepath.Join(filepath.Dir(index), animatic.Path),
+		"format":      animatic.Format,
+		"frames":      animatic.Frames,
+		"fps":         animatic.FPS,
+		"duration":    float64(animatic.Duration) / 1e6,
+		"tool":        "animatic_renderer",
+	}
+}
+
+// handleLintDraft checks a CapCut draft for problems that break it in CapCut
+func (h *Handler) handleLintDraft(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	draftPath, err := request.RequireString("draft")
+	if err != nil {
+		h.logger.Error("Missing draft parameter", zap.Error(err))
+		return mcp.NewToolResultError("Missing required parameter: draft"), nil
+	}
+
+	response := h.lintDraft(draftPath, request.GetString("app_version", ""), request.GetBool("skip_files", false))
+
+	responseJSON, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		h.logger.Error("Failed to serialize response", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to serialize response: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(responseJSON)), nil
+}
+
+// HandleLintDraftDirect 直接调用版本
+func (h *Handler) HandleLintDraftDirect(request *MockRequest) (map[string]interface{}, error) {
+	draftPath, err := request.RequireString("draft")
+	if err != nil {
+		h.logger.Error("Missing draft parameter", zap.Error(err))
+		return nil, fmt.Errorf("missing required parameter: draft")
+	}
+
+	return h.lintDraft(draftPath, request.GetString("app_version", ""), request.GetBool("skip_files", false)), nil
+}
+
+// lintDraft 检查剪映草稿并组装响应，发现问题时 valid 为false
+func (h *Handler) lintDraft(draftPath, appVersion string, skipFiles bool) map[string]interface{} {
+	problems, err := h.processor.LintCapcutDraft(draftPath, appVersion, skipFiles)
+	if err != nil {
+		h.logger.Error("Failed to lint draft", zap.Error(err))
+		return map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to lint draft: %v", err),
+			"draft":   draftPath,
+		}
+	}
+
+	return map[string]interface{}{
+		"success":  true,
+		"draft":    draftPath,
+		"valid":    len(problems) == 0,
+		"problems": problems,
+		"count":    len(problems),
+		"tool":     "draft_linter",
+	}
+}
+
+// splitList 拆分逗号分隔的参数
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // MockRequest 模拟MCP请求
 type MockRequest struct {
 	Params map[string]interface{}
@@ -0,0 +1,526 @@
+// Package fcpxml 将章节时间线导出为 Final Cut Pro 使用的 FCPXML（1.9）。
+//
+// 第一条视频轨道作为主故事情节，图片为静帧片段，片段之间的空隙写成 gap，转场写成交叉叠化；
+// 其余轨道作为连接片段挂在主故事情节上：音频在下方车道，字幕以基本字幕（Basic Title）放在上方车道。
+// 所有时间都按序列帧率取整到帧，并以秒为单位的有理数表示
+package fcpxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"novel-video-workflow/pkg/timeline"
+)
+
+// Ext .fcpxml 文件扩展名
+const Ext = ".fcpxml"
+
+// Version 输出的 FCPXML 版本
+const Version = "1.9"
+
+// Final Cut Pro 内置效果
+const (
+	basicTitleName     = "Basic Title"
+	basicTitleUID      = ".../Titles.localized/Bumper:Opener.localized/Basic Title.localized/Basic Title.moti"
+	crossDissolveName  = "Cross Dissolve"
+	crossDissolveUID   = "FxPlug:4731E73A-8DAC-4113-9A30-AE85B1761265"
+	titlePositionKey   = "9999/999166631/999166633/1/100/101"
+	defaultTitleFont   = "PingFang SC"
+	defaultTitleHeight = 0.025
+)
+
+// Options 导出选项
+type Options struct {
+	FrameRate timeline.FrameRate // 序列帧率，为空时使用时间线帧率
+	TitleFont string             // 字幕字体，为空时使用 PingFang SC
+}
+
+type document struct {
+	XMLName   xml.Name  `xml:"fcpxml"`
+	Version   string    `xml:"version,attr"`
+	Resources resources `xml:"resources"`
+	Library   library   `xml:"library"`
+}
+
+type resources struct {
+	Items []interface{} `xml:",any"`
+}
+
+type format struct {
+	XMLName       xml.Name `xml:"format"`
+	ID            string   `xml:"id,attr"`
+	FrameDuration string   `xml:"frameDuration,attr"`
+	Width         int      `xml:"width,attr"`
+	Height        int      `xml:"height,attr"`
+	ColorSpace    string   `xml:"colorSpace,attr"`
+}
+
+type asset struct {
+	XMLName       xml.Name `xml:"asset"`
+	ID            string   `xml:"id,attr"`
+	Name          string   `xml:"name,attr"`
+	Start         string   `xml:"start,attr"`
+	Duration      string   `xml:"duration,attr"`
+	HasVideo      string   `xml:"hasVideo,attr,omitempty"`
+	VideoSources  string   `xml:"videoSources,attr,omitempty"`
+	HasAudio      string   `xml:"hasAudio,attr,omitempty"`
+	AudioSources  string   `xml:"audioSources,attr,omitempty"`
+	AudioChannels string   `xml:"audioChannels,attr,omitempty"`
+	AudioRate     string   `xml:"audioRate,attr,omitempty"`
+	MediaRep      mediaRep `xml:"media-rep"`
+}
+
+type mediaRep struct {
+	Kind string `xml:"kind,attr"`
+	Src  string `xml:"src,attr"`
+}
+
+type effect struct {
+	XMLName xml.Name `xml:"effect"`
+	ID      string   `xml:"id,attr"`
+	Name    string   `xml:"name,attr"`
+	UID     string   `xml:"uid,attr"`
+}
+
+type library struct {
+	Event event `xml:"event"`
+}
+
+type event struct {
+	Name    string  `xml:"name,attr"`
+	Project project `xml:"project"`
+}
+
+type project struct {
+	Name     string   `xml:"name,attr"`
+	Sequence sequence `xml:"sequence"`
+}
+
+type sequence struct {
+	Format      string `xml:"format,attr"`
+	Duration    string `xml:"duration,attr"`
+	TCStart     string `xml:"tcStart,attr"`
+	TCFormat    string `xml:"tcFormat,attr"`
+	AudioLayout string `xml:"audioLayout,attr"`
+	AudioRate   string `xml:"audioRate,attr"`
+	Spine       spine  `xml:"spine"`
+}
+
+type spine struct {
+	Items []interface{} `xml:",any"`
+}
+
+// clip 主故事情节或连接的片段：静帧为 video，音视频为 asset-clip，空隙为 gap
+type clip struct {
+	XMLName   xml.Name
+	Ref       string        `xml:"ref,attr,omitempty"`
+	Lane      string        `xml:"lane,attr,omitempty"`
+	Offset    string        `xml:"offset,attr"`
+	Name      string        `xml:"name,attr"`
+	Start     string        `xml:"start,attr"`
+	Duration  string        `xml:"duration,attr"`
+	AudioRole string        `xml:"audioRole,attr,omitempty"`
+	Volume    *adjustVolume `xml:"adjust-volume"`
+	Items     []interface{} `xml:",any"`
+}
+
+type adjustVolume struct {
+	Amount string `xml:"amount,attr"`
+}
+
+type transition struct {
+	XMLName  xml.Name    `xml:"transition"`
+	Name     string      `xml:"name,attr"`
+	Offset   string      `xml:"offset,attr"`
+	Duration string      `xml:"duration,attr"`
+	Filter   filterVideo `xml:"filter-video"`
+}
+
+type filterVideo struct {
+	Ref  string `xml:"ref,attr"`
+	Name string `xml:"name,attr"`
+}
+
+type title struct {
+	XMLName  xml.Name     `xml:"title"`
+	Ref      string       `xml:"ref,attr"`
+	Lane     string       `xml:"lane,attr"`
+	Offset   string       `xml:"offset,attr"`
+	Name     string       `xml:"name,attr"`
+	Start    string       `xml:"start,attr"`
+	Duration string       `xml:"duration,attr"`
+	Params   []param      `xml:"param"`
+	Text     titleText    `xml:"text"`
+	StyleDef textStyleDef `xml:"text-style-def"`
+}
+
+type param struct {
+	Name  string `xml:"name,attr"`
+	Key   string `xml:"key,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type titleText struct {
+	Style textStyleRef `xml:"text-style"`
+}
+
+type textStyleRef struct {
+	Ref   string `xml:"ref,attr"`
+	Value string `xml:",chardata"`
+}
+
+type textStyleDef struct {
+	ID    string    `xml:"id,attr"`
+	Style textStyle `xml:"text-style"`
+}
+
+type textStyle struct {
+	Font      string `xml:"font,attr"`
+	FontSize  string `xml:"fontSize,attr"`
+	FontColor string `xml:"fontColor,attr"`
+	Bold      string `xml:"bold,attr,omitempty"`
+	Alignment string `xml:"alignment,attr"`
+}
+
+// spineEntry 主故事情节中的片段或空隙，用于确定连接片段挂在哪个片段上
+type spineEntry struct {
+	offset, start, duration int64 // 帧
+	el                      *clip
+}
+
+// builder 生成过程中的资源编号和帧率
+type builder struct {
+	rate      timeline.FrameRate
+	opts      Options
+	resources []interface{}
+	assets    map[string]string // 素材路径 -> 资源ID
+	effects   map[string]string // 效果名称 -> 资源ID
+	nextID    int
+	styles    int
+}
+
+// Export 将时间线写入 .fcpxml 文件
+func Export(tl *timeline.Timeline, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建FCPXML文件失败: %v", err)
+	}
+	defer file.Close()
+	return Encode(file, tl, Options{})
+}
+
+// Encode 将时间线编码为 FCPXML
+func Encode(w io.Writer, tl *timeline.Timeline, opts Options) error {
+	if err := tl.Validate(); err != nil {
+		return err
+	}
+	b := &builder{
+		rate:    opts.FrameRate,
+		opts:    opts,
+		assets:  make(map[string]string),
+		effects: make(map[string]string),
+	}
+	if b.rate.IsZero() {
+		b.rate = timeline.FrameRateOf(tl.FPS)
+	}
+
+	formatID := b.id()
+	b.resources = append(b.resources, format{
+		ID:            formatID,
+		FrameDuration: b.time(1),
+		Width:         tl.Width,
+		Height:        tl.Height,
+		ColorSpace:    "1-1-1 (Rec. 709)",
+	})
+
+	// 主故事情节优先使用分镜图片轨道，没有时使用第一条视频轨道
+	var primary *timeline.Track
+	if videos := tl.TracksOf(timeline.TrackVideo); len(videos) > 0 {
+		primary = videos[0]
+	}
+	if images := tl.TrackByRole(timeline.RoleImages); images != nil && images.Kind == timeline.TrackVideo {
+		primary = images
+	}
+
+	total := b.rate.Frames(tl.Duration)
+	for _, track := range tl.Tracks {
+		for _, c := range track.Clips {
+			if end := b.rate.Frames(c.Target.End()); end > total {
+				total = end
+			}
+		}
+		for _, cue := range track.Cues {
+			if end := b.rate.Frames(cue.Target.End()); end > total {
+				total = end
+			}
+		}
+	}
+
+	items, entries := b.primaryStoryline(primary, total)
+
+	// 连接片段：音频在下方车道，其他视频轨道和字幕在上方车道
+	audioLane, upperLane := 0, 0
+	for _, track := range tl.Tracks {
+		if track == primary {
+			continue
+		}
+		switch track.Kind {
+		case timeline.TrackAudio:
+			audioLane--
+			for _, c := range track.Clips {
+				el := b.clipElement(c, track)
+				el.Lane = strconv.Itoa(audioLane)
+				attach(entries, b, b.rate.Frames(c.Target.Start), el, &el.Offset)
+			}
+		case timeline.TrackVideo:
+			upperLane++
+			for _, c := range track.Clips {
+				el := b.clipElement(c, track)
+				el.Lane = strconv.Itoa(upperLane)
+				attach(entries, b, b.rate.Frames(c.Target.Start), el, &el.Offset)
+			}
+		case timeline.TrackText:
+			if len(track.Cues) == 0 {
+				continue
+			}
+			upperLane++
+			for _, cue := range track.Cues {
+				el := b.titleElement(cue, track, tl.Height)
+				el.Lane = strconv.Itoa(upperLane)
+				attach(entries, b, b.rate.Frames(cue.Target.Start), el, &el.Offset)
+			}
+		}
+	}
+
+	doc := document{
+		Version:   Version,
+		Resources: resources{Items: b.resources},
+		Library: library{Event: event{
+			Name: tl.Name,
+			Project: project{
+				Name: tl.Name,
+				Sequence: sequence{
+					Format:      formatID,
+					Duration:    b.time(total),
+					TCStart:     "0s",
+					TCFormat:    "NDF",
+					AudioLayout: "stereo",
+					AudioRate:   "48k",
+					Spine:       spine{Items: items},
+				},
+			},
+		}},
+	}
+
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE fcpxml>\n"); err != nil {
+		return fmt.Errorf("写入FCPXML失败: %v", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "    ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("写入FCPXML失败: %v", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// primaryStoryline 生成主故事情节，空隙写成 gap，并用 gap 补齐到序列结束，保证所有连接片段都有可挂载的片段
+func (b *builder) primaryStoryline(track *timeline.Track, total int64) ([]interface{}, []*spineEntry) {
+	var items []interface{}
+	var entries []*spineEntry
+	cursor := int64(0)
+	addGap := func(until int64) {
+		el := &clip{XMLName: xml.Name{Local: "gap"}, Name: "Gap", Offset: b.time(cursor), Start: "0s", Duration: b.time(until - cursor)}
+		items = append(items, el)
+		entries = append(entries, &spineEntry{offset: cursor, start: 0, duration: until - cursor, el: el})
+		cursor = until
+	}
+
+	if track != nil {
+		for i, c := range track.Clips {
+			start, duration := b.rate.FrameRange(c.Target)
+			if duration <= 0 {
+				continue
+			}
+			if start > cursor {
+				addGap(start)
+			}
+			el := b.clipElement(c, track)
+			el.Offset = b.time(start)
+			items = append(items, el)
+			entries = append(entries, &spineEntry{offset: start, start: b.rate.Frames(c.Source.Start), duration: duration, el: el})
+			cursor = start + duration
+
+			// 只有与下一个片段首尾相接时才能加转场
+			if t := c.Transition; t != nil && i+1 < len(track.Clips) && b.rate.Frames(track.Clips[i+1].Target.Start) == cursor {
+				if frames := b.rate.Frames(t.Duration); frames > 0 {
+					items = append(items, transition{
+						Name:     crossDissolveName,
+						Offset:   b.time(cursor - frames/2),
+						Duration: b.time(frames),
+						Filter:   filterVideo{Ref: b.effect(crossDissolveName, crossDissolveUID), Name: crossDissolveName},
+					})
+				}
+			}
+		}
+	}
+	if cursor < total {
+		addGap(total)
+	}
+	return items, entries
+}
+
+// attach 将连接片段挂在开始时间所在的主故事情节片段上，offset 为该片段内部的时间
+func attach(entries []*spineEntry, b *builder, at int64, item interface{}, offset *string) {
+	if len(entries) == 0 {
+		return
+	}
+	parent := entries[len(entries)-1]
+	for _, entry := range entries {
+		if at >= entry.offset && at < entry.offset+entry.duration {
+			parent = entry
+			break
+		}
+	}
+	*offset = b.time(parent.start + at - parent.offset)
+	parent.el.Items = append(parent.el.Items, item)
+}
+
+// clipElement 生成片段，静帧使用 video 元素，音视频使用 asset-clip
+func (b *builder) clipElement(c *timeline.Clip, track *timeline.Track) *clip {
+	start := b.rate.Frames(c.Source.Start)
+	_, duration := b.rate.FrameRange(c.Target)
+	el := &clip{
+		XMLName:  xml.Name{Local: "asset-clip"},
+		Ref:      b.asset(c),
+		Name:     clipName(c),
+		Start:    b.time(start),
+		Duration: b.time(duration),
+	}
+	switch {
+	case c.Media == timeline.MediaImage:
+		el.XMLName.Local = "video"
+	case track.Role == timeline.RoleNarration:
+		el.AudioRole = "dialogue"
+	case track.Role == timeline.RoleBGM:
+		el.AudioRole = "music"
+	}
+	if c.Media != timeline.MediaImage && c.Volume() != 1.0 {
+		el.Volume = &adjustVolume{Amount: fmt.Sprintf("%.1fdB", 20*math.Log10(c.Volume()))}
+	}
+	return el
+}
+
+// titleElement 将一条字幕生成为基本字幕，字号和位置按画布高度换算为像素
+func (b *builder) titleElement(cue timeline.TextCue, track *timeline.Track, height int) *title {
+	_, duration := b.rate.FrameRange(cue.Target)
+	style := track.Style
+	if style == nil {
+		style = &timeline.TextStyle{Size: defaultTitleHeight, Color: "#FFFFFF", PositionY: -0.8}
+	}
+	font := b.opts.TitleFont
+	if font == "" {
+		font = defaultTitleFont
+	}
+	b.styles++
+	styleID := fmt.Sprintf("ts%d", b.styles)
+	el := &title{
+		Ref:      b.effect(basicTitleName, basicTitleUID),
+		Name:     cue.Text,
+		Start:    "0s",
+		Duration: b.time(duration),
+		Params: []param{{
+			Name:  "Position",
+			Key:   titlePositionKey,
+			Value: fmt.Sprintf("0 %s", formatFloat(style.PositionY*float64(height)/2)),
+		}},
+		Text: titleText{Style: textStyleRef{Ref: styleID, Value: cue.Text}},
+		StyleDef: textStyleDef{ID: styleID, Style: textStyle{
+			Font:      font,
+			FontSize:  formatFloat(style.Size * float64(height)),
+			FontColor: fcpColor(style.Color),
+			Alignment: "center",
+		}},
+	}
+	if style.Bold {
+		el.StyleDef.Style.Bold = "1"
+	}
+	return el
+}
+
+// asset 返回素材的资源ID，同一文件只生成一个资源
+func (b *builder) asset(c *timeline.Clip) string {
+	if id, ok := b.assets[c.Path]; ok {
+		return id
+	}
+	id := b.id()
+	a := asset{
+		ID:       id,
+		Name:     filepath.Base(c.Path),
+		Start:    "0s",
+		Duration: "0s", // 静帧没有固定长度
+		MediaRep: mediaRep{Kind: "original-media", Src: timeline.FileURL(c.Path)},
+	}
+	if c.Media != timeline.MediaImage {
+		a.Duration = b.time(b.rate.Frames(c.AvailableDuration()))
+	}
+	if c.Media != timeline.MediaAudio {
+		a.HasVideo, a.VideoSources = "1", "1"
+	}
+	if c.Media != timeline.MediaImage {
+		a.HasAudio, a.AudioSources, a.AudioChannels, a.AudioRate = "1", "1", "2", "48000"
+	}
+	b.resources = append(b.resources, a)
+	b.assets[c.Path] = id
+	return id
+}
+
+// effect 返回效果的资源ID
+func (b *builder) effect(name, uid string) string {
+	if id, ok := b.effects[name]; ok {
+		return id
+	}
+	id := b.id()
+	b.resources = append(b.resources, effect{ID: id, Name: name, UID: uid})
+	b.effects[name] = id
+	return id
+}
+
+func (b *builder) id() string {
+	b.nextID++
+	return fmt.Sprintf("r%d", b.nextID)
+}
+
+// time 将帧数写成 FCPXML 的有理数秒，如 "0s"、"2s"、"1001/30000s"
+func (b *builder) time(frames int64) string {
+	seconds := b.rate.Seconds(frames)
+	if seconds.IsInt() {
+		return seconds.Num().String() + "s"
+	}
+	return seconds.String() + "s"
+}
+
+func clipName(c *timeline.Clip) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return filepath.Base(c.Path)
+}
+
+// fcpColor 将 #RRGGBB 转换为 "r g b a"
+func fcpColor(hex string) string {
+	var r, g, bl int
+	if _, err := fmt.Sscanf(hex, "#%02x%02x%02x", &r, &g, &bl); err != nil {
+		return "1 1 1 1"
+	}
+	return fmt.Sprintf("%s %s %s 1", formatFloat(float64(r)/255), formatFloat(float64(g)/255), formatFloat(float64(bl)/255))
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
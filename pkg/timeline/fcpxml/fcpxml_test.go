@@ -0,0 +1,117 @@
+package fcpxml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"novel-video-workflow/pkg/timeline"
+)
+
+var update = flag.Bool("update", false, "更新 testdata 中的 golden 文件")
+
+func sampleTimeline() *timeline.Timeline {
+	tl := timeline.New("chapter_01", 1080, 1920, 30)
+	tl.Chapter = 1
+	tl.Duration = 6000000
+	images := tl.AddTrack(timeline.TrackVideo, "视频轨道", timeline.RoleImages)
+	images.AddClip(&timeline.Clip{
+		ID: "image_01", Name: "scene_01.png", Media: timeline.MediaImage, Path: "/tmp/小说/scene 01.png",
+		Source: timeline.Range{Start: 0, Duration: 2000000}, Target: timeline.Range{Start: 0, Duration: 2000000},
+		Transition: &timeline.Transition{Name: "淡入淡出", Duration: 500000},
+	})
+	images.AddClip(&timeline.Clip{
+		ID: "image_02", Name: "scene_02.png", Media: timeline.MediaImage, Path: "/tmp/小说/scene_02.png",
+		Source: timeline.Range{Start: 0, Duration: 1000000}, Target: timeline.Range{Start: 2000000, Duration: 1000000},
+	})
+	// 第三张前留1秒空隙
+	images.AddClip(&timeline.Clip{
+		ID: "image_03", Name: "scene_03.png", Media: timeline.MediaImage, Path: "/tmp/小说/scene_03.png",
+		Source: timeline.Range{Start: 0, Duration: 2000000}, Target: timeline.Range{Start: 4000000, Duration: 2000000},
+	})
+	narration := tl.AddTrack(timeline.TrackAudio, "音频轨道", timeline.RoleNarration)
+	narration.AddClip(&timeline.Clip{
+		ID: "narration", Name: "chapter_01.wav", Media: timeline.MediaAudio, Path: "/tmp/小说/chapter_01.wav",
+		Source: timeline.Range{Start: 0, Duration: 6000000}, Target: timeline.Range{Start: 0, Duration: 6000000}, Gain: 1,
+	})
+	bgm := tl.AddTrack(timeline.TrackAudio, "背景音乐", timeline.RoleBGM)
+	bgm.AddClip(&timeline.Clip{
+		ID: "bgm", Name: "bgm.mp3", Media: timeline.MediaAudio, Path: "/tmp/bgm.mp3",
+		Source: timeline.Range{Start: 1000000, Duration: 6000000}, Target: timeline.Range{Start: 0, Duration: 6000000}, Gain: 0.5,
+	})
+	subtitles := tl.AddTrack(timeline.TrackText, "字幕轨道", timeline.RoleSubtitle)
+	subtitles.Cues = []timeline.TextCue{
+		{Target: timeline.Range{Start: 0, Duration: 2500000}, Text: "第一句"},
+		{Target: timeline.Range{Start: 2500000, Duration: 3500000}, Text: "第二句 & 结尾"},
+	}
+	subtitles.Style = &timeline.TextStyle{Size: 0.025, Color: "#FFCC00", Bold: true, PositionY: -0.8}
+	return tl
+}
+
+// checkGolden 与 testdata 中的 golden 文件比较，-update 时重新生成
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("写入 golden 文件失败: %v", err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取 golden 文件失败: %v", err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("输出与 %s 不一致，确认改动后使用 -update 更新:\n%s", path, got)
+	}
+}
+
+func TestEncodeGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, sampleTimeline(), Options{}); err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	checkGolden(t, "chapter_01.fcpxml", buf.Bytes())
+}
+
+// TestEncodeNTSCGolden 29.97fps 下时间应写成以1001为单位的有理数
+func TestEncodeNTSCGolden(t *testing.T) {
+	rate, err := timeline.ParseFrameRate("29.97")
+	if err != nil {
+		t.Fatalf("解析帧率失败: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, sampleTimeline(), Options{FrameRate: rate}); err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `frameDuration="1001/30000s"`) {
+		t.Errorf("格式帧长应为 1001/30000s")
+	}
+	// 2秒在29.97fps下为60帧，即 60060/30000s，约分为 1001/500s
+	if !strings.Contains(out, `name="scene_01.png" start="0s" duration="1001/500s"`) {
+		t.Errorf("2秒的图片应为60帧的有理数时长")
+	}
+	checkGolden(t, "chapter_01_2997.fcpxml", buf.Bytes())
+}
+
+func TestEncodeWellFormed(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, sampleTimeline(), Options{}); err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	decoder := xml.NewDecoder(&buf)
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("输出不是合法XML: %v", err)
+		}
+	}
+}
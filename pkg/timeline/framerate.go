@@ -0,0 +1,103 @@
+package timeline
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net/url"
+	"path/filepath"
+)
+
+// FrameRate 以有理数表示的帧率，如 30/1，NTSC 的 29.97 为 30000/1001
+type FrameRate struct {
+	Num int64
+	Den int64
+}
+
+// FrameRateOf 整数帧率
+func FrameRateOf(fps int) FrameRate {
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+	return FrameRate{Num: int64(fps), Den: 1}
+}
+
+// ParseFrameRate 解析 "30"、"29.97"、"30000/1001" 形式的帧率，23.976、29.97、59.94 按 NTSC 帧率处理
+func ParseFrameRate(s string) (FrameRate, error) {
+	var num, den int64
+	if n, err := fmt.Sscanf(s, "%d/%d", &num, &den); err == nil && n == 2 && num > 0 && den > 0 {
+		return FrameRate{Num: num, Den: den}, nil
+	}
+	var fps float64
+	if _, err := fmt.Sscanf(s, "%g", &fps); err != nil || fps <= 0 {
+		return FrameRate{}, fmt.Errorf("无效的帧率: %s", s)
+	}
+	if whole := math.Round(fps); math.Abs(fps-whole) < 1e-6 {
+		return FrameRate{Num: int64(whole), Den: 1}, nil
+	}
+	if ntsc := math.Round(fps * 1.001); math.Abs(fps-ntsc/1.001) < 0.005 {
+		return FrameRate{Num: int64(ntsc) * 1000, Den: 1001}, nil
+	}
+	return FrameRate{}, fmt.Errorf("不支持的帧率: %s", s)
+}
+
+// IsZero 是否未设置
+func (r FrameRate) IsZero() bool {
+	return r.Num <= 0 || r.Den <= 0
+}
+
+// FPS 帧率的浮点值
+func (r FrameRate) FPS() float64 {
+	return float64(r.Num) / float64(r.Den)
+}
+
+// NTSC 是否为 NTSC 帧率（分母为1001）
+func (r FrameRate) NTSC() bool {
+	return r.Den == 1001
+}
+
+// Timebase 整数时基，NTSC 帧率向上取整，如 29.97 为 30
+func (r FrameRate) Timebase() int64 {
+	return (r.Num + r.Den - 1) / r.Den
+}
+
+// Frames 微秒换算为帧数，四舍五入
+func (r FrameRate) Frames(us int64) int64 {
+	return int64(math.Round(float64(us) * float64(r.Num) / (float64(r.Den) * 1e6)))
+}
+
+// Microseconds 帧数换算为微秒，四舍五入
+func (r FrameRate) Microseconds(frames int64) int64 {
+	return int64(math.Round(float64(frames) * float64(r.Den) * 1e6 / float64(r.Num)))
+}
+
+// Seconds 帧数对应的秒数，以约分后的有理数表示
+func (r FrameRate) Seconds(frames int64) *big.Rat {
+	return new(big.Rat).SetFrac64(frames*r.Den, r.Num)
+}
+
+// FrameRange 将微秒区间换算为帧区间（开始帧，帧数），结束帧单独取整，保证相邻区间首尾相接
+func (r FrameRate) FrameRange(rg Range) (int64, int64) {
+	start := r.Frames(rg.Start)
+	return start, r.Frames(rg.End()) - start
+}
+
+// FileURL 将本地路径转换为 file:// URL
+func FileURL(path string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// PathFromURL 将 file:// URL 转换为本地路径，其他形式的引用原样返回
+func PathFromURL(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "file" {
+		return target
+	}
+	return filepath.FromSlash(u.Path)
+}
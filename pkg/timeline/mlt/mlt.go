@@ -0,0 +1,640 @@
+// Package mlt 将章节时间线导出为 MLT XML，可用 Kdenlive、Shotcut 打开，也可以用 melt 在服务器上直接渲染。
+//
+// 第0条轨道为黑色背景，其后视频和字幕轨道按时间线顺序叠加（qtblend），音频轨道用 mix 混音。
+// 图片为 qimage 静帧，运动关键帧写成 affine 滤镜的 transition.rect 动画；
+// 首尾相接的图片之间的转场写成播放列表中的小 tractor（luma 叠化），跨在切换点两侧。
+// 字幕每句一个透明的 color 片段，文字由 dynamictext 滤镜绘制
+package mlt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"novel-video-workflow/pkg/timeline"
+)
+
+// Ext MLT 项目文件扩展名
+const Ext = ".mlt"
+
+// Version 写入根元素的 MLT 版本
+const Version = "7.0.0"
+
+// Options 导出选项
+type Options struct {
+	FrameRate timeline.FrameRate // 项目帧率，为空时使用时间线帧率
+	TitleFont string             // 字幕字体，为空时使用 Noto Sans CJK SC
+}
+
+const (
+	defaultTitleFont   = "Noto Sans CJK SC"
+	defaultTitleHeight = 0.025
+)
+
+type document struct {
+	XMLName   xml.Name      `xml:"mlt"`
+	LCNumeric string        `xml:"LC_NUMERIC,attr"`
+	Version   string        `xml:"version,attr"`
+	Title     string        `xml:"title,attr"`
+	Producer  string        `xml:"producer,attr"`
+	Profile   profile       `xml:"profile"`
+	Items     []interface{} `xml:",any"`
+}
+
+type profile struct {
+	Description      string `xml:"description,attr"`
+	Width            int    `xml:"width,attr"`
+	Height           int    `xml:"height,attr"`
+	Progressive      int    `xml:"progressive,attr"`
+	SampleAspectNum  int    `xml:"sample_aspect_num,attr"`
+	SampleAspectDen  int    `xml:"sample_aspect_den,attr"`
+	DisplayAspectNum int    `xml:"display_aspect_num,attr"`
+	DisplayAspectDen int    `xml:"display_aspect_den,attr"`
+	FrameRateNum     int64  `xml:"frame_rate_num,attr"`
+	FrameRateDen     int64  `xml:"frame_rate_den,attr"`
+	Colorspace       int    `xml:"colorspace,attr"`
+}
+
+type property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+type producer struct {
+	XMLName    xml.Name   `xml:"producer"`
+	ID         string     `xml:"id,attr"`
+	In         int64      `xml:"in,attr"`
+	Out        int64      `xml:"out,attr"`
+	Properties []property `xml:"property"`
+	Filters    []filter   `xml:"filter"`
+}
+
+type filter struct {
+	ID         string     `xml:"id,attr"`
+	Properties []property `xml:"property"`
+}
+
+type playlist struct {
+	XMLName    xml.Name      `xml:"playlist"`
+	ID         string        `xml:"id,attr"`
+	Properties []property    `xml:"property"`
+	Items      []interface{} `xml:",any"`
+}
+
+type entry struct {
+	XMLName  xml.Name `xml:"entry"`
+	Producer string   `xml:"producer,attr"`
+	In       int64    `xml:"in,attr"`
+	Out      int64    `xml:"out,attr"`
+}
+
+type blank struct {
+	XMLName xml.Name `xml:"blank"`
+	Length  int64    `xml:"length,attr"`
+}
+
+type tractor struct {
+	XMLName     xml.Name     `xml:"tractor"`
+	ID          string       `xml:"id,attr"`
+	Title       string       `xml:"title,attr,omitempty"`
+	In          int64        `xml:"in,attr"`
+	Out         int64        `xml:"out,attr"`
+	Properties  []property   `xml:"property"`
+	Tracks      []trackRef   `xml:"track"`
+	Transitions []transition `xml:"transition"`
+}
+
+type trackRef struct {
+	Producer string `xml:"producer,attr"`
+	In       string `xml:"in,attr,omitempty"`
+	Out      string `xml:"out,attr,omitempty"`
+	Hide     string `xml:"hide,attr,omitempty"`
+}
+
+type transition struct {
+	ID         string     `xml:"id,attr"`
+	Out        string     `xml:"out,attr,omitempty"`
+	Properties []property `xml:"property"`
+}
+
+// builder 生成过程中的编号、帧率和已定义的元素
+type builder struct {
+	rate      timeline.FrameRate
+	opts      Options
+	width     int
+	height    int
+	defs      []interface{} // 需要先于播放列表定义的 producer 和转场 tractor
+	producers int
+	filters   int
+	tractors  int
+	trans     int
+}
+
+// Export 将时间线写入 MLT 项目文件
+func Export(tl *timeline.Timeline, path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建MLT文件失败: %v", err)
+	}
+	defer out.Close()
+	return Encode(out, tl, Options{})
+}
+
+// Encode 将时间线编码为 MLT XML
+func Encode(w io.Writer, tl *timeline.Timeline, opts Options) error {
+	if err := tl.Validate(); err != nil {
+		return err
+	}
+	b := &builder{rate: opts.FrameRate, opts: opts, width: tl.Width, height: tl.Height}
+	if b.rate.IsZero() {
+		b.rate = timeline.FrameRateOf(tl.FPS)
+	}
+
+	total := b.rate.Frames(tl.Duration)
+	for _, tr := range tl.Tracks {
+		for _, c := range tr.Clips {
+			if end := b.rate.Frames(c.Target.End()); end > total {
+				total = end
+			}
+		}
+		for _, cue := range tr.Cues {
+			if end := b.rate.Frames(cue.Target.End()); end > total {
+				total = end
+			}
+		}
+	}
+	if total <= 0 {
+		return fmt.Errorf("时间线为空")
+	}
+
+	// 黑色背景，保证没有图片的空隙也有画面
+	b.defs = append(b.defs, &producer{
+		ID:  "black",
+		In:  0,
+		Out: total - 1,
+		Properties: []property{
+			{"length", strconv.FormatInt(total, 10)},
+			{"eof", "pause"},
+			{"resource", "0"},
+			{"aspect_ratio", "1"},
+			{"mlt_service", "color"},
+			{"mlt_image_format", "rgba"},
+			{"set.test_audio", "0"},
+		},
+	})
+	playlists := []*playlist{{
+		ID:    "background",
+		Items: []interface{}{entry{Producer: "black", In: 0, Out: total - 1}},
+	}}
+	main := tractor{ID: "tractor0", Title: tl.Name, In: 0, Out: total - 1}
+	main.Tracks = append(main.Tracks, trackRef{Producer: "background"})
+
+	for _, tr := range tl.Tracks {
+		pl := &playlist{ID: fmt.Sprintf("playlist%d", len(playlists)-1)}
+		pl.Properties = append(pl.Properties, property{"shotcut:name", tr.Name})
+		switch tr.Kind {
+		case timeline.TrackVideo:
+			pl.Properties = append(pl.Properties, property{"shotcut:video", "1"})
+			pl.Items = b.videoItems(tr)
+		case timeline.TrackAudio:
+			pl.Properties = append(pl.Properties, property{"shotcut:audio", "1"})
+			pl.Items = b.audioItems(tr)
+		case timeline.TrackText:
+			pl.Properties = append(pl.Properties, property{"shotcut:video", "1"})
+			pl.Items = b.textItems(tr)
+		}
+		if len(pl.Items) == 0 {
+			continue
+		}
+		playlists = append(playlists, pl)
+
+		index := strconv.Itoa(len(main.Tracks))
+		ref := trackRef{Producer: pl.ID}
+		var props []property
+		if tr.Kind == timeline.TrackAudio {
+			ref.Hide = "video"
+			props = []property{
+				{"a_track", "0"},
+				{"b_track", index},
+				{"mlt_service", "mix"},
+				{"always_active", "1"},
+				{"sum", "1"},
+			}
+		} else {
+			ref.Hide = "audio"
+			props = []property{
+				{"a_track", "0"},
+				{"b_track", index},
+				{"mlt_service", "qtblend"},
+				{"always_active", "1"},
+				{"compositing", "0"},
+			}
+		}
+		main.Tracks = append(main.Tracks, ref)
+		main.Transitions = append(main.Transitions, transition{ID: b.transitionID(), Properties: props})
+	}
+
+	items := append([]interface{}{}, b.defs...)
+	for _, pl := range playlists {
+		items = append(items, pl)
+	}
+	items = append(items, main)
+
+	num, den := aspectRatio(tl.Width, tl.Height)
+	doc := document{
+		LCNumeric: "C",
+		Version:   Version,
+		Title:     tl.Name,
+		Producer:  main.ID,
+		Profile: profile{
+			Description:      fmt.Sprintf("%dx%d %s fps", tl.Width, tl.Height, formatFloat(b.rate.FPS())),
+			Width:            tl.Width,
+			Height:           tl.Height,
+			Progressive:      1,
+			SampleAspectNum:  1,
+			SampleAspectDen:  1,
+			DisplayAspectNum: num,
+			DisplayAspectDen: den,
+			FrameRateNum:     b.rate.Num,
+			FrameRateDen:     b.rate.Den,
+			Colorspace:       709,
+		},
+		Items: items,
+	}
+
+	if _, err := io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n"); err != nil {
+		return fmt.Errorf("写入MLT失败: %v", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("写入MLT失败: %v", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// shot 视频轨道上一个片段的帧位置。visible 为包含转场在内的可见区间，producer 的第0帧对应 visibleStart
+type shot struct {
+	clip         *timeline.Clip
+	start, end   int64
+	visibleStart int64
+	visibleEnd   int64
+	out          *shotTransition // 切换到下一个片段的转场
+	producer     *producer
+}
+
+// shotTransition 跨在切换点两侧的转场区间
+type shotTransition struct {
+	start, end int64
+}
+
+// videoItems 生成视频轨道的播放列表。只有首尾相接的两张图片之间才写转场：
+// 静帧可以任意延长，转场前半段继续显示上一张，后半段提前显示下一张
+func (b *builder) videoItems(tr *timeline.Track) []interface{} {
+	var shots []*shot
+	for _, c := range tr.Clips {
+		start, duration := b.rate.FrameRange(c.Target)
+		if duration <= 0 {
+			continue
+		}
+		shots = append(shots, &shot{clip: c, start: start, end: start + duration, visibleStart: start, visibleEnd: start + duration})
+	}
+
+	soloStart := int64(0)
+	for i, s := range shots {
+		if i == 0 || shots[i-1].out == nil {
+			soloStart = s.start
+		}
+		if s.clip.Transition == nil || i+1 >= len(shots) {
+			continue
+		}
+		next := shots[i+1]
+		frames := b.rate.Frames(s.clip.Transition.Duration)
+		if frames <= 0 || next.start != s.end || s.clip.Media != timeline.MediaImage || next.clip.Media != timeline.MediaImage {
+			continue
+		}
+		t := &shotTransition{start: s.end - frames/2}
+		t.end = t.start + frames
+		if t.start < soloStart || t.end > next.end {
+			continue
+		}
+		s.out = t
+		s.visibleEnd = t.end
+		next.visibleStart = t.start
+		soloStart = t.end
+	}
+
+	for _, s := range shots {
+		s.producer = b.clipProducer(s.clip, s.visibleEnd-s.visibleStart, s.start-s.visibleStart)
+		b.defs = append(b.defs, s.producer)
+	}
+
+	var items []interface{}
+	position := int64(0)
+	for i, s := range shots {
+		if s.visibleStart > position {
+			items = append(items, blank{Length: s.visibleStart - position})
+		}
+		soloFrom := s.visibleStart
+		if i > 0 && shots[i-1].out != nil {
+			soloFrom = shots[i-1].out.end
+		}
+		soloTo := s.visibleEnd
+		if s.out != nil {
+			soloTo = s.out.start
+		}
+		base := s.producer.In - s.visibleStart
+		if soloTo > soloFrom {
+			items = append(items, entry{Producer: s.producer.ID, In: base + soloFrom, Out: base + soloTo - 1})
+		}
+		position = soloTo
+		if s.out != nil {
+			next := shots[i+1]
+			items = append(items, b.transitionTractor(s.producer, base+s.out.start, next.producer, next.producer.In, s.out.end-s.out.start))
+			position = s.out.end
+		}
+	}
+	return items
+}
+
+// transitionTractor 生成叠化转场：两条轨道分别是上一张的尾部和下一张的头部
+func (b *builder) transitionTractor(from *producer, fromIn int64, to *producer, toIn, frames int64) entry {
+	b.tractors++
+	t := &tractor{
+		ID:         fmt.Sprintf("tractor%d", b.tractors),
+		In:         0,
+		Out:        frames - 1,
+		Properties: []property{{"shotcut:transition", "lumaMix"}},
+		Tracks: []trackRef{
+			{Producer: from.ID, In: strconv.FormatInt(fromIn, 10), Out: strconv.FormatInt(fromIn+frames-1, 10)},
+			{Producer: to.ID, In: strconv.FormatInt(toIn, 10), Out: strconv.FormatInt(toIn+frames-1, 10)},
+		},
+		Transitions: []transition{{
+			ID:  b.transitionID(),
+			Out: strconv.FormatInt(frames-1, 10),
+			Properties: []property{
+				{"a_track", "0"},
+				{"b_track", "1"},
+				{"factory", "loader"},
+				{"mlt_service", "luma"},
+			},
+		}},
+	}
+	b.defs = append(b.defs, t)
+	return entry{Producer: t.ID, In: 0, Out: frames - 1}
+}
+
+// clipProducer 生成视频轨道片段的 producer。图片的 producer 长度为可见帧数，
+// lead 为转场提前显示的帧数，运动关键帧相应后移；视频的帧号从素材开头算起，关键帧从入点开始
+func (b *builder) clipProducer(c *timeline.Clip, length, lead int64) *producer {
+	b.producers++
+	p := &producer{ID: fmt.Sprintf("producer%d", b.producers)}
+	if c.Media == timeline.MediaImage {
+		p.Out = length - 1
+		p.Properties = []property{
+			{"length", strconv.FormatInt(length, 10)},
+			{"eof", "pause"},
+			{"resource", c.Path},
+			{"ttl", "1"},
+			{"aspect_ratio", "1"},
+			{"meta.media.progressive", "1"},
+			{"seekable", "1"},
+			{"mlt_service", "qimage"},
+			{"shotcut:caption", clipName(c)},
+		}
+	} else {
+		p.In = b.rate.Frames(c.Source.Start)
+		p.Out = b.rate.Frames(c.Source.End()) - 1
+		p.Properties = []property{
+			{"length", strconv.FormatInt(p.Out+1, 10)},
+			{"eof", "pause"},
+			{"resource", c.Path},
+			{"mlt_service", "avformat-novalidate"},
+			{"seekable", "1"},
+			{"shotcut:caption", clipName(c)},
+		}
+		lead += p.In
+	}
+	if rect := b.motionRect(c.Motion, lead); rect != "" {
+		p.Filters = append(p.Filters, filter{
+			ID: b.filterID(),
+			Properties: []property{
+				{"background", "color:#00000000"},
+				{"mlt_service", "affine"},
+				{"shotcut:filter", "affineSizePosition"},
+				{"transition.fill", "1"},
+				{"transition.distort", "0"},
+				{"transition.rect", rect},
+				{"transition.valign", "middle"},
+				{"transition.halign", "center"},
+				{"transition.threads", "0"},
+			},
+		})
+	}
+	return p
+}
+
+// motionRect 将运动关键帧换算为 affine 的矩形动画 "帧=x y 宽 高 不透明度;..."。
+// 缩放以画布中心为原点，位移单位为半个画布，上移为正
+func (b *builder) motionRect(m *timeline.Motion, lead int64) string {
+	if m == nil || len(m.Keyframes) == 0 {
+		return ""
+	}
+	var offsets []int64
+	seen := make(map[int64]bool)
+	for _, kf := range m.Keyframes {
+		if !seen[kf.Offset] {
+			seen[kf.Offset] = true
+			offsets = append(offsets, kf.Offset)
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	width, height := float64(b.width), float64(b.height)
+	var points []string
+	for _, offset := range offsets {
+		scale := m.ValueAt(timeline.PropertyScale, offset, 1)
+		x := m.ValueAt(timeline.PropertyPositionX, offset, 0)
+		y := m.ValueAt(timeline.PropertyPositionY, offset, 0)
+		w, h := width*scale, height*scale
+		left := (width-w)/2 + x*width/2
+		top := (height-h)/2 - y*height/2
+		points = append(points, fmt.Sprintf("%d=%s %s %s %s 1", lead+b.rate.Frames(offset),
+			formatFloat(left), formatFloat(top), formatFloat(w), formatFloat(h)))
+	}
+	return strings.Join(points, ";")
+}
+
+// audioItems 生成音频轨道的播放列表，音量不为1时添加 volume 滤镜（dB）
+func (b *builder) audioItems(tr *timeline.Track) []interface{} {
+	var items []interface{}
+	position := int64(0)
+	for _, c := range tr.Clips {
+		start, duration := b.rate.FrameRange(c.Target)
+		if duration <= 0 {
+			continue
+		}
+		b.producers++
+		in := b.rate.Frames(c.Source.Start)
+		length := b.rate.Frames(c.Source.End())
+		if length < in+duration {
+			length = in + duration
+		}
+		p := &producer{
+			ID:  fmt.Sprintf("producer%d", b.producers),
+			In:  0,
+			Out: length - 1,
+			Properties: []property{
+				{"length", strconv.FormatInt(length, 10)},
+				{"eof", "pause"},
+				{"resource", c.Path},
+				{"audio_index", "0"},
+				{"video_index", "-1"},
+				{"mlt_service", "avformat-novalidate"},
+				{"seekable", "1"},
+				{"shotcut:caption", clipName(c)},
+			},
+		}
+		if gain := c.Volume(); gain != 1 {
+			p.Filters = append(p.Filters, filter{
+				ID: b.filterID(),
+				Properties: []property{
+					{"window", "75"},
+					{"max_gain", "20dB"},
+					{"level", formatFloat(20 * math.Log10(gain))},
+					{"mlt_service", "volume"},
+					{"shotcut:filter", "audioGain"},
+				},
+			})
+		}
+		b.defs = append(b.defs, p)
+
+		if start > position {
+			items = append(items, blank{Length: start - position})
+		}
+		items = append(items, entry{Producer: p.ID, In: in, Out: in + duration - 1})
+		position = start + duration
+	}
+	return items
+}
+
+// textItems 每句字幕生成一个透明的 color 片段，用 dynamictext 绘制文字。
+// 文字框宽度为画布宽度的90%，垂直中心按字幕样式的 PositionY 换算
+func (b *builder) textItems(tr *timeline.Track) []interface{} {
+	style := tr.Style
+	if style == nil {
+		style = &timeline.TextStyle{Size: defaultTitleHeight, Color: "#FFFFFF", PositionY: -0.8}
+	}
+	font := b.opts.TitleFont
+	if font == "" {
+		font = defaultTitleFont
+	}
+	weight := "400"
+	if style.Bold {
+		weight = "700"
+	}
+	color := "#ffffffff"
+	if len(style.Color) == 7 && strings.HasPrefix(style.Color, "#") {
+		color = "#ff" + strings.ToLower(style.Color[1:])
+	}
+	size := style.Size * float64(b.height)
+	boxHeight := size * 3
+	boxTop := float64(b.height)/2 - style.PositionY*float64(b.height)/2 - boxHeight/2
+	geometry := fmt.Sprintf("%s %s %s %s 1", formatFloat(float64(b.width)*0.05), formatFloat(boxTop),
+		formatFloat(float64(b.width)*0.9), formatFloat(boxHeight))
+
+	var items []interface{}
+	position := int64(0)
+	for _, cue := range tr.Cues {
+		start, duration := b.rate.FrameRange(cue.Target)
+		if duration <= 0 {
+			continue
+		}
+		b.producers++
+		p := &producer{
+			ID:  fmt.Sprintf("producer%d", b.producers),
+			In:  0,
+			Out: duration - 1,
+			Properties: []property{
+				{"length", strconv.FormatInt(duration, 10)},
+				{"eof", "pause"},
+				{"resource", "#00000000"},
+				{"aspect_ratio", "1"},
+				{"mlt_service", "color"},
+				{"mlt_image_format", "rgba"},
+				{"shotcut:caption", cue.Text},
+			},
+			Filters: []filter{{
+				ID: b.filterID(),
+				Properties: []property{
+					// dynamictext 中 #...# 为关键字，原文中的 # 需要转义
+					{"argument", strings.ReplaceAll(cue.Text, "#", `\#`)},
+					{"geometry", geometry},
+					{"family", font},
+					{"size", formatFloat(size)},
+					{"weight", weight},
+					{"style", "normal"},
+					{"fgcolour", color},
+					{"bgcolour", "#00000000"},
+					{"olcolour", "#ff000000"},
+					{"outline", "2"},
+					{"pad", "0"},
+					{"halign", "center"},
+					{"valign", "middle"},
+					{"mlt_service", "dynamictext"},
+					{"shotcut:filter", "dynamicText"},
+				},
+			}},
+		}
+		b.defs = append(b.defs, p)
+
+		if start > position {
+			items = append(items, blank{Length: start - position})
+		}
+		items = append(items, entry{Producer: p.ID, In: 0, Out: duration - 1})
+		position = start + duration
+	}
+	return items
+}
+
+func (b *builder) filterID() string {
+	id := fmt.Sprintf("filter%d", b.filters)
+	b.filters++
+	return id
+}
+
+func (b *builder) transitionID() string {
+	id := fmt.Sprintf("transition%d", b.trans)
+	b.trans++
+	return id
+}
+
+func clipName(c *timeline.Clip) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return filepath.Base(c.Path)
+}
+
+// aspectRatio 约分后的画面比例
+func aspectRatio(width, height int) (int, int) {
+	a, b := width, height
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a == 0 {
+		return width, height
+	}
+	return width / a, height / a
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
@@ -0,0 +1,155 @@
+package mlt
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"novel-video-workflow/pkg/timeline"
+)
+
+var update = flag.Bool("update", false, "更新 testdata 中的 golden 文件")
+
+func sampleTimeline() *timeline.Timeline {
+	tl := timeline.New("chapter_01", 1080, 1920, 30)
+	tl.Chapter = 1
+	tl.Duration = 6000000
+	images := tl.AddTrack(timeline.TrackVideo, "视频轨道", timeline.RoleImages)
+	images.AddClip(&timeline.Clip{
+		ID: "image_01", Name: "scene_01.png", Media: timeline.MediaImage, Path: "/tmp/小说/scene 01.png",
+		Source: timeline.Range{Start: 0, Duration: 2000000}, Target: timeline.Range{Start: 0, Duration: 2000000},
+		Motion: &timeline.Motion{Preset: "zoom_in", Keyframes: []timeline.Keyframe{
+			{Property: timeline.PropertyScale, Offset: 0, Value: 1},
+			{Property: timeline.PropertyScale, Offset: 2000000, Value: 1.1},
+		}},
+		Transition: &timeline.Transition{Name: "淡入淡出", Duration: 500000},
+	})
+	images.AddClip(&timeline.Clip{
+		ID: "image_02", Name: "scene_02.png", Media: timeline.MediaImage, Path: "/tmp/小说/scene_02.png",
+		Source: timeline.Range{Start: 0, Duration: 1000000}, Target: timeline.Range{Start: 2000000, Duration: 1000000},
+		Motion: &timeline.Motion{Preset: "pan_left", Keyframes: []timeline.Keyframe{
+			{Property: timeline.PropertyScale, Offset: 0, Value: 1.1},
+			{Property: timeline.PropertyPositionX, Offset: 0, Value: 0.05},
+			{Property: timeline.PropertyPositionX, Offset: 1000000, Value: -0.05},
+		}},
+	})
+	// 第三张前留1秒空隙
+	images.AddClip(&timeline.Clip{
+		ID: "image_03", Name: "scene_03.png", Media: timeline.MediaImage, Path: "/tmp/小说/scene_03.png",
+		Source: timeline.Range{Start: 0, Duration: 2000000}, Target: timeline.Range{Start: 4000000, Duration: 2000000},
+	})
+	narration := tl.AddTrack(timeline.TrackAudio, "音频轨道", timeline.RoleNarration)
+	narration.AddClip(&timeline.Clip{
+		ID: "narration", Name: "chapter_01.wav", Media: timeline.MediaAudio, Path: "/tmp/小说/chapter_01.wav",
+		Source: timeline.Range{Start: 0, Duration: 6000000}, Target: timeline.Range{Start: 0, Duration: 6000000}, Gain: 1,
+	})
+	bgm := tl.AddTrack(timeline.TrackAudio, "背景音乐", timeline.RoleBGM)
+	bgm.AddClip(&timeline.Clip{
+		ID: "bgm", Name: "bgm.mp3", Media: timeline.MediaAudio, Path: "/tmp/bgm.mp3",
+		Source: timeline.Range{Start: 1000000, Duration: 5000000}, Target: timeline.Range{Start: 1000000, Duration: 5000000}, Gain: 0.5,
+	})
+	subtitles := tl.AddTrack(timeline.TrackText, "字幕轨道", timeline.RoleSubtitle)
+	subtitles.Cues = []timeline.TextCue{
+		{Target: timeline.Range{Start: 0, Duration: 2500000}, Text: "第一句"},
+		{Target: timeline.Range{Start: 3000000, Duration: 3000000}, Text: "第#2#句 & 结尾"},
+	}
+	subtitles.Style = &timeline.TextStyle{Size: 0.025, Color: "#FFCC00", Bold: true, PositionY: -0.8}
+	return tl
+}
+
+func TestEncodeGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, sampleTimeline(), Options{}); err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	path := filepath.Join("testdata", "chapter_01.mlt")
+	if *update {
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("写入 golden 文件失败: %v", err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取 golden 文件失败: %v", err)
+	}
+	if !bytes.Equal(want, buf.Bytes()) {
+		t.Errorf("输出与 %s 不一致，确认改动后使用 -update 更新:\n%s", path, buf.String())
+	}
+}
+
+// TestTransitionKeepsTiming 转场 tractor 跨在切换点两侧，视频轨道总帧数与图片区间一致
+func TestTransitionKeepsTiming(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, sampleTimeline(), Options{}); err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	var doc struct {
+		Playlists []struct {
+			ID      string `xml:"id,attr"`
+			Entries []struct {
+				Producer string `xml:"producer,attr"`
+				In       int64  `xml:"in,attr"`
+				Out      int64  `xml:"out,attr"`
+			} `xml:"entry"`
+			Blanks []struct {
+				Length int64 `xml:"length,attr"`
+			} `xml:"blank"`
+		} `xml:"playlist"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("输出不是合法XML: %v", err)
+	}
+	video := doc.Playlists[1]
+	var producers []string
+	frames := int64(0)
+	for _, e := range video.Entries {
+		producers = append(producers, e.Producer)
+		frames += e.Out - e.In + 1
+	}
+	for _, b := range video.Blanks {
+		frames += b.Length
+	}
+	// 第一张 0-53，转场 53-68，第二张 68-90，空隙 90-120，第三张 120-180
+	if strings.Join(producers, ",") != "producer1,tractor1,producer2,producer3" {
+		t.Errorf("视频轨道结构错误: %v", producers)
+	}
+	if frames != 180 {
+		t.Errorf("视频轨道总帧数应为180: %d", frames)
+	}
+	// 第二张提前7帧出现在转场中，运动关键帧后移7帧
+	if !strings.Contains(buf.String(), `<property name="transition.rect">7=-27 -96 1188 2112 1;37=-81 -96 1188 2112 1</property>`) {
+		t.Errorf("转场提前显示的图片，运动关键帧应相应后移")
+	}
+	if !strings.Contains(buf.String(), `<property name="argument">第\#2\#句 &amp; 结尾</property>`) {
+		t.Errorf("字幕中的 # 应转义")
+	}
+}
+
+func TestEncodeWellFormed(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, sampleTimeline(), Options{}); err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	decoder := xml.NewDecoder(&buf)
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("输出不是合法XML: %v", err)
+		}
+	}
+}
+
+func TestMeltArgs(t *testing.T) {
+	args := meltArgs("/tmp/chapter_01.mlt", "/tmp/chapter_01.mp4", RenderOptions{VideoCodec: "h264", Bitrate: "10M", Preset: "medium"})
+	want := "/tmp/chapter_01.mlt -consumer avformat:/tmp/chapter_01.mp4 vcodec=libx264 acodec=aac real_time=-1 vb=10M preset=medium"
+	if got := strings.Join(args, " "); got != want {
+		t.Errorf("melt参数错误:\n期望 %s\n实际 %s", want, got)
+	}
+}
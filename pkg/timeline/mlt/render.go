@@ -0,0 +1,88 @@
+package mlt
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// RenderOptions melt 渲染选项
+type RenderOptions struct {
+	Melt       string // melt 可执行文件，为空时从 PATH 查找
+	VideoCodec string // h264、h265 或 ffmpeg 编码器名称，默认 libx264
+	AudioCodec string // 默认 aac
+	Bitrate    string // 视频码率，如 10M
+	Preset     string // x264/x265 预设，如 medium
+}
+
+// LookMelt 查找 melt 可执行文件
+func LookMelt(melt string) (string, error) {
+	if melt == "" {
+		melt = "melt"
+	}
+	path, err := exec.LookPath(melt)
+	if err != nil {
+		return "", fmt.Errorf("系统中未找到melt命令，请安装 MLT（如 apt install melt）: %v", err)
+	}
+	return path, nil
+}
+
+// Render 用 melt 将 MLT 项目渲染为视频文件。melt 使用 Qt 绘制图片和字幕，
+// 在没有显示器的服务器上以 offscreen 方式运行
+func Render(project, output string, opts RenderOptions) error {
+	melt, err := LookMelt(opts.Melt)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(melt, meltArgs(project, output, opts)...)
+	cmd.Env = append(os.Environ(), "QT_QPA_PLATFORM=offscreen")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("melt渲染失败: %v\n%s", err, lastLines(string(out), 10))
+	}
+	return nil
+}
+
+// meltArgs melt 命令行参数：avformat consumer，real_time=-1 表示不丢帧并使用多线程
+func meltArgs(project, output string, opts RenderOptions) []string {
+	args := []string{project, "-consumer", "avformat:" + output,
+		"vcodec=" + videoEncoder(opts.VideoCodec),
+		"acodec=" + defaultString(opts.AudioCodec, "aac"),
+		"real_time=-1",
+	}
+	if opts.Bitrate != "" {
+		args = append(args, "vb="+opts.Bitrate)
+	}
+	if opts.Preset != "" {
+		args = append(args, "preset="+opts.Preset)
+	}
+	return args
+}
+
+// videoEncoder 将配置中的编码格式换算为 ffmpeg 编码器
+func videoEncoder(codec string) string {
+	switch strings.ToLower(codec) {
+	case "", "h264", "avc":
+		return "libx264"
+	case "h265", "hevc":
+		return "libx265"
+	default:
+		return codec
+	}
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// lastLines 返回输出的最后几行，用于错误信息
+func lastLines(out string, n int) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
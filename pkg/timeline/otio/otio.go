@@ -0,0 +1,574 @@
+// Package otio 将章节时间线导出为 OpenTimelineIO JSON（.otio），并支持把剪辑软件修改后的 .otio 读回时间线。
+//
+// 图片和旁白以外部引用（file:// URL）写入视频、音频轨道，字幕写成时间线标记；
+// OTIO 没有对应字段的信息（运动关键帧、剪映转场ID、字幕样式等）保存在各对象 metadata 的 novel_video 下，
+// 读回时优先使用这些信息，缺失时按轨道类型和文件扩展名推断
+package otio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"novel-video-workflow/pkg/timeline"
+)
+
+// Ext .otio 文件扩展名
+const Ext = ".otio"
+
+// OTIO 对象的 schema
+const (
+	schemaTimeline     = "Timeline.1"
+	schemaStack        = "Stack.1"
+	schemaTrack        = "Track.1"
+	schemaClip         = "Clip.2"
+	schemaGap          = "Gap.1"
+	schemaTransition   = "Transition.1"
+	schemaMarker       = "Marker.2"
+	schemaExternalRef  = "ExternalReference.1"
+	schemaTimeRange    = "TimeRange.1"
+	schemaRationalTime = "RationalTime.1"
+)
+
+// 轨道类型
+const (
+	kindVideo = "Video"
+	kindAudio = "Audio"
+)
+
+// defaultMediaKey Clip.2 的默认媒体引用键
+const defaultMediaKey = "DEFAULT_MEDIA"
+
+// 字幕标记颜色，原文与译文区分显示
+var markerColors = map[string]string{
+	timeline.RoleSubtitle:    "RED",
+	timeline.RoleTranslation: "GREEN",
+}
+
+type rationalTime struct {
+	Schema string  `json:"OTIO_SCHEMA"`
+	Rate   float64 `json:"rate"`
+	Value  float64 `json:"value"`
+}
+
+type timeRange struct {
+	Schema    string       `json:"OTIO_SCHEMA"`
+	StartTime rationalTime `json:"start_time"`
+	Duration  rationalTime `json:"duration"`
+}
+
+// extraInfo 保存在 metadata.novel_video 中、OTIO 没有对应字段的信息
+type extraInfo struct {
+	// 时间线
+	Version int `json:"version,omitempty"`
+	Chapter int `json:"chapter,omitempty"`
+	Width   int `json:"width,omitempty"`
+	Height  int `json:"height,omitempty"`
+	FPS     int `json:"fps,omitempty"`
+	// 轨道和字幕标记
+	Role  string              `json:"role,omitempty"`
+	Track string              `json:"track,omitempty"`
+	Style *timeline.TextStyle `json:"style,omitempty"`
+	// 片段
+	ID         string           `json:"id,omitempty"`
+	Media      string           `json:"media,omitempty"`
+	Gain       float64          `json:"gain,omitempty"`
+	Motion     *timeline.Motion `json:"motion,omitempty"`
+	SourceText string           `json:"source_text,omitempty"`
+	Mood       string           `json:"mood,omitempty"`
+	// 转场
+	EffectID   string `json:"effect_id,omitempty"`
+	ResourceID string `json:"resource_id,omitempty"`
+}
+
+type metadata struct {
+	NovelVideo *extraInfo `json:"novel_video,omitempty"`
+}
+
+func (m metadata) info() extraInfo {
+	if m.NovelVideo == nil {
+		return extraInfo{}
+	}
+	return *m.NovelVideo
+}
+
+type mediaReference struct {
+	Schema         string     `json:"OTIO_SCHEMA"`
+	Name           string     `json:"name"`
+	TargetURL      string     `json:"target_url"`
+	AvailableRange *timeRange `json:"available_range"`
+	Metadata       metadata   `json:"metadata"`
+}
+
+type marker struct {
+	Schema      string    `json:"OTIO_SCHEMA"`
+	Name        string    `json:"name"`
+	Color       string    `json:"color"`
+	Comment     string    `json:"comment"`
+	MarkedRange timeRange `json:"marked_range"`
+	Metadata    metadata  `json:"metadata"`
+}
+
+// node OTIO 中的组合对象（Stack、Track、Clip、Gap、Transition），按 schema 只写出相应字段
+type node struct {
+	Schema   string   `json:"OTIO_SCHEMA"`
+	Name     string   `json:"name"`
+	Metadata metadata `json:"metadata"`
+
+	// Stack、Track
+	Kind     string  `json:"kind,omitempty"`
+	Children []*node `json:"children,omitempty"`
+
+	// Stack、Track、Clip、Gap
+	SourceRange *timeRange `json:"source_range,omitempty"`
+	Markers     []marker   `json:"markers,omitempty"`
+	Enabled     *bool      `json:"enabled,omitempty"`
+
+	// Clip.2 使用 media_references，Clip.1 使用 media_reference
+	MediaReferences         map[string]*mediaReference `json:"media_references,omitempty"`
+	ActiveMediaReferenceKey string                     `json:"active_media_reference_key,omitempty"`
+	MediaReference          *mediaReference            `json:"media_reference,omitempty"`
+
+	// Transition
+	TransitionType string        `json:"transition_type,omitempty"`
+	InOffset       *rationalTime `json:"in_offset,omitempty"`
+	OutOffset      *rationalTime `json:"out_offset,omitempty"`
+}
+
+type document struct {
+	Schema          string        `json:"OTIO_SCHEMA"`
+	Name            string        `json:"name"`
+	GlobalStartTime *rationalTime `json:"global_start_time"`
+	Metadata        metadata      `json:"metadata"`
+	Tracks          *node         `json:"tracks"`
+}
+
+// Export 将时间线写入 .otio 文件
+func Export(tl *timeline.Timeline, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建OTIO文件失败: %v", err)
+	}
+	defer file.Close()
+	return Encode(file, tl)
+}
+
+// Encode 将时间线编码为 OTIO JSON，时间按时间线帧率取整到帧
+func Encode(w io.Writer, tl *timeline.Timeline) error {
+	if err := tl.Validate(); err != nil {
+		return err
+	}
+	rate := float64(tl.FPS)
+	stack := &node{Schema: schemaStack, Name: "tracks"}
+
+	for _, track := range tl.Tracks {
+		switch track.Kind {
+		case timeline.TrackVideo, timeline.TrackAudio:
+			stack.Children = append(stack.Children, encodeTrack(track, rate))
+		case timeline.TrackText:
+			// 字幕写成时间线标记，样式保存在标记的 metadata 中
+			for _, cue := range track.Cues {
+				stack.Markers = append(stack.Markers, marker{
+					Schema:      schemaMarker,
+					Name:        cue.Text,
+					Color:       markerColor(track.Role),
+					Comment:     cue.Text,
+					MarkedRange: *newTimeRange(cue.Target.Start, cue.Target.Duration, rate),
+					Metadata:    metadata{NovelVideo: &extraInfo{Role: track.Role, Track: track.Name, Style: track.Style}},
+				})
+			}
+		}
+	}
+	if stack.Children == nil {
+		stack.Children = []*node{}
+	}
+
+	doc := document{
+		Schema: schemaTimeline,
+		Name:   tl.Name,
+		Metadata: metadata{NovelVideo: &extraInfo{
+			Version: tl.Version, Chapter: tl.Chapter, Width: tl.Width, Height: tl.Height, FPS: tl.FPS,
+		}},
+		Tracks: stack,
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("写入OTIO失败: %v", err)
+	}
+	return nil
+}
+
+// encodeTrack 按时间顺序写出片段，片段之间的空隙写成 Gap，转场写在两个片段之间
+func encodeTrack(track *timeline.Track, rate float64) *node {
+	kind := kindVideo
+	if track.Kind == timeline.TrackAudio {
+		kind = kindAudio
+	}
+	out := &node{
+		Schema:   schemaTrack,
+		Name:     track.Name,
+		Kind:     kind,
+		Children: []*node{},
+		Metadata: metadata{NovelVideo: &extraInfo{Role: track.Role}},
+	}
+
+	cursor := int64(0) // 已写出的帧数
+	for i, clip := range track.Clips {
+		start, end := toFrames(clip.Target.Start, rate), toFrames(clip.Target.End(), rate)
+		if start > cursor {
+			out.Children = append(out.Children, &node{
+				Schema:      schemaGap,
+				SourceRange: frameRange(0, start-cursor, rate),
+				Enabled:     boolPtr(true),
+			})
+		}
+		out.Children = append(out.Children, encodeClip(clip, end-start, rate))
+		cursor = end
+
+		if t := clip.Transition; t != nil && i+1 < len(track.Clips) {
+			// 转场跨在切换点两侧，in_offset 为切换点之前的部分
+			frames := toFrames(t.Duration, rate)
+			in := frames / 2
+			out.Children = append(out.Children, &node{
+				Schema:         schemaTransition,
+				Name:           t.Name,
+				TransitionType: "SMPTE_Dissolve",
+				InOffset:       &rationalTime{Schema: schemaRationalTime, Rate: rate, Value: float64(in)},
+				OutOffset:      &rationalTime{Schema: schemaRationalTime, Rate: rate, Value: float64(frames - in)},
+				Metadata:       metadata{NovelVideo: &extraInfo{EffectID: t.EffectID, ResourceID: t.ResourceID}},
+			})
+		}
+	}
+	return out
+}
+
+// encodeClip 写出片段，素材以外部引用的形式给出
+func encodeClip(clip *timeline.Clip, frames int64, rate float64) *node {
+	ref := &mediaReference{
+		Schema:    schemaExternalRef,
+		TargetURL: timeline.FileURL(clip.Path),
+	}
+	if clip.Media != timeline.MediaImage {
+		// 音视频素材的可用范围为文件总时长，未知时至少覆盖片段使用的部分，静态图片没有固定长度
+		ref.AvailableRange = newTimeRange(0, clip.AvailableDuration(), rate)
+	}
+	name := clip.Name
+	if name == "" {
+		name = filepath.Base(clip.Path)
+	}
+	return &node{
+		Schema:                  schemaClip,
+		Name:                    name,
+		SourceRange:             frameRange(toFrames(clip.Source.Start, rate), frames, rate),
+		Enabled:                 boolPtr(true),
+		MediaReferences:         map[string]*mediaReference{defaultMediaKey: ref},
+		ActiveMediaReferenceKey: defaultMediaKey,
+		Metadata: metadata{NovelVideo: &extraInfo{
+			ID:         clip.ID,
+			Media:      clip.Media,
+			Gain:       clip.Gain,
+			Motion:     clip.Motion,
+			SourceText: clip.SourceText,
+			Mood:       clip.Mood,
+		}},
+	}
+}
+
+// Import 读取 .otio 文件
+func Import(path string) (*timeline.Timeline, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取OTIO文件失败: %v", err)
+	}
+	defer file.Close()
+	return Decode(file)
+}
+
+// Decode 解析 OTIO JSON 并转换为时间线。
+// 片段的时间按轨道中的先后顺序累加得到，转场挂在它之前的片段上；
+// 剪辑软件修改了片段时长时，运动关键帧按新时长等比缩放
+func Decode(r io.Reader) (*timeline.Timeline, error) {
+	var doc document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("解析OTIO失败: %v", err)
+	}
+	if doc.Schema != schemaTimeline && !strings.HasPrefix(doc.Schema, "Timeline.") {
+		return nil, fmt.Errorf("不是OTIO时间线: %s", doc.Schema)
+	}
+	if doc.Tracks == nil {
+		return nil, fmt.Errorf("OTIO时间线没有轨道")
+	}
+
+	info := doc.Metadata.info()
+	tl := timeline.New(doc.Name, info.Width, info.Height, info.FPS)
+	tl.Chapter = info.Chapter
+	if tl.Width <= 0 || tl.Height <= 0 {
+		tl.Width, tl.Height = timeline.DefaultWidth, timeline.DefaultHeight
+	}
+	if tl.FPS <= 0 {
+		tl.FPS = int(math.Round(firstRate(doc.Tracks)))
+		if tl.FPS <= 0 {
+			tl.FPS = timeline.DefaultFPS
+		}
+	}
+
+	videoTracks, audioTracks := 0, 0
+	for _, child := range doc.Tracks.Children {
+		if child.Schema != schemaTrack && !strings.HasPrefix(child.Schema, "Track.") {
+			continue
+		}
+		kind, role := timeline.TrackVideo, child.Metadata.info().Role
+		if child.Kind == kindAudio {
+			kind = timeline.TrackAudio
+		}
+		// 没有 novel_video 信息的轨道：第一条视频轨道为分镜图片，第一条音频轨道为旁白，其余音频为背景音乐
+		if role == "" {
+			switch {
+			case kind == timeline.TrackVideo && videoTracks == 0:
+				role = timeline.RoleImages
+			case kind == timeline.TrackAudio && audioTracks == 0:
+				role = timeline.RoleNarration
+			case kind == timeline.TrackAudio:
+				role = timeline.RoleBGM
+			}
+		}
+		if kind == timeline.TrackVideo {
+			videoTracks++
+		} else {
+			audioTracks++
+		}
+
+		track := tl.AddTrack(kind, child.Name, role)
+		if err := decodeTrack(track, child); err != nil {
+			return nil, err
+		}
+	}
+
+	markers := append([]marker{}, doc.Tracks.Markers...)
+	for _, child := range doc.Tracks.Children {
+		markers = append(markers, child.Markers...)
+	}
+	decodeMarkers(tl, markers)
+
+	for _, track := range tl.Tracks {
+		for _, clip := range track.Clips {
+			if end := clip.Target.End(); end > tl.Duration {
+				tl.Duration = end
+			}
+		}
+		for _, cue := range track.Cues {
+			if end := cue.Target.End(); end > tl.Duration {
+				tl.Duration = end
+			}
+		}
+	}
+
+	if err := tl.Validate(); err != nil {
+		return nil, err
+	}
+	return tl, nil
+}
+
+// decodeTrack 按先后顺序读取轨道中的片段、空隙和转场
+func decodeTrack(track *timeline.Track, in *node) error {
+	var cursor float64 // 秒
+	for _, child := range in.Children {
+		schema := strings.SplitN(child.Schema, ".", 2)[0]
+		switch schema {
+		case "Gap":
+			if child.SourceRange != nil {
+				cursor += seconds(child.SourceRange.Duration)
+			}
+		case "Clip":
+			if child.SourceRange == nil {
+				return fmt.Errorf("片段 %s 缺少 source_range", child.Name)
+			}
+			clip := decodeClip(track, child, cursor)
+			cursor += seconds(child.SourceRange.Duration)
+			if clip.Target.Duration > 0 {
+				track.AddClip(clip)
+			}
+		case "Transition":
+			if len(track.Clips) == 0 {
+				continue
+			}
+			info := child.Metadata.info()
+			var duration float64
+			if child.InOffset != nil {
+				duration += seconds(*child.InOffset)
+			}
+			if child.OutOffset != nil {
+				duration += seconds(*child.OutOffset)
+			}
+			track.Clips[len(track.Clips)-1].Transition = &timeline.Transition{
+				Name:       child.Name,
+				Duration:   microseconds(duration),
+				EffectID:   info.EffectID,
+				ResourceID: info.ResourceID,
+			}
+		}
+	}
+	return nil
+}
+
+// decodeClip 读取片段，cursor 为片段在轨道上的开始时间（秒）
+func decodeClip(track *timeline.Track, in *node, cursor float64) *timeline.Clip {
+	info := in.Metadata.info()
+	ref := in.MediaReference
+	if in.MediaReferences != nil {
+		key := in.ActiveMediaReferenceKey
+		if key == "" {
+			key = defaultMediaKey
+		}
+		ref = in.MediaReferences[key]
+	}
+
+	clip := &timeline.Clip{
+		ID:         info.ID,
+		Name:       in.Name,
+		Media:      info.Media,
+		Gain:       info.Gain,
+		SourceText: info.SourceText,
+		Mood:       info.Mood,
+	}
+	if ref != nil {
+		clip.Path = timeline.PathFromURL(ref.TargetURL)
+	}
+	if clip.ID == "" {
+		clip.ID = fmt.Sprintf("%s_%02d", track.Role, len(track.Clips)+1)
+	}
+	if clip.Media == "" {
+		clip.Media = guessMedia(track.Kind, clip.Path)
+	}
+
+	start := microseconds(cursor)
+	sourceStart := microseconds(seconds(in.SourceRange.StartTime))
+	clip.Target = timeline.Range{Start: start, Duration: microseconds(cursor+seconds(in.SourceRange.Duration)) - start}
+	clip.Source = timeline.Range{Start: sourceStart, Duration: clip.Target.Duration}
+	clip.Motion = rescaleMotion(info.Motion, clip.Target.Duration)
+	return clip
+}
+
+// decodeMarkers 将字幕标记按轨道名称分组为文本轨道，没有 novel_video 信息的标记作为原文字幕
+func decodeMarkers(tl *timeline.Timeline, markers []marker) {
+	tracks := make(map[string]*timeline.Track)
+	for _, m := range markers {
+		info := m.Metadata.info()
+		role, name := info.Role, info.Track
+		if role == "" {
+			role = timeline.RoleSubtitle
+		}
+		if name == "" {
+			name = role
+		}
+		track, ok := tracks[name]
+		if !ok {
+			track = tl.AddTrack(timeline.TrackText, name, role)
+			track.Style = info.Style
+			tracks[name] = track
+		}
+		text := m.Name
+		if text == "" {
+			text = m.Comment
+		}
+		start := microseconds(seconds(m.MarkedRange.StartTime))
+		track.Cues = append(track.Cues, timeline.TextCue{
+			Target: timeline.Range{Start: start, Duration: microseconds(seconds(m.MarkedRange.StartTime)+seconds(m.MarkedRange.Duration)) - start},
+			Text:   text,
+		})
+	}
+}
+
+// rescaleMotion 片段时长变化时按比例缩放关键帧时间，使运动仍然覆盖整个片段
+func rescaleMotion(motion *timeline.Motion, duration int64) *timeline.Motion {
+	if motion == nil {
+		return nil
+	}
+	var last int64
+	for _, kf := range motion.Keyframes {
+		if kf.Offset > last {
+			last = kf.Offset
+		}
+	}
+	if last <= 0 || last == duration {
+		return motion
+	}
+	scaled := &timeline.Motion{Preset: motion.Preset, Keyframes: make([]timeline.Keyframe, len(motion.Keyframes))}
+	for i, kf := range motion.Keyframes {
+		kf.Offset = int64(math.Round(float64(kf.Offset) * float64(duration) / float64(last)))
+		scaled.Keyframes[i] = kf
+	}
+	return scaled
+}
+
+// guessMedia 按轨道类型和扩展名推断素材类型
+func guessMedia(kind timeline.TrackKind, path string) string {
+	if kind == timeline.TrackAudio {
+		return timeline.MediaAudio
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".webp", ".bmp", ".tif", ".tiff":
+		return timeline.MediaImage
+	}
+	return timeline.MediaVideo
+}
+
+// firstRate 返回第一个片段或空隙的帧率，没有时返回0
+func firstRate(stack *node) float64 {
+	for _, track := range stack.Children {
+		for _, child := range track.Children {
+			if child.SourceRange != nil && child.SourceRange.Duration.Rate > 0 {
+				return child.SourceRange.Duration.Rate
+			}
+		}
+	}
+	return 0
+}
+
+func markerColor(role string) string {
+	if color, ok := markerColors[role]; ok {
+		return color
+	}
+	return "RED"
+}
+
+// toFrames 微秒换算为帧数，四舍五入
+func toFrames(us int64, rate float64) int64 {
+	return int64(math.Round(float64(us) * rate / 1e6))
+}
+
+func frameRange(start, duration int64, rate float64) *timeRange {
+	return &timeRange{
+		Schema:    schemaTimeRange,
+		StartTime: rationalTime{Schema: schemaRationalTime, Rate: rate, Value: float64(start)},
+		Duration:  rationalTime{Schema: schemaRationalTime, Rate: rate, Value: float64(duration)},
+	}
+}
+
+// newTimeRange 将微秒区间换算为帧区间，结束帧单独取整，保证相邻区间首尾相接
+func newTimeRange(start, duration int64, rate float64) *timeRange {
+	startFrame := toFrames(start, rate)
+	return frameRange(startFrame, toFrames(start+duration, rate)-startFrame, rate)
+}
+
+// seconds RationalTime 换算为秒
+func seconds(t rationalTime) float64 {
+	if t.Rate <= 0 {
+		return 0
+	}
+	return t.Value / t.Rate
+}
+
+func microseconds(seconds float64) int64 {
+	return int64(math.Round(seconds * 1e6))
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
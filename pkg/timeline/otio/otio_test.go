@@ -0,0 +1,154 @@
+package otio
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"novel-video-workflow/pkg/timeline"
+)
+
+// sampleTimeline 时间都取整到 30fps 的帧，往返后应保持不变
+func sampleTimeline() *timeline.Timeline {
+	tl := timeline.New("chapter_01", 1080, 1920, 30)
+	tl.Chapter = 1
+	tl.Duration = 6000000
+	images := tl.AddTrack(timeline.TrackVideo, "视频轨道", timeline.RoleImages)
+	images.AddClip(&timeline.Clip{
+		ID: "image_01", Name: "scene_01.png", Media: timeline.MediaImage, Path: "/tmp/小说/scene 01.png",
+		Source: timeline.Range{Start: 0, Duration: 2000000}, Target: timeline.Range{Start: 0, Duration: 2000000},
+		Motion: &timeline.Motion{Preset: "zoom_in", Keyframes: []timeline.Keyframe{
+			{Property: timeline.PropertyScale, Offset: 0, Value: 1},
+			{Property: timeline.PropertyScale, Offset: 2000000, Value: 1.1},
+		}},
+		Transition: &timeline.Transition{Name: "淡入淡出", Duration: 500000, EffectID: "322577", ResourceID: "6724845717472416269"},
+		SourceText: "夜色渐深",
+		Mood:       "阴冷",
+	})
+	// 第二张前留1秒空隙
+	images.AddClip(&timeline.Clip{
+		ID: "image_02", Name: "scene_02.png", Media: timeline.MediaImage, Path: "/tmp/小说/scene_02.png",
+		Source: timeline.Range{Start: 0, Duration: 3000000}, Target: timeline.Range{Start: 3000000, Duration: 3000000},
+	})
+	narration := tl.AddTrack(timeline.TrackAudio, "音频轨道", timeline.RoleNarration)
+	narration.AddClip(&timeline.Clip{
+		ID: "narration", Name: "chapter_01.wav", Media: timeline.MediaAudio, Path: "/tmp/小说/chapter_01.wav",
+		Source: timeline.Range{Start: 0, Duration: 6000000}, Target: timeline.Range{Start: 0, Duration: 6000000}, Gain: 1,
+	})
+	bgm := tl.AddTrack(timeline.TrackAudio, "背景音乐", timeline.RoleBGM)
+	bgm.AddClip(&timeline.Clip{
+		ID: "bgm", Name: "bgm.mp3", Media: timeline.MediaAudio, Path: "/tmp/bgm.mp3",
+		Source: timeline.Range{Start: 1000000, Duration: 6000000}, Target: timeline.Range{Start: 0, Duration: 6000000}, Gain: 0.3,
+	})
+	subtitles := tl.AddTrack(timeline.TrackText, "字幕轨道", timeline.RoleSubtitle)
+	subtitles.Cues = []timeline.TextCue{
+		{Target: timeline.Range{Start: 0, Duration: 3000000}, Text: "第一句"},
+		{Target: timeline.Range{Start: 3000000, Duration: 3000000}, Text: "第二句"},
+	}
+	subtitles.Style = &timeline.TextStyle{Size: 0.025, Color: "#FFFFFF", Bold: true, PositionY: -0.8}
+	translated := tl.AddTrack(timeline.TrackText, "译文字幕轨道", timeline.RoleTranslation)
+	translated.Cues = []timeline.TextCue{{Target: timeline.Range{Start: 0, Duration: 6000000}, Text: "First line"}}
+	return tl
+}
+
+func TestEncodeStructure(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, sampleTimeline()); err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("输出不是合法JSON: %v", err)
+	}
+	if doc["OTIO_SCHEMA"] != "Timeline.1" {
+		t.Errorf("根对象应为 Timeline.1: %v", doc["OTIO_SCHEMA"])
+	}
+	stack := doc["tracks"].(map[string]interface{})
+	tracks := stack["children"].([]interface{})
+	if len(tracks) != 3 {
+		t.Fatalf("应有1条视频轨道和2条音频轨道: %d", len(tracks))
+	}
+	var schemas []string
+	for _, child := range tracks[0].(map[string]interface{})["children"].([]interface{}) {
+		schemas = append(schemas, child.(map[string]interface{})["OTIO_SCHEMA"].(string))
+	}
+	if strings.Join(schemas, ",") != "Clip.2,Transition.1,Gap.1,Clip.2" {
+		t.Errorf("视频轨道结构错误: %v", schemas)
+	}
+	if len(stack["markers"].([]interface{})) != 3 {
+		t.Errorf("字幕应写成3个标记")
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, `"target_url": "file:///tmp/%E5%B0%8F%E8%AF%B4/scene%2001.png"`) {
+		t.Errorf("素材应以 file URL 引用: %s", out)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	original := sampleTimeline()
+	path := filepath.Join(t.TempDir(), "chapter_01"+Ext)
+	if err := Export(original, path); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	loaded, err := Import(path)
+	if err != nil {
+		t.Fatalf("导入失败: %v", err)
+	}
+
+	want, _ := json.Marshal(original)
+	got, _ := json.Marshal(loaded)
+	if !bytes.Equal(want, got) {
+		t.Errorf("往返后时间线不一致:\n期望 %s\n实际 %s", want, got)
+	}
+}
+
+// TestDecodeEditedTimeline 剪辑软件改动后的 OTIO：没有 novel_video 信息、帧率不同、片段被拉长
+func TestDecodeEditedTimeline(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, sampleTimeline()); err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	var doc document
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	// 第一张图片拉长到3秒（90帧），并去掉轨道的 role
+	video := doc.Tracks.Children[0]
+	video.Metadata = metadata{}
+	video.Children[0].SourceRange.Duration.Value = 90
+	doc.Tracks.Children[1].Metadata = metadata{}
+	doc.Tracks.Markers[0].Metadata = metadata{}
+	data, _ := json.Marshal(doc)
+
+	tl, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	images := tl.TrackByRole(timeline.RoleImages)
+	if images == nil || len(images.Clips) != 2 {
+		t.Fatalf("没有 role 的第一条视频轨道应作为分镜图片: %+v", tl.Tracks)
+	}
+	first, second := images.Clips[0], images.Clips[1]
+	if first.Target.Duration != 3000000 || second.Target.Start != 4000000 {
+		t.Errorf("片段时间应按轨道顺序累加: %+v / %+v", first.Target, second.Target)
+	}
+	if last := first.Motion.Keyframes[len(first.Motion.Keyframes)-1]; last.Offset != 3000000 {
+		t.Errorf("运动关键帧应按新时长缩放: %d", last.Offset)
+	}
+	if first.Path != "/tmp/小说/scene 01.png" {
+		t.Errorf("素材路径应从 file URL 还原: %s", first.Path)
+	}
+	if tl.TrackByRole(timeline.RoleNarration) == nil {
+		t.Errorf("没有 role 的第一条音频轨道应作为旁白")
+	}
+	if tl.TrackByRole(timeline.RoleSubtitle) == nil {
+		t.Errorf("没有 novel_video 信息的标记应作为原文字幕")
+	}
+	if tl.Duration != 7000000 {
+		t.Errorf("时间线时长应取各轨道的最晚结束时间: %d", tl.Duration)
+	}
+}
@@ -0,0 +1,291 @@
+// Package timeline 定义与剪辑软件格式无关的章节时间线。
+// 时间线按章节保存为带版本号的 timeline.json，剪映草稿及其他导出格式都由它生成
+package timeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Version 当前的时间线文件版本，结构发生不兼容变化时递增
+const Version = 1
+
+// FileName 章节目录中时间线文件的文件名
+const FileName = "timeline.json"
+
+// 默认画布：1080x1920 手机竖屏，30fps
+const (
+	DefaultWidth  = 1080
+	DefaultHeight = 1920
+	DefaultFPS    = 30
+)
+
+// TrackKind 轨道类型
+type TrackKind string
+
+const (
+	TrackVideo TrackKind = "video" // 图片或视频
+	TrackAudio TrackKind = "audio" // 旁白、背景音乐
+	TrackText  TrackKind = "text"  // 字幕
+)
+
+// 轨道用途，导出时据此决定层级、音量处理和字幕样式
+const (
+	RoleImages      = "images"      // 分镜图片
+	RoleNarration   = "narration"   // 旁白
+	RoleBGM         = "bgm"         // 背景音乐
+	RoleSubtitle    = "subtitle"    // 原文字幕
+	RoleTranslation = "translation" // 译文字幕
+)
+
+// 片段素材类型
+const (
+	MediaImage = "image"
+	MediaVideo = "video"
+	MediaAudio = "audio"
+)
+
+// 运动关键帧控制的属性
+const (
+	PropertyScale     = "scale"      // 等比缩放，1.0为原始大小
+	PropertyPositionX = "position_x" // 水平位移，右移为正，单位为半个画布宽
+	PropertyPositionY = "position_y" // 垂直位移，上移为正，单位为半个画布高
+)
+
+// Range 时间区间，单位为微秒
+type Range struct {
+	Start    int64 `json:"start"`
+	Duration int64 `json:"duration"`
+}
+
+// End 区间结束时间
+func (r Range) End() int64 {
+	return r.Start + r.Duration
+}
+
+// Keyframe 运动关键帧，Offset 为相对片段开头的时间偏移（微秒），关键帧之间线性插值
+type Keyframe struct {
+	Property string  `json:"property"`
+	Offset   int64   `json:"offset"`
+	Value    float64 `json:"value"`
+}
+
+// Motion 片段的运动效果
+type Motion struct {
+	Preset    string     `json:"preset"`
+	Keyframes []Keyframe `json:"keyframes"`
+}
+
+// ValueAt 返回属性在 offset 处的插值，没有该属性的关键帧时返回 fallback
+func (m *Motion) ValueAt(property string, offset int64, fallback float64) float64 {
+	if m == nil {
+		return fallback
+	}
+	var points []Keyframe
+	for _, kf := range m.Keyframes {
+		if kf.Property == property {
+			points = append(points, kf)
+		}
+	}
+	if len(points) == 0 {
+		return fallback
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Offset < points[j].Offset })
+	if offset <= points[0].Offset {
+		return points[0].Value
+	}
+	for i := 1; i < len(points); i++ {
+		if offset <= points[i].Offset {
+			prev, next := points[i-1], points[i]
+			if next.Offset == prev.Offset {
+				return next.Value
+			}
+			ratio := float64(offset-prev.Offset) / float64(next.Offset-prev.Offset)
+			return prev.Value + ratio*(next.Value-prev.Value)
+		}
+	}
+	return points[len(points)-1].Value
+}
+
+// Transition 切换到下一个片段时的转场，跨在切换点两侧。
+// EffectID、ResourceID 为剪映转场目录中的资源，其他格式按名称和时长处理
+type Transition struct {
+	Name       string `json:"name"`
+	Duration   int64  `json:"duration"`
+	EffectID   string `json:"effect_id,omitempty"`
+	ResourceID string `json:"resource_id,omitempty"`
+}
+
+// Clip 轨道上的一个素材片段
+type Clip struct {
+	ID            string      `json:"id"`
+	Name          string      `json:"name"`
+	Media         string      `json:"media"` // image, video, audio
+	Path          string      `json:"path"`  // 素材文件的绝对路径
+	Source        Range       `json:"source"`
+	Target        Range       `json:"target"`
+	MediaDuration int64       `json:"media_duration,omitempty"` // 音视频素材文件的总时长（微秒），0表示未知
+	Gain          float64     `json:"gain,omitempty"`           // 音量倍数，1.0为原始音量，0表示未设置
+	Motion        *Motion     `json:"motion,omitempty"`
+	Transition    *Transition `json:"transition_out,omitempty"`
+	SourceText    string      `json:"source_text,omitempty"` // 分镜对应的原文
+	Mood          string      `json:"mood,omitempty"`        // 分镜氛围
+}
+
+// AvailableDuration 返回素材可用的时长：已知文件总时长时为总时长，否则至少覆盖片段使用的部分
+func (c *Clip) AvailableDuration() int64 {
+	if c.MediaDuration > c.Source.End() {
+		return c.MediaDuration
+	}
+	return c.Source.End()
+}
+
+// Volume 返回片段音量，未设置时为1.0
+func (c *Clip) Volume() float64 {
+	if c.Gain <= 0 {
+		return 1.0
+	}
+	return c.Gain
+}
+
+// TextCue 一条字幕
+type TextCue struct {
+	Target Range  `json:"target"`
+	Text   string `json:"text"`
+}
+
+// TextStyle 字幕样式
+type TextStyle struct {
+	Size      float64 `json:"size"`  // 字号占画布高度的比例
+	Color     string  `json:"color"` // #RRGGBB
+	Bold      bool    `json:"bold,omitempty"`
+	PositionY float64 `json:"position_y"` // 垂直位置，-1为画面底边，1为顶边
+}
+
+// Track 一条轨道，视频和音频轨道包含片段，文本轨道包含字幕
+type Track struct {
+	Kind  TrackKind  `json:"kind"`
+	Name  string     `json:"name"`
+	Role  string     `json:"role,omitempty"`
+	Clips []*Clip    `json:"clips,omitempty"`
+	Cues  []TextCue  `json:"cues,omitempty"`
+	Style *TextStyle `json:"style,omitempty"`
+}
+
+// Timeline 章节时间线
+type Timeline struct {
+	Version  int      `json:"version"`
+	Name     string   `json:"name"`
+	Chapter  int      `json:"chapter,omitempty"`
+	Width    int      `json:"width"`
+	Height   int      `json:"height"`
+	FPS      int      `json:"fps"`
+	Duration int64    `json:"duration"` // 微秒
+	Tracks   []*Track `json:"tracks"`
+}
+
+// New 创建空时间线
+func New(name string, width, height, fps int) *Timeline {
+	return &Timeline{Version: Version, Name: name, Width: width, Height: height, FPS: fps}
+}
+
+// AddTrack 添加一条轨道，渲染层级按添加顺序递增
+func (t *Timeline) AddTrack(kind TrackKind, name, role string) *Track {
+	track := &Track{Kind: kind, Name: name, Role: role}
+	t.Tracks = append(t.Tracks, track)
+	return track
+}
+
+// TracksOf 返回指定类型的全部轨道
+func (t *Timeline) TracksOf(kind TrackKind) []*Track {
+	var tracks []*Track
+	for _, track := range t.Tracks {
+		if track.Kind == kind {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks
+}
+
+// TrackByRole 返回第一条指定用途的轨道，没有时返回nil
+func (t *Timeline) TrackByRole(role string) *Track {
+	for _, track := range t.Tracks {
+		if track.Role == role {
+			return track
+		}
+	}
+	return nil
+}
+
+// AddClip 在轨道末尾添加片段
+func (tr *Track) AddClip(clip *Clip) *Clip {
+	tr.Clips = append(tr.Clips, clip)
+	return clip
+}
+
+// Validate 检查时间线：区间不能为负，同一轨道上的片段和字幕按时间排列且互不重叠
+func (t *Timeline) Validate() error {
+	if t.Width <= 0 || t.Height <= 0 || t.FPS <= 0 {
+		return fmt.Errorf("时间线画布或帧率无效: %dx%d@%d", t.Width, t.Height, t.FPS)
+	}
+	for _, track := range t.Tracks {
+		var end int64
+		for i, clip := range track.Clips {
+			if clip.Target.Start < 0 || clip.Target.Duration <= 0 || clip.Source.Start < 0 {
+				return fmt.Errorf("轨道 %s 的第%d个片段区间无效", track.Name, i+1)
+			}
+			if clip.Target.Start < end {
+				return fmt.Errorf("轨道 %s 的第%d个片段与前一个片段重叠", track.Name, i+1)
+			}
+			end = clip.Target.End()
+		}
+		end = 0
+		for i, cue := range track.Cues {
+			if cue.Target.Start < end || cue.Target.Duration < 0 {
+				return fmt.Errorf("轨道 %s 的第%d条字幕时间无效", track.Name, i+1)
+			}
+			end = cue.Target.End()
+		}
+	}
+	return nil
+}
+
+// Save 将时间线保存为 dir 下的 timeline.json，返回文件路径
+func (t *Timeline) Save(dir string) (string, error) {
+	if t.Version == 0 {
+		t.Version = Version
+	}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("序列化时间线失败: %v", err)
+	}
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("写入时间线失败: %v", err)
+	}
+	return path, nil
+}
+
+// Load 读取 dir 下的 timeline.json
+func Load(dir string) (*Timeline, error) {
+	return LoadFile(filepath.Join(dir, FileName))
+}
+
+// LoadFile 读取时间线文件，版本高于当前支持的版本时报错
+func LoadFile(path string) (*Timeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取时间线失败: %v", err)
+	}
+	var t Timeline
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("解析时间线失败: %v", err)
+	}
+	if t.Version > Version {
+		return nil, fmt.Errorf("时间线版本 %d 高于当前支持的版本 %d", t.Version, Version)
+	}
+	return &t, nil
+}
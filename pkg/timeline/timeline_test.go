@@ -0,0 +1,125 @@
+package timeline
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func sampleTimeline() *Timeline {
+	tl := New("chapter_01", 1080, 1920, 30)
+	tl.Chapter = 1
+	tl.Duration = 6000000
+	images := tl.AddTrack(TrackVideo, "视频轨道", RoleImages)
+	images.AddClip(&Clip{
+		ID: "image_01", Media: MediaImage, Path: "/tmp/a.png",
+		Source: Range{0, 3000000}, Target: Range{0, 3000000},
+		Motion:     &Motion{Preset: "zoom_in", Keyframes: []Keyframe{{PropertyScale, 0, 1}, {PropertyScale, 3000000, 1.2}}},
+		Transition: &Transition{Name: "淡入淡出", Duration: 500000},
+	})
+	images.AddClip(&Clip{ID: "image_02", Media: MediaImage, Path: "/tmp/b.png", Source: Range{0, 3000000}, Target: Range{3000000, 3000000}})
+	narration := tl.AddTrack(TrackAudio, "音频轨道", RoleNarration)
+	narration.AddClip(&Clip{ID: "narration", Media: MediaAudio, Path: "/tmp/a.wav", Source: Range{0, 6000000}, Target: Range{0, 6000000}, Gain: 1})
+	subtitles := tl.AddTrack(TrackText, "字幕轨道", RoleSubtitle)
+	subtitles.Cues = []TextCue{{Range{0, 3000000}, "第一句"}, {Range{3000000, 3000000}, "第二句"}}
+	subtitles.Style = &TextStyle{Size: 0.025, Color: "#FFFFFF", Bold: true, PositionY: -0.8}
+	return tl
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	tl := sampleTimeline()
+	path, err := tl.Save(dir)
+	if err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	if filepath.Base(path) != FileName {
+		t.Errorf("文件名错误: %s", path)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if loaded.Version != Version || len(loaded.Tracks) != 3 {
+		t.Fatalf("读取的时间线不完整: %+v", loaded)
+	}
+	clip := loaded.TrackByRole(RoleImages).Clips[0]
+	if clip.Transition == nil || clip.Transition.Name != "淡入淡出" || clip.Motion == nil || len(clip.Motion.Keyframes) != 2 {
+		t.Errorf("片段的转场或运动丢失: %+v", clip)
+	}
+	if got := loaded.TrackByRole(RoleSubtitle).Style; got == nil || got.Size != 0.025 {
+		t.Errorf("字幕样式丢失: %+v", got)
+	}
+}
+
+func TestLoadRejectsNewerVersion(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(`{"version": 99}`), 0644); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Errorf("高于当前支持的版本应报错")
+	}
+}
+
+func TestValidateRejectsOverlap(t *testing.T) {
+	tl := sampleTimeline()
+	if err := tl.Validate(); err != nil {
+		t.Fatalf("示例时间线应有效: %v", err)
+	}
+	tl.TrackByRole(RoleImages).Clips[1].Target.Start = 2000000
+	if err := tl.Validate(); err == nil {
+		t.Errorf("片段重叠时应报错")
+	}
+}
+
+func TestMotionValueAt(t *testing.T) {
+	m := &Motion{Keyframes: []Keyframe{{PropertyScale, 1000, 1.2}, {PropertyScale, 0, 1}}}
+	if v := m.ValueAt(PropertyScale, 500, 1); math.Abs(v-1.1) > 1e-9 {
+		t.Errorf("插值错误: %f", v)
+	}
+	if v := m.ValueAt(PropertyScale, 2000, 1); v != 1.2 {
+		t.Errorf("超出最后一个关键帧应保持末值: %f", v)
+	}
+	if v := m.ValueAt(PropertyPositionX, 500, 0); v != 0 {
+		t.Errorf("没有关键帧时应返回默认值: %f", v)
+	}
+	var none *Motion
+	if v := none.ValueAt(PropertyScale, 0, 1); v != 1 {
+		t.Errorf("nil 运动应返回默认值: %f", v)
+	}
+}
+
+func TestParseFrameRate(t *testing.T) {
+	cases := map[string]FrameRate{
+		"30":         {Num: 30, Den: 1},
+		"29.97":      {Num: 30000, Den: 1001},
+		"23.976":     {Num: 24000, Den: 1001},
+		"30000/1001": {Num: 30000, Den: 1001},
+	}
+	for input, want := range cases {
+		got, err := ParseFrameRate(input)
+		if err != nil || got != want {
+			t.Errorf("%s: 期望 %v，实际 %v（%v）", input, want, got, err)
+		}
+	}
+	if _, err := ParseFrameRate("abc"); err == nil {
+		t.Errorf("无效帧率应返回错误")
+	}
+
+	ntsc := FrameRate{Num: 30000, Den: 1001}
+	if ntsc.Timebase() != 30 || !ntsc.NTSC() {
+		t.Errorf("29.97 的时基应为30且为 NTSC")
+	}
+	// 相邻区间换算后首尾相接
+	_, first := ntsc.FrameRange(Range{Start: 0, Duration: 1234567})
+	second, _ := ntsc.FrameRange(Range{Start: 1234567, Duration: 1000000})
+	if first != second {
+		t.Errorf("相邻区间应首尾相接: %d / %d", first, second)
+	}
+	if got := ntsc.Seconds(60).RatString(); got != "1001/500" {
+		t.Errorf("60帧应为 1001/500 秒: %s", got)
+	}
+}
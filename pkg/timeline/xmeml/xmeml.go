@@ -0,0 +1,494 @@
+// Package xmeml 将章节时间线导出为 Final Cut Pro 7 XML（xmeml），供 Premiere Pro 导入。
+//
+// 视频和字幕轨道按时间线中的顺序从下到上排列，图片为静帧片段，转场写成居中对齐的交叉叠化，
+// 字幕写成文本生成器；音频轨道带有音量滤镜。时间以序列时基下的整数帧表示，
+// NTSC 帧率（如 29.97）使用向上取整的时基并标记 ntsc
+package xmeml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"novel-video-workflow/pkg/timeline"
+)
+
+// Ext xmeml 文件扩展名
+const Ext = ".xml"
+
+// Version 输出的 xmeml 版本
+const Version = "4"
+
+// Options 导出选项
+type Options struct {
+	FrameRate timeline.FrameRate // 序列帧率，为空时使用时间线帧率
+	TitleFont string             // 字幕字体，为空时使用 PingFang SC
+}
+
+const (
+	defaultTitleFont   = "PingFang SC"
+	defaultTitleHeight = 0.025
+)
+
+type document struct {
+	XMLName  xml.Name `xml:"xmeml"`
+	Version  string   `xml:"version,attr"`
+	Sequence sequence `xml:"sequence"`
+}
+
+type rate struct {
+	Timebase int64  `xml:"timebase"`
+	NTSC     string `xml:"ntsc"`
+}
+
+type sequence struct {
+	ID       string   `xml:"id,attr"`
+	Name     string   `xml:"name"`
+	Duration int64    `xml:"duration"`
+	Rate     rate     `xml:"rate"`
+	Timecode timecode `xml:"timecode"`
+	Media    media    `xml:"media"`
+}
+
+type timecode struct {
+	Rate          rate   `xml:"rate"`
+	String        string `xml:"string"`
+	Frame         int64  `xml:"frame"`
+	DisplayFormat string `xml:"displayformat"`
+}
+
+type media struct {
+	Video videoMedia `xml:"video"`
+	Audio audioMedia `xml:"audio"`
+}
+
+type videoMedia struct {
+	Format videoFormat `xml:"format"`
+	Tracks []track     `xml:"track"`
+}
+
+type videoFormat struct {
+	SampleCharacteristics videoCharacteristics `xml:"samplecharacteristics"`
+}
+
+type videoCharacteristics struct {
+	Rate             *rate  `xml:"rate,omitempty"`
+	Width            int    `xml:"width"`
+	Height           int    `xml:"height"`
+	PixelAspectRatio string `xml:"pixelaspectratio,omitempty"`
+	Anamorphic       string `xml:"anamorphic,omitempty"`
+	FieldDominance   string `xml:"fielddominance,omitempty"`
+}
+
+type audioMedia struct {
+	NumOutputChannels int     `xml:"numOutputChannels"`
+	Tracks            []track `xml:"track"`
+}
+
+type track struct {
+	Items   []interface{} `xml:",any"`
+	Enabled string        `xml:"enabled"`
+	Locked  string        `xml:"locked"`
+}
+
+type clipitem struct {
+	XMLName     xml.Name     `xml:"clipitem"`
+	ID          string       `xml:"id,attr"`
+	Name        string       `xml:"name"`
+	Enabled     string       `xml:"enabled"`
+	Duration    int64        `xml:"duration"`
+	Rate        rate         `xml:"rate"`
+	Start       int64        `xml:"start"`
+	End         int64        `xml:"end"`
+	In          int64        `xml:"in"`
+	Out         int64        `xml:"out"`
+	StillFrame  string       `xml:"stillframe,omitempty"`
+	File        *file        `xml:"file"`
+	SourceTrack *sourceTrack `xml:"sourcetrack"`
+	Filters     []filter     `xml:"filter"`
+}
+
+type file struct {
+	ID       string     `xml:"id,attr"`
+	Name     string     `xml:"name,omitempty"`
+	PathURL  string     `xml:"pathurl,omitempty"`
+	Rate     *rate      `xml:"rate,omitempty"`
+	Duration int64      `xml:"duration,omitempty"`
+	Media    *fileMedia `xml:"media,omitempty"`
+}
+
+type fileMedia struct {
+	Video *fileVideo `xml:"video,omitempty"`
+	Audio *fileAudio `xml:"audio,omitempty"`
+}
+
+type fileVideo struct {
+	SampleCharacteristics videoCharacteristics `xml:"samplecharacteristics"`
+}
+
+type fileAudio struct {
+	SampleCharacteristics audioCharacteristics `xml:"samplecharacteristics"`
+	ChannelCount          int                  `xml:"channelcount"`
+}
+
+type audioCharacteristics struct {
+	Depth      int `xml:"depth"`
+	SampleRate int `xml:"samplerate"`
+}
+
+type sourceTrack struct {
+	MediaType  string `xml:"mediatype"`
+	TrackIndex int    `xml:"trackindex"`
+}
+
+type filter struct {
+	Effect effect `xml:"effect"`
+}
+
+type effect struct {
+	Name           string      `xml:"name"`
+	EffectID       string      `xml:"effectid"`
+	EffectCategory string      `xml:"effectcategory"`
+	EffectType     string      `xml:"effecttype"`
+	MediaType      string      `xml:"mediatype"`
+	Parameters     []parameter `xml:"parameter"`
+}
+
+type parameter struct {
+	ParameterID string      `xml:"parameterid"`
+	Name        string      `xml:"name"`
+	ValueMin    *float64    `xml:"valuemin,omitempty"`
+	ValueMax    *float64    `xml:"valuemax,omitempty"`
+	Value       interface{} `xml:"value"`
+}
+
+type colorValue struct {
+	Alpha int `xml:"alpha"`
+	Red   int `xml:"red"`
+	Green int `xml:"green"`
+	Blue  int `xml:"blue"`
+}
+
+type pointValue struct {
+	Horiz string `xml:"horiz"`
+	Vert  string `xml:"vert"`
+}
+
+type transitionitem struct {
+	XMLName   xml.Name `xml:"transitionitem"`
+	Rate      rate     `xml:"rate"`
+	Start     int64    `xml:"start"`
+	End       int64    `xml:"end"`
+	Alignment string   `xml:"alignment"`
+	Effect    effect   `xml:"effect"`
+}
+
+type generatoritem struct {
+	XMLName    xml.Name `xml:"generatoritem"`
+	ID         string   `xml:"id,attr"`
+	Name       string   `xml:"name"`
+	Enabled    string   `xml:"enabled"`
+	Duration   int64    `xml:"duration"`
+	Rate       rate     `xml:"rate"`
+	Start      int64    `xml:"start"`
+	End        int64    `xml:"end"`
+	In         int64    `xml:"in"`
+	Out        int64    `xml:"out"`
+	Anamorphic string   `xml:"anamorphic"`
+	AlphaType  string   `xml:"alphatype"`
+	Effect     effect   `xml:"effect"`
+}
+
+// builder 生成过程中的编号和帧率
+type builder struct {
+	rate   timeline.FrameRate
+	xrate  rate
+	opts   Options
+	width  int
+	height int
+	files  map[string]string // 素材路径 -> 文件ID
+	items  int
+}
+
+// Export 将时间线写入 xmeml 文件
+func Export(tl *timeline.Timeline, path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建XML文件失败: %v", err)
+	}
+	defer out.Close()
+	return Encode(out, tl, Options{})
+}
+
+// Encode 将时间线编码为 xmeml
+func Encode(w io.Writer, tl *timeline.Timeline, opts Options) error {
+	if err := tl.Validate(); err != nil {
+		return err
+	}
+	b := &builder{rate: opts.FrameRate, opts: opts, width: tl.Width, height: tl.Height, files: make(map[string]string)}
+	if b.rate.IsZero() {
+		b.rate = timeline.FrameRateOf(tl.FPS)
+	}
+	b.xrate = rate{Timebase: b.rate.Timebase(), NTSC: boolString(b.rate.NTSC())}
+
+	total := b.rate.Frames(tl.Duration)
+	seq := sequence{
+		ID:       "sequence-1",
+		Name:     tl.Name,
+		Rate:     b.xrate,
+		Timecode: timecode{Rate: b.xrate, String: "00:00:00:00", DisplayFormat: "NDF"},
+		Media: media{
+			Video: videoMedia{Format: videoFormat{SampleCharacteristics: b.videoCharacteristics(true)}},
+			Audio: audioMedia{NumOutputChannels: 2},
+		},
+	}
+
+	for _, tr := range tl.Tracks {
+		var items []interface{}
+		switch tr.Kind {
+		case timeline.TrackVideo:
+			items = b.videoItems(tr)
+		case timeline.TrackAudio:
+			items = b.audioItems(tr)
+		case timeline.TrackText:
+			items = b.textItems(tr)
+		}
+		if len(items) == 0 {
+			continue
+		}
+		t := track{Items: items, Enabled: "TRUE", Locked: "FALSE"}
+		if tr.Kind == timeline.TrackAudio {
+			seq.Media.Audio.Tracks = append(seq.Media.Audio.Tracks, t)
+		} else {
+			seq.Media.Video.Tracks = append(seq.Media.Video.Tracks, t)
+		}
+
+		for _, c := range tr.Clips {
+			if end := b.rate.Frames(c.Target.End()); end > total {
+				total = end
+			}
+		}
+		for _, cue := range tr.Cues {
+			if end := b.rate.Frames(cue.Target.End()); end > total {
+				total = end
+			}
+		}
+	}
+	seq.Duration = total
+
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE xmeml>\n"); err != nil {
+		return fmt.Errorf("写入XML失败: %v", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "    ")
+	if err := encoder.Encode(document{Version: Version, Sequence: seq}); err != nil {
+		return fmt.Errorf("写入XML失败: %v", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// videoItems 生成视频轨道上的片段，与下一个片段首尾相接时在切换点插入居中的交叉叠化
+func (b *builder) videoItems(tr *timeline.Track) []interface{} {
+	var items []interface{}
+	for i, c := range tr.Clips {
+		item := b.clipItem(c)
+		if item.Out <= item.In {
+			continue
+		}
+		items = append(items, item)
+
+		if t := c.Transition; t != nil && i+1 < len(tr.Clips) && b.rate.Frames(tr.Clips[i+1].Target.Start) == item.End {
+			if frames := b.rate.Frames(t.Duration); frames > 0 {
+				start := item.End - frames/2
+				items = append(items, transitionitem{
+					Rate:      b.xrate,
+					Start:     start,
+					End:       start + frames,
+					Alignment: "center",
+					Effect: effect{
+						Name:           "Cross Dissolve",
+						EffectID:       "Cross Dissolve",
+						EffectCategory: "Dissolve",
+						EffectType:     "transition",
+						MediaType:      "video",
+					},
+				})
+			}
+		}
+	}
+	return items
+}
+
+// audioItems 生成音频轨道上的片段，音量写成 Audio Levels 滤镜
+func (b *builder) audioItems(tr *timeline.Track) []interface{} {
+	var items []interface{}
+	for _, c := range tr.Clips {
+		item := b.clipItem(c)
+		if item.Out <= item.In {
+			continue
+		}
+		item.SourceTrack = &sourceTrack{MediaType: "audio", TrackIndex: 1}
+		minLevel, maxLevel := 0.0, 3.98109
+		item.Filters = append(item.Filters, filter{Effect: effect{
+			Name:           "Audio Levels",
+			EffectID:       "audiolevels",
+			EffectCategory: "audiolevels",
+			EffectType:     "audiolevels",
+			MediaType:      "audio",
+			Parameters: []parameter{{
+				ParameterID: "level",
+				Name:        "Level",
+				ValueMin:    &minLevel,
+				ValueMax:    &maxLevel,
+				Value:       formatFloat(c.Volume()),
+			}},
+		}})
+		items = append(items, item)
+	}
+	return items
+}
+
+// textItems 将字幕生成为文本生成器，字号按画布高度换算为像素，位置换算为相对画面中心的比例
+func (b *builder) textItems(tr *timeline.Track) []interface{} {
+	style := tr.Style
+	if style == nil {
+		style = &timeline.TextStyle{Size: defaultTitleHeight, Color: "#FFFFFF", PositionY: -0.8}
+	}
+	font := b.opts.TitleFont
+	if font == "" {
+		font = defaultTitleFont
+	}
+	var red, green, blue int
+	if _, err := fmt.Sscanf(style.Color, "#%02x%02x%02x", &red, &green, &blue); err != nil {
+		red, green, blue = 255, 255, 255
+	}
+	fontStyle := "1" // 1 常规，2 粗体
+	if style.Bold {
+		fontStyle = "2"
+	}
+
+	var items []interface{}
+	for _, cue := range tr.Cues {
+		start, duration := b.rate.FrameRange(cue.Target)
+		if duration <= 0 {
+			continue
+		}
+		b.items++
+		items = append(items, generatoritem{
+			ID:         fmt.Sprintf("clipitem-%d", b.items),
+			Name:       cue.Text,
+			Enabled:    "TRUE",
+			Duration:   duration,
+			Rate:       b.xrate,
+			Start:      start,
+			End:        start + duration,
+			In:         0,
+			Out:        duration,
+			Anamorphic: "FALSE",
+			AlphaType:  "black",
+			Effect: effect{
+				Name:           "Text",
+				EffectID:       "Text",
+				EffectCategory: "Text",
+				EffectType:     "generator",
+				MediaType:      "video",
+				Parameters: []parameter{
+					{ParameterID: "str", Name: "Text", Value: cue.Text},
+					{ParameterID: "fontname", Name: "Font", Value: font},
+					{ParameterID: "fontsize", Name: "Size", Value: formatFloat(style.Size * float64(b.height))},
+					{ParameterID: "fontstyle", Name: "Style", Value: fontStyle},
+					{ParameterID: "fontalign", Name: "Alignment", Value: "2"}, // 居中
+					{ParameterID: "fontcolor", Name: "Font Color", Value: colorValue{Alpha: 255, Red: red, Green: green, Blue: blue}},
+					// 原点以画面中心为0，向下为正，单位为画面高度
+					{ParameterID: "origin", Name: "Origin", Value: pointValue{Horiz: "0", Vert: formatFloat(-style.PositionY / 2)}},
+				},
+			},
+		})
+	}
+	return items
+}
+
+// clipItem 生成片段，同一素材文件第二次出现时只引用文件ID
+func (b *builder) clipItem(c *timeline.Clip) *clipitem {
+	start, duration := b.rate.FrameRange(c.Target)
+	in := b.rate.Frames(c.Source.Start)
+	b.items++
+	item := &clipitem{
+		ID:       fmt.Sprintf("clipitem-%d", b.items),
+		Name:     clipName(c),
+		Enabled:  "TRUE",
+		Duration: in + duration,
+		Rate:     b.xrate,
+		Start:    start,
+		End:      start + duration,
+		In:       in,
+		Out:      in + duration,
+		File:     b.file(c),
+	}
+	if c.Media == timeline.MediaImage {
+		item.StillFrame = "TRUE"
+	} else {
+		item.Duration = b.rate.Frames(c.Source.End())
+	}
+	return item
+}
+
+// file 返回素材文件，第一次出现时写出完整信息
+func (b *builder) file(c *timeline.Clip) *file {
+	if id, ok := b.files[c.Path]; ok {
+		return &file{ID: id}
+	}
+	id := fmt.Sprintf("file-%d", len(b.files)+1)
+	b.files[c.Path] = id
+	f := &file{
+		ID:      id,
+		Name:    filepath.Base(c.Path),
+		PathURL: timeline.FileURL(c.Path),
+		Rate:    &b.xrate,
+		Media:   &fileMedia{},
+	}
+	if c.Media != timeline.MediaImage {
+		f.Duration = b.rate.Frames(c.Source.End())
+	}
+	if c.Media != timeline.MediaAudio {
+		f.Media.Video = &fileVideo{SampleCharacteristics: b.videoCharacteristics(false)}
+	}
+	if c.Media != timeline.MediaImage {
+		f.Media.Audio = &fileAudio{SampleCharacteristics: audioCharacteristics{Depth: 16, SampleRate: 48000}, ChannelCount: 2}
+	}
+	return f
+}
+
+func (b *builder) videoCharacteristics(sequence bool) videoCharacteristics {
+	chars := videoCharacteristics{Width: b.width, Height: b.height}
+	if sequence {
+		chars.Rate = &b.xrate
+		chars.PixelAspectRatio = "square"
+		chars.Anamorphic = "FALSE"
+		chars.FieldDominance = "none"
+	}
+	return chars
+}
+
+func clipName(c *timeline.Clip) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return filepath.Base(c.Path)
+}
+
+func boolString(v bool) string {
+	if v {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
@@ -0,0 +1,486 @@
+/*剧集封面生成*/
+package cover
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"novel-video-workflow/pkg/tools/drawthings"
+	imagepkg "novel-video-workflow/pkg/tools/image"
+	"novel-video-workflow/pkg/tools/imageproc"
+
+	"github.com/fogleman/gg"
+	"github.com/spf13/viper"
+)
+
+// CoversDirName 封面默认保存在章节图像目录下的子目录
+const CoversDirName = "covers"
+
+// defaultHookMaxRunes 自动截取钩子文案的最大字数
+const defaultHookMaxRunes = 20
+
+// Options 单张封面的渲染参数
+type Options struct {
+	Title        string // 小说名
+	EpisodeLabel string // 集数标签，如 "第3集"，为空时不绘制
+	Hook         string // 钩子文案，为空时不绘制
+	Width        int
+	Height       int
+	Template     *Template // 为nil时使用默认模板
+}
+
+// Render 以关键画面为背景渲染封面：画面按显著性裁剪或模糊填充到封面尺寸，
+// 叠加模板的渐变遮罩，再按模板位置绘制集数标签、标题和钩子文案。src为nil时使用纯色背景。
+// 同时返回实际使用的字体文件，为空表示没有找到中文字体
+func Render(src image.Image, opts Options) (*image.RGBA, string, error) {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return nil, "", fmt.Errorf("封面尺寸无效: %dx%d", opts.Width, opts.Height)
+	}
+	if strings.TrimSpace(opts.Title) == "" {
+		return nil, "", fmt.Errorf("封面标题不能为空")
+	}
+	t := DefaultTemplate()
+	if opts.Template != nil {
+		t = opts.Template.withDefaults()
+	}
+
+	var background *image.RGBA
+	if src != nil {
+		fitted, _, err := imageproc.Fit(src, imageproc.Options{Width: opts.Width, Height: opts.Height, Anchor: imageproc.AnchorSaliency})
+		if err != nil {
+			return nil, "", fmt.Errorf("适配封面画面失败: %v", err)
+		}
+		background = fitted
+	} else {
+		background = image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	}
+
+	dc := gg.NewContextForRGBA(background)
+	if src == nil {
+		dc.SetRGB(0.08, 0.08, 0.1)
+		dc.Clear()
+	}
+	width, height := float64(opts.Width), float64(opts.Height)
+	short := math.Min(width, height)
+
+	// 排版：集数标签、标题（最多两行，放不下时缩小字号）、钩子文案（最多两行）
+	var lines []textLine
+	fontUsed := ""
+	face := func(scale float64) float64 {
+		size := short * scale
+		f, path, _ := imagepkg.NewFontFace(t.FontPath, size)
+		dc.SetFontFace(f)
+		fontUsed = path
+		return size
+	}
+
+	maxWidth := width * 0.88
+	if opts.EpisodeLabel != "" {
+		size := face(t.EpisodeScale)
+		lines = append(lines, textLine{text: opts.EpisodeLabel, size: size, color: mustColor(t.AccentColor, color.RGBA{R: 255, G: 210, B: 63, A: 255})})
+	}
+	titleScale := t.TitleScale
+	var titleLines []string
+	for {
+		face(titleScale)
+		titleLines = wrapText(dc, opts.Title, maxWidth)
+		if len(titleLines) <= 2 || titleScale <= t.TitleScale*0.6 {
+			break
+		}
+		titleScale *= 0.9
+	}
+	for _, line := range titleLines {
+		lines = append(lines, textLine{text: line, size: short * titleScale, color: mustColor(t.TextColor, color.RGBA{R: 255, G: 255, B: 255, A: 255})})
+	}
+	if opts.Hook != "" {
+		size := face(t.HookScale)
+		hookLines := wrapText(dc, opts.Hook, maxWidth)
+		if len(hookLines) > 2 {
+			hookLines = hookLines[:2]
+		}
+		for _, line := range hookLines {
+			lines = append(lines, textLine{text: line, size: size, color: mustColor(t.TextColor, color.RGBA{R: 255, G: 255, B: 255, A: 255})})
+		}
+	}
+
+	blockHeight := 0.0
+	for _, line := range lines {
+		blockHeight += line.size * 1.3
+	}
+	margin := height * 0.07
+	y := (height - blockHeight) / 2
+	switch t.TitlePosition {
+	case PositionTop:
+		y = margin
+	case PositionBottom:
+		y = height - margin - blockHeight
+	}
+
+	drawOverlay(dc, t, width, height)
+
+	stroke := mustColor(t.StrokeColor, color.RGBA{A: 255})
+	for _, line := range lines {
+		face(line.size / short)
+		lineHeight := line.size * 1.3
+		cx, cy := width/2, y+lineHeight/2
+		if t.StrokeWidth > 0 {
+			drawStroke(dc, line.text, cx, cy, line.size*t.StrokeWidth, stroke)
+		}
+		dc.SetColor(line.color)
+		dc.DrawStringAnchored(line.text, cx, cy, 0.5, 0.5)
+		y += lineHeight
+	}
+
+	return background, fontUsed, nil
+}
+
+// DefaultTemplate 返回 cover.template 配置的模板，配置无效时使用内置的 classic 模板
+func DefaultTemplate() Template {
+	if t, err := ResolveTemplate(""); err == nil {
+		return *t
+	}
+	return builtinTemplates[0].withDefaults()
+}
+
+type textLine struct {
+	text  string
+	size  float64
+	color color.RGBA
+}
+
+// drawOverlay 按标题位置绘制渐变遮罩，保证文字在明亮画面上也清晰可读
+func drawOverlay(dc *gg.Context, t Template, width, height float64) {
+	if t.OverlayOpacity <= 0 {
+		return
+	}
+	base := mustColor(t.OverlayColor, color.RGBA{A: 255})
+	solid := color.NRGBA{R: base.R, G: base.G, B: base.B, A: uint8(255 * t.OverlayOpacity)}
+	clear := color.NRGBA{R: base.R, G: base.G, B: base.B, A: 0}
+
+	switch t.TitlePosition {
+	case PositionCenter:
+		dc.SetColor(solid)
+		dc.DrawRectangle(0, 0, width, height)
+		dc.Fill()
+		return
+	case PositionTop:
+		gradient := gg.NewLinearGradient(0, 0, 0, height*t.OverlayHeight)
+		gradient.AddColorStop(0, solid)
+		gradient.AddColorStop(1, clear)
+		dc.SetFillStyle(gradient)
+		dc.DrawRectangle(0, 0, width, height*t.OverlayHeight)
+	default:
+		top := height * (1 - t.OverlayHeight)
+		gradient := gg.NewLinearGradient(0, top, 0, height)
+		gradient.AddColorStop(0, clear)
+		gradient.AddColorStop(1, solid)
+		dc.SetFillStyle(gradient)
+		dc.DrawRectangle(0, top, width, height-top)
+	}
+	dc.Fill()
+}
+
+// drawStroke 在文字四周多个方向绘制描边色文字，形成描边效果
+func drawStroke(dc *gg.Context, text string, x, y, strokeWidth float64, c color.Color) {
+	if strokeWidth < 1 {
+		strokeWidth = 1
+	}
+	dc.SetColor(c)
+	steps := 16
+	for i := 0; i < steps; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(steps)
+		dc.DrawStringAnchored(text, x+math.Cos(angle)*strokeWidth, y+math.Sin(angle)*strokeWidth, 0.5, 0.5)
+	}
+}
+
+// wrapText 按字符将文本折成不超过maxWidth的多行，中文没有空格无法按单词换行
+func wrapText(dc *gg.Context, text string, maxWidth float64) []string {
+	var lines []string
+	var current []rune
+	for _, r := range strings.TrimSpace(text) {
+		if r == '\n' {
+			lines = append(lines, string(current))
+			current = nil
+			continue
+		}
+		candidate := append(current, r)
+		if w, _ := dc.MeasureString(string(candidate)); w > maxWidth && len(current) > 0 {
+			lines = append(lines, string(current))
+			current = []rune{r}
+			continue
+		}
+		current = candidate
+	}
+	if len(current) > 0 {
+		lines = append(lines, string(current))
+	}
+	return lines
+}
+
+// Request 章节封面生成请求
+type Request struct {
+	ImagesDir string   // 章节图像目录，用于选择关键画面、推断小说名和集数
+	Image     string   // 关键画面图像，为空时从生成清单中选择
+	OutputDir string   // 输出目录，为空时为 ImagesDir/covers
+	Title     string   // 小说名，为空时使用章节目录的上级目录名
+	Episode   int      // 集数，为0时使用生成清单中的章节号或目录名中的数字
+	Hook      string   // 钩子文案，为空时截取关键画面对应原文的第一句
+	Template  string   // 模板名称，为空时使用 cover.template
+	Sizes     []string // 尺寸预设名称，为空时使用 cover.sizes，默认全部尺寸
+}
+
+// Result 生成的封面
+type Result struct {
+	Size        string `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	File        string `json:"file"`
+	SourceImage string `json:"source_image,omitempty"`
+	Title       string `json:"title"`
+	Episode     int    `json:"episode,omitempty"`
+	Hook        string `json:"hook,omitempty"`
+	Template    string `json:"template"`
+	Font        string `json:"font,omitempty"` // 实际使用的字体文件，为空表示没有找到中文字体
+}
+
+// Generate 按请求为章节渲染各平台尺寸的封面，返回生成的封面列表
+func Generate(req Request) ([]Result, error) {
+	if req.ImagesDir == "" && req.Image == "" {
+		return nil, fmt.Errorf("需要指定章节图像目录或关键画面图像")
+	}
+
+	t, err := ResolveTemplate(req.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes, err := resolveSizes(req.Sizes)
+	if err != nil {
+		return nil, err
+	}
+
+	// 选择关键画面，同时取得其对应原文作为默认钩子文案
+	imagePath := req.Image
+	sourceText := ""
+	chapter := 0
+	if req.ImagesDir != "" {
+		if manifest, err := drawthings.LoadImageManifest(req.ImagesDir); err == nil {
+			chapter = manifest.Chapter
+		}
+		if imagePath == "" {
+			record, path, err := SelectKeyScene(req.ImagesDir)
+			if err != nil {
+				return nil, err
+			}
+			imagePath = path
+			if record != nil {
+				sourceText = record.SourceText
+			}
+		}
+	}
+
+	src, err := imageproc.LoadImage(imagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" && req.ImagesDir != "" {
+		title = filepath.Base(filepath.Dir(filepath.Clean(req.ImagesDir)))
+	}
+	episode := req.Episode
+	if episode <= 0 {
+		episode = chapter
+	}
+	if episode <= 0 && req.ImagesDir != "" {
+		episode = episodeFromDir(req.ImagesDir)
+	}
+	hook := strings.TrimSpace(req.Hook)
+	if hook == "" {
+		hook = HookFromText(sourceText, viper.GetInt("cover.hook_max_runes"))
+	}
+
+	outputDir := req.OutputDir
+	if outputDir == "" {
+		base := req.ImagesDir
+		if base == "" {
+			base = filepath.Dir(imagePath)
+		}
+		outputDir = filepath.Join(base, CoversDirName)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建封面目录失败: %v", err)
+	}
+
+	label := ""
+	if episode > 0 {
+		format := viper.GetString("cover.episode_format")
+		if format == "" {
+			format = "第%d集"
+		}
+		label = fmt.Sprintf(format, episode)
+	}
+
+	var results []Result
+	for _, size := range sizes {
+		img, fontPath, err := Render(src, Options{
+			Title:        title,
+			EpisodeLabel: label,
+			Hook:         hook,
+			Width:        size.Width,
+			Height:       size.Height,
+			Template:     t,
+		})
+		if err != nil {
+			return results, err
+		}
+
+		file := filepath.Join(outputDir, fmt.Sprintf("cover_%s.png", strings.ReplaceAll(size.Name, ":", "x")))
+		if err := imageproc.SavePNG(img, file); err != nil {
+			return results, err
+		}
+		results = append(results, Result{
+			Size:        size.Name,
+			Width:       size.Width,
+			Height:      size.Height,
+			File:        file,
+			SourceImage: imagePath,
+			Title:       title,
+			Episode:     episode,
+			Hook:        hook,
+			Template:    t.Name,
+			Font:        fontPath,
+		})
+	}
+	return results, nil
+}
+
+// resolveSizes 将尺寸名称解析为尺寸预设，为空时使用 cover.sizes 配置，仍为空时使用全部尺寸
+func resolveSizes(names []string) ([]SizePreset, error) {
+	if len(names) == 0 {
+		names = viper.GetStringSlice("cover.sizes")
+	}
+	if len(names) == 0 {
+		return SizePresets, nil
+	}
+	var sizes []SizePreset
+	for _, name := range names {
+		size, err := LookupSize(name)
+		if err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// keySceneShots 更适合做封面的景别，选择关键画面时优先
+var keySceneShots = map[string]bool{
+	drawthings.ShotCloseUp: true,
+	drawthings.ShotMedium:  true,
+}
+
+// SelectKeyScene 从章节图像目录选择封面使用的关键画面：
+// 按生成清单中时长权重最高的分镜选择，同等权重时优先有角色出场的近景、中景，
+// 质检未通过的图像不参与选择；没有生成清单时使用目录中的第一张图像
+func SelectKeyScene(imagesDir string) (*drawthings.ImageRecord, string, error) {
+	manifest, err := drawthings.LoadImageManifest(imagesDir)
+	if err == nil && len(manifest.Images) > 0 {
+		failed := failedQCImages(imagesDir)
+
+		var best *drawthings.ImageRecord
+		bestScore := math.Inf(-1)
+		for i := range manifest.Images {
+			record := &manifest.Images[i]
+			if failed[record.Index] {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(imagesDir, record.ImageFile)); err != nil {
+				continue
+			}
+			score := 1.0
+			if scene := record.Scene; scene != nil {
+				score = scene.DurationWeight
+				if len(scene.Characters) > 0 && keySceneShots[scene.ShotType] {
+					score += 0.5
+				}
+			}
+			if score > bestScore {
+				best, bestScore = record, score
+			}
+		}
+		if best != nil {
+			return best, filepath.Join(imagesDir, best.ImageFile), nil
+		}
+	}
+
+	entries, err := os.ReadDir(imagesDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("读取图像目录失败: %v", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".png" && ext != ".jpg" && ext != ".jpeg") {
+			continue
+		}
+		files = append(files, entry.Name())
+	}
+	if len(files) == 0 {
+		return nil, "", fmt.Errorf("图像目录中没有可用作封面的图像: %s", imagesDir)
+	}
+	sort.Strings(files)
+	return nil, filepath.Join(imagesDir, files[0]), nil
+}
+
+// failedQCImages 读取质检报告中未通过的图像序号，没有报告时返回空
+func failedQCImages(imagesDir string) map[int]bool {
+	failed := make(map[int]bool)
+	report, err := drawthings.LoadQCReport(imagesDir)
+	if err != nil {
+		return failed
+	}
+	for _, result := range report.Failures() {
+		failed[result.Index] = true
+	}
+	return failed
+}
+
+// HookFromText 截取原文的第一句作为钩子文案，超过maxRunes时截断并加省略号
+func HookFromText(text string, maxRunes int) string {
+	if maxRunes <= 0 {
+		maxRunes = defaultHookMaxRunes
+	}
+	text = strings.TrimSpace(text)
+	if idx := strings.IndexAny(text, "。！？!?\n"); idx != -1 {
+		_, size := utf8.DecodeRuneInString(text[idx:])
+		text = text[:idx+size]
+		text = strings.TrimRight(text, "。\n")
+	}
+	runes := []rune(text)
+	if len(runes) > maxRunes {
+		return string(runes[:maxRunes]) + "……"
+	}
+	return text
+}
+
+var episodeNumberPattern = regexp.MustCompile(`(\d+)\D*$`)
+
+// episodeFromDir 从 chapter_03 这样的目录名中取得集数
+func episodeFromDir(imagesDir string) int {
+	match := episodeNumberPattern.FindStringSubmatch(filepath.Base(filepath.Clean(imagesDir)))
+	if match == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(match[1])
+	return n
+}
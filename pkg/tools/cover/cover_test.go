@@ -0,0 +1,140 @@
+package cover
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"novel-video-workflow/pkg/tools/drawthings"
+	"novel-video-workflow/pkg/tools/imageproc"
+
+	"github.com/spf13/viper"
+)
+
+// writeChapterFixture 写入两张分镜图像和生成清单，第2张分镜的时长权重更高
+func writeChapterFixture(t *testing.T) string {
+	t.Helper()
+	imagesDir := filepath.Join(t.TempDir(), "诡宅", "chapter_03")
+
+	manifest := drawthings.NewImageManifest(0, drawthings.ManifestSourceOllamaScenes)
+	scenes := []*drawthings.StoryboardScene{
+		{Location: "走廊", DurationWeight: 1},
+		{Location: "卧室", DurationWeight: 2, Characters: []string{"林晚"}, ShotType: drawthings.ShotCloseUp},
+	}
+	sources := []string{"夜深了。", "镜子里的那张脸，不是她自己的！她后退了一步。"}
+	for i, scene := range scenes {
+		img := image.NewRGBA(image.Rect(0, 0, 64, 112))
+		for p := 0; p < len(img.Pix); p += 4 {
+			img.Pix[p], img.Pix[p+1], img.Pix[p+2], img.Pix[p+3] = uint8(60*i), 80, 120, 255
+		}
+		file := filepath.Join(imagesDir, fmt.Sprintf("scene_%02d.png", i+1))
+		if err := imageproc.SavePNG(img, file); err != nil {
+			t.Fatalf("写入图像失败: %v", err)
+		}
+		record := drawthings.NewImageRecord(i+1, file, drawthings.Txt2ImgRequest{})
+		record.SourceText = sources[i]
+		record.Scene = scene
+		manifest.Put(record)
+	}
+	if err := manifest.Save(imagesDir); err != nil {
+		t.Fatalf("保存清单失败: %v", err)
+	}
+	return imagesDir
+}
+
+func TestGenerateCoversFromChapter(t *testing.T) {
+	imagesDir := writeChapterFixture(t)
+
+	results, err := Generate(Request{ImagesDir: imagesDir, Sizes: []string{"9:16", "16x9"}})
+	if err != nil {
+		t.Fatalf("生成封面失败: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("应生成2张封面, 实际 %d", len(results))
+	}
+
+	first := results[0]
+	if first.Title != "诡宅" || first.Episode != 3 || first.Template != DefaultTemplateName {
+		t.Errorf("封面信息推断错误: %+v", first)
+	}
+	if filepath.Base(first.SourceImage) != "scene_02.png" {
+		t.Errorf("应选择权重最高的近景分镜: %s", first.SourceImage)
+	}
+	if first.Hook != "镜子里的那张脸，不是她自己的！" {
+		t.Errorf("钩子文案应取原文第一句: %q", first.Hook)
+	}
+
+	for _, result := range results {
+		img, err := imageproc.LoadImage(result.File)
+		if err != nil {
+			t.Fatalf("读取封面失败: %v", err)
+		}
+		if img.Bounds().Dx() != result.Width || img.Bounds().Dy() != result.Height {
+			t.Errorf("封面尺寸错误: %v, 期望 %dx%d", img.Bounds(), result.Width, result.Height)
+		}
+	}
+	if filepath.Base(results[1].File) != "cover_16x9.png" || filepath.Dir(results[1].File) != filepath.Join(imagesDir, CoversDirName) {
+		t.Errorf("封面文件路径错误: %s", results[1].File)
+	}
+}
+
+func TestRenderDrawsOverlayAtTitlePosition(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 90, 160))
+	for p := 0; p < len(src.Pix); p += 4 {
+		src.Pix[p], src.Pix[p+1], src.Pix[p+2], src.Pix[p+3] = 250, 250, 250, 255
+	}
+
+	bottom, _ := ResolveTemplate("classic")
+	img, _, err := Render(src, Options{Title: "T", Width: 90, Height: 160, Template: bottom})
+	if err != nil {
+		t.Fatalf("渲染失败: %v", err)
+	}
+	// 底部渐变遮罩使底部变暗，顶部保持原画面
+	if top, low := luminanceAt(img, 45, 2), luminanceAt(img, 2, 158); top < 0.9 || low > 0.4 {
+		t.Errorf("底部渐变遮罩错误: 顶部 %.2f, 底部 %.2f", top, low)
+	}
+
+	if _, _, err := Render(src, Options{Width: 90, Height: 160}); err == nil {
+		t.Errorf("标题为空应报错")
+	}
+}
+
+func luminanceAt(img image.Image, x, y int) float64 {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 65535
+}
+
+func TestTemplatesFromConfig(t *testing.T) {
+	viper.Set("cover.templates", []map[string]interface{}{
+		{"name": "mine", "title_position": "top", "accent_color": "#00FF00"},
+	})
+	defer viper.Set("cover.templates", nil)
+
+	mine, err := ResolveTemplate("mine")
+	if err != nil {
+		t.Fatalf("应能读取配置中的模板: %v", err)
+	}
+	if mine.TitlePosition != PositionTop || mine.AccentColor != "#00FF00" || mine.TitleScale <= 0 {
+		t.Errorf("配置模板字段错误: %+v", mine)
+	}
+	if _, err := ResolveTemplate("missing"); err == nil {
+		t.Errorf("未知模板应报错")
+	}
+	if c, err := parseHexColor("#FFD23F"); err != nil || c != (color.RGBA{R: 255, G: 210, B: 63, A: 255}) {
+		t.Errorf("颜色解析错误: %v %v", c, err)
+	}
+}
+
+func TestLookupSizeAndHook(t *testing.T) {
+	if size, err := LookupSize("3x4"); err != nil || size.Width != 1080 || size.Height != 1440 {
+		t.Errorf("尺寸预设错误: %+v %v", size, err)
+	}
+	if _, err := LookupSize("1:1"); err == nil {
+		t.Errorf("未知尺寸应报错")
+	}
+	if hook := HookFromText("一二三四五六七八九十。后面", 5); hook != "一二三四五……" {
+		t.Errorf("钩子文案截断错误: %q", hook)
+	}
+}
@@ -0,0 +1,225 @@
+package cover
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// 标题位置
+const (
+	PositionTop    = "top"
+	PositionCenter = "center"
+	PositionBottom = "bottom"
+)
+
+// DefaultTemplateName 未配置 cover.template 时使用的模板
+const DefaultTemplateName = "classic"
+
+// SizePreset 平台封面尺寸预设
+type SizePreset struct {
+	Name        string `json:"name"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Description string `json:"description"`
+}
+
+// SizePresets 内置的封面尺寸
+var SizePresets = []SizePreset{
+	{Name: "9:16", Width: 1080, Height: 1920, Description: "抖音、快手、视频号竖屏封面"},
+	{Name: "3:4", Width: 1080, Height: 1440, Description: "小红书等信息流封面"},
+	{Name: "16:9", Width: 1920, Height: 1080, Description: "B站、YouTube横屏封面"},
+}
+
+// LookupSize 按名称查找尺寸预设，也接受 "9x16" 这样的写法
+func LookupSize(name string) (SizePreset, error) {
+	normalized := strings.ReplaceAll(strings.TrimSpace(name), "x", ":")
+	for _, preset := range SizePresets {
+		if preset.Name == normalized {
+			return preset, nil
+		}
+	}
+	names := make([]string, len(SizePresets))
+	for i, preset := range SizePresets {
+		names[i] = preset.Name
+	}
+	return SizePreset{}, fmt.Errorf("未知的封面尺寸: %s，可选: %s", name, strings.Join(names, ", "))
+}
+
+// Template 封面模板，描述字体、描边、渐变遮罩和标题位置。
+// 字号和描边宽度按画布短边的比例计算，同一模板适用于所有尺寸
+type Template struct {
+	Name           string  `mapstructure:"name" json:"name"`
+	Description    string  `mapstructure:"description" json:"description,omitempty"`
+	FontPath       string  `mapstructure:"font_path" json:"font_path,omitempty"`           // 为空时使用 image.font_path 和系统中文字体
+	TitlePosition  string  `mapstructure:"title_position" json:"title_position"`           // top, center, bottom
+	TitleScale     float64 `mapstructure:"title_scale" json:"title_scale"`                 // 标题字号占画布短边的比例
+	EpisodeScale   float64 `mapstructure:"episode_scale" json:"episode_scale"`             // 集数字号占画布短边的比例
+	HookScale      float64 `mapstructure:"hook_scale" json:"hook_scale"`                   // 钩子文案字号占画布短边的比例
+	TextColor      string  `mapstructure:"text_color" json:"text_color"`                   // 标题与钩子文案颜色 #RRGGBB
+	AccentColor    string  `mapstructure:"accent_color" json:"accent_color"`               // 集数标签颜色
+	StrokeColor    string  `mapstructure:"stroke_color" json:"stroke_color"`               // 描边颜色
+	StrokeWidth    float64 `mapstructure:"stroke_width" json:"stroke_width"`               // 描边宽度占字号的比例，0为不描边
+	OverlayColor   string  `mapstructure:"overlay_color" json:"overlay_color"`             // 渐变遮罩颜色
+	OverlayOpacity float64 `mapstructure:"overlay_opacity" json:"overlay_opacity"`         // 遮罩最深处的不透明度 0-1
+	OverlayHeight  float64 `mapstructure:"overlay_height" json:"overlay_height,omitempty"` // 渐变遮罩覆盖画布高度的比例
+}
+
+// builtinTemplates 内置封面模板
+var builtinTemplates = []Template{
+	{
+		Name:           "classic",
+		Description:    "标题在下方，底部黑色渐变，黄色集数标签",
+		TitlePosition:  PositionBottom,
+		TitleScale:     0.11,
+		EpisodeScale:   0.055,
+		HookScale:      0.05,
+		TextColor:      "#FFFFFF",
+		AccentColor:    "#FFD23F",
+		StrokeColor:    "#000000",
+		StrokeWidth:    0.06,
+		OverlayColor:   "#000000",
+		OverlayOpacity: 0.85,
+		OverlayHeight:  0.55,
+	},
+	{
+		Name:           "headline",
+		Description:    "标题在上方，顶部暗红渐变，适合悬疑题材",
+		TitlePosition:  PositionTop,
+		TitleScale:     0.12,
+		EpisodeScale:   0.06,
+		HookScale:      0.05,
+		TextColor:      "#FFFFFF",
+		AccentColor:    "#FF4D4D",
+		StrokeColor:    "#2B0000",
+		StrokeWidth:    0.07,
+		OverlayColor:   "#1A0000",
+		OverlayOpacity: 0.8,
+		OverlayHeight:  0.5,
+	},
+	{
+		Name:           "cinema",
+		Description:    "标题居中，整体压暗，细描边",
+		TitlePosition:  PositionCenter,
+		TitleScale:     0.1,
+		EpisodeScale:   0.05,
+		HookScale:      0.045,
+		TextColor:      "#F5F5F5",
+		AccentColor:    "#E0C080",
+		StrokeColor:    "#000000",
+		StrokeWidth:    0.03,
+		OverlayColor:   "#000000",
+		OverlayOpacity: 0.45,
+	},
+}
+
+// withDefaults 补全模板中未填写的字段
+func (t Template) withDefaults() Template {
+	base := builtinTemplates[0]
+	switch t.TitlePosition {
+	case PositionTop, PositionCenter, PositionBottom:
+	default:
+		t.TitlePosition = base.TitlePosition
+	}
+	if t.TitleScale <= 0 {
+		t.TitleScale = base.TitleScale
+	}
+	if t.EpisodeScale <= 0 {
+		t.EpisodeScale = base.EpisodeScale
+	}
+	if t.HookScale <= 0 {
+		t.HookScale = base.HookScale
+	}
+	if t.TextColor == "" {
+		t.TextColor = base.TextColor
+	}
+	if t.AccentColor == "" {
+		t.AccentColor = base.AccentColor
+	}
+	if t.StrokeColor == "" {
+		t.StrokeColor = base.StrokeColor
+	}
+	if t.StrokeWidth < 0 {
+		t.StrokeWidth = 0
+	}
+	if t.OverlayColor == "" {
+		t.OverlayColor = base.OverlayColor
+	}
+	if t.OverlayOpacity < 0 || t.OverlayOpacity > 1 {
+		t.OverlayOpacity = base.OverlayOpacity
+	}
+	if t.OverlayHeight <= 0 || t.OverlayHeight > 1 {
+		t.OverlayHeight = base.OverlayHeight
+	}
+	return t
+}
+
+// Templates 返回内置模板与 cover.templates 配置合并后的全部模板，配置中的同名模板覆盖内置模板
+func Templates() map[string]Template {
+	templates := make(map[string]Template, len(builtinTemplates))
+	for _, t := range builtinTemplates {
+		templates[t.Name] = t.withDefaults()
+	}
+
+	var configured []Template
+	if err := viper.UnmarshalKey("cover.templates", &configured); err == nil {
+		for _, t := range configured {
+			t.Name = strings.TrimSpace(t.Name)
+			if t.Name != "" {
+				templates[t.Name] = t.withDefaults()
+			}
+		}
+	}
+	return templates
+}
+
+// TemplateNames 返回所有模板名称
+func TemplateNames() []string {
+	templates := Templates()
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveTemplate 按名称获取模板，名称为空时使用 cover.template 配置的默认模板
+func ResolveTemplate(name string) (*Template, error) {
+	if name == "" {
+		name = viper.GetString("cover.template")
+	}
+	if name == "" {
+		name = DefaultTemplateName
+	}
+	t, ok := Templates()[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的封面模板: %s，可用模板: %s", name, strings.Join(TemplateNames(), ", "))
+	}
+	return &t, nil
+}
+
+// parseHexColor 解析 #RRGGBB 格式颜色
+func parseHexColor(hex string) (color.RGBA, error) {
+	hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("颜色格式错误: %s", hex)
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("颜色格式错误: %s", hex)
+	}
+	return color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 255}, nil
+}
+
+// mustColor 解析颜色，格式错误时返回fallback
+func mustColor(hex string, fallback color.RGBA) color.RGBA {
+	if c, err := parseHexColor(hex); err == nil {
+		return c
+	}
+	return fallback
+}
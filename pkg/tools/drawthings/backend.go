@@ -0,0 +1,151 @@
+package drawthings
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// ImageBackend 图像生成后端，屏蔽不同推理服务的接口差异
+type ImageBackend interface {
+	// Name 后端名称，用于工作池的并发与限速配置（image.backends.<名称>）和生成清单
+	Name() string
+	// Txt2Img 文生图，返回第一张图像
+	Txt2Img(ctx context.Context, params Txt2ImgRequest) (*BackendImage, error)
+	// Img2Img 图生图，返回第一张图像
+	Img2Img(ctx context.Context, params Img2ImgRequest) (*BackendImage, error)
+	// ListModels 列出后端可用的模型
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// BackendImage 后端返回的图像
+type BackendImage struct {
+	Data []byte // PNG等编码后的图像数据
+	Seed int    // 后端实际使用的种子，未知时为-1
+}
+
+// availabilityChecker 可选接口，生成前检查后端是否可连接
+type availabilityChecker interface {
+	Available() bool
+}
+
+// 后端名称
+const (
+	BackendComfyUI = "comfyui"
+	BackendOffline = "offline"
+)
+
+type sceneIndexKey struct{}
+
+// ContextWithSceneIndex 在ctx中记录当前分镜序号，供离线后端等需要序号的后端使用
+func ContextWithSceneIndex(ctx context.Context, index int) context.Context {
+	return context.WithValue(ctx, sceneIndexKey{}, index)
+}
+
+// SceneIndexFromContext 读取ctx中的分镜序号，未设置时返回0
+func SceneIndexFromContext(ctx context.Context) int {
+	index, _ := ctx.Value(sceneIndexKey{}).(int)
+	return index
+}
+
+// NewImageBackend 按名称创建图像后端：
+// drawthings / a1111 / stable_diffusion 为A1111风格API（client为nil时按 image.api_url 创建），
+// comfyui 为ComfyUI，offline 为离线占位图
+func NewImageBackend(logger *zap.Logger, engine string, client *DrawThingsClient) (ImageBackend, error) {
+	switch engine {
+	case "", BackendDrawThings, "a1111", "stable_diffusion":
+		if client == nil {
+			client = NewDrawThingsClient(logger, viper.GetString("image.api_url"))
+		}
+		return NewA1111Backend(client), nil
+	case BackendComfyUI:
+		return NewComfyUIBackendFromConfig(logger)
+	case BackendOffline:
+		return NewOfflineBackend(), nil
+	default:
+		return nil, fmt.Errorf("不支持的图像后端: %s，可选: drawthings, comfyui, offline", engine)
+	}
+}
+
+// NewImageBackendFromConfig 根据 image.engine 配置创建图像后端
+func NewImageBackendFromConfig(logger *zap.Logger) (ImageBackend, error) {
+	return NewImageBackend(logger, viper.GetString("image.engine"), nil)
+}
+
+// backendAvailable 检查后端是否可连接，不支持检查的后端视为可用
+func backendAvailable(backend ImageBackend) bool {
+	if checker, ok := backend.(availabilityChecker); ok {
+		return checker.Available()
+	}
+	return true
+}
+
+// GenerateWithBackend 使用指定后端按完整参数生成图像并保存。
+// 随机种子（负数）会先在本地确定具体值，保证生成记录中的种子可以复现该图像
+func GenerateWithBackend(ctx context.Context, backend ImageBackend, params Txt2ImgRequest, outputFile string) (*ImageRecord, error) {
+	if params.Seed < 0 {
+		params.Seed = int(rand.Int31())
+	}
+
+	image, err := backend.Txt2Img(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("生成图像失败: %w", err)
+	}
+
+	// 以后端实际使用的种子为准
+	if image.Seed >= 0 {
+		params.Seed = image.Seed
+	}
+
+	if err := writeImageFile(outputFile, image.Data); err != nil {
+		return nil, err
+	}
+
+	record := NewImageRecord(0, outputFile, params)
+	record.Backend = backend.Name()
+	return &record, nil
+}
+
+// GenerateImageWithBackend 按风格预设组装参数后使用指定后端生成图像，preset为nil时使用默认风格预设
+func GenerateImageWithBackend(ctx context.Context, backend ImageBackend, text, outputFile string, width, height int, preset *StylePreset, seed int) (*ImageRecord, error) {
+	preset = presetOrDefault(preset)
+	record, err := GenerateWithBackend(ctx, backend, preset.Txt2ImgParams(text, width, height, seed), outputFile)
+	if err != nil {
+		return nil, err
+	}
+	record.Style = preset.Name
+	return record, nil
+}
+
+// GenerateImageFromImageWithBackend 根据参考图像使用指定后端生成新图像，preset为nil时使用默认风格预设
+func GenerateImageFromImageWithBackend(ctx context.Context, backend ImageBackend, initImagePath, text, outputFile string, width, height int, preset *StylePreset) error {
+	params, err := img2ImgParams(initImagePath, text, width, height, preset)
+	if err != nil {
+		return err
+	}
+
+	image, err := backend.Img2Img(ctx, params)
+	if err != nil {
+		return fmt.Errorf("图生图失败: %v", err)
+	}
+	return writeImageFile(outputFile, image.Data)
+}
+
+// writeImageFile 保存图像数据，自动创建输出目录
+func writeImageFile(outputFile string, data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("后端返回的图像为空")
+	}
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	if err := os.WriteFile(outputFile, data, 0644); err != nil {
+		return fmt.Errorf("保存图像文件失败: %v", err)
+	}
+	return nil
+}
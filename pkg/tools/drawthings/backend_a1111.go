@@ -0,0 +1,130 @@
+package drawthings
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// A1111Backend DrawThings / Stable Diffusion WebUI（AUTOMATIC1111风格API）后端
+type A1111Backend struct {
+	Client *DrawThingsClient
+}
+
+// NewA1111Backend 使用已有的DrawThings客户端创建后端
+func NewA1111Backend(client *DrawThingsClient) *A1111Backend {
+	return &A1111Backend{Client: client}
+}
+
+// Name 后端名称
+func (b *A1111Backend) Name() string {
+	return BackendDrawThings
+}
+
+// Available 检查API是否可连接
+func (b *A1111Backend) Available() bool {
+	return b.Client.APIAvailable.Load() || b.Client.CheckAPIAvailability()
+}
+
+// Txt2Img 调用 /sdapi/v1/txt2img
+func (b *A1111Backend) Txt2Img(ctx context.Context, params Txt2ImgRequest) (*BackendImage, error) {
+	response, err := b.Client.Txt2ImgContext(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Images) == 0 {
+		return nil, fmt.Errorf("API返回的图像数量为0")
+	}
+
+	data, err := decodeBase64Image(response.Images[0])
+	if err != nil {
+		return nil, err
+	}
+
+	seed := -1
+	if s, ok := responseSeed(response); ok {
+		seed = s
+	}
+	return &BackendImage{Data: data, Seed: seed}, nil
+}
+
+// Img2Img 调用 /sdapi/v1/img2img
+func (b *A1111Backend) Img2Img(ctx context.Context, params Img2ImgRequest) (*BackendImage, error) {
+	response, err := b.Client.Img2ImgContext(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Images) == 0 {
+		return nil, fmt.Errorf("API返回的图像数量为0")
+	}
+
+	data, err := decodeBase64Image(response.Images[0])
+	if err != nil {
+		return nil, err
+	}
+
+	seed := -1
+	if params.Seed > 0 {
+		seed = params.Seed
+	}
+	return &BackendImage{Data: data, Seed: seed}, nil
+}
+
+// ListModels 调用 /sdapi/v1/sd-models 列出模型
+func (b *A1111Backend) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", b.Client.BaseURL+"/sdapi/v1/sd-models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+
+	resp, err := b.Client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var models []struct {
+		Title     string `json:"title"`
+		ModelName string `json:"model_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&models); err != nil {
+		return nil, fmt.Errorf("解析模型列表失败: %v", err)
+	}
+
+	names := make([]string, 0, len(models))
+	for _, model := range models {
+		if model.ModelName != "" {
+			names = append(names, model.ModelName)
+		} else {
+			names = append(names, model.Title)
+		}
+	}
+	b.Client.Logger.Info("获取模型列表成功", zap.Int("count", len(names)))
+	return names, nil
+}
+
+// decodeBase64Image 解码API返回的Base64图像，兼容带 data:image/...;base64, 前缀的数据
+func decodeBase64Image(data string) ([]byte, error) {
+	if strings.HasPrefix(data, "data:") {
+		if i := strings.Index(data, ","); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+
+	imgData, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("解码Base64图像数据失败: %v", err)
+	}
+	return imgData, nil
+}
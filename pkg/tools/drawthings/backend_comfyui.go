@@ -0,0 +1,430 @@
+package drawthings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// defaultComfyUITxt2ImgWorkflow 内置的ComfyUI文生图工作流（API格式），
+// 形如 "{{seed}}" 的占位符在提交前替换为实际参数
+const defaultComfyUITxt2ImgWorkflow = `{
+  "3": {"class_type": "KSampler", "inputs": {"seed": "{{seed}}", "steps": "{{steps}}", "cfg": "{{cfg}}", "sampler_name": "euler", "scheduler": "normal", "denoise": 1, "model": ["4", 0], "positive": ["6", 0], "negative": ["7", 0], "latent_image": ["5", 0]}},
+  "4": {"class_type": "CheckpointLoaderSimple", "inputs": {"ckpt_name": "{{model}}"}},
+  "5": {"class_type": "EmptyLatentImage", "inputs": {"width": "{{width}}", "height": "{{height}}", "batch_size": 1}},
+  "6": {"class_type": "CLIPTextEncode", "inputs": {"text": "{{prompt}}", "clip": ["4", 1]}},
+  "7": {"class_type": "CLIPTextEncode", "inputs": {"text": "{{negative_prompt}}", "clip": ["4", 1]}},
+  "8": {"class_type": "VAEDecode", "inputs": {"samples": ["3", 0], "vae": ["4", 2]}},
+  "9": {"class_type": "SaveImage", "inputs": {"filename_prefix": "novel_video", "images": ["8", 0]}}
+}`
+
+// defaultComfyUIImg2ImgWorkflow 内置的ComfyUI图生图工作流，参考图先上传再通过 "{{init_image}}" 引用
+const defaultComfyUIImg2ImgWorkflow = `{
+  "3": {"class_type": "KSampler", "inputs": {"seed": "{{seed}}", "steps": "{{steps}}", "cfg": "{{cfg}}", "sampler_name": "euler", "scheduler": "normal", "denoise": "{{denoise}}", "model": ["4", 0], "positive": ["6", 0], "negative": ["7", 0], "latent_image": ["5", 0]}},
+  "4": {"class_type": "CheckpointLoaderSimple", "inputs": {"ckpt_name": "{{model}}"}},
+  "5": {"class_type": "VAEEncode", "inputs": {"pixels": ["10", 0], "vae": ["4", 2]}},
+  "6": {"class_type": "CLIPTextEncode", "inputs": {"text": "{{prompt}}", "clip": ["4", 1]}},
+  "7": {"class_type": "CLIPTextEncode", "inputs": {"text": "{{negative_prompt}}", "clip": ["4", 1]}},
+  "8": {"class_type": "VAEDecode", "inputs": {"samples": ["3", 0], "vae": ["4", 2]}},
+  "9": {"class_type": "SaveImage", "inputs": {"filename_prefix": "novel_video", "images": ["8", 0]}},
+  "10": {"class_type": "LoadImage", "inputs": {"image": "{{init_image}}"}}
+}`
+
+// ComfyUIBackend ComfyUI后端：按工作流模板提交任务，轮询 /history 后从 /view 下载图像
+type ComfyUIBackend struct {
+	BaseURL         string
+	Txt2ImgWorkflow string // 文生图工作流模板（API格式JSON）
+	Img2ImgWorkflow string // 图生图工作流模板
+	Model           string // 不为空时覆盖请求中的模型
+	PollInterval    time.Duration
+	Timeout         time.Duration // 单个任务的最长等待时间，<=0表示不限
+	ClientID        string
+	HTTPClient      *http.Client
+	Logger          *zap.Logger
+}
+
+// NewComfyUIBackend 使用内置工作流模板创建ComfyUI后端
+func NewComfyUIBackend(logger *zap.Logger, baseURL string) *ComfyUIBackend {
+	if baseURL == "" {
+		baseURL = "http://127.0.0.1:8188" // ComfyUI默认地址
+	}
+	return &ComfyUIBackend{
+		BaseURL:         strings.TrimRight(baseURL, "/"),
+		Txt2ImgWorkflow: defaultComfyUITxt2ImgWorkflow,
+		Img2ImgWorkflow: defaultComfyUIImg2ImgWorkflow,
+		PollInterval:    time.Second,
+		Timeout:         10 * time.Minute,
+		ClientID:        fmt.Sprintf("novel-video-workflow-%d", time.Now().UnixNano()),
+		HTTPClient:      &http.Client{Timeout: 60 * time.Second},
+		Logger:          logger,
+	}
+}
+
+// NewComfyUIBackendFromConfig 根据 image.comfyui 配置创建ComfyUI后端：
+// url、model、workflow_file、img2img_workflow_file、poll_interval_ms、timeout_seconds
+func NewComfyUIBackendFromConfig(logger *zap.Logger) (*ComfyUIBackend, error) {
+	b := NewComfyUIBackend(logger, viper.GetString("image.comfyui.url"))
+	b.Model = viper.GetString("image.comfyui.model")
+
+	if path := viper.GetString("image.comfyui.workflow_file"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取ComfyUI工作流模板失败: %v", err)
+		}
+		b.Txt2ImgWorkflow = string(data)
+	}
+	if path := viper.GetString("image.comfyui.img2img_workflow_file"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取ComfyUI图生图工作流模板失败: %v", err)
+		}
+		b.Img2ImgWorkflow = string(data)
+	}
+	if ms := viper.GetInt("image.comfyui.poll_interval_ms"); ms > 0 {
+		b.PollInterval = time.Duration(ms) * time.Millisecond
+	}
+	if secs := viper.GetInt("image.comfyui.timeout_seconds"); secs > 0 {
+		b.Timeout = time.Duration(secs) * time.Second
+	}
+	return b, nil
+}
+
+// Name 后端名称
+func (b *ComfyUIBackend) Name() string {
+	return BackendComfyUI
+}
+
+// Txt2Img 按文生图模板提交任务并等待结果
+func (b *ComfyUIBackend) Txt2Img(ctx context.Context, params Txt2ImgRequest) (*BackendImage, error) {
+	values := b.templateValues(params.Prompt, params.NegativePrompt, params.Model, params.SamplerName, params.Seed, params.Width, params.Height, params.Steps, params.GuidanceScale)
+	values["denoise"] = 1.0
+
+	data, err := b.run(ctx, b.Txt2ImgWorkflow, values)
+	if err != nil {
+		return nil, err
+	}
+	return &BackendImage{Data: data, Seed: params.Seed}, nil
+}
+
+// Img2Img 上传参考图后按图生图模板提交任务并等待结果
+func (b *ComfyUIBackend) Img2Img(ctx context.Context, params Img2ImgRequest) (*BackendImage, error) {
+	if len(params.InitImages) == 0 {
+		return nil, fmt.Errorf("缺少参考图像")
+	}
+	initImage, err := decodeBase64Image(params.InitImages[0])
+	if err != nil {
+		return nil, err
+	}
+	imageName, err := b.uploadImage(ctx, initImage)
+	if err != nil {
+		return nil, err
+	}
+
+	// 图生图请求没有指定种子时使用本地随机种子
+	seed := params.Seed
+	if seed <= 0 {
+		seed = int(time.Now().UnixNano() & 0x7fffffff)
+	}
+	values := b.templateValues(params.Prompt, params.NegativePrompt, params.Model, params.SamplerName, seed, params.Width, params.Height, params.Steps, params.GuidanceScale)
+	values["denoise"] = params.Strength
+	values["init_image"] = imageName
+
+	data, err := b.run(ctx, b.Img2ImgWorkflow, values)
+	if err != nil {
+		return nil, err
+	}
+	return &BackendImage{Data: data, Seed: seed}, nil
+}
+
+// ListModels 通过 /object_info/CheckpointLoaderSimple 列出可用的checkpoint
+func (b *ComfyUIBackend) ListModels(ctx context.Context) ([]string, error) {
+	var info map[string]struct {
+		Input struct {
+			Required struct {
+				CkptName []json.RawMessage `json:"ckpt_name"`
+			} `json:"required"`
+		} `json:"input"`
+	}
+	if err := b.getJSON(ctx, "/object_info/CheckpointLoaderSimple", &info); err != nil {
+		return nil, err
+	}
+
+	node, ok := info["CheckpointLoaderSimple"]
+	if !ok || len(node.Input.Required.CkptName) == 0 {
+		return nil, fmt.Errorf("ComfyUI未返回checkpoint列表")
+	}
+	var models []string
+	if err := json.Unmarshal(node.Input.Required.CkptName[0], &models); err != nil {
+		return nil, fmt.Errorf("解析checkpoint列表失败: %v", err)
+	}
+	return models, nil
+}
+
+// templateValues 组装工作流模板的占位符取值
+func (b *ComfyUIBackend) templateValues(prompt, negativePrompt, model, sampler string, seed, width, height, steps int, cfg float64) map[string]interface{} {
+	if b.Model != "" {
+		model = b.Model
+	}
+	return map[string]interface{}{
+		"prompt":          prompt,
+		"negative_prompt": negativePrompt,
+		"model":           model,
+		"sampler":         sampler,
+		"seed":            seed,
+		"width":           width,
+		"height":          height,
+		"steps":           steps,
+		"cfg":             cfg,
+	}
+}
+
+// run 提交工作流并等待第一张输出图像
+func (b *ComfyUIBackend) run(ctx context.Context, template string, values map[string]interface{}) ([]byte, error) {
+	workflow, err := RenderComfyUIWorkflow(template, values)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+
+	promptID, err := b.submit(ctx, workflow)
+	if err != nil {
+		return nil, err
+	}
+	b.Logger.Info("ComfyUI任务已提交", zap.String("prompt_id", promptID))
+
+	output, err := b.waitForOutput(ctx, promptID)
+	if err != nil {
+		return nil, err
+	}
+	return b.download(ctx, output)
+}
+
+// submit 提交工作流，返回prompt_id
+func (b *ComfyUIBackend) submit(ctx context.Context, workflow map[string]interface{}) (string, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"prompt":    workflow,
+		"client_id": b.ClientID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("序列化ComfyUI工作流失败: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", b.BaseURL+"/prompt", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		PromptID string `json:"prompt_id"`
+	}
+	if err := b.doJSON(req, &result); err != nil {
+		return "", err
+	}
+	if result.PromptID == "" {
+		return "", fmt.Errorf("ComfyUI未返回prompt_id")
+	}
+	return result.PromptID, nil
+}
+
+// comfyUIImage ComfyUI输出图像的引用
+type comfyUIImage struct {
+	Filename  string `json:"filename"`
+	Subfolder string `json:"subfolder"`
+	Type      string `json:"type"`
+}
+
+// waitForOutput 轮询 /history/{prompt_id}，直到任务产生输出图像或失败
+func (b *ComfyUIBackend) waitForOutput(ctx context.Context, promptID string) (*comfyUIImage, error) {
+	for {
+		var history map[string]struct {
+			Outputs map[string]struct {
+				Images []comfyUIImage `json:"images"`
+			} `json:"outputs"`
+			Status struct {
+				StatusStr string `json:"status_str"`
+				Completed bool   `json:"completed"`
+			} `json:"status"`
+		}
+		if err := b.getJSON(ctx, "/history/"+url.PathEscape(promptID), &history); err != nil {
+			return nil, err
+		}
+
+		if entry, ok := history[promptID]; ok {
+			if entry.Status.StatusStr == "error" {
+				return nil, fmt.Errorf("ComfyUI任务执行失败: %s", promptID)
+			}
+			for _, output := range entry.Outputs {
+				if len(output.Images) > 0 {
+					return &output.Images[0], nil
+				}
+			}
+			if entry.Status.Completed {
+				return nil, fmt.Errorf("ComfyUI任务已完成但没有输出图像: %s", promptID)
+			}
+		}
+
+		if err := sleepContext(ctx, b.PollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// download 通过 /view 下载输出图像
+func (b *ComfyUIBackend) download(ctx context.Context, image *comfyUIImage) ([]byte, error) {
+	query := url.Values{}
+	query.Set("filename", image.Filename)
+	query.Set("subfolder", image.Subfolder)
+	query.Set("type", image.Type)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", b.BaseURL+"/view?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	resp, err := b.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载ComfyUI图像失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取ComfyUI图像失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(data)}
+	}
+	return data, nil
+}
+
+// uploadImage 通过 /upload/image 上传参考图，返回LoadImage节点可引用的文件名
+func (b *ComfyUIBackend) uploadImage(ctx context.Context, data []byte) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("image", fmt.Sprintf("init_%d.png", time.Now().UnixNano()))
+	if err != nil {
+		return "", fmt.Errorf("创建上传表单失败: %v", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", fmt.Errorf("写入上传表单失败: %v", err)
+	}
+	writer.WriteField("overwrite", "true")
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("创建上传表单失败: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", b.BaseURL+"/upload/image", &body)
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	var result struct {
+		Name      string `json:"name"`
+		Subfolder string `json:"subfolder"`
+	}
+	if err := b.doJSON(req, &result); err != nil {
+		return "", err
+	}
+	if result.Subfolder != "" {
+		return result.Subfolder + "/" + result.Name, nil
+	}
+	return result.Name, nil
+}
+
+func (b *ComfyUIBackend) getJSON(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", b.BaseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	return b.doJSON(req, out)
+}
+
+// doJSON 发送请求并解析JSON响应，非200状态码返回 HTTPStatusError 以便工作池判断是否重试
+func (b *ComfyUIBackend) doJSON(req *http.Request, out interface{}) error {
+	resp, err := b.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析ComfyUI响应失败: %v", err)
+	}
+	return nil
+}
+
+// RenderComfyUIWorkflow 替换工作流模板中的占位符：
+// 整个字符串恰好为 "{{name}}" 时替换为对应类型的值（数字保持为数字），
+// 字符串中包含 {{name}} 时按文本替换
+func RenderComfyUIWorkflow(template string, values map[string]interface{}) (map[string]interface{}, error) {
+	var workflow map[string]interface{}
+	if err := json.Unmarshal([]byte(template), &workflow); err != nil {
+		return nil, fmt.Errorf("解析ComfyUI工作流模板失败: %v", err)
+	}
+	return substituteWorkflow(workflow, values).(map[string]interface{}), nil
+}
+
+func substituteWorkflow(node interface{}, values map[string]interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = substituteWorkflow(child, values)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = substituteWorkflow(child, values)
+		}
+		return v
+	case string:
+		if strings.HasPrefix(v, "{{") && strings.HasSuffix(v, "}}") {
+			if value, ok := values[strings.TrimSpace(v[2:len(v)-2])]; ok {
+				return value
+			}
+		}
+		for name, value := range values {
+			placeholder := "{{" + name + "}}"
+			if strings.Contains(v, placeholder) {
+				v = strings.ReplaceAll(v, placeholder, formatTemplateValue(value))
+			}
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func formatTemplateValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
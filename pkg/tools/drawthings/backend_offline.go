@@ -0,0 +1,54 @@
+package drawthings
+
+import (
+	"context"
+
+	image "novel-video-workflow/pkg/tools/image"
+)
+
+// OfflineBackend 离线占位图后端，不依赖任何推理服务，
+// 按提示词、分镜序号、尺寸和种子绘制确定性的占位图，便于在测试和无GPU环境中跑通完整流程
+type OfflineBackend struct{}
+
+// NewOfflineBackend 创建离线占位图后端
+func NewOfflineBackend() *OfflineBackend {
+	return &OfflineBackend{}
+}
+
+// Name 后端名称
+func (b *OfflineBackend) Name() string {
+	return BackendOffline
+}
+
+// Txt2Img 绘制包含提示词和分镜序号的占位图
+func (b *OfflineBackend) Txt2Img(ctx context.Context, params Txt2ImgRequest) (*BackendImage, error) {
+	return b.render(ctx, params.Prompt, params.Width, params.Height, params.Seed)
+}
+
+// Img2Img 忽略参考图像，与文生图一样绘制占位图
+func (b *OfflineBackend) Img2Img(ctx context.Context, params Img2ImgRequest) (*BackendImage, error) {
+	return b.render(ctx, params.Prompt, params.Width, params.Height, params.Seed)
+}
+
+// ListModels 离线后端没有模型
+func (b *OfflineBackend) ListModels(ctx context.Context) ([]string, error) {
+	return []string{BackendOffline}, nil
+}
+
+func (b *OfflineBackend) render(ctx context.Context, prompt string, width, height, seed int) (*BackendImage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := image.RenderPlaceholderPNG(image.PlaceholderOptions{
+		Prompt: prompt,
+		Index:  SceneIndexFromContext(ctx),
+		Width:  width,
+		Height: height,
+		Seed:   int64(seed),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BackendImage{Data: data, Seed: seed}, nil
+}
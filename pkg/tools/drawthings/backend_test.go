@@ -0,0 +1,163 @@
+package drawthings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRenderComfyUIWorkflow(t *testing.T) {
+	workflow, err := RenderComfyUIWorkflow(defaultComfyUITxt2ImgWorkflow, map[string]interface{}{
+		"prompt":          "雨夜的街道",
+		"negative_prompt": "模糊",
+		"model":           "sd15.safetensors",
+		"seed":            42,
+		"width":           512,
+		"height":          896,
+		"steps":           8,
+		"cfg":             1.5,
+	})
+	if err != nil {
+		t.Fatalf("渲染工作流失败: %v", err)
+	}
+
+	sampler := workflow["3"].(map[string]interface{})["inputs"].(map[string]interface{})
+	if sampler["seed"] != 42 || sampler["cfg"] != 1.5 {
+		t.Errorf("数值占位符应替换为数字: %+v", sampler)
+	}
+	text := workflow["6"].(map[string]interface{})["inputs"].(map[string]interface{})["text"]
+	if text != "雨夜的街道" {
+		t.Errorf("提示词未替换: %v", text)
+	}
+
+	partial, err := RenderComfyUIWorkflow(`{"1": {"inputs": {"text": "风格: {{prompt}}, 步数 {{steps}}"}}}`, map[string]interface{}{"prompt": "古城", "steps": 8})
+	if err != nil {
+		t.Fatalf("渲染工作流失败: %v", err)
+	}
+	if got := partial["1"].(map[string]interface{})["inputs"].(map[string]interface{})["text"]; got != "风格: 古城, 步数 8" {
+		t.Errorf("字符串中的占位符应按文本替换: %v", got)
+	}
+}
+
+func TestComfyUIBackendTxt2Img(t *testing.T) {
+	var submitted map[string]interface{}
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/prompt":
+			var body struct {
+				Prompt map[string]interface{} `json:"prompt"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			submitted = body.Prompt
+			json.NewEncoder(w).Encode(map[string]string{"prompt_id": "p1"})
+		case "/history/p1":
+			// 第一次轮询时任务尚未完成
+			if atomic.AddInt32(&polls, 1) == 1 {
+				w.Write([]byte("{}"))
+				return
+			}
+			w.Write([]byte(`{"p1": {"outputs": {"9": {"images": [{"filename": "novel_video_001.png", "subfolder": "", "type": "output"}]}}, "status": {"status_str": "success", "completed": true}}}`))
+		case "/view":
+			if r.URL.Query().Get("filename") != "novel_video_001.png" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte("png-data"))
+		case "/object_info/CheckpointLoaderSimple":
+			w.Write([]byte(`{"CheckpointLoaderSimple": {"input": {"required": {"ckpt_name": [["a.safetensors", "b.ckpt"], {}]}}}}`))
+		}
+	}))
+	defer server.Close()
+
+	backend := NewComfyUIBackend(zap.NewNop(), server.URL)
+	backend.PollInterval = time.Millisecond
+
+	preset, _ := NewStyleRegistry().Get("plain")
+	record, err := GenerateWithBackend(context.Background(), backend, preset.Txt2ImgParams("雨夜", 512, 896, 7), filepath.Join(t.TempDir(), "scene_01.png"))
+	if err != nil {
+		t.Fatalf("ComfyUI生成失败: %v", err)
+	}
+	if record.Backend != BackendComfyUI || record.Parameters.Seed != 7 {
+		t.Errorf("生成记录错误: %+v", record)
+	}
+	if polls < 2 {
+		t.Errorf("应轮询直至任务完成, 实际 %d 次", polls)
+	}
+
+	latent := submitted["5"].(map[string]interface{})["inputs"].(map[string]interface{})
+	if latent["width"] != float64(512) || latent["height"] != float64(896) {
+		t.Errorf("提交的工作流尺寸错误: %+v", latent)
+	}
+
+	models, err := backend.ListModels(context.Background())
+	if err != nil || len(models) != 2 || models[0] != "a.safetensors" {
+		t.Errorf("模型列表错误: %v, %v", models, err)
+	}
+}
+
+func TestOfflineBackendIsDeterministic(t *testing.T) {
+	backend := NewOfflineBackend()
+	params := Txt2ImgRequest{Prompt: "雨夜的街道", Width: 64, Height: 112, Seed: 3}
+
+	first, err := backend.Txt2Img(ContextWithSceneIndex(context.Background(), 1), params)
+	if err != nil {
+		t.Fatalf("离线生成失败: %v", err)
+	}
+	second, _ := backend.Txt2Img(ContextWithSceneIndex(context.Background(), 1), params)
+	other, _ := backend.Txt2Img(ContextWithSceneIndex(context.Background(), 2), params)
+
+	if !bytes.Equal(first.Data, second.Data) {
+		t.Errorf("相同参数应生成相同的占位图")
+	}
+	if bytes.Equal(first.Data, other.Data) {
+		t.Errorf("不同分镜序号的占位图应不同")
+	}
+	if !bytes.HasPrefix(first.Data, []byte("\x89PNG")) {
+		t.Errorf("占位图应为PNG")
+	}
+}
+
+func TestChapterImageGeneratorWithOfflineBackend(t *testing.T) {
+	ollama := newFakeOllama(t, func(req ChatRequest) string { return "阴暗的走廊" })
+	defer ollama.Close()
+
+	generator := &ChapterImageGenerator{
+		OllamaClient: NewOllamaClient(zap.NewNop(), ollama.URL, "test"),
+		Logger:       zap.NewNop(),
+		Pool:         NewImageWorkerPool(zap.NewNop(), 2, RetryPolicy{MaxAttempts: 1}),
+		Backend:      NewOfflineBackend(),
+	}
+
+	dir := t.TempDir()
+	preset, _ := NewStyleRegistry().Get("plain")
+	results, err := generator.GenerateImagesFromChapterContext(context.Background(), "第一段文字。\n\n第二段文字。", dir, 64, 112, preset)
+	if err != nil {
+		t.Fatalf("章节图像生成失败: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("应生成2张图像, 实际 %d", len(results))
+	}
+	for _, result := range results {
+		if _, err := os.Stat(result.ImageFile); err != nil {
+			t.Errorf("图像文件不存在: %v", err)
+		}
+	}
+
+	manifest, err := LoadImageManifest(dir)
+	if err != nil {
+		t.Fatalf("加载清单失败: %v", err)
+	}
+	if len(manifest.Images) != 2 || manifest.Images[0].Backend != BackendOffline || manifest.Images[0].Style != "plain" {
+		t.Errorf("清单记录错误: %+v", manifest.Images)
+	}
+}
@@ -2,12 +2,14 @@ package drawthings
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
@@ -16,17 +18,51 @@ type ChapterImageGenerator struct {
 	Client       *DrawThingsClient
 	OllamaClient *OllamaClient
 	Logger       *zap.Logger
+	// CharacterBible 角色设定集，设置后会把提及角色的固定外貌描述注入提示词
+	CharacterBible *CharacterBible
+	// Pool 图像生成工作池，默认使用进程内共享的工作池
+	Pool *ImageWorkerPool
+	// Backend 图像生成后端，为nil时使用Client（DrawThings）
+	Backend ImageBackend
+	// QC 图像质检参数，设置后生成完成时检查图像并重新生成未通过的图像
+	QC *QCOptions
+	// Mode 生成方式：txt2img（默认）或 continuity（同一地点的连续分镜以上一张图像为参考图）
+	Mode string
+	// ContinuityStrength 连续生成时图生图的重绘幅度
+	ContinuityStrength float64
 }
 
-// NewChapterImageGenerator 创建章节图像生成器
+// NewChapterImageGenerator 创建章节图像生成器，图像后端由 image.engine 配置决定
 func NewChapterImageGenerator(logger *zap.Logger) *ChapterImageGenerator {
 	client := NewDrawThingsClient(logger, "http://localhost:7861")
-	ollamaClient := NewOllamaClient(logger, "http://localhost:11434", "qwen3:4b") // 使用默认Ollama配置
-	return &ChapterImageGenerator{
+	ollamaClient := NewOllamaClientFromConfig(logger)
+
+	backend, err := NewImageBackend(logger, viper.GetString("image.engine"), client)
+	if err != nil {
+		logger.Warn("创建图像后端失败，使用DrawThings", zap.Error(err))
+		backend = NewA1111Backend(client)
+	}
+
+	generator := &ChapterImageGenerator{
 		Client:       client,
 		OllamaClient: ollamaClient,
 		Logger:       logger,
+		Pool:         DefaultImageWorkerPool(logger),
+		Backend:      backend,
+	}
+	if opts, enabled := QCOptionsFromConfig(); enabled {
+		generator.QC = &opts
 	}
+	generator.Mode, generator.ContinuityStrength = GenerationModeFromConfig()
+	return generator
+}
+
+// backend 返回当前使用的图像后端
+func (c *ChapterImageGenerator) backend() ImageBackend {
+	if c.Backend != nil {
+		return c.Backend
+	}
+	return NewA1111Backend(c.Client)
 }
 
 // ParagraphImage 生成的段落图像信息
@@ -35,10 +71,138 @@ type ParagraphImage struct {
 	ImageFile     string `json:"image_file"`
 	ImagePrompt   string `json:"image_prompt"` // 新增：图像提示词
 	Index         int    `json:"index"`
+	Seed          int    `json:"seed"` // 实际使用的种子，可用于单独重新生成
 }
 
-// GenerateImagesFromChapter 根据章节文本生成图像
-func (c *ChapterImageGenerator) GenerateImagesFromChapter(chapterText, outputDir string, width, height int, isSuspense bool) ([]ParagraphImage, error) {
+// ScenePrompt 待生成的分镜图像
+type ScenePrompt struct {
+	Index         int    // 分镜序号，从1开始
+	ImageFile     string // 图像文件完整路径
+	Prompt        string
+	Seed          int  // -1表示随机种子
+	Styled        bool // 提示词由Ollama按预设的风格描述生成，已包含风格，生成时不再加预设的前后缀
+	SourceText    string
+	SourceStart   int
+	SourceEnd     int
+	SpanEstimated bool
+	Storyboard    *StoryboardScene // 结构化分镜信息，按段落生成时为空
+}
+
+// GenerateScenes 通过工作池并发生成一组分镜图像，并写入图像目录的生成清单。
+// 未包含风格的提示词按风格预设加上前后缀，width/height为0时使用预设尺寸；
+// 生成方式为 continuity 时，同一地点的连续分镜以上一张图像为参考图生成。
+// 结果按分镜序号排序，scenes按序号递增时与之一一对应
+func (c *ChapterImageGenerator) GenerateScenes(ctx context.Context, imagesDir string, chapter int, source string, scenes []ScenePrompt, width, height int, preset *StylePreset) []ImageJobResult {
+	pool := c.Pool
+	if pool == nil {
+		pool = DefaultImageWorkerPool(c.Logger)
+	}
+	backend := c.backend()
+
+	var results []ImageJobResult
+	mode := GenerationModeTxt2Img
+	if c.Mode == GenerationModeContinuity {
+		mode = GenerationModeContinuity
+		results = c.generateContinuityScenes(ctx, pool, backend, chapter, scenes, width, height, preset)
+	} else {
+		jobs := make([]ImageJob, len(scenes))
+		for i, scene := range scenes {
+			scene := scene
+			jobs[i] = ImageJob{
+				Chapter: chapter,
+				Index:   scene.Index,
+				Backend: backend.Name(),
+				Generate: func(ctx context.Context) (*ImageRecord, error) {
+					ctx = ContextWithSceneIndex(ctx, scene.Index)
+					return generateSceneWithBackend(ctx, backend, scene, width, height, preset)
+				},
+			}
+		}
+		results = pool.Run(ctx, jobs)
+	}
+
+	// 记录生成参数，便于复现或单独重新生成
+	sceneByIndex := make(map[int]ScenePrompt, len(scenes))
+	for _, scene := range scenes {
+		sceneByIndex[scene.Index] = scene
+	}
+	manifest := NewImageManifest(chapter, source)
+	manifest.GenerationMode = mode
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		scene := sceneByIndex[result.Index]
+		record := *result.Record
+		record.Index = scene.Index
+		record.SourceText = scene.SourceText
+		record.SourceStart, record.SourceEnd = scene.SourceStart, scene.SourceEnd
+		record.SpanEstimated = scene.SpanEstimated
+		record.Scene = scene.Storyboard
+		manifest.Put(record)
+	}
+	if len(manifest.Images) > 0 {
+		if err := manifest.Save(imagesDir); err != nil {
+			c.Logger.Warn("保存图像生成清单失败", zap.Error(err))
+		} else if c.QC != nil {
+			c.runQC(ctx, pool, backend, imagesDir, results)
+		}
+	}
+
+	return results
+}
+
+// txt2ImgParams 按风格预设组装分镜的文生图参数
+func (s ScenePrompt) txt2ImgParams(preset *StylePreset, width, height int) Txt2ImgRequest {
+	if s.Styled {
+		return preset.Txt2ImgParamsStyled(s.Prompt, width, height, s.Seed)
+	}
+	return preset.Txt2ImgParams(s.Prompt, width, height, s.Seed)
+}
+
+// generateSceneWithBackend 使用指定后端以文生图生成分镜图像，preset为nil时使用默认风格预设
+func generateSceneWithBackend(ctx context.Context, backend ImageBackend, scene ScenePrompt, width, height int, preset *StylePreset) (*ImageRecord, error) {
+	preset = presetOrDefault(preset)
+	record, err := GenerateWithBackend(ctx, backend, scene.txt2ImgParams(preset, width, height), scene.ImageFile)
+	if err != nil {
+		return nil, err
+	}
+	record.Style = preset.Name
+	return record, nil
+}
+
+// runQC 质检本次生成的图像，重新生成的图像以清单中的最新记录更新到结果中
+func (c *ChapterImageGenerator) runQC(ctx context.Context, pool *ImageWorkerPool, backend ImageBackend, imagesDir string, results []ImageJobResult) {
+	report, err := RunChapterQC(ctx, c.Logger, pool, backend, imagesDir, *c.QC)
+	if err != nil {
+		c.Logger.Warn("图像质检失败", zap.Error(err))
+		return
+	}
+	if report.Failed > 0 {
+		c.Logger.Warn("部分图像重新生成后仍未通过质检", zap.Int("failed", report.Failed), zap.String("report", filepath.Join(imagesDir, QCReportFileName)))
+	}
+
+	manifest, err := LoadImageManifest(imagesDir)
+	if err != nil {
+		return
+	}
+	for i := range results {
+		if record := manifest.Find(results[i].Index); record != nil && results[i].Err == nil {
+			updated := *record
+			results[i].Record = &updated
+		}
+	}
+}
+
+// GenerateImagesFromChapter 根据章节文本生成图像，preset为nil时使用默认风格预设
+func (c *ChapterImageGenerator) GenerateImagesFromChapter(chapterText, outputDir string, width, height int, preset *StylePreset) ([]ParagraphImage, error) {
+	return c.GenerateImagesFromChapterContext(context.Background(), chapterText, outputDir, width, height, preset)
+}
+
+// GenerateImagesFromChapterContext 根据章节文本生成图像，先逐段生成提示词，再通过工作池并发生成图像
+func (c *ChapterImageGenerator) GenerateImagesFromChapterContext(ctx context.Context, chapterText, outputDir string, width, height int, preset *StylePreset) ([]ParagraphImage, error) {
+	preset = presetOrDefault(preset)
+
 	// 将相对路径转换为绝对路径
 	absOutputDir, err := filepath.Abs(outputDir)
 	if err != nil {
@@ -50,18 +214,24 @@ func (c *ChapterImageGenerator) GenerateImagesFromChapter(chapterText, outputDir
 	paragraphs := c.splitChapterIntoParagraphs(chapterText)
 
 	var results []ParagraphImage
+	var scenes []ScenePrompt
+	locator := NewSpanLocator(chapterText)
 
 	c.Logger.Info("开始生成章节图像",
 		zap.String("output_dir", absOutputDir),
 		zap.Int("paragraph_count", len(paragraphs)))
 
-	// 检查DrawThings API可用性
-	if !c.Client.APIAvailable {
-		c.Logger.Warn("DrawThings API不可用，将跳过图像生成步骤", zap.String("api_url", c.Client.BaseURL))
-		return results, fmt.Errorf("DrawThings API不可用，请确保Stable Diffusion WebUI正在运行在 %s", c.Client.BaseURL)
+	// 检查图像后端可用性
+	if !backendAvailable(c.backend()) {
+		c.Logger.Warn("图像后端不可用，将跳过图像生成步骤", zap.String("backend", c.backend().Name()))
+		return results, fmt.Errorf("图像后端 %s 不可用，请确保对应的服务已启动", c.backend().Name())
 	}
 
 	for i, paragraph := range paragraphs {
+		if ctx.Err() != nil {
+			break
+		}
+
 		// 跳过空白段落
 		trimmedPara := strings.TrimSpace(paragraph)
 		if trimmedPara == "" {
@@ -77,53 +247,69 @@ func (c *ChapterImageGenerator) GenerateImagesFromChapter(chapterText, outputDir
 			continue
 		}
 
-		// 使用Ollama生成更精确的图像提示词
-		styleDesc := "悬疑风格，氛围紧张，暗淡光线，神秘感"
-		if isSuspense {
-			styleDesc = "悬疑惊悚风格，周围环境模糊成黑影, 空气凝滞,浅景深, 胶片颗粒感, 低饱和度，极致悬疑氛围, 阴沉窒息感, 夏季，环境阴霾，其他部分模糊不可见"
-		}
-
-		imagePrompt, err := c.OllamaClient.GenerateImagePrompt(trimmedPara, styleDesc)
+		// 使用Ollama按预设的风格描述生成更精确的图像提示词
+		imagePrompt, err := c.OllamaClient.GenerateImagePrompt(trimmedPara, preset.OllamaStyle)
+		styled := err == nil
 		if err != nil {
 			c.Logger.Warn("使用Ollama生成图像提示词失败，使用原始文本",
 				zap.Int("paragraph_index", i),
 				zap.String("paragraph", trimmedPara),
 				zap.Error(err))
-			// 如果Ollama失败，使用原始文本加上悬疑风格
-			if isSuspense {
-				imagePrompt = trimmedPara + ", 周围环境模糊成黑影, 空气凝滞,浅景深, 胶片颗粒感, 低饱和度，极致悬疑氛围, 阴沉窒息感, 夏季，环境阴霾，其他部分模糊不可见"
-			} else {
-				imagePrompt = trimmedPara
-			}
+			// 如果Ollama失败，使用原始文本，风格修饰在生成时由预设统一添加
+			imagePrompt = trimmedPara
 		}
 
-		// 使用生成的提示词调用DrawThings API生成图像
-		err = c.Client.GenerateImageFromText(imagePrompt, imageFile, width, height, false) // isSuspense已经在提示词中处理
-		if err != nil {
+		// 注入角色设定，保持角色形象一致
+		seed := -1
+		if c.CharacterBible != nil {
+			imagePrompt, seed = c.CharacterBible.ApplyToPrompt(imagePrompt, trimmedPara)
+		}
+
+		sourceStart, sourceEnd := locator.Locate(trimmedPara)
+		scenes = append(scenes, ScenePrompt{
+			Index:       i + 1,
+			ImageFile:   imageFile,
+			Prompt:      imagePrompt,
+			Seed:        seed,
+			Styled:      styled,
+			SourceText:  trimmedPara,
+			SourceStart: sourceStart,
+			SourceEnd:   sourceEnd,
+		})
+	}
+
+	// 使用生成的提示词并发调用DrawThings API生成图像，结果按段落顺序返回
+	for k, result := range c.GenerateScenes(ctx, absOutputDir, 0, ManifestSourceParagraphs, scenes, width, height, preset) {
+		scene := scenes[k]
+
+		if result.Err != nil {
 			c.Logger.Warn("生成段落图像失败",
-				zap.Int("paragraph_index", i),
-				zap.String("paragraph", trimmedPara),
-				zap.String("prompt", imagePrompt),
-				zap.Error(err))
+				zap.Int("paragraph_index", result.Index-1),
+				zap.String("paragraph", scene.SourceText),
+				zap.String("prompt", scene.Prompt),
+				zap.Int("attempts", result.Attempts),
+				zap.Error(result.Err))
 			continue
 		}
 
 		// 添加到结果
 		results = append(results, ParagraphImage{
-			ParagraphText: trimmedPara,
-			ImageFile:     imageFile,
-			ImagePrompt:   imagePrompt, // 记录使用的提示词
-			Index:         i,
+			ParagraphText: scene.SourceText,
+			ImageFile:     scene.ImageFile,
+			ImagePrompt:   scene.Prompt, // 记录使用的提示词
+			Index:         result.Index - 1,
+			Seed:          result.Record.Parameters.Seed,
 		})
 
 		c.Logger.Info("段落图像生成成功",
-			zap.Int("index", i),
-			zap.String("image_file", imageFile),
-			zap.String("paragraph_preview", c.truncateString(trimmedPara, 50)),
-			zap.String("prompt_preview", c.truncateString(imagePrompt, 80)))
+			zap.Int("index", result.Index-1),
+			zap.String("image_file", scene.ImageFile),
+			zap.String("paragraph_preview", c.truncateString(scene.SourceText, 50)),
+			zap.String("prompt_preview", c.truncateString(scene.Prompt, 80)))
+	}
 
-		// 添加小延迟避免API过载
-		time.Sleep(100 * time.Millisecond)
+	if err := ctx.Err(); err != nil {
+		return results, fmt.Errorf("章节图像生成已取消: %v", err)
 	}
 
 	c.Logger.Info("章节图像生成完成",
@@ -185,7 +371,7 @@ func (c *ChapterImageGenerator) truncateString(s string, maxLen int) string {
 }
 
 // GenerateImageSequenceFromText 根据文本生成一系列图像，用于视频制作
-func (c *ChapterImageGenerator) GenerateImageSequenceFromText(text, outputDir, baseFilename string, width, height int, isSuspense bool) ([]string, error) {
+func (c *ChapterImageGenerator) GenerateImageSequenceFromText(text, outputDir, baseFilename string, width, height int, preset *StylePreset) ([]string, error) {
 	// 分割文本为句子或有意义的片段
 	segments := c.segmentText(text)
 
@@ -212,7 +398,7 @@ func (c *ChapterImageGenerator) GenerateImageSequenceFromText(text, outputDir, b
 		}
 
 		// 生成图像
-		err := c.Client.GenerateImageFromText(trimmedSeg, imageFile, width, height, isSuspense)
+		_, err := GenerateImageWithBackend(ContextWithSceneIndex(context.Background(), i+1), c.backend(), trimmedSeg, imageFile, width, height, preset, -1)
 		if err != nil {
 			c.Logger.Warn("生成文本片段图像失败",
 				zap.Int("segment_index", i),
@@ -306,7 +492,7 @@ func (c *ChapterImageGenerator) splitLongLine(line string) []string {
 }
 
 // ProcessChapterTextFile 处理章节文本文件
-func (c *ChapterImageGenerator) ProcessChapterTextFile(textFilePath, outputDir string, width, height int, isSuspense bool) error {
+func (c *ChapterImageGenerator) ProcessChapterTextFile(textFilePath, outputDir string, width, height int, preset *StylePreset) error {
 	// 读取文本文件
 	file, err := os.Open(textFilePath)
 	if err != nil {
@@ -329,7 +515,7 @@ func (c *ChapterImageGenerator) ProcessChapterTextFile(textFilePath, outputDir s
 	text := content.String()
 
 	// 生成图像
-	_, err = c.GenerateImagesFromChapter(text, outputDir, width, height, isSuspense)
+	_, err = c.GenerateImagesFromChapter(text, outputDir, width, height, preset)
 	if err != nil {
 		return fmt.Errorf("生成章节图像失败: %v", err)
 	}
@@ -0,0 +1,264 @@
+package drawthings
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// CharacterBibleFileName 角色设定文件名，保存在 output/<小说名>/ 下
+const CharacterBibleFileName = "characters.yaml"
+
+// Character 角色设定，Descriptor 会原样注入到提及该角色的每个分镜提示词中
+type Character struct {
+	Name       string   `yaml:"name" json:"name"`
+	Aliases    []string `yaml:"aliases,omitempty" json:"aliases,omitempty"`         // 别名、称呼，用于匹配
+	Descriptor string   `yaml:"descriptor" json:"descriptor"`                       // 固定的外貌描述
+	Seed       int      `yaml:"seed,omitempty" json:"seed,omitempty"`               // 可选的固定种子，0表示不固定
+	LoRA       string   `yaml:"lora,omitempty" json:"lora,omitempty"`               // 可选的LoRA名称
+	LoRAWeight float64  `yaml:"lora_weight,omitempty" json:"lora_weight,omitempty"` // LoRA权重，默认0.8
+}
+
+// CharacterBible 单部小说的角色设定集
+type CharacterBible struct {
+	Novel      string      `yaml:"novel"`
+	Characters []Character `yaml:"characters"`
+}
+
+// CharacterBiblePath 返回小说输出目录下的角色设定文件路径
+func CharacterBiblePath(novelOutputDir string) string {
+	return filepath.Join(novelOutputDir, CharacterBibleFileName)
+}
+
+// LoadCharacterBible 从YAML文件加载角色设定
+func LoadCharacterBible(path string) (*CharacterBible, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取角色设定文件失败: %v", err)
+	}
+
+	var bible CharacterBible
+	if err := yaml.Unmarshal(data, &bible); err != nil {
+		return nil, fmt.Errorf("解析角色设定文件失败: %v", err)
+	}
+	return &bible, nil
+}
+
+// Save 将角色设定保存为YAML，便于人工编辑
+func (b *CharacterBible) Save(path string) error {
+	data, err := yaml.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("序列化角色设定失败: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建角色设定目录失败: %v", err)
+	}
+
+	header := "# 角色设定集：descriptor 会注入到提及该角色的每个分镜提示词中\n# 可手动修改描述，或设置 seed / lora / lora_weight 以固定角色形象\n"
+	if err := os.WriteFile(path, append([]byte(header), data...), 0644); err != nil {
+		return fmt.Errorf("保存角色设定文件失败: %v", err)
+	}
+	return nil
+}
+
+// LoadOrBuildCharacterBible 已有角色设定文件时直接加载（保留人工修改），
+// 否则使用Ollama从前几章提取角色并保存
+func LoadOrBuildCharacterBible(path, novel string, ollamaClient *OllamaClient, earlyChapters []string) (*CharacterBible, error) {
+	if _, err := os.Stat(path); err == nil {
+		return LoadCharacterBible(path)
+	}
+
+	bible := &CharacterBible{Novel: novel}
+	for i, chapter := range earlyChapters {
+		characters, err := ollamaClient.ExtractCharacters(chapter, bible.Characters)
+		if err != nil {
+			ollamaClient.Logger.Warn("从章节提取角色失败", zap.Int("chapter_index", i), zap.Error(err))
+			continue
+		}
+		bible.Merge(characters)
+	}
+
+	if len(bible.Characters) == 0 {
+		return nil, fmt.Errorf("未能从前%d章提取到任何角色", len(earlyChapters))
+	}
+
+	if err := bible.Save(path); err != nil {
+		return nil, err
+	}
+	return bible, nil
+}
+
+// EarlyChapters 按章节号顺序取前n章内容，用于提取角色
+func EarlyChapters(chapters map[int]string, n int) []string {
+	keys := make([]int, 0, len(chapters))
+	for k := range chapters {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	if n > 0 && len(keys) > n {
+		keys = keys[:n]
+	}
+
+	result := make([]string, len(keys))
+	for i, k := range keys {
+		result[i] = chapters[k]
+	}
+	return result
+}
+
+// Merge 合并新提取的角色，已存在的角色保留原有描述，仅补充别名
+func (b *CharacterBible) Merge(characters []Character) {
+	for _, ch := range characters {
+		ch.Name = strings.TrimSpace(ch.Name)
+		if ch.Name == "" || strings.TrimSpace(ch.Descriptor) == "" {
+			continue
+		}
+
+		existing := b.Find(ch.Name)
+		if existing == nil {
+			b.Characters = append(b.Characters, ch)
+			continue
+		}
+		for _, alias := range ch.Aliases {
+			if !existing.matches(alias) {
+				existing.Aliases = append(existing.Aliases, alias)
+			}
+		}
+	}
+}
+
+// Find 根据名称或别名查找角色
+func (b *CharacterBible) Find(name string) *Character {
+	for i := range b.Characters {
+		if b.Characters[i].matches(name) {
+			return &b.Characters[i]
+		}
+	}
+	return nil
+}
+
+func (ch *Character) matches(name string) bool {
+	if ch.Name == name {
+		return true
+	}
+	for _, alias := range ch.Aliases {
+		if alias == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mentionedIn 判断文本中是否提及该角色
+func (ch *Character) mentionedIn(text string) bool {
+	if ch.Name != "" && strings.Contains(text, ch.Name) {
+		return true
+	}
+	for _, alias := range ch.Aliases {
+		if alias != "" && strings.Contains(text, alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// MentionedCharacters 返回在任一文本中被提及的角色，保持设定集中的顺序
+func (b *CharacterBible) MentionedCharacters(texts ...string) []Character {
+	if b == nil {
+		return nil
+	}
+
+	var result []Character
+	for _, ch := range b.Characters {
+		for _, text := range texts {
+			if ch.mentionedIn(text) {
+				result = append(result, ch)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// ApplyToPrompt 将被提及角色的固定外貌描述和LoRA注入提示词，
+// 返回新的提示词以及第一个设置了固定种子的角色种子（未设置时为-1）
+func (b *CharacterBible) ApplyToPrompt(prompt string, sourceTexts ...string) (string, int) {
+	seed := -1
+	characters := b.MentionedCharacters(append([]string{prompt}, sourceTexts...)...)
+	if len(characters) == 0 {
+		return prompt, seed
+	}
+
+	var descriptors, loras []string
+	for _, ch := range characters {
+		descriptors = append(descriptors, fmt.Sprintf("%s：%s", ch.Name, strings.TrimSpace(ch.Descriptor)))
+		if ch.LoRA != "" {
+			weight := ch.LoRAWeight
+			if weight == 0 {
+				weight = 0.8
+			}
+			loras = append(loras, fmt.Sprintf("<lora:%s:%.2f>", ch.LoRA, weight))
+		}
+		if seed == -1 && ch.Seed != 0 {
+			seed = ch.Seed
+		}
+	}
+
+	result := strings.TrimSpace(prompt) + "，" + strings.Join(descriptors, "，")
+	if len(loras) > 0 {
+		result += " " + strings.Join(loras, " ")
+	}
+	return result, seed
+}
+
+// ExtractCharacters 使用Ollama从章节文本中提取有名字的角色及其外貌描述，known为已知角色，避免重复提取
+func (c *OllamaClient) ExtractCharacters(text string, known []Character) ([]Character, error) {
+	systemPrompt := `你是一名小说角色设定整理师，负责为AI绘图整理角色的固定外貌设定。
+要求：
+1. 只提取有明确名字的人物，忽略路人和泛称
+2. 外貌描述应是可直接用于AI绘图的中文短语：性别、年龄段、脸型五官、发型发色、体型、标志性服饰或配饰
+3. 文本没有写明的外貌可以根据人物身份合理补全，但要保持具体、稳定，不要使用"可能""或者"
+4. aliases 填写文中对该人物的其他称呼
+5. 只返回JSON数组，格式如：[{"name": "林晚", "aliases": ["小晚"], "descriptor": "二十岁女性，瓜子脸，黑色齐肩短发，身穿白色连衣裙"}]`
+
+	knownNames := make([]string, 0, len(known))
+	for _, ch := range known {
+		knownNames = append(knownNames, ch.Name)
+	}
+	knownMsg := "（无）"
+	if len(knownNames) > 0 {
+		knownMsg = strings.Join(knownNames, "、")
+	}
+
+	userPrompt := fmt.Sprintf("已整理过的角色（不需要重复返回）：%s\n\n章节内容：\n%s", knownMsg, text)
+
+	response, err := c.Generate(systemPrompt, userPrompt, map[string]interface{}{
+		"temperature":    0.3,
+		"top_p":          0.9,
+		"repeat_penalty": 1.1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := ExtractJSON(response)
+	if err != nil || !strings.HasPrefix(raw, "[") {
+		return nil, fmt.Errorf("响应中未找到角色JSON数组")
+	}
+
+	var characters []Character
+	if err := json.Unmarshal([]byte(RepairJSON(raw)), &characters); err != nil {
+		return nil, fmt.Errorf("解析角色JSON失败: %v", err)
+	}
+
+	c.Logger.Info("提取角色完成", zap.Int("character_count", len(characters)))
+	return characters, nil
+}
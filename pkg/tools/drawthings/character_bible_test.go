@@ -0,0 +1,98 @@
+package drawthings
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func testBible() *CharacterBible {
+	return &CharacterBible{
+		Novel: "测试小说",
+		Characters: []Character{
+			{Name: "林晚", Aliases: []string{"小晚"}, Descriptor: "二十岁女性，黑色齐肩短发，白色连衣裙", Seed: 42},
+			{Name: "陈默", Descriptor: "三十岁男性，寸头，黑色风衣", LoRA: "chenmo_v1"},
+		},
+	}
+}
+
+func TestApplyToPromptInjectsMentionedCharacters(t *testing.T) {
+	bible := testBible()
+
+	prompt, seed := bible.ApplyToPrompt("雨夜的街道，小晚撑伞站在路灯下")
+	if !strings.Contains(prompt, "林晚：二十岁女性，黑色齐肩短发，白色连衣裙") {
+		t.Errorf("应通过别名注入林晚的描述: %s", prompt)
+	}
+	if strings.Contains(prompt, "陈默") {
+		t.Errorf("未提及的角色不应注入: %s", prompt)
+	}
+	if seed != 42 {
+		t.Errorf("期望使用角色固定种子42, 实际 %d", seed)
+	}
+
+	// 提示词未提及角色时，从原文中匹配
+	prompt, seed = bible.ApplyToPrompt("昏暗的走廊", "陈默推开了门")
+	if !strings.Contains(prompt, "陈默：三十岁男性") || !strings.Contains(prompt, "<lora:chenmo_v1:0.80>") {
+		t.Errorf("应注入陈默的描述与LoRA: %s", prompt)
+	}
+	if seed != -1 {
+		t.Errorf("未设置种子时应为-1, 实际 %d", seed)
+	}
+
+	if prompt, _ := bible.ApplyToPrompt("空无一人的房间"); prompt != "空无一人的房间" {
+		t.Errorf("没有角色时提示词不应变化: %s", prompt)
+	}
+}
+
+func TestLoadOrBuildCharacterBible(t *testing.T) {
+	calls := 0
+	server := newFakeOllama(t, func(req ChatRequest) string {
+		calls++
+		if calls == 2 {
+			return `角色如下：[{"name": "林晚", "aliases": ["晚晚"], "descriptor": "另一种描述"}, {"name": "陈默", "descriptor": "三十岁男性，寸头"}]`
+		}
+		return `[{"name": "林晚", "aliases": ["小晚"], "descriptor": "二十岁女性，黑色齐肩短发"}]`
+	})
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), CharacterBibleFileName)
+	client := NewOllamaClient(zap.NewNop(), server.URL, "test")
+
+	bible, err := LoadOrBuildCharacterBible(path, "测试小说", client, []string{"第一章", "第二章"})
+	if err != nil {
+		t.Fatalf("生成角色设定集失败: %v", err)
+	}
+	if len(bible.Characters) != 2 {
+		t.Fatalf("期望2个角色, 实际 %d", len(bible.Characters))
+	}
+	lin := bible.Find("晚晚")
+	if lin == nil || lin.Descriptor != "二十岁女性，黑色齐肩短发" {
+		t.Errorf("合并角色时应保留首次描述并补充别名: %+v", lin)
+	}
+
+	// 人工修改后再次加载，不应重新提取或覆盖
+	bible.Characters[0].Descriptor = "手动修改的描述"
+	if err := bible.Save(path); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	loaded, err := LoadOrBuildCharacterBible(path, "测试小说", client, []string{"第一章"})
+	if err != nil {
+		t.Fatalf("加载角色设定集失败: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("已存在设定文件时不应调用Ollama, 调用次数 %d", calls)
+	}
+	if loaded.Characters[0].Descriptor != "手动修改的描述" {
+		t.Errorf("应保留人工修改: %s", loaded.Characters[0].Descriptor)
+	}
+}
+
+func TestEarlyChaptersSortedByNumber(t *testing.T) {
+	chapters := map[int]string{3: "三", 1: "一", 10: "十", 2: "二"}
+	got := EarlyChapters(chapters, 3)
+	if strings.Join(got, "") != "一二三" {
+		t.Errorf("应按章节号取前3章: %v", got)
+	}
+}
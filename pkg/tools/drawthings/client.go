@@ -2,14 +2,17 @@ package drawthings
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"novel-video-workflow/pkg/broadcast"
+	"novel-video-workflow/pkg/tools/imageproc"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -20,7 +23,7 @@ type DrawThingsClient struct {
 	BaseURL          string
 	Logger           *zap.Logger
 	HTTPClient       *http.Client
-	APIAvailable     bool // 记录API是否可用
+	APIAvailable     atomic.Bool // 记录API是否可用，初始为不可用；工作池的多个任务会并发读写
 	BroadcastService *broadcast.BroadcastService
 }
 
@@ -36,7 +39,6 @@ func NewDrawThingsClient(logger *zap.Logger, baseURL string) *DrawThingsClient {
 		HTTPClient: &http.Client{
 			Timeout: 300 * time.Second, // 图像生成可能需要较长时间
 		},
-		APIAvailable:     false, // 初始状态假设API不可用
 		BroadcastService: broadcast.NewBroadcastService(),
 	}
 
@@ -75,6 +77,7 @@ type Img2ImgRequest struct {
 	Width          int      `json:"width"`
 	Height         int      `json:"height"`
 	Steps          int      `json:"steps"`
+	Seed           int      `json:"seed,omitempty"` // 为0时由后端随机选择
 	SamplerName    string   `json:"sampler"`
 	GuidanceScale  float64  `json:"cfg_scale"`
 	BatchSize      int      `json:"batch_size"`
@@ -102,28 +105,43 @@ func (c *DrawThingsClient) CheckAPIAvailability() bool {
 	req, err := http.NewRequest("GET", testEndpoint, nil)
 	if err != nil {
 		c.Logger.Error("创建API可用性检查请求失败", zap.Error(err))
-		c.APIAvailable = false
+		c.APIAvailable.Store(false)
 		return false
 	}
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		c.Logger.Info("DrawThings API不可用", zap.String("url", testEndpoint), zap.Error(err))
-		c.APIAvailable = false
+		c.APIAvailable.Store(false)
 		return false
 	}
 	resp.Body.Close()
 
 	// 不检查响应状态，只要能连接就认为可用
 	c.Logger.Info("DrawThings API可用", zap.String("url", testEndpoint))
-	c.APIAvailable = true
+	c.APIAvailable.Store(true)
 	return true
 }
 
+// HTTPStatusError API返回的非200状态码错误
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("API返回错误状态码 %d: %s", e.StatusCode, e.Body)
+}
+
 // Txt2Img 生成图像
 func (c *DrawThingsClient) Txt2Img(params Txt2ImgRequest) (*Txt2ImgResponse, error) {
+	return c.Txt2ImgContext(context.Background(), params)
+}
+
+// Txt2ImgContext 生成图像，取消ctx会中断正在进行的请求
+func (c *DrawThingsClient) Txt2ImgContext(ctx context.Context, params Txt2ImgRequest) (*Txt2ImgResponse, error) {
 	// 先检查API是否可用
-	if !c.APIAvailable {
+	if !c.APIAvailable.Load() {
 		if !c.CheckAPIAvailability() {
 			return nil, fmt.Errorf("DrawThings API不可用，请确保Stable Diffusion WebUI正在运行在 %s", c.BaseURL)
 		}
@@ -138,7 +156,7 @@ func (c *DrawThingsClient) Txt2Img(params Txt2ImgRequest) (*Txt2ImgResponse, err
 		return nil, fmt.Errorf("序列化请求参数失败: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(payload))
 	if err != nil {
 		c.Logger.Error("创建请求失败", zap.Error(err))
 		return nil, fmt.Errorf("创建请求失败: %v", err)
@@ -158,8 +176,8 @@ func (c *DrawThingsClient) Txt2Img(params Txt2ImgRequest) (*Txt2ImgResponse, err
 	if err != nil {
 		c.Logger.Error("发送请求失败", zap.Error(err))
 		// 更新API可用性状态
-		c.APIAvailable = false
-		return nil, fmt.Errorf("发送请求失败: %v", err)
+		c.APIAvailable.Store(false)
+		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -168,7 +186,7 @@ func (c *DrawThingsClient) Txt2Img(params Txt2ImgRequest) (*Txt2ImgResponse, err
 		c.Logger.Error("API返回错误状态码",
 			zap.Int("status", resp.StatusCode),
 			zap.String("body", string(body)))
-		return nil, fmt.Errorf("API返回错误状态码 %d: %s", resp.StatusCode, string(body))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var result Txt2ImgResponse
@@ -185,8 +203,13 @@ func (c *DrawThingsClient) Txt2Img(params Txt2ImgRequest) (*Txt2ImgResponse, err
 
 // Img2Img 图生图
 func (c *DrawThingsClient) Img2Img(params Img2ImgRequest) (*Img2ImgResponse, error) {
+	return c.Img2ImgContext(context.Background(), params)
+}
+
+// Img2ImgContext 图生图，取消ctx会中断正在进行的请求
+func (c *DrawThingsClient) Img2ImgContext(ctx context.Context, params Img2ImgRequest) (*Img2ImgResponse, error) {
 	// 先检查API是否可用
-	if !c.APIAvailable {
+	if !c.APIAvailable.Load() {
 		if !c.CheckAPIAvailability() {
 			return nil, fmt.Errorf("DrawThings API不可用，请确保Stable Diffusion WebUI正在运行在 %s", c.BaseURL)
 		}
@@ -201,7 +224,7 @@ func (c *DrawThingsClient) Img2Img(params Img2ImgRequest) (*Img2ImgResponse, err
 		return nil, fmt.Errorf("序列化请求参数失败: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(payload))
 	if err != nil {
 		c.Logger.Error("创建请求失败", zap.Error(err))
 		return nil, fmt.Errorf("创建请求失败: %v", err)
@@ -218,8 +241,8 @@ func (c *DrawThingsClient) Img2Img(params Img2ImgRequest) (*Img2ImgResponse, err
 	if err != nil {
 		c.Logger.Error("发送请求失败", zap.Error(err))
 		// 更新API可用性状态
-		c.APIAvailable = false
-		return nil, fmt.Errorf("发送请求失败: %v", err)
+		c.APIAvailable.Store(false)
+		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -228,7 +251,7 @@ func (c *DrawThingsClient) Img2Img(params Img2ImgRequest) (*Img2ImgResponse, err
 		c.Logger.Error("API返回错误状态码",
 			zap.Int("status", resp.StatusCode),
 			zap.String("body", string(body)))
-		return nil, fmt.Errorf("API返回错误状态码 %d: %s", resp.StatusCode, string(body))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var result Img2ImgResponse
@@ -278,103 +301,112 @@ func (c *DrawThingsClient) SaveImageFromBase64(base64Data, filePath string) erro
 	return nil
 }
 
-// GenerateImageFromText 根据文本生成图像
-func (c *DrawThingsClient) GenerateImageFromText(text, outputFile string, width, height int, isSuspense bool) error {
+// GenerateImageFromText 根据文本生成图像，preset为nil时使用默认风格预设
+func (c *DrawThingsClient) GenerateImageFromText(text, outputFile string, width, height int, preset *StylePreset) error {
+	return c.GenerateImageFromTextWithSeed(text, outputFile, width, height, preset, -1)
+}
+
+// GenerateImageFromTextWithSeed 根据文本生成图像，seed为-1时使用随机种子，
+// 固定种子用于保持角色形象在不同分镜间一致
+func (c *DrawThingsClient) GenerateImageFromTextWithSeed(text, outputFile string, width, height int, preset *StylePreset, seed int) error {
+	_, err := c.GenerateImageWithRecord(text, outputFile, width, height, preset, seed)
+	return err
+}
+
+// GenerateImageWithRecord 根据文本生成图像，并返回包含实际种子、模型和全部参数的生成记录，
+// 调用方负责补充序号和原文信息后写入生成清单
+func (c *DrawThingsClient) GenerateImageWithRecord(text, outputFile string, width, height int, preset *StylePreset, seed int) (*ImageRecord, error) {
+	return c.GenerateImageWithRecordContext(context.Background(), text, outputFile, width, height, preset, seed)
+}
+
+// GenerateImageWithRecordContext 同 GenerateImageWithRecord，支持通过ctx取消
+func (c *DrawThingsClient) GenerateImageWithRecordContext(ctx context.Context, text, outputFile string, width, height int, preset *StylePreset, seed int) (*ImageRecord, error) {
 	// 先检查API是否可用
 	c.BroadcastService.SendMessage("ollama整合后的提示词", fmt.Sprintf("内容：%s", text), broadcast.GetTimeStr())
 
-	if !c.APIAvailable {
+	if !c.APIAvailable.Load() {
 		if !c.CheckAPIAvailability() {
-			return fmt.Errorf("无法连接到DrawThings API，请确保Stable Diffusion WebUI正在运行在 %s 并且可以通过该地址访问", c.BaseURL)
+			return nil, fmt.Errorf("无法连接到DrawThings API，请确保Stable Diffusion WebUI正在运行在 %s 并且可以通过该地址访问", c.BaseURL)
 		}
 	}
 
-	// 生成提示词，结合文本内容和悬疑风格
-	prompt := text
-	if isSuspense {
-		// 添加悬疑风格描述
-		suspenseStyle := ", 周围环境模糊成黑影, 空气凝滞,浅景深, 胶片颗粒感, 低饱和度，极致悬疑氛围, 阴沉窒息感, 夏季，环境阴霾，其他部分模糊不可见"
-		prompt = text + suspenseStyle
-	}
+	// 按风格预设组装提示词、反向提示词、模型与采样参数
+	return GenerateImageWithBackend(ctx, NewA1111Backend(c), text, outputFile, width, height, preset, seed)
+}
 
-	// 设置denoisingStrength值
-	strengthValue := 1.0
-
-	// 默认参数值
-	params := Txt2ImgRequest{
-		Prompt:            prompt,
-		NegativePrompt:    "人脸特写，半身像，模糊，比例失调，原参考图背景，比例失调，缺肢",
-		Width:             width,
-		Height:            height,
-		Steps:             8, // 快速生成
-		Seed:              -1,
-		SamplerName:       "DPM++ 2M Trailing", // 使用API支持的标准采样器
-		GuidanceScale:     1.0,                 // 使用较低的引导系数
-		BatchSize:         1,
-		EnableHr:          false, // 关闭高清修复
-		HrScale:           0,
-		HrUpscaler:        "",
-		HrSecondPassSteps: 0,
-		Tiling:            false,
-		Model:             "z_image_turbo_1.0_q6p.ckpt", // 使用z-image turbo模型
-		DenoisingStrength: &strengthValue,
+// presetOrDefault preset为nil时返回默认风格预设
+func presetOrDefault(preset *StylePreset) *StylePreset {
+	if preset != nil {
+		return preset
 	}
-
-	response, err := c.Txt2Img(params)
-	if err != nil {
-		return fmt.Errorf("生成图像失败: %v", err)
+	if p, err := ResolveStylePreset(""); err == nil {
+		return p
 	}
+	p := builtinStylePresets[0]
+	return &p
+}
 
-	if len(response.Images) == 0 {
-		return fmt.Errorf("API返回的图像数量为0")
+// GenerateFromParams 使用完整的文生图参数生成图像并保存。
+// 随机种子（负数）会先在本地确定具体值，保证生成记录中的种子可以复现该图像
+func (c *DrawThingsClient) GenerateFromParams(params Txt2ImgRequest, outputFile string) (*ImageRecord, error) {
+	return c.GenerateFromParamsContext(context.Background(), params, outputFile)
+}
+
+// GenerateFromParamsContext 同 GenerateFromParams，支持通过ctx取消
+func (c *DrawThingsClient) GenerateFromParamsContext(ctx context.Context, params Txt2ImgRequest, outputFile string) (*ImageRecord, error) {
+	return GenerateWithBackend(ctx, NewA1111Backend(c), params, outputFile)
+}
+
+// responseSeed 从文生图响应的info或parameters中读取实际使用的种子
+func responseSeed(response *Txt2ImgResponse) (int, bool) {
+	if response.Info != "" {
+		var info struct {
+			Seed *float64 `json:"seed"`
+		}
+		if err := json.Unmarshal([]byte(response.Info), &info); err == nil && info.Seed != nil && *info.Seed >= 0 {
+			return int(*info.Seed), true
+		}
+	}
+	if seed, ok := response.Parameters["seed"].(float64); ok && seed >= 0 {
+		return int(seed), true
 	}
+	return 0, false
+}
 
-	// 保存第一张图像
-	return c.SaveImageFromBase64(response.Images[0], outputFile)
+// GenerateImageFromImage 根据参考图像生成新图像，preset为nil时使用默认风格预设
+func (c *DrawThingsClient) GenerateImageFromImage(initImagePath, text, outputFile string, width, height int, preset *StylePreset) error {
+	return GenerateImageFromImageWithBackend(context.Background(), NewA1111Backend(c), initImagePath, text, outputFile, width, height, preset)
 }
 
-// GenerateImageFromImage 根据参考图像生成新图像
-func (c *DrawThingsClient) GenerateImageFromImage(initImagePath, text, outputFile string, width, height int, isSuspense bool) error {
+// img2ImgParams 读取参考图像并按风格预设组装图生图参数
+func img2ImgParams(initImagePath, text string, width, height int, preset *StylePreset) (Img2ImgRequest, error) {
 	// 读取参考图像并编码为Base64
 	initImageBytes, err := os.ReadFile(initImagePath)
 	if err != nil {
-		return fmt.Errorf("读取参考图像失败: %v", err)
+		return Img2ImgRequest{}, fmt.Errorf("读取参考图像失败: %v", err)
 	}
 
-	initImageBase64 := base64.StdEncoding.EncodeToString(initImageBytes)
+	// 按风格预设组装参数，加强文本描述的权重以突破参考图的构图
+	preset = presetOrDefault(preset)
+	width, height = preset.Size(width, height)
 
-	// 生成提示词
-	prompt := text
-	if isSuspense {
-		// 添加悬疑风格描述
-		suspenseStyle := ", 参考图面部特征，极致悬疑氛围, 阴沉窒息感, 夏季，环境阴霾"
-		prompt = "(" + text + ":1.5)" + suspenseStyle
+	// 参考图像比例与输出尺寸不一致时先裁剪或模糊填充到输出尺寸，避免后端拉伸变形；
+	// 无法解码的格式按原样发送
+	if fitted, _, err := imageproc.FitBytes(initImageBytes, imageproc.Options{Width: width, Height: height}); err == nil {
+		initImageBytes = fitted
 	}
-
-	// 默认参数值
-	params := Img2ImgRequest{
+	initImageBase64 := base64.StdEncoding.EncodeToString(initImageBytes)
+	return Img2ImgRequest{
 		InitImages:     []string{initImageBase64},
 		Strength:       0.7, // 关键：突破原图人脸构图限制
-		Prompt:         prompt,
-		NegativePrompt: "人脸特写，半身像，原参考图背景，比例失调，缺肢",
+		Prompt:         preset.ApplyPrompt("(" + text + ":1.5)"),
+		NegativePrompt: preset.NegativePrompt,
 		Width:          width,
 		Height:         height,
-		Steps:          8,
-		SamplerName:    "DPM++ 2M Trailing",
-		GuidanceScale:  1.0,
+		Steps:          preset.Steps,
+		SamplerName:    preset.Sampler,
+		GuidanceScale:  preset.CfgScale,
 		BatchSize:      1,
-		Model:          "z_image_turbo_1.0_q6p.ckpt", // 使用z-image turbo模型
-	}
-
-	response, err := c.Img2Img(params)
-	if err != nil {
-		return fmt.Errorf("图生图失败: %v", err)
-	}
-
-	if len(response.Images) == 0 {
-		return fmt.Errorf("API返回的图像数量为0")
-	}
-
-	// 保存第一张图像
-	return c.SaveImageFromBase64(response.Images[0], outputFile)
+		Model:          preset.Model,
+	}, nil
 }
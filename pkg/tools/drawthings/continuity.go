@@ -0,0 +1,199 @@
+package drawthings
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"novel-video-workflow/pkg/tools/imageproc"
+
+	"github.com/spf13/viper"
+)
+
+// 章节图像的生成方式
+const (
+	GenerationModeTxt2Img    = "txt2img"    // 每个分镜独立文生图
+	GenerationModeContinuity = "continuity" // 同一地点的连续分镜以上一张图像为参考图生成
+)
+
+// DefaultContinuityStrength 连续分镜图生图的默认重绘幅度
+const DefaultContinuityStrength = 0.55
+
+// GenerationModeFromConfig 读取 image.generation_mode 和 image.continuity.denoising_strength 配置
+func GenerationModeFromConfig() (string, float64) {
+	mode := strings.ToLower(strings.TrimSpace(viper.GetString("image.generation_mode")))
+	if mode != GenerationModeContinuity {
+		mode = GenerationModeTxt2Img
+	}
+	strength := DefaultContinuityStrength
+	if v := viper.GetFloat64("image.continuity.denoising_strength"); v > 0 && v <= 1 {
+		strength = v
+	}
+	return mode, strength
+}
+
+// ContinuityChains 将分镜按地点划分为连续链：相邻且地点相同的分镜归入同一条链，
+// 没有结构化分镜信息或地点为空的分镜单独成链。返回每条链中分镜在scenes中的下标
+func ContinuityChains(scenes []ScenePrompt) [][]int {
+	var chains [][]int
+	prevLocation := ""
+	for i, scene := range scenes {
+		location := ""
+		if scene.Storyboard != nil {
+			location = normalizeLocation(scene.Storyboard.Location)
+		}
+		if location != "" && location == prevLocation && len(chains) > 0 {
+			chains[len(chains)-1] = append(chains[len(chains)-1], i)
+		} else {
+			chains = append(chains, []int{i})
+		}
+		prevLocation = location
+	}
+	return chains
+}
+
+// normalizeLocation 忽略大小写、空白和标点比较地点名称
+func normalizeLocation(location string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(location) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// GenerateContinuationWithBackend 以参考图像为起点，按文生图参数通过图生图生成下一张图像。
+// 参考图像先适配到输出尺寸；随机种子在本地确定具体值，记录中的 denoising_strength 为重绘幅度
+func GenerateContinuationWithBackend(ctx context.Context, backend ImageBackend, initImagePath string, params Txt2ImgRequest, strength float64, outputFile string) (*ImageRecord, error) {
+	initImageBytes, err := os.ReadFile(initImagePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取参考图像失败: %v", err)
+	}
+	if fitted, _, err := imageproc.FitBytes(initImageBytes, imageproc.Options{Width: params.Width, Height: params.Height}); err == nil {
+		initImageBytes = fitted
+	}
+
+	if params.Seed < 0 {
+		params.Seed = int(rand.Int31())
+	}
+
+	image, err := backend.Img2Img(ctx, Img2ImgRequest{
+		InitImages:     []string{base64.StdEncoding.EncodeToString(initImageBytes)},
+		Strength:       strength,
+		Prompt:         params.Prompt,
+		NegativePrompt: params.NegativePrompt,
+		Width:          params.Width,
+		Height:         params.Height,
+		Steps:          params.Steps,
+		Seed:           params.Seed,
+		SamplerName:    params.SamplerName,
+		GuidanceScale:  params.GuidanceScale,
+		BatchSize:      1,
+		Model:          params.Model,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("图生图失败: %w", err)
+	}
+	if image.Seed >= 0 {
+		params.Seed = image.Seed
+	}
+	params.DenoisingStrength = &strength
+
+	if err := writeImageFile(outputFile, image.Data); err != nil {
+		return nil, err
+	}
+
+	record := NewImageRecord(0, outputFile, params)
+	record.Backend = backend.Name()
+	record.InitImage = filepath.Base(initImagePath)
+	return &record, nil
+}
+
+// generateContinuityScenes 按地点连续链分轮生成：第k轮生成每条链的第k个分镜，
+// 链首使用文生图，其余分镜以同一条链上一个分镜的图像为参考图；上一张生成失败时回到文生图。
+// 每一轮内不同的链仍通过工作池并发生成
+func (c *ChapterImageGenerator) generateContinuityScenes(ctx context.Context, pool *ImageWorkerPool, backend ImageBackend, chapter int, scenes []ScenePrompt, width, height int, preset *StylePreset) []ImageJobResult {
+	preset = presetOrDefault(preset)
+	strength := c.ContinuityStrength
+	if strength <= 0 || strength > 1 {
+		strength = DefaultContinuityStrength
+	}
+
+	chains := ContinuityChains(scenes)
+	generated := make(map[int]*ImageRecord, len(scenes))
+	var results []ImageJobResult
+	for round := 0; ; round++ {
+		var jobs []ImageJob
+		for _, chain := range chains {
+			if round >= len(chain) {
+				continue
+			}
+			scene := scenes[chain[round]]
+			var prev *ScenePrompt
+			var initImage string
+			if round > 0 {
+				if record := generated[scenes[chain[round-1]].Index]; record != nil {
+					prev = &scenes[chain[round-1]]
+					initImage = chainInitImage(filepath.Dir(prev.ImageFile), record)
+				}
+			}
+
+			jobs = append(jobs, ImageJob{
+				Chapter: chapter,
+				Index:   scene.Index,
+				Backend: backend.Name(),
+				Generate: func(ctx context.Context) (*ImageRecord, error) {
+					ctx = ContextWithSceneIndex(ctx, scene.Index)
+					if prev == nil {
+						return generateSceneWithBackend(ctx, backend, scene, width, height, preset)
+					}
+					record, err := GenerateContinuationWithBackend(ctx, backend, initImage, scene.txt2ImgParams(preset, width, height), strength, scene.ImageFile)
+					if err != nil {
+						return nil, err
+					}
+					record.Style = preset.Name
+					record.ChainFrom = prev.Index
+					return record, nil
+				},
+			})
+		}
+		if len(jobs) == 0 {
+			break
+		}
+
+		for _, result := range pool.Run(ctx, jobs) {
+			if result.Err == nil {
+				generated[result.Index] = result.Record
+			}
+			results = append(results, result)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+	return results
+}
+
+// continuationInitImage 返回重新生成连续分镜时使用的参考图像：
+// 与首次生成一样取上一分镜的原图，清单中没有上一分镜时为记录的参考图像文件
+func continuationInitImage(manifest *ImageManifest, imagesDir string, record *ImageRecord) string {
+	if prev := manifest.Find(record.ChainFrom); prev != nil {
+		return chainInitImage(imagesDir, prev)
+	}
+	return filepath.Join(imagesDir, record.InitImage)
+}
+
+// chainInitImage 返回连续分镜作为参考图的上一分镜图像：已适配过画布时为适配前的原图，否则为图像文件本身。
+// 首次生成和重新生成都经由此处，保证参考图来源一致
+func chainInitImage(imagesDir string, prev *ImageRecord) string {
+	if prev.RawImageFile != "" {
+		return filepath.Join(imagesDir, prev.RawImageFile)
+	}
+	return filepath.Join(imagesDir, prev.ImageFile)
+}
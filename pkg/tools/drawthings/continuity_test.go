@@ -0,0 +1,189 @@
+package drawthings
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"novel-video-workflow/pkg/tools/imageproc"
+
+	"go.uber.org/zap"
+)
+
+// recordingBackend 记录每个分镜使用文生图还是图生图，图像由离线后端绘制
+type recordingBackend struct {
+	OfflineBackend
+	mu      sync.Mutex
+	txt2img map[int]Txt2ImgRequest
+	img2img map[int]Img2ImgRequest
+}
+
+func (b *recordingBackend) Txt2Img(ctx context.Context, params Txt2ImgRequest) (*BackendImage, error) {
+	b.mu.Lock()
+	if b.txt2img != nil {
+		b.txt2img[SceneIndexFromContext(ctx)] = params
+	}
+	b.mu.Unlock()
+	return b.OfflineBackend.Txt2Img(ctx, params)
+}
+
+func (b *recordingBackend) Img2Img(ctx context.Context, params Img2ImgRequest) (*BackendImage, error) {
+	b.mu.Lock()
+	b.img2img[SceneIndexFromContext(ctx)] = params
+	b.mu.Unlock()
+	return b.OfflineBackend.Img2Img(ctx, params)
+}
+
+func continuityScenes(dir string, locations ...string) []ScenePrompt {
+	scenes := make([]ScenePrompt, len(locations))
+	for i, location := range locations {
+		scenes[i] = ScenePrompt{
+			Index:      i + 1,
+			ImageFile:  filepath.Join(dir, fmt.Sprintf("scene_%02d.png", i+1)),
+			Prompt:     "分镜 " + location,
+			Seed:       -1,
+			Storyboard: &StoryboardScene{Location: location},
+		}
+	}
+	return scenes
+}
+
+func TestContinuityChains(t *testing.T) {
+	scenes := continuityScenes("", "客栈大堂", "客栈 大堂", "后院", "", "", "客栈大堂")
+	want := [][]int{{0, 1}, {2}, {3}, {4}, {5}}
+	if chains := ContinuityChains(scenes); !reflect.DeepEqual(chains, want) {
+		t.Errorf("连续链划分错误: %v, 期望 %v", chains, want)
+	}
+}
+
+func TestGenerateScenesContinuityMode(t *testing.T) {
+	dir := t.TempDir()
+	backend := &recordingBackend{img2img: make(map[int]Img2ImgRequest)}
+	generator := &ChapterImageGenerator{
+		Logger:             zap.NewNop(),
+		Pool:               NewImageWorkerPool(zap.NewNop(), 2, RetryPolicy{MaxAttempts: 1}),
+		Backend:            backend,
+		Mode:               GenerationModeContinuity,
+		ContinuityStrength: 0.4,
+	}
+
+	preset, _ := NewStyleRegistry().Get("plain")
+	scenes := continuityScenes(dir, "客栈大堂", "客栈大堂", "客栈大堂", "后院")
+	results := generator.GenerateScenes(context.Background(), dir, 1, ManifestSourceOllamaScenes, scenes, 64, 112, preset)
+	if len(results) != 4 {
+		t.Fatalf("应返回4个结果, 实际 %d", len(results))
+	}
+	for i, result := range results {
+		if result.Err != nil || result.Index != i+1 {
+			t.Fatalf("第%d个结果错误: %+v", i+1, result)
+		}
+	}
+
+	// 同一地点的第2、3张使用图生图，新地点回到文生图
+	if len(backend.img2img) != 2 || backend.img2img[2].Strength != 0.4 || backend.img2img[3].Seed <= 0 {
+		t.Errorf("图生图调用错误: %v", len(backend.img2img))
+	}
+
+	manifest, err := LoadImageManifest(dir)
+	if err != nil {
+		t.Fatalf("加载清单失败: %v", err)
+	}
+	if manifest.GenerationMode != GenerationModeContinuity {
+		t.Errorf("清单应记录生成方式: %q", manifest.GenerationMode)
+	}
+	chain := []int{0, 1, 2, 0}
+	for i, record := range manifest.Images {
+		if record.ChainFrom != chain[i] {
+			t.Errorf("第%d张的参考分镜为 %d, 期望 %d", i+1, record.ChainFrom, chain[i])
+		}
+	}
+	if second := manifest.Images[1]; second.InitImage != "scene_01.png" || second.Parameters.DenoisingStrength == nil || *second.Parameters.DenoisingStrength != 0.4 {
+		t.Errorf("连续分镜记录错误: %+v", second)
+	}
+
+	// 单独重新生成连续分镜时仍使用图生图
+	delete(backend.img2img, 3)
+	if _, err := RegenerateImageWithBackend(context.Background(), backend, dir, 3, RegenerateOptions{}); err != nil {
+		t.Fatalf("重新生成失败: %v", err)
+	}
+	if _, ok := backend.img2img[3]; !ok {
+		t.Errorf("重新生成连续分镜应使用图生图")
+	}
+}
+
+func TestGenerateScenesStyledPrompt(t *testing.T) {
+	preset, _ := NewStyleRegistry().Get(DefaultStylePresetName)
+	for _, mode := range []string{GenerationModeTxt2Img, GenerationModeContinuity} {
+		dir := t.TempDir()
+		backend := &recordingBackend{txt2img: make(map[int]Txt2ImgRequest), img2img: make(map[int]Img2ImgRequest)}
+		generator := &ChapterImageGenerator{
+			Logger:  zap.NewNop(),
+			Pool:    NewImageWorkerPool(zap.NewNop(), 1, RetryPolicy{MaxAttempts: 1}),
+			Backend: backend,
+			Mode:    mode,
+		}
+
+		// 第1、2张的提示词由Ollama按风格描述生成，已包含风格；第3张为原始文本
+		scenes := continuityScenes(dir, "客栈大堂", "客栈大堂", "后院")
+		scenes[0].Prompt += preset.PromptSuffix
+		scenes[0].Styled = true
+		scenes[1].Prompt += preset.PromptSuffix
+		scenes[1].Styled = true
+		results := generator.GenerateScenes(context.Background(), dir, 1, ManifestSourceOllamaScenes, scenes, 0, 0, preset)
+		for i, result := range results {
+			if result.Err != nil {
+				t.Fatalf("%s: 第%d张生成失败: %v", mode, i+1, result.Err)
+			}
+		}
+
+		prompts := map[int]string{}
+		for index, params := range backend.txt2img {
+			prompts[index] = params.Prompt
+		}
+		for index, params := range backend.img2img {
+			prompts[index] = params.Prompt
+		}
+		for index := 1; index <= 3; index++ {
+			if count := strings.Count(prompts[index], preset.PromptSuffix); count != 1 {
+				t.Errorf("%s: 第%d张提示词中的风格后缀出现 %d 次, 期望 1 次: %q", mode, index, count, prompts[index])
+			}
+		}
+	}
+}
+
+func TestContinuityInitImageMatchesRegeneration(t *testing.T) {
+	dir := t.TempDir()
+	backend := &recordingBackend{img2img: make(map[int]Img2ImgRequest)}
+	generator := &ChapterImageGenerator{
+		Logger:  zap.NewNop(),
+		Pool:    NewImageWorkerPool(zap.NewNop(), 1, RetryPolicy{MaxAttempts: 1}),
+		Backend: backend,
+		Mode:    GenerationModeContinuity,
+	}
+
+	preset, _ := NewStyleRegistry().Get("plain")
+	scenes := continuityScenes(dir, "客栈大堂", "客栈大堂")
+	for _, result := range generator.GenerateScenes(context.Background(), dir, 1, ManifestSourceOllamaScenes, scenes, 64, 112, preset) {
+		if result.Err != nil {
+			t.Fatalf("第%d张生成失败: %v", result.Index, result.Err)
+		}
+	}
+	first := backend.img2img[2]
+
+	// 画布适配后图像文件已裁剪缩放，重新生成仍应以上一分镜的原图为参考图
+	if _, err := PostProcessChapterImages(dir, imageproc.Options{Width: 108, Height: 192}); err != nil {
+		t.Fatalf("画布适配失败: %v", err)
+	}
+	delete(backend.img2img, 2)
+	if _, err := RegenerateImageWithBackend(context.Background(), backend, dir, 2, RegenerateOptions{}); err != nil {
+		t.Fatalf("重新生成失败: %v", err)
+	}
+	regenerated := backend.img2img[2]
+	if len(first.InitImages) != 1 || !reflect.DeepEqual(first.InitImages, regenerated.InitImages) {
+		t.Errorf("首次生成与重新生成应使用相同的参考图")
+	}
+}
@@ -0,0 +1,143 @@
+package drawthings
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var thinkBlockPattern = regexp.MustCompile(`(?s)<think>.*?</think>`)
+
+// ExtractJSON 从模型响应中提取第一段JSON（对象或数组）。
+// 依次去掉推理模型的 <think> 块和 Markdown 代码围栏，再按括号配对截取，
+// 响应被截断时返回到末尾的部分，交给 RepairJSON 补全
+func ExtractJSON(text string) (string, error) {
+	text = StripThinking(text)
+
+	// 优先使用代码围栏中的内容
+	if start := strings.Index(text, "```"); start != -1 {
+		body := text[start+3:]
+		if nl := strings.Index(body, "\n"); nl != -1 {
+			body = body[nl+1:] // 跳过 ```json 语言标记
+		}
+		if end := strings.Index(body, "```"); end != -1 {
+			body = body[:end]
+		}
+		if strings.ContainsAny(body, "{[") {
+			text = body
+		}
+	}
+
+	start := strings.IndexAny(text, "{[")
+	if start == -1 {
+		return "", fmt.Errorf("响应中未找到JSON")
+	}
+
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return text[start : i+1], nil
+			}
+		}
+	}
+	return strings.TrimSpace(text[start:]), nil
+}
+
+// RepairJSON 修复模型常见的JSON格式错误：
+// 字符串外的中文逗号和冒号、多余的尾随逗号、字符串中未转义的换行，
+// 以及响应截断导致的未闭合字符串和括号
+func RepairJSON(s string) string {
+	var out strings.Builder
+	var stack []byte
+	inString, escaped := false, false
+
+	for _, r := range s {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == '"':
+				inString = false
+			case r == '\n':
+				out.WriteString(`\n`)
+				continue
+			case r == '\r' || r == '\t':
+				out.WriteRune(' ')
+				continue
+			}
+			out.WriteRune(r)
+			continue
+		}
+
+		switch r {
+		case '"':
+			inString = true
+		case '，':
+			r = ','
+		case '：':
+			r = ':'
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			trimTrailingComma(&out)
+			if len(stack) > 0 {
+				r = rune(stack[len(stack)-1])
+				stack = stack[:len(stack)-1]
+			}
+		}
+		out.WriteRune(r)
+	}
+
+	// 补全被截断的JSON
+	if inString {
+		if escaped {
+			out.WriteRune('\\')
+		}
+		out.WriteRune('"')
+	}
+	trimmed := strings.TrimRight(out.String(), " \t\r\n")
+	if strings.HasSuffix(trimmed, ":") {
+		trimmed += "null"
+	}
+	out.Reset()
+	out.WriteString(trimmed)
+	for i := len(stack) - 1; i >= 0; i-- {
+		trimTrailingComma(&out)
+		out.WriteByte(stack[i])
+	}
+	return out.String()
+}
+
+// trimTrailingComma 去掉已输出内容末尾（忽略空白）的逗号
+func trimTrailingComma(out *strings.Builder) {
+	current := out.String()
+	trimmed := strings.TrimRight(current, " \t\r\n")
+	if strings.HasSuffix(trimmed, ",") {
+		out.Reset()
+		out.WriteString(trimmed[:len(trimmed)-1])
+	}
+}
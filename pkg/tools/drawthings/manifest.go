@@ -0,0 +1,255 @@
+package drawthings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"novel-video-workflow/pkg/tools/imageproc"
+)
+
+// ImageManifestFileName 章节图像生成清单文件名，与图像保存在同一目录
+const ImageManifestFileName = "images_manifest.json"
+
+// 生成清单的来源
+const (
+	ManifestSourceParagraphs   = "paragraphs"    // 按段落生成
+	ManifestSourceOllamaScenes = "ollama_scenes" // Ollama分镜分析生成
+)
+
+// ImageRecord 单张图像的生成记录，保存复现该图像所需的全部参数
+type ImageRecord struct {
+	Index         int              `json:"index"`                    // 分镜序号，从1开始，与图像文件名一致
+	ImageFile     string           `json:"image_file"`               // 相对清单所在目录的图像文件名
+	SourceText    string           `json:"source_text,omitempty"`    // 对应的章节原文
+	SourceStart   int              `json:"source_start"`             // 原文在章节中的起始位置（按字符计），未知时为-1
+	SourceEnd     int              `json:"source_end"`               // 原文在章节中的结束位置（按字符计，不含），未知时为-1
+	SpanEstimated bool             `json:"span_estimated,omitempty"` // 原文范围为按分镜数量估算
+	Scene         *StoryboardScene `json:"scene,omitempty"`          // 结构化分镜信息（景别、角色、地点、氛围、时长权重）
+	Style         string           `json:"style,omitempty"`          // 使用的风格预设名称
+	Backend       string           `json:"backend,omitempty"`        // 生成该图像的后端
+	Parameters    Txt2ImgRequest   `json:"parameters"`               // 实际发送的文生图参数，seed为实际使用的种子
+	GeneratedAt   string           `json:"generated_at"`
+	Regenerations int              `json:"regenerations,omitempty"` // 单独重新生成的次数
+	InitImage     string           `json:"init_image,omitempty"`    // 图生图使用的参考图像，相对清单所在目录
+	ChainFrom     int              `json:"chain_from,omitempty"`    // 连续生成时作为参考图的上一分镜序号
+
+	RawImageFile string               `json:"raw_image_file,omitempty"` // 画布适配前的原始图像，相对清单所在目录
+	PostProcess  *imageproc.Transform `json:"postprocess,omitempty"`    // 画布适配实际应用的变换，未适配时为空
+}
+
+// ImageManifest 章节图像生成清单
+type ImageManifest struct {
+	Chapter        int           `json:"chapter,omitempty"`
+	Source         string        `json:"source,omitempty"`
+	GenerationMode string        `json:"generation_mode,omitempty"` // txt2img 或 continuity
+	UpdatedAt      string        `json:"updated_at"`
+	Images         []ImageRecord `json:"images"`
+}
+
+// ImageManifestPath 返回图像目录下的生成清单路径
+func ImageManifestPath(imagesDir string) string {
+	return filepath.Join(imagesDir, ImageManifestFileName)
+}
+
+// NewImageManifest 创建空的生成清单
+func NewImageManifest(chapter int, source string) *ImageManifest {
+	return &ImageManifest{Chapter: chapter, Source: source}
+}
+
+// LoadImageManifest 加载图像目录下的生成清单
+func LoadImageManifest(imagesDir string) (*ImageManifest, error) {
+	data, err := os.ReadFile(ImageManifestPath(imagesDir))
+	if err != nil {
+		return nil, fmt.Errorf("读取图像生成清单失败: %v", err)
+	}
+
+	var manifest ImageManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("解析图像生成清单失败: %v", err)
+	}
+	return &manifest, nil
+}
+
+// Save 将生成清单写入图像目录
+func (m *ImageManifest) Save(imagesDir string) error {
+	m.UpdatedAt = time.Now().Format(time.RFC3339)
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化图像生成清单失败: %v", err)
+	}
+	if err := os.WriteFile(ImageManifestPath(imagesDir), data, 0644); err != nil {
+		return fmt.Errorf("保存图像生成清单失败: %v", err)
+	}
+	return nil
+}
+
+// Put 添加或替换同序号的生成记录，并保持按序号排序
+func (m *ImageManifest) Put(record ImageRecord) {
+	if existing := m.Find(record.Index); existing != nil {
+		*existing = record
+		return
+	}
+	m.Images = append(m.Images, record)
+	sort.Slice(m.Images, func(i, j int) bool { return m.Images[i].Index < m.Images[j].Index })
+}
+
+// Find 根据分镜序号查找生成记录
+func (m *ImageManifest) Find(index int) *ImageRecord {
+	for i := range m.Images {
+		if m.Images[i].Index == index {
+			return &m.Images[i]
+		}
+	}
+	return nil
+}
+
+// NewImageRecord 根据实际生成参数创建生成记录
+func NewImageRecord(index int, imageFile string, params Txt2ImgRequest) ImageRecord {
+	return ImageRecord{
+		Index:       index,
+		ImageFile:   filepath.Base(imageFile),
+		SourceStart: -1,
+		SourceEnd:   -1,
+		Parameters:  params,
+		GeneratedAt: time.Now().Format(time.RFC3339),
+	}
+}
+
+// SpanLocator 按顺序在章节文本中定位各段原文的位置
+type SpanLocator struct {
+	text   string
+	cursor int // 字节偏移
+}
+
+// NewSpanLocator 创建原文定位器
+func NewSpanLocator(text string) *SpanLocator {
+	return &SpanLocator{text: text}
+}
+
+// Locate 从上一次匹配结束处开始查找片段，返回按字符计的起止位置，找不到时返回-1, -1
+func (l *SpanLocator) Locate(fragment string) (int, int) {
+	fragment = strings.TrimSpace(fragment)
+	if fragment == "" {
+		return -1, -1
+	}
+
+	idx := strings.Index(l.text[l.cursor:], fragment)
+	if idx == -1 {
+		return -1, -1
+	}
+
+	byteStart := l.cursor + idx
+	l.cursor = byteStart + len(fragment)
+
+	start := utf8.RuneCountInString(l.text[:byteStart])
+	return start, start + utf8.RuneCountInString(fragment)
+}
+
+// EstimateSceneSpan 按分镜数量等分章节文本，估算第index个分镜（从0开始）对应的原文范围
+func EstimateSceneSpan(text string, index, total int) (string, int, int) {
+	runes := []rune(text)
+	if total <= 0 || index < 0 || index >= total {
+		return "", -1, -1
+	}
+	start := len(runes) * index / total
+	end := len(runes) * (index + 1) / total
+	return string(runes[start:end]), start, end
+}
+
+// RegenerateOptions 单张图像重新生成选项
+type RegenerateOptions struct {
+	Seed           *int   // 为nil时沿用清单中记录的种子，-1表示使用新的随机种子
+	Prompt         string // 为空时沿用原提示词，不为空时按原风格预设加上前后缀
+	NegativePrompt string // 为空时沿用原反向提示词
+}
+
+// RegenerateImage 根据生成清单重新生成指定序号的单张图像，其他图像不受影响，
+// 完成后覆盖原图像文件并更新清单中的记录；原图像已适配过画布时新图像同样适配
+func (c *DrawThingsClient) RegenerateImage(imagesDir string, index int, opts RegenerateOptions) (*ImageRecord, error) {
+	return RegenerateImageWithBackend(context.Background(), NewA1111Backend(c), imagesDir, index, opts)
+}
+
+// RegenerateImageWithBackend 同 RegenerateImage，使用指定的图像后端生成
+func RegenerateImageWithBackend(ctx context.Context, backend ImageBackend, imagesDir string, index int, opts RegenerateOptions) (*ImageRecord, error) {
+	manifest, err := LoadImageManifest(imagesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := regenerateRecord(ctx, backend, manifest, imagesDir, index, opts)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Put(*record)
+	if err := manifest.Save(imagesDir); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// regenerateRecord 按清单记录重新生成单张图像并覆盖图像文件，返回更新后的记录副本，
+// 不修改也不保存清单，可在多个任务间并发读取同一份清单
+func regenerateRecord(ctx context.Context, backend ImageBackend, manifest *ImageManifest, imagesDir string, index int, opts RegenerateOptions) (*ImageRecord, error) {
+	found := manifest.Find(index)
+	if found == nil {
+		return nil, fmt.Errorf("生成清单中没有序号为%d的图像", index)
+	}
+	record := *found
+
+	params := record.Parameters
+	if opts.Seed != nil {
+		params.Seed = *opts.Seed
+	}
+	if opts.Prompt != "" {
+		// 新提示词沿用原风格预设的前后缀修饰
+		params.Prompt = opts.Prompt
+		if record.Style != "" {
+			if preset, err := ResolveStylePreset(record.Style); err == nil {
+				params.Prompt = preset.ApplyPrompt(opts.Prompt)
+			}
+		}
+	}
+	if opts.NegativePrompt != "" {
+		params.NegativePrompt = opts.NegativePrompt
+	}
+
+	// 连续生成的分镜仍以上一分镜的图像为参考图重新生成
+	var generated *ImageRecord
+	var err error
+	outputFile := filepath.Join(imagesDir, record.ImageFile)
+	if record.ChainFrom > 0 || record.InitImage != "" {
+		strength := DefaultContinuityStrength
+		if params.DenoisingStrength != nil {
+			strength = *params.DenoisingStrength
+		}
+		generated, err = GenerateContinuationWithBackend(ContextWithSceneIndex(ctx, index), backend, continuationInitImage(manifest, imagesDir, &record), params, strength, outputFile)
+	} else {
+		generated, err = GenerateWithBackend(ContextWithSceneIndex(ctx, index), backend, params, outputFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record.Parameters = generated.Parameters
+	record.Backend = generated.Backend
+	record.GeneratedAt = generated.GeneratedAt
+	record.Regenerations++
+
+	// 原图像已适配过画布时，按相同的变换参数适配新图像
+	if record.PostProcess != nil {
+		opts := postProcessOptionsFromTransform(record.PostProcess)
+		record.PostProcess = nil
+		if err := postProcessRecord(imagesDir, &record, opts); err != nil {
+			return nil, fmt.Errorf("适配重新生成的图像失败: %v", err)
+		}
+	}
+	return &record, nil
+}
@@ -0,0 +1,133 @@
+package drawthings
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// newFakeDrawThings 模拟文生图API，图像内容为 "<prompt>|<seed>"，并在info中返回实际种子
+func newFakeDrawThings(t *testing.T, requests *[]Txt2ImgRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sdapi/v1/txt2img" {
+			return
+		}
+		var req Txt2ImgRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("解析请求失败: %v", err)
+			return
+		}
+		*requests = append(*requests, req)
+
+		image := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s|%d", req.Prompt, req.Seed)))
+		info, _ := json.Marshal(map[string]interface{}{"seed": req.Seed})
+		json.NewEncoder(w).Encode(Txt2ImgResponse{Images: []string{image}, Info: string(info)})
+	}))
+}
+
+func TestGenerateImageWithRecordResolvesRandomSeed(t *testing.T) {
+	var requests []Txt2ImgRequest
+	server := newFakeDrawThings(t, &requests)
+	defer server.Close()
+
+	client := NewDrawThingsClient(zap.NewNop(), server.URL)
+	record, err := client.GenerateImageWithRecord("雨夜的街道", filepath.Join(t.TempDir(), "scene_01.png"), 512, 896, nil, -1)
+	if err != nil {
+		t.Fatalf("生成图像失败: %v", err)
+	}
+
+	if requests[0].Seed < 0 {
+		t.Errorf("随机种子应在发送前确定具体值, 实际 %d", requests[0].Seed)
+	}
+	if record.Parameters.Seed != requests[0].Seed || record.Parameters.Model == "" || record.Parameters.SamplerName == "" {
+		t.Errorf("生成记录应包含实际种子和全部参数: %+v", record.Parameters)
+	}
+	if record.ImageFile != "scene_01.png" {
+		t.Errorf("图像文件应记录为相对文件名: %s", record.ImageFile)
+	}
+}
+
+func TestRegenerateImageOnlyTouchesOneScene(t *testing.T) {
+	var requests []Txt2ImgRequest
+	server := newFakeDrawThings(t, &requests)
+	defer server.Close()
+
+	dir := t.TempDir()
+	client := NewDrawThingsClient(zap.NewNop(), server.URL)
+	plain, err := NewStyleRegistry().Get("plain")
+	if err != nil {
+		t.Fatalf("获取风格预设失败: %v", err)
+	}
+	text := "第一段。\n第二段。"
+	locator := NewSpanLocator(text)
+	manifest := NewImageManifest(1, ManifestSourceParagraphs)
+	for i, para := range []string{"第一段。", "第二段。"} {
+		record, err := client.GenerateImageWithRecord(para, filepath.Join(dir, fmt.Sprintf("scene_%02d.png", i+1)), 512, 896, plain, 100+i)
+		if err != nil {
+			t.Fatalf("生成图像失败: %v", err)
+		}
+		record.Index = i + 1
+		record.SourceText = para
+		record.SourceStart, record.SourceEnd = locator.Locate(para)
+		manifest.Put(*record)
+	}
+	if err := manifest.Save(dir); err != nil {
+		t.Fatalf("保存清单失败: %v", err)
+	}
+
+	if r := manifest.Find(2); r.SourceStart != 5 || r.SourceEnd != 9 {
+		t.Errorf("原文位置应按字符计算: %d-%d", r.SourceStart, r.SourceEnd)
+	}
+
+	// 沿用原种子，仅更换提示词
+	record, err := client.RegenerateImage(dir, 2, RegenerateOptions{Prompt: "新的提示词"})
+	if err != nil {
+		t.Fatalf("重新生成失败: %v", err)
+	}
+	if record.Parameters.Seed != 101 || record.Regenerations != 1 {
+		t.Errorf("应沿用原种子并记录重新生成次数: %+v", record)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "scene_02.png"))
+	if string(data) != "新的提示词|101" {
+		t.Errorf("第二张图像内容错误: %s", data)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "scene_01.png"))
+	if string(data) != "第一段。|100" {
+		t.Errorf("第一张图像不应被修改: %s", data)
+	}
+
+	// 指定新种子
+	seed := 7
+	if _, err := client.RegenerateImage(dir, 2, RegenerateOptions{Seed: &seed}); err != nil {
+		t.Fatalf("重新生成失败: %v", err)
+	}
+	loaded, err := LoadImageManifest(dir)
+	if err != nil {
+		t.Fatalf("加载清单失败: %v", err)
+	}
+	if r := loaded.Find(2); r.Parameters.Seed != 7 || r.Parameters.Prompt != "新的提示词" || r.Regenerations != 2 || r.SourceText != "第二段。" {
+		t.Errorf("清单记录未正确更新: %+v", r)
+	}
+	if r := loaded.Find(1); r.Parameters.Seed != 100 || r.Regenerations != 0 {
+		t.Errorf("其他记录不应变化: %+v", r)
+	}
+
+	if _, err := client.RegenerateImage(dir, 9, RegenerateOptions{}); err == nil {
+		t.Errorf("不存在的序号应返回错误")
+	}
+}
+
+func TestEstimateSceneSpan(t *testing.T) {
+	text, start, end := EstimateSceneSpan("一二三四五六", 1, 3)
+	if text != "三四" || start != 2 || end != 4 {
+		t.Errorf("估算范围错误: %s %d-%d", text, start, end)
+	}
+}
@@ -2,226 +2,294 @@ package drawthings
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"novel-video-workflow/pkg/broadcast"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
-// OllamaClient 封装 Ollama API 调用
+// Ollama 默认参数，配置文件未设置时使用
+const (
+	DefaultOllamaURL         = "http://localhost:11434"
+	DefaultOllamaModel       = "qwen3:4b"
+	DefaultOllamaTimeout     = 600 * time.Second
+	DefaultOllamaTemperature = 0.7
+)
+
+// OllamaClient 封装 Ollama API 调用，统一使用 /api/chat 接口
 type OllamaClient struct {
 	BaseURL          string
 	Model            string
 	Logger           *zap.Logger
 	HTTPClient       *http.Client
 	BroadcastService *broadcast.BroadcastService
+
+	// Temperature 默认采样温度，调用时传入的 options 可覆盖
+	Temperature float64
+	// NumCtx 上下文窗口大小，0为使用模型默认值
+	NumCtx int
+	// KeepAlive 请求结束后模型在内存中保留的时长，如 "5m"，为空时使用Ollama默认值
+	KeepAlive string
+	// Stream 是否流式接收响应，流式输出会逐段转发给 OnToken
+	Stream bool
+	// OnToken 流式输出的回调，为nil时转发到广播服务
+	OnToken func(token string)
+	// CheckModel 首次请求前通过 /api/tags 检查模型是否已下载
+	CheckModel bool
+
+	modelMu      sync.Mutex
+	modelChecked bool
+	modelErr     error
 }
 
-// NewOllamaClient 创建新的Ollama客户端实例
+// NewOllamaClient 创建新的Ollama客户端实例，超时、上下文大小、温度等参数读取 ollama 配置
 func NewOllamaClient(logger *zap.Logger, baseURL string, model string) *OllamaClient {
 	if baseURL == "" {
-		baseURL = "http://localhost:11434" // Ollama默认地址
+		baseURL = DefaultOllamaURL
 	}
 	if model == "" {
-		model = "qwen3:4b" // 默认模型
+		model = DefaultOllamaModel
+	}
+
+	timeout := DefaultOllamaTimeout
+	if seconds := viper.GetInt("ollama.timeout_seconds"); seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	temperature := DefaultOllamaTemperature
+	if viper.IsSet("ollama.temperature") {
+		temperature = viper.GetFloat64("ollama.temperature")
+	}
+	stream := true
+	if viper.IsSet("ollama.stream") {
+		stream = viper.GetBool("ollama.stream")
+	}
+	checkModel := true
+	if viper.IsSet("ollama.check_model") {
+		checkModel = viper.GetBool("ollama.check_model")
 	}
 
 	return &OllamaClient{
-		BaseURL: baseURL,
+		BaseURL: strings.TrimRight(baseURL, "/"),
 		Model:   model,
 		Logger:  logger,
 		HTTPClient: &http.Client{
-			Timeout: 300 * time.Minute, // 请求可能需要较长时间
+			Timeout: timeout, // 流式响应的总时长也受此限制
 		},
 		BroadcastService: broadcast.NewBroadcastService(),
+		Temperature:      temperature,
+		NumCtx:           viper.GetInt("ollama.num_ctx"),
+		KeepAlive:        viper.GetString("ollama.keep_alive"),
+		Stream:           stream,
+		CheckModel:       checkModel,
 	}
 }
 
-// OllamaRequest Ollama API请求结构
-type OllamaRequest struct {
-	Model   string                 `json:"model"`
-	Prompt  string                 `json:"prompt"`
-	System  string                 `json:"system,omitempty"`
-	Stream  bool                   `json:"stream"`
-	Options map[string]interface{} `json:"options,omitempty"`
+// NewOllamaClientFromConfig 按 ollama.api_url 和 ollama.model 配置创建客户端
+func NewOllamaClientFromConfig(logger *zap.Logger) *OllamaClient {
+	return NewOllamaClient(logger, viper.GetString("ollama.api_url"), viper.GetString("ollama.model"))
 }
 
-// OllamaResponse Ollama API响应结构
-type OllamaResponse struct {
-	Response       string  `json:"response"`
-	Model          string  `json:"model"`
-	CreatedAt      string  `json:"created_at"`
-	Done           bool    `json:"done"`
-	Context        []int   `json:"context,omitempty"`
-	TotalEval      int     `json:"total_eval_count,omitempty"`
-	TotalPrompt    int     `json:"total_prompt_count,omitempty"`
-	EvalCount      int     `json:"eval_count,omitempty"`
-	EvalDuration   float64 `json:"eval_duration,omitempty"`
-	PromptEval     int     `json:"prompt_eval_count,omitempty"`
-	PromptDuration float64 `json:"prompt_duration,omitempty"`
+// ChatMessage 对话消息，Role 为 system、user 或 assistant
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
 }
 
-func (c *OllamaClient) SendMsg(text string) {
-	c.BroadcastService.SendMessage("Ollama", text, broadcast.GetTimeStr())
+// SystemMessage 创建系统消息
+func SystemMessage(content string) ChatMessage {
+	return ChatMessage{Role: "system", Content: content}
 }
 
-// GenerateImagePrompt 生成图像提示词
-func (c *OllamaClient) GenerateImagePrompt(text, style string) (string, error) {
-	c.Logger.Info("开始使用Ollama生成图像提示词",
-		zap.String("text", text),
-		zap.String("style", style))
-
-	c.SendMsg(fmt.Sprintf("正在生成TTS语音，文本长度: %d", len(text)))
+// UserMessage 创建用户消息
+func UserMessage(content string) ChatMessage {
+	return ChatMessage{Role: "user", Content: content}
+}
 
-	systemPrompt := `你是一个专业的AI图像生成提示词工程师。你的任务是根据给定的文本内容生成详细、具体的中文图像提示词(prompt)，以指导AI图像生成模型创建高质量的图像。
+// AssistantMessage 创建模型回复消息，用于在多轮对话中回放历史
+func AssistantMessage(content string) ChatMessage {
+	return ChatMessage{Role: "assistant", Content: content}
+}
 
-注意事项：
-1. 提示词应该包含丰富的视觉细节，如人物外貌、环境、光线、颜色、构图等
-2. 根据文本内容判断场景类型（室内/室外、白天/夜晚、自然环境/城市等）
-3. 如果文本描述悬疑/恐怖情节，请强调相应的视觉元素，如昏暗光线、神秘氛围、紧张感等
-4. 使用专业摄影和艺术术语，如景深、色调、对比度等
-5. 保持提示词简洁但信息丰富，避免冗余描述
-6. 请务必使用中文输出所有提示词内容`
+// ChatRequest Ollama /api/chat 请求结构
+type ChatRequest struct {
+	Model     string                 `json:"model"`
+	Messages  []ChatMessage          `json:"messages"`
+	Stream    bool                   `json:"stream"`
+	Format    interface{}            `json:"format,omitempty"` // "json" 或 JSON Schema，约束输出格式
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+}
 
-	userPrompt := fmt.Sprintf(`根据以下文本内容生成一个详细的中文图像提示词，用于AI图像生成：
+// ChatResponse Ollama /api/chat 响应结构，流式响应的每一行都是一个 ChatResponse
+type ChatResponse struct {
+	Model           string      `json:"model"`
+	CreatedAt       string      `json:"created_at"`
+	Message         ChatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	DoneReason      string      `json:"done_reason,omitempty"`
+	Error           string      `json:"error,omitempty"`
+	TotalDuration   int64       `json:"total_duration,omitempty"`
+	PromptEvalCount int         `json:"prompt_eval_count,omitempty"`
+	EvalCount       int         `json:"eval_count,omitempty"`
+	EvalDuration    int64       `json:"eval_duration,omitempty"`
+}
 
-文本内容：%s
+// OllamaModel /api/tags 返回的本地模型信息
+type OllamaModel struct {
+	Name       string `json:"name"`
+	Model      string `json:"model"`
+	Size       int64  `json:"size"`
+	ModifiedAt string `json:"modified_at"`
+}
 
-图像风格：%s
+// OllamaTagsResponse /api/tags 响应结构
+type OllamaTagsResponse struct {
+	Models []OllamaModel `json:"models"`
+}
 
-请只返回中文图像提示词，不要添加任何解释或其他内容。`, text, style)
+func (c *OllamaClient) SendMsg(text string) {
+	c.BroadcastService.SendMessage("Ollama", text, broadcast.GetTimeStr())
+}
 
-	request := OllamaRequest{
-		Model:  c.Model,
-		Prompt: userPrompt,
-		System: systemPrompt,
-		Stream: false,
-		Options: map[string]interface{}{
-			"temperature":    0.7,
-			"top_p":          0.9,
-			"repeat_penalty": 1.1,
-		},
+// requestOptions 以客户端的默认参数为基础，合并调用时传入的 options
+func (c *OllamaClient) requestOptions(overrides map[string]interface{}) map[string]interface{} {
+	options := map[string]interface{}{
+		"temperature":    c.Temperature,
+		"top_p":          0.9,
+		"repeat_penalty": 1.1,
 	}
-
-	endpoint := c.BaseURL + "/api/generate"
-	payload, err := json.Marshal(request)
-	if err != nil {
-		c.Logger.Error("序列化Ollama请求失败", zap.Error(err))
-		return "", fmt.Errorf("序列化请求失败: %v", err)
+	if c.NumCtx > 0 {
+		options["num_ctx"] = c.NumCtx
 	}
+	for key, value := range overrides {
+		options[key] = value
+	}
+	return options
+}
 
-	c.Logger.Info("发送Ollama请求生成图像提示词",
-		zap.String("endpoint", endpoint),
-		zap.String("model", request.Model))
-
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(payload))
+// ListModels 通过 /api/tags 获取Ollama本地已下载的模型名称
+func (c *OllamaClient) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/api/tags", nil)
 	if err != nil {
-		c.Logger.Error("创建Ollama请求失败", zap.Error(err))
-		return "", fmt.Errorf("创建请求失败: %v", err)
+		return nil, fmt.Errorf("创建请求失败: %v", err)
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		c.Logger.Error("发送Ollama请求失败", zap.Error(err))
-		c.SendMsg(fmt.Sprintf("发送Ollama请求失败:%s", err.Error()))
-
-		return "", fmt.Errorf("发送请求失败: %v", err)
+		return nil, fmt.Errorf("获取Ollama模型列表失败: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		c.Logger.Error("Ollama API返回错误状态码",
-			zap.Int("status", resp.StatusCode),
-			zap.String("body", string(body)))
-		return "", fmt.Errorf("Ollama API返回错误状态码 %d: %s", resp.StatusCode, string(body))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
-	var ollamaResp OllamaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
-		c.Logger.Error("解析Ollama响应失败", zap.Error(err))
-		return "", fmt.Errorf("解析响应失败: %v", err)
+	var tags OllamaTagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("解析Ollama模型列表失败: %v", err)
 	}
-
-	if ollamaResp.Response == "" {
-		return "", fmt.Errorf("Ollama返回空响应")
+	names := make([]string, 0, len(tags.Models))
+	for _, model := range tags.Models {
+		name := model.Name
+		if name == "" {
+			name = model.Model
+		}
+		names = append(names, name)
 	}
-
-	// 清理响应内容
-	prompt := strings.TrimSpace(ollamaResp.Response)
-	c.Logger.Info("成功生成图像提示词", zap.String("prompt", prompt))
-	c.SendMsg(fmt.Sprintf("成功返回提示词:%s", prompt))
-
-	return prompt, nil
+	return names, nil
 }
 
-// AnalyzeScenesAndGeneratePrompts 分析整个章节内容并生成分镜提示词
-func (c *OllamaClient) AnalyzeScenesAndGeneratePrompts(content, style string, estimatedDurationSecs int) ([]string, error) {
-	systemPrompt := `你是一个专业的影视分镜师和AI图像生成提示词工程师。你的任务是：
-1. 分析输入的文本内容
-2. 识别出适合生成图像的关键场景/分镜
-3. 为每个分镜生成详细的中文图像提示词
-
-要求：
-1. 将长文本分解为3-8个关键视觉场景（根据内容长度调整）
-2. 每个场景应该是一个可以独立成图的视觉时刻
-3. 提示词需要包含丰富的视觉细节（人物、环境、光线、构图、色调等）
-4. 保持与整体风格的连贯性
-5. 使用专业摄影和艺术术语
-6. 返回格式为JSON数组，包含每个分镜的提示词`
-
-	estimatedDurationMsg := ""
-	if estimatedDurationSecs > 0 {
-		estimatedDurationMsg = fmt.Sprintf("文本内容估算的音频时长约为%d秒，请根据音频时长确定最少分镜数量（建议每30-60秒音频时长对应一个视觉场景作为最低标准），但可根据内容重要性和视觉表现力自主决定最终分镜数量上限。", estimatedDurationSecs)
-	} else {
-		estimatedDurationMsg = ""
+// EnsureModel 检查配置的模型是否已下载，未指定标签的模型名按 latest 匹配
+func (c *OllamaClient) EnsureModel(ctx context.Context) error {
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		return err
 	}
+	return c.findModel(models)
+}
 
-	userPrompt := fmt.Sprintf(`请分析以下文本内容并生成分镜图像提示词：
+// findModel 在已下载的模型中查找配置的模型，找不到时返回提示下载的错误
+func (c *OllamaClient) findModel(models []string) error {
+	for _, name := range models {
+		if modelNameMatches(name, c.Model) {
+			return nil
+		}
+	}
+	installed := "无"
+	if len(models) > 0 {
+		installed = strings.Join(models, ", ")
+	}
+	return fmt.Errorf("Ollama中没有模型 %s，请先执行 ollama pull %s（已下载: %s）", c.Model, c.Model, installed)
+}
 
-文本内容：%s
+func modelNameMatches(installed, model string) bool {
+	if installed == model {
+		return true
+	}
+	if !strings.Contains(model, ":") {
+		return installed == model+":latest"
+	}
+	return false
+}
 
-图像风格：%s
+// checkModelOnce 首次请求前检查模型，检查结果缓存在客户端中；
+// 无法获取模型列表时只记录警告，交给实际请求报错
+func (c *OllamaClient) checkModelOnce(ctx context.Context) error {
+	if !c.CheckModel {
+		return nil
+	}
+	c.modelMu.Lock()
+	defer c.modelMu.Unlock()
+	if c.modelChecked {
+		return c.modelErr
+	}
 
-%s
-请根据上述信息，分析内容并生成适量的关键视觉场景中文图像提示词（建议8-20个），以JSON数组格式返回，格式如：["场景1提示词", "场景2提示词", ...]
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		c.Logger.Warn("无法获取Ollama模型列表，跳过模型检查", zap.Error(err))
+		return nil
+	}
+	c.modelChecked = true
+	c.modelErr = c.findModel(models)
+	return c.modelErr
+}
 
-只返回JSON数组，不要添加其他解释。`, content, style, estimatedDurationMsg)
+// Chat 调用 /api/chat 进行对话，返回去掉 <think> 推理块和首尾空白的回复内容。
+// 流式模式下每段输出都会交给 onToken（为nil时使用 c.OnToken 或转发到广播服务），
+// 非200状态码返回 HTTPStatusError
+func (c *OllamaClient) Chat(ctx context.Context, messages []ChatMessage, format interface{}, options map[string]interface{}, onToken func(token string)) (string, error) {
+	if err := c.checkModelOnce(ctx); err != nil {
+		return "", err
+	}
 
-	request := OllamaRequest{
-		Model:  c.Model,
-		Prompt: userPrompt,
-		System: systemPrompt,
-		Stream: false,
-		Options: map[string]interface{}{
-			"temperature":    0.7,
-			"top_p":          0.9,
-			"repeat_penalty": 1.1,
-		},
+	request := ChatRequest{
+		Model:     c.Model,
+		Messages:  messages,
+		Stream:    c.Stream,
+		Format:    format,
+		Options:   c.requestOptions(options),
+		KeepAlive: c.KeepAlive,
 	}
 
-	endpoint := c.BaseURL + "/api/generate"
+	endpoint := c.BaseURL + "/api/chat"
 	payload, err := json.Marshal(request)
 	if err != nil {
 		c.Logger.Error("序列化Ollama请求失败", zap.Error(err))
-		return nil, fmt.Errorf("序列化请求失败: %v", err)
+		return "", fmt.Errorf("序列化请求失败: %v", err)
 	}
 
-	c.Logger.Info("发送Ollama请求分析场景并生成分镜提示词",
-		zap.String("endpoint", endpoint),
-		zap.String("model", request.Model))
-
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(payload))
 	if err != nil {
 		c.Logger.Error("创建Ollama请求失败", zap.Error(err))
-		return nil, fmt.Errorf("创建请求失败: %v", err)
+		return "", fmt.Errorf("创建请求失败: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -229,7 +297,7 @@ func (c *OllamaClient) AnalyzeScenesAndGeneratePrompts(content, style string, es
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		c.Logger.Error("发送Ollama请求失败", zap.Error(err))
-		return nil, fmt.Errorf("发送请求失败: %v", err)
+		return "", fmt.Errorf("发送请求失败: %v", err)
 	}
 	defer resp.Body.Close()
 
@@ -238,68 +306,149 @@ func (c *OllamaClient) AnalyzeScenesAndGeneratePrompts(content, style string, es
 		c.Logger.Error("Ollama API返回错误状态码",
 			zap.Int("status", resp.StatusCode),
 			zap.String("body", string(body)))
-		return nil, fmt.Errorf("Ollama API返回错误状态码 %d: %s", resp.StatusCode, string(body))
+		return "", &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
-	var ollamaResp OllamaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
-		c.Logger.Error("解析Ollama响应失败", zap.Error(err))
-		return nil, fmt.Errorf("解析响应失败: %v", err)
+	if onToken == nil {
+		onToken = c.OnToken
 	}
-
-	if ollamaResp.Response == "" {
-		return nil, fmt.Errorf("Ollama返回空响应")
+	var forwarder *tokenForwarder
+	if onToken == nil && c.BroadcastService != nil {
+		forwarder = &tokenForwarder{service: c.BroadcastService}
+		onToken = forwarder.Write
 	}
 
-	// 尝试解析JSON数组
-	responseText := strings.TrimSpace(ollamaResp.Response)
-
-	// 如果响应看起来像JSON数组，直接解析
-	if strings.HasPrefix(responseText, "[") && strings.HasSuffix(responseText, "]") {
-		var prompts []string
-		err := json.Unmarshal([]byte(responseText), &prompts)
-		if err == nil {
-			c.Logger.Info("成功解析分镜提示词JSON", zap.Int("scene_count", len(prompts)))
-			return prompts, nil
+	// 非流式响应只有一个JSON对象，流式响应为逐行的JSON对象，统一按JSON流解码
+	var content strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk ChatResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				break
+			}
+			c.Logger.Error("解析Ollama响应失败", zap.Error(err))
+			return "", fmt.Errorf("解析响应失败: %v", err)
+		}
+		if chunk.Error != "" {
+			return "", fmt.Errorf("Ollama返回错误: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if onToken != nil {
+				onToken(chunk.Message.Content)
+			}
+		}
+		if chunk.Done {
+			if chunk.DoneReason == "length" {
+				c.Logger.Warn("Ollama输出达到长度上限被截断", zap.String("model", c.Model))
+			}
+			break
 		}
 	}
+	if forwarder != nil {
+		forwarder.Flush()
+	}
 
-	// 如果不是有效的JSON数组，尝试查找JSON部分
-	jsonStart := strings.Index(responseText, "[")
-	jsonEnd := strings.LastIndex(responseText, "]")
-	if jsonStart != -1 && jsonEnd != -1 && jsonEnd > jsonStart {
-		jsonStr := responseText[jsonStart : jsonEnd+1]
-		var prompts []string
-		err := json.Unmarshal([]byte(jsonStr), &prompts)
-		if err == nil {
-			c.Logger.Info("成功解析分镜提示词JSON", zap.Int("scene_count", len(prompts)))
-			return prompts, nil
-		}
+	text := StripThinking(content.String())
+	if text == "" {
+		return "", fmt.Errorf("Ollama返回空响应")
 	}
+	return text, nil
+}
 
-	// 如果JSON解析失败，回退到按行分割的方式
-	lines := strings.Split(responseText, "\n")
-	var prompts []string
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed != "" && !strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "]") {
-			// 移除可能的编号前缀
-			for i := 1; i <= 50; i++ { // 支持最多50个条目，避免硬编码
-				trimmed = strings.TrimPrefix(trimmed, fmt.Sprintf("%d. ", i))
-			}
-			trimmed = strings.TrimSpace(trimmed)
+// tokenForwarder 把流式输出按行或按长度合并后非阻塞地转发到广播服务
+type tokenForwarder struct {
+	service *broadcast.BroadcastService
+	buffer  strings.Builder
+}
 
-			if trimmed != "" {
-				prompts = append(prompts, trimmed)
-			}
-		}
+// tokenForwardRunes 未遇到换行时累计多少字符转发一次
+const tokenForwardRunes = 60
+
+func (f *tokenForwarder) Write(token string) {
+	f.buffer.WriteString(token)
+	if strings.Contains(token, "\n") || len([]rune(f.buffer.String())) >= tokenForwardRunes {
+		f.Flush()
+	}
+}
+
+func (f *tokenForwarder) Flush() {
+	text := strings.TrimSpace(f.buffer.String())
+	f.buffer.Reset()
+	if text != "" {
+		f.service.TrySendMessage("Ollama", text, broadcast.GetTimeStr())
+	}
+}
+
+// StripThinking 去掉推理模型（如qwen3、deepseek-r1）输出的 <think> 推理块。
+// 未闭合的 <think> 视为推理被截断，丢弃其后的内容；
+// 只有 </think> 时（模板已预置 <think>），丢弃其前的内容
+func StripThinking(text string) string {
+	text = thinkBlockPattern.ReplaceAllString(text, "")
+	if idx := strings.LastIndex(text, "</think>"); idx != -1 {
+		text = text[idx+len("</think>"):]
+	}
+	if idx := strings.Index(text, "<think>"); idx != -1 {
+		text = text[:idx]
 	}
+	return strings.TrimSpace(text)
+}
+
+// GenerateImagePrompt 生成图像提示词
+func (c *OllamaClient) GenerateImagePrompt(text, style string) (string, error) {
+	c.Logger.Info("开始使用Ollama生成图像提示词",
+		zap.String("text", text),
+		zap.String("style", style))
+
+	c.SendMsg(fmt.Sprintf("正在生成图像提示词，文本长度: %d", len(text)))
+
+	systemPrompt := `你是一个专业的AI图像生成提示词工程师。你的任务是根据给定的文本内容生成详细、具体的中文图像提示词(prompt)，以指导AI图像生成模型创建高质量的图像。
+
+注意事项：
+1. 提示词应该包含丰富的视觉细节，如人物外貌、环境、光线、颜色、构图等
+2. 根据文本内容判断场景类型（室内/室外、白天/夜晚、自然环境/城市等）
+3. 如果文本描述悬疑/恐怖情节，请强调相应的视觉元素，如昏暗光线、神秘氛围、紧张感等
+4. 使用专业摄影和艺术术语，如景深、色调、对比度等
+5. 保持提示词简洁但信息丰富，避免冗余描述
+6. 请务必使用中文输出所有提示词内容`
+
+	userPrompt := fmt.Sprintf(`根据以下文本内容生成一个详细的中文图像提示词，用于AI图像生成：
 
-	if len(prompts) == 0 {
-		// 如果所有方法都失败，将整个响应作为一个提示词
-		prompts = []string{responseText}
+文本内容：%s
+
+图像风格：%s
+
+请只返回中文图像提示词，不要添加任何解释或其他内容。`, text, style)
+
+	c.Logger.Info("发送Ollama请求生成图像提示词",
+		zap.String("endpoint", c.BaseURL+"/api/chat"),
+		zap.String("model", c.Model))
+
+	prompt, err := c.Generate(systemPrompt, userPrompt, nil)
+	if err != nil {
+		c.SendMsg(fmt.Sprintf("发送Ollama请求失败:%s", err.Error()))
+		return "", err
 	}
 
-	c.Logger.Info("生成分镜提示词（非JSON格式）", zap.Int("scene_count", len(prompts)))
-	return prompts, nil
+	c.Logger.Info("成功生成图像提示词", zap.String("prompt", prompt))
+	c.SendMsg(fmt.Sprintf("成功返回提示词:%s", prompt))
+
+	return prompt, nil
+}
+
+// Generate 使用给定的系统提示词和用户提示词调用Ollama，返回去除推理块和首尾空白的响应文本
+func (c *OllamaClient) Generate(systemPrompt, userPrompt string, options map[string]interface{}) (string, error) {
+	return c.GenerateWithFormat(systemPrompt, userPrompt, nil, options)
+}
+
+// GenerateWithFormat 同 Generate，format 为 "json" 或 JSON Schema 时约束模型输出格式，
+// 非200状态码返回 HTTPStatusError
+func (c *OllamaClient) GenerateWithFormat(systemPrompt, userPrompt string, format interface{}, options map[string]interface{}) (string, error) {
+	var messages []ChatMessage
+	if systemPrompt != "" {
+		messages = append(messages, SystemMessage(systemPrompt))
+	}
+	messages = append(messages, UserMessage(userPrompt))
+	return c.Chat(context.Background(), messages, format, options, nil)
 }
@@ -0,0 +1,108 @@
+package drawthings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// newFakeOllama 模拟Ollama服务：/api/tags 返回 test 模型，/api/chat 把 reply 的结果按字符流式返回
+func newFakeOllama(t *testing.T, reply func(req ChatRequest) string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/tags" {
+			json.NewEncoder(w).Encode(OllamaTagsResponse{Models: []OllamaModel{{Name: "test:latest"}}})
+			return
+		}
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("解析请求失败: %v", err)
+			return
+		}
+		content := reply(req)
+		encoder := json.NewEncoder(w)
+		if !req.Stream {
+			encoder.Encode(ChatResponse{Message: AssistantMessage(content), Done: true})
+			return
+		}
+		for _, r := range content {
+			encoder.Encode(ChatResponse{Message: AssistantMessage(string(r))})
+		}
+		encoder.Encode(ChatResponse{Done: true, DoneReason: "stop"})
+	}))
+}
+
+func TestChatStreamsTokensAndStripsThinking(t *testing.T) {
+	var request ChatRequest
+	server := newFakeOllama(t, func(req ChatRequest) string {
+		request = req
+		return "<think>先想一想</think>\n阴暗的走廊"
+	})
+	defer server.Close()
+
+	client := NewOllamaClient(zap.NewNop(), server.URL, "test")
+	client.NumCtx = 8192
+	client.KeepAlive = "10m"
+
+	var tokens []string
+	response, err := client.Chat(context.Background(),
+		[]ChatMessage{SystemMessage("系统"), UserMessage("问题")},
+		nil, map[string]interface{}{"temperature": 0.2},
+		func(token string) { tokens = append(tokens, token) })
+	if err != nil {
+		t.Fatalf("对话失败: %v", err)
+	}
+	if response != "阴暗的走廊" {
+		t.Errorf("应去掉推理块: %q", response)
+	}
+	if strings.Join(tokens, "") != "<think>先想一想</think>\n阴暗的走廊" || len(tokens) < 2 {
+		t.Errorf("应逐段回调流式输出: %v", tokens)
+	}
+
+	if !request.Stream || request.KeepAlive != "10m" || len(request.Messages) != 2 || request.Messages[0].Role != "system" {
+		t.Errorf("请求结构错误: %+v", request)
+	}
+	if request.Options["temperature"] != 0.2 || request.Options["num_ctx"] != float64(8192) {
+		t.Errorf("选项合并错误: %v", request.Options)
+	}
+}
+
+func TestChatChecksModelPresence(t *testing.T) {
+	server := newFakeOllama(t, func(req ChatRequest) string { return "好" })
+	defer server.Close()
+
+	client := NewOllamaClient(zap.NewNop(), server.URL, "test")
+	if err := client.EnsureModel(context.Background()); err != nil {
+		t.Errorf("test 应匹配 test:latest: %v", err)
+	}
+
+	missing := NewOllamaClient(zap.NewNop(), server.URL, "llama3:8b")
+	if _, err := missing.Generate("", "你好", nil); err == nil || !strings.Contains(err.Error(), "ollama pull llama3:8b") {
+		t.Errorf("模型不存在时应提示下载: %v", err)
+	}
+}
+
+func TestStripThinking(t *testing.T) {
+	cases := map[string]string{
+		"<think>a</think>结果":    "结果",
+		"推理过程</think>\n结果":      "结果",
+		"结果<think>被截断的推理":       "结果",
+		"  没有推理块  ":             "没有推理块",
+		"<think>\n</think>\n\n": "",
+	}
+	for input, want := range cases {
+		if got := StripThinking(input); got != want {
+			t.Errorf("StripThinking(%q) = %q, 期望 %q", input, got, want)
+		}
+	}
+}
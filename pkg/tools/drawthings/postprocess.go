@@ -0,0 +1,107 @@
+package drawthings
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"novel-video-workflow/pkg/tools/imageproc"
+
+	"github.com/spf13/viper"
+)
+
+// RawImagesDirName 画布适配前的原始图像目录，位于章节图像目录下，
+// 重新适配时总是从原始图像开始，避免多次裁剪、缩放累积损失
+const RawImagesDirName = "raw"
+
+// PostProcessOptionsFromConfig 读取 image.postprocess 配置，返回画布适配参数和是否启用。
+// 画布尺寸默认与 video.resolution 一致
+func PostProcessOptionsFromConfig() (imageproc.Options, bool) {
+	opts := imageproc.Options{
+		Width:         viper.GetInt("image.postprocess.width"),
+		Height:        viper.GetInt("image.postprocess.height"),
+		Mode:          viper.GetString("image.postprocess.mode"),
+		Anchor:        viper.GetString("image.postprocess.anchor"),
+		MaxCropLoss:   viper.GetFloat64("image.postprocess.max_crop_loss"),
+		BlurRadius:    viper.GetInt("image.postprocess.blur_radius"),
+		BackgroundDim: viper.GetFloat64("image.postprocess.background_dim"),
+	}
+	if opts.Width <= 0 || opts.Height <= 0 {
+		opts.Width = viper.GetInt("video.resolution.width")
+		opts.Height = viper.GetInt("video.resolution.height")
+	}
+	if opts.Width <= 0 || opts.Height <= 0 {
+		opts.Width, opts.Height = 1080, 1920
+	}
+	return opts, viper.GetBool("image.postprocess.enabled")
+}
+
+// PostProcessChapterImages 将章节图像目录中清单记录的图像适配到画布尺寸，
+// 原始图像移至 raw/ 子目录，适配结果覆盖原文件名（剪映草稿等后续步骤无需改动），
+// 实际应用的变换写入清单记录的 postprocess 字段
+func PostProcessChapterImages(imagesDir string, opts imageproc.Options) ([]ImageRecord, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	manifest, err := LoadImageManifest(imagesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range manifest.Images {
+		if err := postProcessRecord(imagesDir, &manifest.Images[i], opts); err != nil {
+			return nil, fmt.Errorf("适配第%d张图像失败: %v", manifest.Images[i].Index, err)
+		}
+	}
+
+	if err := manifest.Save(imagesDir); err != nil {
+		return nil, err
+	}
+	return manifest.Images, nil
+}
+
+// postProcessRecord 适配单张图像并更新记录。
+// 尚未适配过的图像（新生成或重新生成后清除了变换记录）先备份到 raw/，已适配过的从 raw/ 重新适配
+func postProcessRecord(imagesDir string, record *ImageRecord, opts imageproc.Options) error {
+	imagePath := filepath.Join(imagesDir, record.ImageFile)
+	rawFile := filepath.Join(RawImagesDirName, record.ImageFile)
+	rawPath := filepath.Join(imagesDir, rawFile)
+
+	_, statErr := os.Stat(rawPath)
+	if record.PostProcess == nil || statErr != nil {
+		data, err := os.ReadFile(imagePath)
+		if err != nil {
+			return fmt.Errorf("读取图像文件失败: %v", err)
+		}
+		if err := writeImageFile(rawPath, data); err != nil {
+			return err
+		}
+	}
+
+	transform, err := imageproc.FitFile(rawPath, imagePath, opts)
+	if err != nil {
+		return err
+	}
+	record.RawImageFile = rawFile
+	record.PostProcess = &transform
+	return nil
+}
+
+// postProcessOptionsFromTransform 按清单中记录的变换还原画布适配参数，用于单张重新生成后保持一致
+func postProcessOptionsFromTransform(transform *imageproc.Transform) imageproc.Options {
+	opts, _ := PostProcessOptionsFromConfig()
+	opts.Width = transform.TargetWidth
+	opts.Height = transform.TargetHeight
+	switch transform.Mode {
+	case imageproc.ModeCrop, imageproc.ModeLetterbox:
+		opts.Mode = transform.Mode
+	}
+	if transform.Anchor != "" {
+		opts.Anchor = transform.Anchor
+	}
+	if transform.BlurRadius > 0 {
+		opts.BlurRadius = transform.BlurRadius
+	}
+	return opts
+}
@@ -0,0 +1,73 @@
+package drawthings
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"novel-video-workflow/pkg/tools/imageproc"
+)
+
+func TestPostProcessChapterImages(t *testing.T) {
+	dir := t.TempDir()
+	backend := NewOfflineBackend()
+
+	manifest := NewImageManifest(1, ManifestSourceParagraphs)
+	for i := 1; i <= 2; i++ {
+		record, err := GenerateWithBackend(ContextWithSceneIndex(context.Background(), i), backend, Txt2ImgRequest{Prompt: "雨夜", Width: 64, Height: 112, Seed: i}, filepath.Join(dir, fmt.Sprintf("scene_%02d.png", i)))
+		if err != nil {
+			t.Fatalf("生成图像失败: %v", err)
+		}
+		record.Index = i
+		manifest.Put(*record)
+	}
+	if err := manifest.Save(dir); err != nil {
+		t.Fatalf("保存清单失败: %v", err)
+	}
+
+	opts := imageproc.Options{Width: 108, Height: 192}
+	records, err := PostProcessChapterImages(dir, opts)
+	if err != nil {
+		t.Fatalf("画布适配失败: %v", err)
+	}
+	if len(records) != 2 || records[0].PostProcess == nil || records[0].PostProcess.Mode != imageproc.ModeCrop {
+		t.Fatalf("清单应记录适配变换: %+v", records)
+	}
+
+	assertSize := func(path string, width, height int) {
+		t.Helper()
+		img, err := imageproc.LoadImage(path)
+		if err != nil {
+			t.Fatalf("读取图像失败: %v", err)
+		}
+		if img.Bounds().Dx() != width || img.Bounds().Dy() != height {
+			t.Errorf("%s 尺寸应为 %dx%d, 实际 %v", filepath.Base(path), width, height, img.Bounds())
+		}
+	}
+	assertSize(filepath.Join(dir, records[0].ImageFile), 108, 192)
+	assertSize(filepath.Join(dir, records[0].RawImageFile), 64, 112)
+
+	// 再次适配从原图开始，结果不变
+	if _, err := PostProcessChapterImages(dir, opts); err != nil {
+		t.Fatalf("重复适配失败: %v", err)
+	}
+	assertSize(filepath.Join(dir, records[0].RawImageFile), 64, 112)
+
+	// 重新生成的图像按原变换重新适配，原图备份同步更新
+	seed := 99
+	record, err := RegenerateImageWithBackend(context.Background(), backend, dir, 2, RegenerateOptions{Seed: &seed})
+	if err != nil {
+		t.Fatalf("重新生成失败: %v", err)
+	}
+	if record.PostProcess == nil || record.PostProcess.TargetWidth != 108 {
+		t.Errorf("重新生成后应保留适配记录: %+v", record.PostProcess)
+	}
+	assertSize(filepath.Join(dir, record.ImageFile), 108, 192)
+	assertSize(filepath.Join(dir, record.RawImageFile), 64, 112)
+
+	if _, err := os.Stat(filepath.Join(dir, RawImagesDirName)); err != nil {
+		t.Errorf("原图目录不存在: %v", err)
+	}
+}
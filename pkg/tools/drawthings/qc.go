@@ -0,0 +1,341 @@
+package drawthings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"novel-video-workflow/pkg/tools/imageproc"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// QCReportFileName 章节图像质检报告文件名，与图像保存在同一目录
+const QCReportFileName = "qc_report.json"
+
+// 质检问题类型
+const (
+	QCIssueUnreadable  = "unreadable"   // 图像文件无法读取或解码
+	QCIssueBlank       = "blank"        // 接近纯黑或纯白
+	QCIssueLowVariance = "low_variance" // 画面几乎没有变化
+	QCIssueDuplicate   = "duplicate"    // 与本章前面的图像几乎相同
+	QCIssueAspectRatio = "aspect_ratio" // 宽高比与请求的尺寸不一致
+)
+
+// QCOptions 图像质检参数
+type QCOptions struct {
+	MinStdDev         float64 // 亮度标准差低于该值视为画面单一
+	BlankMeanLow      float64 // 亮度均值低于该值且画面单一视为纯黑
+	BlankMeanHigh     float64 // 亮度均值高于该值且画面单一视为纯白
+	BlankStdDev       float64 // 判断纯黑/纯白时的标准差上限
+	DuplicateDistance int     // 感知哈希汉明距离不超过该值
+	DuplicateColor    float64 // 且缩略图平均颜色差不超过该值时视为重复
+	AspectTolerance   float64 // 宽高比允许的相对误差
+	MaxAttempts       int     // 未通过的图像最多重新生成的次数
+}
+
+// DefaultQCOptions 默认质检参数
+func DefaultQCOptions() QCOptions {
+	return QCOptions{
+		MinStdDev:         0.02,
+		BlankMeanLow:      0.04,
+		BlankMeanHigh:     0.96,
+		BlankStdDev:       0.06,
+		DuplicateDistance: 4,
+		DuplicateColor:    0.04,
+		AspectTolerance:   0.02,
+		MaxAttempts:       2,
+	}
+}
+
+// QCOptionsFromConfig 读取 image.qc 配置，返回质检参数和是否启用
+func QCOptionsFromConfig() (QCOptions, bool) {
+	opts := DefaultQCOptions()
+	if viper.IsSet("image.qc.min_std_dev") {
+		opts.MinStdDev = viper.GetFloat64("image.qc.min_std_dev")
+	}
+	if viper.IsSet("image.qc.duplicate_distance") {
+		opts.DuplicateDistance = viper.GetInt("image.qc.duplicate_distance")
+	}
+	if viper.IsSet("image.qc.duplicate_color") {
+		opts.DuplicateColor = viper.GetFloat64("image.qc.duplicate_color")
+	}
+	if viper.IsSet("image.qc.aspect_tolerance") {
+		opts.AspectTolerance = viper.GetFloat64("image.qc.aspect_tolerance")
+	}
+	if viper.IsSet("image.qc.max_attempts") {
+		opts.MaxAttempts = viper.GetInt("image.qc.max_attempts")
+	}
+	return opts, viper.GetBool("image.qc.enabled")
+}
+
+// ImageQCResult 单张图像的质检结果
+type ImageQCResult struct {
+	Index        int                      `json:"index"`
+	ImageFile    string                   `json:"image_file"`
+	Passed       bool                     `json:"passed"`
+	Issues       []string                 `json:"issues,omitempty"`
+	Luminance    imageproc.LuminanceStats `json:"luminance"`
+	Hash         string                   `json:"hash,omitempty"`
+	DuplicateOf  int                      `json:"duplicate_of,omitempty"` // 重复时与之相同的图像序号
+	Width        int                      `json:"width"`
+	Height       int                      `json:"height"`
+	Regenerated  int                      `json:"regenerated,omitempty"`   // 因质检未通过重新生成的次数
+	RetriedSeeds []int                    `json:"retried_seeds,omitempty"` // 重新生成时使用的种子
+	Error        string                   `json:"error,omitempty"`
+}
+
+// QCReport 章节图像质检报告
+type QCReport struct {
+	Chapter   int             `json:"chapter,omitempty"`
+	CheckedAt string          `json:"checked_at"`
+	Passed    int             `json:"passed"`
+	Failed    int             `json:"failed"`
+	Images    []ImageQCResult `json:"images"`
+}
+
+// Failures 返回未通过质检的结果
+func (r *QCReport) Failures() []ImageQCResult {
+	var failed []ImageQCResult
+	for _, result := range r.Images {
+		if !result.Passed {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Save 将质检报告写入图像目录
+func (r *QCReport) Save(imagesDir string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化质检报告失败: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(imagesDir, QCReportFileName), data, 0644); err != nil {
+		return fmt.Errorf("保存质检报告失败: %v", err)
+	}
+	return nil
+}
+
+// LoadQCReport 加载图像目录下的质检报告
+func LoadQCReport(imagesDir string) (*QCReport, error) {
+	data, err := os.ReadFile(filepath.Join(imagesDir, QCReportFileName))
+	if err != nil {
+		return nil, fmt.Errorf("读取质检报告失败: %v", err)
+	}
+	var report QCReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("解析质检报告失败: %v", err)
+	}
+	return &report, nil
+}
+
+// CheckChapterImages 按生成清单检查章节图像：纯黑/纯白、画面单一、本章内重复和宽高比错误
+func CheckChapterImages(imagesDir string, opts QCOptions) (*QCReport, error) {
+	manifest, err := LoadImageManifest(imagesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &QCReport{Chapter: manifest.Chapter, CheckedAt: time.Now().Format(time.RFC3339)}
+	var accepted []imageSignature
+	for _, record := range manifest.Images {
+		result, signature, decoded := checkImage(imagesDir, record, opts)
+
+		// 与本章前面的图像比较感知哈希和缩略图颜色，平滑的画面哈希相近但颜色不同时不算重复
+		if decoded {
+			for _, prev := range accepted {
+				if imageproc.HashDistance(signature.hash, prev.hash) <= opts.DuplicateDistance &&
+					imageproc.ThumbnailDistance(signature.thumb, prev.thumb) <= opts.DuplicateColor {
+					result.Issues = append(result.Issues, QCIssueDuplicate)
+					result.DuplicateOf = prev.index
+					break
+				}
+			}
+			// 画面单一的图像哈希不稳定，不作为其他图像的比较对象
+			if len(result.Issues) == 0 {
+				accepted = append(accepted, signature)
+			}
+		}
+
+		result.Passed = len(result.Issues) == 0
+		if result.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Images = append(report.Images, result)
+	}
+	return report, nil
+}
+
+// imageSignature 用于重复检测的图像特征
+type imageSignature struct {
+	index int
+	hash  uint64
+	thumb []uint8
+}
+
+// checkImage 检查单张图像本身的问题，同时返回重复检测特征和是否成功解码
+func checkImage(imagesDir string, record ImageRecord, opts QCOptions) (ImageQCResult, imageSignature, bool) {
+	result := ImageQCResult{Index: record.Index, ImageFile: record.ImageFile}
+
+	img, err := imageproc.LoadImage(filepath.Join(imagesDir, record.ImageFile))
+	if err != nil {
+		result.Issues = []string{QCIssueUnreadable}
+		result.Error = err.Error()
+		return result, imageSignature{}, false
+	}
+
+	hash := imageproc.DifferenceHash(img)
+	signature := imageSignature{index: record.Index, hash: hash, thumb: imageproc.Thumbnail(img)}
+	result.Width, result.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	result.Luminance = imageproc.MeasureLuminance(img)
+	result.Hash = imageproc.FormatHash(hash)
+
+	stats := result.Luminance
+	switch {
+	case stats.StdDev <= opts.BlankStdDev && (stats.Mean <= opts.BlankMeanLow || stats.Mean >= opts.BlankMeanHigh):
+		result.Issues = append(result.Issues, QCIssueBlank)
+	case stats.StdDev < opts.MinStdDev:
+		result.Issues = append(result.Issues, QCIssueLowVariance)
+	}
+
+	// 已适配画布的图像按画布尺寸检查，否则按请求的生成尺寸检查
+	wantW, wantH := record.Parameters.Width, record.Parameters.Height
+	if record.PostProcess != nil {
+		wantW, wantH = record.PostProcess.TargetWidth, record.PostProcess.TargetHeight
+	}
+	if wantW > 0 && wantH > 0 && result.Height > 0 {
+		want := float64(wantW) / float64(wantH)
+		got := float64(result.Width) / float64(result.Height)
+		if math.Abs(got-want)/want > opts.AspectTolerance {
+			result.Issues = append(result.Issues, QCIssueAspectRatio)
+		}
+	}
+	return result, signature, true
+}
+
+// RunChapterQC 检查章节图像，未通过的图像使用由原种子推导的新种子重新生成，最多 opts.MaxAttempts 轮，
+// 最终结果写入图像目录的 qc_report.json。重新生成作为图像任务提交到工作池，
+// 与首次生成共用后端的并发、限速和重试设置，pool为nil时使用进程内共享的工作池
+func RunChapterQC(ctx context.Context, logger *zap.Logger, pool *ImageWorkerPool, backend ImageBackend, imagesDir string, opts QCOptions) (*QCReport, error) {
+	if pool == nil {
+		pool = DefaultImageWorkerPool(logger)
+	}
+
+	report, err := CheckChapterImages(imagesDir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	regenerated := make(map[int]int)
+	seeds := make(map[int][]int)
+	for attempt := 1; attempt <= opts.MaxAttempts && report.Failed > 0; attempt++ {
+		if err := ctx.Err(); err != nil {
+			break
+		}
+		for _, failed := range report.Failures() {
+			logger.Warn("图像质检未通过，使用新种子重新生成",
+				zap.Int("index", failed.Index),
+				zap.Strings("issues", failed.Issues),
+				zap.Int("attempt", attempt))
+		}
+
+		records, err := regenerateFailures(ctx, logger, pool, backend, imagesDir, report.Failures(), attempt)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			regenerated[record.Index]++
+			seeds[record.Index] = append(seeds[record.Index], record.Parameters.Seed)
+		}
+
+		if report, err = CheckChapterImages(imagesDir, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range report.Images {
+		report.Images[i].Regenerated = regenerated[report.Images[i].Index]
+		report.Images[i].RetriedSeeds = seeds[report.Images[i].Index]
+	}
+	if err := report.Save(imagesDir); err != nil {
+		return nil, err
+	}
+
+	logger.Info("章节图像质检完成",
+		zap.String("dir", imagesDir),
+		zap.Int("passed", report.Passed),
+		zap.Int("failed", report.Failed))
+	return report, nil
+}
+
+// regenerateFailures 通过工作池重新生成一轮未通过质检的图像，返回成功重新生成的记录。
+// 以另一张待重新生成的图像为参考图的连续分镜放到下一批，等参考图重新生成后再生成；
+// 每批完成后统一更新并保存清单
+func regenerateFailures(ctx context.Context, logger *zap.Logger, pool *ImageWorkerPool, backend ImageBackend, imagesDir string, failures []ImageQCResult, attempt int) ([]ImageRecord, error) {
+	var regenerated []ImageRecord
+	pending := failures
+	for len(pending) > 0 {
+		manifest, err := LoadImageManifest(imagesDir)
+		if err != nil {
+			return nil, err
+		}
+
+		failing := make(map[int]bool, len(pending))
+		for _, failed := range pending {
+			failing[failed.Index] = true
+		}
+		var jobs []ImageJob
+		var deferred []ImageQCResult
+		for _, failed := range pending {
+			if record := manifest.Find(failed.Index); record != nil && failing[record.ChainFrom] {
+				deferred = append(deferred, failed)
+				continue
+			}
+			index := failed.Index
+			seed := qcRetrySeed(index, attempt, seedOf(manifest, index))
+			jobs = append(jobs, ImageJob{
+				Chapter: manifest.Chapter,
+				Index:   index,
+				Backend: backend.Name(),
+				Generate: func(ctx context.Context) (*ImageRecord, error) {
+					return regenerateRecord(ctx, backend, manifest, imagesDir, index, RegenerateOptions{Seed: &seed})
+				},
+			})
+		}
+
+		for _, result := range pool.Run(ctx, jobs) {
+			if result.Err != nil {
+				logger.Warn("重新生成图像失败", zap.Int("index", result.Index), zap.Error(result.Err))
+				continue
+			}
+			manifest.Put(*result.Record)
+			regenerated = append(regenerated, *result.Record)
+		}
+		if err := manifest.Save(imagesDir); err != nil {
+			return nil, err
+		}
+		pending = deferred
+	}
+	return regenerated, nil
+}
+
+// qcRetrySeed 由原种子、分镜序号和尝试次数推导新种子，保证重新生成的结果可以复现
+func qcRetrySeed(index, attempt, seed int) int {
+	return int((int64(seed) + int64(attempt)*1000003 + int64(index)*7919) % math.MaxInt32)
+}
+
+// seedOf 返回清单中记录的种子，没有记录时返回0
+func seedOf(manifest *ImageManifest, index int) int {
+	if record := manifest.Find(index); record != nil && record.Parameters.Seed > 0 {
+		return record.Parameters.Seed
+	}
+	return 0
+}
@@ -0,0 +1,182 @@
+package drawthings
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"novel-video-workflow/pkg/tools/imageproc"
+
+	"go.uber.org/zap"
+)
+
+// writeSolidImage 写入纯色图像
+func writeSolidImage(t *testing.T, path string, width, height int, c color.RGBA) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	if err := imageproc.SavePNG(img, path); err != nil {
+		t.Fatalf("写入图像失败: %v", err)
+	}
+}
+
+func TestChapterQCDetectsAndRegenerates(t *testing.T) {
+	dir := t.TempDir()
+	backend := NewOfflineBackend()
+
+	// 1: 正常图像  2: 与1重复  3: 纯黑  4: 宽高比错误
+	manifest := NewImageManifest(1, ManifestSourceParagraphs)
+	for i := 1; i <= 4; i++ {
+		file := filepath.Join(dir, fmt.Sprintf("scene_%02d.png", i))
+		record, err := GenerateWithBackend(ContextWithSceneIndex(context.Background(), 1), backend, Txt2ImgRequest{Prompt: "雨夜的客栈", Width: 64, Height: 112, Seed: 5}, file)
+		if err != nil {
+			t.Fatalf("生成图像失败: %v", err)
+		}
+		record.Index = i
+		manifest.Put(*record)
+	}
+	writeSolidImage(t, filepath.Join(dir, "scene_03.png"), 64, 112, color.RGBA{A: 255})
+	writeSolidImage(t, filepath.Join(dir, "scene_04.png"), 112, 112, color.RGBA{R: 200, G: 30, B: 30, A: 255})
+	if err := manifest.Save(dir); err != nil {
+		t.Fatalf("保存清单失败: %v", err)
+	}
+
+	opts := DefaultQCOptions()
+	report, err := CheckChapterImages(dir, opts)
+	if err != nil {
+		t.Fatalf("质检失败: %v", err)
+	}
+	issues := map[int][]string{}
+	for _, result := range report.Images {
+		issues[result.Index] = result.Issues
+	}
+	if len(issues[1]) != 0 || len(issues[2]) != 1 || issues[2][0] != QCIssueDuplicate || report.Images[1].DuplicateOf != 1 {
+		t.Errorf("重复检测错误: %v", issues)
+	}
+	if len(issues[3]) != 1 || issues[3][0] != QCIssueBlank {
+		t.Errorf("纯黑检测错误: %v", issues[3])
+	}
+	if len(issues[4]) == 0 || issues[4][len(issues[4])-1] != QCIssueAspectRatio {
+		t.Errorf("宽高比检测错误: %v", issues[4])
+	}
+
+	report, err = RunChapterQC(context.Background(), zap.NewNop(), nil, backend, dir, opts)
+	if err != nil {
+		t.Fatalf("质检重新生成失败: %v", err)
+	}
+	if report.Failed != 0 {
+		t.Errorf("重新生成后应全部通过: %+v", report.Failures())
+	}
+	if report.Images[0].Regenerated != 0 || report.Images[2].Regenerated == 0 || len(report.Images[2].RetriedSeeds) == 0 {
+		t.Errorf("重新生成记录错误: %+v", report.Images)
+	}
+	if _, err := os.Stat(filepath.Join(dir, QCReportFileName)); err != nil {
+		t.Errorf("质检报告未写入: %v", err)
+	}
+}
+
+// flakyBackend 每个分镜的第一次请求返回503，记录成功请求的分镜顺序和最大并发数
+type flakyBackend struct {
+	OfflineBackend
+	mu          sync.Mutex
+	failed      map[int]bool
+	calls       []int
+	inFlight    int
+	maxInFlight int
+}
+
+func (b *flakyBackend) call(ctx context.Context, render func() (*BackendImage, error)) (*BackendImage, error) {
+	index := SceneIndexFromContext(ctx)
+	b.mu.Lock()
+	b.inFlight++
+	if b.inFlight > b.maxInFlight {
+		b.maxInFlight = b.inFlight
+	}
+	first := !b.failed[index]
+	b.failed[index] = true
+	b.mu.Unlock()
+
+	time.Sleep(2 * time.Millisecond)
+	defer func() {
+		b.mu.Lock()
+		b.inFlight--
+		b.mu.Unlock()
+	}()
+	if first {
+		return nil, &HTTPStatusError{StatusCode: 503, Body: "busy"}
+	}
+	b.mu.Lock()
+	b.calls = append(b.calls, index)
+	b.mu.Unlock()
+	return render()
+}
+
+func (b *flakyBackend) Txt2Img(ctx context.Context, params Txt2ImgRequest) (*BackendImage, error) {
+	return b.call(ctx, func() (*BackendImage, error) { return b.OfflineBackend.Txt2Img(ctx, params) })
+}
+
+func (b *flakyBackend) Img2Img(ctx context.Context, params Img2ImgRequest) (*BackendImage, error) {
+	return b.call(ctx, func() (*BackendImage, error) { return b.OfflineBackend.Img2Img(ctx, params) })
+}
+
+func TestChapterQCRegeneratesThroughPool(t *testing.T) {
+	dir := t.TempDir()
+
+	// 三张纯黑图像，第3张以第2张为参考图连续生成
+	manifest := NewImageManifest(1, ManifestSourceOllamaScenes)
+	for i := 1; i <= 3; i++ {
+		file := fmt.Sprintf("scene_%02d.png", i)
+		writeSolidImage(t, filepath.Join(dir, file), 64, 112, color.RGBA{A: 255})
+		record := NewImageRecord(i, file, Txt2ImgRequest{Prompt: fmt.Sprintf("分镜%d", i), Width: 64, Height: 112, Seed: 5})
+		manifest.Put(record)
+	}
+	manifest.Find(3).ChainFrom = 2
+	manifest.Find(3).InitImage = "scene_02.png"
+	if err := manifest.Save(dir); err != nil {
+		t.Fatalf("保存清单失败: %v", err)
+	}
+
+	backend := &flakyBackend{failed: make(map[int]bool)}
+	pool := NewImageWorkerPool(zap.NewNop(), 4, RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})
+	pool.SetBackendLimit(backend.Name(), BackendLimit{MaxConcurrent: 1})
+
+	report, err := RunChapterQC(context.Background(), zap.NewNop(), pool, backend, dir, DefaultQCOptions())
+	if err != nil {
+		t.Fatalf("质检重新生成失败: %v", err)
+	}
+
+	// 503由工作池重试，重新生成一次即全部通过
+	if report.Failed != 0 {
+		t.Errorf("重新生成后应全部通过: %+v", report.Failures())
+	}
+	for _, result := range report.Images {
+		if result.Regenerated != 1 {
+			t.Errorf("第%d张应重新生成1次, 实际 %d", result.Index, result.Regenerated)
+		}
+	}
+	if backend.maxInFlight > 1 {
+		t.Errorf("重新生成应遵守后端并发限制, 最大并发 %d", backend.maxInFlight)
+	}
+
+	// 连续分镜在参考图重新生成之后才重新生成
+	if len(backend.calls) != 3 || backend.calls[2] != 3 {
+		t.Errorf("连续分镜应在参考图之后重新生成: %v", backend.calls)
+	}
+	regenerated, err := LoadImageManifest(dir)
+	if err != nil {
+		t.Fatalf("加载清单失败: %v", err)
+	}
+	for _, record := range regenerated.Images {
+		if record.Regenerations != 1 {
+			t.Errorf("清单中第%d张的重新生成次数为 %d", record.Index, record.Regenerations)
+		}
+	}
+}
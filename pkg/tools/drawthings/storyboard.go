@@ -0,0 +1,329 @@
+package drawthings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// 镜头景别
+const (
+	ShotExtremeWide    = "extreme_wide"     // 大远景
+	ShotWide           = "wide"             // 远景/全景
+	ShotMedium         = "medium"           // 中景
+	ShotCloseUp        = "close_up"         // 近景
+	ShotExtremeCloseUp = "extreme_close_up" // 特写
+	ShotOverShoulder   = "over_shoulder"    // 过肩镜头
+	ShotPOV            = "pov"              // 主观镜头
+	ShotInsert         = "insert"           // 物件特写插入镜头
+)
+
+// StoryboardShotTypes 分镜可用的镜头景别
+var StoryboardShotTypes = []string{ShotExtremeWide, ShotWide, ShotMedium, ShotCloseUp, ShotExtremeCloseUp, ShotOverShoulder, ShotPOV, ShotInsert}
+
+// shotTypeAliases 模型常输出的中文或非规范景别名称
+var shotTypeAliases = map[string]string{
+	"大远景": ShotExtremeWide, "远景": ShotWide, "全景": ShotWide, "中景": ShotMedium,
+	"近景": ShotCloseUp, "特写": ShotExtremeCloseUp, "大特写": ShotExtremeCloseUp, "过肩": ShotOverShoulder,
+	"主观": ShotPOV, "主观镜头": ShotPOV, "插入镜头": ShotInsert,
+	"closeup": ShotCloseUp, "close-up": ShotCloseUp, "establishing": ShotExtremeWide, "full": ShotWide,
+}
+
+// StoryboardScene 结构化分镜
+type StoryboardScene struct {
+	ID             string   `json:"id"`
+	SourceQuote    string   `json:"source_quote"`             // 分镜对应的原文摘录
+	SourceStart    int      `json:"source_start"`             // 原文在章节中的起始位置（按字符计），由本地定位，未知时为-1
+	SourceEnd      int      `json:"source_end"`               // 原文在章节中的结束位置（按字符计，不含）
+	SpanEstimated  bool     `json:"span_estimated,omitempty"` // 原文摘录无法在章节中定位，范围为估算
+	ShotType       string   `json:"shot_type"`
+	Characters     []string `json:"characters"`
+	Location       string   `json:"location"`
+	Mood           string   `json:"mood"`
+	Prompt         string   `json:"prompt"`
+	DurationWeight float64  `json:"duration_weight"` // 相对时长权重，默认1
+}
+
+// StoryboardSchema 分镜JSON Schema，作为Ollama请求的 format 参数约束模型输出
+func StoryboardSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"scenes": map[string]interface{}{
+				"type":     "array",
+				"minItems": 1,
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"id":              map[string]interface{}{"type": "string"},
+						"source_quote":    map[string]interface{}{"type": "string"},
+						"shot_type":       map[string]interface{}{"type": "string", "enum": StoryboardShotTypes},
+						"characters":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+						"location":        map[string]interface{}{"type": "string"},
+						"mood":            map[string]interface{}{"type": "string"},
+						"prompt":          map[string]interface{}{"type": "string"},
+						"duration_weight": map[string]interface{}{"type": "number", "minimum": 0.1, "maximum": 5},
+					},
+					"required": []string{"id", "source_quote", "shot_type", "characters", "location", "mood", "prompt", "duration_weight"},
+				},
+			},
+		},
+		"required": []string{"scenes"},
+	}
+}
+
+// ParseStoryboard 从模型响应中解析分镜，容忍代码围栏、前后说明文字和常见JSON错误。
+// 同时接受 {"scenes": [...]}、分镜对象数组，以及旧格式的提示词字符串数组
+func ParseStoryboard(response string) ([]StoryboardScene, error) {
+	raw, err := ExtractJSON(response)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		if err := json.Unmarshal([]byte(RepairJSON(raw)), &value); err != nil {
+			return nil, fmt.Errorf("分镜JSON格式错误: %v", err)
+		}
+	}
+
+	if obj, ok := value.(map[string]interface{}); ok {
+		if scenes, ok := obj["scenes"]; ok {
+			value = scenes
+		} else {
+			value = []interface{}{obj} // 只返回了单个分镜对象
+		}
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("分镜JSON应为包含 scenes 数组的对象")
+	}
+
+	scenes := make([]StoryboardScene, 0, len(items))
+	for i, item := range items {
+		var scene StoryboardScene
+		switch v := item.(type) {
+		case string:
+			scene.Prompt = v
+		case map[string]interface{}:
+			data, _ := json.Marshal(v)
+			if err := json.Unmarshal(data, &scene); err != nil {
+				return nil, fmt.Errorf("第%d个分镜字段类型错误: %v", i+1, err)
+			}
+		default:
+			return nil, fmt.Errorf("第%d个分镜应为对象", i+1)
+		}
+		scenes = append(scenes, scene)
+	}
+
+	normalizeStoryboard(scenes)
+	return scenes, nil
+}
+
+// normalizeStoryboard 补全可推断的字段：编号、景别、时长权重
+func normalizeStoryboard(scenes []StoryboardScene) {
+	seen := make(map[string]bool, len(scenes))
+	for i := range scenes {
+		scene := &scenes[i]
+		scene.ID = strings.TrimSpace(scene.ID)
+		if scene.ID == "" || seen[scene.ID] {
+			scene.ID = fmt.Sprintf("S%02d", i+1)
+		}
+		seen[scene.ID] = true
+
+		scene.Prompt = strings.TrimSpace(scene.Prompt)
+		scene.SourceQuote = strings.TrimSpace(scene.SourceQuote)
+		scene.Location = strings.TrimSpace(scene.Location)
+		scene.ShotType = normalizeShotType(scene.ShotType)
+		if scene.DurationWeight <= 0 {
+			scene.DurationWeight = 1
+		} else if scene.DurationWeight > 5 {
+			scene.DurationWeight = 5
+		}
+		scene.SourceStart, scene.SourceEnd = -1, -1
+	}
+}
+
+func normalizeShotType(shot string) string {
+	shot = strings.ToLower(strings.TrimSpace(shot))
+	for _, known := range StoryboardShotTypes {
+		if shot == known {
+			return shot
+		}
+	}
+	if alias, ok := shotTypeAliases[shot]; ok {
+		return alias
+	}
+	return ShotMedium
+}
+
+// ValidateStoryboard 检查分镜是否可用，返回需要模型修正的问题，没有问题时返回空
+func ValidateStoryboard(scenes []StoryboardScene, minScenes int) []string {
+	var issues []string
+	if len(scenes) == 0 {
+		return []string{"scenes 数组为空"}
+	}
+	if len(scenes) < minScenes {
+		issues = append(issues, fmt.Sprintf("分镜数量为%d个，少于要求的最少%d个", len(scenes), minScenes))
+	}
+	for _, scene := range scenes {
+		if scene.Prompt == "" {
+			issues = append(issues, fmt.Sprintf("分镜%s缺少 prompt", scene.ID))
+		}
+		if scene.SourceQuote == "" {
+			issues = append(issues, fmt.Sprintf("分镜%s缺少 source_quote", scene.ID))
+		}
+	}
+	return issues
+}
+
+// LocateStoryboard 按顺序在章节文本中定位各分镜的原文摘录，找不到时按分镜数量估算范围
+func LocateStoryboard(content string, scenes []StoryboardScene) {
+	locator := NewSpanLocator(content)
+	for i := range scenes {
+		scene := &scenes[i]
+		scene.SourceStart, scene.SourceEnd = locator.Locate(scene.SourceQuote)
+		if scene.SourceStart == -1 {
+			var estimated string
+			estimated, scene.SourceStart, scene.SourceEnd = EstimateSceneSpan(content, i, len(scenes))
+			if scene.SourceQuote == "" {
+				scene.SourceQuote = estimated
+			}
+			scene.SpanEstimated = true
+		}
+	}
+}
+
+// storyboardFeedback 生成重试时追加到对话中的修正说明，上一次的输出已作为模型回复保留在对话历史中
+func storyboardFeedback(err error) string {
+	return fmt.Sprintf(`你上一次的输出不符合要求：%v
+
+请修正上述问题，重新输出完整的分镜JSON对象，不要输出任何其他内容。`, err)
+}
+
+// AnalyzeScenesAndGeneratePrompts 分析整个章节内容并生成结构化分镜。
+// 通过 format 参数要求Ollama按分镜JSON Schema输出，解析时容忍并修复常见格式错误；
+// 仍不合法时把问题反馈给模型重试，最多 ollama.storyboard_max_attempts 次（默认3次）
+func (c *OllamaClient) AnalyzeScenesAndGeneratePrompts(content, style string, estimatedDurationSecs int) ([]StoryboardScene, error) {
+	systemPrompt := fmt.Sprintf(`你是一个专业的影视分镜师和AI图像生成提示词工程师。你的任务是：
+1. 分析输入的文本内容
+2. 识别出适合生成图像的关键场景/分镜
+3. 为每个分镜生成结构化的分镜信息和详细的中文图像提示词
+
+要求：
+1. 每个分镜应该是一个可以独立成图的视觉时刻，按原文顺序排列
+2. id: 分镜编号，如 S01、S02
+3. source_quote: 从原文中逐字摘录该分镜对应的一句话，不要改写
+4. shot_type: 镜头景别，只能是 %s 之一
+5. characters: 画面中出现的角色名称列表，没有角色时为空数组
+6. location: 场景地点，同一地点的连续分镜必须使用完全相同的地点名称
+7. mood: 情绪氛围
+8. prompt: 详细的中文图像提示词，包含人物、环境、光线、构图、色调等视觉细节，使用专业摄影和艺术术语，并保持与整体风格的连贯性
+9. duration_weight: 该分镜相对的时长权重，普通分镜为1，需要停留更久的关键分镜可为1.5-3
+10. 返回格式为JSON对象：{"scenes": [分镜, ...]}`, strings.Join(StoryboardShotTypes, "、"))
+
+	estimatedDurationMsg := ""
+	minScenes := 1
+	if estimatedDurationSecs > 0 {
+		estimatedDurationMsg = fmt.Sprintf("文本内容估算的音频时长约为%d秒，请根据音频时长确定最少分镜数量（建议每30-60秒音频时长对应一个视觉场景作为最低标准），但可根据内容重要性和视觉表现力自主决定最终分镜数量上限。", estimatedDurationSecs)
+		if n := estimatedDurationSecs / 60; n > minScenes {
+			minScenes = n
+		}
+	}
+
+	userPrompt := fmt.Sprintf(`请分析以下文本内容并生成分镜：
+
+文本内容：%s
+
+图像风格：%s
+
+%s
+请根据上述信息，分析内容并生成适量的关键视觉场景（建议8-20个），以JSON对象格式返回，格式如：
+{"scenes": [{"id": "S01", "source_quote": "原文摘录", "shot_type": "wide", "characters": ["角色名"], "location": "地点", "mood": "氛围", "prompt": "中文图像提示词", "duration_weight": 1}]}
+
+只返回JSON对象，不要添加其他解释。`, content, style, estimatedDurationMsg)
+
+	maxAttempts := viper.GetInt("ollama.storyboard_max_attempts")
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+
+	c.Logger.Info("发送Ollama请求分析场景并生成分镜",
+		zap.String("model", c.Model),
+		zap.Int("min_scenes", minScenes))
+
+	// 重试时把上一次的输出和修正说明追加到对话历史中
+	messages := []ChatMessage{SystemMessage(systemPrompt), UserMessage(userPrompt)}
+	var format interface{} = StoryboardSchema()
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		response, err := c.Chat(context.Background(), messages, format, nil, nil)
+		var statusErr *HTTPStatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == 400 && attempt == 1 {
+			// 旧版本Ollama不支持JSON Schema，退回为普通JSON模式
+			c.Logger.Warn("Ollama不支持JSON Schema格式约束，改用JSON模式", zap.Error(err))
+			format = "json"
+			response, err = c.Chat(context.Background(), messages, format, nil, nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scenes, err := ParseStoryboard(response)
+		if err == nil {
+			if issues := ValidateStoryboard(scenes, minScenes); len(issues) > 0 {
+				err = fmt.Errorf("%s", strings.Join(issues, "；"))
+			}
+		}
+		if err == nil {
+			LocateStoryboard(content, scenes)
+			c.Logger.Info("成功解析结构化分镜", zap.Int("scene_count", len(scenes)), zap.Int("attempt", attempt))
+			return scenes, nil
+		}
+
+		lastErr = err
+		c.Logger.Warn("分镜JSON不合法，反馈给模型重试",
+			zap.Int("attempt", attempt),
+			zap.Int("max_attempts", maxAttempts),
+			zap.Error(err))
+		messages = append(messages, AssistantMessage(response), UserMessage(storyboardFeedback(err)))
+	}
+
+	return nil, fmt.Errorf("分镜JSON在%d次尝试后仍不合法: %v", maxAttempts, lastErr)
+}
+
+// StoryboardScenePrompts 将结构化分镜转换为待生成的分镜图像，注入角色设定，
+// 图像文件按 scene_XX.png 命名。分镜提示词由Ollama按风格描述生成，已包含风格
+func (c *ChapterImageGenerator) StoryboardScenePrompts(imagesDir string, storyboard []StoryboardScene) []ScenePrompt {
+	scenes := make([]ScenePrompt, 0, len(storyboard))
+	for idx, board := range storyboard {
+		board := board
+		prompt := board.Prompt
+
+		// 注入角色设定，保持角色形象一致
+		seed := -1
+		if c.CharacterBible != nil {
+			prompt, seed = c.CharacterBible.ApplyToPrompt(prompt, board.SourceQuote, strings.Join(board.Characters, "、"))
+		}
+
+		scenes = append(scenes, ScenePrompt{
+			Index:         idx + 1,
+			ImageFile:     filepath.Join(imagesDir, fmt.Sprintf("scene_%02d.png", idx+1)),
+			Prompt:        prompt,
+			Seed:          seed,
+			Styled:        true,
+			SourceText:    board.SourceQuote,
+			SourceStart:   board.SourceStart,
+			SourceEnd:     board.SourceEnd,
+			SpanEstimated: board.SpanEstimated,
+			Storyboard:    &board,
+		})
+	}
+	return scenes
+}
@@ -0,0 +1,97 @@
+package drawthings
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestParseStoryboardTolerant(t *testing.T) {
+	cases := map[string]string{
+		"代码围栏和说明文字": "好的，以下是分镜：\n```json\n{\"scenes\": [{\"id\": \"S01\", \"source_quote\": \"门开了\", \"shot_type\": \"close_up\", \"characters\": [\"林晚\"], \"location\": \"客栈\", \"mood\": \"紧张\", \"prompt\": \"昏暗的木门缓缓打开\", \"duration_weight\": 2}]}\n```\n希望对你有帮助。",
+		"推理块":       "<think>先分析一下 [这里不是JSON]</think>{\"scenes\": [{\"id\": \"S01\", \"source_quote\": \"门开了\", \"shot_type\": \"特写\", \"prompt\": \"昏暗的木门缓缓打开\", \"duration_weight\": 2}]}",
+		"尾随逗号与中文标点": "{\"scenes\"：[{\"id\": \"S01\", \"source_quote\": \"门开了\", \"shot_type\": \"close_up\", \"prompt\": \"昏暗的木门，缓缓打开\", \"duration_weight\": 2,},]}",
+		"截断的响应":     "{\"scenes\": [{\"id\": \"S01\", \"source_quote\": \"门开了\", \"shot_type\": \"close_up\", \"duration_weight\": 2, \"prompt\": \"昏暗的木门缓缓打开",
+	}
+
+	for name, response := range cases {
+		scenes, err := ParseStoryboard(response)
+		if err != nil {
+			t.Errorf("%s: 解析失败: %v", name, err)
+			continue
+		}
+		if len(scenes) != 1 || scenes[0].SourceQuote != "门开了" || !strings.HasPrefix(scenes[0].Prompt, "昏暗的木门") || scenes[0].DurationWeight != 2 {
+			t.Errorf("%s: 分镜内容错误: %+v", name, scenes)
+		}
+		if scenes[0].ShotType != ShotCloseUp && scenes[0].ShotType != ShotExtremeCloseUp {
+			t.Errorf("%s: 景别错误: %s", name, scenes[0].ShotType)
+		}
+	}
+}
+
+func TestParseStoryboardLegacyArrayAndDefaults(t *testing.T) {
+	scenes, err := ParseStoryboard(`["雨夜的街道", "客栈的大堂"]`)
+	if err != nil {
+		t.Fatalf("解析旧格式失败: %v", err)
+	}
+	if len(scenes) != 2 || scenes[1].Prompt != "客栈的大堂" {
+		t.Fatalf("旧格式分镜错误: %+v", scenes)
+	}
+	if scenes[0].ID != "S01" || scenes[1].ID != "S02" || scenes[0].ShotType != ShotMedium || scenes[0].DurationWeight != 1 {
+		t.Errorf("默认字段未补全: %+v", scenes)
+	}
+
+	if issues := ValidateStoryboard(scenes, 3); len(issues) != 3 {
+		t.Errorf("应报告数量不足和缺少原文摘录: %v", issues)
+	}
+}
+
+func TestLocateStoryboard(t *testing.T) {
+	content := "夜深了。门开了，一阵冷风吹进来。林晚抬起头。"
+	scenes := []StoryboardScene{{SourceQuote: "门开了"}, {SourceQuote: "不存在的句子"}, {SourceQuote: "林晚抬起头"}}
+	LocateStoryboard(content, scenes)
+
+	if scenes[0].SourceStart != 4 || scenes[0].SourceEnd != 7 || scenes[0].SpanEstimated {
+		t.Errorf("原文定位错误: %+v", scenes[0])
+	}
+	if !scenes[1].SpanEstimated || scenes[1].SourceStart == -1 {
+		t.Errorf("找不到的摘录应估算范围: %+v", scenes[1])
+	}
+	if scenes[2].SpanEstimated || scenes[2].SourceStart != 16 {
+		t.Errorf("原文定位错误: %+v", scenes[2])
+	}
+}
+
+func TestAnalyzeScenesRetriesWithFeedback(t *testing.T) {
+	var requests []ChatRequest
+	server := newFakeOllama(t, func(req ChatRequest) string {
+		requests = append(requests, req)
+		if len(requests) > 1 {
+			return `{"scenes": [{"id": "S01", "source_quote": "门开了", "shot_type": "wide", "characters": [], "location": "客栈", "mood": "阴冷", "prompt": "客栈木门被风吹开", "duration_weight": 1}]}`
+		}
+		return `{"scenes": [{"id": "S01", "source_quote": "门开了", "shot_type": "wide", "prompt": ""}]}`
+	})
+	defer server.Close()
+
+	client := NewOllamaClient(zap.NewNop(), server.URL, "test")
+	scenes, err := client.AnalyzeScenesAndGeneratePrompts("夜深了。门开了。", "悬疑", 0)
+	if err != nil {
+		t.Fatalf("分镜分析失败: %v", err)
+	}
+	if len(scenes) != 1 || scenes[0].Prompt != "客栈木门被风吹开" || scenes[0].SourceStart != 4 {
+		t.Errorf("分镜结果错误: %+v", scenes)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("应重试1次, 实际请求 %d 次", len(requests))
+	}
+	if _, ok := requests[0].Format.(map[string]interface{}); !ok {
+		t.Errorf("请求应携带JSON Schema格式约束: %v", requests[0].Format)
+	}
+	// 重试请求带上一次的回复和修正说明
+	retry := requests[1].Messages
+	if len(retry) != 4 || retry[2].Role != "assistant" || !strings.Contains(retry[3].Content, "缺少 prompt") {
+		t.Errorf("重试请求应包含对话历史和修正说明: %+v", retry)
+	}
+}
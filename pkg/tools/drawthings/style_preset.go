@@ -0,0 +1,239 @@
+package drawthings
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultStylePresetName 未配置 image.style_preset 时使用的风格预设
+const DefaultStylePresetName = "suspense_horror"
+
+// StylePreset 画面风格预设，统一描述提示词修饰、反向提示词、模型与采样参数
+type StylePreset struct {
+	Name           string  `yaml:"name" json:"name"`
+	Description    string  `yaml:"description,omitempty" json:"description,omitempty"`
+	PromptPrefix   string  `yaml:"prompt_prefix,omitempty" json:"prompt_prefix,omitempty"`
+	PromptSuffix   string  `yaml:"prompt_suffix,omitempty" json:"prompt_suffix,omitempty"`
+	NegativePrompt string  `yaml:"negative_prompt,omitempty" json:"negative_prompt,omitempty"`
+	Model          string  `yaml:"model,omitempty" json:"model,omitempty"`
+	Sampler        string  `yaml:"sampler,omitempty" json:"sampler,omitempty"`
+	Steps          int     `yaml:"steps,omitempty" json:"steps,omitempty"`
+	CfgScale       float64 `yaml:"cfg_scale,omitempty" json:"cfg_scale,omitempty"`
+	Width          int     `yaml:"width,omitempty" json:"width,omitempty"`
+	Height         int     `yaml:"height,omitempty" json:"height,omitempty"`
+	OllamaStyle    string  `yaml:"ollama_style,omitempty" json:"ollama_style,omitempty"` // 让Ollama生成提示词时遵循的风格描述
+}
+
+// builtinStylePresets 内置风格预设，未提供风格预设文件时也可使用
+var builtinStylePresets = []StylePreset{
+	{
+		Name:           "suspense_horror",
+		Description:    "悬疑惊悚：阴暗压抑、低饱和度的胶片质感",
+		PromptSuffix:   ", 周围环境模糊成黑影, 空气凝滞,浅景深, 胶片颗粒感, 低饱和度，极致悬疑氛围, 阴沉窒息感, 夏季，环境阴霾，其他部分模糊不可见",
+		NegativePrompt: "人脸特写，半身像，模糊，比例失调，原参考图背景，比例失调，缺肢",
+		Model:          "z_image_turbo_1.0_q6p.ckpt",
+		Sampler:        "DPM++ 2M Trailing",
+		Steps:          8,
+		CfgScale:       1.0,
+		Width:          512,
+		Height:         896,
+		OllamaStyle:    "悬疑惊悚风格，周围环境模糊成黑影, 空气凝滞,浅景深, 胶片颗粒感, 低饱和度，极致悬疑氛围, 阴沉窒息感, 夏季，环境阴霾，其他部分模糊不可见",
+	},
+	{
+		Name:           "plain",
+		Description:    "不附加风格修饰，仅使用原始提示词",
+		NegativePrompt: "模糊，比例失调，缺肢",
+		Model:          "z_image_turbo_1.0_q6p.ckpt",
+		Sampler:        "DPM++ 2M Trailing",
+		Steps:          8,
+		CfgScale:       1.0,
+		Width:          512,
+		Height:         896,
+		OllamaStyle:    "写实风格，构图清晰，光线自然",
+	},
+}
+
+// withDefaults 补全预设中未填写的模型与采样参数
+func (p StylePreset) withDefaults() StylePreset {
+	base := builtinStylePresets[0]
+	if p.Model == "" {
+		p.Model = base.Model
+	}
+	if p.Sampler == "" {
+		p.Sampler = base.Sampler
+	}
+	if p.Steps <= 0 {
+		p.Steps = base.Steps
+	}
+	if p.CfgScale <= 0 {
+		p.CfgScale = base.CfgScale
+	}
+	if p.Width <= 0 {
+		p.Width = base.Width
+	}
+	if p.Height <= 0 {
+		p.Height = base.Height
+	}
+	return p
+}
+
+// ApplyPrompt 为提示词加上预设的前缀和后缀
+func (p *StylePreset) ApplyPrompt(text string) string {
+	return p.PromptPrefix + text + p.PromptSuffix
+}
+
+// Size 返回图像尺寸，width/height为0时使用预设尺寸
+func (p *StylePreset) Size(width, height int) (int, int) {
+	if width <= 0 {
+		width = p.Width
+	}
+	if height <= 0 {
+		height = p.Height
+	}
+	return width, height
+}
+
+// Txt2ImgParams 根据预设组装文生图参数，text为未加风格修饰的提示词
+func (p *StylePreset) Txt2ImgParams(text string, width, height, seed int) Txt2ImgRequest {
+	return p.Txt2ImgParamsStyled(p.ApplyPrompt(text), width, height, seed)
+}
+
+// Txt2ImgParamsStyled 根据预设组装文生图参数，prompt已包含风格描述（如Ollama按 OllamaStyle 生成的提示词），不再加前后缀
+func (p *StylePreset) Txt2ImgParamsStyled(prompt string, width, height, seed int) Txt2ImgRequest {
+	width, height = p.Size(width, height)
+	strengthValue := 1.0
+	return Txt2ImgRequest{
+		Prompt:            prompt,
+		NegativePrompt:    p.NegativePrompt,
+		Width:             width,
+		Height:            height,
+		Steps:             p.Steps,
+		Seed:              seed,
+		SamplerName:       p.Sampler,
+		GuidanceScale:     p.CfgScale,
+		BatchSize:         1,
+		Model:             p.Model,
+		DenoisingStrength: &strengthValue,
+	}
+}
+
+// StyleRegistry 风格预设注册表
+type StyleRegistry struct {
+	mu      sync.RWMutex
+	presets map[string]StylePreset
+}
+
+// stylePresetFile 风格预设文件格式
+type stylePresetFile struct {
+	Presets []StylePreset `yaml:"presets"`
+}
+
+// NewStyleRegistry 创建包含内置预设的注册表
+func NewStyleRegistry() *StyleRegistry {
+	r := &StyleRegistry{presets: make(map[string]StylePreset)}
+	for _, preset := range builtinStylePresets {
+		r.Register(preset)
+	}
+	return r
+}
+
+// Register 注册风格预设，同名预设会被覆盖
+func (r *StyleRegistry) Register(preset StylePreset) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.presets[preset.Name] = preset.withDefaults()
+}
+
+// LoadFile 从YAML文件加载风格预设，文件中的预设覆盖同名的内置预设
+func (r *StyleRegistry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取风格预设文件失败: %v", err)
+	}
+
+	var file stylePresetFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("解析风格预设文件失败: %v", err)
+	}
+
+	for i, preset := range file.Presets {
+		preset.Name = strings.TrimSpace(preset.Name)
+		if preset.Name == "" {
+			return fmt.Errorf("风格预设文件第%d个预设缺少name", i+1)
+		}
+		r.Register(preset)
+	}
+	return nil
+}
+
+// Get 按名称获取风格预设，名称为空时使用 image.style_preset 配置的默认预设
+func (r *StyleRegistry) Get(name string) (*StylePreset, error) {
+	if name == "" {
+		name = viper.GetString("image.style_preset")
+	}
+	if name == "" {
+		name = DefaultStylePresetName
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	preset, ok := r.presets[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的风格预设: %s，可用预设: %s", name, strings.Join(r.namesLocked(), ", "))
+	}
+	return &preset, nil
+}
+
+// Names 返回所有预设名称
+func (r *StyleRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.namesLocked()
+}
+
+func (r *StyleRegistry) namesLocked() []string {
+	names := make([]string, 0, len(r.presets))
+	for name := range r.presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	defaultStyleRegistry     *StyleRegistry
+	defaultStyleRegistryErr  error
+	defaultStyleRegistryOnce sync.Once
+)
+
+// DefaultStyleRegistry 返回进程内共享的风格预设注册表：
+// 内置预设 + image.style_presets_file 指定的YAML文件（默认 style_presets.yaml，不存在时忽略）
+func DefaultStyleRegistry() *StyleRegistry {
+	defaultStyleRegistryOnce.Do(func() {
+		defaultStyleRegistry = NewStyleRegistry()
+
+		path := viper.GetString("image.style_presets_file")
+		if path == "" {
+			path = "style_presets.yaml"
+		}
+		if _, err := os.Stat(path); err == nil {
+			defaultStyleRegistryErr = defaultStyleRegistry.LoadFile(path)
+		}
+	})
+	return defaultStyleRegistry
+}
+
+// ResolveStylePreset 从共享注册表中按名称获取风格预设，名称为空时使用默认预设
+func ResolveStylePreset(name string) (*StylePreset, error) {
+	registry := DefaultStyleRegistry()
+	if defaultStyleRegistryErr != nil {
+		return nil, defaultStyleRegistryErr
+	}
+	return registry.Get(name)
+}
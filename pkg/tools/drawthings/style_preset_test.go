@@ -0,0 +1,73 @@
+package drawthings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStyleRegistryLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "style_presets.yaml")
+	content := `presets:
+  - name: ancient_wuxia
+    prompt_prefix: "水墨画, "
+    prompt_suffix: ", 山水意境"
+    negative_prompt: 现代建筑
+    width: 768
+  - name: plain
+    negative_prompt: 覆盖内置预设
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("写入预设文件失败: %v", err)
+	}
+
+	registry := NewStyleRegistry()
+	if err := registry.LoadFile(path); err != nil {
+		t.Fatalf("加载预设文件失败: %v", err)
+	}
+
+	preset, err := registry.Get("ancient_wuxia")
+	if err != nil {
+		t.Fatalf("获取预设失败: %v", err)
+	}
+	params := preset.Txt2ImgParams("古城门", 0, 0, 42)
+	if params.Prompt != "水墨画, 古城门, 山水意境" || params.NegativePrompt != "现代建筑" {
+		t.Errorf("提示词未按预设组装: %+v", params)
+	}
+	if params.Width != 768 || params.Height != 896 || params.Seed != 42 {
+		t.Errorf("尺寸或种子错误: %dx%d seed=%d", params.Width, params.Height, params.Seed)
+	}
+	if params.Model == "" || params.SamplerName == "" || params.Steps <= 0 {
+		t.Errorf("未填写的模型与采样参数应使用默认值: %+v", params)
+	}
+
+	if plain, _ := registry.Get("plain"); plain.NegativePrompt != "覆盖内置预设" {
+		t.Errorf("同名预设应覆盖内置预设: %+v", plain)
+	}
+}
+
+func TestStyleRegistryGet(t *testing.T) {
+	registry := NewStyleRegistry()
+
+	preset, err := registry.Get("")
+	if err != nil || preset.Name != DefaultStylePresetName {
+		t.Errorf("名称为空时应返回默认预设: %+v, %v", preset, err)
+	}
+	if preset.OllamaStyle == "" || preset.PromptSuffix == "" {
+		t.Errorf("默认预设应包含风格描述: %+v", preset)
+	}
+
+	if _, err := registry.Get("不存在"); err == nil {
+		t.Errorf("未知预设应返回错误")
+	}
+}
+
+func TestStyleRegistryLoadFileRequiresName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "style_presets.yaml")
+	if err := os.WriteFile(path, []byte("presets:\n  - prompt_suffix: x\n"), 0644); err != nil {
+		t.Fatalf("写入预设文件失败: %v", err)
+	}
+	if err := NewStyleRegistry().LoadFile(path); err == nil {
+		t.Errorf("缺少name的预设应返回错误")
+	}
+}
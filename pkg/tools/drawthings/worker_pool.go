@@ -0,0 +1,315 @@
+package drawthings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// BackendDrawThings DrawThings / Stable Diffusion WebUI 后端名称
+const BackendDrawThings = "drawthings"
+
+// BackendLimit 单个图像后端的并发与限速设置
+type BackendLimit struct {
+	MaxConcurrent     int // 同时发往该后端的请求数，<=0表示不限
+	RequestsPerMinute int // 每分钟请求上限，<=0表示不限
+}
+
+// RetryPolicy 失败重试策略，仅对临时性错误（网络错误、429、5xx）重试
+type RetryPolicy struct {
+	MaxAttempts    int           // 最多尝试次数（含首次）
+	InitialBackoff time.Duration // 首次重试前的等待时间，之后每次翻倍
+	MaxBackoff     time.Duration
+}
+
+// ImageJob 单个图像生成任务
+type ImageJob struct {
+	Chapter  int
+	Index    int
+	Backend  string
+	Generate func(ctx context.Context) (*ImageRecord, error)
+}
+
+// ImageJobResult 图像生成任务结果
+type ImageJobResult struct {
+	Chapter  int
+	Index    int
+	Record   *ImageRecord
+	Attempts int
+	Err      error
+}
+
+// backendGate 控制单个后端的并发数和请求间隔
+type backendGate struct {
+	slots    chan struct{}
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newBackendGate(limit BackendLimit) *backendGate {
+	g := &backendGate{}
+	if limit.MaxConcurrent > 0 {
+		g.slots = make(chan struct{}, limit.MaxConcurrent)
+	}
+	if limit.RequestsPerMinute > 0 {
+		g.interval = time.Minute / time.Duration(limit.RequestsPerMinute)
+	}
+	return g
+}
+
+func (g *backendGate) acquire(ctx context.Context) error {
+	if g.slots == nil {
+		return nil
+	}
+	select {
+	case g.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g *backendGate) release() {
+	if g.slots != nil {
+		<-g.slots
+	}
+}
+
+// wait 按限速要求等待到下一个可发送请求的时间点
+func (g *backendGate) wait(ctx context.Context) error {
+	if g.interval <= 0 {
+		return nil
+	}
+
+	g.mu.Lock()
+	now := time.Now()
+	start := g.next
+	if start.Before(now) {
+		start = now
+	}
+	g.next = start.Add(g.interval)
+	g.mu.Unlock()
+
+	return sleepContext(ctx, start.Sub(now))
+}
+
+// ImageWorkerPool 有界的图像生成工作池，
+// 同一个池在多个章节、多个调用间共享时，后端的并发与限速是全局生效的
+type ImageWorkerPool struct {
+	Workers int
+	Retry   RetryPolicy
+	Logger  *zap.Logger
+
+	mu     sync.Mutex
+	limits map[string]BackendLimit
+	gates  map[string]*backendGate
+}
+
+var (
+	defaultPool     *ImageWorkerPool
+	defaultPoolOnce sync.Once
+)
+
+// DefaultImageWorkerPool 返回进程内共享的图像工作池，配置读取自 workflow 与 image.backends
+func DefaultImageWorkerPool(logger *zap.Logger) *ImageWorkerPool {
+	defaultPoolOnce.Do(func() {
+		defaultPool = NewImageWorkerPoolFromConfig(logger)
+	})
+	return defaultPool
+}
+
+// NewImageWorkerPool 创建图像工作池
+func NewImageWorkerPool(logger *zap.Logger, workers int, retry RetryPolicy) *ImageWorkerPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if retry.MaxAttempts <= 0 {
+		retry.MaxAttempts = 1
+	}
+	return &ImageWorkerPool{
+		Workers: workers,
+		Retry:   retry,
+		Logger:  logger,
+		limits:  make(map[string]BackendLimit),
+		gates:   make(map[string]*backendGate),
+	}
+}
+
+// NewImageWorkerPoolFromConfig 根据配置创建图像工作池：
+// workflow.max_concurrent 为任务并发数，workflow.retry_attempts 为最多尝试次数，
+// image.backends.<名称>.max_concurrent / requests_per_minute 为各后端限制
+func NewImageWorkerPoolFromConfig(logger *zap.Logger) *ImageWorkerPool {
+	workers := viper.GetInt("workflow.max_concurrent")
+	if workers <= 0 {
+		workers = 2
+	}
+
+	attempts := viper.GetInt("workflow.retry_attempts")
+	if attempts <= 0 {
+		attempts = 3
+	}
+
+	backoff := time.Duration(viper.GetInt("image.retry_backoff_ms")) * time.Millisecond
+	if backoff <= 0 {
+		backoff = 2 * time.Second
+	}
+
+	pool := NewImageWorkerPool(logger, workers, RetryPolicy{
+		MaxAttempts:    attempts,
+		InitialBackoff: backoff,
+		MaxBackoff:     30 * time.Second,
+	})
+
+	// DrawThings本地推理通常一次只能处理一个请求
+	pool.SetBackendLimit(BackendDrawThings, BackendLimit{MaxConcurrent: 1})
+	for name := range viper.GetStringMap("image.backends") {
+		pool.SetBackendLimit(name, BackendLimit{
+			MaxConcurrent:     viper.GetInt(fmt.Sprintf("image.backends.%s.max_concurrent", name)),
+			RequestsPerMinute: viper.GetInt(fmt.Sprintf("image.backends.%s.requests_per_minute", name)),
+		})
+	}
+
+	return pool
+}
+
+// SetBackendLimit 设置后端的并发与限速，需在提交任务前调用
+func (p *ImageWorkerPool) SetBackendLimit(backend string, limit BackendLimit) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limits[backend] = limit
+	p.gates[backend] = newBackendGate(limit)
+}
+
+func (p *ImageWorkerPool) gate(backend string) *backendGate {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	g, ok := p.gates[backend]
+	if !ok {
+		g = newBackendGate(p.limits[backend])
+		p.gates[backend] = g
+	}
+	return g
+}
+
+// Run 并发执行图像任务并等待全部完成，返回按章节、序号排序的结果。
+// ctx取消后，未开始的任务直接以ctx错误返回，进行中的请求会被中断
+func (p *ImageWorkerPool) Run(ctx context.Context, jobs []ImageJob) []ImageJobResult {
+	results := make([]ImageJobResult, len(jobs))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	workers := p.Workers
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = p.runJob(ctx, jobs[i])
+			}
+		}()
+	}
+
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Chapter != results[j].Chapter {
+			return results[i].Chapter < results[j].Chapter
+		}
+		return results[i].Index < results[j].Index
+	})
+	return results
+}
+
+// runJob 在后端并发与限速约束下执行单个任务，临时性错误按退避策略重试
+func (p *ImageWorkerPool) runJob(ctx context.Context, job ImageJob) ImageJobResult {
+	result := ImageJobResult{Chapter: job.Chapter, Index: job.Index}
+	if err := ctx.Err(); err != nil {
+		result.Err = err
+		return result
+	}
+
+	g := p.gate(job.Backend)
+	if err := g.acquire(ctx); err != nil {
+		result.Err = err
+		return result
+	}
+	defer g.release()
+
+	backoff := p.Retry.InitialBackoff
+	for attempt := 1; attempt <= p.Retry.MaxAttempts; attempt++ {
+		if err := g.wait(ctx); err != nil {
+			result.Err = err
+			return result
+		}
+
+		result.Attempts = attempt
+		result.Record, result.Err = job.Generate(ctx)
+		if result.Err == nil || !IsTransientError(result.Err) || attempt == p.Retry.MaxAttempts {
+			break
+		}
+
+		p.Logger.Warn("图像生成失败，稍后重试",
+			zap.Int("chapter", job.Chapter),
+			zap.Int("index", job.Index),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(result.Err))
+
+		if err := sleepContext(ctx, backoff); err != nil {
+			result.Err = err
+			return result
+		}
+		backoff *= 2
+		if p.Retry.MaxBackoff > 0 && backoff > p.Retry.MaxBackoff {
+			backoff = p.Retry.MaxBackoff
+		}
+	}
+
+	return result
+}
+
+// IsTransientError 判断是否为可重试的临时性错误：网络错误、请求超时、429及5xx状态码
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
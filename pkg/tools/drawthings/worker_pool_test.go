@@ -0,0 +1,178 @@
+package drawthings
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestWorkerPoolBackendLimitAndOrdering(t *testing.T) {
+	pool := NewImageWorkerPool(zap.NewNop(), 4, RetryPolicy{MaxAttempts: 1})
+	pool.SetBackendLimit("test", BackendLimit{MaxConcurrent: 2})
+
+	var inFlight, maxInFlight int32
+	var jobs []ImageJob
+	for chapter := 2; chapter >= 1; chapter-- {
+		for index := 4; index >= 1; index-- {
+			delay := time.Duration(index) * 5 * time.Millisecond
+			jobs = append(jobs, ImageJob{
+				Chapter: chapter,
+				Index:   index,
+				Backend: "test",
+				Generate: func(ctx context.Context) (*ImageRecord, error) {
+					n := atomic.AddInt32(&inFlight, 1)
+					for {
+						m := atomic.LoadInt32(&maxInFlight)
+						if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+							break
+						}
+					}
+					time.Sleep(delay)
+					atomic.AddInt32(&inFlight, -1)
+					return &ImageRecord{}, nil
+				},
+			})
+		}
+	}
+
+	results := pool.Run(context.Background(), jobs)
+	if maxInFlight > 2 {
+		t.Errorf("后端并发不应超过2, 实际 %d", maxInFlight)
+	}
+	for i, result := range results {
+		wantChapter, wantIndex := i/4+1, i%4+1
+		if result.Chapter != wantChapter || result.Index != wantIndex || result.Err != nil {
+			t.Errorf("第%d个结果应为 章节%d-分镜%d: %+v", i, wantChapter, wantIndex, result)
+		}
+	}
+}
+
+func TestWorkerPoolRetriesTransientErrors(t *testing.T) {
+	pool := NewImageWorkerPool(zap.NewNop(), 2, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	var transientCalls, permanentCalls int32
+	results := pool.Run(context.Background(), []ImageJob{
+		{Index: 1, Generate: func(ctx context.Context) (*ImageRecord, error) {
+			if atomic.AddInt32(&transientCalls, 1) < 3 {
+				return nil, &HTTPStatusError{StatusCode: 503, Body: "busy"}
+			}
+			return &ImageRecord{}, nil
+		}},
+		{Index: 2, Generate: func(ctx context.Context) (*ImageRecord, error) {
+			atomic.AddInt32(&permanentCalls, 1)
+			return nil, &HTTPStatusError{StatusCode: 400, Body: "bad request"}
+		}},
+	})
+
+	if results[0].Err != nil || results[0].Attempts != 3 {
+		t.Errorf("503应重试直至成功: %+v", results[0])
+	}
+	if results[1].Err == nil || permanentCalls != 1 {
+		t.Errorf("400不应重试: %+v, 调用次数 %d", results[1], permanentCalls)
+	}
+}
+
+func TestWorkerPoolRateLimit(t *testing.T) {
+	pool := NewImageWorkerPool(zap.NewNop(), 3, RetryPolicy{MaxAttempts: 1})
+	pool.SetBackendLimit("test", BackendLimit{RequestsPerMinute: 3000}) // 每20ms一个请求
+
+	var mu sync.Mutex
+	var starts []time.Time
+	jobs := make([]ImageJob, 3)
+	for i := range jobs {
+		jobs[i] = ImageJob{Index: i + 1, Backend: "test", Generate: func(ctx context.Context) (*ImageRecord, error) {
+			mu.Lock()
+			starts = append(starts, time.Now())
+			mu.Unlock()
+			return &ImageRecord{}, nil
+		}}
+	}
+
+	pool.Run(context.Background(), jobs)
+	if elapsed := starts[len(starts)-1].Sub(starts[0]); elapsed < 35*time.Millisecond {
+		t.Errorf("3个请求应至少间隔40ms, 实际 %v", elapsed)
+	}
+}
+
+func TestWorkerPoolCancellation(t *testing.T) {
+	pool := NewImageWorkerPool(zap.NewNop(), 1, RetryPolicy{MaxAttempts: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int32
+	jobs := make([]ImageJob, 3)
+	for i := range jobs {
+		jobs[i] = ImageJob{Index: i + 1, Generate: func(ctx context.Context) (*ImageRecord, error) {
+			atomic.AddInt32(&calls, 1)
+			cancel()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}}
+	}
+
+	results := pool.Run(ctx, jobs)
+	if calls != 1 {
+		t.Errorf("取消后不应再执行新任务, 实际执行 %d 个", calls)
+	}
+	for _, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("取消后任务应返回取消错误: %+v", result)
+		}
+	}
+}
+
+// TestWorkerPoolConcurrentDrawThingsClient 多个任务并发使用同一个DrawThings客户端，
+// 部分请求连接被断开，客户端会并发更新API可用状态；需配合 go test -race 运行
+func TestWorkerPoolConcurrentDrawThingsClient(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sdapi/v1/txt2img" {
+			return
+		}
+		if atomic.AddInt32(&requests, 1)%3 == 0 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+		image := base64.StdEncoding.EncodeToString([]byte("png"))
+		json.NewEncoder(w).Encode(Txt2ImgResponse{Images: []string{image}, Info: `{"seed": 1}`})
+	}))
+	defer server.Close()
+
+	client := NewDrawThingsClient(zap.NewNop(), server.URL)
+	backend := NewA1111Backend(client)
+	pool := NewImageWorkerPool(zap.NewNop(), 4, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	pool.SetBackendLimit(BackendDrawThings, BackendLimit{}) // max_concurrent 为0表示不限并发
+
+	dir := t.TempDir()
+	jobs := make([]ImageJob, 8)
+	for i := range jobs {
+		file := filepath.Join(dir, fmt.Sprintf("scene_%02d.png", i+1))
+		jobs[i] = ImageJob{Index: i + 1, Backend: backend.Name(), Generate: func(ctx context.Context) (*ImageRecord, error) {
+			return GenerateWithBackend(ctx, backend, Txt2ImgRequest{Prompt: "雨夜", Width: 64, Height: 64, Seed: 1}, file)
+		}}
+	}
+
+	for _, result := range pool.Run(context.Background(), jobs) {
+		if result.Err != nil {
+			t.Errorf("第%d张生成失败: %v", result.Index, result.Err)
+		}
+	}
+	if !backend.Available() {
+		t.Errorf("服务仍在运行, 客户端应恢复为可用")
+	}
+}
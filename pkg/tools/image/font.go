@@ -0,0 +1,104 @@
+package image
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/spf13/viper"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+// defaultCJKFontPaths 常见系统自带的中文字体，image.font_path 未配置或加载失败时依次尝试
+var defaultCJKFontPaths = []string{
+	"/System/Library/Fonts/PingFang.ttc",                        // macOS
+	"/System/Library/Fonts/STHeiti Medium.ttc",                  // macOS
+	"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",    // Debian/Ubuntu fonts-noto-cjk
+	"/usr/share/fonts/noto-cjk/NotoSansCJK-Regular.ttc",         // Arch/Fedora
+	"/usr/share/fonts/google-noto-cjk/NotoSansCJK-Regular.ttc",  // Fedora
+	"/usr/share/fonts/truetype/wqy/wqy-zenhei.ttc",              // Debian/Ubuntu fonts-wqy-zenhei
+	"/usr/share/fonts/wenquanyi/wqy-zenhei/wqy-zenhei.ttc",      // Arch
+	"/usr/share/fonts/truetype/droid/DroidSansFallbackFull.ttf", // Debian/Ubuntu fonts-droid-fallback
+	"C:\\Windows\\Fonts\\msyh.ttc",                              // Windows 微软雅黑
+	"C:\\Windows\\Fonts\\simhei.ttf",                            // Windows 黑体
+}
+
+var (
+	fontCacheMu sync.Mutex
+	fontCache   = make(map[string]*opentype.Font)
+
+	// fallbackFont 内置的Go字体，不含中文字形，仅在没有任何可用字体时使用
+	fallbackFont     *opentype.Font
+	fallbackFontOnce sync.Once
+)
+
+// FontCandidates 返回按优先级排列的字体路径：
+// preferred（不为空时）、image.font_path、image.font_fallbacks，最后是常见系统中文字体
+func FontCandidates(preferred string) []string {
+	var candidates []string
+	if preferred != "" {
+		candidates = append(candidates, preferred)
+	}
+	if path := viper.GetString("image.font_path"); path != "" {
+		candidates = append(candidates, path)
+	}
+	candidates = append(candidates, viper.GetStringSlice("image.font_fallbacks")...)
+	return append(candidates, defaultCJKFontPaths...)
+}
+
+// LoadFont 加载TTF/OTF字体或TTC/OTC字体集中的第index个字体，加载结果按路径缓存
+func LoadFont(path string, index int) (*opentype.Font, error) {
+	key := fmt.Sprintf("%s#%d", path, index)
+	fontCacheMu.Lock()
+	defer fontCacheMu.Unlock()
+	if f, ok := fontCache[key]; ok {
+		return f, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取字体文件失败: %v", err)
+	}
+	// 单个字体会被解析为只有一个字体的字体集
+	collection, err := opentype.ParseCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析字体文件失败: %v", err)
+	}
+	if index < 0 || index >= collection.NumFonts() {
+		index = 0
+	}
+	f, err := collection.Font(index)
+	if err != nil {
+		return nil, fmt.Errorf("读取字体集中的字体失败: %v", err)
+	}
+	fontCache[key] = f
+	return f, nil
+}
+
+// NewFontFace 按 FontCandidates 的顺序加载第一个可用的字体，返回字体和实际使用的路径；
+// 都不可用时返回不含中文字形的内置Go字体和错误
+func NewFontFace(preferred string, size float64) (font.Face, string, error) {
+	index := viper.GetInt("image.font_index")
+	for _, path := range FontCandidates(preferred) {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		f, err := LoadFont(path, index)
+		if err != nil {
+			continue
+		}
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			continue
+		}
+		return face, path, nil
+	}
+
+	fallbackFontOnce.Do(func() {
+		fallbackFont, _ = opentype.Parse(goregular.TTF)
+	})
+	face, _ := opentype.NewFace(fallbackFont, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	return face, "", fmt.Errorf("没有可用的中文字体，请在 image.font_path 中配置字体文件路径")
+}
@@ -5,16 +5,11 @@ import (
 	"fmt"
 	"image/color"
 	"math/rand"
-	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/fogleman/gg"
-	"github.com/golang/freetype/truetype"
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
-	"golang.org/x/image/font"
 )
 
 // ImageGenerator 图片生成器
@@ -142,26 +137,7 @@ func (ig *ImageGenerator) generateImage(prompt, outputFile string) error {
 	dc.SetRGB(1, 1, 1) // 白色文字
 
 	// 尝试加载字体
-	var font font.Face
-	fontPath := viper.GetString("image.font_path")
-	if fontPath != "" {
-		if fontBytes, err := os.ReadFile(fontPath); err == nil {
-			if parsedFont, err := truetype.Parse(fontBytes); err == nil {
-				font = truetype.NewFace(parsedFont, &truetype.Options{
-					Size: fontSize,
-				})
-				dc.SetFontFace(font)
-			}
-		}
-	}
-
-	if font == nil {
-		// 如果没有加载特定字体，使用默认字体
-		dc.LoadFontFace("/System/Library/Fonts/PingFang.ttc", fontSize) // macOS
-		if !strings.Contains(outputFile, "PingFang") {
-			// 如果上面的字体加载失败，尝试其他常见字体路径
-		}
-	}
+	loadFontFace(dc, fontSize)
 
 	// 计算文字尺寸并居中
 	w, h := dc.MeasureString(prompt)
@@ -183,6 +159,14 @@ func (ig *ImageGenerator) generateImage(prompt, outputFile string) error {
 	return dc.SavePNG(outputFile)
 }
 
+// loadFontFace 按 image.font_path、image.font_fallbacks 和常见系统中文字体的顺序加载字体，
+// 均失败时使用不含中文字形的内置字体
+func loadFontFace(dc *gg.Context, fontSize float64) bool {
+	face, _, err := NewFontFace("", fontSize)
+	dc.SetFontFace(face)
+	return err == nil
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -0,0 +1,93 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"math/rand"
+
+	"github.com/fogleman/gg"
+)
+
+// PlaceholderOptions 离线占位图参数
+type PlaceholderOptions struct {
+	Prompt string
+	Index  int // 分镜序号，<=0时不绘制
+	Width  int
+	Height int
+	Seed   int64
+}
+
+// RenderPlaceholderPNG 绘制包含提示词和分镜序号的占位图。
+// 相同参数总是得到相同的PNG，用于离线运行和测试完整流程
+func RenderPlaceholderPNG(opts PlaceholderOptions) ([]byte, error) {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return nil, fmt.Errorf("占位图尺寸无效: %dx%d", opts.Width, opts.Height)
+	}
+
+	width, height := float64(opts.Width), float64(opts.Height)
+	rng := rand.New(rand.NewSource(opts.Seed))
+	dc := gg.NewContext(opts.Width, opts.Height)
+
+	// 按种子确定的纵向渐变背景
+	top := color.RGBA{R: uint8(rng.Intn(96)), G: uint8(rng.Intn(96)), B: uint8(rng.Intn(96)), A: 255}
+	bottom := color.RGBA{R: uint8(rng.Intn(64)), G: uint8(rng.Intn(64)), B: uint8(rng.Intn(64)), A: 255}
+	gradient := gg.NewLinearGradient(0, 0, 0, height)
+	gradient.AddColorStop(0, top)
+	gradient.AddColorStop(1, bottom)
+	dc.SetFillStyle(gradient)
+	dc.DrawRectangle(0, 0, width, height)
+	dc.Fill()
+
+	// 分镜序号
+	if opts.Index > 0 {
+		loadFontFace(dc, height/8)
+		dc.SetRGBA(1, 1, 1, 0.85)
+		dc.DrawStringAnchored(fmt.Sprintf("#%02d", opts.Index), width/2, height/4, 0.5, 0.5)
+	}
+
+	// 提示词按宽度逐字换行，中文没有空格无法按单词换行
+	fontSize := width / 18
+	loadFontFace(dc, fontSize)
+	lines := wrapRunes(dc, opts.Prompt, width*0.85)
+	lineHeight := fontSize * 1.5
+	y := height/2 - lineHeight*float64(len(lines)-1)/2
+	dc.SetRGB(1, 1, 1)
+	for _, line := range lines {
+		if y > height-lineHeight {
+			break
+		}
+		dc.DrawStringAnchored(line, width/2, y, 0.5, 0.5)
+		y += lineHeight
+	}
+
+	var buf bytes.Buffer
+	if err := dc.EncodePNG(&buf); err != nil {
+		return nil, fmt.Errorf("编码占位图失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// wrapRunes 按字符将文本折成不超过maxWidth的多行
+func wrapRunes(dc *gg.Context, text string, maxWidth float64) []string {
+	var lines []string
+	var current []rune
+	for _, r := range text {
+		if r == '\n' {
+			lines = append(lines, string(current))
+			current = nil
+			continue
+		}
+		candidate := append(current, r)
+		if w, _ := dc.MeasureString(string(candidate)); w > maxWidth && len(current) > 0 {
+			lines = append(lines, string(current))
+			current = []rune{r}
+			continue
+		}
+		current = candidate
+	}
+	if len(current) > 0 {
+		lines = append(lines, string(current))
+	}
+	return lines
+}
@@ -0,0 +1,86 @@
+package imageproc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// Decode 解码PNG/JPEG图像数据
+func Decode(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解码图像失败: %v", err)
+	}
+	return img, nil
+}
+
+// LoadImage 读取并解码图像文件
+func LoadImage(path string) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取图像文件失败: %v", err)
+	}
+	return Decode(data)
+}
+
+// EncodePNG 将图像编码为PNG
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("编码PNG失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SavePNG 将图像保存为PNG文件，自动创建输出目录
+func SavePNG(img image.Image, path string) error {
+	data, err := EncodePNG(img)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("保存图像文件失败: %v", err)
+	}
+	return nil
+}
+
+// FitFile 将图像文件适配到画布并保存为PNG，inputPath 与 outputPath 可以相同
+func FitFile(inputPath, outputPath string, opts Options) (Transform, error) {
+	img, err := LoadImage(inputPath)
+	if err != nil {
+		return Transform{}, err
+	}
+	fitted, transform, err := Fit(img, opts)
+	if err != nil {
+		return Transform{}, err
+	}
+	if err := SavePNG(fitted, outputPath); err != nil {
+		return Transform{}, err
+	}
+	return transform, nil
+}
+
+// FitBytes 将编码后的图像数据适配到画布，返回PNG数据
+func FitBytes(data []byte, opts Options) ([]byte, Transform, error) {
+	img, err := Decode(data)
+	if err != nil {
+		return nil, Transform{}, err
+	}
+	fitted, transform, err := Fit(img, opts)
+	if err != nil {
+		return nil, Transform{}, err
+	}
+	out, err := EncodePNG(fitted)
+	if err != nil {
+		return nil, Transform{}, err
+	}
+	return out, transform, nil
+}
@@ -0,0 +1,392 @@
+// Package imageproc 将生成的图像适配到视频画布：高质量缩放、按显著性裁剪到目标比例，
+// 裁剪损失过大时改为模糊放大的背景填充，并记录实际应用的变换
+package imageproc
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// 适配方式
+const (
+	ModeAuto      = "auto"      // 裁剪损失不超过 MaxCropLoss 时裁剪，否则模糊背景填充
+	ModeCrop      = "crop"      // 始终裁剪到目标比例
+	ModeLetterbox = "letterbox" // 始终完整保留画面，空白处用模糊放大的背景填充
+	ModeResize    = "resize"    // 比例一致，仅缩放
+)
+
+// 裁剪锚点
+const (
+	AnchorCenter   = "center"   // 居中裁剪
+	AnchorSaliency = "saliency" // 按画面细节（边缘能量）加权选择裁剪位置
+)
+
+// 默认参数
+const (
+	DefaultMaxCropLoss   = 0.2
+	DefaultBackgroundDim = 0.3
+)
+
+// Options 画布适配参数
+type Options struct {
+	Width         int     // 画布宽度
+	Height        int     // 画布高度
+	Mode          string  // 适配方式，为空时为 auto
+	Anchor        string  // 裁剪锚点，为空时为 saliency
+	MaxCropLoss   float64 // auto 模式下允许裁掉的最大画面比例，<=0时使用默认值
+	BlurRadius    int     // 背景模糊半径（画布像素），<=0时按画布短边的1/30
+	BackgroundDim float64 // 背景压暗比例 0-1，<0时不压暗，0时使用默认值
+}
+
+// Rect 源图中的矩形区域
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Transform 实际应用的画布变换，写入生成清单以便追溯和复现
+type Transform struct {
+	Mode         string  `json:"mode"` // 实际应用的方式: crop / letterbox / resize
+	Anchor       string  `json:"anchor,omitempty"`
+	SourceWidth  int     `json:"source_width"`
+	SourceHeight int     `json:"source_height"`
+	TargetWidth  int     `json:"target_width"`
+	TargetHeight int     `json:"target_height"`
+	Crop         *Rect   `json:"crop,omitempty"`        // 裁剪时源图中保留的区域
+	Scale        float64 `json:"scale"`                 // 前景相对源图（或裁剪区域）的缩放倍数
+	OffsetX      int     `json:"offset_x,omitempty"`    // 填充时前景在画布中的位置
+	OffsetY      int     `json:"offset_y,omitempty"`    // 填充时前景在画布中的位置
+	CropLoss     float64 `json:"crop_loss"`             // 裁剪到目标比例会损失的画面比例
+	BlurRadius   int     `json:"blur_radius,omitempty"` // 填充时背景的模糊半径
+}
+
+func (o Options) withDefaults() Options {
+	if o.Mode == "" {
+		o.Mode = ModeAuto
+	}
+	if o.Anchor == "" {
+		o.Anchor = AnchorSaliency
+	}
+	if o.MaxCropLoss <= 0 {
+		o.MaxCropLoss = DefaultMaxCropLoss
+	}
+	if o.BlurRadius <= 0 {
+		o.BlurRadius = minInt(o.Width, o.Height) / 30
+		if o.BlurRadius < 1 {
+			o.BlurRadius = 1
+		}
+	}
+	if o.BackgroundDim == 0 {
+		o.BackgroundDim = DefaultBackgroundDim
+	}
+	return o
+}
+
+// Validate 检查画布参数
+func (o Options) Validate() error {
+	if o.Width <= 0 || o.Height <= 0 {
+		return fmt.Errorf("画布尺寸无效: %dx%d", o.Width, o.Height)
+	}
+	switch o.Mode {
+	case "", ModeAuto, ModeCrop, ModeLetterbox:
+	default:
+		return fmt.Errorf("不支持的适配方式: %s，可选: auto, crop, letterbox", o.Mode)
+	}
+	switch o.Anchor {
+	case "", AnchorCenter, AnchorSaliency:
+	default:
+		return fmt.Errorf("不支持的裁剪锚点: %s，可选: center, saliency", o.Anchor)
+	}
+	return nil
+}
+
+// CropLoss 计算将 srcW x srcH 的图像裁剪到 dstW:dstH 比例时损失的画面比例
+func CropLoss(srcW, srcH, dstW, dstH int) float64 {
+	if srcW <= 0 || srcH <= 0 || dstW <= 0 || dstH <= 0 {
+		return 0
+	}
+	srcAspect := float64(srcW) / float64(srcH)
+	dstAspect := float64(dstW) / float64(dstH)
+	if srcAspect > dstAspect {
+		return 1 - dstAspect/srcAspect
+	}
+	return 1 - srcAspect/dstAspect
+}
+
+// Fit 将图像适配到画布尺寸，返回适配后的图像和实际应用的变换
+func Fit(src image.Image, opts Options) (*image.RGBA, Transform, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, Transform{}, err
+	}
+	opts = opts.withDefaults()
+
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW == 0 || srcH == 0 {
+		return nil, Transform{}, fmt.Errorf("源图像为空")
+	}
+
+	transform := Transform{
+		SourceWidth:  srcW,
+		SourceHeight: srcH,
+		TargetWidth:  opts.Width,
+		TargetHeight: opts.Height,
+		CropLoss:     round(CropLoss(srcW, srcH, opts.Width, opts.Height), 4),
+	}
+
+	// 比例一致（按高度缩放后宽度误差不超过1个像素）时直接缩放
+	if math.Abs(float64(srcW)*float64(opts.Height)/float64(srcH)-float64(opts.Width)) <= 1 {
+		transform.Mode = ModeResize
+		transform.Scale = round(float64(opts.Width)/float64(srcW), 4)
+		return Resize(src, opts.Width, opts.Height), transform, nil
+	}
+
+	mode := opts.Mode
+	if mode == ModeAuto {
+		if transform.CropLoss <= opts.MaxCropLoss {
+			mode = ModeCrop
+		} else {
+			mode = ModeLetterbox
+		}
+	}
+
+	if mode == ModeCrop {
+		crop := CropRect(src, opts.Width, opts.Height, opts.Anchor)
+		transform.Mode = ModeCrop
+		transform.Anchor = opts.Anchor
+		transform.Crop = &crop
+		transform.Scale = round(float64(opts.Width)/float64(crop.Width), 4)
+		cropped := image.Rect(bounds.Min.X+crop.X, bounds.Min.Y+crop.Y, bounds.Min.X+crop.X+crop.Width, bounds.Min.Y+crop.Y+crop.Height)
+		dst := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, cropped, draw.Src, nil)
+		return dst, transform, nil
+	}
+
+	dst, x, y, scale := letterbox(src, opts)
+	transform.Mode = ModeLetterbox
+	transform.Scale = round(scale, 4)
+	transform.OffsetX = x
+	transform.OffsetY = y
+	transform.BlurRadius = opts.BlurRadius
+	return dst, transform, nil
+}
+
+// Resize 使用 Catmull-Rom 插值缩放到指定尺寸
+func Resize(src image.Image, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// CropRect 计算裁剪到 width:height 比例时在源图中保留的区域。
+// saliency 锚点沿需要裁剪的方向滑动窗口，选择边缘能量最高的位置，并向中心略微偏置，
+// 避免细节均匀的画面被裁到边缘
+func CropRect(src image.Image, width, height int, anchor string) Rect {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	cropW, cropH := srcW, srcH
+	horizontal := float64(srcW)/float64(srcH) > float64(width)/float64(height)
+	if horizontal {
+		cropW = int(math.Round(float64(srcH) * float64(width) / float64(height)))
+	} else {
+		cropH = int(math.Round(float64(srcW) * float64(height) / float64(width)))
+	}
+	cropW = clampInt(cropW, 1, srcW)
+	cropH = clampInt(cropH, 1, srcH)
+
+	rect := Rect{X: (srcW - cropW) / 2, Y: (srcH - cropH) / 2, Width: cropW, Height: cropH}
+	if anchor == AnchorCenter {
+		return rect
+	}
+
+	profile := energyProfile(src, horizontal)
+	if horizontal {
+		rect.X = bestWindow(profile, cropW)
+	} else {
+		rect.Y = bestWindow(profile, cropH)
+	}
+	return rect
+}
+
+// energyProfile 计算沿裁剪方向每一列（或每一行）的边缘能量之和
+func energyProfile(src image.Image, horizontal bool) []float64 {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	gray := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gray[y*w+x] = luminance(src.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+
+	size := h
+	if horizontal {
+		size = w
+	}
+	profile := make([]float64, size)
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			gx := gray[y*w+x+1] - gray[y*w+x-1]
+			gy := gray[(y+1)*w+x] - gray[(y-1)*w+x]
+			energy := math.Abs(gx) + math.Abs(gy)
+			if horizontal {
+				profile[x] += energy
+			} else {
+				profile[y] += energy
+			}
+		}
+	}
+	return profile
+}
+
+// bestWindow 返回长度为 window 的窗口中能量最高的起点，按与中心的距离衰减
+func bestWindow(profile []float64, window int) int {
+	size := len(profile)
+	if window >= size {
+		return 0
+	}
+
+	center := float64(size-window) / 2
+	var sum, total float64
+	for i, v := range profile {
+		if i < window {
+			sum += v
+		}
+		total += v
+	}
+	if total == 0 {
+		return int(center)
+	}
+
+	best, bestScore := int(center), -1.0
+	for start := 0; start <= size-window; start++ {
+		if start > 0 {
+			sum += profile[start+window-1] - profile[start-1]
+		}
+		// 距离中心越远得分越低，最多降低10%
+		score := sum * (1 - 0.1*math.Abs(float64(start)-center)/center)
+		if score > bestScore {
+			best, bestScore = start, score
+		}
+	}
+	return best
+}
+
+// letterbox 完整保留画面并居中，空白处用放大、模糊、压暗后的同一图像填充
+func letterbox(src image.Image, opts Options) (*image.RGBA, int, int, float64) {
+	bounds := src.Bounds()
+	srcW, srcH := float64(bounds.Dx()), float64(bounds.Dy())
+	width, height := float64(opts.Width), float64(opts.Height)
+
+	// 背景：按覆盖画布的比例放大后居中裁剪；在1/4分辨率上模糊以降低开销
+	const factor = 4
+	smallW, smallH := maxInt(opts.Width/factor, 1), maxInt(opts.Height/factor, 1)
+	cover := CropRect(src, opts.Width, opts.Height, AnchorCenter)
+	coverRect := image.Rect(bounds.Min.X+cover.X, bounds.Min.Y+cover.Y, bounds.Min.X+cover.X+cover.Width, bounds.Min.Y+cover.Y+cover.Height)
+	small := image.NewRGBA(image.Rect(0, 0, smallW, smallH))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), src, coverRect, draw.Src, nil)
+	small = BoxBlur(small, maxInt(opts.BlurRadius/factor, 1), 3)
+	if opts.BackgroundDim > 0 {
+		dim(small, opts.BackgroundDim)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	draw.BiLinear.Scale(dst, dst.Bounds(), small, small.Bounds(), draw.Src, nil)
+
+	// 前景：等比缩放到画布内
+	scale := math.Min(width/srcW, height/srcH)
+	fgW := clampInt(int(math.Round(srcW*scale)), 1, opts.Width)
+	fgH := clampInt(int(math.Round(srcH*scale)), 1, opts.Height)
+	x := (opts.Width - fgW) / 2
+	y := (opts.Height - fgH) / 2
+	draw.CatmullRom.Scale(dst, image.Rect(x, y, x+fgW, y+fgH), src, bounds, draw.Over, nil)
+	return dst, x, y, scale
+}
+
+// BoxBlur 对图像做多次盒式模糊，三次迭代近似高斯模糊
+func BoxBlur(src *image.RGBA, radius, passes int) *image.RGBA {
+	if radius <= 0 || passes <= 0 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	cur := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(cur, cur.Bounds(), src, src.Bounds().Min, draw.Src)
+	tmp := image.NewRGBA(cur.Bounds())
+	for i := 0; i < passes; i++ {
+		blurLine(cur.Pix, tmp.Pix, w, h, radius, 4, cur.Stride)
+		blurLine(tmp.Pix, cur.Pix, h, w, radius, cur.Stride, 4)
+	}
+	return cur
+}
+
+// blurLine 沿一个方向做滑动平均；step 为沿模糊方向相邻像素的字节间距，lineStep 为相邻行的字节间距。
+// 边缘按复制边界像素处理
+func blurLine(in, out []uint8, length, lines, radius, step, lineStep int) {
+	window := float64(2*radius + 1)
+	for line := 0; line < lines; line++ {
+		base := line * lineStep
+		for c := 0; c < 4; c++ {
+			at := func(i int) float64 {
+				return float64(in[base+clampInt(i, 0, length-1)*step+c])
+			}
+			var sum float64
+			for i := -radius; i <= radius; i++ {
+				sum += at(i)
+			}
+			for i := 0; i < length; i++ {
+				out[base+i*step+c] = uint8(math.Round(sum / window))
+				sum += at(i+radius+1) - at(i-radius)
+			}
+		}
+	}
+}
+
+func dim(img *image.RGBA, amount float64) {
+	keep := 1 - math.Min(math.Max(amount, 0), 1)
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i] = uint8(float64(img.Pix[i]) * keep)
+		img.Pix[i+1] = uint8(float64(img.Pix[i+1]) * keep)
+		img.Pix[i+2] = uint8(float64(img.Pix[i+2]) * keep)
+	}
+}
+
+func luminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 65535
+}
+
+func round(v float64, digits int) float64 {
+	p := math.Pow(10, float64(digits))
+	return math.Round(v*p) / p
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
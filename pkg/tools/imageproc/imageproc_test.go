@@ -0,0 +1,151 @@
+package imageproc
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// newTestImage 创建纯色图像，detail 区域内绘制黑白棋盘格作为画面细节
+func newTestImage(width, height int, detail image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{R: 90, G: 90, B: 90, A: 255}
+			if (image.Point{X: x, Y: y}).In(detail) && (x/2+y/2)%2 == 0 {
+				c = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+			} else if (image.Point{X: x, Y: y}).In(detail) {
+				c = color.RGBA{A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestCropLoss(t *testing.T) {
+	if loss := CropLoss(512, 896, 1080, 1920); math.Abs(loss-0.0156) > 0.001 {
+		t.Errorf("512x896 -> 1080x1920 裁剪损失错误: %v", loss)
+	}
+	if loss := CropLoss(1024, 1024, 1080, 1920); math.Abs(loss-0.4375) > 0.001 {
+		t.Errorf("方图 -> 竖屏 裁剪损失错误: %v", loss)
+	}
+	if loss := CropLoss(1080, 1920, 540, 960); loss != 0 {
+		t.Errorf("比例相同时不应有损失: %v", loss)
+	}
+}
+
+func TestFitAutoChoosesCropOrLetterbox(t *testing.T) {
+	opts := Options{Width: 108, Height: 192}
+
+	img, transform, err := Fit(newTestImage(100, 175, image.Rect(0, 0, 0, 0)), opts)
+	if err != nil {
+		t.Fatalf("适配失败: %v", err)
+	}
+	if transform.Mode != ModeCrop || transform.Crop == nil {
+		t.Errorf("比例接近时应裁剪: %+v", transform)
+	}
+	if img.Bounds().Dx() != 108 || img.Bounds().Dy() != 192 {
+		t.Errorf("输出尺寸错误: %v", img.Bounds())
+	}
+
+	img, transform, err = Fit(newTestImage(120, 120, image.Rect(0, 0, 0, 0)), opts)
+	if err != nil {
+		t.Fatalf("适配失败: %v", err)
+	}
+	if transform.Mode != ModeLetterbox || transform.OffsetY == 0 || transform.OffsetX != 0 {
+		t.Errorf("比例差异大时应模糊填充: %+v", transform)
+	}
+	if img.Bounds().Dx() != 108 || img.Bounds().Dy() != 192 {
+		t.Errorf("输出尺寸错误: %v", img.Bounds())
+	}
+
+	_, transform, _ = Fit(newTestImage(54, 96, image.Rect(0, 0, 0, 0)), opts)
+	if transform.Mode != ModeResize || transform.Scale != 2 {
+		t.Errorf("比例相同时应直接缩放: %+v", transform)
+	}
+}
+
+func TestSaliencyCropFollowsDetail(t *testing.T) {
+	// 横图右侧有细节，竖屏裁剪应偏向右侧
+	src := newTestImage(200, 100, image.Rect(150, 20, 195, 80))
+
+	center := CropRect(src, 50, 100, AnchorCenter)
+	if center.X != 75 || center.Width != 50 || center.Height != 100 {
+		t.Errorf("居中裁剪区域错误: %+v", center)
+	}
+
+	saliency := CropRect(src, 50, 100, AnchorSaliency)
+	if saliency.X <= center.X || saliency.X+saliency.Width > 200 {
+		t.Errorf("显著性裁剪应偏向细节所在的右侧: %+v", saliency)
+	}
+
+	// 没有细节时回到居中
+	if flat := CropRect(newTestImage(200, 100, image.Rect(0, 0, 0, 0)), 50, 100, AnchorSaliency); flat.X != center.X {
+		t.Errorf("无细节时应居中裁剪: %+v", flat)
+	}
+}
+
+func TestFitCropModeKeepsForeground(t *testing.T) {
+	_, transform, err := Fit(newTestImage(120, 120, image.Rect(0, 0, 0, 0)), Options{Width: 108, Height: 192, Mode: ModeCrop, Anchor: AnchorCenter})
+	if err != nil {
+		t.Fatalf("适配失败: %v", err)
+	}
+	if transform.Mode != ModeCrop || transform.Crop.Width != 68 || transform.Crop.Height != 120 {
+		t.Errorf("强制裁剪的区域错误: %+v", transform.Crop)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	if err := (Options{Width: 0, Height: 10}).Validate(); err == nil {
+		t.Errorf("画布尺寸为0应报错")
+	}
+	if err := (Options{Width: 10, Height: 10, Mode: "stretch"}).Validate(); err == nil {
+		t.Errorf("不支持的适配方式应报错")
+	}
+}
+
+func TestLuminanceAndDifferenceHash(t *testing.T) {
+	black := newTestImage(64, 64, image.Rect(0, 0, 0, 0))
+	for i := range black.Pix {
+		if i%4 != 3 {
+			black.Pix[i] = 0
+		}
+	}
+	if stats := MeasureLuminance(black); stats.Mean != 0 || stats.StdDev != 0 {
+		t.Errorf("纯黑图像亮度统计错误: %+v", stats)
+	}
+
+	detailed := newTestImage(300, 300, image.Rect(20, 20, 280, 150))
+	if stats := MeasureLuminance(detailed); stats.StdDev < 0.1 {
+		t.Errorf("有细节的图像标准差过低: %+v", stats)
+	}
+
+	// 缩放后的同一图像哈希和缩略图接近，内容不同的图像差异大
+	blocks := func(flip bool) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 300, 300))
+		for y := 0; y < 300; y++ {
+			for x := 0; x < 300; x++ {
+				v := uint8(x * 255 / 300)
+				if (x/75+y/100)%2 == 1 != flip {
+					v = 255 - v
+				}
+				img.Set(x, y, color.RGBA{R: v, G: v / 2, B: 80, A: 255})
+			}
+		}
+		return img
+	}
+	src, flipped := blocks(false), blocks(true)
+	same := HashDistance(DifferenceHash(src), DifferenceHash(Resize(src, 150, 150)))
+	other := HashDistance(DifferenceHash(src), DifferenceHash(flipped))
+	if same > 4 || other <= same {
+		t.Errorf("感知哈希距离不合理: 相同 %d, 不同 %d", same, other)
+	}
+	if d := ThumbnailDistance(Thumbnail(src), Thumbnail(Resize(src, 150, 150))); d > 0.02 {
+		t.Errorf("缩放后的缩略图差异过大: %v", d)
+	}
+	if d := ThumbnailDistance(Thumbnail(src), Thumbnail(flipped)); d < 0.1 {
+		t.Errorf("不同图像的缩略图差异过小: %v", d)
+	}
+}
@@ -0,0 +1,107 @@
+package imageproc
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+
+	"golang.org/x/image/draw"
+)
+
+// LuminanceStats 图像亮度统计，取值范围0-1
+type LuminanceStats struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+}
+
+// MeasureLuminance 统计图像亮度的均值和标准差，大图先缩小到不超过256像素边长再统计
+func MeasureLuminance(img image.Image) LuminanceStats {
+	small := shrink(img, 256)
+	bounds := small.Bounds()
+
+	var sum, sumSq float64
+	count := float64(bounds.Dx() * bounds.Dy())
+	if count == 0 {
+		return LuminanceStats{}
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			l := luminance(small.At(x, y))
+			sum += l
+			sumSq += l * l
+		}
+	}
+
+	mean := sum / count
+	variance := sumSq/count - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	return LuminanceStats{Mean: round(mean, 4), StdDev: round(math.Sqrt(variance), 4)}
+}
+
+// DifferenceHash 计算64位差值感知哈希（dHash）：缩小为9x8灰度图，比较相邻像素的明暗。
+// 内容相近的图像哈希的汉明距离很小，用于检测重复画面
+func DifferenceHash(img image.Image) uint64 {
+	small := image.NewRGBA(image.Rect(0, 0, 9, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if luminance(small.At(x, y)) > luminance(small.At(x+1, y)) {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Thumbnail 将图像缩小为8x8的RGB缩略图（192字节），用于比较整体构图和颜色
+func Thumbnail(img image.Image) []uint8 {
+	small := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	thumb := make([]uint8, 0, 8*8*3)
+	for i := 0; i < len(small.Pix); i += 4 {
+		thumb = append(thumb, small.Pix[i], small.Pix[i+1], small.Pix[i+2])
+	}
+	return thumb
+}
+
+// ThumbnailDistance 两个缩略图的平均绝对差，取值范围0-1
+func ThumbnailDistance(a, b []uint8) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 1
+	}
+	var sum float64
+	for i := range a {
+		sum += math.Abs(float64(a[i]) - float64(b[i]))
+	}
+	return sum / float64(len(a)) / 255
+}
+
+// HashDistance 两个感知哈希的汉明距离
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// FormatHash 将感知哈希格式化为16位十六进制字符串
+func FormatHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// shrink 等比缩小到最长边不超过maxSide，已足够小时原样返回
+func shrink(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+	scale := float64(maxSide) / float64(maxInt(w, h))
+	dst := image.NewRGBA(image.Rect(0, 0, maxInt(int(float64(w)*scale), 1), maxInt(int(float64(h)*scale), 1)))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,169 @@
+// Package subtitle 提供字幕条目的读写以及基于本地大模型的字幕翻译
+package subtitle
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Cue 字幕条目
+type Cue struct {
+	ID    int    `json:"id"`
+	Start int64  `json:"start"` // 微秒
+	End   int64  `json:"end"`   // 微秒
+	Text  string `json:"text"`
+}
+
+// ParseSrtTime 解析SRT时间格式(00:00:01,500)为微秒
+func ParseSrtTime(timeStr string) (int64, error) {
+	timeStr = strings.TrimSpace(strings.ReplaceAll(timeStr, ".", ","))
+	timeParts := strings.Split(timeStr, ",")
+	if len(timeParts) != 2 {
+		return 0, fmt.Errorf("无效的SRT时间格式: %s", timeStr)
+	}
+
+	hms := strings.Split(timeParts[0], ":")
+	if len(hms) != 3 {
+		return 0, fmt.Errorf("无效的SRT时间格式: %s", timeStr)
+	}
+
+	values := make([]int, 4)
+	for i, part := range append(hms, timeParts[1]) {
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("无效的SRT时间格式: %s", timeStr)
+		}
+		values[i] = v
+	}
+
+	return int64(values[0]*3600+values[1]*60+values[2])*1000000 + int64(values[3])*1000, nil
+}
+
+// FormatSrtTime 将微秒格式化为SRT时间(00:00:01,500)
+func FormatSrtTime(micros int64) string {
+	return formatTime(micros, ",")
+}
+
+// FormatVttTime 将微秒格式化为WebVTT时间(00:00:01.500)
+func FormatVttTime(micros int64) string {
+	return formatTime(micros, ".")
+}
+
+func formatTime(micros int64, sep string) string {
+	if micros < 0 {
+		micros = 0
+	}
+	totalMs := micros / 1000
+	hours := totalMs / 3600000
+	minutes := (totalMs % 3600000) / 60000
+	seconds := (totalMs % 60000) / 1000
+	ms := totalMs % 1000
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", hours, minutes, seconds, sep, ms)
+}
+
+// ParseSrtFile 解析SRT字幕文件
+func ParseSrtFile(filePath string) ([]Cue, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("打开字幕文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var cues []Cue
+	scanner := bufio.NewScanner(file)
+
+	current := Cue{}
+	state := 0 // 0: ID, 1: 时间, 2: 文本
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+
+		if line == "" {
+			if current.ID != 0 {
+				cues = append(cues, current)
+				current = Cue{}
+				state = 0
+			}
+			continue
+		}
+
+		switch state {
+		case 0:
+			id, err := strconv.Atoi(line)
+			if err != nil {
+				continue
+			}
+			current.ID = id
+			state = 1
+		case 1:
+			timeParts := strings.Split(line, " --> ")
+			if len(timeParts) == 2 {
+				start, err1 := ParseSrtTime(timeParts[0])
+				end, err2 := ParseSrtTime(timeParts[1])
+				if err1 == nil && err2 == nil {
+					current.Start = start
+					current.End = end
+				}
+			}
+			state = 2
+		case 2:
+			if current.Text == "" {
+				current.Text = line
+			} else {
+				current.Text += "\n" + line
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取字幕文件失败: %v", err)
+	}
+
+	if current.ID != 0 {
+		cues = append(cues, current)
+	}
+
+	return cues, nil
+}
+
+// WriteSrtFile 将字幕条目写入SRT文件
+func WriteSrtFile(filePath string, cues []Cue) error {
+	var sb strings.Builder
+	for i, cue := range cues {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n", cue.ID, FormatSrtTime(cue.Start), FormatSrtTime(cue.End), cue.Text)
+	}
+	return writeFile(filePath, sb.String())
+}
+
+// WriteVttFile 将字幕条目写入WebVTT文件，条目标识沿用SRT序号以便对齐
+func WriteVttFile(filePath string, cues []Cue) error {
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&sb, "\n%d\n%s --> %s\n%s\n", cue.ID, FormatVttTime(cue.Start), FormatVttTime(cue.End), cue.Text)
+	}
+	return writeFile(filePath, sb.String())
+}
+
+func writeFile(filePath, content string) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("创建字幕目录失败: %v", err)
+	}
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("写入字幕文件失败: %v", err)
+	}
+	return nil
+}
+
+// TranslatedFileName 返回译文字幕文件路径，如 chapter_01.srt -> chapter_01.en.srt
+func TranslatedFileName(srtPath, language, ext string) string {
+	base := strings.TrimSuffix(srtPath, filepath.Ext(srtPath))
+	return fmt.Sprintf("%s.%s%s", base, language, ext)
+}
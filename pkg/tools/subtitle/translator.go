@@ -0,0 +1,297 @@
+package subtitle
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"novel-video-workflow/pkg/broadcast"
+	"novel-video-workflow/pkg/tools/drawthings"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// languageNames 常用目标语言代码与提示词中使用的语言名称
+var languageNames = map[string]string{
+	"en": "English",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"es": "Spanish",
+	"fr": "French",
+	"de": "German",
+	"ru": "Russian",
+	"pt": "Portuguese",
+	"vi": "Vietnamese",
+	"th": "Thai",
+	"id": "Indonesian",
+}
+
+// SubtitleTranslator 使用Ollama分批翻译字幕，保持字幕序号一一对应
+type SubtitleTranslator struct {
+	OllamaClient     *drawthings.OllamaClient
+	Logger           *zap.Logger
+	TargetLanguage   string // 目标语言代码，如 en
+	BatchSize        int    // 每批翻译的字幕条数
+	ContextSize      int    // 每批前后附带的上下文条数（仅供参考，不翻译）
+	MaxRetries       int    // 批次中缺失条目的重试次数
+	BroadcastService *broadcast.BroadcastService
+}
+
+// TranslationResult 字幕翻译结果
+type TranslationResult struct {
+	SourceFile   string `json:"source_file"`
+	SrtFile      string `json:"srt_file"`
+	VttFile      string `json:"vtt_file"`
+	Language     string `json:"language"`
+	CueCount     int    `json:"cue_count"`
+	Untranslated []int  `json:"untranslated"` // 多次重试后仍未得到译文的字幕序号，保留原文
+}
+
+// translatedCue 模型返回的单条译文
+type translatedCue struct {
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+}
+
+// NewSubtitleTranslator 创建字幕翻译器，未指定的参数从 subtitle.translation 配置读取
+func NewSubtitleTranslator(logger *zap.Logger, ollamaClient *drawthings.OllamaClient, targetLanguage string) *SubtitleTranslator {
+	if targetLanguage == "" {
+		targetLanguage = viper.GetString("subtitle.translation.target_language")
+	}
+	if targetLanguage == "" {
+		targetLanguage = "en"
+	}
+
+	batchSize := viper.GetInt("subtitle.translation.batch_size")
+	if batchSize <= 0 {
+		batchSize = 20
+	}
+
+	contextSize := 3
+	if viper.IsSet("subtitle.translation.context_size") {
+		contextSize = viper.GetInt("subtitle.translation.context_size")
+		if contextSize < 0 {
+			contextSize = 0
+		}
+	}
+
+	maxRetries := viper.GetInt("subtitle.translation.max_retries")
+	if maxRetries <= 0 {
+		maxRetries = 2
+	}
+
+	if ollamaClient == nil {
+		ollamaClient = drawthings.NewOllamaClientFromConfig(logger)
+	}
+
+	return &SubtitleTranslator{
+		OllamaClient:     ollamaClient,
+		Logger:           logger,
+		TargetLanguage:   targetLanguage,
+		BatchSize:        batchSize,
+		ContextSize:      contextSize,
+		MaxRetries:       maxRetries,
+		BroadcastService: broadcast.NewBroadcastService(),
+	}
+}
+
+// SendMsg 广播翻译进度，非阻塞发送，广播服务未启动（如MCP模式、命令行）时不会卡住翻译
+func (t *SubtitleTranslator) SendMsg(text string) {
+	t.BroadcastService.TrySendMessage("translate", text, broadcast.GetTimeStr())
+}
+
+// TranslateSrtFile 翻译SRT字幕文件，在同目录写出 <name>.<lang>.srt 和 <name>.<lang>.vtt
+func (t *SubtitleTranslator) TranslateSrtFile(srtFile string) (*TranslationResult, error) {
+	cues, err := ParseSrtFile(srtFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("字幕文件中没有可翻译的条目: %s", srtFile)
+	}
+
+	translated, untranslated, err := t.TranslateCues(cues)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TranslationResult{
+		SourceFile:   srtFile,
+		SrtFile:      TranslatedFileName(srtFile, t.TargetLanguage, ".srt"),
+		VttFile:      TranslatedFileName(srtFile, t.TargetLanguage, ".vtt"),
+		Language:     t.TargetLanguage,
+		CueCount:     len(translated),
+		Untranslated: untranslated,
+	}
+
+	if err := WriteSrtFile(result.SrtFile, translated); err != nil {
+		return nil, err
+	}
+	if err := WriteVttFile(result.VttFile, translated); err != nil {
+		return nil, err
+	}
+
+	t.Logger.Info("字幕翻译完成",
+		zap.String("source", srtFile),
+		zap.String("srt", result.SrtFile),
+		zap.String("vtt", result.VttFile),
+		zap.Int("untranslated", len(untranslated)))
+	t.SendMsg(fmt.Sprintf("字幕翻译完成: %s", result.SrtFile))
+
+	return result, nil
+}
+
+// TranslateCues 分批翻译字幕条目，返回与输入时间轴和序号完全一致的译文条目，
+// 以及多次重试后仍未翻译、保留原文的字幕序号
+func (t *SubtitleTranslator) TranslateCues(cues []Cue) ([]Cue, []int, error) {
+	translations := make(map[int]string, len(cues))
+	var untranslated []int
+
+	batchSize := t.BatchSize
+	if batchSize <= 0 {
+		batchSize = 20
+	}
+
+	for start := 0; start < len(cues); start += batchSize {
+		end := start + batchSize
+		if end > len(cues) {
+			end = len(cues)
+		}
+
+		t.SendMsg(fmt.Sprintf("正在翻译字幕 %d-%d/%d", start+1, end, len(cues)))
+
+		pending := cues[start:end]
+		for attempt := 0; attempt <= t.MaxRetries && len(pending) > 0; attempt++ {
+			got, err := t.translateBatch(pending, t.contextBefore(cues, start), t.contextAfter(cues, end))
+			if err != nil {
+				t.Logger.Warn("字幕批次翻译失败",
+					zap.Int("batch_start", start),
+					zap.Int("attempt", attempt+1),
+					zap.Error(err))
+				continue
+			}
+
+			var missing []Cue
+			for _, cue := range pending {
+				if text, ok := got[cue.ID]; ok {
+					translations[cue.ID] = text
+				} else {
+					missing = append(missing, cue)
+				}
+			}
+			pending = missing
+		}
+
+		for _, cue := range pending {
+			untranslated = append(untranslated, cue.ID)
+		}
+	}
+
+	if len(translations) == 0 {
+		return nil, nil, fmt.Errorf("字幕翻译失败: 未获得任何译文")
+	}
+
+	result := make([]Cue, len(cues))
+	for i, cue := range cues {
+		result[i] = cue
+		if text, ok := translations[cue.ID]; ok {
+			result[i].Text = text
+		}
+	}
+
+	if len(untranslated) > 0 {
+		t.Logger.Warn("部分字幕未能翻译，保留原文", zap.Ints("ids", untranslated))
+	}
+
+	return result, untranslated, nil
+}
+
+func (t *SubtitleTranslator) contextBefore(cues []Cue, start int) []Cue {
+	from := start - t.ContextSize
+	if from < 0 {
+		from = 0
+	}
+	return cues[from:start]
+}
+
+func (t *SubtitleTranslator) contextAfter(cues []Cue, end int) []Cue {
+	to := end + t.ContextSize
+	if to > len(cues) {
+		to = len(cues)
+	}
+	return cues[end:to]
+}
+
+// translateBatch 翻译一批字幕，返回序号到译文的映射
+func (t *SubtitleTranslator) translateBatch(batch, before, after []Cue) (map[int]string, error) {
+	language := languageNames[t.TargetLanguage]
+	if language == "" {
+		language = t.TargetLanguage
+	}
+
+	systemPrompt := fmt.Sprintf(`你是一名专业的影视字幕译者，负责把中文小说解说视频的字幕翻译成%s。
+要求：
+1. 逐条翻译，每条字幕的id必须与输入保持一致，不得合并、拆分、遗漏或新增条目
+2. 译文要口语化、简洁，适合作为字幕阅读，保持人名、地名在全片中的译法一致
+3. "上文"和"下文"仅用于理解语境，不要翻译它们
+4. 只返回JSON数组，格式如：[{"id": 1, "text": "译文"}]，不要添加任何解释`, language)
+
+	userPrompt := fmt.Sprintf("上文：\n%s\n\n需要翻译的字幕：\n%s\n\n下文：\n%s",
+		formatContext(before), formatBatch(batch), formatContext(after))
+
+	response, err := t.OllamaClient.Generate(systemPrompt, userPrompt, map[string]interface{}{
+		"temperature":    0.3,
+		"top_p":          0.9,
+		"repeat_penalty": 1.1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTranslationResponse(response)
+}
+
+func formatBatch(batch []Cue) string {
+	items := make([]translatedCue, len(batch))
+	for i, cue := range batch {
+		items[i] = translatedCue{ID: cue.ID, Text: cue.Text}
+	}
+	data, _ := json.MarshalIndent(items, "", "  ")
+	return string(data)
+}
+
+func formatContext(cues []Cue) string {
+	if len(cues) == 0 {
+		return "（无）"
+	}
+	lines := make([]string, len(cues))
+	for i, cue := range cues {
+		lines[i] = strings.ReplaceAll(cue.Text, "\n", " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseTranslationResponse 从模型响应中提取JSON数组并按序号建立映射
+func parseTranslationResponse(response string) (map[int]string, error) {
+	jsonStart := strings.Index(response, "[")
+	jsonEnd := strings.LastIndex(response, "]")
+	if jsonStart == -1 || jsonEnd <= jsonStart {
+		return nil, fmt.Errorf("响应中未找到JSON数组")
+	}
+
+	var items []translatedCue
+	if err := json.Unmarshal([]byte(response[jsonStart:jsonEnd+1]), &items); err != nil {
+		return nil, fmt.Errorf("解析翻译结果失败: %v", err)
+	}
+
+	result := make(map[int]string, len(items))
+	for _, item := range items {
+		text := strings.TrimSpace(item.Text)
+		if item.ID == 0 || text == "" {
+			continue
+		}
+		result[item.ID] = text
+	}
+	return result, nil
+}
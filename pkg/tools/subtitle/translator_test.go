@@ -0,0 +1,183 @@
+package subtitle
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"novel-video-workflow/pkg/tools/drawthings"
+
+	"go.uber.org/zap"
+)
+
+// newFakeOllama 模拟Ollama服务，把需要翻译的每条字幕译为 "EN:<原文>"，并跳过 skipID 对应的条目
+func newFakeOllama(t *testing.T, skipID int, calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/tags" {
+			json.NewEncoder(w).Encode(drawthings.OllamaTagsResponse{Models: []drawthings.OllamaModel{{Name: "test:latest"}}})
+			return
+		}
+		*calls++
+		var req drawthings.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("解析请求失败: %v", err)
+			return
+		}
+
+		// 只解析"需要翻译的字幕"部分，上下文不应出现在结果中
+		prompt := req.Messages[len(req.Messages)-1].Content
+		section := prompt[strings.Index(prompt, "需要翻译的字幕：")+len("需要翻译的字幕：") : strings.Index(prompt, "下文：")]
+		var items []translatedCue
+		if err := json.Unmarshal([]byte(strings.TrimSpace(section)), &items); err != nil {
+			t.Errorf("解析批次失败: %v", err)
+			return
+		}
+
+		var out []translatedCue
+		for _, item := range items {
+			if item.ID == skipID {
+				continue
+			}
+			out = append(out, translatedCue{ID: item.ID, Text: "EN:" + item.Text})
+		}
+		data, _ := json.Marshal(out)
+		json.NewEncoder(w).Encode(drawthings.ChatResponse{Message: drawthings.AssistantMessage("好的，译文如下：\n" + string(data)), Done: true})
+	}))
+}
+
+func testCues(n int) []Cue {
+	cues := make([]Cue, n)
+	for i := range cues {
+		cues[i] = Cue{ID: i + 1, Start: int64(i) * 2000000, End: int64(i+1) * 2000000, Text: fmt.Sprintf("第%d句", i+1)}
+	}
+	return cues
+}
+
+func TestTranslateCuesKeepsIDsAligned(t *testing.T) {
+	calls := 0
+	server := newFakeOllama(t, 0, &calls)
+	defer server.Close()
+
+	translator := NewSubtitleTranslator(zap.NewNop(), drawthings.NewOllamaClient(zap.NewNop(), server.URL, "test"), "en")
+	translator.BatchSize = 4
+
+	cues := testCues(10)
+	result, untranslated, err := translator.TranslateCues(cues)
+	if err != nil {
+		t.Fatalf("翻译失败: %v", err)
+	}
+	if len(untranslated) != 0 {
+		t.Errorf("不应有未翻译条目: %v", untranslated)
+	}
+	if calls != 3 {
+		t.Errorf("期望3个批次, 实际 %d", calls)
+	}
+	for i, cue := range result {
+		if cue.ID != cues[i].ID || cue.Start != cues[i].Start || cue.End != cues[i].End {
+			t.Errorf("第%d条时间轴或序号不一致: %+v", i, cue)
+		}
+		if cue.Text != "EN:"+cues[i].Text {
+			t.Errorf("第%d条译文错误: %s", i, cue.Text)
+		}
+	}
+}
+
+func TestTranslateCuesMissingCueKeepsOriginal(t *testing.T) {
+	calls := 0
+	server := newFakeOllama(t, 2, &calls)
+	defer server.Close()
+
+	translator := NewSubtitleTranslator(zap.NewNop(), drawthings.NewOllamaClient(zap.NewNop(), server.URL, "test"), "en")
+	translator.BatchSize = 5
+	translator.MaxRetries = 1
+
+	result, untranslated, err := translator.TranslateCues(testCues(3))
+	if err != nil {
+		t.Fatalf("翻译失败: %v", err)
+	}
+	if len(untranslated) != 1 || untranslated[0] != 2 {
+		t.Errorf("期望序号2未翻译, 实际 %v", untranslated)
+	}
+	if result[1].Text != "第2句" {
+		t.Errorf("未翻译条目应保留原文, 实际 %s", result[1].Text)
+	}
+	// 首次请求 + 1次针对缺失条目的重试
+	if calls != 2 {
+		t.Errorf("期望2次请求, 实际 %d", calls)
+	}
+}
+
+func TestTranslateCuesDoesNotBlockWithoutBroadcastClients(t *testing.T) {
+	calls := 0
+	server := newFakeOllama(t, 0, &calls)
+	defer server.Close()
+
+	// 广播服务未启动时没有人读取通道，进度消息超过通道容量也不应阻塞翻译
+	translator := NewSubtitleTranslator(zap.NewNop(), drawthings.NewOllamaClient(zap.NewNop(), server.URL, "test"), "en")
+	translator.BatchSize = 1
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := translator.TranslateCues(testCues(120))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("翻译失败: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("翻译被广播消息阻塞")
+	}
+}
+
+func TestTranslateSrtFileWritesSrtAndVtt(t *testing.T) {
+	calls := 0
+	server := newFakeOllama(t, 0, &calls)
+	defer server.Close()
+
+	dir := t.TempDir()
+	srtFile := filepath.Join(dir, "chapter_01.srt")
+	if err := WriteSrtFile(srtFile, testCues(2)); err != nil {
+		t.Fatalf("写入SRT失败: %v", err)
+	}
+
+	translator := NewSubtitleTranslator(zap.NewNop(), drawthings.NewOllamaClient(zap.NewNop(), server.URL, "test"), "en")
+	result, err := translator.TranslateSrtFile(srtFile)
+	if err != nil {
+		t.Fatalf("翻译字幕文件失败: %v", err)
+	}
+
+	if result.SrtFile != filepath.Join(dir, "chapter_01.en.srt") {
+		t.Errorf("译文SRT路径错误: %s", result.SrtFile)
+	}
+
+	parsed, err := ParseSrtFile(result.SrtFile)
+	if err != nil || len(parsed) != 2 || parsed[1].Text != "EN:第2句" || parsed[1].Start != 2000000 {
+		t.Errorf("译文SRT内容错误: %+v, %v", parsed, err)
+	}
+
+	vtt, err := os.ReadFile(result.VttFile)
+	if err != nil {
+		t.Fatalf("读取VTT失败: %v", err)
+	}
+	if !strings.HasPrefix(string(vtt), "WEBVTT\n") || !strings.Contains(string(vtt), "00:00:02.000 --> 00:00:04.000\nEN:第2句") {
+		t.Errorf("VTT内容错误:\n%s", vtt)
+	}
+}
+
+func TestFormatSrtTime(t *testing.T) {
+	if got := FormatSrtTime(3723456000); got != "01:02:03,456" {
+		t.Errorf("FormatSrtTime = %s", got)
+	}
+	micros, err := ParseSrtTime("01:02:03,456")
+	if err != nil || micros != 3723456000 {
+		t.Errorf("ParseSrtTime = %d, %v", micros, err)
+	}
+}
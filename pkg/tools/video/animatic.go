@@ -0,0 +1,465 @@
+package video
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"novel-video-workflow/pkg/timeline"
+	imagepkg "novel-video-workflow/pkg/tools/image"
+	"novel-video-workflow/pkg/tools/imageproc"
+
+	"github.com/fogleman/gg"
+	"github.com/spf13/viper"
+	"golang.org/x/image/font"
+)
+
+// 分镜预览的输出格式
+const (
+	AnimaticGIF = "gif" // 单个动图
+	AnimaticPNG = "png" // PNG 序列，文件名为 frame_00001.png 起
+)
+
+// 默认值
+const (
+	defaultAnimaticWidth = 240
+	defaultAnimaticFPS   = 6
+	maxAnimaticFPS       = 25 // GIF 帧间隔以百分之一秒计，太小时浏览器会按0.1秒播放
+
+	// 预加载图片时相对预览画布放大的倍数，放大和平移时保持清晰
+	animaticSupersample = 2
+	// animaticFramePattern PNG 序列的文件名格式
+	animaticFramePattern = "frame_%05d.png"
+)
+
+// AnimaticOptions 分镜预览选项，对应 video.animatic 配置
+type AnimaticOptions struct {
+	Format   string // gif 或 png，默认 gif
+	Width    int    // 预览宽度，高度按时间线画布比例换算，默认 240
+	FPS      int    // 预览帧率，默认 6
+	FontPath string // 字幕字体，为空时使用 image.font_path 和系统中文字体
+}
+
+// AnimaticOptionsFromConfig 读取 video.animatic 配置
+func AnimaticOptionsFromConfig() AnimaticOptions {
+	return AnimaticOptions{
+		Format:   viper.GetString("video.animatic.format"),
+		Width:    viper.GetInt("video.animatic.width"),
+		FPS:      viper.GetInt("video.animatic.fps"),
+		FontPath: viper.GetString("image.font_path"),
+	}
+}
+
+// withDefaults 补全未配置的选项
+func (o AnimaticOptions) withDefaults() AnimaticOptions {
+	o.Format = strings.ToLower(strings.TrimSpace(o.Format))
+	if o.Format == "" {
+		o.Format = AnimaticGIF
+	}
+	if o.Width <= 0 {
+		o.Width = defaultAnimaticWidth
+	}
+	if o.FPS <= 0 {
+		o.FPS = defaultAnimaticFPS
+	}
+	if o.FPS > maxAnimaticFPS {
+		o.FPS = maxAnimaticFPS
+	}
+	return o
+}
+
+// AnimaticShot 预览中的一个镜头，供网页在进度条上标出切换点
+type AnimaticShot struct {
+	Name  string `json:"name"`
+	Start int64  `json:"start"` // 微秒
+	Frame int    `json:"frame"` // 镜头开始的预览帧，从0开始
+}
+
+// Animatic 分镜预览的索引，与预览文件一起写入 <名称>.json。
+// 路径都是相对索引文件所在目录的文件名，网页按帧率和旁白的播放位置换算当前帧
+type Animatic struct {
+	Format   string         `json:"format"`
+	Path     string         `json:"path"`              // GIF 文件或 PNG 序列目录
+	Pattern  string         `json:"pattern,omitempty"` // PNG 序列的文件名格式
+	Audio    string         `json:"audio,omitempty"`   // 旁白音频
+	Width    int            `json:"width"`
+	Height   int            `json:"height"`
+	FPS      int            `json:"fps"`
+	Frames   int            `json:"frames"`
+	Duration int64          `json:"duration"` // 微秒
+	Shots    []AnimaticShot `json:"shots"`
+	Font     string         `json:"font,omitempty"` // 实际使用的字体文件，为空表示没有找到中文字体
+}
+
+// AnimaticIndexPath 预览索引文件的路径：输出去掉扩展名后加 .json
+func AnimaticIndexPath(output string) string {
+	return strings.TrimSuffix(output, filepath.Ext(output)) + ".json"
+}
+
+// animaticShot 图片轨道上一个片段的可见区间（微秒），转场跨在切换点两侧
+type animaticShot struct {
+	clip         *timeline.Clip
+	visibleStart int64
+	visibleEnd   int64
+	image        *image.RGBA // 铺满预览画布后放大 animaticSupersample 倍的画面，视频片段为nil
+}
+
+// animaticRenderer 逐帧绘制分镜预览
+type animaticRenderer struct {
+	tl            *timeline.Timeline
+	opts          AnimaticOptions
+	width, height int
+	shots         []*animaticShot
+	faces         map[int]font.Face
+	fontUsed      string
+}
+
+// RenderAnimatic 不依赖剪映和 ffmpeg，用 gg 把时间线绘制成低分辨率的分镜预览：
+// 图片按运动关键帧缩放平移，首尾相接且带转场的图片之间交叉淡化，叠加字幕和镜头编号、时间码。
+// output 为 GIF 文件路径或 PNG 序列目录，同时写入索引文件 AnimaticIndexPath(output)
+func RenderAnimatic(tl *timeline.Timeline, output string, opts AnimaticOptions) (*Animatic, error) {
+	if err := tl.Validate(); err != nil {
+		return nil, err
+	}
+	opts = opts.withDefaults()
+	if opts.Format != AnimaticGIF && opts.Format != AnimaticPNG {
+		return nil, fmt.Errorf("不支持的预览格式: %s，可选: %s, %s", opts.Format, AnimaticGIF, AnimaticPNG)
+	}
+
+	duration := timelineEnd(tl)
+	if duration <= 0 {
+		return nil, fmt.Errorf("时间线为空")
+	}
+	r := newAnimaticRenderer(tl, opts)
+	if err := r.loadImages(); err != nil {
+		return nil, err
+	}
+	frames := int(math.Ceil(float64(duration) * float64(opts.FPS) / 1e6))
+
+	result := &Animatic{
+		Format:   opts.Format,
+		Path:     filepath.Base(output),
+		Width:    r.width,
+		Height:   r.height,
+		FPS:      opts.FPS,
+		Frames:   frames,
+		Duration: duration,
+	}
+	if narration := tl.TrackByRole(timeline.RoleNarration); narration != nil && len(narration.Clips) > 0 {
+		result.Audio = relativeTo(filepath.Dir(output), narration.Clips[0].Path)
+	}
+	for _, s := range r.shots {
+		result.Shots = append(result.Shots, AnimaticShot{
+			Name:  s.clip.Name,
+			Start: s.clip.Target.Start,
+			Frame: int(s.clip.Target.Start * int64(opts.FPS) / 1e6),
+		})
+	}
+
+	sink, err := newAnimaticSink(output, opts.Format, r.width, r.height)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < frames; i++ {
+		// 帧间隔按累计时间取整，避免百分之一秒的误差累积
+		delay := int(math.Round(float64(i+1)*100/float64(opts.FPS)) - math.Round(float64(i)*100/float64(opts.FPS)))
+		if err := sink.WriteFrame(r.frame(int64(i)*1000000/int64(opts.FPS)), delay); err != nil {
+			sink.Close()
+			return nil, err
+		}
+	}
+	if err := sink.Close(); err != nil {
+		return nil, err
+	}
+	if opts.Format == AnimaticPNG {
+		result.Pattern = animaticFramePattern
+	}
+	result.Font = r.fontUsed
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化预览索引失败: %w", err)
+	}
+	if err := os.WriteFile(AnimaticIndexPath(output), data, 0644); err != nil {
+		return nil, fmt.Errorf("写入预览索引失败: %w", err)
+	}
+	return result, nil
+}
+
+// newAnimaticRenderer 按预览宽度换算画布尺寸，整理图片轨道上各片段的可见区间
+func newAnimaticRenderer(tl *timeline.Timeline, opts AnimaticOptions) *animaticRenderer {
+	height := int(math.Round(float64(opts.Width) * float64(tl.Height) / float64(tl.Width)))
+	r := &animaticRenderer{tl: tl, opts: opts, width: opts.Width, height: max(height, 1), faces: make(map[int]font.Face)}
+
+	track := tl.TrackByRole(timeline.RoleImages)
+	if track == nil || track.Kind != timeline.TrackVideo {
+		track = nil
+		if videos := tl.TracksOf(timeline.TrackVideo); len(videos) > 0 {
+			track = videos[0]
+		}
+	}
+	if track == nil {
+		return r
+	}
+	for _, c := range track.Clips {
+		if c.Target.Duration > 0 {
+			r.shots = append(r.shots, &animaticShot{clip: c, visibleStart: c.Target.Start, visibleEnd: c.Target.End()})
+		}
+	}
+
+	// 与渲染计划一致：转场跨在切换点两侧，不能与前一个转场重叠，也不能超出下一张的显示区间
+	soloStart := int64(0)
+	for i, s := range r.shots {
+		if i == 0 || r.shots[i-1].visibleEnd == r.shots[i-1].clip.Target.End() {
+			soloStart = s.clip.Target.Start
+		}
+		c := s.clip
+		if c.Transition == nil || c.Transition.Duration <= 0 || i+1 >= len(r.shots) {
+			continue
+		}
+		next := r.shots[i+1]
+		if next.clip.Target.Start != c.Target.End() || c.Media != timeline.MediaImage || next.clip.Media != timeline.MediaImage {
+			continue
+		}
+		start := c.Target.End() - c.Transition.Duration/2
+		if start < soloStart || start+c.Transition.Duration > next.clip.Target.End() {
+			continue
+		}
+		s.visibleEnd = start + c.Transition.Duration
+		next.visibleStart = start
+		soloStart = s.visibleEnd
+	}
+	return r
+}
+
+// loadImages 预加载图片片段，居中裁剪到画布比例后缩放，同一文件只加载一次
+func (r *animaticRenderer) loadImages() error {
+	loaded := make(map[string]*image.RGBA)
+	w, h := r.width*animaticSupersample, r.height*animaticSupersample
+	for _, s := range r.shots {
+		if s.clip.Media != timeline.MediaImage {
+			continue
+		}
+		if img, ok := loaded[s.clip.Path]; ok {
+			s.image = img
+			continue
+		}
+		src, err := imageproc.LoadImage(s.clip.Path)
+		if err != nil {
+			return fmt.Errorf("加载分镜图片失败 %s: %v", filepath.Base(s.clip.Path), err)
+		}
+		crop := imageproc.CropRect(src, w, h, imageproc.AnchorCenter)
+		bounds := src.Bounds()
+		area := image.Rect(crop.X, crop.Y, crop.X+crop.Width, crop.Y+crop.Height).Add(bounds.Min)
+		s.image = imageproc.Resize(subImage(src, area), w, h)
+		loaded[s.clip.Path] = s.image
+	}
+	return nil
+}
+
+// frame 绘制 at（微秒）时刻的预览画面
+func (r *animaticRenderer) frame(at int64) *image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, r.width, r.height))
+	draw.Draw(canvas, canvas.Bounds(), image.Black, image.Point{}, draw.Src)
+
+	var visible []*animaticShot
+	for _, s := range r.shots {
+		if at >= s.visibleStart && at < s.visibleEnd {
+			visible = append(visible, s)
+		}
+	}
+	current := 0
+	for i, s := range visible {
+		layer := r.shotLayer(s, at)
+		if i == 0 {
+			draw.Draw(canvas, canvas.Bounds(), layer, image.Point{}, draw.Over)
+			continue
+		}
+		// 转场期间下一张按进度叠加在上一张之上
+		prev := visible[i-1]
+		progress := float64(at-s.visibleStart) / float64(prev.visibleEnd-s.visibleStart)
+		alpha := uint8(math.Round(math.Max(0, math.Min(1, progress)) * 255))
+		draw.DrawMask(canvas, canvas.Bounds(), layer, image.Point{}, image.NewUniform(color.Alpha{A: alpha}), image.Point{}, draw.Over)
+		current = i
+	}
+
+	dc := gg.NewContextForRGBA(canvas)
+	for _, tr := range r.tl.TracksOf(timeline.TrackText) {
+		for _, cue := range tr.Cues {
+			if at >= cue.Target.Start && at < cue.Target.End() {
+				r.drawCue(dc, cue.Text, tr.Style)
+				break
+			}
+		}
+	}
+	label := animaticTimecode(at)
+	if len(visible) > 0 {
+		label = fmt.Sprintf("#%02d  %s", r.shotIndex(visible[current])+1, label)
+	}
+	r.drawInfo(dc, label)
+	return canvas
+}
+
+// shotLayer 按运动关键帧绘制一个镜头：缩放以画布中心为基准，位移单位为半个画布、右移和上移为正
+func (r *animaticRenderer) shotLayer(s *animaticShot, at int64) *image.RGBA {
+	layer := image.NewRGBA(image.Rect(0, 0, r.width, r.height))
+	dc := gg.NewContextForRGBA(layer)
+	width, height := float64(r.width), float64(r.height)
+	if s.image == nil {
+		// 纯 Go 无法解码视频，用片段名称占位
+		dc.SetRGB(0.15, 0.15, 0.18)
+		dc.Clear()
+		r.setFont(dc, 0.04)
+		dc.SetRGB(0.7, 0.7, 0.7)
+		dc.DrawStringAnchored(s.clip.Name, width/2, height/2, 0.5, 0.5)
+		return layer
+	}
+
+	offset := at - s.clip.Target.Start
+	scale := s.clip.Motion.ValueAt(timeline.PropertyScale, offset, 1)
+	x := s.clip.Motion.ValueAt(timeline.PropertyPositionX, offset, 0)
+	y := s.clip.Motion.ValueAt(timeline.PropertyPositionY, offset, 0)
+	dc.Translate(width/2+x*width/2, height/2-y*height/2)
+	dc.Scale(scale/animaticSupersample, scale/animaticSupersample)
+	dc.DrawImageAnchored(s.image, 0, 0, 0.5, 0.5)
+	return layer
+}
+
+// drawCue 绘制一条字幕：按样式的字号和垂直位置，超宽时按字符换行，最后一行的中心落在样式位置
+func (r *animaticRenderer) drawCue(dc *gg.Context, text string, style *timeline.TextStyle) {
+	if style == nil {
+		style = &timeline.TextStyle{Size: 0.025, Color: "#FFFFFF", PositionY: -0.8}
+	}
+	size := r.setFont(dc, style.Size)
+	width, height := float64(r.width), float64(r.height)
+	lines := wrapRunes(dc, text, width*0.9)
+	lineHeight := size * 1.3
+	center := height/2 - style.PositionY*height/2
+	top := center - lineHeight*float64(len(lines)-1)
+	for i, line := range lines {
+		y := top + lineHeight*float64(i)
+		drawOutlined(dc, line, width/2, y, 0.5, math.Max(1, size/12), parseHexColor(style.Color))
+	}
+}
+
+// drawInfo 在左上角绘制镜头编号和时间码
+func (r *animaticRenderer) drawInfo(dc *gg.Context, label string) {
+	size := r.setFont(dc, 0.02)
+	drawOutlined(dc, label, size*0.6, size*1.1, 0, 1, color.White)
+}
+
+// setFont 按画布高度的比例设置字号，返回像素字号；预览分辨率很低，字号不小于9像素
+func (r *animaticRenderer) setFont(dc *gg.Context, ratio float64) float64 {
+	size := int(math.Max(9, math.Round(ratio*float64(r.height))))
+	face, ok := r.faces[size]
+	if !ok {
+		var path string
+		face, path, _ = imagepkg.NewFontFace(r.opts.FontPath, float64(size))
+		if path != "" {
+			r.fontUsed = path
+		}
+		r.faces[size] = face
+	}
+	dc.SetFontFace(face)
+	return float64(size)
+}
+
+// shotIndex 镜头在图片轨道中的序号
+func (r *animaticRenderer) shotIndex(shot *animaticShot) int {
+	for i, s := range r.shots {
+		if s == shot {
+			return i
+		}
+	}
+	return 0
+}
+
+// drawOutlined 绘制带黑色描边的文字，ax 为水平锚点（0左对齐，0.5居中）
+func drawOutlined(dc *gg.Context, text string, x, y, ax, stroke float64, c color.Color) {
+	dc.SetRGBA(0, 0, 0, 0.85)
+	for dy := -stroke; dy <= stroke; dy++ {
+		for dx := -stroke; dx <= stroke; dx++ {
+			if dx != 0 || dy != 0 {
+				dc.DrawStringAnchored(text, x+dx, y+dy, ax, 0.5)
+			}
+		}
+	}
+	dc.SetColor(c)
+	dc.DrawStringAnchored(text, x, y, ax, 0.5)
+}
+
+// wrapRunes 按字符将文本折成不超过 maxWidth 的多行，中文没有空格无法按单词换行
+func wrapRunes(dc *gg.Context, text string, maxWidth float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.TrimSpace(text), "\n") {
+		var current []rune
+		for _, r := range paragraph {
+			candidate := append(current, r)
+			if w, _ := dc.MeasureString(string(candidate)); w > maxWidth && len(current) > 0 {
+				lines = append(lines, string(current))
+				current = []rune{r}
+				continue
+			}
+			current = candidate
+		}
+		if len(current) > 0 {
+			lines = append(lines, string(current))
+		}
+	}
+	return lines
+}
+
+// parseHexColor 解析 #RRGGBB，失败时为白色
+func parseHexColor(hex string) color.Color {
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(hex, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return color.White
+	}
+	return color.RGBA{R: r, G: g, B: b, A: 255}
+}
+
+// animaticTimecode 微秒换算为 MM:SS.d
+func animaticTimecode(us int64) string {
+	ds := us / 100000
+	return fmt.Sprintf("%02d:%02d.%d", ds/600, ds/10%60, ds%10)
+}
+
+// timelineEnd 时间线时长，片段超出 Duration 时以最后一个片段的结束为准
+func timelineEnd(tl *timeline.Timeline) int64 {
+	end := tl.Duration
+	for _, tr := range tl.Tracks {
+		for _, c := range tr.Clips {
+			end = max(end, c.Target.End())
+		}
+		for _, cue := range tr.Cues {
+			end = max(end, cue.Target.End())
+		}
+	}
+	return end
+}
+
+// subImage 截取图像的一部分，不支持截取的图像类型先复制为 RGBA
+func subImage(src image.Image, area image.Rectangle) image.Image {
+	if sub, ok := src.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(area)
+	}
+	rgba := image.NewRGBA(area)
+	draw.Draw(rgba, area, src, area.Min, draw.Src)
+	return rgba
+}
+
+// relativeTo 返回 path 相对 dir 的路径，无法换算时返回原路径
+func relativeTo(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
@@ -0,0 +1,170 @@
+package video
+
+import (
+	"bufio"
+	"compress/lzw"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"io"
+	"os"
+	"path/filepath"
+
+	"novel-video-workflow/pkg/tools/imageproc"
+)
+
+// animaticSink 逐帧写出预览，delay 为该帧的显示时长（百分之一秒）
+type animaticSink interface {
+	WriteFrame(frame *image.RGBA, delay int) error
+	Close() error
+}
+
+// newAnimaticSink 按格式创建输出：GIF 写入单个文件，PNG 序列写入目录（会先清空旧的序列）
+func newAnimaticSink(output, format string, width, height int) (animaticSink, error) {
+	if format == AnimaticPNG {
+		if err := os.RemoveAll(output); err != nil {
+			return nil, fmt.Errorf("清理旧的预览序列失败: %w", err)
+		}
+		if err := os.MkdirAll(output, 0755); err != nil {
+			return nil, fmt.Errorf("创建预览序列目录失败: %w", err)
+		}
+		return &pngSequenceSink{dir: output}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return nil, fmt.Errorf("创建预览目录失败: %w", err)
+	}
+	file, err := os.Create(output)
+	if err != nil {
+		return nil, fmt.Errorf("创建预览文件失败: %w", err)
+	}
+	sink := &gifSink{file: file, w: bufio.NewWriter(file), width: width, height: height}
+	if err := sink.writeHeader(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return sink, nil
+}
+
+// pngSequenceSink 每帧写一个 PNG 文件
+type pngSequenceSink struct {
+	dir   string
+	count int
+}
+
+func (s *pngSequenceSink) WriteFrame(frame *image.RGBA, delay int) error {
+	s.count++
+	return imageproc.SavePNG(frame, filepath.Join(s.dir, fmt.Sprintf(animaticFramePattern, s.count)))
+}
+
+func (s *pngSequenceSink) Close() error {
+	return nil
+}
+
+// gifSink 边绘制边编码的 GIF。image/gif 的 EncodeAll 需要把所有帧留在内存中，
+// 一章的预览有上千帧，这里按 GIF89a 格式逐帧写出：全局使用 Plan9 调色板，帧数据用 LZW 压缩
+type gifSink struct {
+	file          *os.File
+	w             *bufio.Writer
+	width, height int
+	paletted      *image.Paletted
+}
+
+// writeHeader 写出文件头、逻辑屏幕描述、全局调色板和循环播放扩展
+func (s *gifSink) writeHeader() error {
+	s.w.WriteString("GIF89a")
+	binary.Write(s.w, binary.LittleEndian, [2]uint16{uint16(s.width), uint16(s.height)})
+	// 0xF7: 有全局调色板，颜色深度8位，调色板大小 2^(7+1)=256
+	s.w.Write([]byte{0xF7, 0x00, 0x00})
+	for _, c := range palette.Plan9 {
+		r, g, b, _ := c.RGBA()
+		s.w.Write([]byte{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)})
+	}
+	// NETSCAPE2.0 扩展：无限循环
+	s.w.Write([]byte{0x21, 0xFF, 0x0B})
+	s.w.WriteString("NETSCAPE2.0")
+	_, err := s.w.Write([]byte{0x03, 0x01, 0x00, 0x00, 0x00})
+	return err
+}
+
+func (s *gifSink) WriteFrame(frame *image.RGBA, delay int) error {
+	if s.paletted == nil {
+		s.paletted = image.NewPaletted(image.Rect(0, 0, s.width, s.height), palette.Plan9)
+	}
+	draw.FloydSteinberg.Draw(s.paletted, s.paletted.Bounds(), frame, frame.Bounds().Min)
+
+	// 图形控制扩展：帧间隔
+	s.w.Write([]byte{0x21, 0xF9, 0x04, 0x00, uint8(delay), uint8(delay >> 8), 0x00, 0x00})
+	// 图像描述：整幅画面，不使用局部调色板
+	s.w.WriteByte(0x2C)
+	binary.Write(s.w, binary.LittleEndian, [4]uint16{0, 0, uint16(s.width), uint16(s.height)})
+	s.w.Write([]byte{0x00, 0x08}) // LZW 最小码长8位
+
+	blocks := &gifBlockWriter{w: s.w}
+	compressor := lzw.NewWriter(blocks, lzw.LSB, 8)
+	for y := 0; y < s.height; y++ {
+		row := s.paletted.Pix[y*s.paletted.Stride : y*s.paletted.Stride+s.width]
+		if _, err := compressor.Write(row); err != nil {
+			return fmt.Errorf("压缩GIF帧失败: %w", err)
+		}
+	}
+	if err := compressor.Close(); err != nil {
+		return fmt.Errorf("压缩GIF帧失败: %w", err)
+	}
+	return blocks.close()
+}
+
+func (s *gifSink) Close() error {
+	s.w.WriteByte(0x3B)
+	err := s.w.Flush()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("写入预览文件失败: %w", err)
+	}
+	return nil
+}
+
+// gifBlockWriter 把 LZW 数据切分为不超过255字节的数据子块，以长度为0的块结束
+type gifBlockWriter struct {
+	w   io.Writer
+	buf [256]byte
+	n   int
+}
+
+func (b *gifBlockWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		copied := copy(b.buf[1+b.n:], p)
+		b.n += copied
+		written += copied
+		p = p[copied:]
+		if b.n == 255 {
+			if err := b.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (b *gifBlockWriter) flush() error {
+	if b.n == 0 {
+		return nil
+	}
+	b.buf[0] = uint8(b.n)
+	_, err := b.w.Write(b.buf[:1+b.n])
+	b.n = 0
+	return err
+}
+
+func (b *gifBlockWriter) close() error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	_, err := b.w.Write([]byte{0x00})
+	return err
+}
@@ -0,0 +1,151 @@
+package video
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"novel-video-workflow/pkg/timeline"
+	"novel-video-workflow/pkg/tools/imageproc"
+)
+
+// animaticTimeline 两张纯色图片（红、蓝）之间有1秒转场，随后1秒空白
+func animaticTimeline(t *testing.T) *timeline.Timeline {
+	t.Helper()
+	dir := t.TempDir()
+	solid := func(name string, c color.RGBA) string {
+		img := image.NewRGBA(image.Rect(0, 0, 90, 160))
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+		}
+		path := filepath.Join(dir, name)
+		if err := imageproc.SavePNG(img, path); err != nil {
+			t.Fatalf("写入测试图片失败: %v", err)
+		}
+		return path
+	}
+
+	tl := timeline.New("chapter_01", 1080, 1920, 30)
+	tl.Duration = 5000000
+	images := tl.AddTrack(timeline.TrackVideo, "视频轨道", timeline.RoleImages)
+	images.AddClip(&timeline.Clip{
+		ID: "image_01", Name: "scene_01.png", Media: timeline.MediaImage, Path: solid("scene_01.png", color.RGBA{R: 255, A: 255}),
+		Source: timeline.Range{Duration: 2000000}, Target: timeline.Range{Start: 0, Duration: 2000000},
+		Motion: &timeline.Motion{Preset: "zoom_in", Keyframes: []timeline.Keyframe{
+			{Property: timeline.PropertyScale, Offset: 0, Value: 1},
+			{Property: timeline.PropertyScale, Offset: 2000000, Value: 1.2},
+		}},
+		Transition: &timeline.Transition{Name: "淡入淡出", Duration: 1000000},
+	})
+	images.AddClip(&timeline.Clip{
+		ID: "image_02", Name: "scene_02.png", Media: timeline.MediaImage, Path: solid("scene_02.png", color.RGBA{B: 255, A: 255}),
+		Source: timeline.Range{Duration: 2000000}, Target: timeline.Range{Start: 2000000, Duration: 2000000},
+	})
+	narration := tl.AddTrack(timeline.TrackAudio, "音频轨道", timeline.RoleNarration)
+	narration.AddClip(&timeline.Clip{
+		ID: "narration", Media: timeline.MediaAudio, Path: filepath.Join(dir, "chapter_01.wav"),
+		Source: timeline.Range{Duration: 5000000}, Target: timeline.Range{Duration: 5000000},
+	})
+	subtitles := tl.AddTrack(timeline.TrackText, "字幕轨道", timeline.RoleSubtitle)
+	subtitles.Cues = []timeline.TextCue{{Target: timeline.Range{Start: 0, Duration: 1000000}, Text: "Hello"}}
+	subtitles.Style = &timeline.TextStyle{Size: 0.05, Color: "#FFCC00", PositionY: -0.8}
+	return tl
+}
+
+func TestAnimaticFrameTiming(t *testing.T) {
+	tl := animaticTimeline(t)
+	r := newAnimaticRenderer(tl, AnimaticOptions{Width: 90, FPS: 4}.withDefaults())
+	if err := r.loadImages(); err != nil {
+		t.Fatalf("加载图片失败: %v", err)
+	}
+	if r.height != 160 {
+		t.Fatalf("预览高度应按画布比例换算为160，实际 %d", r.height)
+	}
+	// 转场跨在切换点两侧
+	if r.shots[0].visibleEnd != 2500000 || r.shots[1].visibleStart != 1500000 {
+		t.Fatalf("转场区间错误: %d, %d", r.shots[0].visibleEnd, r.shots[1].visibleStart)
+	}
+
+	center := func(at int64) color.RGBA {
+		return r.frame(at).RGBAAt(45, 80)
+	}
+	if c := center(500000); c.R < 250 || c.B > 5 {
+		t.Errorf("第一张应为红色，实际 %v", c)
+	}
+	if c := center(2000000); c.R < 100 || c.B < 100 {
+		t.Errorf("切换点应为红蓝混合，实际 %v", c)
+	}
+	if c := center(3000000); c.B < 250 || c.R > 5 {
+		t.Errorf("第二张应为蓝色，实际 %v", c)
+	}
+	if c := center(4500000); c.R != 0 || c.G != 0 || c.B != 0 {
+		t.Errorf("没有图片时应为黑色，实际 %v", c)
+	}
+}
+
+func TestRenderAnimaticGIF(t *testing.T) {
+	tl := animaticTimeline(t)
+	output := filepath.Join(t.TempDir(), "chapter_01_animatic.gif")
+	result, err := RenderAnimatic(tl, output, AnimaticOptions{Format: AnimaticGIF, Width: 90, FPS: 3})
+	if err != nil {
+		t.Fatalf("生成预览失败: %v", err)
+	}
+	if result.Frames != 15 || len(result.Shots) != 2 || result.Shots[1].Frame != 6 {
+		t.Fatalf("预览索引错误: %+v", result)
+	}
+	if filepath.IsAbs(result.Audio) || filepath.Base(result.Audio) != "chapter_01.wav" {
+		t.Errorf("旁白路径应相对预览目录，实际 %s", result.Audio)
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatalf("打开预览失败: %v", err)
+	}
+	defer file.Close()
+	decoded, err := gif.DecodeAll(file)
+	if err != nil {
+		t.Fatalf("解码GIF失败: %v", err)
+	}
+	if len(decoded.Image) != 15 || decoded.Config.Width != 90 || decoded.Config.Height != 160 {
+		t.Fatalf("GIF帧数或尺寸错误: %d帧 %dx%d", len(decoded.Image), decoded.Config.Width, decoded.Config.Height)
+	}
+	total := 0
+	for _, delay := range decoded.Delay {
+		total += delay
+	}
+	if total != 500 || decoded.Delay[0] != 33 || decoded.Delay[1] != 34 {
+		t.Errorf("帧间隔应累计为5秒，实际 %v", decoded.Delay)
+	}
+
+	data, err := os.ReadFile(AnimaticIndexPath(output))
+	if err != nil {
+		t.Fatalf("读取预览索引失败: %v", err)
+	}
+	var index Animatic
+	if err := json.Unmarshal(data, &index); err != nil || index.Path != "chapter_01_animatic.gif" || index.FPS != 3 {
+		t.Fatalf("预览索引内容错误: %s (%v)", data, err)
+	}
+}
+
+func TestRenderAnimaticPNG(t *testing.T) {
+	tl := animaticTimeline(t)
+	output := filepath.Join(t.TempDir(), "chapter_01_animatic")
+	if _, err := RenderAnimatic(tl, output, AnimaticOptions{Format: AnimaticPNG, Width: 90, FPS: 2}); err != nil {
+		t.Fatalf("生成预览失败: %v", err)
+	}
+	frames, _ := filepath.Glob(filepath.Join(output, "frame_*.png"))
+	if len(frames) != 10 {
+		t.Fatalf("应生成10帧，实际 %d", len(frames))
+	}
+	if _, err := os.Stat(output + ".json"); err != nil {
+		t.Fatalf("缺少预览索引: %v", err)
+	}
+
+	if _, err := RenderAnimatic(tl, output, AnimaticOptions{Format: "webm"}); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
@@ -0,0 +1,91 @@
+package video
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"novel-video-workflow/pkg/timeline"
+)
+
+// assStyleFormat ASS 样式字段顺序
+const assStyleFormat = "Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding"
+
+// buildASS 将时间线的字幕轨道转换为 ASS 字幕，每条轨道一个样式；没有字幕时返回空字符串。
+// 字幕底部居中对齐，按样式的 PositionY 换算底边距，字号为画布高度的比例
+func buildASS(tl *timeline.Timeline, font string) string {
+	var styles, events []string
+	for i, tr := range tl.TracksOf(timeline.TrackText) {
+		if len(tr.Cues) == 0 {
+			continue
+		}
+		name := tr.Role
+		if name == "" {
+			name = fmt.Sprintf("track%d", i+1)
+		}
+		styles = append(styles, assStyle(name, tr.Style, font, tl.Width, tl.Height))
+		for _, cue := range tr.Cues {
+			if cue.Target.Duration <= 0 {
+				continue
+			}
+			events = append(events, fmt.Sprintf("Dialogue: 0,%s,%s,%s,,0,0,0,,%s",
+				assTime(cue.Target.Start), assTime(cue.Target.End()), name, assText(cue.Text)))
+		}
+	}
+	if len(events) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("[Script Info]\n")
+	b.WriteString("ScriptType: v4.00+\n")
+	fmt.Fprintf(&b, "PlayResX: %d\nPlayResY: %d\n", tl.Width, tl.Height)
+	b.WriteString("WrapStyle: 0\nScaledBorderAndShadow: yes\n\n")
+	b.WriteString("[V4+ Styles]\n" + assStyleFormat + "\n")
+	for _, style := range styles {
+		b.WriteString(style + "\n")
+	}
+	b.WriteString("\n[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n")
+	for _, event := range events {
+		b.WriteString(event + "\n")
+	}
+	return b.String()
+}
+
+// assStyle 按字幕样式生成 ASS 样式行，黑色描边
+func assStyle(name string, style *timeline.TextStyle, font string, width, height int) string {
+	if style == nil {
+		style = &timeline.TextStyle{Size: 0.025, Color: "#FFFFFF", PositionY: -0.8}
+	}
+	size := math.Round(style.Size * float64(height))
+	bold := 0
+	if style.Bold {
+		bold = -1
+	}
+	center := float64(height)/2 - style.PositionY*float64(height)/2
+	marginV := int(math.Max(0, math.Round(float64(height)-center-size/2)))
+	marginH := int(math.Round(float64(width) * 0.05))
+	return fmt.Sprintf("Style: %s,%s,%s,%s,&H000000FF,&H00000000,&H80000000,%d,0,0,0,100,100,0,0,1,2,0,2,%d,%d,%d,1",
+		name, font, formatFloat(size), assColor(style.Color), bold, marginH, marginH, marginV)
+}
+
+// assColor 将 #RRGGBB 转换为 ASS 的 &HAABBGGRR，解析失败时为白色
+func assColor(hex string) string {
+	var r, g, b int
+	if _, err := fmt.Sscanf(hex, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return "&H00FFFFFF"
+	}
+	return fmt.Sprintf("&H00%02X%02X%02X", b, g, r)
+}
+
+// assTime 微秒换算为 ASS 时间 H:MM:SS.cc
+func assTime(us int64) string {
+	cs := (us + 5000) / 10000
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
+
+// assText 换行写成 \N，花括号会被当作样式覆盖标签，需要转义
+func assText(text string) string {
+	text = strings.NewReplacer("\r\n", `\N`, "\n", `\N`, "{", `\{`, "}", `\}`).Replace(text)
+	return text
+}
@@ -0,0 +1,125 @@
+package video
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"novel-video-workflow/pkg/broadcast"
+
+	"go.uber.org/zap"
+)
+
+// RenderProgress ffmpeg 的渲染进度
+type RenderProgress struct {
+	OutTime int64   // 已渲染的时长（微秒）
+	Percent float64 // 0-100
+	Speed   string  // 渲染速度，如 2.1x
+	Done    bool
+}
+
+// WriteFiles 写出 filter_complex 脚本和 ASS 字幕
+func (plan *RenderPlan) WriteFiles() error {
+	if err := os.MkdirAll(filepath.Dir(plan.FilterPath), 0755); err != nil {
+		return fmt.Errorf("创建渲染目录失败: %w", err)
+	}
+	if err := os.WriteFile(plan.FilterPath, []byte(plan.Filter), 0644); err != nil {
+		return fmt.Errorf("写入滤镜脚本失败: %w", err)
+	}
+	if plan.ASS != "" {
+		if err := os.WriteFile(plan.ASSPath, []byte(plan.ASS), 0644); err != nil {
+			return fmt.Errorf("写入ASS字幕失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// RunRenderPlan 写出脚本和字幕后执行 ffmpeg，渲染进度转发到广播服务。ffmpeg 为空时从 PATH 查找
+func (vp *VideoProcessor) RunRenderPlan(plan *RenderPlan, ffmpeg string) error {
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+	path, err := exec.LookPath(ffmpeg)
+	if err != nil {
+		return fmt.Errorf("系统中未找到ffmpeg命令，请确保已安装FFmpeg: %v", err)
+	}
+	if err := plan.WriteFiles(); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(path, plan.Args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("创建ffmpeg输出管道失败: %w", err)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	vp.logger.Info("开始ffmpeg渲染", zap.String("output", plan.Output), zap.String("filter", plan.FilterPath))
+	vp.sendBroadcast(fmt.Sprintf("开始渲染 %s", filepath.Base(plan.Output)))
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动ffmpeg失败: %w", err)
+	}
+
+	lastPercent := -1
+	parseErr := parseProgress(stdout, plan.Duration, func(progress RenderProgress) {
+		// 每增加1%转发一次，避免刷屏
+		if percent := int(progress.Percent); percent > lastPercent || progress.Done {
+			lastPercent = percent
+			vp.sendBroadcast(fmt.Sprintf("渲染进度 %.1f%%（速度 %s）", progress.Percent, progress.Speed))
+		}
+	})
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg渲染失败: %v\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	if parseErr != nil {
+		vp.logger.Warn("解析ffmpeg进度失败", zap.Error(parseErr))
+	}
+
+	vp.logger.Info("ffmpeg渲染完成", zap.String("output", plan.Output))
+	vp.sendBroadcast(fmt.Sprintf("渲染完成 %s", plan.Output))
+	return nil
+}
+
+// sendBroadcast 非阻塞地发送渲染消息
+func (vp *VideoProcessor) sendBroadcast(content string) {
+	if vp.BroadcastService != nil {
+		vp.BroadcastService.TrySendMessage("ffmpeg渲染", content, broadcast.GetTimeStr())
+	}
+}
+
+// parseProgress 解析 ffmpeg -progress 输出的 key=value 行，每个 progress= 行结束一组进度
+func parseProgress(r io.Reader, duration int64, report func(RenderProgress)) error {
+	scanner := bufio.NewScanner(r)
+	var current RenderProgress
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "out_time_us", "out_time_ms": // 旧版本的 out_time_ms 实际单位也是微秒
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil && us >= 0 {
+				current.OutTime = us
+			}
+		case "speed":
+			current.Speed = strings.TrimSpace(value)
+		case "progress":
+			current.Done = value == "end"
+			if duration > 0 {
+				current.Percent = float64(current.OutTime) / float64(duration) * 100
+			}
+			if current.Percent > 100 || current.Done {
+				current.Percent = 100
+			}
+			report(current)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,597 @@
+package video
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"novel-video-workflow/pkg/timeline"
+
+	"github.com/spf13/viper"
+)
+
+// ExportSettings 最终视频的编码设置，对应 video.export 配置
+type ExportSettings struct {
+	Format       string // 容器格式，默认 mp4
+	Codec        string // h264、h265 或 ffmpeg 编码器名称
+	Bitrate      string // 视频码率，如 10M
+	Preset       string // x264/x265 预设，如 medium
+	AudioCodec   string // 默认 aac
+	AudioBitrate string // 默认 192k
+	SampleRate   int    // 音频采样率，默认 44100
+}
+
+// DuckingSettings 旁白出现时压低背景音乐（sidechaincompress）
+type DuckingSettings struct {
+	Enabled   bool
+	Threshold float64 // 旁白电平超过该值（0-1）时开始压低背景音乐
+	Ratio     float64 // 压缩比
+	AttackMS  float64
+	ReleaseMS float64
+}
+
+// RenderOptions 渲染计划选项
+type RenderOptions struct {
+	Export    ExportSettings
+	Ducking   DuckingSettings
+	WorkDir   string // 滤镜脚本和 ASS 字幕的写入目录，为空时使用输出文件所在目录
+	TitleFont string // 烧录字幕的字体，为空时使用 Noto Sans CJK SC
+}
+
+// 默认值
+const (
+	defaultRenderFormat     = "mp4"
+	defaultAudioCodec       = "aac"
+	defaultAudioBitrate     = "192k"
+	defaultSampleRate       = 44100
+	defaultRenderTitleFont  = "Noto Sans CJK SC"
+	defaultDuckingThreshold = 0.05
+	defaultDuckingRatio     = 8
+	defaultDuckingAttackMS  = 20
+	defaultDuckingReleaseMS = 400
+
+	// zoompan 前把图片放大的倍数，减轻缩放和平移时的抖动
+	zoompanSupersample = 2
+)
+
+// RenderOptionsFromConfig 读取 video.export、video.audio_sample_rate 和 video.ducking 配置
+func RenderOptionsFromConfig() RenderOptions {
+	opts := RenderOptions{
+		Export: ExportSettings{
+			Format:       viper.GetString("video.export.format"),
+			Codec:        viper.GetString("video.export.codec"),
+			Bitrate:      viper.GetString("video.export.bitrate"),
+			Preset:       viper.GetString("video.export.preset"),
+			AudioCodec:   viper.GetString("video.export.audio_codec"),
+			AudioBitrate: viper.GetString("video.export.audio_bitrate"),
+			SampleRate:   viper.GetInt("video.audio_sample_rate"),
+		},
+		Ducking: DuckingSettings{
+			Enabled:   viper.GetBool("video.ducking.enabled"),
+			Threshold: viper.GetFloat64("video.ducking.threshold"),
+			Ratio:     viper.GetFloat64("video.ducking.ratio"),
+			AttackMS:  viper.GetFloat64("video.ducking.attack_ms"),
+			ReleaseMS: viper.GetFloat64("video.ducking.release_ms"),
+		},
+		TitleFont: viper.GetString("video.export.subtitle_font"),
+	}
+	return opts
+}
+
+// withDefaults 补全未配置的选项
+func (o RenderOptions) withDefaults() RenderOptions {
+	if o.Export.Format == "" {
+		o.Export.Format = defaultRenderFormat
+	}
+	if o.Export.AudioCodec == "" {
+		o.Export.AudioCodec = defaultAudioCodec
+	}
+	if o.Export.AudioBitrate == "" {
+		o.Export.AudioBitrate = defaultAudioBitrate
+	}
+	if o.Export.SampleRate <= 0 {
+		o.Export.SampleRate = defaultSampleRate
+	}
+	if o.TitleFont == "" {
+		o.TitleFont = defaultRenderTitleFont
+	}
+	if o.Ducking.Threshold <= 0 {
+		o.Ducking.Threshold = defaultDuckingThreshold
+	}
+	if o.Ducking.Ratio < 1 {
+		o.Ducking.Ratio = defaultDuckingRatio
+	}
+	if o.Ducking.AttackMS <= 0 {
+		o.Ducking.AttackMS = defaultDuckingAttackMS
+	}
+	if o.Ducking.ReleaseMS <= 0 {
+		o.Ducking.ReleaseMS = defaultDuckingReleaseMS
+	}
+	return o
+}
+
+// RenderInput ffmpeg 的一个输入文件及其输入选项
+type RenderInput struct {
+	Options []string
+	Path    string
+}
+
+// RenderPlan 一次 ffmpeg 渲染的完整调用：输入、filter_complex 脚本、ASS 字幕和编码参数。
+// 生成计划不访问文件系统，执行前由 WriteFiles 写出脚本和字幕
+type RenderPlan struct {
+	Output     string
+	Duration   int64 // 视频时长（微秒），用于计算进度
+	Inputs     []RenderInput
+	Filter     string // filter_complex 脚本内容
+	FilterPath string
+	ASS        string // ASS 字幕内容，为空时不烧录字幕
+	ASSPath    string
+	Args       []string // ffmpeg 参数，不含 ffmpeg 本身
+}
+
+// renderPlanner 生成渲染计划时的状态
+type renderPlanner struct {
+	tl     *timeline.Timeline
+	opts   RenderOptions
+	rate   timeline.FrameRate
+	inputs []RenderInput
+	lines  []string
+	labels int
+}
+
+// PlanRender 将章节时间线换算为 ffmpeg 渲染计划。
+// 分镜图片轨道（没有时为第一条视频轨道）用 zoompan 实现运动效果，首尾相接的图片之间用 xfade 转场，
+// 字幕轨道转换为 ASS 后烧录；旁白和背景音乐混音，开启闪避时旁白出现时压低背景音乐
+func PlanRender(tl *timeline.Timeline, output string, opts RenderOptions) (*RenderPlan, error) {
+	if err := tl.Validate(); err != nil {
+		return nil, err
+	}
+	opts = opts.withDefaults()
+	p := &renderPlanner{tl: tl, opts: opts, rate: timeline.FrameRateOf(tl.FPS)}
+
+	total := p.rate.Frames(tl.Duration)
+	for _, tr := range tl.Tracks {
+		for _, c := range tr.Clips {
+			if end := p.rate.Frames(c.Target.End()); end > total {
+				total = end
+			}
+		}
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("时间线为空")
+	}
+
+	workDir := opts.WorkDir
+	if workDir == "" {
+		workDir = filepath.Dir(output)
+	}
+	base := strings.TrimSuffix(filepath.Base(output), filepath.Ext(output))
+	plan := &RenderPlan{
+		Output:     output,
+		Duration:   p.rate.Microseconds(total),
+		FilterPath: filepath.Join(workDir, base+".filter.txt"),
+		ASSPath:    filepath.Join(workDir, base+".ass"),
+	}
+	plan.ASS = buildASS(tl, opts.TitleFont)
+
+	video := p.videoGraph(total)
+	if plan.ASS != "" {
+		p.addLine(fmt.Sprintf("[%s]subtitles=filename=%s[vout]", video, escapeFilterPath(plan.ASSPath)))
+	} else {
+		p.addLine(fmt.Sprintf("[%s]null[vout]", video))
+	}
+	audio := p.audioGraph(total)
+	p.addLine(fmt.Sprintf("[%s]aresample=%d,apad[aout]", audio, opts.Export.SampleRate))
+
+	plan.Inputs = p.inputs
+	plan.Filter = strings.Join(p.lines, ";\n") + "\n"
+	plan.Args = p.args(plan, total)
+	return plan, nil
+}
+
+// args 组装 ffmpeg 参数：-progress pipe:1 输出机器可读的进度
+func (p *renderPlanner) args(plan *RenderPlan, total int64) []string {
+	export := p.opts.Export
+	args := []string{"-hide_banner", "-loglevel", "error", "-y"}
+	for _, in := range plan.Inputs {
+		args = append(args, in.Options...)
+		args = append(args, "-i", in.Path)
+	}
+	args = append(args, "-filter_complex_script", plan.FilterPath, "-map", "[vout]", "-map", "[aout]")
+
+	encoder := videoEncoder(export.Codec)
+	args = append(args, "-c:v", encoder)
+	if export.Preset != "" {
+		args = append(args, "-preset", export.Preset)
+	}
+	if export.Bitrate != "" {
+		args = append(args, "-b:v", export.Bitrate)
+	}
+	if encoder == "libx265" {
+		args = append(args, "-tag:v", "hvc1") // QuickTime 和 iOS 需要 hvc1 标签
+	}
+	args = append(args, "-pix_fmt", "yuv420p", "-r", rateString(p.rate),
+		"-c:a", export.AudioCodec, "-b:a", export.AudioBitrate, "-ar", strconv.Itoa(export.SampleRate),
+		"-t", p.seconds(total))
+	switch strings.ToLower(export.Format) {
+	case "mp4", "mov":
+		args = append(args, "-movflags", "+faststart")
+	}
+	return append(args, "-progress", "pipe:1", "-nostats", plan.Output)
+}
+
+// renderShot 视频轨道上一个片段的帧位置，visible 为包含转场在内的可见区间
+type renderShot struct {
+	clip         *timeline.Clip
+	start, end   int64
+	visibleStart int64
+	visibleEnd   int64
+	transition   string // 切换到下一个片段的 xfade 效果，为空时硬切
+}
+
+// videoGraph 生成画面部分的滤镜，返回输出标签。
+// 转场跨在切换点两侧：上一张延长到转场结束，下一张提前到转场开始，xfade 后总时长不变
+func (p *renderPlanner) videoGraph(total int64) string {
+	track := p.tl.TrackByRole(timeline.RoleImages)
+	if track == nil || track.Kind != timeline.TrackVideo {
+		if videos := p.tl.TracksOf(timeline.TrackVideo); len(videos) > 0 {
+			track = videos[0]
+		} else {
+			track = nil
+		}
+	}
+
+	var shots []*renderShot
+	if track != nil {
+		for _, c := range track.Clips {
+			start, duration := p.rate.FrameRange(c.Target)
+			if duration <= 0 {
+				continue
+			}
+			shots = append(shots, &renderShot{clip: c, start: start, end: start + duration, visibleStart: start, visibleEnd: start + duration})
+		}
+	}
+	soloStart := int64(0)
+	for i, s := range shots {
+		if i == 0 || shots[i-1].transition == "" {
+			soloStart = s.start
+		}
+		if s.clip.Transition == nil || i+1 >= len(shots) {
+			continue
+		}
+		next := shots[i+1]
+		frames := p.rate.Frames(s.clip.Transition.Duration)
+		if frames <= 0 || next.start != s.end || s.clip.Media != timeline.MediaImage || next.clip.Media != timeline.MediaImage {
+			continue
+		}
+		start := s.end - frames/2
+		if start < soloStart || start+frames > next.end {
+			continue
+		}
+		s.transition = xfadeTransition(s.clip.Transition.Name)
+		s.visibleEnd = start + frames
+		next.visibleStart = start
+		soloStart = start + frames
+	}
+
+	acc := ""
+	position := int64(0)
+	for i, s := range shots {
+		if s.visibleStart > position {
+			acc = p.concat(acc, p.blackSource(s.visibleStart-position))
+			position = s.visibleStart
+		}
+		segment := p.shotSegment(s)
+		if acc != "" && s.visibleStart < position {
+			label := p.label("x")
+			p.addLine(fmt.Sprintf("[%s][%s]xfade=transition=%s:duration=%s:offset=%s[%s]",
+				acc, segment, shots[i-1].transition, p.seconds(position-s.visibleStart), p.seconds(s.visibleStart), label))
+			acc = label
+		} else {
+			acc = p.concat(acc, segment)
+		}
+		position = s.visibleEnd
+	}
+
+	if acc == "" {
+		return p.blackSource(total)
+	}
+	label := p.label("vbase")
+	if position < total {
+		p.addLine(fmt.Sprintf("[%s]tpad=stop=%d:color=black[%s]", acc, total-position, label))
+	} else {
+		p.addLine(fmt.Sprintf("[%s]null[%s]", acc, label))
+	}
+	return label
+}
+
+// shotSegment 生成一个片段的画面：铺满画布后按运动关键帧 zoompan，图片按可见帧数循环输入
+func (p *renderPlanner) shotSegment(s *renderShot) string {
+	c := s.clip
+	frames := s.visibleEnd - s.visibleStart
+	index := len(p.inputs)
+	if c.Media == timeline.MediaImage {
+		p.inputs = append(p.inputs, RenderInput{
+			Options: []string{"-loop", "1", "-framerate", rateString(p.rate), "-t", p.seconds(frames)},
+			Path:    c.Path,
+		})
+	} else {
+		p.inputs = append(p.inputs, RenderInput{
+			Options: []string{"-ss", formatSeconds(float64(c.Source.Start) / 1e6), "-t", p.seconds(frames)},
+			Path:    c.Path,
+		})
+	}
+
+	width, height := p.tl.Width, p.tl.Height
+	var chain []string
+	if zoom, x, y, ok := p.zoompanExpressions(c.Motion, s.start-s.visibleStart); ok {
+		sw, sh := width*zoompanSupersample, height*zoompanSupersample
+		chain = append(chain,
+			fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=increase", sw, sh),
+			fmt.Sprintf("crop=%d:%d", sw, sh),
+			"setsar=1",
+			fmt.Sprintf("zoompan=z='%s':x='%s':y='%s':d=1:s=%dx%d:fps=%s", zoom, x, y, width, height, rateString(p.rate)))
+	} else {
+		chain = append(chain,
+			fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=increase", width, height),
+			fmt.Sprintf("crop=%d:%d", width, height),
+			"setsar=1",
+			"fps="+rateString(p.rate))
+	}
+	chain = append(chain, fmt.Sprintf("trim=end_frame=%d", frames), "setpts=PTS-STARTPTS", "format=yuv420p")
+
+	label := p.label("v")
+	p.addLine(fmt.Sprintf("[%d:v]%s[%s]", index, strings.Join(chain, ","), label))
+	return label
+}
+
+// zoompanExpressions 将运动关键帧换算为 zoompan 的缩放和窗口位置表达式（on 为输出帧号）。
+// 位移单位为半个画布、右移和上移为正；画面右移相当于取景窗口左移 位移/缩放
+func (p *renderPlanner) zoompanExpressions(m *timeline.Motion, lead int64) (string, string, string, bool) {
+	if m == nil || len(m.Keyframes) == 0 {
+		return "", "", "", false
+	}
+	var offsets []int64
+	seen := make(map[int64]bool)
+	for _, kf := range m.Keyframes {
+		if !seen[kf.Offset] {
+			seen[kf.Offset] = true
+			offsets = append(offsets, kf.Offset)
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	points := func(property string, fallback float64) []exprPoint {
+		var out []exprPoint
+		for _, offset := range offsets {
+			out = append(out, exprPoint{frame: lead + p.rate.Frames(offset), value: m.ValueAt(property, offset, fallback)})
+		}
+		return out
+	}
+	zoom := piecewiseExpr(points(timeline.PropertyScale, 1))
+	x, y := "(iw-iw/zoom)/2", "(ih-ih/zoom)/2"
+	if px := piecewiseExpr(points(timeline.PropertyPositionX, 0)); px != "0" {
+		x += fmt.Sprintf("-(%s)*iw/(2*zoom)", px)
+	}
+	if py := piecewiseExpr(points(timeline.PropertyPositionY, 0)); py != "0" {
+		y += fmt.Sprintf("+(%s)*ih/(2*zoom)", py)
+	}
+	return zoom, x, y, true
+}
+
+// exprPoint 分段线性表达式的一个控制点
+type exprPoint struct {
+	frame int64
+	value float64
+}
+
+// piecewiseExpr 生成按输出帧号 on 线性插值的表达式，第一个点之前和最后一个点之后保持不变
+func piecewiseExpr(points []exprPoint) string {
+	constant := true
+	for _, pt := range points {
+		if pt.value != points[0].value {
+			constant = false
+		}
+	}
+	if constant {
+		return formatFloat(points[0].value)
+	}
+	last := points[len(points)-1]
+	expr := formatFloat(last.value)
+	for i := len(points) - 2; i >= 0; i-- {
+		a, b := points[i], points[i+1]
+		if b.frame <= a.frame {
+			continue
+		}
+		expr = fmt.Sprintf("if(lt(on,%d),%s+(%s)*(on-%d)/%d,%s)", b.frame, formatFloat(a.value),
+			formatFloat(b.value-a.value), a.frame, b.frame-a.frame, expr)
+	}
+	if points[0].frame > 0 {
+		expr = fmt.Sprintf("if(lt(on,%d),%s,%s)", points[0].frame, formatFloat(points[0].value), expr)
+	}
+	return expr
+}
+
+// audioGraph 生成声音部分的滤镜，返回输出标签。背景音乐轨道之外的音频都视为旁白
+func (p *renderPlanner) audioGraph(total int64) string {
+	var voices, music []string
+	for _, tr := range p.tl.TracksOf(timeline.TrackAudio) {
+		var clips []string
+		for _, c := range tr.Clips {
+			if label := p.audioClip(c); label != "" {
+				clips = append(clips, label)
+			}
+		}
+		if len(clips) == 0 {
+			continue
+		}
+		mixed := p.amix(clips)
+		if tr.Role == timeline.RoleBGM {
+			music = append(music, mixed)
+		} else {
+			voices = append(voices, mixed)
+		}
+	}
+
+	voice, bgm := "", ""
+	if len(voices) > 0 {
+		voice = p.amix(voices)
+	}
+	if len(music) > 0 {
+		bgm = p.amix(music)
+	}
+	switch {
+	case voice != "" && bgm != "" && p.opts.Ducking.Enabled:
+		mix, sidechain, ducked := p.label("voice"), p.label("sc"), p.label("ducked")
+		d := p.opts.Ducking
+		p.addLine(fmt.Sprintf("[%s]asplit=2[%s][%s]", voice, mix, sidechain))
+		p.addLine(fmt.Sprintf("[%s][%s]sidechaincompress=threshold=%s:ratio=%s:attack=%s:release=%s[%s]",
+			bgm, sidechain, formatFloat(d.Threshold), formatFloat(d.Ratio), formatFloat(d.AttackMS), formatFloat(d.ReleaseMS), ducked))
+		return p.amix([]string{mix, ducked})
+	case voice != "" && bgm != "":
+		return p.amix([]string{voice, bgm})
+	case voice != "":
+		return voice
+	case bgm != "":
+		return bgm
+	}
+	label := p.label("silence")
+	p.addLine(fmt.Sprintf("anullsrc=r=%d:cl=stereo,atrim=duration=%s[%s]", p.opts.Export.SampleRate, p.seconds(total), label))
+	return label
+}
+
+// audioClip 截取素材区间，调整音量后延迟到片段开始时间
+func (p *renderPlanner) audioClip(c *timeline.Clip) string {
+	if c.Target.Duration <= 0 {
+		return ""
+	}
+	index := len(p.inputs)
+	p.inputs = append(p.inputs, RenderInput{Path: c.Path})
+
+	chain := []string{
+		fmt.Sprintf("atrim=start=%s:duration=%s", formatSeconds(float64(c.Source.Start)/1e6), formatSeconds(float64(c.Target.Duration)/1e6)),
+		"asetpts=PTS-STARTPTS",
+	}
+	if gain := c.Volume(); gain != 1 {
+		chain = append(chain, "volume="+formatFloat(gain))
+	}
+	if delay := c.Target.Start / 1000; delay > 0 {
+		chain = append(chain, fmt.Sprintf("adelay=%d:all=1", delay))
+	}
+	label := p.label("a")
+	p.addLine(fmt.Sprintf("[%d:a]%s[%s]", index, strings.Join(chain, ","), label))
+	return label
+}
+
+// amix 混合多路音频，不做音量归一化以保留各自的增益
+func (p *renderPlanner) amix(labels []string) string {
+	if len(labels) == 1 {
+		return labels[0]
+	}
+	label := p.label("mix")
+	p.addLine(fmt.Sprintf("[%s]amix=inputs=%d:duration=longest:normalize=0[%s]", strings.Join(labels, "]["), len(labels), label))
+	return label
+}
+
+// concat 拼接两段画面，acc 为空时直接返回 next
+func (p *renderPlanner) concat(acc, next string) string {
+	if acc == "" {
+		return next
+	}
+	label := p.label("c")
+	p.addLine(fmt.Sprintf("[%s][%s]concat=n=2:v=1:a=0[%s]", acc, next, label))
+	return label
+}
+
+// blackSource 生成指定帧数的黑色画面，用于图片之间的空隙
+func (p *renderPlanner) blackSource(frames int64) string {
+	label := p.label("gap")
+	p.addLine(fmt.Sprintf("color=c=black:s=%dx%d:r=%s:d=%s,format=yuv420p[%s]",
+		p.tl.Width, p.tl.Height, rateString(p.rate), p.seconds(frames), label))
+	return label
+}
+
+func (p *renderPlanner) addLine(line string) {
+	p.lines = append(p.lines, line)
+}
+
+func (p *renderPlanner) label(prefix string) string {
+	p.labels++
+	return fmt.Sprintf("%s%d", prefix, p.labels)
+}
+
+// seconds 帧数对应的秒数
+func (p *renderPlanner) seconds(frames int64) string {
+	return formatSeconds(float64(frames) * float64(p.rate.Den) / float64(p.rate.Num))
+}
+
+// xfadeNames 剪映转场名称对应的 xfade 效果，未列出的名称如果本身是 xfade 效果名则直接使用，否则用 fade
+var xfadeNames = map[string]string{
+	"淡入淡出": "fade",
+	"叠化":   "dissolve",
+	"闪黑":   "fadeblack",
+	"闪白":   "fadewhite",
+	"向左擦除": "wipeleft",
+	"向右擦除": "wiperight",
+	"向上擦除": "wipeup",
+	"向下擦除": "wipedown",
+	"向左滑动": "slideleft",
+	"向右滑动": "slideright",
+	"圆形扫描": "circleopen",
+	"模糊":   "hblur",
+}
+
+var xfadeEffects = map[string]bool{
+	"fade": true, "dissolve": true, "fadeblack": true, "fadewhite": true, "wipeleft": true, "wiperight": true,
+	"wipeup": true, "wipedown": true, "slideleft": true, "slideright": true, "slideup": true, "slidedown": true,
+	"circleopen": true, "circleclose": true, "circlecrop": true, "rectcrop": true, "radial": true, "pixelize": true,
+	"hblur": true, "distance": true, "smoothleft": true, "smoothright": true, "smoothup": true, "smoothdown": true,
+	"zoomin": true,
+}
+
+func xfadeTransition(name string) string {
+	if effect, ok := xfadeNames[name]; ok {
+		return effect
+	}
+	if xfadeEffects[name] {
+		return name
+	}
+	return "fade"
+}
+
+// videoEncoder 将配置中的编码格式换算为 ffmpeg 编码器
+func videoEncoder(codec string) string {
+	switch strings.ToLower(codec) {
+	case "", "h264", "avc":
+		return "libx264"
+	case "h265", "hevc":
+		return "libx265"
+	default:
+		return codec
+	}
+}
+
+// rateString ffmpeg 的帧率写法，如 30 或 30000/1001
+func rateString(rate timeline.FrameRate) string {
+	if rate.Den == 1 {
+		return strconv.FormatInt(rate.Num, 10)
+	}
+	return fmt.Sprintf("%d/%d", rate.Num, rate.Den)
+}
+
+// escapeFilterPath 转义滤镜参数中的文件路径：先按选项值转义 \ ' :，再整体加单引号
+func escapeFilterPath(path string) string {
+	value := strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(filepath.ToSlash(path))
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func formatSeconds(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e6)/1e6, 'f', -1, 64)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10000)/10000, 'f', -1, 64)
+}
@@ -0,0 +1,150 @@
+package video
+
+import (
+	"strings"
+	"testing"
+
+	"novel-video-workflow/pkg/timeline"
+)
+
+func sampleRenderTimeline() *timeline.Timeline {
+	tl := timeline.New("chapter_01", 1080, 1920, 30)
+	tl.Duration = 6000000
+	images := tl.AddTrack(timeline.TrackVideo, "视频轨道", timeline.RoleImages)
+	images.AddClip(&timeline.Clip{
+		ID: "image_01", Media: timeline.MediaImage, Path: "/tmp/novel/scene_01.png",
+		Source: timeline.Range{Start: 0, Duration: 2000000}, Target: timeline.Range{Start: 0, Duration: 2000000},
+		Motion: &timeline.Motion{Preset: "zoom_in", Keyframes: []timeline.Keyframe{
+			{Property: timeline.PropertyScale, Offset: 0, Value: 1},
+			{Property: timeline.PropertyScale, Offset: 2000000, Value: 1.1},
+		}},
+		Transition: &timeline.Transition{Name: "淡入淡出", Duration: 500000},
+	})
+	images.AddClip(&timeline.Clip{
+		ID: "image_02", Media: timeline.MediaImage, Path: "/tmp/novel/scene_02.png",
+		Source: timeline.Range{Start: 0, Duration: 1000000}, Target: timeline.Range{Start: 2000000, Duration: 1000000},
+	})
+	// 第三张前留1秒空隙，最后1秒没有图片
+	images.AddClip(&timeline.Clip{
+		ID: "image_03", Media: timeline.MediaImage, Path: "/tmp/novel/scene_03.png",
+		Source: timeline.Range{Start: 0, Duration: 1000000}, Target: timeline.Range{Start: 4000000, Duration: 1000000},
+	})
+	narration := tl.AddTrack(timeline.TrackAudio, "音频轨道", timeline.RoleNarration)
+	narration.AddClip(&timeline.Clip{
+		ID: "narration", Media: timeline.MediaAudio, Path: "/tmp/novel/chapter_01.wav",
+		Source: timeline.Range{Start: 0, Duration: 6000000}, Target: timeline.Range{Start: 0, Duration: 6000000}, Gain: 1,
+	})
+	bgm := tl.AddTrack(timeline.TrackAudio, "背景音乐", timeline.RoleBGM)
+	bgm.AddClip(&timeline.Clip{
+		ID: "bgm", Media: timeline.MediaAudio, Path: "/tmp/bgm.mp3",
+		Source: timeline.Range{Start: 1000000, Duration: 5000000}, Target: timeline.Range{Start: 1000000, Duration: 5000000}, Gain: 0.3,
+	})
+	subtitles := tl.AddTrack(timeline.TrackText, "字幕轨道", timeline.RoleSubtitle)
+	subtitles.Cues = []timeline.TextCue{{Target: timeline.Range{Start: 0, Duration: 2500000}, Text: "第一句{旁白}"}}
+	subtitles.Style = &timeline.TextStyle{Size: 0.025, Color: "#FFCC00", Bold: true, PositionY: -0.8}
+	return tl
+}
+
+func testRenderOptions() RenderOptions {
+	return RenderOptions{
+		Export:  ExportSettings{Format: "mp4", Codec: "h264", Bitrate: "10M", Preset: "medium", SampleRate: 44100},
+		Ducking: DuckingSettings{Enabled: true},
+	}
+}
+
+func TestPlanRenderFilterGraph(t *testing.T) {
+	plan, err := PlanRender(sampleRenderTimeline(), "/tmp/out/chapter_01.mp4", testRenderOptions())
+	if err != nil {
+		t.Fatalf("生成渲染计划失败: %v", err)
+	}
+
+	wants := []string{
+		// 第一张图片 0-60 帧，转场 53-68，总共显示68帧
+		"[0:v]scale=2160:3840:force_original_aspect_ratio=increase,crop=2160:3840,setsar=1,zoompan=z='if(lt(on,60),1+(0.1)*(on-0)/60,1.1)'",
+		"trim=end_frame=68",
+		"zoompan=z='if(lt(on,60),1+(0.1)*(on-0)/60,1.1)':x='(iw-iw/zoom)/2':y='(ih-ih/zoom)/2'",
+		// 第二张提前7帧出现在转场中，显示 53-90
+		"[1:v]scale=1080:1920:force_original_aspect_ratio=increase,crop=1080:1920,setsar=1,fps=30,trim=end_frame=37",
+		"xfade=transition=fade:duration=0.5:offset=1.766667",
+		"color=c=black:s=1080x1920:r=30:d=1,format=yuv420p",
+		"tpad=stop=30:color=black",
+		"subtitles=filename='/tmp/out/chapter_01.ass'[vout]",
+		"[3:a]atrim=start=0:duration=6,asetpts=PTS-STARTPTS[",
+		"[4:a]atrim=start=1:duration=5,asetpts=PTS-STARTPTS,volume=0.3,adelay=1000:all=1[",
+		"sidechaincompress=threshold=0.05:ratio=8:attack=20:release=400",
+		"amix=inputs=2:duration=longest:normalize=0",
+		"aresample=44100,apad[aout]",
+	}
+	for _, want := range wants {
+		if !strings.Contains(plan.Filter, want) {
+			t.Errorf("滤镜脚本缺少 %q:\n%s", want, plan.Filter)
+		}
+	}
+
+	args := strings.Join(plan.Args, " ")
+	for _, want := range []string{
+		"-loop 1 -framerate 30 -t 2.266667 -i /tmp/novel/scene_01.png",
+		"-filter_complex_script /tmp/out/chapter_01.filter.txt -map [vout] -map [aout]",
+		"-c:v libx264 -preset medium -b:v 10M -pix_fmt yuv420p -r 30 -c:a aac -b:a 192k -ar 44100 -t 6",
+		"-movflags +faststart -progress pipe:1 -nostats /tmp/out/chapter_01.mp4",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("ffmpeg参数缺少 %q:\n%s", want, args)
+		}
+	}
+	if plan.Duration != 6000000 {
+		t.Errorf("渲染时长应为6秒: %d", plan.Duration)
+	}
+}
+
+func TestPlanRenderWithoutDucking(t *testing.T) {
+	opts := testRenderOptions()
+	opts.Ducking.Enabled = false
+	opts.Export.Codec = "h265"
+	plan, err := PlanRender(sampleRenderTimeline(), "/tmp/out/chapter_01.mp4", opts)
+	if err != nil {
+		t.Fatalf("生成渲染计划失败: %v", err)
+	}
+	if strings.Contains(plan.Filter, "sidechaincompress") {
+		t.Errorf("关闭闪避时不应压低背景音乐")
+	}
+	if !strings.Contains(strings.Join(plan.Args, " "), "-c:v libx265 -preset medium -b:v 10M -tag:v hvc1") {
+		t.Errorf("h265 应使用 libx265 并添加 hvc1 标签: %v", plan.Args)
+	}
+}
+
+func TestBuildASS(t *testing.T) {
+	ass := buildASS(sampleRenderTimeline(), "Noto Sans CJK SC")
+	for _, want := range []string{
+		"PlayResX: 1080\nPlayResY: 1920",
+		"Style: subtitle,Noto Sans CJK SC,48,&H0000CCFF,&H000000FF,&H00000000,&H80000000,-1,0,0,0,100,100,0,0,1,2,0,2,54,54,168,1",
+		`Dialogue: 0,0:00:00.00,0:00:02.50,subtitle,,0,0,0,,第一句\{旁白\}`,
+	} {
+		if !strings.Contains(ass, want) {
+			t.Errorf("ASS字幕缺少 %q:\n%s", want, ass)
+		}
+	}
+
+	tl := sampleRenderTimeline()
+	tl.TrackByRole(timeline.RoleSubtitle).Cues = nil
+	if buildASS(tl, "") != "" {
+		t.Errorf("没有字幕时不应生成ASS")
+	}
+}
+
+func TestParseProgress(t *testing.T) {
+	output := "frame=90\nout_time_us=3000000\nspeed=2.5x\nprogress=continue\nframe=180\nout_time_us=6000000\nspeed=2.4x\nprogress=end\n"
+	var reports []RenderProgress
+	if err := parseProgress(strings.NewReader(output), 6000000, func(p RenderProgress) { reports = append(reports, p) }); err != nil {
+		t.Fatalf("解析进度失败: %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("应有2组进度: %d", len(reports))
+	}
+	if reports[0].Percent != 50 || reports[0].Speed != "2.5x" || reports[0].Done {
+		t.Errorf("第一组进度错误: %+v", reports[0])
+	}
+	if !reports[1].Done || reports[1].Percent != 100 {
+		t.Errorf("最后一组进度应为完成: %+v", reports[1])
+	}
+}
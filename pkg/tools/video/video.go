@@ -7,12 +7,17 @@ import (
 	"path/filepath"
 	"time"
 
+	"novel-video-workflow/pkg/broadcast"
+	"novel-video-workflow/pkg/capcut"
+	"novel-video-workflow/pkg/timeline"
+
 	"go.uber.org/zap"
 )
 
 // VideoProcessor 视频处理器
 type VideoProcessor struct {
-	logger *zap.Logger
+	logger           *zap.Logger
+	BroadcastService *broadcast.BroadcastService
 }
 
 // VideoProject 视频项目信息
@@ -20,6 +25,7 @@ type VideoProject struct {
 	Name     string                 `json:"name"`
 	Dir      string                 `json:"dir"`
 	Metadata map[string]interface{} `json:"metadata"`
+	Timeline string                 `json:"timeline"` // 章节时间线文件 timeline.json
 	Created  time.Time              `json:"created"`
 }
 
@@ -36,7 +42,8 @@ type SubtitleLine struct {
 // NewVideoProcessor 创建视频处理器
 func NewVideoProcessor(logger *zap.Logger) *VideoProcessor {
 	return &VideoProcessor{
-		logger: logger,
+		logger:           logger,
+		BroadcastService: broadcast.NewBroadcastService(),
 	}
 }
 
@@ -62,41 +69,32 @@ func (vp *VideoProcessor) CreateVideoProject(chapterDir string, chapterNum int)
 		Name:     projectName,
 		Dir:      projectDir,
 		Metadata: metadata,
+		Timeline: filepath.Join(chapterDir, timeline.FileName),
 		Created:  time.Now(),
 	}
 
 	return project, nil
 }
 
-// GenerateEditList 生成编辑清单
-func (vp *VideoProcessor) GenerateEditList(chapterDir string, chapterNum int) (string, error) {
-	editListFile := filepath.Join(chapterDir, fmt.Sprintf("chapter_%02d_edit_list.json", chapterNum))
-
-	// 这里可以实现生成编辑清单的逻辑
-	// 暂时返回一个占位文件
-	content := fmt.Sprintf(`{
-  "chapter": %02d,
-  "created_at": "%s",
-  "assets": {
-    "audio": "%s",
-    "subtitles": "%s",
-    "images": []
-  },
-  "timeline": []
-}`, chapterNum, time.Now().Format(time.RFC3339), 
-		filepath.Join(chapterDir, "audio", fmt.Sprintf("chapter_%02d.wav", chapterNum)),
-		filepath.Join(chapterDir, "subtitles", fmt.Sprintf("chapter_%02d.srt", chapterNum)))
-
-	if err := os.WriteFile(editListFile, []byte(content), 0644); err != nil {
-		return "", fmt.Errorf("写入编辑清单文件失败: %w", err)
+// GenerateTimeline 根据章节目录中的音频、图片和字幕生成时间线 timeline.json，返回文件路径
+func (vp *VideoProcessor) GenerateTimeline(chapterDir string) (string, error) {
+	if _, err := capcut.BuildChapterTimeline(chapterDir); err != nil {
+		return "", fmt.Errorf("生成时间线失败: %w", err)
 	}
-
-	return editListFile, nil
+	return filepath.Join(chapterDir, timeline.FileName), nil
 }
 
 // ProcessVideoTimeline 处理视频时间线
-func (vp *VideoProcessor) ProcessVideoTimeline(projectDir string, timeline []interface{}) error {
-	// 实现视频时间线处理逻辑
-	vp.logger.Info("处理视频时间线", zap.String("project", projectDir), zap.Int("events", len(timeline)))
+func (vp *VideoProcessor) ProcessVideoTimeline(projectDir string, tl *timeline.Timeline) error {
+	if err := tl.Validate(); err != nil {
+		return err
+	}
+	for _, track := range tl.Tracks {
+		vp.logger.Info("处理视频时间线",
+			zap.String("project", projectDir),
+			zap.String("track", track.Name),
+			zap.Int("clips", len(track.Clips)),
+			zap.Int("cues", len(track.Cues)))
+	}
 	return nil
 }
@@ -1,13 +1,18 @@
 package workflow
 
 import (
+	"path/filepath"
+
 	aegisub "novel-video-workflow/pkg/tools/aegisub"
 	drawthings "novel-video-workflow/pkg/tools/drawthings"
 	"novel-video-workflow/pkg/tools/file"
 	image "novel-video-workflow/pkg/tools/image"
 	"novel-video-workflow/pkg/tools/indextts2"
+	"novel-video-workflow/pkg/tools/video"
 	"novel-video-workflow/pkg/capcut"
+	"novel-video-workflow/pkg/timeline"
 
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
@@ -28,7 +33,7 @@ type ChapterResult struct {
 	Status       string
 	Message      string
 	VideoProject string
-	EditListFile string
+	TimelineFile string
 }
 
 type Processor struct {
@@ -38,6 +43,7 @@ type Processor struct {
 	imageTool      *image.ImageGenerator
 	drawThingsTool *drawthings.ChapterImageGenerator
 	capcutTool     *capcut.CapcutGenerator
+	videoTool      *video.VideoProcessor
 	logger         *zap.Logger
 }
 
@@ -49,6 +55,7 @@ func NewProcessor(logger *zap.Logger) (*Processor, error) {
 	imageTool := image.NewImageGenerator(logger)
 	drawThingsTool := drawthings.NewChapterImageGenerator(logger)
 	capcutTool := capcut.NewCapcutGenerator(logger)
+	videoTool := video.NewVideoProcessor(logger)
 
 	return &Processor{
 		fileTool:       fileTool,
@@ -57,35 +64,33 @@ func NewProcessor(logger *zap.Logger) (*Processor, error) {
 		imageTool:      imageTool,
 		drawThingsTool: drawThingsTool,
 		capcutTool:     capcutTool,
+		videoTool:      videoTool,
 		logger:         logger,
 	}, nil
 }
 
-func (p *Processor) generateEditList(chapterDir string, chapterNum int,
-	ttsResult *indextts2.TTSResult, subtitleFile string, images []string) map[string]interface{} {
-
-	return map[string]interface{}{
-		"chapter": chapterNum,
-		"assets": map[string]interface{}{
-			"audio":    ttsResult.AudioPath,
-			"subtitle": subtitleFile,
-			"images":   images,
-		},
-		"timeline": []map[string]interface{}{
-			{
-				"time": "00:00",
-				"type": "audio_start",
-				"file": ttsResult.AudioPath,
-			},
-		},
+// generateTimeline 生成章节时间线，剪映草稿和其他导出格式都由它生成，返回 timeline.json 路径
+func (p *Processor) generateTimeline(chapterDir string) (string, error) {
+	if _, err := capcut.BuildChapterTimeline(chapterDir); err != nil {
+		return "", err
 	}
+	return filepath.Join(chapterDir, timeline.FileName), nil
 }
 
 func (p *Processor) GenerateCapcutProject(chapterDir string) error {
+	// 配置了设计好的剪映模板时，复制模板草稿并替换其中的占位素材；草稿已存在时合并剪映中的修改
+	if templateName := viper.GetString("video.capcut_template.name"); templateName != "" {
+		return p.capcutTool.GenerateProjectFromTemplate(chapterDir, templateName)
+	}
 	// 使用 CapCut 生成器生成剪映项目
 	return p.capcutTool.GenerateProject(chapterDir)
 }
 
+// LintCapcutDraft 检查剪映草稿文件或草稿目录，skipFiles 为true时不检查素材文件是否存在
+func (p *Processor) LintCapcutDraft(path, appVersion string, skipFiles bool) ([]capcut.LintProblem, error) {
+	return capcut.LintDraftFile(path, capcut.LintOptions{SkipFiles: skipFiles, AppVersion: appVersion})
+}
+
 func (p *Processor) GetProgress() any {
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,234 @@
+package workflow
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"novel-video-workflow/pkg/capcut"
+	"novel-video-workflow/pkg/timeline"
+	"novel-video-workflow/pkg/timeline/fcpxml"
+	"novel-video-workflow/pkg/timeline/mlt"
+	"novel-video-workflow/pkg/timeline/otio"
+	"novel-video-workflow/pkg/timeline/xmeml"
+	"novel-video-workflow/pkg/tools/video"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// 时间线导出格式
+const (
+	TimelineFormatOTIO   = "otio"   // OpenTimelineIO，可导入 DaVinci Resolve 等剪辑软件
+	TimelineFormatFCPXML = "fcpxml" // Final Cut Pro X
+	TimelineFormatXMEML  = "xmeml"  // Final Cut Pro 7 XML，可导入 Premiere Pro
+	TimelineFormatMLT    = "mlt"    // MLT XML，可用 Kdenlive、Shotcut 打开，或用 melt 直接渲染
+)
+
+// timelineExporter 导出格式的扩展名和写入函数
+type timelineExporter struct {
+	ext    string
+	export func(tl *timeline.Timeline, path string) error
+}
+
+var timelineExporters = map[string]timelineExporter{
+	TimelineFormatOTIO:   {ext: otio.Ext, export: otio.Export},
+	TimelineFormatFCPXML: {ext: fcpxml.Ext, export: fcpxml.Export},
+	TimelineFormatXMEML:  {ext: xmeml.Ext, export: xmeml.Export},
+	TimelineFormatMLT:    {ext: mlt.Ext, export: mlt.Export},
+}
+
+// timelineImporters 按扩展名读取剪辑软件修改后的时间线
+var timelineImporters = map[string]func(path string) (*timeline.Timeline, error){
+	otio.Ext: otio.Import,
+}
+
+// TimelineFormats 返回支持的导出格式
+func TimelineFormats() []string {
+	return []string{TimelineFormatOTIO, TimelineFormatFCPXML, TimelineFormatXMEML, TimelineFormatMLT}
+}
+
+// chapterTimeline 读取章节目录中的 timeline.json，不存在或 rebuild 为 true 时按章节素材重新生成
+func (p *Processor) chapterTimeline(chapterDir string, rebuild bool) (*timeline.Timeline, error) {
+	if !rebuild {
+		if _, err := os.Stat(filepath.Join(chapterDir, timeline.FileName)); err == nil {
+			tl, err := timeline.Load(chapterDir)
+			if err == nil && tl.TrackByRole(timeline.RoleBGM) == nil {
+				// 添加背景音乐之前保存的时间线，导出和渲染的结果中不会有背景音乐
+				if bgm := capcut.ChapterBGMFile(chapterDir); bgm != "" {
+					p.logger.Warn("timeline.json 中没有背景音乐轨道，使用 rebuild 重新生成时间线后才会包含背景音乐", zap.String("bgm", bgm))
+				}
+			}
+			return tl, err
+		}
+	}
+	if _, err := p.generateTimeline(chapterDir); err != nil {
+		return nil, err
+	}
+	return timeline.Load(chapterDir)
+}
+
+// ExportTimeline 将章节时间线导出为指定格式，output 为空时写入章节目录下的 <章节名>.<扩展名>，返回输出文件路径
+func (p *Processor) ExportTimeline(chapterDir, format, output string, rebuild bool) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		format = TimelineFormatOTIO
+	}
+	exporter, ok := timelineExporters[format]
+	if !ok {
+		return "", fmt.Errorf("不支持的时间线导出格式: %s，可选: %s", format, strings.Join(TimelineFormats(), ", "))
+	}
+
+	tl, err := p.chapterTimeline(chapterDir, rebuild)
+	if err != nil {
+		return "", err
+	}
+	if output == "" {
+		output = filepath.Join(chapterDir, filepath.Base(filepath.Clean(chapterDir))+exporter.ext)
+	}
+	if err := exporter.export(tl, output); err != nil {
+		return "", err
+	}
+	p.logger.Info("时间线已导出", zap.String("format", format), zap.String("output", output))
+	return output, nil
+}
+
+// ImportTimeline 读取剪辑软件修改后的时间线文件，保存为章节目录下的 timeline.json；
+// chapterDir 为空时使用时间线文件所在目录，返回保存的路径
+func (p *Processor) ImportTimeline(path, chapterDir string) (string, *timeline.Timeline, error) {
+	importer, ok := timelineImporters[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return "", nil, fmt.Errorf("不支持导入的时间线文件: %s", filepath.Base(path))
+	}
+	tl, err := importer(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if chapterDir == "" {
+		chapterDir = filepath.Dir(path)
+	}
+	saved, err := tl.Save(chapterDir)
+	if err != nil {
+		return "", nil, err
+	}
+	p.logger.Info("时间线已导入", zap.String("source", path), zap.String("timeline", saved))
+	return saved, tl, nil
+}
+
+// 渲染引擎
+const (
+	RenderEngineFFmpeg = "ffmpeg" // 按渲染计划生成 filter_complex 脚本后调用 ffmpeg
+	RenderEngineMelt   = "melt"   // 导出 MLT 项目后调用 melt
+)
+
+// RenderTimeline 用指定引擎把章节时间线渲染成视频，engine 为空时使用 ffmpeg，返回视频文件路径
+func (p *Processor) RenderTimeline(chapterDir, engine, output string, rebuild bool) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(engine)) {
+	case "", RenderEngineFFmpeg:
+		return p.RenderTimelineFFmpeg(chapterDir, output, rebuild)
+	case RenderEngineMelt:
+		_, rendered, err := p.RenderTimelineMLT(chapterDir, output, rebuild)
+		return rendered, err
+	default:
+		return "", fmt.Errorf("不支持的渲染引擎: %s，可选: %s, %s", engine, RenderEngineFFmpeg, RenderEngineMelt)
+	}
+}
+
+// RenderTimelineFFmpeg 按 video.export 配置生成 ffmpeg 渲染计划并执行，output 为空时写入章节目录下的 <章节名>.<format>
+func (p *Processor) RenderTimelineFFmpeg(chapterDir, output string, rebuild bool) (string, error) {
+	tl, err := p.chapterTimeline(chapterDir, rebuild)
+	if err != nil {
+		return "", err
+	}
+	opts := video.RenderOptionsFromConfig()
+	if output == "" {
+		format := opts.Export.Format
+		if format == "" {
+			format = "mp4"
+		}
+		output = filepath.Join(chapterDir, filepath.Base(filepath.Clean(chapterDir))+"."+format)
+	}
+	plan, err := video.PlanRender(tl, output, opts)
+	if err != nil {
+		return "", err
+	}
+	if err := p.videoTool.RunRenderPlan(plan, viper.GetString("video.ffmpeg_path")); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// RenderTimelineMLT 将章节时间线导出为 MLT 项目并用 melt 渲染成视频，output 为空时写入章节目录下的 <章节名>.mp4，
+// 返回 MLT 项目和视频文件路径
+func (p *Processor) RenderTimelineMLT(chapterDir, output string, rebuild bool) (string, string, error) {
+	opts := meltOptionsFromConfig()
+	if _, err := mlt.LookMelt(opts.Melt); err != nil {
+		return "", "", err
+	}
+	project, err := p.ExportTimeline(chapterDir, TimelineFormatMLT, "", rebuild)
+	if err != nil {
+		return "", "", err
+	}
+	if output == "" {
+		output = strings.TrimSuffix(project, mlt.Ext) + ".mp4"
+	}
+
+	p.logger.Info("开始melt渲染", zap.String("project", project), zap.String("output", output))
+	if err := mlt.Render(project, output, opts); err != nil {
+		return project, "", err
+	}
+	p.logger.Info("melt渲染完成", zap.String("output", output))
+	return project, output, nil
+}
+
+// meltOptionsFromConfig 读取 video.melt_path 和 video.export 中的编码配置
+func meltOptionsFromConfig() mlt.RenderOptions {
+	return mlt.RenderOptions{
+		Melt:       viper.GetString("video.melt_path"),
+		VideoCodec: viper.GetString("video.export.codec"),
+		Bitrate:    viper.GetString("video.export.bitrate"),
+		Preset:     viper.GetString("video.export.preset"),
+	}
+}
+
+// RenderAnimatic 不依赖剪映和 ffmpeg，把章节时间线绘制成低分辨率的分镜预览（gif 或 png 序列，为空时使用 video.animatic.format）。
+// 预览写在旁白音频旁边，命名为 <旁白文件名>_animatic，供网页跟随旁白播放和拖动；返回预览索引文件路径
+func (p *Processor) RenderAnimatic(chapterDir, format string, rebuild bool) (string, *video.Animatic, error) {
+	tl, err := p.chapterTimeline(chapterDir, rebuild)
+	if err != nil {
+		return "", nil, err
+	}
+	opts := video.AnimaticOptionsFromConfig()
+	if format != "" {
+		opts.Format = format
+	}
+	if opts.Format == "" {
+		opts.Format = video.AnimaticGIF
+	}
+	opts.Format = strings.ToLower(strings.TrimSpace(opts.Format))
+
+	dir, base := chapterDir, filepath.Base(filepath.Clean(chapterDir))
+	if narration := tl.TrackByRole(timeline.RoleNarration); narration != nil && len(narration.Clips) > 0 {
+		audio := narration.Clips[0].Path
+		if _, err := os.Stat(audio); err == nil {
+			dir = filepath.Dir(audio)
+			base = strings.TrimSuffix(filepath.Base(audio), filepath.Ext(audio))
+		}
+	}
+	output := filepath.Join(dir, base+"_animatic")
+	if opts.Format == video.AnimaticGIF {
+		output += ".gif"
+	}
+
+	result, err := video.RenderAnimatic(tl, output, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	if result.Font == "" {
+		p.logger.Warn("分镜预览没有找到中文字体，请在 image.font_path 中配置字体文件")
+	}
+	index := video.AnimaticIndexPath(output)
+	p.logger.Info("分镜预览已生成", zap.String("output", output), zap.Int("frames", result.Frames))
+	return index, result, nil
+}